@@ -0,0 +1,271 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/cmd/trickster/config"
+	"github.com/trickstercache/trickster/v2/pkg/cache"
+	"github.com/trickstercache/trickster/v2/pkg/cache/badger"
+	"github.com/trickstercache/trickster/v2/pkg/cache/bbolt"
+	"github.com/trickstercache/trickster/v2/pkg/cache/filesystem"
+	"github.com/trickstercache/trickster/v2/pkg/cache/index"
+	"github.com/trickstercache/trickster/v2/pkg/cache/inspect"
+	"github.com/trickstercache/trickster/v2/pkg/cache/memory"
+	co "github.com/trickstercache/trickster/v2/pkg/cache/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache/providers"
+	"github.com/trickstercache/trickster/v2/pkg/locks"
+	ph "github.com/trickstercache/trickster/v2/pkg/proxy/handlers"
+	"github.com/trickstercache/trickster/v2/pkg/routing"
+
+	"github.com/gorilla/mux"
+)
+
+const cacheUsageText = `
+Trickster Cache Inspection Usage:
+
+ Inspecting how a backend caches a request, against a running instance's metrics port:
+  trickster cache inspect -endpoint http://localhost:8481 -backend prom1 -url '/api/v1/query_range?query=up&start=1&end=2&step=1' [-method GET] [-header 'Name: value']
+
+ Listing the largest keys in a running instance's cache:
+  trickster cache keys -endpoint http://localhost:8481 -cache default [-order size|access_count|last_access|eviction] [-limit 100]
+
+ Against an offline filesystem, bbolt or badger cache, using the configuration file of a stopped instance:
+  trickster cache inspect -config /path/to/file.yaml -backend prom1 -url '/api/v1/query_range?...'
+  trickster cache keys -config /path/to/file.yaml -cache default
+`
+
+var errOfflineProvider = errors.New("offline inspection is only supported for filesystem, bbolt and badger caches")
+
+// headerFlags collects repeated -header flags
+type headerFlags []string
+
+func (h *headerFlags) String() string {
+	return strings.Join(*h, ", ")
+}
+
+func (h *headerFlags) Set(v string) error {
+	if !strings.Contains(v, ":") {
+		return errors.New("headers must be formatted as 'Name: value'")
+	}
+	*h = append(*h, v)
+	return nil
+}
+
+func (h headerFlags) header() http.Header {
+	out := make(http.Header)
+	for _, v := range h {
+		name, value, _ := strings.Cut(v, ":")
+		out.Add(textproto.TrimString(name), textproto.TrimString(value))
+	}
+	return out
+}
+
+// cacheCommand holds the parsed arguments of the cache subcommand
+type cacheCommand struct {
+	action     string
+	endpoint   string
+	path       string
+	configPath string
+	backend    string
+	url        string
+	method     string
+	headers    headerFlags
+	cacheName  string
+	order      string
+	limit      int
+}
+
+// runCacheCommand runs the cache inspection subcommand with the provided arguments,
+// and returns the process exit code
+func runCacheCommand(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || (args[0] != "inspect" && args[0] != "keys") {
+		fmt.Fprint(stderr, cacheUsageText)
+		return 2
+	}
+	cmd := &cacheCommand{action: args[0]}
+	fs := flag.NewFlagSet(applicationName+" cache "+cmd.action, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { fmt.Fprint(stderr, cacheUsageText) }
+	fs.StringVar(&cmd.endpoint, "endpoint", "", "base URL of a running instance's metrics listener")
+	fs.StringVar(&cmd.path, "path", config.DefaultCacheHandlerPath,
+		"cache handler path of the running instance")
+	fs.StringVar(&cmd.configPath, "config", "",
+		"configuration file used to open an offline cache")
+	if cmd.action == "inspect" {
+		fs.StringVar(&cmd.backend, "backend", "", "name of the backend that serves the request")
+		fs.StringVar(&cmd.url, "url", "", "URL of the inspected request")
+		fs.StringVar(&cmd.method, "method", http.MethodGet, "method of the inspected request")
+		fs.Var(&cmd.headers, "header", "header of the inspected request, as 'Name: value'; repeatable")
+	} else {
+		fs.StringVar(&cmd.cacheName, "cache", "default", "name of the cache")
+		fs.StringVar(&cmd.order, "order", index.OrderSize,
+			"key order: size, access_count, last_access or eviction")
+		fs.IntVar(&cmd.limit, "limit", ph.DefaultCacheKeysLimit, "maximum number of keys listed")
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if (cmd.endpoint == "") == (cmd.configPath == "") {
+		fmt.Fprintln(stderr, "exactly one of -endpoint or -config must be provided")
+		return 2
+	}
+	if cmd.action == "inspect" && (cmd.backend == "" || cmd.url == "") {
+		fmt.Fprintln(stderr, "the -backend and -url flags are required")
+		return 2
+	}
+
+	var b []byte
+	var err error
+	if cmd.endpoint != "" {
+		b, err = cmd.requestOnline()
+	} else {
+		b, err = cmd.runOffline()
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err.Error())
+		return 1
+	}
+	stdout.Write(append(b, '\n'))
+	return 0
+}
+
+// requestOnline requests the inspection from the cache handlers of a running instance
+func (cmd *cacheCommand) requestOnline() ([]byte, error) {
+	qp := make(url.Values)
+	if cmd.action == "inspect" {
+		qp.Set("backend", cmd.backend)
+		qp.Set("url", cmd.url)
+		qp.Set("method", cmd.method)
+		qp["header"] = cmd.headers
+	} else {
+		qp.Set("cache", cmd.cacheName)
+		qp.Set("order", cmd.order)
+		qp.Set("limit", strconv.Itoa(cmd.limit))
+	}
+	u := strings.TrimSuffix(cmd.endpoint, "/") + "/" +
+		strings.Trim(cmd.path, "/") + "/" + cmd.action + "?" + qp.Encode()
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+	return b, nil
+}
+
+// runOffline performs the inspection against the caches configured by the
+// configuration file, which must not be in use by a running instance
+func (cmd *cacheCommand) runOffline() ([]byte, error) {
+	conf, _, err := config.Load(applicationName, applicationVersion,
+		[]string{"-config", cmd.configPath})
+	if err != nil {
+		return nil, err
+	}
+	if cmd.action == "keys" {
+		opts, ok := conf.Caches[cmd.cacheName]
+		if !ok {
+			return nil, fmt.Errorf("unknown cache: %s", cmd.cacheName)
+		}
+		c, err := openOfflineCache(cmd.cacheName, opts)
+		if err != nil {
+			return nil, err
+		}
+		defer c.Close()
+		keys, err := inspect.TopKeys(c, cmd.order, cmd.limit)
+		if err != nil {
+			return nil, err
+		}
+		return json.MarshalIndent(keys, "", "  ")
+	}
+
+	o, ok := conf.Backends[cmd.backend]
+	if !ok {
+		return nil, inspect.ErrUnknownBackend
+	}
+	// only the backend's cache is opened; the others are replaced by empty memory
+	// caches, since the routes of every backend are registered
+	caches := make(map[string]cache.Cache, len(conf.Caches))
+	defer func() {
+		for _, c := range caches {
+			c.Close()
+		}
+	}()
+	for k, opts := range conf.Caches {
+		if k == o.CacheName {
+			c, err := openOfflineCache(k, opts)
+			if err != nil {
+				return nil, err
+			}
+			caches[k] = c
+			continue
+		}
+		mo := co.New()
+		mo.Name = k
+		c := &memory.Cache{Name: k, Config: mo}
+		c.SetLocker(locks.NewNamedLocker())
+		c.Connect()
+		caches[k] = c
+	}
+	clients, err := routing.RegisterProxyRoutes(conf, mux.NewRouter(), http.NewServeMux(),
+		caches, nil, nil, false)
+	if err != nil {
+		return nil, err
+	}
+	rpt, err := inspect.Request(clients, cmd.backend, cmd.method, cmd.url, cmd.headers.header())
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(rpt, "", "  ")
+}
+
+// openOfflineCache opens a cache that persists across restarts
+func openOfflineCache(name string, opts *co.Options) (cache.Cache, error) {
+	var c cache.Cache
+	switch opts.ProviderID {
+	case providers.Filesystem:
+		c = &filesystem.Cache{Name: name, Config: opts}
+	case providers.Bbolt:
+		c = &bbolt.Cache{Name: name, Config: opts}
+	case providers.BadgerDB:
+		c = &badger.Cache{Name: name, Config: opts}
+	default:
+		return nil, errOfflineProvider
+	}
+	c.SetLocker(locks.NewNamedLocker())
+	if err := c.Connect(); err != nil {
+		return nil, fmt.Errorf("could not open cache %s: %w", name, err)
+	}
+	return c, nil
+}
@@ -0,0 +1,170 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/cmd/trickster/config"
+	"github.com/trickstercache/trickster/v2/pkg/cache"
+	"github.com/trickstercache/trickster/v2/pkg/cache/inspect"
+	"github.com/trickstercache/trickster/v2/pkg/cache/memory"
+	co "github.com/trickstercache/trickster/v2/pkg/cache/options"
+	"github.com/trickstercache/trickster/v2/pkg/routing"
+)
+
+func TestRunCacheCommandUsage(t *testing.T) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	tests := [][]string{
+		{},
+		{"invalid"},
+		{"keys", "-invalid"},
+		{"keys"},
+		{"keys", "-endpoint", "http://0", "-config", "x.yaml"},
+		{"inspect", "-endpoint", "http://0"},
+		{"inspect", "-endpoint", "http://0", "-backend", "test", "-url", "/", "-header", "invalid"},
+	}
+	for _, args := range tests {
+		if code := runCacheCommand(args, stdout, stderr); code != 2 {
+			t.Errorf("%v: expected %d got %d", args, 2, code)
+		}
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("unexpected output %s", stdout.String())
+	}
+}
+
+func TestRunCacheCommandOnline(t *testing.T) {
+	c, err := memory.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.Store("key1", []byte("value"), time.Minute)
+	mr := http.NewServeMux()
+	routing.RegisterCacheHandlers(mr, config.DefaultCacheHandlerPath, nil,
+		map[string]cache.Cache{"default": c})
+	ts := httptest.NewServer(mr)
+	defer ts.Close()
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := runCacheCommand([]string{"keys", "-endpoint", ts.URL}, stdout, stderr)
+	if code != 0 {
+		t.Fatalf("expected %d got %d: %s", 0, code, stderr.String())
+	}
+	var keys []inspect.KeyInfo
+	if err = json.Unmarshal(stdout.Bytes(), &keys); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].Key != "key1" {
+		t.Errorf("unexpected keys %+v", keys)
+	}
+
+	code = runCacheCommand([]string{"keys", "-endpoint", ts.URL, "-cache", "missing"},
+		stdout, stderr)
+	if code != 1 {
+		t.Errorf("expected %d got %d", 1, code)
+	}
+}
+
+func TestRunCacheCommandOffline(t *testing.T) {
+	dir := t.TempDir()
+	confFile := filepath.Join(dir, "trickster.yaml")
+	err := os.WriteFile(confFile, []byte(`
+caches:
+  default:
+    provider: filesystem
+    filesystem:
+      cache_path: `+filepath.Join(dir, "cache")+`
+    index:
+      flush_interval_ms: 10
+  other:
+    provider: memory
+backends:
+  prom:
+    provider: prometheus
+    origin_url: http://127.0.0.1:9090
+    cache_name: default
+  prom2:
+    provider: prometheus
+    origin_url: http://127.0.0.1:9091
+    cache_name: other
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf, _, err := config.Load(applicationName, applicationVersion, []string{"-config", confFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := openOfflineCache("default", conf.Caches["default"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Store("key1", []byte("value"), time.Minute)
+	// allow the index to be flushed to disk
+	time.Sleep(100 * time.Millisecond)
+	c.Close()
+
+	if _, err = openOfflineCache("other", co.New()); err != errOfflineProvider {
+		t.Errorf("expected %v got %v", errOfflineProvider, err)
+	}
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := runCacheCommand([]string{"keys", "-config", confFile}, stdout, stderr)
+	if code != 0 {
+		t.Fatalf("expected %d got %d: %s", 0, code, stderr.String())
+	}
+	var keys []inspect.KeyInfo
+	if err = json.Unmarshal(stdout.Bytes(), &keys); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].Key != "key1" {
+		t.Errorf("unexpected keys %+v", keys)
+	}
+
+	stdout.Reset()
+	end := time.Now().Unix()
+	code = runCacheCommand([]string{"inspect", "-config", confFile, "-backend", "prom",
+		"-url", fmt.Sprintf("/api/v1/query_range?query=up&start=%d&end=%d&step=60", end-3600, end)},
+		stdout, stderr)
+	if code != 0 {
+		t.Fatalf("expected %d got %d: %s", 0, code, stderr.String())
+	}
+	rpt := &inspect.Report{}
+	if err = json.Unmarshal(stdout.Bytes(), rpt); err != nil {
+		t.Fatal(err)
+	}
+	if rpt.Engine != "DeltaProxyCache" || rpt.Key == "" || rpt.Exists ||
+		rpt.Cache != "default" || rpt.Provider != "filesystem" {
+		t.Errorf("unexpected report %+v", rpt)
+	}
+
+	code = runCacheCommand([]string{"inspect", "-config", confFile, "-backend", "missing",
+		"-url", "/"}, stdout, stderr)
+	if code != 1 {
+		t.Errorf("expected %d got %d", 1, code)
+	}
+}
@@ -17,6 +17,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
@@ -28,17 +29,24 @@ import (
 	"github.com/trickstercache/trickster/v2/cmd/trickster/config"
 	ro "github.com/trickstercache/trickster/v2/cmd/trickster/config/reload/options"
 	"github.com/trickstercache/trickster/v2/cmd/trickster/config/validate"
+	"github.com/trickstercache/trickster/v2/pkg/backends"
 	"github.com/trickstercache/trickster/v2/pkg/backends/alb"
 	"github.com/trickstercache/trickster/v2/pkg/backends/healthcheck"
+	"github.com/trickstercache/trickster/v2/pkg/backends/healthcheck/webhook"
 	"github.com/trickstercache/trickster/v2/pkg/cache"
+	io "github.com/trickstercache/trickster/v2/pkg/cache/index/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache/invalidation"
 	"github.com/trickstercache/trickster/v2/pkg/cache/memory"
+	co "github.com/trickstercache/trickster/v2/pkg/cache/options"
 	"github.com/trickstercache/trickster/v2/pkg/cache/providers"
 	"github.com/trickstercache/trickster/v2/pkg/cache/registration"
 	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
 	"github.com/trickstercache/trickster/v2/pkg/observability/metrics"
+	"github.com/trickstercache/trickster/v2/pkg/observability/tracing"
 	tr "github.com/trickstercache/trickster/v2/pkg/observability/tracing/registration"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/handlers"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/nats"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/warmer"
 	"github.com/trickstercache/trickster/v2/pkg/routing"
 	"github.com/trickstercache/trickster/v2/pkg/runtime"
 
@@ -48,6 +56,8 @@ import (
 var (
 	cfgLock = &sync.Mutex{}
 	hc      healthcheck.HealthChecker
+	warmers warmer.Warmers
+	hooks   webhook.Webhooks
 )
 
 func runConfig(oldConf *config.Config, wg *sync.WaitGroup, logger *tl.Logger,
@@ -114,23 +124,42 @@ func applyConfig(conf, oldConf *config.Config, wg *sync.WaitGroup, logger *tl.Lo
 		conf.ReloadConfig = ro.New()
 	}
 
-	logger = applyLoggingConfig(conf, oldConf, logger)
+	// the previous logger's file, NATS connection and tracers remain in use until
+	// the new configuration is committed, so that a failed or rolled-back reload
+	// can restore them
+	prevLogger := logger
+	logger, retiredLogger := applyLoggingConfig(conf, oldConf, logger)
+	drainTimeout := time.Duration(conf.ReloadConfig.DrainTimeoutMS) * time.Millisecond
 
 	for _, w := range conf.LoaderWarnings {
 		tl.Warn(logger, w, tl.Pairs{})
 	}
 
-	err := nats.InitNATS(conf.Nats)
+	// Register Tracing Configurations
+	tracers, err := tr.RegisterAll(conf, logger, false)
 	if err != nil {
-		handleStartupIssue("nats initialization failed", tl.Pairs{"detail": err.Error()},
+		logger = restoreLogger(logger, prevLogger, oldConf, drainTimeout)
+		handleStartupIssue("tracing registration failed", tl.Pairs{"detail": err.Error()},
 			logger, errorFunc)
 		return err
 	}
 
-	// Register Tracing Configurations
-	tracers, err := tr.RegisterAll(conf, logger, false)
+	prevNATS, err := nats.SwapNATS(conf.Nats)
+	var tracersHeld bool
+	// restore reverts the logging, NATS, invalidation and tracing changes of a
+	// configuration that is not committed
+	restore := func() {
+		nats.RestoreNATS(prevNATS)
+		logger = restoreLogger(logger, prevLogger, oldConf, drainTimeout)
+		applyInvalidationConfig(oldConf, oldCaches, logger)
+		// tracers handed to a listener are shut down by it when it stops
+		if !tracersHeld {
+			go shutdownTracers(tracers, logger)
+		}
+	}
 	if err != nil {
-		handleStartupIssue("tracing registration failed", tl.Pairs{"detail": err.Error()},
+		restore()
+		handleStartupIssue("nats initialization failed", tl.Pairs{"detail": err.Error()},
 			logger, errorFunc)
 		return err
 	}
@@ -141,27 +170,89 @@ func applyConfig(conf, oldConf *config.Config, wg *sync.WaitGroup, logger *tl.Lo
 
 	router.HandleFunc(conf.Main.PingHandlerPath, handlers.PingHandleFunc(conf)).Methods(http.MethodGet)
 
-	caches := applyCachingConfig(conf, oldConf, logger, oldCaches)
+	caches, retired := applyCachingConfig(conf, oldConf, logger, oldCaches)
+	applyInvalidationConfig(conf, caches, logger)
 	rh := handlers.ReloadHandleFunc(runConfig, conf, wg, logger, caches, args)
 
 	o, err := routing.RegisterProxyRoutes(conf, router, mr, caches, tracers, logger, false)
 	if err != nil {
+		restore()
 		handleStartupIssue("route registration failed", tl.Pairs{"detail": err.Error()},
 			logger, errorFunc)
 		return err
 	}
 
-	if hc != nil {
-		hc.Shutdown()
+	// when the reload is guarded, the previous health checker and routers are retained
+	// until the new configuration's backends are verified, so they can be rolled back to
+	prevHC := hc
+	guarded := prevHC != nil && oldConf != nil && conf.ReloadConfig.IsGuarded()
+	if prevHC != nil && !guarded {
+		prevHC.Shutdown()
+	}
+	var prevRouters map[string]http.Handler
+	if guarded {
+		prevRouters = lg.Routers()
 	}
-	hc, err = o.StartHealthChecks(logger)
+	// health webhooks are started and subscribed to the new health checker's
+	// transitions before its health checks start, so that no transition is missed
+	newHooks := webhook.StartAll(conf.HealthWebhooks, logger)
+	hc, err = o.StartHealthChecks(logger, newHooks.Notify)
 	if err != nil {
+		newHooks.Stop()
+		if guarded {
+			hc = prevHC
+		}
+		restore()
 		return err
 	}
 	alb.StartALBPools(o, hc.Statuses())
 	routing.RegisterDefaultBackendRoutes(router, o, logger, tracers)
 	routing.RegisterHealthHandler(mr, conf.Main.HealthHandlerPath, hc)
-	applyListenerConfigs(conf, oldConf, router, http.HandlerFunc(rh), mr, logger, tracers)
+	routing.RegisterCacheHandlers(mr, conf.Main.CacheHandlerPath, o, caches)
+	tracersHeld = applyListenerConfigs(conf, oldConf, router, http.HandlerFunc(rh), mr,
+		logger, tracers)
+
+	if guarded {
+		if err = guardReload(conf.ReloadConfig, hc); err != nil {
+			lg.RestoreRouters(prevRouters)
+			stopBackends(o, hc)
+			hc = prevHC
+			newHooks.Stop()
+			// close any caches that were created for the rolled-back configuration
+			unused := make([]cache.Cache, 0, len(caches))
+			for k, c := range caches {
+				if oc, ok := oldCaches[k]; !ok || oc != c {
+					unused = append(unused, c)
+				}
+			}
+			go closeCaches(unused, drainTimeout)
+			restore()
+			metrics.ReloadRollbacks.Inc()
+			handleStartupIssue("configuration reload rolled back",
+				tl.Pairs{"detail": err.Error()}, logger, nil)
+			return err
+		}
+		prevHC.Shutdown()
+	}
+	invalidateChangedBackends(conf, oldConf)
+	go closeCaches(retired, drainTimeout)
+	if prevNATS != nil {
+		prevNATS.Drain()
+	}
+	if retiredLogger != nil {
+		// the extra 1s allows HTTP listeners to close first and finish their log writes
+		go delayedLogCloser(retiredLogger, drainTimeout+time.Second)
+	}
+
+	// warmers are restarted so they issue requests through the new backends' routers
+	warmers.Stop()
+	if warmers, err = warmer.StartAll(conf.Warmers, o, logger); err != nil {
+		tl.Warn(logger, "cache warmers not started", tl.Pairs{"detail": err.Error()})
+	}
+
+	// the previous health webhooks are replaced by those of the new health checker
+	hooks.Stop()
+	hooks = newHooks
 
 	metrics.LastReloadSuccessfulTimestamp.Set(float64(time.Now().Unix()))
 	metrics.LastReloadSuccessful.Set(1)
@@ -169,15 +260,18 @@ func applyConfig(conf, oldConf *config.Config, wg *sync.WaitGroup, logger *tl.Lo
 	if oldConf != nil && oldConf.Resources != nil {
 		oldConf.Resources.QuitChan <- true // this signals the old hup monitor goroutine to exit
 	}
+	setActiveCaches(caches)
 	startHupMonitor(conf, wg, logger, caches, args)
 	startKubeController(conf, wg, logger, caches, args)
 
 	return nil
 }
 
-func applyLoggingConfig(c, o *config.Config, oldLog *tl.Logger) *tl.Logger {
+// applyLoggingConfig returns the logger for the new config, along with the previous
+// logger when it is replaced and its log file should be closed once the config is committed
+func applyLoggingConfig(c, o *config.Config, oldLog *tl.Logger) (*tl.Logger, *tl.Logger) {
 	if c == nil || c.Logging == nil {
-		return oldLog
+		return oldLog, nil
 	}
 
 	if c.ReloadConfig == nil {
@@ -189,52 +283,127 @@ func applyLoggingConfig(c, o *config.Config, oldLog *tl.Logger) *tl.Logger {
 			c.Logging.LogLevel == o.Logging.LogLevel {
 			// no changes in logging config,
 			// so we keep the old logger intact
-			return oldLog
+			return oldLog, nil
 		}
 		if c.Logging.LogFile != o.Logging.LogFile {
 			if o.Logging.LogFile != "" {
-				// if we're changing from file1 -> console or file1 -> file2, close file1 handle
-				// the extra 1s allows HTTP listeners to close first and finish their log writes
-				go delayedLogCloser(oldLog,
-					time.Duration(c.ReloadConfig.DrainTimeoutMS+1000)*time.Millisecond)
+				// if we're changing from file1 -> console or file1 -> file2, file1 is
+				// closed once the config is committed
+				return initLogger(c), oldLog
 			}
-			return initLogger(c)
+			return initLogger(c), nil
 		}
 		if c.Logging.LogLevel != o.Logging.LogLevel {
 			// the only change is the log level, so update it and return the original logger
 			oldLog.SetLogLevel(c.Logging.LogLevel)
-			return oldLog
+			return oldLog, nil
 		}
 	}
 
-	return initLogger(c)
+	return initLogger(c), nil
+}
+
+// restoreLogger reverts the changes made by applyLoggingConfig for a config that is
+// not committed, closing the new logger when it replaced the previous one, and
+// returns the previous logger
+func restoreLogger(logger, prevLogger *tl.Logger, oc *config.Config,
+	delay time.Duration,
+) *tl.Logger {
+	if prevLogger == nil {
+		return logger
+	}
+	if logger != prevLogger {
+		go delayedLogCloser(logger, delay+time.Second)
+	} else if oc != nil && oc.Logging != nil {
+		prevLogger.SetLogLevel(oc.Logging.LogLevel)
+	}
+	return prevLogger
+}
+
+// shutdownTracers flushes and shuts down tracers that were registered for a
+// config that is not committed
+func shutdownTracers(tracers tracing.Tracers, logger *tl.Logger) {
+	for _, t := range tracers {
+		if t == nil || t.ShutdownFunc == nil {
+			continue
+		}
+		if err := t.ShutdownFunc(context.Background()); err != nil {
+			tl.Error(logger, "tracer shutdown failed", tl.Pairs{"detail": err.Error()})
+		}
+	}
 }
 
+// applyCachingConfig returns the caches for the new config, reusing unchanged caches from the
+// old config, along with the list of old caches that are no longer in use and should be closed
 func applyCachingConfig(c, oc *config.Config, logger *tl.Logger,
 	oldCaches map[string]cache.Cache,
-) map[string]cache.Cache {
+) (map[string]cache.Cache, []cache.Cache) {
 	if c == nil {
-		return nil
+		return nil, nil
 	}
 
 	caches := make(map[string]cache.Cache)
+	// tiered caches use the standalone caches as their tiers, so they are made last
+	tiers := func(name string) cache.Cache {
+		if w, ok := caches[name]; ok {
+			return invalidation.Unwrap(w)
+		}
+		return nil
+	}
+	// tiersReused returns true unless the config is for a tiered cache, and one of its
+	// standalone tiers was made anew, in which case the tiered cache is made anew too,
+	// so that it doesn't keep using the retired tier
+	tiersReused := func(cfg *co.Options) bool {
+		if !registration.IsTiered(cfg) || cfg.Tiered == nil {
+			return true
+		}
+		for _, n := range []string{cfg.Tiered.L1CacheName, cfg.Tiered.L2CacheName} {
+			if w, ok := caches[n]; ok && w != oldCaches[n] {
+				return false
+			}
+		}
+		return true
+	}
+	names := make([]string, 0, len(c.Caches))
+	for k, v := range c.Caches {
+		if !registration.IsTiered(v) {
+			names = append(names, k)
+		}
+	}
+	for k, v := range c.Caches {
+		if registration.IsTiered(v) {
+			names = append(names, k)
+		}
+	}
 
 	if oc == nil || oldCaches == nil {
-		for k, v := range c.Caches {
-			caches[k] = registration.NewCache(k, v, logger)
+		for _, k := range names {
+			caches[k] = invalidation.Wrap(k,
+				registration.NewCacheWithTiers(k, c.Caches[k], logger, tiers))
 		}
-		return caches
+		return caches, nil
 	}
 
-	for k, v := range c.Caches {
+	var retired []cache.Cache
+
+	for _, k := range names {
+		v := c.Caches[k]
 
 		if w, ok := oldCaches[k]; ok {
 
 			ocfg := w.Configuration()
 
+			// the Index is partitioned into a fixed number of shards, as are the memory
+			// cache's objects, so a changed shard count requires a new cache
+			sameShards := indexShardCount(v) == indexShardCount(ocfg)
+			if !sameShards {
+				tl.Info(logger, "cache index shard count changed, recreating cache",
+					tl.Pairs{"cacheName": k, "shardCount": indexShardCount(v)})
+			}
+
 			// if a cache is in both the old and new config, and unchanged, pass the
 			// pre-existing object instead of making a new one
-			if v.Equal(ocfg) {
+			if v.Equal(ocfg) && tiersReused(v) && sameShards {
 				caches[k] = w
 				continue
 			}
@@ -244,26 +413,85 @@ func applyCachingConfig(c, oc *config.Config, logger *tl.Logger,
 			// is the only change. In this case, we'll apply the new index configuration,
 			// then add the old cache with the new index config to the new cache map
 			if ocfg.ProviderID == v.ProviderID &&
-				ocfg.ProviderID == providers.Memory {
+				ocfg.ProviderID == providers.Memory && sameShards {
 				if v.Index != nil {
-					mc := w.(*memory.Cache)
+					mc := invalidation.Unwrap(w).(*memory.Cache)
 					mc.Index.UpdateOptions(v.Index)
 				}
 				caches[k] = w
 				continue
 			}
 
-			// if we got to this point, the cache won't be used, so lets close it
-			go func() {
-				time.Sleep(time.Millisecond * time.Duration(c.ReloadConfig.DrainTimeoutMS))
-				w.Close()
-			}()
+			// if we got to this point, the cache won't be used, so it can be closed
+			// once the old config's requests have drained
+			retired = append(retired, w)
 		}
 
 		// the newly-named cache is not in the old config or couldn't be reused, so make it anew
-		caches[k] = registration.NewCache(k, v, logger)
+		caches[k] = invalidation.Wrap(k, registration.NewCacheWithTiers(k, v, logger, tiers))
+	}
+	return caches, retired
+}
+
+// indexShardCount returns the number of shards that the cache's Index is partitioned into
+func indexShardCount(o *co.Options) int {
+	if o.Index == nil || o.Index.ShardCount < 1 {
+		return io.DefaultShardCount
+	}
+	return o.Index.ShardCount
+}
+
+// applyInvalidationConfig starts the cross-instance cache invalidation bus for the caches,
+// when the config provides a NATS invalidation subject
+func applyInvalidationConfig(c *config.Config, caches map[string]cache.Cache,
+	logger *tl.Logger,
+) {
+	if c == nil {
+		return
+	}
+	var b invalidation.Bus
+	if c.Nats != nil && c.Nats.InvalidationSubject != "" {
+		nc, err := nats.Connection()
+		if err != nil {
+			tl.Warn(logger, "cache invalidation bus not started", tl.Pairs{"detail": err.Error()})
+		} else {
+			b = invalidation.NewNATSBus(nc, c.Nats.InvalidationSubject)
+		}
+	}
+	if err := invalidation.Start(b, caches); err != nil {
+		tl.Warn(logger, "cache invalidation bus not started", tl.Pairs{"detail": err.Error()})
+	}
+}
+
+// invalidateChangedBackends invalidates the cache key prefixes of backends that were
+// removed or changed by a reload. It is called only once the reload is committed,
+// since a rolled-back reload can't restore the invalidated objects
+func invalidateChangedBackends(c, oc *config.Config) {
+	if c == nil || oc == nil {
+		return
+	}
+	for k, o := range oc.Backends {
+		if o == nil || !backends.UsesCache(o.Provider) || o.CacheKeyPrefix == "" {
+			continue
+		}
+		if n, ok := c.Backends[k]; ok && n.CacheName == o.CacheName &&
+			n.CacheKeyPrefix == o.CacheKeyPrefix {
+			continue
+		}
+		invalidation.InvalidatePrefix(o.CacheName, o.CacheKeyPrefix+".")
+	}
+}
+
+// closeCaches closes the provided caches after waiting for the delay, which allows
+// outstanding requests to drain
+func closeCaches(caches []cache.Cache, delay time.Duration) {
+	if len(caches) == 0 {
+		return
+	}
+	time.Sleep(delay)
+	for _, c := range caches {
+		c.Close()
 	}
-	return caches
 }
 
 func initLogger(c *config.Config) *tl.Logger {
@@ -26,6 +26,7 @@ import (
 	"time"
 
 	reload "github.com/trickstercache/trickster/v2/cmd/trickster/config/reload/options"
+	hwo "github.com/trickstercache/trickster/v2/pkg/backends/healthcheck/webhook/options"
 	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
 	rule "github.com/trickstercache/trickster/v2/pkg/backends/rule/options"
 	"github.com/trickstercache/trickster/v2/pkg/cache/negative"
@@ -37,6 +38,7 @@ import (
 	no "github.com/trickstercache/trickster/v2/pkg/proxy/nats/options"
 	rewriter "github.com/trickstercache/trickster/v2/pkg/proxy/request/rewriter"
 	rwopts "github.com/trickstercache/trickster/v2/pkg/proxy/request/rewriter/options"
+	warmer "github.com/trickstercache/trickster/v2/pkg/proxy/warmer/options"
 	"github.com/trickstercache/trickster/v2/pkg/util/yamlx"
 
 	"sigs.k8s.io/yaml"
@@ -68,6 +70,10 @@ type Config struct {
 	RequestRewriters map[string]*rwopts.Options `json:"request_rewriters,omitempty"`
 	// ReloadConfig provides configurations for in-process config reloading
 	ReloadConfig *reload.Options `json:"reloading,omitempty"`
+	// Warmers is a map of the Cache Warmers
+	Warmers map[string]*warmer.Options `json:"warmers,omitempty"`
+	// HealthWebhooks is a map of the webhooks notified of backend health transitions
+	HealthWebhooks map[string]*hwo.Options `json:"health_webhooks,omitempty"`
 
 	// Resources holds runtime resources uses by the Config
 	Resources *Resources `json:"-"`
@@ -92,6 +98,8 @@ type MainConfig struct {
 	ReloadHandlerPath string `json:"reload_handler_path,omitempty"`
 	// HeatlHandlerPath provides the base Health Check Handler path
 	HealthHandlerPath string `json:"health_handler_path,omitempty"`
+	// CacheHandlerPath provides the base path of the Cache Inspection Handlers
+	CacheHandlerPath string `json:"cache_handler_path,omitempty"`
 	// PprofServer provides the name of the http listener that will host the pprof debugging routes
 	// Options are: "metrics", "reload", "both", or "off"; default is both
 	PprofServer string `json:"pprof_server,omitempty"`
@@ -121,6 +129,7 @@ func (in *MainConfig) DeepCopyInto(out *MainConfig) {
 	out.PingHandlerPath = in.PingHandlerPath
 	out.ReloadHandlerPath = in.ReloadHandlerPath
 	out.HealthHandlerPath = in.HealthHandlerPath
+	out.CacheHandlerPath = in.CacheHandlerPath
 	out.PprofServer = in.PprofServer
 	out.ServerName = in.ServerName
 	// out.ReloaderLock        = in.ReloaderLock
@@ -159,6 +168,7 @@ func NewConfig() *Config {
 			PingHandlerPath:   DefaultPingHandlerPath,
 			ReloadHandlerPath: reload.DefaultReloadHandlerPath,
 			HealthHandlerPath: DefaultHealthHandlerPath,
+			CacheHandlerPath:  DefaultCacheHandlerPath,
 			PprofServer:       DefaultPprofServerName,
 			ServerName:        hn,
 		},
@@ -264,6 +274,14 @@ func (c *Config) SetDefaults(metadata yamlx.KeyLookup) error {
 		return err
 	}
 
+	if err = warmer.Lookup(c.Warmers).SetDefaults(c.Backends); err != nil {
+		return err
+	}
+
+	if err = hwo.Lookup(c.HealthWebhooks).SetDefaults(c.Backends); err != nil {
+		return err
+	}
+
 	serveTLS, err := ol.ValidateTLSConfigs()
 	if err != nil {
 		return err
@@ -299,6 +317,7 @@ func (c *Config) Clone() *Config {
 	nc.Main.PingHandlerPath = c.Main.PingHandlerPath
 	nc.Main.ReloadHandlerPath = c.Main.ReloadHandlerPath
 	nc.Main.HealthHandlerPath = c.Main.HealthHandlerPath
+	nc.Main.CacheHandlerPath = c.Main.CacheHandlerPath
 	nc.Main.PprofServer = c.Main.PprofServer
 	nc.Main.ServerName = c.Main.ServerName
 
@@ -313,6 +332,10 @@ func (c *Config) Clone() *Config {
 		nc.Frontend = c.Frontend.Clone()
 	}
 
+	if c.Nats != nil {
+		nc.Nats = c.Nats.Clone()
+	}
+
 	nc.Resources = &Resources{
 		QuitChan: make(chan bool, 1),
 	}
@@ -351,6 +374,20 @@ func (c *Config) Clone() *Config {
 		}
 	}
 
+	if len(c.Warmers) > 0 {
+		nc.Warmers = make(map[string]*warmer.Options, len(c.Warmers))
+		for k, v := range c.Warmers {
+			nc.Warmers[k] = v.Clone()
+		}
+	}
+
+	if len(c.HealthWebhooks) > 0 {
+		nc.HealthWebhooks = make(map[string]*hwo.Options, len(c.HealthWebhooks))
+		for k, v := range c.HealthWebhooks {
+			nc.HealthWebhooks[k] = v.Clone()
+		}
+	}
+
 	return nc
 }
 
@@ -383,11 +420,23 @@ func (c *Config) String() string {
 		cp.Backends[k] = o.CloneYAMLSafe()
 	}
 
-	// strip Redis password
+	for k, o := range cp.HealthWebhooks {
+		cp.HealthWebhooks[k] = o.CloneYAMLSafe()
+	}
+
+	// strip Redis password and S3 credentials
 	for k, v := range cp.Caches {
 		if v != nil && cp.Caches[k].Redis.Password != "" {
 			cp.Caches[k].Redis.Password = "*****"
 		}
+		if v != nil && v.S3 != nil {
+			if v.S3.SecretAccessKey != "" {
+				v.S3.SecretAccessKey = "*****"
+			}
+			if v.S3.SessionToken != "" {
+				v.S3.SessionToken = "*****"
+			}
+		}
 	}
 
 	bytes, err := yaml.Marshal(cp)
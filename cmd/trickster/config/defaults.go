@@ -23,6 +23,8 @@ const (
 	DefaultPingHandlerPath = "/trickster/ping"
 	// DefaultHealthHandlerPath defines the default path for the Health Handler
 	DefaultHealthHandlerPath = "/trickster/health"
+	// DefaultCacheHandlerPath defines the default base path for the Cache Inspection Handlers
+	DefaultCacheHandlerPath = "/trickster/cache"
 	// DefaultPprofServerName defines the default Pprof Server Name
 	DefaultPprofServerName = "both"
 )
@@ -23,6 +23,7 @@ import (
 
 	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
 	"github.com/trickstercache/trickster/v2/pkg/cache/negative"
+	cache "github.com/trickstercache/trickster/v2/pkg/cache/options"
 )
 
 // Load returns the Application Configuration, starting with a default config,
@@ -85,8 +86,13 @@ func Load(applicationName string, applicationVersion string, arguments []string)
 	}
 
 	for _, c := range c.Caches {
-		c.Index.FlushInterval = time.Duration(c.Index.FlushIntervalMS) * time.Millisecond
-		c.Index.ReapInterval = time.Duration(c.Index.ReapIntervalMS) * time.Millisecond
+		for _, o := range []*cache.Options{c, c.L1Options, c.L2Options} {
+			if o == nil {
+				continue
+			}
+			o.Index.FlushInterval = time.Duration(o.Index.FlushIntervalMS) * time.Millisecond
+			o.Index.ReapInterval = time.Duration(o.Index.ReapIntervalMS) * time.Millisecond
+		}
 	}
 
 	return c, flags, nil
@@ -24,6 +24,7 @@ import (
 	"time"
 
 	"github.com/trickstercache/trickster/v2/pkg/cache/evictionmethods"
+	"github.com/trickstercache/trickster/v2/pkg/cache/warningpolicies"
 	tlstest "github.com/trickstercache/trickster/v2/pkg/testutil/tls"
 )
 
@@ -203,6 +204,10 @@ func TestFullLoadConfiguration(t *testing.T) {
 		t.Errorf("expected %s, got %s", evictionmethods.EvictionMethodLRU, o.TimeseriesEvictionMethod)
 	}
 
+	if o.TimeseriesWarningPolicy != warningpolicies.WarningPolicySkip {
+		t.Errorf("expected %s, got %s", warningpolicies.WarningPolicySkip, o.TimeseriesWarningPolicy)
+	}
+
 	if !o.FastForwardDisable {
 		t.Errorf("expected fast_forward_disable true, got %t", o.FastForwardDisable)
 	}
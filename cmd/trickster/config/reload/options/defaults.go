@@ -28,4 +28,7 @@ const (
 	DefaultRateLimitMS = 3000
 	// DefaultReloadHandlerPath defines the default path for the Reload Handler
 	DefaultReloadHandlerPath = "/trickster/config/reload"
+	// DefaultGuardUnhealthyBackends is the default number of unhealthy backends that will
+	// cause a guarded reload to be rolled back
+	DefaultGuardUnhealthyBackends = 1
 )
@@ -33,6 +33,16 @@ type Options struct {
 	// This prevents a bad actor from stating the config file with millions of concurrent requests
 	// The rate limit does not apply to SIGHUP-based reload requests
 	RateLimitMS int `json:"rate_limit_ms,omitempty"`
+	// GuardWindowMS enables guarded reloads when > 0. After a reload is applied, Trickster waits
+	// this long for the new backends' health checks to report, and rolls back to the previous
+	// configuration if the unhealthy thresholds below are met
+	GuardWindowMS int `json:"guard_window_ms,omitempty"`
+	// GuardUnhealthyBackends is the number of unhealthy backends at which a guarded reload
+	// is rolled back. A value of 0 disables the count-based threshold
+	GuardUnhealthyBackends int `json:"guard_unhealthy_backends,omitempty"`
+	// GuardUnhealthyPercent is the percentage of health-checked backends that, when unhealthy,
+	// causes a guarded reload to be rolled back. A value of 0 disables the percentage-based threshold
+	GuardUnhealthyPercent int `json:"guard_unhealthy_percent,omitempty"`
 }
 
 // New returns a new Options references with Default Values set
@@ -43,5 +53,13 @@ func New() *Options {
 		HandlerPath:    DefaultReloadHandlerPath,
 		DrainTimeoutMS: DefaultDrainTimeoutMS,
 		RateLimitMS:    DefaultRateLimitMS,
+
+		GuardUnhealthyBackends: DefaultGuardUnhealthyBackends,
 	}
 }
+
+// IsGuarded returns true if guarded reloads are enabled
+func (o *Options) IsGuarded() bool {
+	return o != nil && o.GuardWindowMS > 0 &&
+		(o.GuardUnhealthyBackends > 0 || o.GuardUnhealthyPercent > 0)
+}
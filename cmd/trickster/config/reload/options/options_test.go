@@ -24,3 +24,26 @@ func TestNew(t *testing.T) {
 		t.Error("expected non-nil options")
 	}
 }
+
+func TestIsGuarded(t *testing.T) {
+	var o *Options
+	if o.IsGuarded() {
+		t.Error("expected false")
+	}
+	o = New()
+	if o.IsGuarded() {
+		t.Error("expected false")
+	}
+	o.GuardWindowMS = 5000
+	if !o.IsGuarded() {
+		t.Error("expected true")
+	}
+	o.GuardUnhealthyBackends = 0
+	if o.IsGuarded() {
+		t.Error("expected false")
+	}
+	o.GuardUnhealthyPercent = 50
+	if !o.IsGuarded() {
+		t.Error("expected true")
+	}
+}
@@ -18,6 +18,7 @@
 package reload
 
 import (
+	"errors"
 	"sync"
 
 	"github.com/trickstercache/trickster/v2/cmd/trickster/config"
@@ -29,3 +30,7 @@ import (
 // or gracefully over an existing running Config
 type ReloaderFunc func(*config.Config, *sync.WaitGroup, *tl.Logger,
 	map[string]cache.Cache, []string, func()) error
+
+// ErrReloadRolledBack is returned when a guarded reload was applied, but rolled back
+// because the new configuration's backends failed their health checks
+var ErrReloadRolledBack = errors.New("configuration reload rolled back")
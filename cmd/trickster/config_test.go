@@ -0,0 +1,162 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/cmd/trickster/config"
+	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache"
+	"github.com/trickstercache/trickster/v2/pkg/cache/invalidation"
+	"github.com/trickstercache/trickster/v2/pkg/cache/memory"
+	co "github.com/trickstercache/trickster/v2/pkg/cache/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache/providers"
+	"github.com/trickstercache/trickster/v2/pkg/cache/tiered"
+	to "github.com/trickstercache/trickster/v2/pkg/cache/tiered/options"
+	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
+)
+
+func TestInvalidateChangedBackends(t *testing.T) {
+	c, err := memory.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	invalidation.Start(nil, map[string]cache.Cache{"default": c})
+	defer invalidation.Stop()
+	c.Store("b1.key", []byte("value"), time.Minute)
+	c.Store("b2.key", []byte("value"), time.Minute)
+
+	b2 := &bo.Options{Provider: "prometheus", CacheName: "default", CacheKeyPrefix: "b2"}
+	oc := &config.Config{Backends: map[string]*bo.Options{
+		"b1": {Provider: "prometheus", CacheName: "default", CacheKeyPrefix: "b1"},
+		"b2": b2,
+	}}
+	nc := &config.Config{Backends: map[string]*bo.Options{"b2": b2}}
+
+	// the initial load has no previous config to invalidate
+	invalidateChangedBackends(nc, nil)
+	if _, _, err = c.Retrieve("b1.key", false); err != nil {
+		t.Error(err)
+	}
+
+	invalidateChangedBackends(nc, oc)
+	if _, _, err = c.Retrieve("b1.key", false); err == nil {
+		t.Error("expected the removed backend's objects to be invalidated")
+	}
+	if _, _, err = c.Retrieve("b2.key", false); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRestoreLogger(t *testing.T) {
+	oc := config.NewConfig()
+	oc.Logging.LogLevel = "info"
+	prev := tl.ConsoleLogger("info")
+
+	// a level change is applied to the previous logger, and reverted on rollback
+	c := config.NewConfig()
+	c.Logging.LogLevel = "debug"
+	l, retired := applyLoggingConfig(c, oc, prev)
+	if l != prev || retired != nil || l.Level() != "debug" {
+		t.Errorf("unexpected logger %v retired %v level %s", l, retired, l.Level())
+	}
+	if l = restoreLogger(l, prev, oc, 0); l != prev || l.Level() != "info" {
+		t.Errorf("expected the previous logger at level info, got level %s", l.Level())
+	}
+
+	// a new log file replaces the logger, and the previous logger is restored on rollback
+	c.Logging.LogFile = t.TempDir() + "/trickster.log"
+	l, _ = applyLoggingConfig(c, oc, prev)
+	if l == prev {
+		t.Error("expected a new logger")
+	}
+	if l = restoreLogger(l, prev, oc, 0); l != prev {
+		t.Error("expected the previous logger")
+	}
+}
+
+func TestApplyCachingConfigRecreatedTier(t *testing.T) {
+	newConfig := func(l2Provider string) *config.Config {
+		l1 := co.New()
+		l2 := co.New()
+		l2.Provider, l2.ProviderID = l2Provider, providers.Names[l2Provider]
+		l2.Filesystem.CachePath = t.TempDir()
+		l2.BBolt.Filename = t.TempDir() + "/trickster.db"
+		tc := co.New()
+		tc.Provider, tc.ProviderID = "tiered", providers.Tiered
+		tc.Tiered = &to.Options{L1CacheName: "l1", L2CacheName: "l2"}
+		c := config.NewConfig()
+		c.Caches = co.Lookup{"l1": l1, "l2": l2, "tiered": tc}
+		return c
+	}
+	logger := tl.ConsoleLogger("error")
+	oc := newConfig("filesystem")
+	oldCaches, _ := applyCachingConfig(oc, nil, logger, nil)
+
+	// an unchanged tiered cache is reused along with its tiers
+	caches, retired := applyCachingConfig(newConfig("filesystem"), oc, logger, oldCaches)
+	if caches["tiered"] != oldCaches["tiered"] || len(retired) != 0 {
+		t.Error("expected the tiered cache to be reused")
+	}
+
+	// when a shared tier is made anew, so is the tiered cache that uses it
+	caches, retired = applyCachingConfig(newConfig("bbolt"), oc, logger, oldCaches)
+	defer closeCaches(slices.Collect(maps.Values(caches)), 0)
+	defer closeCaches(retired, 0)
+	if caches["tiered"] == oldCaches["tiered"] {
+		t.Fatal("expected the tiered cache to be made anew")
+	}
+	tc := invalidation.Unwrap(caches["tiered"]).(*tiered.Cache)
+	if tc.L2 != invalidation.Unwrap(caches["l2"]) || tc.L1 != invalidation.Unwrap(caches["l1"]) {
+		t.Error("expected the tiered cache to use the current tiers")
+	}
+	if !slices.Contains(retired, oldCaches["tiered"]) || !slices.Contains(retired, oldCaches["l2"]) {
+		t.Error("expected the old tiered cache and tier to be retired")
+	}
+}
+
+func TestApplyCachingConfigShardCount(t *testing.T) {
+	newConfig := func(shards int, maxObjects int64) *config.Config {
+		mc := co.New()
+		mc.Index.ShardCount, mc.Index.MaxSizeObjects = shards, maxObjects
+		c := config.NewConfig()
+		c.Caches = co.Lookup{"default": mc}
+		return c
+	}
+	logger := tl.ConsoleLogger("error")
+	oc := newConfig(4, 10)
+	oldCaches, _ := applyCachingConfig(oc, nil, logger, nil)
+
+	// a memory cache whose other index options changed is reused
+	caches, retired := applyCachingConfig(newConfig(4, 20), oc, logger, oldCaches)
+	if caches["default"] != oldCaches["default"] || len(retired) != 0 {
+		t.Fatal("expected the cache to be reused")
+	}
+
+	// a changed shard count requires a new cache
+	caches, retired = applyCachingConfig(newConfig(8, 20), oc, logger, oldCaches)
+	defer closeCaches(slices.Collect(maps.Values(caches)), 0)
+	defer closeCaches(retired, 0)
+	if caches["default"] == oldCaches["default"] ||
+		!slices.Contains(retired, oldCaches["default"]) {
+		t.Error("expected the cache to be made anew")
+	}
+}
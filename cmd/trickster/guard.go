@@ -0,0 +1,86 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/cmd/trickster/config/reload"
+	ro "github.com/trickstercache/trickster/v2/cmd/trickster/config/reload/options"
+	"github.com/trickstercache/trickster/v2/pkg/backends"
+	"github.com/trickstercache/trickster/v2/pkg/backends/alb"
+	"github.com/trickstercache/trickster/v2/pkg/backends/healthcheck"
+)
+
+// guardPollInterval is how often the health statuses are evaluated during a guarded reload
+const guardPollInterval = 250 * time.Millisecond
+
+// guardReload waits up to the configured guard window for the new configuration's health
+// checks to report, and returns reload.ErrReloadRolledBack if the unhealthy thresholds are met
+func guardReload(o *ro.Options, hc healthcheck.HealthChecker) error {
+	if !o.IsGuarded() || hc == nil {
+		return nil
+	}
+	deadline := time.Now().Add(time.Duration(o.GuardWindowMS) * time.Millisecond)
+	for {
+		st := hc.Statuses()
+		unhealthy := unhealthyBackends(st)
+		if exceedsGuardThresholds(o, len(unhealthy), len(st)) {
+			return fmt.Errorf("%w: unhealthy backends %v", reload.ErrReloadRolledBack, unhealthy)
+		}
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return nil
+		}
+		time.Sleep(min(wait, guardPollInterval))
+	}
+}
+
+// unhealthyBackends returns the sorted names of the backends whose health checks have failed
+func unhealthyBackends(st healthcheck.StatusLookup) []string {
+	out := make([]string, 0, len(st))
+	for k, v := range st {
+		if v != nil && v.Get() < 0 {
+			out = append(out, k)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// exceedsGuardThresholds returns true if the number of unhealthy backends, out of the
+// total number of health-checked backends, meets any of the guard's thresholds
+func exceedsGuardThresholds(o *ro.Options, unhealthy, total int) bool {
+	if unhealthy == 0 || total == 0 {
+		return false
+	}
+	if o.GuardUnhealthyBackends > 0 && unhealthy >= o.GuardUnhealthyBackends {
+		return true
+	}
+	return o.GuardUnhealthyPercent > 0 && unhealthy*100 >= o.GuardUnhealthyPercent*total
+}
+
+// stopBackends stops the ALB pools and health checks started for the backends of
+// a configuration that is rolled back
+func stopBackends(o backends.Backends, hc healthcheck.HealthChecker) {
+	alb.StopALBPools(o)
+	if hc != nil {
+		hc.Shutdown()
+	}
+}
@@ -0,0 +1,175 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	goruntime "runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/cmd/trickster/config/reload"
+	ro "github.com/trickstercache/trickster/v2/cmd/trickster/config/reload/options"
+	"github.com/trickstercache/trickster/v2/pkg/backends"
+	"github.com/trickstercache/trickster/v2/pkg/backends/alb"
+	ao "github.com/trickstercache/trickster/v2/pkg/backends/alb/options"
+	"github.com/trickstercache/trickster/v2/pkg/backends/healthcheck"
+	ho "github.com/trickstercache/trickster/v2/pkg/backends/healthcheck/options"
+	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
+
+	"github.com/gorilla/mux"
+)
+
+type testHealthChecker struct {
+	statuses healthcheck.StatusLookup
+}
+
+func (hc *testHealthChecker) Register(string, string, *ho.Options, *http.Client,
+	interface{}) (*healthcheck.Status, error) {
+	return nil, nil
+}
+func (hc *testHealthChecker) Unregister(string)                         {}
+func (hc *testHealthChecker) Status(n string) *healthcheck.Status       { return hc.statuses[n] }
+func (hc *testHealthChecker) Statuses() healthcheck.StatusLookup        { return hc.statuses }
+func (hc *testHealthChecker) Shutdown()                                 {}
+func (hc *testHealthChecker) Subscribe(chan bool)                       {}
+func (hc *testHealthChecker) OnTransition(func(healthcheck.Transition)) {}
+
+func newTestStatuses(vals ...int32) healthcheck.StatusLookup {
+	st := make(healthcheck.StatusLookup)
+	for i, v := range vals {
+		s := &healthcheck.Status{}
+		s.Set(v)
+		st[string(rune('a'+i))] = s
+	}
+	return st
+}
+
+func TestGuardReload(t *testing.T) {
+	o := ro.New()
+	hc := &testHealthChecker{statuses: newTestStatuses(1, 1, 0)}
+	if err := guardReload(o, hc); err != nil {
+		t.Error(err)
+	}
+	o.GuardWindowMS = 10
+	if err := guardReload(o, hc); err != nil {
+		t.Error(err)
+	}
+	hc.statuses = newTestStatuses(1, -1, 0)
+	err := guardReload(o, hc)
+	if !errors.Is(err, reload.ErrReloadRolledBack) {
+		t.Errorf("expected %v got %v", reload.ErrReloadRolledBack, err)
+	}
+}
+
+func TestUnhealthyBackends(t *testing.T) {
+	u := unhealthyBackends(newTestStatuses(-1, 1, -1, 0))
+	if len(u) != 2 || u[0] != "a" || u[1] != "c" {
+		t.Errorf("unexpected output %v", u)
+	}
+}
+
+func TestExceedsGuardThresholds(t *testing.T) {
+	tests := []struct {
+		count, pct       int
+		unhealthy, total int
+		expected         bool
+	}{
+		{1, 0, 0, 4, false},
+		{1, 0, 1, 4, true},
+		{2, 0, 1, 4, false},
+		{0, 50, 1, 4, false},
+		{0, 50, 2, 4, true},
+		{3, 50, 2, 4, true},
+		{0, 0, 4, 4, false},
+	}
+	for i, test := range tests {
+		o := &ro.Options{GuardUnhealthyBackends: test.count, GuardUnhealthyPercent: test.pct}
+		if v := exceedsGuardThresholds(o, test.unhealthy, test.total); v != test.expected {
+			t.Errorf("test %d: expected %t got %t", i, test.expected, v)
+		}
+	}
+}
+
+func TestStopBackends(t *testing.T) {
+	var probes atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		probes.Add(1)
+		// without keep-alives, no client connection goroutines outlive a probe
+		w.Header().Set("Connection", "close")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := bo.New()
+	o.HealthCheck = ho.New()
+	o.HealthCheck.IntervalMS = 10
+	o.HealthCheck.Scheme = u.Scheme
+	o.HealthCheck.Host = u.Host
+	o.HealthCheck.Path = "/"
+	member, err := backends.New("member", o, nil, mux.NewRouter(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := ao.New()
+	a.MechanismName = "rr"
+	a.Pool = []string{"member"}
+	o = bo.New()
+	o.Provider = "alb"
+	o.ALBOptions = a
+	lb, err := alb.NewClient("alb", o, mux.NewRouter(), nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := backends.Backends{"member": member, "alb": lb}
+
+	baseline := goruntime.NumGoroutine()
+	hc, err := b.StartHealthChecks(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = alb.StartALBPools(b, hc.Statuses()); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if probes.Load() == 0 {
+		t.Fatal("expected the member to be probed")
+	}
+
+	stopBackends(b, hc)
+	n := probes.Load()
+	time.Sleep(50 * time.Millisecond)
+	if v := probes.Load(); v != n {
+		t.Errorf("expected no probes after stopping, got %d", v-n)
+	}
+	// stopped probe loops linger for a second before they return
+	deadline := time.Now().Add(3 * time.Second)
+	for goruntime.NumGoroutine() > baseline && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if v := goruntime.NumGoroutine(); v > baseline {
+		t.Errorf("expected at most %d goroutines got %d", baseline, v)
+	}
+}
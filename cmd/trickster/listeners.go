@@ -33,15 +33,17 @@ import (
 
 var lg = listener.NewListenerGroup()
 
+// applyListenerConfigs starts or updates the listeners for the config, and reports
+// whether a listener was started with the tracers, which it shuts down when it stops
 func applyListenerConfigs(conf, oldConf *config.Config,
 	router, reloadHandler http.Handler, metricsRouter *http.ServeMux, log *tl.Logger,
 	tracers tracing.Tracers,
-) {
+) bool {
 	var err error
 	var tlsConfig *tls.Config
 
 	if conf == nil || conf.Frontend == nil {
-		return
+		return false
 	}
 
 	adminRouter := http.NewServeMux()
@@ -69,7 +71,7 @@ func applyListenerConfigs(conf, oldConf *config.Config,
 				"oldLimit": oldConf.Frontend.ConnectionsLimit,
 				"newLimit": conf.Frontend.ConnectionsLimit,
 			})
-		return
+		return false
 	}
 
 	hasOldFC := oldConf != nil && oldConf.Frontend != nil
@@ -104,7 +106,7 @@ func applyListenerConfigs(conf, oldConf *config.Config,
 		tlsConfig, _ = conf.TLSCertConfig()
 		if err != nil {
 			tl.Error(log, "unable to update tls config to certificate error", tl.Pairs{"detail": err})
-			return
+			return tracerFlusherSet
 		}
 		l := lg.Get("tlsListener")
 		if l != nil {
@@ -124,6 +126,7 @@ func applyListenerConfigs(conf, oldConf *config.Config,
 		var t2 tracing.Tracers
 		if !tracerFlusherSet {
 			t2 = tracers
+			tracerFlusherSet = true
 		}
 		go lg.StartListener("httpListener",
 			conf.Frontend.ListenAddress, conf.Frontend.ListenPort,
@@ -171,4 +174,5 @@ func applyListenerConfigs(conf, oldConf *config.Config,
 		rr.Handle(conf.ReloadConfig.HandlerPath, reloadHandler)
 		lg.UpdateRouter("reloadListener", rr)
 	}
+	return tracerFlusherSet
 }
@@ -43,6 +43,10 @@ var exitFunc func() = exitFatal
 func main() {
 	runtime.ApplicationName = applicationName
 	runtime.ApplicationVersion = applicationVersion
+	if len(os.Args) > 1 && os.Args[1] == "cache" {
+		os.Exit(runCacheCommand(os.Args[2:], os.Stdout, os.Stderr))
+	}
+	startShutdownMonitor(os.Exit)
 	runConfig(nil, wg, nil, nil, os.Args[1:], exitFunc)
 	wg.Wait()
 }
@@ -33,6 +33,42 @@ func init() {
 	signal.Notify(hups, syscall.SIGHUP)
 }
 
+var (
+	activeCaches    []cache.Cache
+	activeCachesMtx sync.Mutex
+)
+
+// setActiveCaches sets the caches that are closed on shutdown
+func setActiveCaches(caches map[string]cache.Cache) {
+	l := make([]cache.Cache, 0, len(caches))
+	for _, c := range caches {
+		l = append(l, c)
+	}
+	activeCachesMtx.Lock()
+	activeCaches = l
+	activeCachesMtx.Unlock()
+}
+
+// startShutdownMonitor closes the active caches and exits when the process is
+// interrupted or terminated, so caches can persist their state (e.g., memory
+// cache snapshots) before exiting
+func startShutdownMonitor(exit func(int)) {
+	shutdowns := make(chan os.Signal, 1)
+	signal.Notify(shutdowns, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-shutdowns
+		shutdown(exit)
+	}()
+}
+
+func shutdown(exit func(int)) {
+	activeCachesMtx.Lock()
+	closeCaches(activeCaches, 0)
+	activeCaches = nil
+	activeCachesMtx.Unlock()
+	exit(0)
+}
+
 func startHupMonitor(conf *config.Config, wg *sync.WaitGroup, log *tl.Logger,
 	caches map[string]cache.Cache, args []string,
 ) {
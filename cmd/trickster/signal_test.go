@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/trickstercache/trickster/v2/cmd/trickster/config"
+	"github.com/trickstercache/trickster/v2/pkg/cache"
+	"github.com/trickstercache/trickster/v2/pkg/cache/memory"
 	"github.com/trickstercache/trickster/v2/pkg/observability/logging"
 )
 
@@ -43,3 +45,19 @@ func TestStartHupMonitor(t *testing.T) {
 	hups <- syscall.SIGHUP
 	time.Sleep(time.Millisecond * 100)
 }
+
+func TestShutdown(t *testing.T) {
+	c, err := memory.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	setActiveCaches(map[string]cache.Cache{"default": c})
+	code := -1
+	shutdown(func(i int) { code = i })
+	if code != 0 {
+		t.Errorf("expected %d got %d", 0, code)
+	}
+	if activeCaches != nil {
+		t.Error("expected active caches to be cleared")
+	}
+}
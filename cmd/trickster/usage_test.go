@@ -49,6 +49,9 @@ func ExamplePrintUsage() {
 	//  Using origin-url and provider:
 	//   trickster -origin-url https://example.com -provider reverseproxycache [-log-level DEBUG|INFO|WARN|ERROR] [-proxy-port 8480] [-metrics-port 8481]
 	//
+	//  Inspecting cached objects (run 'trickster cache' for details):
+	//   trickster cache inspect|keys [-endpoint http://localhost:8481 | -config /path/to/file.yaml] ...
+	//
 	// ------
 	//
 	//  Simple HTTP Reverse Proxy Cache listening on 8080:
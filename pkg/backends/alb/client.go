@@ -122,6 +122,15 @@ func StartALBPools(clients backends.Backends, hcs healthcheck.StatusLookup) erro
 	return nil
 }
 
+// StopALBPools stops the pools of the ALB backends started by StartALBPools
+func StopALBPools(clients backends.Backends) {
+	for _, c := range clients {
+		if rc, ok := c.(*Client); ok {
+			rc.StopPool()
+		}
+	}
+}
+
 // ValidatePools iterates the backends and validates ALB backends
 func ValidatePools(clients backends.Backends) error {
 	for _, v := range clients {
@@ -175,10 +184,17 @@ func (c *Client) ValidateAndStartPool(clients backends.Backends, hcs healthcheck
 		hc, _ := hcs[n]
 		targets = append(targets, pool.NewTarget(tc.Router(), hc))
 	}
-	c.pool = pool.New(m, targets, o.HealthyFloor)
+	c.pool = pool.New(m, targets, o.HealthyFloor, o.OutlierDetection)
 	return nil
 }
 
+// StopPool stops this Client's pool, if it was started
+func (c *Client) StopPool() {
+	if c.pool != nil {
+		c.pool.Stop()
+	}
+}
+
 // Boilerplate Interface Functions (to EOF)
 
 // DefaultPathConfigs returns the default PathConfigs for the given Provider
@@ -44,6 +44,9 @@ type Options struct {
 	// FGRStatusCodes provides an explicit list of status codes considered "good" when using
 	// the First Good Response (fgr) methodology. By default, any code < 400 is good.
 	FGRStatusCodes []int `json:"fgr_status_codes"`
+	// OutlierDetection, when set, passively ejects pool members from rotation
+	// based on the outcomes of the requests proxied to them
+	OutlierDetection *OutlierDetectionOptions `json:"outlier_detection,omitempty"`
 	//
 	// synthetic values
 	FgrCodesLookup map[int]interface{} `json:"-"`
@@ -81,6 +84,9 @@ func (o *Options) Clone() *Options {
 		FGRStatusCodes: fsc,
 	}
 	c.Pool = copiers.CopyStrings(o.Pool)
+	if o.OutlierDetection != nil {
+		c.OutlierDetection = o.OutlierDetection.Clone()
+	}
 	return c
 }
 
@@ -134,6 +140,12 @@ func SetDefaults(name string, options *Options, metadata yamlx.KeyLookup) (*Opti
 		o.OutputFormat = defaultOutputFormat
 	}
 
+	od, err := setOutlierDetectionDefaults(name, options.OutlierDetection, metadata)
+	if err != nil {
+		return nil, err
+	}
+	o.OutlierDetection = od
+
 	return o, nil
 }
 
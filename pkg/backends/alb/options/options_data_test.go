@@ -46,3 +46,24 @@ backends:
       healthy_floor: 1
       pool: [ 'test' ]
 `
+
+const testTOMLOutlierDetection = `
+backends:
+  test:
+    alb:
+      mechanism: rr
+      pool: [ 'test' ]
+      outlier_detection:
+        consecutive_failures: 3
+        max_ejection_percent: 100
+`
+
+const testTOMLBadOutlierDetection = `
+backends:
+  test:
+    alb:
+      mechanism: rr
+      pool: [ 'test' ]
+      outlier_detection:
+        max_ejection_percent: 150
+`
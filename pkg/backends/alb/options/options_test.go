@@ -123,3 +123,42 @@ func TestSetDefaults(t *testing.T) {
 		t.Error("expected output_format error")
 	}
 }
+
+func TestSetDefaultsOutlierDetection(t *testing.T) {
+	o, md, err := fromYAML(testTOMLOutlierDetection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o2, err := SetDefaults("test", o, md)
+	if err != nil {
+		t.Fatal(err)
+	}
+	od := o2.OutlierDetection
+	if od == nil {
+		t.Fatal("expected non-nil outlier detection options")
+	}
+	if od.ConsecutiveFailures != 3 || od.MaxEjectionPercent != 100 ||
+		od.EjectionMS != DefaultEjectionMS || od.MaxEjectionMS != DefaultMaxEjectionMS {
+		t.Errorf("unexpected options: %+v", od)
+	}
+	co := o2.Clone()
+	if co.OutlierDetection == od || co.OutlierDetection.ConsecutiveFailures != 3 {
+		t.Error("clone mismatch")
+	}
+
+	o, md, err = fromYAML(testTOML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o2, _ = SetDefaults("test", o, md); o2.OutlierDetection != nil {
+		t.Error("expected nil outlier detection options")
+	}
+
+	o, md, err = fromYAML(testTOMLBadOutlierDetection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = SetDefaults("test", o, md); err != ErrInvalidOutlierDetection {
+		t.Errorf("expected %v got %v", ErrInvalidOutlierDetection, err)
+	}
+}
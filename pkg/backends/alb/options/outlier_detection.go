@@ -0,0 +1,113 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"errors"
+
+	"github.com/trickstercache/trickster/v2/pkg/util/yamlx"
+)
+
+// OutlierDetectionOptions defines how an ALB passively ejects pool members from
+// rotation based on the outcomes of the requests it proxies to them
+type OutlierDetectionOptions struct {
+	// ConsecutiveFailures is the number of consecutive 5xx responses (including
+	// upstream timeouts and connection failures) that ejects a pool member
+	ConsecutiveFailures int `json:"consecutive_failures,omitempty"`
+	// EjectionMS is how long a pool member is ejected the first time. Each
+	// subsequent ejection, without a successful response in between, is longer by EjectionMS
+	EjectionMS int `json:"ejection_ms,omitempty"`
+	// MaxEjectionMS is the longest a pool member is ejected
+	MaxEjectionMS int `json:"max_ejection_ms,omitempty"`
+	// MaxEjectionPercent is the maximum percentage of pool members that can be
+	// ejected at once. At least one pool member can always be ejected
+	MaxEjectionPercent int `json:"max_ejection_percent,omitempty"`
+}
+
+const (
+	// DefaultConsecutiveFailures is the default number of consecutive failures that ejects a pool member
+	DefaultConsecutiveFailures = 5
+	// DefaultEjectionMS is the default duration of a pool member's first ejection
+	DefaultEjectionMS = 30000
+	// DefaultMaxEjectionMS is the default maximum duration of an ejection
+	DefaultMaxEjectionMS = 300000
+	// DefaultMaxEjectionPercent is the default maximum percentage of ejected pool members
+	DefaultMaxEjectionPercent = 50
+)
+
+// ErrInvalidOutlierDetection is returned when the outlier detection options are invalid
+var ErrInvalidOutlierDetection = errors.New("invalid outlier_detection: consecutive_failures, " +
+	"ejection_ms and max_ejection_ms must be > 0, and max_ejection_percent must be between 0 and 100")
+
+// NewOutlierDetectionOptions returns a New OutlierDetectionOptions object with the default values
+func NewOutlierDetectionOptions() *OutlierDetectionOptions {
+	return &OutlierDetectionOptions{
+		ConsecutiveFailures: DefaultConsecutiveFailures,
+		EjectionMS:          DefaultEjectionMS,
+		MaxEjectionMS:       DefaultMaxEjectionMS,
+		MaxEjectionPercent:  DefaultMaxEjectionPercent,
+	}
+}
+
+// Clone returns a perfect copy of the OutlierDetectionOptions
+func (o *OutlierDetectionOptions) Clone() *OutlierDetectionOptions {
+	no := *o
+	return &no
+}
+
+// Validate returns an error if the OutlierDetectionOptions are invalid
+func (o *OutlierDetectionOptions) Validate() error {
+	if o.ConsecutiveFailures <= 0 || o.EjectionMS <= 0 || o.MaxEjectionMS <= 0 ||
+		o.MaxEjectionPercent < 0 || o.MaxEjectionPercent > 100 {
+		return ErrInvalidOutlierDetection
+	}
+	return nil
+}
+
+// setOutlierDetectionDefaults overlays the outlier detection options defined in
+// the yaml metadata for the named Backend onto the default options. It returns
+// nil if no outlier detection options are defined
+func setOutlierDetectionDefaults(name string, options *OutlierDetectionOptions,
+	metadata yamlx.KeyLookup,
+) (*OutlierDetectionOptions, error) {
+	if options == nil || !metadata.IsDefined("backends", name, "alb", "outlier_detection") {
+		return nil, nil
+	}
+
+	o := NewOutlierDetectionOptions()
+
+	if metadata.IsDefined("backends", name, "alb", "outlier_detection", "consecutive_failures") {
+		o.ConsecutiveFailures = options.ConsecutiveFailures
+	}
+
+	if metadata.IsDefined("backends", name, "alb", "outlier_detection", "ejection_ms") {
+		o.EjectionMS = options.EjectionMS
+	}
+
+	if metadata.IsDefined("backends", name, "alb", "outlier_detection", "max_ejection_ms") {
+		o.MaxEjectionMS = options.MaxEjectionMS
+	}
+
+	if metadata.IsDefined("backends", name, "alb", "outlier_detection", "max_ejection_percent") {
+		o.MaxEjectionPercent = options.MaxEjectionPercent
+	}
+
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
@@ -20,16 +20,26 @@ import (
 	"net/http"
 )
 
+// signalRebuild requests that the healthy list be rebuilt, without blocking. When
+// the channel is full, a rebuild is already pending, and it will observe the
+// caller's change, so the signal is dropped rather than blocking the caller
+func (p *pool) signalRebuild() {
+	select {
+	case p.ch <- true:
+	default:
+	}
+}
+
 func (p *pool) checkHealth() {
 	for {
 		select {
 		case <-p.ctx.Done():
-			break
+			return
 		case <-p.ch: // msg arrives whenever the healthy list must be rebuilt
 			p.mtx.Lock()
 			h := make([]http.Handler, 0, len(p.targets))
 			for _, t := range p.targets {
-				if t.hcStatus.Get() >= p.healthyFloor {
+				if t.hcStatus.Get() >= p.healthyFloor && !t.ejected.Load() {
 					h = append(h, t.handler)
 				}
 			}
@@ -0,0 +1,125 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pool
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	ao "github.com/trickstercache/trickster/v2/pkg/backends/alb/options"
+)
+
+// outlierDetector passively ejects targets from the pool's rotation when the
+// requests proxied to them fail consecutively, and re-admits them once their
+// ejection has elapsed
+type outlierDetector struct {
+	options *ao.OutlierDetectionOptions
+	p       *pool
+	mtx     sync.Mutex
+	ejected int
+}
+
+func newOutlierDetector(o *ao.OutlierDetectionOptions, p *pool) *outlierDetector {
+	if o == nil {
+		return nil
+	}
+	return &outlierDetector{options: o, p: p}
+}
+
+// observe wraps the target's handler to record the outcome of each request
+func (d *outlierDetector) observe(t *Target, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+		// requests canceled by the client, or by a fanout that has already been
+		// answered by another target, say nothing about the target's health
+		if r.Context().Err() != nil {
+			return
+		}
+		d.record(t, sw.code >= http.StatusInternalServerError)
+	})
+}
+
+// record records the outcome of a request to the target, ejecting it when it
+// reaches the consecutive failure threshold
+func (d *outlierDetector) record(t *Target, failed bool) {
+	d.mtx.Lock()
+	if t.ejected.Load() {
+		// outcomes of requests that were in flight when the target was ejected are ignored
+		d.mtx.Unlock()
+		return
+	}
+	if !failed {
+		t.failures, t.ejections = 0, 0
+		d.mtx.Unlock()
+		return
+	}
+	t.failures++
+	if t.failures < d.options.ConsecutiveFailures || d.ejected >= d.maxEjected() {
+		d.mtx.Unlock()
+		return
+	}
+	t.failures = 0
+	t.ejections++
+	d.ejected++
+	t.ejected.Store(true)
+	ejection := min(time.Duration(d.options.EjectionMS*t.ejections),
+		time.Duration(d.options.MaxEjectionMS)) * time.Millisecond
+	d.mtx.Unlock()
+	time.AfterFunc(ejection, func() { d.readmit(t) })
+	d.p.signalRebuild()
+}
+
+// readmit returns an ejected target to the pool's rotation
+func (d *outlierDetector) readmit(t *Target) {
+	d.mtx.Lock()
+	t.ejected.Store(false)
+	d.ejected--
+	d.mtx.Unlock()
+	d.p.signalRebuild()
+}
+
+// maxEjected returns the number of targets that may be ejected at once
+func (d *outlierDetector) maxEjected() int {
+	return max(len(d.p.targets)*d.options.MaxEjectionPercent/100, 1)
+}
+
+// statusWriter captures the status code of the response written through it
+type statusWriter struct {
+	http.ResponseWriter
+	code int
+}
+
+func (sw *statusWriter) WriteHeader(code int) {
+	if sw.code == 0 {
+		sw.code = code
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	if sw.code == 0 {
+		sw.code = http.StatusOK
+	}
+	return sw.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the underlying ResponseWriter for use by http.ResponseController
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
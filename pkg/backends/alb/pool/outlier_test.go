@@ -0,0 +1,189 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pool
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	ao "github.com/trickstercache/trickster/v2/pkg/backends/alb/options"
+	"github.com/trickstercache/trickster/v2/pkg/backends/healthcheck"
+)
+
+func testOutlierTarget(code *int32) *Target {
+	s := &healthcheck.Status{}
+	s.Set(1)
+	return NewTarget(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(atomic.LoadInt32(code)))
+	}), s)
+}
+
+func healthyCount(p *pool) int {
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+	return len(p.healthy)
+}
+
+func serve(ctx context.Context, h http.Handler) {
+	r := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+	h.ServeHTTP(httptest.NewRecorder(), r)
+}
+
+func TestOutlierDetection(t *testing.T) {
+	od := ao.NewOutlierDetectionOptions()
+	od.ConsecutiveFailures = 3
+	od.EjectionMS = 100
+	od.MaxEjectionPercent = 50
+
+	code1, code2 := int32(http.StatusBadGateway), int32(http.StatusOK)
+	t1, t2 := testOutlierTarget(&code1), testOutlierTarget(&code2)
+	p := New(RoundRobin, []*Target{t1, t2}, 0, od).(*pool)
+	time.Sleep(20 * time.Millisecond)
+	if n := healthyCount(p); n != 2 {
+		t.Fatalf("expected %d got %d", 2, n)
+	}
+
+	// a success resets the consecutive failure count
+	serve(context.Background(), t1.handler)
+	serve(context.Background(), t1.handler)
+	atomic.StoreInt32(&code1, http.StatusOK)
+	serve(context.Background(), t1.handler)
+	atomic.StoreInt32(&code1, http.StatusBadGateway)
+	serve(context.Background(), t1.handler)
+	if t1.ejected.Load() {
+		t.Fatal("expected target to remain in rotation")
+	}
+
+	// failures of canceled requests are not counted
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for range 3 {
+		serve(ctx, t1.handler)
+	}
+	if t1.ejected.Load() {
+		t.Fatal("expected target to remain in rotation")
+	}
+
+	serve(context.Background(), t1.handler)
+	serve(context.Background(), t1.handler)
+	if !t1.ejected.Load() {
+		t.Fatal("expected target to be ejected")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if n := healthyCount(p); n != 1 {
+		t.Errorf("expected %d got %d", 1, n)
+	}
+
+	// max_ejection_percent prevents ejecting the other target
+	atomic.StoreInt32(&code2, http.StatusServiceUnavailable)
+	for range 4 {
+		serve(context.Background(), t2.handler)
+	}
+	if t2.ejected.Load() {
+		t.Error("expected max_ejection_percent to prevent ejection")
+	}
+
+	// the ejected target is re-admitted once the ejection elapses
+	time.Sleep(150 * time.Millisecond)
+	if t1.ejected.Load() {
+		t.Fatal("expected target to be re-admitted")
+	}
+	if n := healthyCount(p); n != 2 {
+		t.Errorf("expected %d got %d", 2, n)
+	}
+	// the other target can now be ejected
+	serve(context.Background(), t2.handler)
+	if !t2.ejected.Load() {
+		t.Error("expected target to be ejected")
+	}
+}
+
+func TestOutlierEjectionDuration(t *testing.T) {
+	od := ao.NewOutlierDetectionOptions()
+	od.ConsecutiveFailures = 1
+	od.EjectionMS = 100
+	od.MaxEjectionMS = 300
+	od.MaxEjectionPercent = 100
+
+	code := int32(http.StatusGatewayTimeout)
+	tgt := testOutlierTarget(&code)
+	New(RoundRobin, []*Target{tgt}, 0, od)
+
+	serve(context.Background(), tgt.handler)
+	time.Sleep(150 * time.Millisecond)
+	if tgt.ejected.Load() {
+		t.Fatal("expected target to be re-admitted")
+	}
+	// a target that fails again on re-admission is ejected for longer
+	serve(context.Background(), tgt.handler)
+	time.Sleep(150 * time.Millisecond)
+	if !tgt.ejected.Load() {
+		t.Fatal("expected target to remain ejected")
+	}
+	time.Sleep(150 * time.Millisecond)
+	if tgt.ejected.Load() {
+		t.Fatal("expected target to be re-admitted")
+	}
+}
+
+func TestOutlierSignalDoesNotBlock(t *testing.T) {
+	od := ao.NewOutlierDetectionOptions()
+	od.ConsecutiveFailures = 1
+	od.EjectionMS = 10
+	od.MaxEjectionMS = 10
+	od.MaxEjectionPercent = 100
+
+	code := int32(http.StatusBadGateway)
+	tgt := testOutlierTarget(&code)
+	// a pool whose channel is full and no longer drained
+	p := &pool{targets: []*Target{tgt}, ch: make(chan bool, 1)}
+	p.ch <- true
+	d := newOutlierDetector(od, p)
+
+	done := make(chan struct{})
+	go func() {
+		d.record(tgt, true)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected ejection not to block")
+	}
+	// the readmission doesn't block either
+	time.Sleep(100 * time.Millisecond)
+	if tgt.ejected.Load() {
+		t.Error("expected target to be re-admitted")
+	}
+}
+
+func TestStatusWriter(t *testing.T) {
+	w := httptest.NewRecorder()
+	sw := &statusWriter{ResponseWriter: w}
+	sw.Write([]byte("test"))
+	sw.WriteHeader(http.StatusInternalServerError)
+	if sw.code != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, sw.code)
+	}
+	if sw.Unwrap() != w {
+		t.Error("unexpected mismatch")
+	}
+}
@@ -21,13 +21,16 @@ import (
 	"context"
 	"net/http"
 	"sync"
+	"sync/atomic"
 
+	ao "github.com/trickstercache/trickster/v2/pkg/backends/alb/options"
 	"github.com/trickstercache/trickster/v2/pkg/backends/healthcheck"
 )
 
 // Pool defines the interface for a load balancer pool
 type Pool interface {
 	Next() []http.Handler
+	Stop()
 }
 
 type selectionFunc func(*pool) []http.Handler
@@ -36,10 +39,18 @@ type selectionFunc func(*pool) []http.Handler
 type Target struct {
 	hcStatus *healthcheck.Status
 	handler  http.Handler
+	// passive outlier detection state; failures and ejections are guarded by
+	// the pool's outlierDetector
+	failures  int
+	ejections int
+	ejected   atomic.Bool
 }
 
-// New returns a new pool
-func New(mechanism Mechanism, targets []*Target, healthyFloor int) Pool {
+// New returns a new pool. When od is non-nil, targets are passively ejected
+// from rotation based on the outcomes of the requests proxied to them
+func New(mechanism Mechanism, targets []*Target, healthyFloor int,
+	od *ao.OutlierDetectionOptions,
+) Pool {
 	f, ok := mechsToFuncs()[mechanism]
 	if !ok {
 		return nil
@@ -48,14 +59,18 @@ func New(mechanism Mechanism, targets []*Target, healthyFloor int) Pool {
 		mechanism:    mechanism,
 		targets:      targets,
 		f:            f,
-		ctx:          context.Background(),
 		ch:           make(chan bool, 16),
 		healthyFloor: healthyFloor,
 	}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	p.outliers = newOutlierDetector(od, p)
 	p.ch <- true
 
 	for _, t := range targets {
 		t.hcStatus.RegisterSubscriber(p.ch)
+		if p.outliers != nil {
+			t.handler = p.outliers.observe(t, t.handler)
+		}
 	}
 
 	go p.checkHealth()
@@ -79,13 +94,24 @@ type pool struct {
 	pos          uint64
 	mtx          sync.RWMutex
 	ctx          context.Context
+	cancel       context.CancelFunc
 	ch           chan bool
+	outliers     *outlierDetector
 }
 
 func (p *pool) Next() []http.Handler {
 	return p.f(p)
 }
 
+// Stop unsubscribes the pool from its targets' health statuses and ends the
+// goroutine that maintains its healthy list
+func (p *pool) Stop() {
+	for _, t := range p.targets {
+		t.hcStatus.UnregisterSubscriber(p.ch)
+	}
+	p.cancel()
+}
+
 func mechsToFuncs() map[Mechanism]selectionFunc {
 	return map[Mechanism]selectionFunc{
 		RoundRobin:         nextRoundRobin,
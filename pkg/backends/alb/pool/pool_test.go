@@ -55,7 +55,7 @@ func TestNewTarget(t *testing.T) {
 }
 
 func TestNewPool(t *testing.T) {
-	p := New(83, nil, 0)
+	p := New(83, nil, 0, nil)
 	if p != nil {
 		t.Error("expected nil pool")
 	}
@@ -66,7 +66,7 @@ func TestNewPool(t *testing.T) {
 		t.Error("unexpected mismatch")
 	}
 
-	p = New(RoundRobin, []*Target{tgt}, 0)
+	p = New(RoundRobin, []*Target{tgt}, 0, nil)
 	if p == nil {
 		t.Error("expected non-nil")
 	}
@@ -42,7 +42,7 @@ func testPool(mech pool.Mechanism, healthyFloor int, hs []http.Handler) (pool.Po
 			targets = append(targets, pool.NewTarget(h, hst))
 		}
 	}
-	pool := pool.New(mech, targets, healthyFloor)
+	pool := pool.New(mech, targets, healthyFloor, nil)
 	return pool, targets, statuses
 }
 
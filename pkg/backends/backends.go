@@ -22,6 +22,7 @@ import (
 
 	"github.com/trickstercache/trickster/v2/pkg/backends/healthcheck"
 	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/circuitbreaker"
 )
 
 // Backends represents a map of Backends keyed by Name
@@ -29,8 +30,15 @@ type Backends map[string]Backend
 
 // StartHealthChecks iterates the backends to fully configure health checkers
 // and start up any intervaled health checks
-func (b Backends) StartHealthChecks(logger interface{}) (healthcheck.HealthChecker, error) {
+func (b Backends) StartHealthChecks(logger interface{},
+	observers ...func(healthcheck.Transition),
+) (healthcheck.HealthChecker, error) {
 	hc := healthcheck.New()
+	// observers are registered before any health check starts, so that they
+	// receive each backend's initial transitions
+	for _, f := range observers {
+		hc.OnTransition(f)
+	}
 	for k, c := range b {
 		bo := c.Configuration()
 		if IsVirtual(bo.Provider) || k == "frontend" {
@@ -48,9 +56,18 @@ func (b Backends) StartHealthChecks(logger interface{}) (healthcheck.HealthCheck
 		}
 		st, err := hc.Register(k, bo.Provider, bo.HealthCheck, c.HealthCheckHTTPClient(), logger)
 		if err != nil {
+			// stop the health checks that were already started
+			hc.Shutdown()
 			return nil, err
 		}
 		c.SetHealthCheckProbe(st.Prober())
+		// the circuit breaker's state overlays the health check status, so that
+		// ALB pools and the health page reflect open circuits
+		if bo.Breaker != nil {
+			bo.Breaker.OnStateChange(func(s circuitbreaker.State, detail string) {
+				st.SetCircuitOpen(s == circuitbreaker.StateOpen, detail)
+			})
+		}
 	}
 	return hc, nil
 }
@@ -21,6 +21,8 @@ import (
 
 	ho "github.com/trickstercache/trickster/v2/pkg/backends/healthcheck/options"
 	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/circuitbreaker"
+	cbo "github.com/trickstercache/trickster/v2/pkg/proxy/circuitbreaker/options"
 
 	"github.com/gorilla/mux"
 )
@@ -112,6 +114,25 @@ func TestStartHealthChecks(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
+
+	// an opened circuit breaker marks the health check status unavailable
+	cbOpts := cbo.New()
+	cbOpts.MinRequests = 1
+	o2.Breaker = circuitbreaker.New(cbOpts)
+	o2.HealthCheck = ho.New()
+	b = Backends{"test1": c1, "test2": c2}
+	hc, err := b.StartHealthChecks(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, ok := hc.Statuses()["test2"]
+	if !ok {
+		t.Fatal("expected status for test2")
+	}
+	o2.Breaker.Record(true, 0)
+	if st.Get() != -1 {
+		t.Errorf("expected %d got %d", -1, st.Get())
+	}
 }
 
 type testBackend struct {
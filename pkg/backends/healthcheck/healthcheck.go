@@ -32,6 +32,7 @@ type HealthChecker interface {
 	Statuses() StatusLookup
 	Shutdown()
 	Subscribe(chan bool)
+	OnTransition(func(Transition))
 }
 
 // Lookup is a map of named Target references
@@ -41,6 +42,7 @@ type healthChecker struct {
 	targets     Lookup
 	statuses    StatusLookup
 	subscribers []chan bool
+	observers   []func(Transition)
 }
 
 // New returns a new HealthChecker
@@ -56,6 +58,17 @@ func (hc *healthChecker) Subscribe(ch chan bool) {
 	hc.subscribers = append(hc.subscribers, ch)
 }
 
+// OnTransition registers a func that is called each time the overall status of
+// any registered target changes, including targets registered later. Observers
+// are attached to a target before its health checks start, so that no
+// transition is missed.
+func (hc *healthChecker) OnTransition(f func(Transition)) {
+	hc.observers = append(hc.observers, f)
+	for _, st := range hc.statuses {
+		st.OnTransition(f)
+	}
+}
+
 func (hc *healthChecker) Shutdown() {
 	for _, t := range hc.targets {
 		t.Stop()
@@ -86,6 +99,9 @@ func (hc *healthChecker) Register(name, description string, o *ho.Options,
 		return nil, err
 	}
 	hc.targets[t.name] = t
+	for _, f := range hc.observers {
+		t.status.OnTransition(f)
+	}
 	if t.interval > 0 {
 		t.Start()
 		// wait for the health check to be fully registered
@@ -94,6 +110,7 @@ func (hc *healthChecker) Register(name, description string, o *ho.Options,
 		}
 	}
 	hc.statuses[t.name] = t.status
+	t.status.setMetrics(t.status.Get(), t.status.FailingSince())
 	return t.status, nil
 }
 
@@ -103,6 +120,7 @@ func (hc *healthChecker) Unregister(name string) {
 	}
 	if t, ok := hc.targets[name]; ok && t != nil {
 		t.Stop()
+		t.status.deleteMetrics()
 		delete(hc.targets, t.name)
 		delete(hc.statuses, t.name)
 	}
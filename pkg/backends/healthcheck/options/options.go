@@ -35,6 +35,20 @@ const MinProbeWaitMS = 100
 // ErrNoOptionsProvided returns an error for no health check options provided
 var ErrNoOptionsProvided = errors.New("no health check options provided")
 
+// ErrInvalidProbeType returns an error for an unsupported health check probe type
+var ErrInvalidProbeType = errors.New("invalid health check type")
+
+const (
+	// ProbeTypeHTTP probes the target with an HTTP request
+	ProbeTypeHTTP = "http"
+	// ProbeTypeTCP probes the target by opening a TCP connection
+	ProbeTypeTCP = "tcp"
+	// ProbeTypeTLS probes the target by completing a TLS handshake
+	ProbeTypeTLS = "tls"
+	// ProbeTypeGRPC probes the target with a grpc.health.v1 Health Check
+	ProbeTypeGRPC = "grpc"
+)
+
 // Options defines Health Checking Options
 type Options struct {
 	// IntervalMS defines the interval in milliseconds at which the target will be probed
@@ -46,6 +60,17 @@ type Options struct {
 	// mark an unavailable target as available
 	RecoveryThreshold int `json:"recovery_threshold,omitempty"`
 
+	// Type is the kind of probe used to check the target: http (default), tcp,
+	// tls or grpc. tcp, tls and grpc probes connect to Host, and ignore the
+	// HTTP request and response options other than Scheme
+	Type string `json:"type,omitempty"`
+	// MinCertValidityMS, for tls probes, fails the probe when any certificate
+	// presented by the target expires within this many milliseconds
+	MinCertValidityMS int64 `json:"min_cert_validity_ms,omitempty"`
+	// GRPCService, for grpc probes, is the service name sent in the grpc.health.v1
+	// HealthCheckRequest. The default of empty checks the overall server health
+	GRPCService string `json:"grpc_service,omitempty"`
+
 	// Target Outbound Request Options
 	// Verb provides the HTTP verb to use when making an upstream health check
 	Verb string `json:"verb,omitempty"`
@@ -99,6 +124,9 @@ func (o *Options) SetMetaData(md yamlx.KeyLookup) {
 // Clone returns an exact copy of a *healthcheck.Options
 func (o *Options) Clone() *Options {
 	c := &Options{}
+	c.Type = o.Type
+	c.MinCertValidityMS = o.MinCertValidityMS
+	c.GRPCService = o.GRPCService
 	c.Verb = o.Verb
 	c.Scheme = o.Scheme
 	c.Host = o.Host
@@ -128,6 +156,21 @@ func (o *Options) Overlay(name string, custom *Options) {
 	if custom == nil || custom.md == nil {
 		return
 	}
+	if custom.md.IsDefined("backends", name, "healthcheck", "type") {
+		o.Type = custom.Type
+	}
+	if custom.md.IsDefined("backends", name, "healthcheck", "scheme") {
+		o.Scheme = custom.Scheme
+	}
+	if custom.md.IsDefined("backends", name, "healthcheck", "host") {
+		o.Host = custom.Host
+	}
+	if custom.md.IsDefined("backends", name, "healthcheck", "min_cert_validity_ms") {
+		o.MinCertValidityMS = custom.MinCertValidityMS
+	}
+	if custom.md.IsDefined("backends", name, "healthcheck", "grpc_service") {
+		o.GRPCService = custom.GRPCService
+	}
 	if custom.md.IsDefined("backends", name, "healthcheck", "path") {
 		o.Path = custom.Path
 	}
@@ -171,6 +214,17 @@ func (o *Options) URL() *url.URL {
 	return u
 }
 
+// ProbeType returns the normalized probe type, or ErrInvalidProbeType if it is unsupported
+func (o *Options) ProbeType() (string, error) {
+	switch t := strings.ToLower(o.Type); t {
+	case "", ProbeTypeHTTP:
+		return ProbeTypeHTTP, nil
+	case ProbeTypeTCP, ProbeTypeTLS, ProbeTypeGRPC:
+		return t, nil
+	}
+	return "", ErrInvalidProbeType
+}
+
 // HasExpectedBody returns true if a Custom Expected Body was provided
 func (o *Options) HasExpectedBody() bool {
 	return o.hasExpectedBody
@@ -131,6 +131,58 @@ backends:
         TestHeader: test-header-val
 `
 
+func TestOverlayProbeType(t *testing.T) {
+	const conf = `
+backends:
+  test:
+    healthcheck:
+      type: tls
+      host: example.com:8443
+      scheme: https
+      min_cert_validity_ms: 86400000
+      grpc_service: test
+`
+	md, err := yamlx.GetKeyList(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Options{Type: "tls", Host: "example.com:8443", Scheme: "https",
+		MinCertValidityMS: 86400000, GRPCService: "test"}
+	c.SetMetaData(md)
+	o := New()
+	o.Overlay("test", c)
+	if o.Type != "tls" || o.Host != "example.com:8443" || o.Scheme != "https" ||
+		o.MinCertValidityMS != 86400000 || o.GRPCService != "test" {
+		t.Errorf("unexpected options: %+v", o)
+	}
+	o2 := o.Clone()
+	if o2.Type != o.Type || o2.MinCertValidityMS != o.MinCertValidityMS ||
+		o2.GRPCService != o.GRPCService {
+		t.Error("clone mismatch")
+	}
+}
+
+func TestProbeType(t *testing.T) {
+	tests := []struct {
+		in, out string
+		err     error
+	}{
+		{"", ProbeTypeHTTP, nil},
+		{"HTTP", ProbeTypeHTTP, nil},
+		{"tcp", ProbeTypeTCP, nil},
+		{"tls", ProbeTypeTLS, nil},
+		{"gRPC", ProbeTypeGRPC, nil},
+		{"udp", "", ErrInvalidProbeType},
+	}
+	for _, test := range tests {
+		o := &Options{Type: test.in}
+		v, err := o.ProbeType()
+		if v != test.out || err != test.err {
+			t.Errorf("expected %s %v got %s %v", test.out, test.err, v, err)
+		}
+	}
+}
+
 func TestCalibrateTimeout(t *testing.T) {
 	const defaultTimeout = time.Duration(DefaultHealthCheckTimeoutMS) * time.Millisecond
 	const maxTimeout = time.Duration(MaxProbeWaitMS) * time.Millisecond
@@ -0,0 +1,242 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package healthcheck
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	ho "github.com/trickstercache/trickster/v2/pkg/backends/healthcheck/options"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// checker performs a non-HTTP probe of a target, returning an error describing
+// the failure when the target is unhealthy
+type checker func(context.Context) error
+
+// grpcHealthPath is the path of the standard gRPC Health Check method
+const grpcHealthPath = "/grpc.health.v1.Health/Check"
+
+// grpcServing is the SERVING value of grpc.health.v1.HealthCheckResponse.ServingStatus
+const grpcServing = 1
+
+var grpcServingStatuses = map[uint64]string{
+	0: "UNKNOWN",
+	1: "SERVING",
+	2: "NOT_SERVING",
+	3: "SERVICE_UNKNOWN",
+}
+
+// newChecker returns the checker for the options' probe type, or nil for http probes
+func newChecker(o *ho.Options, client *http.Client, timeout time.Duration) (checker, error) {
+	pt, err := o.ProbeType()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, o.Type)
+	}
+	switch pt {
+	case ho.ProbeTypeTCP:
+		return tcpChecker(probeAddress(o)), nil
+	case ho.ProbeTypeTLS:
+		return tlsChecker(probeAddress(o), tlsConfig(client),
+			time.Duration(o.MinCertValidityMS)*time.Millisecond), nil
+	case ho.ProbeTypeGRPC:
+		u := o.URL()
+		u.Path, u.RawQuery = grpcHealthPath, ""
+		return grpcChecker(newGRPCClient(timeout, tlsConfig(client)), u.String(),
+			o.GRPCService), nil
+	}
+	return nil, nil
+}
+
+// probeAddress returns the host:port of the target, using the default port for
+// the scheme when Host does not include one
+func probeAddress(o *ho.Options) string {
+	if _, _, err := net.SplitHostPort(o.Host); err == nil {
+		return o.Host
+	}
+	if o.Scheme == "https" || o.Type == ho.ProbeTypeTLS {
+		return net.JoinHostPort(o.Host, "443")
+	}
+	return net.JoinHostPort(o.Host, "80")
+}
+
+// tlsConfig returns a copy of the client's TLS configuration, so that probes
+// verify the target with the same settings as the Backend's upstream requests
+func tlsConfig(c *http.Client) *tls.Config {
+	if c == nil {
+		return &tls.Config{}
+	}
+	rt := c.Transport
+	// unwrap any RoundTrippers that wrap the transport, such as upstream auth
+	for {
+		u, ok := rt.(interface{ Unwrap() http.RoundTripper })
+		if !ok {
+			break
+		}
+		rt = u.Unwrap()
+	}
+	if tr, ok := rt.(*http.Transport); ok && tr.TLSClientConfig != nil {
+		return tr.TLSClientConfig.Clone()
+	}
+	return &tls.Config{}
+}
+
+func tcpChecker(addr string) checker {
+	return func(ctx context.Context) error {
+		conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return fmt.Errorf("error connecting to target: %w", err)
+		}
+		return conn.Close()
+	}
+}
+
+func tlsChecker(addr string, cfg *tls.Config, minValidity time.Duration) checker {
+	return func(ctx context.Context) error {
+		conn, err := (&tls.Dialer{Config: cfg}).DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return fmt.Errorf("error completing tls handshake with target: %w", err)
+		}
+		defer conn.Close()
+		for _, cert := range conn.(*tls.Conn).ConnectionState().PeerCertificates {
+			if time.Until(cert.NotAfter) < minValidity {
+				return fmt.Errorf("certificate [%s] expires at %s",
+					cert.Subject.CommonName, cert.NotAfter.UTC().Format(time.RFC3339))
+			}
+		}
+		return nil
+	}
+}
+
+// newGRPCClient returns an HTTP/2-only client for gRPC probes, which uses
+// prior knowledge (h2c) for http targets
+func newGRPCClient(timeout time.Duration, cfg *tls.Config) *http.Client {
+	p := &http.Protocols{}
+	p.SetHTTP2(true)
+	p.SetUnencryptedHTTP2(true)
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Protocols:       p,
+			TLSClientConfig: cfg,
+		},
+	}
+}
+
+func grpcChecker(client *http.Client, url, service string) checker {
+	// the HealthCheckRequest message has a single field: string service = 1
+	var msg []byte
+	if service != "" {
+		msg = protowire.AppendTag(msg, 1, protowire.BytesType)
+		msg = protowire.AppendString(msg, service)
+	}
+	body := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(body[1:], uint32(len(msg)))
+	body = append(body, msg...)
+	return func(ctx context.Context) error {
+		r, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		r.Header.Set("Content-Type", "application/grpc")
+		r.Header.Set("Te", "trailers")
+		resp, err := client.Do(r)
+		if err != nil {
+			return fmt.Errorf("error probing target: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("grpc health check returned http status %d", resp.StatusCode)
+		}
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("error reading grpc health check response: %w", err)
+		}
+		// the grpc status is in the trailers, or in the headers of a trailers-only response
+		gs := resp.Trailer.Get("Grpc-Status")
+		if gs == "" {
+			gs = resp.Header.Get("Grpc-Status")
+		}
+		if gs != "0" {
+			msg := resp.Trailer.Get("Grpc-Message")
+			if msg == "" {
+				msg = resp.Header.Get("Grpc-Message")
+			}
+			return fmt.Errorf("grpc health check failed with status [%s]: %s", gs, msg)
+		}
+		st, err := grpcServingStatus(b)
+		if err != nil {
+			return err
+		}
+		if st != grpcServing {
+			name, ok := grpcServingStatuses[st]
+			if !ok {
+				name = strconv.FormatUint(st, 10)
+			}
+			return fmt.Errorf("grpc health check status is %s", name)
+		}
+		return nil
+	}
+}
+
+var errInvalidGRPCResponse = errors.New("invalid grpc health check response")
+
+// grpcServingStatus returns the status field of the length-prefixed
+// HealthCheckResponse message in b
+func grpcServingStatus(b []byte) (uint64, error) {
+	if len(b) < 5 || b[0] != 0 {
+		return 0, errInvalidGRPCResponse
+	}
+	n := binary.BigEndian.Uint32(b[1:5])
+	b = b[5:]
+	if uint32(len(b)) < n {
+		return 0, errInvalidGRPCResponse
+	}
+	b = b[:n]
+	var status uint64
+	for len(b) > 0 {
+		num, typ, l := protowire.ConsumeTag(b)
+		if l < 0 {
+			return 0, errInvalidGRPCResponse
+		}
+		b = b[l:]
+		if num == 1 && typ == protowire.VarintType {
+			v, l := protowire.ConsumeVarint(b)
+			if l < 0 {
+				return 0, errInvalidGRPCResponse
+			}
+			status, b = v, b[l:]
+			continue
+		}
+		l = protowire.ConsumeFieldValue(num, typ, b)
+		if l < 0 {
+			return 0, errInvalidGRPCResponse
+		}
+		b = b[l:]
+	}
+	return status, nil
+}
@@ -0,0 +1,246 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package healthcheck
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	ho "github.com/trickstercache/trickster/v2/pkg/backends/healthcheck/options"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestNewChecker(t *testing.T) {
+	o := ho.New()
+	c, err := newChecker(o, nil, time.Second)
+	if err != nil || c != nil {
+		t.Errorf("expected nil checker for http probes, got %v", err)
+	}
+	for _, pt := range []string{"tcp", "TLS", "grpc"} {
+		o.Type = pt
+		if c, err = newChecker(o, nil, time.Second); err != nil || c == nil {
+			t.Errorf("expected %s checker, got %v", pt, err)
+		}
+	}
+	o.Type = "udp"
+	if _, err = newChecker(o, nil, time.Second); !errors.Is(err, ho.ErrInvalidProbeType) {
+		t.Errorf("expected %v got %v", ho.ErrInvalidProbeType, err)
+	}
+}
+
+func TestProbeAddress(t *testing.T) {
+	tests := []struct {
+		scheme, pt, host, expected string
+	}{
+		{"http", "tcp", "example.com:9090", "example.com:9090"},
+		{"http", "tcp", "example.com", "example.com:80"},
+		{"https", "tcp", "example.com", "example.com:443"},
+		{"http", "tls", "example.com", "example.com:443"},
+	}
+	for _, test := range tests {
+		o := &ho.Options{Scheme: test.scheme, Type: test.pt, Host: test.host}
+		if v := probeAddress(o); v != test.expected {
+			t.Errorf("expected %s got %s", test.expected, v)
+		}
+	}
+}
+
+func TestTCPChecker(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	addr := ts.Listener.Addr().String()
+	c := tcpChecker(addr)
+	if err := c(context.Background()); err != nil {
+		t.Error(err)
+	}
+	ts.Close()
+	if err := c(context.Background()); err == nil {
+		t.Error("expected connection error")
+	}
+}
+
+func TestTLSChecker(t *testing.T) {
+	ts := httptest.NewTLSServer(http.NotFoundHandler())
+	defer ts.Close()
+	addr := ts.Listener.Addr().String()
+
+	if err := tlsChecker(addr, tlsConfig(ts.Client()), 0)(context.Background()); err != nil {
+		t.Error(err)
+	}
+	// the transport is found when it is wrapped, such as by upstream auth
+	wrapped := &http.Client{Transport: &testWrappedTransport{ts.Client().Transport}}
+	if err := tlsChecker(addr, tlsConfig(wrapped), 0)(context.Background()); err != nil {
+		t.Error(err)
+	}
+	// the test server's certificate is not trusted by default
+	if err := tlsChecker(addr, tlsConfig(nil), 0)(context.Background()); err == nil {
+		t.Error("expected certificate verification error")
+	}
+	// the test server's certificate expires within two hundred years
+	err := tlsChecker(addr, tlsConfig(ts.Client()), 200*365*24*time.Hour)(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "expires at") {
+		t.Errorf("expected certificate expiry error, got %v", err)
+	}
+}
+
+type testWrappedTransport struct {
+	http.RoundTripper
+}
+
+func (t *testWrappedTransport) Unwrap() http.RoundTripper {
+	return t.RoundTripper
+}
+
+func grpcHealthResponse(status uint64) []byte {
+	var msg []byte
+	msg = protowire.AppendTag(msg, 1, protowire.VarintType)
+	msg = protowire.AppendVarint(msg, status)
+	b := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(b[1:], uint32(len(msg)))
+	return append(b, msg...)
+}
+
+func newGRPCTestServer(t *testing.T, status uint64, grpcStatus string) *httptest.Server {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 || r.URL.Path != grpcHealthPath ||
+			r.Header.Get("Content-Type") != "application/grpc" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		// the request is a length-prefixed HealthCheckRequest with service = "test"
+		if len(b) < 5 || string(b[7:]) != "test" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+		w.WriteHeader(http.StatusOK)
+		if grpcStatus == "0" {
+			w.Write(grpcHealthResponse(status))
+		}
+		w.Header().Set("Grpc-Status", grpcStatus)
+		w.Header().Set("Grpc-Message", "test message")
+	}))
+	ts.Config.Protocols = &http.Protocols{}
+	ts.Config.Protocols.SetHTTP1(true)
+	ts.Config.Protocols.SetUnencryptedHTTP2(true)
+	ts.Start()
+	return ts
+}
+
+func TestGRPCChecker(t *testing.T) {
+	tests := []struct {
+		status     uint64
+		grpcStatus string
+		err        string
+	}{
+		{1, "0", ""},
+		{2, "0", "grpc health check status is NOT_SERVING"},
+		{9, "0", "grpc health check status is 9"},
+		{0, "5", "grpc health check failed with status [5]: test message"},
+	}
+	for _, test := range tests {
+		ts := newGRPCTestServer(t, test.status, test.grpcStatus)
+		u, _ := url.Parse(ts.URL)
+		o := ho.New()
+		o.Type = ho.ProbeTypeGRPC
+		o.Host = u.Host
+		o.GRPCService = "test"
+		c, err := newChecker(o, nil, time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = c(context.Background())
+		if test.err == "" && err != nil {
+			t.Error(err)
+		} else if test.err != "" && (err == nil || err.Error() != test.err) {
+			t.Errorf("expected %s got %v", test.err, err)
+		}
+		ts.Close()
+	}
+}
+
+func TestGRPCServingStatus(t *testing.T) {
+	if s, err := grpcServingStatus(grpcHealthResponse(1)); err != nil || s != 1 {
+		t.Errorf("expected %d got %d %v", 1, s, err)
+	}
+	// unknown fields are skipped
+	b := grpcHealthResponse(2)
+	b = protowire.AppendTag(b, 2, protowire.BytesType)
+	b = protowire.AppendString(b, "test")
+	binary.BigEndian.PutUint32(b[1:], uint32(len(b)-5))
+	if s, err := grpcServingStatus(b); err != nil || s != 2 {
+		t.Errorf("expected %d got %d %v", 2, s, err)
+	}
+	for _, b := range [][]byte{nil, {1, 0, 0, 0, 0}, {0, 0, 0, 0, 9, 8}, {0, 0, 0, 0, 1, 0xff}} {
+		if _, err := grpcServingStatus(b); err != errInvalidGRPCResponse {
+			t.Errorf("expected %v got %v", errInvalidGRPCResponse, err)
+		}
+	}
+}
+
+func TestProbeChecker(t *testing.T) {
+	var fail bool
+	tgt := &target{
+		status:  &Status{},
+		ctx:     context.Background(),
+		timeout: time.Second,
+		checker: func(context.Context) error {
+			if fail {
+				return errors.New("test failure")
+			}
+			return nil
+		},
+		failureThreshold:  1,
+		recoveryThreshold: 1,
+	}
+	tgt.probe()
+	if tgt.status.Get() != 1 {
+		t.Error("expected 1 got", tgt.status.Get())
+	}
+	w := httptest.NewRecorder()
+	tgt.demandProbe(w)
+	if w.Code != 200 {
+		t.Error("expected 200 got", w.Code)
+	}
+
+	fail = true
+	tgt.probe()
+	if tgt.status.Get() != -1 {
+		t.Error("expected -1 got", tgt.status.Get())
+	}
+	if tgt.status.Detail() != "test failure" {
+		t.Error("expected test failure got", tgt.status.Detail())
+	}
+	w = httptest.NewRecorder()
+	tgt.demandProbe(w)
+	if w.Code != 500 {
+		t.Error("expected 500 got", w.Code)
+	}
+	if v := w.Header().Get("Trk-HC-Status"); v != "-1" {
+		t.Error("expected -1 got", v)
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/trickstercache/trickster/v2/pkg/observability/metrics"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
 )
 
@@ -38,6 +39,36 @@ type Status struct {
 	subscribers  []chan bool
 	mtx          sync.Mutex
 	prober       func(http.ResponseWriter)
+	// circuitOpen is 1 while the backend's circuit breaker is open, which
+	// overrides the status to unavailable regardless of health check results
+	circuitOpen   int32
+	circuitDetail string
+	circuitSince  time.Time
+	// last is the most recently observed overall status, used to detect transitions
+	last      int32
+	observers []func(Transition)
+}
+
+// Transition describes a change in the overall status of a Target
+type Transition struct {
+	Name         string
+	Description  string
+	From         int
+	To           int
+	Detail       string
+	FailingSince time.Time
+	Time         time.Time
+}
+
+// StatusName returns the name of the provided status value
+func StatusName(i int) string {
+	if i > 0 {
+		return "available"
+	}
+	if i < 0 {
+		return "unavailable"
+	}
+	return "unknown"
 }
 
 // StatusLookup is a map of named Status references
@@ -45,12 +76,13 @@ type StatusLookup map[string]*Status
 
 func (s *Status) String() string {
 	sb := strings.Builder{}
-	sb.WriteString(fmt.Sprintf("target: %s\nstatus: %d\n", s.name, s.status))
-	if s.status < 1 {
-		sb.WriteString(fmt.Sprintf("detail: %s\n", s.detail))
+	status := s.Get()
+	sb.WriteString(fmt.Sprintf("target: %s\nstatus: %d\n", s.name, status))
+	if status < 1 {
+		sb.WriteString(fmt.Sprintf("detail: %s\n", s.Detail()))
 	}
-	if s.status < 0 {
-		sb.WriteString(fmt.Sprintf("since: %d", s.failingSince.Unix()))
+	if status < 0 {
+		sb.WriteString(fmt.Sprintf("since: %d", s.FailingSince().Unix()))
 	}
 	return sb.String()
 }
@@ -58,9 +90,10 @@ func (s *Status) String() string {
 // Headers returns a header set indicating the Status
 func (s *Status) Headers() http.Header {
 	h := http.Header{}
-	h.Set(headers.NameTrkHCStatus, strconv.Itoa(int(s.status)))
-	if s.status < 1 {
-		h.Set(headers.NameTrkHCDetail, s.detail)
+	status := s.Get()
+	h.Set(headers.NameTrkHCStatus, strconv.Itoa(status))
+	if status < 1 {
+		h.Set(headers.NameTrkHCDetail, s.Detail())
 	}
 	return h
 }
@@ -68,9 +101,82 @@ func (s *Status) Headers() http.Header {
 // Set updates the status
 func (s *Status) Set(i int32) {
 	atomic.StoreInt32(&s.status, i)
-	for _, ch := range s.subscribers {
+	for _, ch := range s.getSubscribers() {
 		ch <- i == i
 	}
+	s.transition()
+}
+
+// SetCircuitOpen overlays the state of the backend's circuit breaker onto the
+// status. While the circuit is open, the status is unavailable (-1) with the
+// provided detail; once it closes or half-opens, the health check status applies
+func (s *Status) SetCircuitOpen(open bool, detail string) {
+	var v int32
+	if open {
+		v = 1
+	}
+	s.mtx.Lock()
+	if open {
+		s.circuitDetail = detail
+		s.circuitSince = time.Now()
+	}
+	s.mtx.Unlock()
+	if atomic.SwapInt32(&s.circuitOpen, v) == v {
+		return
+	}
+	for _, ch := range s.getSubscribers() {
+		ch <- true
+	}
+	s.transition()
+}
+
+// OnTransition registers a func that is called each time the overall status
+// changes. Observers are called synchronously and must not block.
+func (s *Status) OnTransition(f func(Transition)) {
+	s.mtx.Lock()
+	s.observers = append(s.observers, f)
+	s.mtx.Unlock()
+}
+
+// transition updates the status metrics and notifies observers when the
+// overall status differs from the last observed status
+func (s *Status) transition() {
+	to := s.Get()
+	from := int(atomic.SwapInt32(&s.last, int32(to)))
+	if from == to {
+		return
+	}
+	s.mtx.Lock()
+	observers := s.observers
+	s.mtx.Unlock()
+	t := Transition{
+		Name:         s.name,
+		Description:  s.description,
+		From:         from,
+		To:           to,
+		Detail:       s.Detail(),
+		FailingSince: s.FailingSince(),
+		Time:         time.Now(),
+	}
+	s.setMetrics(t.To, t.FailingSince)
+	metrics.HealthTransitions.WithLabelValues(s.name, StatusName(to)).Inc()
+	for _, f := range observers {
+		f(t)
+	}
+}
+
+func (s *Status) setMetrics(status int, failingSince time.Time) {
+	metrics.HealthStatus.WithLabelValues(s.name).Set(float64(status))
+	var fs float64
+	if status < 0 && !failingSince.IsZero() {
+		fs = float64(failingSince.Unix())
+	}
+	metrics.HealthFailingSince.WithLabelValues(s.name).Set(fs)
+}
+
+func (s *Status) deleteMetrics() {
+	metrics.HealthStatus.DeleteLabelValues(s.name)
+	metrics.HealthFailingSince.DeleteLabelValues(s.name)
 }
 
 // Prober returns the Prober func
@@ -80,11 +186,19 @@ func (s *Status) Prober() func(http.ResponseWriter) {
 
 // Get provides the current status
 func (s *Status) Get() int {
+	if atomic.LoadInt32(&s.circuitOpen) == 1 {
+		return -1
+	}
 	return int(atomic.LoadInt32(&s.status))
 }
 
 // Detail provides the current detail
 func (s *Status) Detail() string {
+	if atomic.LoadInt32(&s.circuitOpen) == 1 {
+		s.mtx.Lock()
+		defer s.mtx.Unlock()
+		return s.circuitDetail
+	}
 	return s.detail
 }
 
@@ -95,6 +209,11 @@ func (s *Status) Description() string {
 
 // FailingSince provides the failing since time
 func (s *Status) FailingSince() time.Time {
+	if atomic.LoadInt32(&s.circuitOpen) == 1 && s.failingSince.IsZero() {
+		s.mtx.Lock()
+		defer s.mtx.Unlock()
+		return s.circuitSince
+	}
 	return s.failingSince
 }
 
@@ -107,3 +226,22 @@ func (s *Status) RegisterSubscriber(ch chan bool) {
 	s.subscribers = append(s.subscribers, ch)
 	s.mtx.Unlock()
 }
+
+func (s *Status) getSubscribers() []chan bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.subscribers
+}
+
+// UnregisterSubscriber removes a subscriber from the Status
+func (s *Status) UnregisterSubscriber(ch chan bool) {
+	s.mtx.Lock()
+	subscribers := make([]chan bool, 0, len(s.subscribers))
+	for _, c := range s.subscribers {
+		if c != ch {
+			subscribers = append(subscribers, c)
+		}
+	}
+	s.subscribers = subscribers
+	s.mtx.Unlock()
+}
@@ -17,9 +17,13 @@
 package healthcheck
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/trickstercache/trickster/v2/pkg/observability/metrics"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
 )
 
@@ -92,3 +96,93 @@ func TestFailingSince(t *testing.T) {
 		t.Error("expected 0 got", status.FailingSince().Unix())
 	}
 }
+
+func TestSetCircuitOpen(t *testing.T) {
+	ch := make(chan bool, 4)
+	status := &Status{name: "test", status: 1}
+	status.RegisterSubscriber(ch)
+
+	status.SetCircuitOpen(true, "circuit open")
+	if status.Get() != -1 {
+		t.Error("expected -1 got", status.Get())
+	}
+	if status.Detail() != "circuit open" {
+		t.Error("expected circuit open got", status.Detail())
+	}
+	if status.FailingSince().IsZero() {
+		t.Error("expected non-zero failing since")
+	}
+	if v := status.Headers().Get(headers.NameTrkHCDetail); v != "circuit open" {
+		t.Error("expected circuit open got", v)
+	}
+	// repeated state is not re-notified
+	status.SetCircuitOpen(true, "circuit open")
+	if len(ch) != 1 {
+		t.Errorf("expected %d notifications got %d", 1, len(ch))
+	}
+
+	status.SetCircuitOpen(false, "")
+	if status.Get() != 1 {
+		t.Error("expected 1 got", status.Get())
+	}
+	if status.Detail() != "" {
+		t.Error("expected empty detail got", status.Detail())
+	}
+	if !status.FailingSince().IsZero() {
+		t.Error("expected zero failing since")
+	}
+	if len(ch) != 2 {
+		t.Errorf("expected %d notifications got %d", 2, len(ch))
+	}
+}
+
+func TestOnTransition(t *testing.T) {
+	status := &Status{name: "test-transition", description: "test"}
+	var ts []Transition
+	status.OnTransition(func(tr Transition) {
+		ts = append(ts, tr)
+	})
+	status.detail = "failed"
+	status.failingSince = time.Unix(1700000000, 0)
+	status.Set(-1)
+	status.Set(-1)
+	if len(ts) != 1 {
+		t.Fatalf("expected 1 transition got %d", len(ts))
+	}
+	if ts[0].From != 0 || ts[0].To != -1 || ts[0].Detail != "failed" ||
+		ts[0].Name != "test-transition" || !ts[0].FailingSince.Equal(status.failingSince) {
+		t.Errorf("unexpected transition %+v", ts[0])
+	}
+
+	w := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+	for _, s := range []string{
+		`trickster_health_status{backend_name="test-transition"} -1`,
+		`trickster_health_failing_since_time_seconds{backend_name="test-transition"} 1.7e+09`,
+		`trickster_health_transitions_total{backend_name="test-transition",status="unavailable"} 1`,
+	} {
+		if !strings.Contains(body, s) {
+			t.Errorf("expected metric %s", s)
+		}
+	}
+
+	status.failingSince = time.Time{}
+	status.Set(1)
+	status.SetCircuitOpen(true, "circuit open")
+	status.SetCircuitOpen(false, "")
+	if len(ts) != 4 {
+		t.Fatalf("expected 4 transitions got %d", len(ts))
+	}
+	if ts[2].From != 1 || ts[2].To != -1 || ts[2].Detail != "circuit open" ||
+		ts[3].To != 1 {
+		t.Errorf("unexpected transitions %+v", ts[2:])
+	}
+}
+
+func TestStatusName(t *testing.T) {
+	if StatusName(1) != "available" || StatusName(0) != "unknown" ||
+		StatusName(-1) != "unavailable" {
+		t.Error("unexpected status names")
+	}
+}
@@ -39,6 +39,7 @@ type target struct {
 	description           string
 	baseRequest           *http.Request
 	httpClient            *http.Client
+	checker               checker // nil for http probes
 	interval              time.Duration
 	timeout               time.Duration
 	status                *Status
@@ -83,8 +84,13 @@ func newTarget(ctx context.Context,
 		r.Header = headers.Lookup(o.Headers).ToHeader()
 	}
 	interval := time.Duration(o.IntervalMS) * time.Millisecond
+	timeout := ho.CalibrateTimeout(o.TimeoutMS)
 	if client == nil {
-		client = newHTTPClient(ho.CalibrateTimeout(o.TimeoutMS))
+		client = newHTTPClient(timeout)
+	}
+	c, err := newChecker(o, client, timeout)
+	if err != nil {
+		return nil, err
 	}
 	if o.FailureThreshold < 1 {
 		o.FailureThreshold = 3 // default to 3
@@ -98,6 +104,8 @@ func newTarget(ctx context.Context,
 		description:       description,
 		baseRequest:       r,
 		httpClient:        client,
+		checker:           c,
+		timeout:           timeout,
 		failureThreshold:  o.FailureThreshold,
 		recoveryThreshold: o.RecoveryThreshold,
 		interval:          interval,
@@ -203,23 +211,37 @@ func (t *target) probeLoop() {
 	}
 }
 
-func (t *target) probe() {
+// check performs a single probe of the target, setting the status detail and
+// returning false when the target is unhealthy
+func (t *target) check() bool {
+	if t.checker != nil {
+		ctx, cancel := context.WithTimeout(t.ctx, t.timeout)
+		defer cancel()
+		if err := t.checker(ctx); err != nil {
+			t.status.detail = err.Error()
+			return false
+		}
+		return true
+	}
 	r := t.baseRequest.Clone(t.ctx)
 	resp, err := t.httpClient.Do(r)
-	var errCnt, successCnt int
-	var passed bool
 	if err != nil || resp == nil {
 		t.status.detail = fmt.Sprintf("error probing target: %v", err)
-		errCnt = int(atomic.AddInt32(&t.failConsecutiveCnt, 1))
-		atomic.StoreInt32(&t.successConsecutiveCnt, 0)
-	} else if !t.isGoodCode(resp.StatusCode) || !t.isGoodHeader(resp.Header) || !t.isGoodBody(resp.Body) {
-		errCnt = int(atomic.AddInt32(&t.failConsecutiveCnt, 1))
-		atomic.StoreInt32(&t.successConsecutiveCnt, 0)
-	} else {
-		resp.Body.Close()
+		return false
+	}
+	defer resp.Body.Close()
+	return t.isGoodCode(resp.StatusCode) && t.isGoodHeader(resp.Header) && t.isGoodBody(resp.Body)
+}
+
+func (t *target) probe() {
+	var errCnt, successCnt int
+	passed := t.check()
+	if passed {
 		successCnt = int(atomic.AddInt32(&t.successConsecutiveCnt, 1))
 		atomic.StoreInt32(&t.failConsecutiveCnt, 0)
-		passed = true
+	} else {
+		errCnt = int(atomic.AddInt32(&t.failConsecutiveCnt, 1))
+		atomic.StoreInt32(&t.successConsecutiveCnt, 0)
 	}
 	if !passed && t.ks != -1 && (errCnt == t.failureThreshold || t.ks == 0) {
 		t.status.failingSince = time.Now()
@@ -244,6 +266,10 @@ func (t *target) probe() {
 }
 
 func (t *target) demandProbe(w http.ResponseWriter) {
+	if t.checker != nil {
+		t.demandCheck(w)
+		return
+	}
 	r := t.baseRequest.Clone(context.Background())
 	resp, err := t.httpClient.Do(r)
 	h := w.Header()
@@ -273,6 +299,28 @@ func (t *target) demandProbe(w http.ResponseWriter) {
 	}
 }
 
+// demandCheck performs a non-HTTP probe of the target and writes its result
+// to the provided ResponseWriter
+func (t *target) demandCheck(w http.ResponseWriter) {
+	ctx, cancel := context.WithTimeout(context.Background(), t.timeout)
+	defer cancel()
+	err := t.checker(ctx)
+	if t.status != nil && t.status.status != 0 {
+		h := w.Header()
+		sh := t.status.Headers()
+		for k := range sh {
+			h.Set(k, sh.Get(k))
+		}
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte("error performing health check: " + err.Error()))
+		return
+	}
+	w.WriteHeader(200)
+	w.Write([]byte("health check passed"))
+}
+
 func newHTTPClient(timeout time.Duration) *http.Client {
 	return &http.Client{
 		Timeout: timeout,
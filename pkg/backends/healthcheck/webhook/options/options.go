@@ -0,0 +1,188 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package options provides the configuration for health transition webhooks
+package options
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+)
+
+// ErrInvalidWebhookURL returns an error for a webhook with a missing or invalid URL
+var ErrInvalidWebhookURL = errors.New("webhook url must be an absolute http or https url")
+
+const (
+	// DefaultMethod is the default HTTP method for webhook deliveries
+	DefaultMethod = http.MethodPost
+	// DefaultTimeoutMS is the default amount of time a webhook delivery may take
+	DefaultTimeoutMS = 5000
+	// DefaultMaxRetries is the default number of times a failed delivery is retried
+	DefaultMaxRetries = 3
+	// DefaultRetryBackoffMS is the default delay before the first retry, which
+	// doubles with each subsequent retry
+	DefaultRetryBackoffMS = 1000
+	// DefaultMaxPerMinute is the default maximum number of deliveries per minute
+	DefaultMaxPerMinute = 60
+)
+
+// Options defines the configuration for a Health Webhook, which delivers a JSON
+// payload to an HTTP endpoint each time a backend's health status changes
+type Options struct {
+	// Name is the name of the Webhook
+	Name string `json:"-"`
+	// URL is the endpoint to which transitions are delivered
+	URL string `json:"url,omitempty"`
+	// Method is the HTTP method of the delivery request
+	Method string `json:"method,omitempty"`
+	// Headers is the map of HTTP headers to include in delivery requests
+	Headers map[string]string `json:"headers,omitempty"`
+	// Backends limits the webhook to transitions of the named backends; when
+	// empty, transitions of all health-checked backends are delivered
+	Backends []string `json:"backends,omitempty"`
+	// TimeoutMS is the amount of time a delivery attempt may take
+	TimeoutMS int `json:"timeout_ms,omitempty"`
+	// MaxRetries is the number of times a failed delivery is retried; a negative
+	// value disables retries
+	MaxRetries int `json:"max_retries,omitempty"`
+	// RetryBackoffMS is the delay before the first retry, doubling with each retry
+	RetryBackoffMS int `json:"retry_backoff_ms,omitempty"`
+	// MaxPerMinute is the maximum number of deliveries per minute; transitions
+	// in excess of the limit are dropped
+	MaxPerMinute int `json:"max_per_minute,omitempty"`
+}
+
+// Lookup is a map of Options
+type Lookup map[string]*Options
+
+// New returns a new Options with default values
+func New() *Options {
+	return &Options{
+		Method:         DefaultMethod,
+		TimeoutMS:      DefaultTimeoutMS,
+		MaxRetries:     DefaultMaxRetries,
+		RetryBackoffMS: DefaultRetryBackoffMS,
+		MaxPerMinute:   DefaultMaxPerMinute,
+	}
+}
+
+// Clone returns an exact copy of the subject Options
+func (o *Options) Clone() *Options {
+	c := &Options{
+		Name:           o.Name,
+		URL:            o.URL,
+		Method:         o.Method,
+		TimeoutMS:      o.TimeoutMS,
+		MaxRetries:     o.MaxRetries,
+		RetryBackoffMS: o.RetryBackoffMS,
+		MaxPerMinute:   o.MaxPerMinute,
+	}
+	if o.Headers != nil {
+		c.Headers = headers.Lookup(o.Headers).Clone()
+	}
+	if o.Backends != nil {
+		c.Backends = slices.Clone(o.Backends)
+	}
+	return c
+}
+
+// CloneYAMLSafe returns a copy of the Options that is safe to export to YAML
+// without exposing credentials in the headers or the URL's userinfo
+func (o *Options) CloneYAMLSafe() *Options {
+	c := o.Clone()
+	headers.HideAuthorizationCredentials(c.Headers)
+	if u, err := url.Parse(c.URL); err == nil && u.User != nil {
+		// the userinfo is masked after encoding the URL, so that the mask isn't escaped
+		ui := "*****"
+		if _, ok := u.User.Password(); ok {
+			ui = u.User.Username() + ":*****"
+		}
+		u.User = nil
+		c.URL = strings.Replace(u.String(), "//", "//"+ui+"@", 1)
+	}
+	return c
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Options) DeepCopyInto(out *Options) {
+	*out = *in.Clone()
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Options.
+func (in *Options) DeepCopy() *Options {
+	if in == nil {
+		return nil
+	}
+	out := new(Options)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// Includes returns true if the webhook delivers transitions for the named backend
+func (o *Options) Includes(backendName string) bool {
+	return len(o.Backends) == 0 || slices.Contains(o.Backends, backendName)
+}
+
+// SetDefaults applies default values to any unset Options in the Lookup, and
+// validates that each webhook has a valid URL and references defined backends
+func (l Lookup) SetDefaults(backends bo.Lookup) error {
+	for k, o := range l {
+		if o == nil {
+			o = New()
+			l[k] = o
+		}
+		o.Name = k
+		u, err := url.Parse(o.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%w: %s", ErrInvalidWebhookURL, k)
+		}
+		for _, b := range o.Backends {
+			if _, ok := backends[b]; !ok {
+				return fmt.Errorf("invalid backend name [%s] provided in health webhook [%s]",
+					b, k)
+			}
+		}
+		if o.Method == "" {
+			o.Method = DefaultMethod
+		}
+		o.Method = strings.ToUpper(o.Method)
+		if o.Method != http.MethodPost && o.Method != http.MethodPut {
+			return fmt.Errorf("invalid method [%s] in health webhook [%s]", o.Method, k)
+		}
+		if o.TimeoutMS <= 0 {
+			o.TimeoutMS = DefaultTimeoutMS
+		}
+		if o.MaxRetries == 0 {
+			o.MaxRetries = DefaultMaxRetries
+		} else if o.MaxRetries < 0 {
+			o.MaxRetries = 0
+		}
+		if o.RetryBackoffMS <= 0 {
+			o.RetryBackoffMS = DefaultRetryBackoffMS
+		}
+		if o.MaxPerMinute <= 0 {
+			o.MaxPerMinute = DefaultMaxPerMinute
+		}
+	}
+	return nil
+}
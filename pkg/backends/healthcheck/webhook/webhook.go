@@ -0,0 +1,255 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package webhook delivers backend health status transitions to HTTP endpoints
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/backends/healthcheck"
+	wo "github.com/trickstercache/trickster/v2/pkg/backends/healthcheck/webhook/options"
+	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
+	"github.com/trickstercache/trickster/v2/pkg/observability/metrics"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+)
+
+// queueSize is the number of transitions a Webhook buffers for delivery
+const queueSize = 64
+
+// Payload is the JSON document delivered to a webhook endpoint for a transition
+type Payload struct {
+	Backend      string     `json:"backend"`
+	Provider     string     `json:"provider,omitempty"`
+	OldState     string     `json:"old_state"`
+	NewState     string     `json:"new_state"`
+	Detail       string     `json:"detail,omitempty"`
+	FailingSince *time.Time `json:"failing_since,omitempty"`
+	Time         time.Time  `json:"time"`
+}
+
+// NewPayload returns a Payload for the provided Transition
+func NewPayload(t healthcheck.Transition) Payload {
+	p := Payload{
+		Backend:  t.Name,
+		Provider: t.Description,
+		OldState: healthcheck.StatusName(t.From),
+		NewState: healthcheck.StatusName(t.To),
+		Detail:   t.Detail,
+		Time:     t.Time.UTC(),
+	}
+	if t.To < 0 && !t.FailingSince.IsZero() {
+		fs := t.FailingSince.UTC()
+		p.FailingSince = &fs
+	}
+	return p
+}
+
+// Webhook delivers health transitions to an HTTP endpoint
+type Webhook struct {
+	options *wo.Options
+	client  *http.Client
+	logger  interface{}
+	queue   chan Payload
+	cancel  context.CancelFunc
+	ctx     context.Context
+	wg      sync.WaitGroup
+
+	mtx        sync.Mutex
+	tokens     float64
+	lastRefill time.Time
+}
+
+// Webhooks is a list of Webhooks
+type Webhooks []*Webhook
+
+// New returns a new Webhook for the provided options
+func New(o *wo.Options, logger interface{}) *Webhook {
+	return &Webhook{
+		options:    o,
+		client:     &http.Client{Timeout: time.Duration(o.TimeoutMS) * time.Millisecond},
+		logger:     logger,
+		queue:      make(chan Payload, queueSize),
+		tokens:     float64(o.MaxPerMinute),
+		lastRefill: time.Now(),
+	}
+}
+
+// StartAll creates and starts a Webhook for each of the provided options. The
+// Webhooks receive transitions once their Notify func is registered as a
+// transition observer, which should happen before the health checks start.
+func StartAll(opts wo.Lookup, logger interface{}) Webhooks {
+	if len(opts) == 0 {
+		return nil
+	}
+	ws := make(Webhooks, 0, len(opts))
+	for _, o := range opts {
+		w := New(o, logger)
+		w.Start()
+		ws = append(ws, w)
+	}
+	return ws
+}
+
+// Notify queues the transition for delivery by each Webhook in the list
+func (ws Webhooks) Notify(t healthcheck.Transition) {
+	for _, w := range ws {
+		w.Notify(t)
+	}
+}
+
+// Stop stops all of the Webhooks in the list
+func (ws Webhooks) Stop() {
+	for _, w := range ws {
+		w.Stop()
+	}
+}
+
+// Start starts the Webhook's delivery loop in a new goroutine
+func (w *Webhook) Start() {
+	w.ctx, w.cancel = context.WithCancel(context.Background())
+	w.wg.Add(1)
+	go w.run(w.ctx)
+}
+
+// Stop stops the Webhook, abandoning any queued or in-flight deliveries
+func (w *Webhook) Stop() {
+	if w.cancel == nil {
+		return
+	}
+	w.cancel()
+	w.wg.Wait()
+	w.cancel = nil
+}
+
+// Notify queues the transition for delivery, unless the webhook does not
+// include the transitioned backend, or the webhook is stopped or rate limited.
+// The initial transition of a backend from unknown to available is not delivered.
+func (w *Webhook) Notify(t healthcheck.Transition) {
+	if !w.options.Includes(t.Name) || (t.From == 0 && t.To > 0) ||
+		w.ctx == nil || w.ctx.Err() != nil {
+		return
+	}
+	if !w.allow(time.Now()) {
+		w.drop(t.Name, "rate limit exceeded")
+		return
+	}
+	select {
+	case w.queue <- NewPayload(t):
+	default:
+		w.drop(t.Name, "delivery queue is full")
+	}
+}
+
+func (w *Webhook) drop(backendName, reason string) {
+	metrics.HealthWebhookDeliveries.WithLabelValues(w.options.Name, "dropped").Inc()
+	tl.Warn(w.logger, "health webhook delivery dropped",
+		tl.Pairs{"webhookName": w.options.Name, "backendName": backendName,
+			"reason": reason})
+}
+
+// allow returns true if a delivery is permitted by the webhook's rate limit,
+// which is a token bucket holding up to MaxPerMinute deliveries
+func (w *Webhook) allow(now time.Time) bool {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	limit := float64(w.options.MaxPerMinute)
+	w.tokens = min(limit, w.tokens+now.Sub(w.lastRefill).Minutes()*limit)
+	w.lastRefill = now
+	if w.tokens < 1 {
+		return false
+	}
+	w.tokens--
+	return true
+}
+
+func (w *Webhook) run(ctx context.Context) {
+	defer w.wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case p := <-w.queue:
+			w.deliver(ctx, p)
+		}
+	}
+}
+
+// deliver sends the payload to the webhook endpoint, retrying failed attempts
+// with exponential backoff
+func (w *Webhook) deliver(ctx context.Context, p Payload) {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return
+	}
+	backoff := time.Duration(w.options.RetryBackoffMS) * time.Millisecond
+	for i := 0; ; i++ {
+		err = w.send(ctx, b)
+		if err == nil {
+			metrics.HealthWebhookDeliveries.WithLabelValues(w.options.Name, "success").Inc()
+			return
+		}
+		if i >= w.options.MaxRetries || !sleep(ctx, backoff) {
+			break
+		}
+		backoff *= 2
+	}
+	metrics.HealthWebhookDeliveries.WithLabelValues(w.options.Name, "failed").Inc()
+	tl.Warn(w.logger, "health webhook delivery failed",
+		tl.Pairs{"webhookName": w.options.Name, "backendName": p.Backend,
+			"newState": p.NewState, "detail": err.Error()})
+}
+
+func (w *Webhook) send(ctx context.Context, body []byte) error {
+	r, err := http.NewRequestWithContext(ctx, w.options.Method, w.options.URL,
+		bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range w.options.Headers {
+		r.Header.Set(k, v)
+	}
+	r.Header.Set(headers.NameContentType, headers.ValueApplicationJSON)
+	resp, err := w.client.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// sleep waits for the duration, returning false if the context is canceled first
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
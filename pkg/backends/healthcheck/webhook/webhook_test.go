@@ -0,0 +1,140 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/backends/healthcheck"
+	wo "github.com/trickstercache/trickster/v2/pkg/backends/healthcheck/webhook/options"
+	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
+)
+
+func testOptions(url string) *wo.Options {
+	o := wo.New()
+	o.Name = "test"
+	o.URL = url
+	o.RetryBackoffMS = 10
+	o.Headers = map[string]string{"X-Test": "1"}
+	return o
+}
+
+func testTransition(from, to int) healthcheck.Transition {
+	return healthcheck.Transition{Name: "backend1", Description: "prometheus",
+		From: from, To: to, Detail: "error probing target",
+		FailingSince: time.Unix(1700000000, 0), Time: time.Unix(1700000001, 0)}
+}
+
+func TestNewPayload(t *testing.T) {
+	p := NewPayload(testTransition(1, -1))
+	if p.Backend != "backend1" || p.Provider != "prometheus" || p.OldState != "available" ||
+		p.NewState != "unavailable" || p.FailingSince == nil ||
+		p.FailingSince.Unix() != 1700000000 {
+		t.Errorf("unexpected payload %+v", p)
+	}
+	p = NewPayload(testTransition(-1, 1))
+	if p.FailingSince != nil {
+		t.Error("expected nil failing since")
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	var calls atomic.Int32
+	got := make(chan Payload, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first attempt fails, so the delivery is retried
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		if r.Method != http.MethodPost || r.Header.Get("X-Test") != "1" ||
+			r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request %s %v", r.Method, r.Header)
+		}
+		var p Payload
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			t.Error(err)
+		}
+		got <- p
+	}))
+	defer ts.Close()
+
+	ws := StartAll(wo.Lookup{"test": testOptions(ts.URL)}, tl.ConsoleLogger("error"))
+	defer ws.Stop()
+	if len(ws) != 1 {
+		t.Fatalf("expected 1 webhook got %d", len(ws))
+	}
+	ws.Notify(testTransition(1, -1))
+	select {
+	case p := <-got:
+		if p.Backend != "backend1" || p.NewState != "unavailable" || p.OldState != "available" {
+			t.Errorf("unexpected payload %+v", p)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+	if calls.Load() != 2 {
+		t.Errorf("expected 2 calls got %d", calls.Load())
+	}
+}
+
+func TestNotifyFilters(t *testing.T) {
+	o := testOptions("http://127.0.0.1/")
+	o.Backends = []string{"backend2"}
+	w := New(o, tl.ConsoleLogger("error"))
+	// not started
+	w.Notify(testTransition(1, -1))
+	w.Start()
+	defer w.Stop()
+	// excluded backend
+	w.Notify(testTransition(1, -1))
+	o.Backends = nil
+	// initial availability
+	w.Notify(testTransition(0, 1))
+	if len(w.queue) != 0 {
+		t.Errorf("expected empty queue got %d", len(w.queue))
+	}
+}
+
+func TestAllow(t *testing.T) {
+	o := testOptions("http://127.0.0.1/")
+	o.MaxPerMinute = 2
+	w := New(o, nil)
+	now := time.Now()
+	if !w.allow(now) || !w.allow(now) {
+		t.Error("expected allowed")
+	}
+	if w.allow(now) {
+		t.Error("expected rate limited")
+	}
+	if !w.allow(now.Add(30 * time.Second)) {
+		t.Error("expected allowed after refill")
+	}
+}
+
+func TestStartAllEmpty(t *testing.T) {
+	var ws Webhooks
+	if ws = StartAll(nil, nil); ws != nil {
+		t.Error("expected nil webhooks")
+	}
+	ws.Stop()
+}
@@ -34,6 +34,7 @@ type WFResult struct {
 	StatementID int          `json:"statement_id"`
 	SeriesList  []models.Row `json:"series,omitempty"`
 	Err         string       `json:"error,omitempty"`
+	Partial     bool         `json:"partial,omitempty"`
 }
 
 var epochMultipliers = map[byte]int64{
@@ -19,6 +19,7 @@ package model
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
 	"sort"
 	"sync"
@@ -62,6 +63,16 @@ func UnmarshalTimeseriesReader(reader io.Reader, trq *timeseries.TimeRangeQuery)
 			StatementID: wfd.Results[i].StatementID,
 			Error:       wfd.Results[i].Err,
 		}
+		// statement errors and partial results indicate the response is incomplete,
+		// so they are surfaced as DataSet warnings for the caching layer to act on
+		if wfd.Results[i].Err != "" {
+			ds.Warnings = append(ds.Warnings, fmt.Sprintf("statement %d: %s",
+				wfd.Results[i].StatementID, wfd.Results[i].Err))
+		}
+		if wfd.Results[i].Partial {
+			ds.Warnings = append(ds.Warnings, fmt.Sprintf("statement %d: partial result",
+				wfd.Results[i].StatementID))
+		}
 		if wfd.Results[i].SeriesList == nil {
 			continue
 		}
@@ -93,6 +104,10 @@ func UnmarshalTimeseriesReader(reader io.Reader, trq *timeseries.TimeRangeQuery)
 			if !timeFound || wfd.Results[i].SeriesList[j].Values == nil {
 				return nil, timeseries.ErrInvalidBody
 			}
+			if wfd.Results[i].SeriesList[j].Partial {
+				ds.Warnings = append(ds.Warnings, fmt.Sprintf("statement %d: series %s is partial",
+					wfd.Results[i].StatementID, sh.Name))
+			}
 			sh.CalculateSize()
 			pts := make(dataset.Points, 0, len(wfd.Results[i].SeriesList[j].Values))
 			var sz int64
@@ -76,6 +76,33 @@ func TestUnmarshalTimeseries(t *testing.T) {
 	}
 }
 
+const testDocPartial01 = `{"results":[{"statement_id":0,"partial":true,"series":[` +
+	`{"name":"trickster","columns":["time","value"],"partial":true,` +
+	`"values":[[1577836800000,0.484]]}]},` +
+	`{"statement_id":1,"error":"timeout"}]}`
+
+func TestUnmarshalTimeseriesWarnings(t *testing.T) {
+	trq := &timeseries.TimeRangeQuery{Statement: "hello"}
+	ts, err := UnmarshalTimeseries([]byte(testDoc01), trq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := timeseries.Warnings(ts); len(w) != 0 {
+		t.Errorf("expected no warnings, got %v", w)
+	}
+
+	ts, err = UnmarshalTimeseries([]byte(testDocPartial01), trq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"statement 0: partial result", "statement 0: series trickster is partial",
+		"statement 1: timeout"}
+	w := timeseries.Warnings(ts)
+	if strings.Join(w, "|") != strings.Join(expected, "|") {
+		t.Errorf("expected %v got %v", expected, w)
+	}
+}
+
 func TestPointFromValues(t *testing.T) {
 	v := make([]interface{}, 6)
 
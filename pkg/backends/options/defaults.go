@@ -18,6 +18,7 @@ package options
 
 import (
 	"github.com/trickstercache/trickster/v2/pkg/cache/evictionmethods"
+	"github.com/trickstercache/trickster/v2/pkg/cache/warningpolicies"
 )
 
 const (
@@ -37,6 +38,10 @@ const (
 	DefaultBackendTEM = evictionmethods.EvictionMethodOldest
 	// DefaultBackendTEMName is the default Timeseries Eviction Method name for Time Series-based Backends
 	DefaultBackendTEMName = "oldest"
+	// DefaultBackendTWP is the default Timeseries Warning Policy for Time Series-based Backends
+	DefaultBackendTWP = warningpolicies.WarningPolicyVolatile
+	// DefaultBackendTWPName is the default Timeseries Warning Policy name for Time Series-based Backends
+	DefaultBackendTWPName = "volatile"
 	// DefaultBackendTimeoutMS is the default Upstream Request Timeout for Backends
 	DefaultBackendTimeoutMS = 180000
 	// DefaultBackendCacheName is the default Cache Name for Backends
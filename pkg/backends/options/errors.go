@@ -73,6 +73,84 @@ func NewErrInvalidNegativeCacheName(cacheName string) error {
 	return e
 }
 
+// ErrInvalidNegativeCacheRules is an error type for invalid negative cache rules
+type ErrInvalidNegativeCacheRules struct {
+	error
+}
+
+// NewErrInvalidNegativeCacheRules returns a new invalid negative cache rules error
+func NewErrInvalidNegativeCacheRules(backendName string, err error) error {
+	var e *ErrInvalidNegativeCacheRules = &ErrInvalidNegativeCacheRules{
+		error: fmt.Errorf(`invalid negative cache rules for backend "%s": %w`, backendName, err),
+	}
+	return e
+}
+
+// ErrInvalidRetryOptions is an error type for invalid retry options
+type ErrInvalidRetryOptions struct {
+	error
+}
+
+// NewErrInvalidRetryOptions returns a new invalid retry options error
+func NewErrInvalidRetryOptions(backendName string, err error) error {
+	var e *ErrInvalidRetryOptions = &ErrInvalidRetryOptions{
+		error: fmt.Errorf(`invalid retry options for backend "%s": %w`, backendName, err),
+	}
+	return e
+}
+
+// ErrInvalidCircuitBreakerOptions is an error type for invalid circuit breaker options
+type ErrInvalidCircuitBreakerOptions struct {
+	error
+}
+
+// NewErrInvalidCircuitBreakerOptions returns a new invalid circuit breaker options error
+func NewErrInvalidCircuitBreakerOptions(backendName string, err error) error {
+	var e *ErrInvalidCircuitBreakerOptions = &ErrInvalidCircuitBreakerOptions{
+		error: fmt.Errorf(`invalid circuit breaker options for backend "%s": %w`, backendName, err),
+	}
+	return e
+}
+
+// ErrInvalidMirrorOptions is an error type for invalid mirror options
+type ErrInvalidMirrorOptions struct {
+	error
+}
+
+// NewErrInvalidMirrorOptions returns a new invalid mirror options error
+func NewErrInvalidMirrorOptions(backendName string, err error) error {
+	var e *ErrInvalidMirrorOptions = &ErrInvalidMirrorOptions{
+		error: fmt.Errorf(`invalid mirror options for backend "%s": %w`, backendName, err),
+	}
+	return e
+}
+
+// ErrInvalidFaultInjectionOptions is an error type for invalid fault injection options
+type ErrInvalidFaultInjectionOptions struct {
+	error
+}
+
+// NewErrInvalidFaultInjectionOptions returns a new invalid fault injection options error
+func NewErrInvalidFaultInjectionOptions(backendName string, err error) error {
+	var e *ErrInvalidFaultInjectionOptions = &ErrInvalidFaultInjectionOptions{
+		error: fmt.Errorf(`invalid fault injection options for backend "%s": %w`, backendName, err),
+	}
+	return e
+}
+
+// ErrInvalidUpstreamAuthOptions is an error type for invalid upstream auth options
+type ErrInvalidUpstreamAuthOptions struct {
+	error
+}
+
+// NewErrInvalidUpstreamAuthOptions returns a new invalid upstream auth options error
+func NewErrInvalidUpstreamAuthOptions(backendName string, err error) error {
+	var e *ErrInvalidUpstreamAuthOptions = &ErrInvalidUpstreamAuthOptions{
+		error: fmt.Errorf(`invalid upstream_auth options for backend "%s": %w`, backendName, err),
+	}
+	return e
+}
+
 // ErrInvalidRuleName is an error type for invalid rule name
 type ErrInvalidRuleName struct {
 	error
@@ -141,3 +219,17 @@ func NewErrInvalidRewriterName(rewriterName, backendName string) error {
 	}
 	return e
 }
+
+// ErrInvalidTimeseriesWarningPolicy is an error type for invalid timeseries warning policy
+type ErrInvalidTimeseriesWarningPolicy struct {
+	error
+}
+
+// NewErrInvalidTimeseriesWarningPolicy returns a new invalid timeseries warning policy error
+func NewErrInvalidTimeseriesWarningPolicy(policyName, backendName string) error {
+	var e *ErrInvalidTimeseriesWarningPolicy = &ErrInvalidTimeseriesWarningPolicy{
+		error: fmt.Errorf(`invalid timeseries_warning_policy "%s" provided in backend options "%s"`,
+			policyName, backendName),
+	}
+	return e
+}
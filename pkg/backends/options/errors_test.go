@@ -19,6 +19,10 @@ package options
 import (
 	"errors"
 	"testing"
+
+	"github.com/trickstercache/trickster/v2/pkg/cache/negative"
+	cbo "github.com/trickstercache/trickster/v2/pkg/proxy/circuitbreaker/options"
+	rto "github.com/trickstercache/trickster/v2/pkg/proxy/retry/options"
 )
 
 func TestErrMissingProvider(t *testing.T) {
@@ -57,6 +61,33 @@ func TestInvalidNegativeCacheName(t *testing.T) {
 	}
 }
 
+func TestInvalidNegativeCacheRules(t *testing.T) {
+	err := NewErrInvalidNegativeCacheRules("test", negative.ErrNoRuleMatchers)
+	var e *ErrInvalidNegativeCacheRules
+	ok := errors.As(err, &e)
+	if !ok {
+		t.Error("invalid type assertion")
+	}
+}
+
+func TestInvalidRetryOptions(t *testing.T) {
+	err := NewErrInvalidRetryOptions("test", rto.ErrInvalidJitter)
+	var e *ErrInvalidRetryOptions
+	ok := errors.As(err, &e)
+	if !ok {
+		t.Error("invalid type assertion")
+	}
+}
+
+func TestInvalidCircuitBreakerOptions(t *testing.T) {
+	err := NewErrInvalidCircuitBreakerOptions("test", cbo.ErrInvalidRate)
+	var e *ErrInvalidCircuitBreakerOptions
+	ok := errors.As(err, &e)
+	if !ok {
+		t.Error("invalid type assertion")
+	}
+}
+
 func TestInvalidRuleName(t *testing.T) {
 	err := NewErrInvalidRuleName("testRule", "testBackend")
 	var e *ErrInvalidRuleName
@@ -92,3 +123,12 @@ func TestInvalidRewriterName(t *testing.T) {
 		t.Error("invalid type assertion")
 	}
 }
+
+func TestInvalidTimeseriesWarningPolicy(t *testing.T) {
+	err := NewErrInvalidTimeseriesWarningPolicy("testPolicy", "testBackend")
+	var e *ErrInvalidTimeseriesWarningPolicy
+	ok := errors.As(err, &e)
+	if !ok {
+		t.Error("invalid type assertion")
+	}
+}
@@ -17,8 +17,10 @@
 package options
 
 import (
+	"fmt"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
 	"time"
 
@@ -29,10 +31,18 @@ import (
 	"github.com/trickstercache/trickster/v2/pkg/cache/evictionmethods"
 	"github.com/trickstercache/trickster/v2/pkg/cache/negative"
 	co "github.com/trickstercache/trickster/v2/pkg/cache/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache/warningpolicies"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/circuitbreaker"
+	cbo "github.com/trickstercache/trickster/v2/pkg/proxy/circuitbreaker/options"
+	fo "github.com/trickstercache/trickster/v2/pkg/proxy/faults/options"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+	mo "github.com/trickstercache/trickster/v2/pkg/proxy/mirror/options"
 	po "github.com/trickstercache/trickster/v2/pkg/proxy/paths/options"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/request/rewriter"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/retry"
+	rto "github.com/trickstercache/trickster/v2/pkg/proxy/retry/options"
 	to "github.com/trickstercache/trickster/v2/pkg/proxy/tls/options"
+	uao "github.com/trickstercache/trickster/v2/pkg/proxy/upstreamauth/options"
 	"github.com/trickstercache/trickster/v2/pkg/util/copiers"
 	"github.com/trickstercache/trickster/v2/pkg/util/yamlx"
 
@@ -68,6 +78,18 @@ type Options struct {
 	CacheKeyPrefix string `json:"cache_key_prefix,omitempty"`
 	// HealthCheck is the health check options reference for this backend
 	HealthCheck *ho.Options `json:"healthcheck,omitempty"`
+	// Retry is the upstream retry and hedging policy for this backend
+	Retry *rto.Options `json:"retry,omitempty"`
+	// CircuitBreaker is the circuit breaker policy for this backend
+	CircuitBreaker *cbo.Options `json:"circuit_breaker,omitempty"`
+	// Mirror is the request mirroring policy for this backend, which can be
+	// overridden per-path
+	Mirror *mo.Options `json:"mirror,omitempty"`
+	// FaultInjection is the fault injection policy for this backend, which can
+	// be overridden per-path
+	FaultInjection *fo.Options `json:"fault_injection,omitempty"`
+	// UpstreamAuth configures how requests to the origin are authenticated
+	UpstreamAuth *uao.Options `json:"upstream_auth,omitempty"`
 	// Object Proxy Cache and Delta Proxy Cache Configurations
 	// TimeseriesRetentionFactor limits the maximum the number of chronological
 	// timestamps worth of data to store in cache for each query
@@ -75,6 +97,9 @@ type Options struct {
 	// TimeseriesEvictionMethodName specifies which methodology ("oldest", "lru") is used to identify
 	// timeseries to evict from a full cache object
 	TimeseriesEvictionMethodName string `json:"timeseries_eviction_method,omitempty"`
+	// TimeseriesWarningPolicyName specifies how ("volatile", "skip", "cache") timeseries data from
+	// upstream responses carrying warnings, such as partial responses, is cached
+	TimeseriesWarningPolicyName string `json:"timeseries_warning_policy,omitempty"`
 	// BackfillToleranceMS prevents values with timestamps newer than the provided number of
 	// milliseconds from being cached. this allows propagation of upstream backfill operations
 	// that modify recently-cached data
@@ -87,6 +112,9 @@ type Options struct {
 	Paths map[string]*po.Options `json:"paths,omitempty"`
 	// NegativeCacheName provides the name of the Negative Cache Config to be used by this Backend
 	NegativeCacheName string `json:"negative_cache_name,omitempty"`
+	// NegativeCacheRules is an ordered list of rules that match upstream responses by status
+	// class, headers or body content, and either negatively cache them or serve the last good response
+	NegativeCacheRules []*negative.RuleOptions `json:"negative_cache_rules,omitempty"`
 	// TimeseriesTTLMS specifies the cache TTL of timeseries objects
 	TimeseriesTTLMS int `json:"timeseries_ttl_ms,omitempty"`
 	// TimeseriesTTLMS specifies the cache TTL of fast forward data
@@ -140,6 +168,10 @@ type Options struct {
 	IsDefault bool `json:"is_default,omitempty"`
 	// FastForwardDisable indicates whether the FastForward feature should be disabled for this backend
 	FastForwardDisable bool `json:"fast_forward_disable,omitempty"`
+	// ServeStaleOnError, when true, indicates that when upstream fetches for a timeseries request
+	// fail with a network error or 5xx response, the cached portion of the requested range is
+	// served in place of the upstream error
+	ServeStaleOnError bool `json:"serve_stale_on_error,omitempty"`
 	// PathRoutingDisabled, when true, will bypass /backendName/path route registrations
 	PathRoutingDisabled bool `json:"path_routing_disabled,omitempty"`
 	// RequireTLS, when true, indicates this Backend Config's paths must only be registered with the TLS Router
@@ -188,11 +220,15 @@ type Options struct {
 	PathPrefix string `json:"-"`
 	// NegativeCache provides a map for the negative cache, with TTLs converted to time.Durations
 	NegativeCache negative.Lookup `json:"-"`
+	// NegativeRules is the compiled form of NegativeCacheRules
+	NegativeRules negative.Rules `json:"-"`
 	// TimeseriesRetention when subtracted from time.Now() represents the oldest allowable timestamp in a
 	// timeseries when EvictionMethod is 'oldest'
 	TimeseriesRetention time.Duration `json:"-"`
 	// TimeseriesEvictionMethod is the parsed value of TimeseriesEvictionMethodName
 	TimeseriesEvictionMethod evictionmethods.TimeseriesEvictionMethod `json:"-"`
+	// TimeseriesWarningPolicy is the parsed value of TimeseriesWarningPolicyName
+	TimeseriesWarningPolicy warningpolicies.TimeseriesWarningPolicy `json:"-"`
 	// TimeseriesTTL is the parsed value of TimeseriesTTLMS
 	TimeseriesTTL time.Duration `json:"-"`
 	// FastForwardTTL is the parsed value of FastForwardTTL
@@ -203,6 +239,10 @@ type Options struct {
 	MaxTTL time.Duration `json:"-"`
 	// HTTPClient is the Client used by Trickster to communicate with the origin
 	HTTPClient *http.Client `json:"-"`
+	// RetryPolicy applies the Retry options to upstream requests
+	RetryPolicy *retry.Policy `json:"-"`
+	// Breaker applies the CircuitBreaker options to upstream requests
+	Breaker *circuitbreaker.Breaker `json:"-"`
 	// CompressibleTypes is the map version of CompressibleTypeList for fast lookup
 	CompressibleTypes map[string]interface{} `json:"-"`
 	// RuleOptions is the reference to the Rule Options as indicated by RuleName
@@ -254,6 +294,8 @@ func New() *Options {
 		TimeoutMS:                    DefaultBackendTimeoutMS,
 		TimeseriesEvictionMethod:     DefaultBackendTEM,
 		TimeseriesEvictionMethodName: DefaultBackendTEMName,
+		TimeseriesWarningPolicy:      DefaultBackendTWP,
+		TimeseriesWarningPolicyName:  DefaultBackendTWPName,
 		TimeseriesRetention:          DefaultBackendTRF,
 		TimeseriesRetentionFactor:    DefaultBackendTRF,
 		TimeseriesTTL:                DefaultTimeseriesTTLMS * time.Millisecond,
@@ -273,6 +315,7 @@ func (o *Options) Clone() *Options {
 	no.CacheKeyPrefix = o.CacheKeyPrefix
 	no.DoesShard = o.DoesShard
 	no.FastForwardDisable = o.FastForwardDisable
+	no.ServeStaleOnError = o.ServeStaleOnError
 	no.FastForwardTTL = o.FastForwardTTL
 	no.FastForwardTTLMS = o.FastForwardTTLMS
 	no.ForwardedHeaders = o.ForwardedHeaders
@@ -305,6 +348,8 @@ func (o *Options) Clone() *Options {
 	no.TimeseriesRetentionFactor = o.TimeseriesRetentionFactor
 	no.TimeseriesEvictionMethodName = o.TimeseriesEvictionMethodName
 	no.TimeseriesEvictionMethod = o.TimeseriesEvictionMethod
+	no.TimeseriesWarningPolicyName = o.TimeseriesWarningPolicyName
+	no.TimeseriesWarningPolicy = o.TimeseriesWarningPolicy
 	no.TimeseriesTTL = o.TimeseriesTTL
 	no.TimeseriesTTLMS = o.TimeseriesTTLMS
 	no.ValueRetention = o.ValueRetention
@@ -315,6 +360,28 @@ func (o *Options) Clone() *Options {
 		no.HealthCheck = o.HealthCheck.Clone()
 	}
 
+	if o.Retry != nil {
+		no.Retry = o.Retry.Clone()
+		no.RetryPolicy = retry.New(no.Retry)
+	}
+
+	if o.CircuitBreaker != nil {
+		no.CircuitBreaker = o.CircuitBreaker.Clone()
+		no.Breaker = circuitbreaker.New(no.CircuitBreaker)
+	}
+
+	if o.Mirror != nil {
+		no.Mirror = o.Mirror.Clone()
+	}
+
+	if o.FaultInjection != nil {
+		no.FaultInjection = o.FaultInjection.Clone()
+	}
+
+	if o.UpstreamAuth != nil {
+		no.UpstreamAuth = o.UpstreamAuth.Clone()
+	}
+
 	no.Hosts = copiers.CopyStrings(o.Hosts)
 	no.CompressibleTypeList = copiers.CopyStrings(no.CompressibleTypeList)
 
@@ -338,6 +405,16 @@ func (o *Options) Clone() *Options {
 		}
 		no.NegativeCache = m
 	}
+	if o.NegativeCacheRules != nil {
+		no.NegativeCacheRules = make([]*negative.RuleOptions, len(o.NegativeCacheRules))
+		for i, r := range o.NegativeCacheRules {
+			no.NegativeCacheRules[i] = r.Clone()
+		}
+	}
+	if o.NegativeRules != nil {
+		// compiled rules are not modified after Validate, so they can be shared
+		no.NegativeRules = slices.Clone(o.NegativeRules)
+	}
 
 	if o.Transport != nil {
 		no.Transport = o.Transport.Clone()
@@ -429,6 +506,58 @@ func (l Lookup) Validate(ncl negative.Lookups) error {
 			o.NegativeCache = nc
 		}
 
+		if o.NegativeRules, err = negative.CompileRules(o.NegativeCacheRules); err != nil {
+			return NewErrInvalidNegativeCacheRules(k, err)
+		}
+
+		if o.Retry != nil {
+			if err = o.Retry.Validate(); err != nil {
+				return NewErrInvalidRetryOptions(k, err)
+			}
+			o.RetryPolicy = retry.New(o.Retry)
+		}
+
+		if o.CircuitBreaker != nil {
+			if err = o.CircuitBreaker.Validate(); err != nil {
+				return NewErrInvalidCircuitBreakerOptions(k, err)
+			}
+			o.Breaker = circuitbreaker.New(o.CircuitBreaker)
+		}
+
+		if o.Mirror != nil {
+			if err = o.Mirror.Validate(); err != nil {
+				return NewErrInvalidMirrorOptions(k, err)
+			}
+		}
+		for _, p := range o.Paths {
+			if p.Mirror == nil {
+				continue
+			}
+			if err = p.Mirror.Validate(); err != nil {
+				return NewErrInvalidMirrorOptions(k, err)
+			}
+		}
+
+		if o.FaultInjection != nil {
+			if err = o.FaultInjection.Validate(); err != nil {
+				return NewErrInvalidFaultInjectionOptions(k, err)
+			}
+		}
+		for _, p := range o.Paths {
+			if p.FaultInjection == nil {
+				continue
+			}
+			if err = p.FaultInjection.Validate(); err != nil {
+				return NewErrInvalidFaultInjectionOptions(k, err)
+			}
+		}
+
+		if o.UpstreamAuth != nil {
+			if err = o.UpstreamAuth.Validate(); err != nil {
+				return NewErrInvalidUpstreamAuthOptions(k, err)
+			}
+		}
+
 		// enforce MaxTTL
 		if o.TimeseriesTTLMS > o.MaxTTLMS {
 			o.TimeseriesTTLMS = o.MaxTTLMS
@@ -483,6 +612,27 @@ func (l Lookup) ValidateConfigMappings(rules ro.Lookup, caches co.Lookup) error
 				return NewErrInvalidCacheName(o.CacheName, o.Name)
 			}
 		}
+		if err := l.validateMirror(o.Name, o.Mirror); err != nil {
+			return err
+		}
+		for _, p := range o.Paths {
+			if err := l.validateMirror(o.Name, p.Mirror); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateMirror ensures the mirror options reference a defined backend other
+// than the mirrored backend
+func (l Lookup) validateMirror(backendName string, m *mo.Options) error {
+	if m == nil {
+		return nil
+	}
+	if _, ok := l[m.Backend]; !ok || m.Backend == backendName {
+		return NewErrInvalidMirrorOptions(backendName,
+			fmt.Errorf("%w: %s", mo.ErrInvalidBackend, m.Backend))
 	}
 	return nil
 }
@@ -613,6 +763,15 @@ func SetDefaults(
 		}
 	}
 
+	if metadata.IsDefined("backends", name, "timeseries_warning_policy") {
+		no.TimeseriesWarningPolicyName = strings.ToLower(o.TimeseriesWarningPolicyName)
+		p, ok := warningpolicies.Names[no.TimeseriesWarningPolicyName]
+		if !ok {
+			return nil, NewErrInvalidTimeseriesWarningPolicy(no.TimeseriesWarningPolicyName, name)
+		}
+		no.TimeseriesWarningPolicy = p
+	}
+
 	if metadata.IsDefined("backends", name, "timeseries_ttl_ms") {
 		no.TimeseriesTTLMS = o.TimeseriesTTLMS
 	}
@@ -629,6 +788,10 @@ func SetDefaults(
 		no.FastForwardDisable = o.FastForwardDisable
 	}
 
+	if metadata.IsDefined("backends", name, "serve_stale_on_error") {
+		no.ServeStaleOnError = o.ServeStaleOnError
+	}
+
 	if metadata.IsDefined("backends", name, "backfill_tolerance_ms") {
 		no.BackfillToleranceMS = o.BackfillToleranceMS
 	}
@@ -652,10 +815,51 @@ func SetDefaults(
 		no.ALBOptions = opts
 	}
 
+	if metadata.IsDefined("backends", name, "retry") {
+		opts, err := rto.SetDefaults(name, o.Retry, metadata)
+		if err != nil {
+			return nil, err
+		}
+		no.Retry = opts
+	}
+
+	if metadata.IsDefined("backends", name, "circuit_breaker") {
+		opts, err := cbo.SetDefaults(name, o.CircuitBreaker, metadata)
+		if err != nil {
+			return nil, err
+		}
+		no.CircuitBreaker = opts
+	}
+
+	if metadata.IsDefined("backends", name, "mirror") {
+		opts, err := mo.SetDefaults(o.Mirror, metadata, "backends", name, "mirror")
+		if err != nil {
+			return nil, err
+		}
+		no.Mirror = opts
+	}
+
+	if metadata.IsDefined("backends", name, "fault_injection") {
+		opts, err := fo.SetDefaults(o.FaultInjection, metadata,
+			"backends", name, "fault_injection")
+		if err != nil {
+			return nil, err
+		}
+		no.FaultInjection = opts
+	}
+
+	if metadata.IsDefined("backends", name, "upstream_auth") && o.UpstreamAuth != nil {
+		no.UpstreamAuth = o.UpstreamAuth.Clone()
+	}
+
 	if metadata.IsDefined("backends", name, "negative_cache_name") {
 		no.NegativeCacheName = o.NegativeCacheName
 	}
 
+	if metadata.IsDefined("backends", name, "negative_cache_rules") {
+		no.NegativeCacheRules = o.NegativeCacheRules
+	}
+
 	if metadata.IsDefined("backends", name, "tracing_name") {
 		no.TracingConfigName = o.TracingConfigName
 	}
@@ -729,6 +933,9 @@ func (o *Options) CloneYAMLSafe() *Options {
 		// also strip out potentially sensitive headers
 		headers.HideAuthorizationCredentials(co.HealthCheck.Headers)
 	}
+	if co.UpstreamAuth != nil {
+		co.UpstreamAuth = co.UpstreamAuth.CloneYAMLSafe()
+	}
 	return co
 }
 
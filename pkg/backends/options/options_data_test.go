@@ -49,7 +49,9 @@ backends:
     ignore_caching_headers: true
     timeseries_retention_factor: 666
     timeseries_eviction_method: lru
+    timeseries_warning_policy: skip
     fast_forward_disable: true
+    serve_stale_on_error: true
     backfill_tolerance_ms: 301000
     backfill_tolerance_points: 2
     timeout_ms: 37000
@@ -28,9 +28,14 @@ import (
 	ro "github.com/trickstercache/trickster/v2/pkg/backends/rule/options"
 	"github.com/trickstercache/trickster/v2/pkg/cache/negative"
 	co "github.com/trickstercache/trickster/v2/pkg/cache/options"
+	cbo "github.com/trickstercache/trickster/v2/pkg/proxy/circuitbreaker/options"
+	fo "github.com/trickstercache/trickster/v2/pkg/proxy/faults/options"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+	mo "github.com/trickstercache/trickster/v2/pkg/proxy/mirror/options"
 	po "github.com/trickstercache/trickster/v2/pkg/proxy/paths/options"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/request/rewriter"
+	rto "github.com/trickstercache/trickster/v2/pkg/proxy/retry/options"
+	uao "github.com/trickstercache/trickster/v2/pkg/proxy/upstreamauth/options"
 	tlstest "github.com/trickstercache/trickster/v2/pkg/testutil/tls"
 	"github.com/trickstercache/trickster/v2/pkg/util/yamlx"
 
@@ -81,10 +86,172 @@ func TestClone(t *testing.T) {
 	o.HealthCheck = &ho.Options{}
 	o.FastForwardPath = p
 	o.RuleOptions = &ro.Options{}
+	o.NegativeCacheRules = []*negative.RuleOptions{{Status: "5xx", TTLMS: 1000}}
+	o.NegativeRules, _ = negative.CompileRules(o.NegativeCacheRules)
+	o.Retry = rto.New()
+	o.CircuitBreaker = cbo.New()
 	o2 := o.Clone()
 	if o2.CacheName != "test" {
 		t.Error("clone failed")
 	}
+	if len(o2.NegativeRules) != 1 || o2.NegativeCacheRules[0] == o.NegativeCacheRules[0] {
+		t.Error("clone failed")
+	}
+	if o2.Retry == o.Retry || o2.RetryPolicy == nil {
+		t.Error("clone failed")
+	}
+	if o2.CircuitBreaker == o.CircuitBreaker || o2.Breaker == nil {
+		t.Error("clone failed")
+	}
+}
+
+func TestValidateNegativeCacheRules(t *testing.T) {
+	o, err := fromTestYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := Lookup{o.Name: o}
+	o.NegativeCacheName = "test"
+	o.NegativeCacheRules = []*negative.RuleOptions{{Status: "5xx"}}
+	err = l.Validate(testNegativeCaches())
+	var e *ErrInvalidNegativeCacheRules
+	if !errors.As(err, &e) {
+		t.Errorf("expected invalid negative cache rules error, got %v", err)
+	}
+	o.NegativeCacheRules[0].Action = negative.ActionServeLastGood
+	if err = l.Validate(testNegativeCaches()); err != nil {
+		t.Fatal(err)
+	}
+	if len(o.NegativeRules) != 1 || !o.NegativeRules[0].IsServeLastGood() {
+		t.Error("expected compiled serve_last_good rule")
+	}
+}
+
+func TestValidateRetryOptions(t *testing.T) {
+	o, err := fromTestYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := Lookup{o.Name: o}
+	o.NegativeCacheName = "test"
+	o.Retry = rto.New()
+	o.Retry.Jitter = 2
+	err = l.Validate(testNegativeCaches())
+	var e *ErrInvalidRetryOptions
+	if !errors.As(err, &e) {
+		t.Errorf("expected invalid retry options error, got %v", err)
+	}
+	o.Retry.Jitter = 0
+	if err = l.Validate(testNegativeCaches()); err != nil {
+		t.Fatal(err)
+	}
+	if o.RetryPolicy == nil || o.RetryPolicy.Options() != o.Retry {
+		t.Error("expected retry policy")
+	}
+}
+
+func TestValidateCircuitBreakerOptions(t *testing.T) {
+	o, err := fromTestYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := Lookup{o.Name: o}
+	o.NegativeCacheName = "test"
+	o.CircuitBreaker = cbo.New()
+	o.CircuitBreaker.OpenActionName = "invalid"
+	err = l.Validate(testNegativeCaches())
+	var e *ErrInvalidCircuitBreakerOptions
+	if !errors.As(err, &e) {
+		t.Errorf("expected invalid circuit breaker options error, got %v", err)
+	}
+	o.CircuitBreaker.OpenActionName = "serve_stale"
+	if err = l.Validate(testNegativeCaches()); err != nil {
+		t.Fatal(err)
+	}
+	if o.Breaker == nil || o.Breaker.Options().OpenAction != cbo.OpenActionServeStale {
+		t.Error("expected circuit breaker")
+	}
+}
+
+func TestValidateMirrorOptions(t *testing.T) {
+	o, err := fromTestYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := Lookup{o.Name: o}
+	o.NegativeCacheName = "test"
+	o.Mirror = mo.New()
+	err = l.Validate(testNegativeCaches())
+	var e *ErrInvalidMirrorOptions
+	if !errors.As(err, &e) {
+		t.Errorf("expected invalid mirror options error, got %v", err)
+	}
+
+	o.Mirror.Backend = o.Name
+	if err = l.Validate(testNegativeCaches()); err != nil {
+		t.Fatal(err)
+	}
+	err = l.ValidateConfigMappings(ro.Lookup{}, co.Lookup{o.CacheName: nil})
+	if !errors.As(err, &e) {
+		t.Errorf("expected invalid mirror options error for self-mirroring, got %v", err)
+	}
+
+	o.Mirror.Backend = "shadow"
+	err = l.ValidateConfigMappings(ro.Lookup{}, co.Lookup{o.CacheName: nil})
+	if !errors.As(err, &e) {
+		t.Errorf("expected invalid mirror options error for unknown backend, got %v", err)
+	}
+
+	s := New()
+	s.Name = "shadow"
+	s.Provider = "prometheus"
+	s.CacheName = o.CacheName
+	l["shadow"] = s
+	if err = l.ValidateConfigMappings(ro.Lookup{}, co.Lookup{o.CacheName: nil}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestValidateFaultInjectionOptions(t *testing.T) {
+	o, err := fromTestYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := Lookup{o.Name: o}
+	o.NegativeCacheName = "test"
+	o.FaultInjection = fo.New()
+	err = l.Validate(testNegativeCaches())
+	var e *ErrInvalidFaultInjectionOptions
+	if !errors.As(err, &e) {
+		t.Errorf("expected invalid fault injection options error, got %v", err)
+	}
+	o.FaultInjection.Abort = true
+	if err = l.Validate(testNegativeCaches()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestValidateUpstreamAuthOptions(t *testing.T) {
+	o, err := fromTestYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := Lookup{o.Name: o}
+	o.NegativeCacheName = "test"
+	o.UpstreamAuth = &uao.Options{Mode: "invalid"}
+	err = l.Validate(testNegativeCaches())
+	var e *ErrInvalidUpstreamAuthOptions
+	if !errors.As(err, &e) {
+		t.Errorf("expected invalid upstream auth options error, got %v", err)
+	}
+	o.UpstreamAuth = &uao.Options{Mode: uao.ModeSigV4,
+		SigV4: &uao.SigV4Options{Region: "us-east-1"}}
+	if err = l.Validate(testNegativeCaches()); err != nil {
+		t.Error(err)
+	}
+	if o.UpstreamAuth.SigV4.Service != uao.DefaultSigV4Service {
+		t.Errorf("expected default service got %s", o.UpstreamAuth.SigV4.Service)
+	}
 }
 
 func TestValidateBackendName(t *testing.T) {
@@ -347,9 +514,11 @@ func TestSetDefaults(t *testing.T) {
 
 	backends := Lookup{o.Name: o}
 
-	_, err = SetDefaults("test", o, o.md, nil, backends, map[string]interface{}{})
+	no, err := SetDefaults("test", o, o.md, nil, backends, map[string]interface{}{})
 	if err != nil {
 		t.Error(err)
+	} else if !no.ServeStaleOnError || !no.Clone().ServeStaleOnError {
+		t.Error("expected serve_stale_on_error to be true")
 	}
 
 	_, err = SetDefaults("test", o, nil, nil, backends, map[string]interface{}{})
@@ -367,6 +536,14 @@ func TestSetDefaults(t *testing.T) {
 		t.Error(err)
 	}
 
+	o.TimeseriesWarningPolicyName = "invalid"
+	_, err = SetDefaults("test", o, o.md, nil, backends, map[string]interface{}{})
+	var twpErr *ErrInvalidTimeseriesWarningPolicy
+	if !errors.As(err, &twpErr) {
+		t.Error("expected error for invalid timeseries warning policy, got", err)
+	}
+	o.TimeseriesWarningPolicyName = "skip"
+
 	o.Paths["series"].ReqRewriterName = "invalid"
 	_, err = SetDefaults("test", o, o.md, nil, backends, map[string]interface{}{})
 	if err == nil {
@@ -469,6 +646,16 @@ func TestCloneYAMLSafe(t *testing.T) {
 	}
 
 	p.RequestHeaders = map[string]string{headers.NameAuthorization: "trickster"}
+
+	o.UpstreamAuth = &uao.Options{Mode: uao.ModeBearer,
+		Bearer: &uao.BearerOptions{Token: "trickster"}}
+	co = o.CloneYAMLSafe()
+	if co.UpstreamAuth.Bearer.Token != "*****" {
+		t.Error("expected *****")
+	}
+	if o.UpstreamAuth.Bearer.Token != "trickster" {
+		t.Error("expected trickster")
+	}
 }
 
 func TestToYAML(t *testing.T) {
@@ -17,10 +17,10 @@
 package model
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
-	"strings"
 
 	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
 )
@@ -59,7 +59,10 @@ func (e *Envelope) StartMarshal(w io.Writer, httpStatus int) {
 	}
 
 	if len(e.Warnings) > 0 {
-		w.Write([]byte(fmt.Sprintf(`,"warnings":["%s"]`, strings.Join(e.Warnings, `","`))))
+		// warnings are relayed from upstream, so they are escaped rather than trusted
+		b, _ := json.Marshal(e.Warnings)
+		w.Write([]byte(`,"warnings":`))
+		w.Write(b)
 	}
 }
 
@@ -18,6 +18,7 @@ package model
 
 import (
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/trickstercache/trickster/v2/pkg/timeseries"
@@ -71,10 +72,14 @@ func TestStartMarshal(t *testing.T) {
 		Status:    "test status",
 		Error:     "test error",
 		ErrorType: "test type",
-		Warnings:  []string{"test_warning1", "test_warning2"},
+		Warnings:  []string{"test_warning1", `store "b" unavailable`},
 	}
 	e.StartMarshal(w, 400)
 	if w.Code != 400 {
 		t.Errorf("expected %d got %d", 400, w.Code)
 	}
+	const expected = `,"warnings":["test_warning1","store \"b\" unavailable"]`
+	if !strings.HasSuffix(w.Body.String(), expected) {
+		t.Errorf("expected suffix %s got %s", expected, w.Body.String())
+	}
 }
@@ -18,11 +18,16 @@
 package badger
 
 import (
+	"errors"
+	"sync"
 	"time"
 
 	"github.com/trickstercache/trickster/v2/pkg/cache"
+	"github.com/trickstercache/trickster/v2/pkg/cache/index"
+	io "github.com/trickstercache/trickster/v2/pkg/cache/index/options"
 	"github.com/trickstercache/trickster/v2/pkg/cache/metrics"
 	"github.com/trickstercache/trickster/v2/pkg/cache/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache/scrub"
 	"github.com/trickstercache/trickster/v2/pkg/cache/status"
 	"github.com/trickstercache/trickster/v2/pkg/locks"
 	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
@@ -30,14 +35,21 @@ import (
 	"github.com/dgraph-io/badger"
 )
 
+var errClosed = errors.New("badger cache is closed")
+
 // Cache describes a Badger Cache
 type Cache struct {
 	Name   string
 	Config *options.Options
+	Index  *index.Index
 	Logger interface{}
 	locker locks.NamedLocker
 
 	dbh *badger.DB
+	// mtx guards closed, so the Index's flusher and reaper don't write to a closed database
+	mtx       sync.RWMutex
+	closed    bool
+	scrubStop chan struct{}
 }
 
 // Locker returns the cache's locker
@@ -55,7 +67,13 @@ func (c *Cache) Configuration() *options.Options {
 	return c.Config
 }
 
-// Connect opens the configured Badger key-value store
+// CacheIndex returns the Index that tracks the cache's objects
+func (c *Cache) CacheIndex() *index.Index {
+	return c.Index
+}
+
+// Connect opens the configured Badger key-value store and loads its Index, which
+// enforces the cache's size limits, since Badger manages Object Expiration internally
 func (c *Cache) Connect() error {
 	tl.Info(c.Logger, "badger cache setup", tl.Pairs{"cacheDir": c.Config.Badger.Directory})
 
@@ -68,21 +86,68 @@ func (c *Cache) Connect() error {
 		return err
 	}
 
+	o := c.Config.Index
+	if o == nil {
+		o = io.New()
+	}
+	indexData, _, _ := c.retrieve(index.IndexKey, false)
+	c.Index = index.NewIndex(c.Name, c.Config.Provider, indexData,
+		o, c.BulkRemove, c.storeNoIndex, c.Logger)
+	if c.Index.Count() == 0 {
+		if err = c.seedIndex(); err != nil {
+			tl.Warn(c.Logger, "badger cache index seeding failed",
+				tl.Pairs{"cacheName": c.Name, "detail": err.Error()})
+		}
+	}
+	if o.ScrubIntervalMS > 0 {
+		c.scrubStop = make(chan struct{})
+		go scrub.New(c.Name, c.Config.Provider, c, c.Index, c.Logger).
+			Run(time.Duration(o.ScrubIntervalMS)*time.Millisecond, c.scrubStop)
+	}
 	return nil
 }
 
 // Store places the the data into the Badger Cache using the provided Key and TTL
 func (c *Cache) Store(cacheKey string, data []byte, ttl time.Duration) error {
+	return c.store(cacheKey, data, ttl, true)
+}
+
+func (c *Cache) storeNoIndex(cacheKey string, data []byte) {
+	err := c.store(cacheKey, data, 31536000*time.Second, false)
+	if err != nil {
+		tl.Error(c.Logger, "cache failed to write non-indexed object",
+			tl.Pairs{
+				"cacheName": c.Name, "cacheProvider": "badger",
+				"cacheKey": cacheKey, "objectSize": len(data),
+			})
+	}
+}
+
+func (c *Cache) store(cacheKey string, data []byte, ttl time.Duration, updateIndex bool) error {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	if c.closed {
+		return errClosed
+	}
 	metrics.ObserveCacheOperation(c.Name, c.Config.Provider, "set", "none", float64(len(data)))
-	tl.Debug(c.Logger, "badger cache store", tl.Pairs{"key": cacheKey, "ttl": ttl})
-	return c.dbh.Update(func(txn *badger.Txn) error {
-		return txn.SetEntry(&badger.Entry{Key: []byte(cacheKey), Value: data, ExpiresAt: uint64(time.Now().Add(ttl).Unix())})
+	tl.Debug(c.Logger, "badger cache store", tl.Pairs{"key": cacheKey, "ttl": ttl, "indexed": updateIndex})
+	exp := time.Now().Add(ttl)
+	err := c.dbh.Update(func(txn *badger.Txn) error {
+		return txn.SetEntry(&badger.Entry{Key: []byte(cacheKey), Value: data, ExpiresAt: uint64(exp.Unix())})
 	})
+	if err == nil && updateIndex {
+		c.Index.UpdateObject(&index.Object{Key: cacheKey, Value: data, Expiration: exp})
+	}
+	return err
 }
 
 // Retrieve gets data from the Badger Cache using the provided Key
 // because Badger manages Object Expiration internally, allowExpired is not used.
 func (c *Cache) Retrieve(cacheKey string, allowExpired bool) ([]byte, status.LookupStatus, error) {
+	return c.retrieve(cacheKey, true)
+}
+
+func (c *Cache) retrieve(cacheKey string, atime bool) ([]byte, status.LookupStatus, error) {
 	var data []byte
 	err := c.dbh.View(func(txn *badger.Txn) error {
 		item, err := txn.Get([]byte(cacheKey))
@@ -95,6 +160,9 @@ func (c *Cache) Retrieve(cacheKey string, allowExpired bool) ([]byte, status.Loo
 
 	if err == nil {
 		tl.Debug(c.Logger, "badger cache retrieve", tl.Pairs{"key": cacheKey})
+		if atime && c.Index != nil {
+			go c.Index.UpdateObjectAccessTime(cacheKey)
+		}
 		metrics.ObserveCacheOperation(c.Name, c.Config.Provider, "get", "hit", float64(len(data)))
 		return data, status.LookupStatusHit, nil
 	}
@@ -114,9 +182,17 @@ func (c *Cache) Retrieve(cacheKey string, allowExpired bool) ([]byte, status.Loo
 // Remove removes an object in cache, if present
 func (c *Cache) Remove(cacheKey string) {
 	tl.Debug(c.Logger, "badger cache remove", tl.Pairs{"key": cacheKey})
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	if c.closed {
+		return
+	}
 	c.dbh.Update(func(txn *badger.Txn) error {
 		return txn.Delete([]byte(cacheKey))
 	})
+	if c.Index != nil {
+		go c.Index.RemoveObject(cacheKey)
+	}
 	metrics.ObserveCacheDel(c.Name, c.Config.Provider, 0)
 }
 
@@ -124,6 +200,11 @@ func (c *Cache) Remove(cacheKey string) {
 func (c *Cache) BulkRemove(cacheKeys []string) {
 	tl.Debug(c.Logger, "badger cache bulk remove", tl.Pairs{})
 
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	if c.closed {
+		return
+	}
 	c.dbh.Update(func(txn *badger.Txn) error {
 		for _, key := range cacheKeys {
 			if err := txn.Delete([]byte(key)); err != nil {
@@ -137,11 +218,105 @@ func (c *Cache) BulkRemove(cacheKeys []string) {
 
 // Close closes the Badger Cache
 func (c *Cache) Close() error {
+	if c.scrubStop != nil {
+		close(c.scrubStop)
+		c.scrubStop = nil
+	}
+	if c.Index != nil {
+		c.Index.Close()
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.closed = true
 	return c.dbh.Close()
 }
 
+// walkBatchSize is the number of objects read by Walk while holding the lock
+const walkBatchSize = 256
+
+type walkedObject struct {
+	key   string
+	value []byte
+	exp   time.Time
+	err   error
+}
+
+// Walk calls visit with the key and value of each unexpired object in the cache.
+// Objects are read in batches, and the lock is released between batches and while
+// visiting, so that a long walk doesn't block Close
+func (c *Cache) Walk(visit func(key string, value []byte, err error)) error {
+	return c.walk(func(o walkedObject) {
+		visit(o.key, o.value, o.err)
+	})
+}
+
+// seedIndex adds the objects in the store to an empty Index, such as when no Index
+// was persisted by the version of Trickster that wrote the cache, so that they are
+// subject to the cache's size limits and are not reclaimed by the scrubber as orphans
+func (c *Cache) seedIndex() error {
+	return c.walk(func(o walkedObject) {
+		if o.err != nil || o.key == index.IndexKey {
+			return
+		}
+		c.Index.UpdateObject(&index.Object{Key: o.key, Value: o.value, Expiration: o.exp})
+	})
+}
+
+func (c *Cache) walk(visit func(walkedObject)) error {
+	var start []byte
+	for {
+		batch, next, err := c.walkBatch(start)
+		if err != nil {
+			return err
+		}
+		for _, o := range batch {
+			visit(o)
+		}
+		if next == nil {
+			return nil
+		}
+		start = next
+	}
+}
+
+// walkBatch reads up to walkBatchSize objects, beginning with the start key, and
+// returns them along with the key at which the next batch begins, or nil if done
+func (c *Cache) walkBatch(start []byte) ([]walkedObject, []byte, error) {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	if c.closed {
+		return nil, nil, errClosed
+	}
+	batch := make([]walkedObject, 0, walkBatchSize)
+	var next []byte
+	err := c.dbh.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Seek(start); it.Valid(); it.Next() {
+			item := it.Item()
+			if len(batch) == walkBatchSize {
+				next = item.KeyCopy(nil)
+				return nil
+			}
+			v, err := item.ValueCopy(nil)
+			o := walkedObject{key: string(item.Key()), value: v, err: err}
+			if e := item.ExpiresAt(); e > 0 {
+				o.exp = time.Unix(int64(e), 0)
+			}
+			batch = append(batch, o)
+		}
+		return nil
+	})
+	return batch, next, err
+}
+
 // SetTTL updates the TTL for the provided cache object
 func (c *Cache) SetTTL(cacheKey string, ttl time.Duration) {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	if c.closed {
+		return
+	}
 	var data []byte
 	err := c.dbh.Update(func(txn *badger.Txn) error {
 		item, err := txn.Get([]byte(cacheKey))
@@ -153,6 +328,9 @@ func (c *Cache) SetTTL(cacheKey string, ttl time.Duration) {
 	})
 	tl.Debug(c.Logger, "badger cache update-ttl", tl.Pairs{"key": cacheKey, "ttl": ttl, "success": err == nil})
 	if err == nil {
+		if c.Index != nil {
+			go c.Index.UpdateObjectTTL(cacheKey, ttl)
+		}
 		metrics.ObserveCacheOperation(c.Name, c.Config.Provider, "update-ttl", "none", 0)
 	}
 }
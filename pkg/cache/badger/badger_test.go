@@ -17,14 +17,19 @@
 package badger
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
 	bo "github.com/trickstercache/trickster/v2/pkg/cache/badger/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache/envelope"
 	co "github.com/trickstercache/trickster/v2/pkg/cache/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache/scrub"
 	"github.com/trickstercache/trickster/v2/pkg/cache/status"
 	"github.com/trickstercache/trickster/v2/pkg/locks"
 	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
+
+	"github.com/dgraph-io/badger"
 )
 
 const (
@@ -251,6 +256,119 @@ func TestBadgerCache_Retrieve(t *testing.T) {
 	}
 }
 
+func TestBadgerCache_Scrub(t *testing.T) {
+	cacheConfig := newCacheConfig(t.TempDir() + "/test.db")
+	bc := Cache{Config: cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+	if err := bc.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer bc.Close()
+	sealed, _ := envelope.Default.Seal([]byte("data"), true)
+	if err := bc.Store(cacheKey, sealed, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.Store(cacheKey+"-truncated", sealed[:len(sealed)-1], time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	bc.store(cacheKey+"-orphan", sealed, time.Minute, false)
+
+	sc := scrub.New(bc.Name, provider, &bc, bc.Index, nil)
+	r, err := sc.Scrub()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Scanned != 3 || r.Corrupt != 1 || r.Orphaned != 0 {
+		t.Errorf("unexpected result %+v", r)
+	}
+	r, _ = sc.Scrub()
+	if r.Scanned != 2 || r.Orphaned != 1 {
+		t.Errorf("unexpected result %+v", r)
+	}
+	for _, k := range []string{cacheKey + "-truncated", cacheKey + "-orphan"} {
+		if _, ls, _ := bc.Retrieve(k, false); ls != status.LookupStatusKeyMiss {
+			t.Errorf("%s: expected %s got %s", k, status.LookupStatusKeyMiss, ls)
+		}
+	}
+	if _, ls, _ := bc.Retrieve(cacheKey, false); ls != status.LookupStatusHit {
+		t.Errorf("expected %s got %s", status.LookupStatusHit, ls)
+	}
+}
+
+func TestBadgerCache_Walk(t *testing.T) {
+	cacheConfig := newCacheConfig(t.TempDir() + "/test.db")
+	bc := Cache{Config: cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+	if err := bc.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	// more objects than fit in a single batch
+	const n = walkBatchSize*2 + 1
+	for i := range n {
+		bc.store(fmt.Sprintf("%s-%d", cacheKey, i), []byte("data"), time.Minute, false)
+	}
+	seen := make(map[string]struct{}, n)
+	err := bc.Walk(func(key string, _ []byte, err error) {
+		if err != nil {
+			t.Error(err)
+		}
+		seen[key] = struct{}{}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != n {
+		t.Errorf("expected %d objects got %d", n, len(seen))
+	}
+	bc.Close()
+	if err = bc.Walk(func(string, []byte, error) {}); err != errClosed {
+		t.Errorf("expected %v got %v", errClosed, err)
+	}
+}
+
+func TestBadgerCache_SeedIndex(t *testing.T) {
+	cacheConfig := newCacheConfig(t.TempDir() + "/test.db")
+	// objects written by a version that kept no index
+	dbh, err := badger.Open(badger.DefaultOptions(cacheConfig.Badger.Directory))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, _ := envelope.Default.Seal([]byte("data"), true)
+	exp := time.Now().Add(time.Hour).Truncate(time.Second)
+	err = dbh.Update(func(txn *badger.Txn) error {
+		if err := txn.SetEntry(&badger.Entry{Key: []byte(cacheKey), Value: sealed,
+			ExpiresAt: uint64(exp.Unix())}); err != nil {
+			return err
+		}
+		return txn.Set([]byte(cacheKey+"-2"), sealed)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbh.Close()
+
+	bc := Cache{Config: cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+	if err = bc.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer bc.Close()
+	if bc.Index.Count() != 2 || bc.Index.Size() != int64(len(sealed)*2) {
+		t.Errorf("unexpected index size %d objects %d bytes",
+			bc.Index.Count(), bc.Index.Size())
+	}
+	if e := bc.Index.GetExpiration(cacheKey); !e.Equal(exp) {
+		t.Errorf("expected %v got %v", exp, e)
+	}
+	// the seeded objects are not reclaimed as orphans
+	sc := scrub.New(bc.Name, provider, &bc, bc.Index, nil)
+	for range 2 {
+		if r, _ := sc.Scrub(); r.Orphaned != 0 {
+			t.Errorf("unexpected result %+v", r)
+		}
+	}
+	if _, ls, _ := bc.Retrieve(cacheKey+"-2", false); ls != status.LookupStatusHit {
+		t.Errorf("expected %s got %s", status.LookupStatusHit, ls)
+	}
+}
+
 func TestBadgerCache_Close(t *testing.T) {
 	testDbPath := t.TempDir() + "/test.db"
 	cacheConfig := &co.Options{Provider: provider, Badger: &bo.Options{Directory: testDbPath, ValueDirectory: testDbPath}}
@@ -275,3 +393,32 @@ func TestLocker(t *testing.T) {
 		t.Errorf("error setting locker")
 	}
 }
+
+func TestBadgerCache_Index(t *testing.T) {
+	testDbPath := t.TempDir() + "/test.db"
+	cacheConfig := newCacheConfig(testDbPath)
+	bc := Cache{Config: cacheConfig, Logger: tl.ConsoleLogger("error")}
+
+	if err := bc.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	// it should index stored objects, so the index can enforce the max cache size
+	if err := bc.Store(cacheKey, []byte("data"), time.Minute); err != nil {
+		t.Error(err)
+	}
+	if bc.Index.Count() != 1 || bc.Index.Size() != 4 {
+		t.Errorf("unexpected index size %d objects %d bytes",
+			bc.Index.Count(), bc.Index.Size())
+	}
+
+	// it should ignore index writes once closed
+	bc.Close()
+	bc.storeNoIndex("cache.index", bc.Index.ToBytes())
+	if err := bc.Store(cacheKey, []byte("data"), time.Minute); err != errClosed {
+		t.Errorf("expected %v got %v", errClosed, err)
+	}
+	// removals and ttl updates are ignored once closed, rather than panicking
+	bc.SetTTL(cacheKey, time.Hour)
+	bc.Remove(cacheKey)
+}
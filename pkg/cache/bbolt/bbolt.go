@@ -18,6 +18,7 @@
 package bbolt
 
 import (
+	"bytes"
 	"fmt"
 	"sync"
 	"time"
@@ -26,6 +27,7 @@ import (
 	"github.com/trickstercache/trickster/v2/pkg/cache/index"
 	"github.com/trickstercache/trickster/v2/pkg/cache/metrics"
 	"github.com/trickstercache/trickster/v2/pkg/cache/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache/scrub"
 	"github.com/trickstercache/trickster/v2/pkg/cache/status"
 	"github.com/trickstercache/trickster/v2/pkg/locks"
 	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
@@ -41,6 +43,7 @@ type Cache struct {
 	Index      *index.Index
 	locker     locks.NamedLocker
 	lockPrefix string
+	scrubStop  chan struct{}
 
 	dbh *bbolt.DB
 }
@@ -78,6 +81,11 @@ func (c *Cache) Configuration() *options.Options {
 	return c.Config
 }
 
+// CacheIndex returns the Index that tracks the cache's objects
+func (c *Cache) CacheIndex() *index.Index {
+	return c.Index
+}
+
 // Connect instantiates the Cache mutex map and starts the Expired Entry Reaper goroutine
 func (c *Cache) Connect() error {
 	tl.Info(c.Logger, "bbolt cache setup", tl.Pairs{"name": c.Name, "cacheFile": c.Config.BBolt.Filename})
@@ -105,6 +113,11 @@ func (c *Cache) Connect() error {
 	indexData, _, _ := c.retrieve(index.IndexKey, false, false)
 	c.Index = index.NewIndex(c.Name, c.Config.Provider, indexData,
 		c.Config.Index, c.BulkRemove, c.storeNoIndex, c.Logger)
+	if c.Config.Index != nil && c.Config.Index.ScrubIntervalMS > 0 {
+		c.scrubStop = make(chan struct{})
+		go scrub.New(c.Name, c.Config.Provider, c, c.Index, c.Logger).
+			Run(time.Duration(c.Config.Index.ScrubIntervalMS)*time.Millisecond, c.scrubStop)
+	}
 	return nil
 }
 
@@ -182,6 +195,10 @@ func (c *Cache) retrieve(cacheKey string, allowExpired bool,
 
 	o, err := index.ObjectFromBytes(data)
 	if err != nil {
+		// the value is corrupt, so it's removed to be replaced on the next write,
+		// rather than failing every read
+		metrics.ObserveCacheCorruption(c.Name, c.Config.Provider, "read")
+		c.RemoveIfUnchanged(cacheKey, nil)
 		_, err = metrics.CacheError(cacheKey, c.Name, c.Config.Provider,
 			"value for key [%s] could not be deserialized from cache")
 		return nil, status.LookupStatusError, err
@@ -239,6 +256,39 @@ func (c *Cache) remove(cacheKey string, isBulk bool) error {
 	return nil
 }
 
+// RemoveIfUnchanged removes the object only if its stored value still matches value,
+// which is nil for an object that could not be decoded, and returns true if it was
+// removed. The comparison and removal are made in a single transaction, so an object
+// rewritten after being found to be corrupt is not removed along with the corrupt version
+func (c *Cache) RemoveIfUnchanged(cacheKey string, value []byte) bool {
+	nl, _ := c.locker.Acquire(c.lockPrefix + cacheKey)
+	var removed bool
+	err := c.dbh.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(c.Config.BBolt.Bucket))
+		data := b.Get([]byte(cacheKey))
+		if data == nil {
+			return nil
+		}
+		o, err := index.ObjectFromBytes(data)
+		if unchanged := (err != nil && value == nil) ||
+			(err == nil && value != nil && bytes.Equal(o.Value, value)); !unchanged {
+			return nil
+		}
+		removed = true
+		return b.Delete([]byte(cacheKey))
+	})
+	nl.Release()
+	if err != nil || !removed {
+		return false
+	}
+	if c.Index != nil {
+		go c.Index.RemoveObject(cacheKey)
+	}
+	metrics.ObserveCacheDel(c.Name, c.Config.Provider, 0)
+	tl.Debug(c.Logger, "bbolt cache key delete", tl.Pairs{"key": cacheKey})
+	return true
+}
+
 // BulkRemove removes a list of objects from the cache
 func (c *Cache) BulkRemove(cacheKeys []string) {
 	wg := &sync.WaitGroup{}
@@ -254,6 +304,10 @@ func (c *Cache) BulkRemove(cacheKeys []string) {
 
 // Close closes the Cache
 func (c *Cache) Close() error {
+	if c.scrubStop != nil {
+		close(c.scrubStop)
+		c.scrubStop = nil
+	}
 	if c.Index != nil {
 		c.Index.Close()
 	}
@@ -262,3 +316,62 @@ func (c *Cache) Close() error {
 	}
 	return nil
 }
+
+// walkBatchSize is the number of objects read by Walk in a single transaction
+const walkBatchSize = 256
+
+type walkedObject struct {
+	key   string
+	value []byte
+	err   error
+}
+
+// Walk calls visit with the key and value of each object in the cache's bucket.
+// Objects are read in batches, each in its own read transaction, and no transaction
+// is open while visiting, so that a long walk doesn't hold up writes that grow the db
+func (c *Cache) Walk(visit func(key string, value []byte, err error)) error {
+	var start []byte
+	for {
+		batch, next, err := c.walkBatch(start)
+		if err != nil {
+			return err
+		}
+		for _, o := range batch {
+			visit(o.key, o.value, o.err)
+		}
+		if next == nil {
+			return nil
+		}
+		start = next
+	}
+}
+
+// walkBatch reads up to walkBatchSize objects, beginning with the start key, and
+// returns them along with the key at which the next batch begins, or nil if done
+func (c *Cache) walkBatch(start []byte) ([]walkedObject, []byte, error) {
+	batch := make([]walkedObject, 0, walkBatchSize)
+	var next []byte
+	err := c.dbh.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(c.Config.BBolt.Bucket))
+		if b == nil {
+			return nil
+		}
+		cur := b.Cursor()
+		for k, v := cur.Seek(start); k != nil; k, v = cur.Next() {
+			if len(batch) == walkBatchSize {
+				// keys are only valid for the life of the transaction
+				next = bytes.Clone(k)
+				return nil
+			}
+			// the decoded value is a copy, so it remains valid after the transaction
+			o, err := index.ObjectFromBytes(v)
+			if err != nil {
+				batch = append(batch, walkedObject{key: string(k), err: err})
+				continue
+			}
+			batch = append(batch, walkedObject{key: string(k), value: o.Value})
+		}
+		return nil
+	})
+	return batch, next, err
+}
@@ -17,6 +17,7 @@
 package bbolt
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
 	"testing"
@@ -24,8 +25,10 @@ import (
 
 	"github.com/trickstercache/trickster/v2/pkg/cache"
 	bo "github.com/trickstercache/trickster/v2/pkg/cache/bbolt/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache/index"
 	io "github.com/trickstercache/trickster/v2/pkg/cache/index/options"
 	co "github.com/trickstercache/trickster/v2/pkg/cache/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache/scrub"
 	"github.com/trickstercache/trickster/v2/pkg/cache/status"
 	"github.com/trickstercache/trickster/v2/pkg/locks"
 	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
@@ -531,6 +534,118 @@ func TestBboltCache_Retrieve(t *testing.T) {
 	if ls != status.LookupStatusError {
 		t.Errorf("expected %s got %s", status.LookupStatusError, ls)
 	}
+	// the corrupt value should have been removed
+	_, ls, _ = bc.Retrieve(cacheKey+"-invalid", false)
+	if ls != status.LookupStatusKeyMiss {
+		t.Errorf("expected %s got %s", status.LookupStatusKeyMiss, ls)
+	}
+}
+
+func TestBboltCache_Scrub(t *testing.T) {
+	cacheConfig := newCacheConfig(t.TempDir() + "/test.db")
+	bc := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+	if err := bc.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer bc.Close()
+	if err := bc.Store(cacheKey, []byte("data"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	writeToBBolt(bc.dbh, cacheConfig.BBolt.Bucket, cacheKey+"-invalid", []byte("junk"))
+	bc.store(cacheKey+"-orphan", []byte("data"), time.Minute, false)
+
+	sc := scrub.New(bc.Name, cacheProvider, &bc, bc.Index, nil)
+	r, err := sc.Scrub()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Scanned != 3 || r.Corrupt != 1 || r.Orphaned != 0 {
+		t.Errorf("unexpected result %+v", r)
+	}
+	r, _ = sc.Scrub()
+	if r.Scanned != 2 || r.Orphaned != 1 {
+		t.Errorf("unexpected result %+v", r)
+	}
+	if _, ls, _ := bc.Retrieve(cacheKey+"-orphan", false); ls != status.LookupStatusKeyMiss {
+		t.Errorf("expected %s got %s", status.LookupStatusKeyMiss, ls)
+	}
+	if _, ls, _ := bc.Retrieve(cacheKey, false); ls != status.LookupStatusHit {
+		t.Errorf("expected %s got %s", status.LookupStatusHit, ls)
+	}
+}
+
+func TestBboltCache_RemoveIfUnchanged(t *testing.T) {
+	cacheConfig := newCacheConfig(t.TempDir() + "/test.db")
+	bc := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+	if err := bc.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer bc.Close()
+
+	// a corrupt value is removed
+	writeToBBolt(bc.dbh, cacheConfig.BBolt.Bucket, cacheKey, []byte("junk"))
+	if !bc.RemoveIfUnchanged(cacheKey, nil) {
+		t.Error("expected corrupt value to be removed")
+	}
+	if bc.RemoveIfUnchanged(cacheKey, nil) {
+		t.Error("expected missing value not to be removed")
+	}
+
+	// a value rewritten since it was found to be corrupt, or that holds a
+	// different value, is kept
+	if err := bc.Store(cacheKey, []byte("data"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if bc.RemoveIfUnchanged(cacheKey, nil) || bc.RemoveIfUnchanged(cacheKey, []byte("other")) {
+		t.Error("expected rewritten value to be kept")
+	}
+	if _, ls, _ := bc.Retrieve(cacheKey, false); ls != status.LookupStatusHit {
+		t.Errorf("expected %s got %s", status.LookupStatusHit, ls)
+	}
+
+	// an unchanged value is removed
+	if !bc.RemoveIfUnchanged(cacheKey, []byte("data")) {
+		t.Error("expected unchanged value to be removed")
+	}
+	if _, ls, _ := bc.Retrieve(cacheKey, false); ls != status.LookupStatusKeyMiss {
+		t.Errorf("expected %s got %s", status.LookupStatusKeyMiss, ls)
+	}
+}
+
+func TestBboltCache_Walk(t *testing.T) {
+	cacheConfig := newCacheConfig(t.TempDir() + "/test.db")
+	bc := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+	if err := bc.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer bc.Close()
+	// more objects than fit in a single batch
+	const n = walkBatchSize*2 + 1
+	for i := range n {
+		bc.store(fmt.Sprintf("%s-%d", cacheKey, i), []byte("data"), time.Minute, false)
+	}
+	writeToBBolt(bc.dbh, cacheConfig.BBolt.Bucket, cacheKey+"-invalid", []byte("junk"))
+	seen := make(map[string]struct{}, n)
+	var corrupt int
+	err := bc.Walk(func(key string, value []byte, err error) {
+		if err != nil {
+			corrupt++
+			return
+		}
+		if key == index.IndexKey {
+			return
+		}
+		if string(value) != "data" {
+			t.Errorf("expected %s got %s", "data", value)
+		}
+		seen[key] = struct{}{}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != n || corrupt != 1 {
+		t.Errorf("expected %d objects and 1 corrupt got %d and %d", n, len(seen), corrupt)
+	}
 }
 
 func BenchmarkCache_Retrieve(b *testing.B) {
@@ -67,3 +67,24 @@ type MemoryCache interface {
 type ReferenceObject interface {
 	Size() int
 }
+
+// ReferenceCodec marshals and unmarshals ReferenceObjects, so they can be held where
+// only serialized objects are stored, such as memory cache snapshots and the L2 of a
+// tiered cache
+type ReferenceCodec interface {
+	MarshalReference(ReferenceObject) ([]byte, error)
+	UnmarshalReference([]byte) (ReferenceObject, error)
+}
+
+var referenceCodec ReferenceCodec
+
+// RegisterReferenceCodec sets the codec used to serialize ReferenceObjects. It is not
+// safe to call concurrently with cache operations, and is intended to be called from init
+func RegisterReferenceCodec(rc ReferenceCodec) {
+	referenceCodec = rc
+}
+
+// GetReferenceCodec returns the registered ReferenceCodec, or nil if none is registered
+func GetReferenceCodec() ReferenceCodec {
+	return referenceCodec
+}
@@ -0,0 +1,88 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package options provides options for the encryption of cached documents at rest
+package options
+
+import (
+	"errors"
+	"maps"
+)
+
+// Options is a collection of at-rest encryption configurations
+type Options struct {
+	// KeyFiles maps key IDs to the paths of files containing 16, 24 or 32-byte AES
+	// keys, as raw bytes or hex- or base64-encoded text. Keys that are no longer
+	// active should remain listed until objects encrypted with them have expired
+	KeyFiles map[string]string `json:"key_files,omitempty"`
+	// ActiveKeyID is the ID of the key in KeyFiles used to encrypt new objects
+	ActiveKeyID string `json:"active_key_id,omitempty"`
+}
+
+var (
+	// ErrNoActiveKey is returned when key files are provided without an active key ID
+	ErrNoActiveKey = errors.New("encryption active_key_id is required")
+	// ErrUnknownActiveKey is returned when the active key ID is not in the key files
+	ErrUnknownActiveKey = errors.New("encryption active_key_id is not in key_files")
+	// ErrKeyIDTooLong is returned when a key ID is longer than 255 bytes
+	ErrKeyIDTooLong = errors.New("encryption key IDs must be 255 bytes or less")
+)
+
+// New returns a new Options with default values
+func New() *Options {
+	return &Options{}
+}
+
+// Clone returns an exact copy of the subject Options
+func (o *Options) Clone() *Options {
+	c := &Options{ActiveKeyID: o.ActiveKeyID}
+	if o.KeyFiles != nil {
+		c.KeyFiles = maps.Clone(o.KeyFiles)
+	}
+	return c
+}
+
+// Equal returns true if the subject and provided Options are identical
+func (o *Options) Equal(o2 *Options) bool {
+	if o == nil || o2 == nil {
+		return o == o2
+	}
+	return o.ActiveKeyID == o2.ActiveKeyID && maps.Equal(o.KeyFiles, o2.KeyFiles)
+}
+
+// Enabled returns true if encryption is configured
+func (o *Options) Enabled() bool {
+	return o != nil && o.ActiveKeyID != ""
+}
+
+// Validate returns an error if the Options are invalid
+func (o *Options) Validate() error {
+	if len(o.KeyFiles) == 0 && o.ActiveKeyID == "" {
+		return nil
+	}
+	if o.ActiveKeyID == "" {
+		return ErrNoActiveKey
+	}
+	if _, ok := o.KeyFiles[o.ActiveKeyID]; !ok {
+		return ErrUnknownActiveKey
+	}
+	for k := range o.KeyFiles {
+		if len(k) > 255 {
+			return ErrKeyIDTooLong
+		}
+	}
+	return nil
+}
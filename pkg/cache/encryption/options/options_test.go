@@ -0,0 +1,63 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		o        *Options
+		expected error
+	}{
+		{New(), nil},
+		{&Options{KeyFiles: map[string]string{"k1": "/k1"}, ActiveKeyID: "k1"}, nil},
+		{&Options{KeyFiles: map[string]string{"k1": "/k1"}}, ErrNoActiveKey},
+		{&Options{KeyFiles: map[string]string{"k1": "/k1"}, ActiveKeyID: "k2"}, ErrUnknownActiveKey},
+		{&Options{KeyFiles: map[string]string{strings.Repeat("k", 256): "/k1", "k1": "/k1"},
+			ActiveKeyID: "k1"}, ErrKeyIDTooLong},
+	}
+	for i, test := range tests {
+		if err := test.o.Validate(); err != test.expected {
+			t.Errorf("test %d: expected %v got %v", i, test.expected, err)
+		}
+	}
+}
+
+func TestCloneAndEqual(t *testing.T) {
+	o := &Options{KeyFiles: map[string]string{"k1": "/k1"}, ActiveKeyID: "k1"}
+	c := o.Clone()
+	if !o.Equal(c) {
+		t.Error("expected true")
+	}
+	c.KeyFiles["k2"] = "/k2"
+	if o.Equal(c) {
+		t.Error("expected false")
+	}
+	if o.Equal(nil) {
+		t.Error("expected false")
+	}
+	var n *Options
+	if !n.Equal(nil) || n.Enabled() {
+		t.Error("expected nil options to be equal and disabled")
+	}
+	if !o.Enabled() {
+		t.Error("expected enabled")
+	}
+}
@@ -0,0 +1,306 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package envelope provides the versioned, at-rest format of serialized
+// documents in a cache, which records the codec used to encode the document,
+// the ID of the key used to encrypt it (if any), and a checksum of the payload
+package envelope
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+
+	eo "github.com/trickstercache/trickster/v2/pkg/cache/encryption/options"
+	"github.com/trickstercache/trickster/v2/pkg/encoding/brotli"
+	"github.com/trickstercache/trickster/v2/pkg/encoding/deflate"
+	"github.com/trickstercache/trickster/v2/pkg/encoding/gzip"
+	"github.com/trickstercache/trickster/v2/pkg/encoding/providers"
+	"github.com/trickstercache/trickster/v2/pkg/encoding/snappy"
+	"github.com/trickstercache/trickster/v2/pkg/encoding/zstd"
+)
+
+// An envelope is laid out as:
+//
+//	magic (1) | version (1) | codec (1) | flags (1) | key id length (1) | key id (n) |
+//	crc32c of payload (4) | payload
+//
+// where the payload is the encoded document, and when encrypted, is prefixed with
+// the AES-GCM nonce. The header (through the key id) is authenticated by AES-GCM.
+//
+// Documents written before envelopes were introduced have a single leading byte
+// of 0 (uncompressed) or 1 (brotli), and are read transparently.
+const (
+	// FormatVersion is the current envelope format version
+	FormatVersion byte = 1
+
+	magic         byte = 0xE0
+	flagEncrypted byte = 1
+
+	legacyIdentity byte = 0
+	legacyBrotli   byte = 1
+
+	fixedHeaderLen = 5
+	checksumLen    = 4
+)
+
+var (
+	// ErrTruncated is returned when an envelope is shorter than its header
+	ErrTruncated = errors.New("cache envelope is truncated")
+	// ErrUnsupportedVersion is returned when an envelope's format version is unknown,
+	// such as one written by a newer version of Trickster
+	ErrUnsupportedVersion = errors.New("unsupported cache envelope version")
+	// ErrUnsupportedCodec is returned when an envelope's codec is unknown
+	ErrUnsupportedCodec = errors.New("unsupported cache envelope codec")
+	// ErrChecksumMismatch is returned when an envelope's payload does not match its checksum
+	ErrChecksumMismatch = errors.New("cache envelope checksum mismatch")
+	// ErrUnknownKey is returned when an envelope is encrypted with a key that is not loaded
+	ErrUnknownKey = errors.New("cache envelope encrypted with unknown key")
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// IsCorrupt returns true if err, returned by Open or Verify, means the envelope's
+// data is damaged. Envelopes written by a newer version of Trickster, with a codec
+// that isn't supported, or encrypted with a key that isn't loaded, are unreadable
+// by this instance but not corrupt, since other instances may be able to read them
+func IsCorrupt(err error) bool {
+	return err != nil && !errors.Is(err, ErrUnsupportedVersion) &&
+		!errors.Is(err, ErrUnsupportedCodec) && !errors.Is(err, ErrUnknownKey)
+}
+
+// Envelope seals and opens serialized documents using a codec and, optionally,
+// a set of AES-GCM keys
+type Envelope struct {
+	codec       providers.Provider
+	keys        map[string]cipher.AEAD
+	activeKeyID string
+}
+
+// Default is the Envelope used by caches without codec or encryption options,
+// which compresses using brotli and does not encrypt
+var Default = &Envelope{codec: providers.Brotli}
+
+// New returns a new Envelope using the named codec, and the keys in the provided
+// encryption options, which are loaded from their files
+func New(codec string, o *eo.Options) (*Envelope, error) {
+	p, err := ParseCodec(codec)
+	if err != nil {
+		return nil, err
+	}
+	e := &Envelope{codec: p}
+	if !o.Enabled() {
+		return e, nil
+	}
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	e.keys = make(map[string]cipher.AEAD, len(o.KeyFiles))
+	for id, path := range o.KeyFiles {
+		key, err := LoadKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not load encryption key %s: %w", id, err)
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption key %s: %w", id, err)
+		}
+		e.keys[id] = aead
+	}
+	e.activeKeyID = o.ActiveKeyID
+	return e, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// ParseCodec returns the encoding provider for the named codec. An empty name
+// returns brotli, and "identity" or "none" returns the Identity provider
+func ParseCodec(name string) (providers.Provider, error) {
+	switch name {
+	case "":
+		return providers.Brotli, nil
+	case "identity", "none":
+		return providers.Identity, nil
+	}
+	p := providers.ProviderID(name)
+	if p == providers.Identity {
+		return p, fmt.Errorf("%w: %s", ErrUnsupportedCodec, name)
+	}
+	return p, nil
+}
+
+// Codec returns the Envelope's codec
+func (e *Envelope) Codec() providers.Provider {
+	return e.codec
+}
+
+// Encrypted returns true if the Envelope encrypts the documents it seals
+func (e *Envelope) Encrypted() bool {
+	return e.activeKeyID != ""
+}
+
+// Seal encodes the data with the Envelope's codec when compress is true, encrypts
+// it with the active key, if any, and returns it in an envelope
+func (e *Envelope) Seal(data []byte, compress bool) ([]byte, error) {
+	codec := providers.Identity
+	if compress {
+		codec = e.codec
+	}
+	payload, err := encode(codec, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var flags byte
+	var aead cipher.AEAD
+	keyID := ""
+	if e.Encrypted() {
+		flags |= flagEncrypted
+		keyID = e.activeKeyID
+		aead = e.keys[keyID]
+	}
+
+	header := make([]byte, 0, fixedHeaderLen+len(keyID))
+	header = append(header, magic, FormatVersion, byte(codec), flags, byte(len(keyID)))
+	header = append(header, keyID...)
+
+	if aead != nil {
+		nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(payload)+aead.Overhead())
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, err
+		}
+		payload = aead.Seal(nonce, nonce, payload, header)
+	}
+
+	out := make([]byte, len(header), len(header)+checksumLen+len(payload))
+	copy(out, header)
+	out = binary.BigEndian.AppendUint32(out, crc32.Checksum(payload, crcTable))
+	return append(out, payload...), nil
+}
+
+// Open returns the data sealed in the envelope, after verifying its checksum,
+// decrypting it and decoding it. Documents in the legacy single-byte format are
+// also accepted
+func (e *Envelope) Open(b []byte) ([]byte, error) {
+	if len(b) == 0 {
+		return nil, ErrTruncated
+	}
+	switch b[0] {
+	case legacyIdentity:
+		return b[1:], nil
+	case legacyBrotli:
+		return brotli.Decode(b[1:])
+	case magic:
+	default:
+		return nil, ErrUnsupportedVersion
+	}
+	hl, err := verify(b)
+	if err != nil {
+		return nil, err
+	}
+	codec, flags := providers.Provider(b[2]), b[3]
+	header, payload := b[:hl], b[hl+checksumLen:]
+	if flags&flagEncrypted == flagEncrypted {
+		aead, ok := e.keys[string(b[fixedHeaderLen:hl])]
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+		ns := aead.NonceSize()
+		if len(payload) < ns {
+			return nil, ErrTruncated
+		}
+		payload, err = aead.Open(nil, payload[:ns], payload[ns:], header)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return decode(codec, payload)
+}
+
+// Verify returns an error if the data is an envelope that is truncated, or whose
+// payload does not match its checksum. The payload is not decrypted or decoded, so
+// no keys are needed. Data in the legacy format has no checksum, and is not verified
+func Verify(b []byte) error {
+	if len(b) == 0 || b[0] != magic {
+		return nil
+	}
+	_, err := verify(b)
+	return err
+}
+
+// verify checks the envelope's header and checksum, and returns the header length
+func verify(b []byte) (int, error) {
+	if len(b) < fixedHeaderLen {
+		return 0, ErrTruncated
+	}
+	if b[1] != FormatVersion {
+		return 0, fmt.Errorf("%w: %d", ErrUnsupportedVersion, b[1])
+	}
+	hl := fixedHeaderLen + int(b[4])
+	if len(b) < hl+checksumLen {
+		return 0, ErrTruncated
+	}
+	if binary.BigEndian.Uint32(b[hl:]) != crc32.Checksum(b[hl+checksumLen:], crcTable) {
+		return 0, ErrChecksumMismatch
+	}
+	return hl, nil
+}
+
+func encode(p providers.Provider, b []byte) ([]byte, error) {
+	switch p {
+	case providers.Identity:
+		return b, nil
+	case providers.Zstandard:
+		return zstd.Encode(b)
+	case providers.Brotli:
+		return brotli.Encode(b)
+	case providers.GZip:
+		return gzip.Encode(b)
+	case providers.Deflate:
+		return deflate.Encode(b)
+	case providers.Snappy:
+		return snappy.Encode(b)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedCodec, p)
+}
+
+func decode(p providers.Provider, b []byte) ([]byte, error) {
+	switch p {
+	case providers.Identity:
+		return b, nil
+	case providers.Zstandard:
+		return zstd.Decode(b)
+	case providers.Brotli:
+		return brotli.Decode(b)
+	case providers.GZip:
+		return gzip.Decode(b)
+	case providers.Deflate:
+		return deflate.Decode(b)
+	case providers.Snappy:
+		return snappy.Decode(b)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedCodec, p)
+}
@@ -0,0 +1,245 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package envelope
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	eo "github.com/trickstercache/trickster/v2/pkg/cache/encryption/options"
+	"github.com/trickstercache/trickster/v2/pkg/encoding/brotli"
+	"github.com/trickstercache/trickster/v2/pkg/encoding/providers"
+)
+
+var testData = bytes.Repeat([]byte("trickster test document "), 64)
+
+func writeKeyFile(t *testing.T, dir, name string, key []byte) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.WriteFile(p, key, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestParseCodec(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected providers.Provider
+		err      bool
+	}{
+		{"", providers.Brotli, false},
+		{"identity", providers.Identity, false},
+		{"none", providers.Identity, false},
+		{"zstd", providers.Zstandard, false},
+		{"brotli", providers.Brotli, false},
+		{"gzip", providers.GZip, false},
+		{"deflate", providers.Deflate, false},
+		{"snappy", providers.Snappy, false},
+		{"invalid", providers.Identity, true},
+	}
+	for _, test := range tests {
+		p, err := ParseCodec(test.name)
+		if (err != nil) != test.err {
+			t.Errorf("%s: unexpected error result %v", test.name, err)
+		}
+		if p != test.expected {
+			t.Errorf("%s: expected %s got %s", test.name, test.expected, p)
+		}
+	}
+}
+
+func TestSealAndOpen(t *testing.T) {
+	for _, codec := range []string{"identity", "zstd", "brotli", "gzip", "deflate", "snappy"} {
+		e, err := New(codec, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, compress := range []bool{true, false} {
+			b, err := e.Seal(testData, compress)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b[0] != magic || b[1] != FormatVersion {
+				t.Errorf("%s: unexpected header %v", codec, b[:2])
+			}
+			if compress && codec != "identity" && len(b) >= len(testData) {
+				t.Errorf("%s: expected compressed envelope", codec)
+			}
+			out, err := e.Open(b)
+			if err != nil {
+				t.Fatalf("%s: %v", codec, err)
+			}
+			if !bytes.Equal(out, testData) {
+				t.Errorf("%s: unexpected document", codec)
+			}
+		}
+	}
+}
+
+func TestOpenLegacy(t *testing.T) {
+	out, err := Default.Open(append([]byte{legacyIdentity}, testData...))
+	if err != nil || !bytes.Equal(out, testData) {
+		t.Errorf("unexpected legacy identity result: %v", err)
+	}
+	enc, _ := brotli.Encode(testData)
+	out, err = Default.Open(append([]byte{legacyBrotli}, enc...))
+	if err != nil || !bytes.Equal(out, testData) {
+		t.Errorf("unexpected legacy brotli result: %v", err)
+	}
+}
+
+func TestOpenErrors(t *testing.T) {
+	b, _ := Default.Seal(testData, true)
+
+	tests := []struct {
+		b        []byte
+		expected error
+	}{
+		{nil, ErrTruncated},
+		{[]byte{magic, FormatVersion}, ErrTruncated},
+		{[]byte{0xFF}, ErrUnsupportedVersion},
+		{append([]byte{magic, FormatVersion + 1}, b[2:]...), ErrUnsupportedVersion},
+		{b[:len(b)-1], ErrChecksumMismatch},
+	}
+	for i, test := range tests {
+		if _, err := Default.Open(test.b); !errors.Is(err, test.expected) {
+			t.Errorf("test %d: expected %v got %v", i, test.expected, err)
+		}
+	}
+}
+
+func TestIsCorrupt(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected bool
+	}{
+		{nil, false},
+		{ErrTruncated, true},
+		{ErrChecksumMismatch, true},
+		{errors.New("cipher: message authentication failed"), true},
+		{ErrUnknownKey, false},
+		{ErrUnsupportedCodec, false},
+		{fmt.Errorf("%w: %d", ErrUnsupportedVersion, 2), false},
+	}
+	for i, test := range tests {
+		if v := IsCorrupt(test.err); v != test.expected {
+			t.Errorf("test %d: expected %t got %t", i, test.expected, v)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	b, _ := Default.Seal(testData, true)
+
+	tests := []struct {
+		b        []byte
+		expected error
+	}{
+		{b, nil},
+		{nil, nil},
+		{append([]byte{legacyIdentity}, testData...), nil},
+		{[]byte{magic, FormatVersion}, ErrTruncated},
+		{b[:fixedHeaderLen+1], ErrTruncated},
+		{b[:len(b)-1], ErrChecksumMismatch},
+	}
+	for i, test := range tests {
+		if err := Verify(test.b); !errors.Is(err, test.expected) {
+			t.Errorf("test %d: expected %v got %v", i, test.expected, err)
+		}
+	}
+}
+
+func TestEncryption(t *testing.T) {
+	dir := t.TempDir()
+	k1 := make([]byte, 32)
+	for i := range k1 {
+		k1[i] = byte(i)
+	}
+	k2 := bytes.Repeat([]byte{7}, 16)
+	o := &eo.Options{
+		KeyFiles: map[string]string{
+			"k1": writeKeyFile(t, dir, "k1", k1),
+			"k2": writeKeyFile(t, dir, "k2", []byte(hex.EncodeToString(k2)+"\n")),
+		},
+		ActiveKeyID: "k1",
+	}
+	e1, err := New("zstd", o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !e1.Encrypted() {
+		t.Error("expected encrypted envelope")
+	}
+	b, err := e1.Seal(testData, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(b, []byte("trickster")) {
+		t.Error("expected ciphertext")
+	}
+
+	// rotating the active key still opens objects sealed with the prior key
+	o.ActiveKeyID = "k2"
+	e2, err := New("zstd", o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := e2.Open(b)
+	if err != nil || !bytes.Equal(out, testData) {
+		t.Fatalf("unexpected result opening with rotated keys: %v", err)
+	}
+	b2, _ := e2.Seal(testData, false)
+	if _, err = e1.Open(b2); err != nil {
+		t.Error(err)
+	}
+
+	// an envelope without the key can't open the object
+	if _, err = Default.Open(b); err != ErrUnknownKey {
+		t.Errorf("expected %v got %v", ErrUnknownKey, err)
+	}
+
+	// tampering with the authenticated header is detected
+	b[2] = byte(providers.Identity)
+	if _, err = e1.Open(b); err == nil {
+		t.Error("expected error for tampered header")
+	}
+
+	o.KeyFiles["k3"] = writeKeyFile(t, dir, "k3", []byte("short"))
+	if _, err = New("zstd", o); err == nil {
+		t.Error("expected error for invalid key")
+	}
+	o.KeyFiles["k3"] = filepath.Join(dir, "missing")
+	if _, err = New("zstd", o); err == nil {
+		t.Error("expected error for missing key file")
+	}
+}
+
+func TestLoadKey(t *testing.T) {
+	dir := t.TempDir()
+	key := bytes.Repeat([]byte{1}, 24)
+	p := writeKeyFile(t, dir, "b64", []byte("AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEB\n"))
+	k, err := LoadKey(p)
+	if err != nil || !bytes.Equal(k, key) {
+		t.Errorf("unexpected base64 key result: %v", err)
+	}
+}
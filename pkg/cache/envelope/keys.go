@@ -0,0 +1,54 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package envelope
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"os"
+)
+
+// ErrInvalidKeySize is returned when a key is not 16, 24 or 32 bytes
+var ErrInvalidKeySize = errors.New("encryption keys must be 16, 24 or 32 bytes")
+
+func validKeySize(n int) bool {
+	return n == 16 || n == 24 || n == 32
+}
+
+// LoadKey returns the AES key in the file at path, which may contain the key as
+// hex- or base64-encoded text, or the raw key bytes
+func LoadKey(path string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// encoded text is checked first, since a 32-character hex-encoded
+	// 16-byte key is also a valid raw 32-byte key
+	s := string(bytes.TrimSpace(b))
+	if k, err := hex.DecodeString(s); err == nil && validKeySize(len(k)) {
+		return k, nil
+	}
+	if k, err := base64.StdEncoding.DecodeString(s); err == nil && validKeySize(len(k)) {
+		return k, nil
+	}
+	if validKeySize(len(b)) {
+		return b, nil
+	}
+	return nil, ErrInvalidKeySize
+}
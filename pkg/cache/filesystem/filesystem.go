@@ -18,6 +18,7 @@
 package filesystem
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"strconv"
@@ -29,11 +30,15 @@ import (
 	"github.com/trickstercache/trickster/v2/pkg/cache/index"
 	"github.com/trickstercache/trickster/v2/pkg/cache/metrics"
 	"github.com/trickstercache/trickster/v2/pkg/cache/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache/scrub"
 	"github.com/trickstercache/trickster/v2/pkg/cache/status"
 	"github.com/trickstercache/trickster/v2/pkg/locks"
 	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
 )
 
+// dataFileSuffix is the suffix of the file names of cached objects
+const dataFileSuffix = ".data"
+
 // Cache describes a Filesystem Cache
 type Cache struct {
 	Name       string
@@ -42,6 +47,7 @@ type Cache struct {
 	Logger     interface{}
 	locker     locks.NamedLocker
 	lockPrefix string
+	scrubStop  chan struct{}
 }
 
 // Locker returns the cache's locker
@@ -59,6 +65,11 @@ func (c *Cache) Configuration() *options.Options {
 	return c.Config
 }
 
+// CacheIndex returns the Index that tracks the cache's objects
+func (c *Cache) CacheIndex() *index.Index {
+	return c.Index
+}
+
 // Connect instantiates the Cache mutex map and starts the Expired Entry Reaper goroutine
 func (c *Cache) Connect() error {
 	tl.Info(c.Logger, "filesystem cache setup", tl.Pairs{
@@ -74,6 +85,11 @@ func (c *Cache) Connect() error {
 	indexData, _, _ := c.retrieve(index.IndexKey, false, false)
 	c.Index = index.NewIndex(c.Name, c.Config.Provider, indexData,
 		c.Config.Index, c.BulkRemove, c.storeNoIndex, c.Logger)
+	if c.Config.Index != nil && c.Config.Index.ScrubIntervalMS > 0 {
+		c.scrubStop = make(chan struct{})
+		go scrub.New(c.Name, c.Config.Provider, c, c.Index, c.Logger).
+			Run(time.Duration(c.Config.Index.ScrubIntervalMS)*time.Millisecond, c.scrubStop)
+	}
 	return nil
 }
 
@@ -143,7 +159,10 @@ func (c *Cache) retrieve(cacheKey string, allowExpired bool, atime bool) ([]byte
 
 	o, err := index.ObjectFromBytes(data)
 	if err != nil {
-
+		// the file is corrupt, such as one left truncated by a crash, so it's removed
+		// to be replaced on the next write, rather than failing every read
+		metrics.ObserveCacheCorruption(c.Name, c.Config.Provider, "read")
+		c.RemoveIfUnchanged(cacheKey, nil)
 		_, err2 := metrics.CacheError(cacheKey, c.Name, c.Config.Provider,
 			"value for key [%s] could not be deserialized from cache")
 		return nil, status.LookupStatusError, err2
@@ -190,6 +209,33 @@ func (c *Cache) remove(cacheKey string, isBulk bool) {
 	metrics.ObserveCacheDel(c.Name, c.Config.Provider, 0)
 }
 
+// RemoveIfUnchanged removes the object only if its stored value still matches value,
+// which is nil for an object that could not be decoded, and returns true if it was
+// removed. This keeps an object that was rewritten after being found to be corrupt
+// from being removed along with the corrupt version
+func (c *Cache) RemoveIfUnchanged(cacheKey string, value []byte) bool {
+	nl, _ := c.locker.Acquire(c.lockPrefix + cacheKey)
+	defer nl.Release()
+	dataFile := c.getFileName(cacheKey)
+	data, err := os.ReadFile(dataFile)
+	if err != nil {
+		return false
+	}
+	o, err := index.ObjectFromBytes(data)
+	if unchanged := (err != nil && value == nil) ||
+		(err == nil && value != nil && bytes.Equal(o.Value, value)); !unchanged {
+		return false
+	}
+	if err = os.Remove(dataFile); err != nil {
+		return false
+	}
+	if c.Index != nil {
+		go c.Index.RemoveObject(cacheKey)
+	}
+	metrics.ObserveCacheDel(c.Name, c.Config.Provider, 0)
+	return true
+}
+
 // BulkRemove removes a list of objects from the cache
 func (c *Cache) BulkRemove(cacheKeys []string) {
 	wg := &sync.WaitGroup{}
@@ -204,17 +250,48 @@ func (c *Cache) BulkRemove(cacheKeys []string) {
 	wg.Wait()
 }
 
-// Close is not used for Cache
+// Close stops the cache's scrubber and Index
 func (c *Cache) Close() error {
+	if c.scrubStop != nil {
+		close(c.scrubStop)
+		c.scrubStop = nil
+	}
 	if c.Index != nil {
 		c.Index.Close()
 	}
 	return nil
 }
 
+// Walk calls visit with the key and value of each object in the cache path
+func (c *Cache) Walk(visit func(key string, value []byte, err error)) error {
+	entries, err := os.ReadDir(c.Config.Filesystem.CachePath)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), dataFileSuffix) {
+			continue
+		}
+		cacheKey := strings.TrimSuffix(e.Name(), dataFileSuffix)
+		nl, _ := c.locker.RAcquire(c.lockPrefix + cacheKey)
+		data, err := os.ReadFile(c.getFileName(cacheKey))
+		nl.RRelease()
+		if err != nil {
+			// the object was removed since the directory was read
+			continue
+		}
+		o, err := index.ObjectFromBytes(data)
+		if err != nil {
+			visit(cacheKey, nil, err)
+			continue
+		}
+		visit(cacheKey, o.Value, nil)
+	}
+	return nil
+}
+
 func (c *Cache) getFileName(cacheKey string) string {
-	prefix := strings.Replace(c.Config.Filesystem.CachePath+"/"+cacheKey+".", "//", "/", 1)
-	return prefix + "data"
+	return strings.Replace(c.Config.Filesystem.CachePath+"/"+cacheKey+dataFileSuffix, "//", "/", 1)
 }
 
 // makeDirectory creates a directory on the filesystem and returns the error in the event of a failure.
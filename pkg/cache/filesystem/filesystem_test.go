@@ -25,8 +25,10 @@ import (
 
 	"github.com/trickstercache/trickster/v2/pkg/cache"
 	flo "github.com/trickstercache/trickster/v2/pkg/cache/filesystem/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache/index"
 	io "github.com/trickstercache/trickster/v2/pkg/cache/index/options"
 	co "github.com/trickstercache/trickster/v2/pkg/cache/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache/scrub"
 	"github.com/trickstercache/trickster/v2/pkg/cache/status"
 	"github.com/trickstercache/trickster/v2/pkg/locks"
 	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
@@ -399,6 +401,91 @@ func TestFilesystemCache_Retrieve(t *testing.T) {
 	if ls != status.LookupStatusError {
 		t.Errorf("expected %s got %s", status.LookupStatusError, ls)
 	}
+	// the corrupt file should have been removed
+	if _, err = os.Stat(filename); !os.IsNotExist(err) {
+		t.Errorf("expected corrupt file to be removed, got %v", err)
+	}
+}
+
+func TestFilesystemCache_RemoveIfUnchanged(t *testing.T) {
+	cacheConfig := newCacheConfig(t)
+	fc := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+	if err := fc.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer fc.Close()
+	filename := fc.getFileName(cacheKey)
+
+	// a corrupt file is removed
+	os.WriteFile(filename, []byte("junk"), 0o644)
+	if !fc.RemoveIfUnchanged(cacheKey, nil) {
+		t.Error("expected corrupt file to be removed")
+	}
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Errorf("expected corrupt file to be removed, got %v", err)
+	}
+
+	// a file rewritten since it was found to be corrupt, or that holds a
+	// different value, is kept
+	if err := fc.Store(cacheKey, []byte("data"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if fc.RemoveIfUnchanged(cacheKey, nil) || fc.RemoveIfUnchanged(cacheKey, []byte("other")) {
+		t.Error("expected rewritten file to be kept")
+	}
+	if _, ls, _ := fc.Retrieve(cacheKey, false); ls != status.LookupStatusHit {
+		t.Errorf("expected %s got %s", status.LookupStatusHit, ls)
+	}
+
+	// a file holding the same value is removed
+	if !fc.RemoveIfUnchanged(cacheKey, []byte("data")) {
+		t.Error("expected unchanged file to be removed")
+	}
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Errorf("expected unchanged file to be removed, got %v", err)
+	}
+}
+
+func TestFilesystemCache_Scrub(t *testing.T) {
+	cacheConfig := newCacheConfig(t)
+	fc := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+	if err := fc.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer fc.Close()
+	if err := fc.Store(cacheKey, []byte("data"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	// a truncated file, and a file that is not tracked by the index
+	os.WriteFile(fc.getFileName(cacheKey+"-truncated"), []byte("junk"), 0o644)
+	o := &index.Object{Key: cacheKey + "-orphan", Value: []byte("data")}
+	os.WriteFile(fc.getFileName(cacheKey+"-orphan"), o.ToBytes(), 0o644)
+	// files without the data suffix are not managed by the cache
+	os.WriteFile(cacheConfig.Filesystem.CachePath+"/README", []byte("junk"), 0o644)
+
+	sc := scrub.New(fc.Name, cacheProvider, &fc, fc.Index, nil)
+	r, err := sc.Scrub()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Scanned != 3 || r.Corrupt != 1 || r.Orphaned != 0 {
+		t.Errorf("unexpected result %+v", r)
+	}
+	r, _ = sc.Scrub()
+	if r.Scanned != 2 || r.Orphaned != 1 {
+		t.Errorf("unexpected result %+v", r)
+	}
+	for _, k := range []string{cacheKey + "-truncated", cacheKey + "-orphan"} {
+		if _, err = os.Stat(fc.getFileName(k)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed, got %v", k, err)
+		}
+	}
+	if _, ls, _ := fc.Retrieve(cacheKey, false); ls != status.LookupStatusHit {
+		t.Errorf("expected %s got %s", status.LookupStatusHit, ls)
+	}
+	if _, err = os.Stat(cacheConfig.Filesystem.CachePath + "/README"); err != nil {
+		t.Error(err)
+	}
 }
 
 func BenchmarkCache_Retrieve(b *testing.B) {
@@ -0,0 +1,81 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package index
+
+import (
+	"sort"
+
+	"github.com/trickstercache/trickster/v2/pkg/cache/index/options"
+)
+
+// evictionPolicy returns the Index's configured eviction policy
+func (idx *Index) evictionPolicy() string {
+	if idx.options == nil || idx.options.EvictionPolicy == "" {
+		return options.DefaultEvictionPolicy
+	}
+	return idx.options.EvictionPolicy
+}
+
+// sortForEviction sorts the objects in the order they should be evicted under the policy
+func sortForEviction(policy string, objects objectsAtime) {
+	switch policy {
+	case options.EvictionPolicyLFU:
+		sort.SliceStable(objects, func(i, j int) bool {
+			if objects[i].AccessCount != objects[j].AccessCount {
+				return objects[i].AccessCount < objects[j].AccessCount
+			}
+			return objects[i].LastAccess.Before(objects[j].LastAccess)
+		})
+	case options.EvictionPolicySLRU:
+		// objects that have not been accessed since being written are probationary,
+		// and are all evicted before any objects in the protected segment
+		sort.SliceStable(objects, func(i, j int) bool {
+			pi, pj := objects[i].AccessCount == 0, objects[j].AccessCount == 0
+			if pi != pj {
+				return pi
+			}
+			return objects[i].LastAccess.Before(objects[j].LastAccess)
+		})
+	case options.EvictionPolicyTTL:
+		// objects that never expire are evicted last
+		sort.SliceStable(objects, func(i, j int) bool {
+			ei, ej := objects[i].Expiration, objects[j].Expiration
+			if ei.IsZero() != ej.IsZero() {
+				return ej.IsZero()
+			}
+			if !ei.Equal(ej) {
+				return ei.Before(ej)
+			}
+			return objects[i].LastAccess.Before(objects[j].LastAccess)
+		})
+	default:
+		sort.Sort(objects)
+	}
+}
+
+// ageAccessCounts halves the access count of each object on each reap cycle, so
+// that objects which were popular in the past, but no longer are, are eventually
+// evicted by LFU, even when the cache rarely reaches its max size
+func (idx *Index) ageAccessCounts() {
+	for _, sh := range idx.shards {
+		sh.mtx.Lock()
+		for _, o := range sh.objects {
+			o.AccessCount >>= 1
+		}
+		sh.mtx.Unlock()
+	}
+}
@@ -0,0 +1,106 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package index
+
+import (
+	"testing"
+	"time"
+
+	io "github.com/trickstercache/trickster/v2/pkg/cache/index/options"
+)
+
+func TestSortForEviction(t *testing.T) {
+	now := time.Now()
+	newObjects := func() objectsAtime {
+		return objectsAtime{
+			// a regularly-used report, last accessed long ago
+			{Key: "report", LastAccess: now.Add(-3 * time.Hour), AccessCount: 20,
+				Expiration: now.Add(time.Hour)},
+			// a one-shot query, recently written
+			{Key: "adhoc", LastAccess: now.Add(-time.Minute),
+				Expiration: now.Add(2 * time.Hour)},
+			// an object that never expires
+			{Key: "forever", LastAccess: now.Add(-2 * time.Hour), AccessCount: 1},
+			// a frequently-used object that expires soon
+			{Key: "popular", LastAccess: now, AccessCount: 50,
+				Expiration: now.Add(time.Minute)},
+		}
+	}
+	tests := []struct {
+		policy   string
+		expected []string
+	}{
+		{"", []string{"report", "forever", "adhoc", "popular"}},
+		{io.EvictionPolicyLRU, []string{"report", "forever", "adhoc", "popular"}},
+		{io.EvictionPolicyLFU, []string{"adhoc", "forever", "report", "popular"}},
+		{io.EvictionPolicySLRU, []string{"adhoc", "report", "forever", "popular"}},
+		{io.EvictionPolicyTTL, []string{"popular", "report", "adhoc", "forever"}},
+	}
+	for _, test := range tests {
+		t.Run(test.policy, func(t *testing.T) {
+			o := newObjects()
+			sortForEviction(test.policy, o)
+			for i, k := range test.expected {
+				if o[i].Key != k {
+					t.Errorf("expected %s at %d got %s", k, i, o[i].Key)
+				}
+			}
+		})
+	}
+}
+
+func TestReapLFU(t *testing.T) {
+	o := io.New()
+	o.ReapInterval = 0
+	o.MaxSizeObjects = 2
+	o.MaxSizeBackoffObjects = 0
+	o.MaxSizeBytes = 0
+	o.EvictionPolicy = io.EvictionPolicyLFU
+	idx := NewIndex("test", "test", nil, o, testBulkRemoveFunc, nil, testLogger)
+
+	idx.UpdateObject(&Object{Key: "report", Value: []byte("value")})
+	for i := 0; i < 4; i++ {
+		idx.UpdateObjectAccessTime("report")
+	}
+	idx.UpdateObject(&Object{Key: "adhoc1", Value: []byte("value")})
+	idx.UpdateObject(&Object{Key: "adhoc2", Value: []byte("value")})
+	idx.UpdateObjectAccessTime("adhoc2")
+
+	// rewriting an object retains its access count
+	idx.UpdateObject(&Object{Key: "report", Value: []byte("value")})
+	if o, _ := idx.object("report"); o.AccessCount != 4 {
+		t.Errorf("expected %d got %d", 4, o.AccessCount)
+	}
+
+	idx.reap(testLogger)
+	if _, ok := idx.object("adhoc1"); ok {
+		t.Error("expected least-frequently used object to be evicted")
+	}
+	if _, ok := idx.object("report"); !ok {
+		t.Error("expected most-frequently used object to be retained")
+	}
+	// remaining access counts are aged
+	if o, _ := idx.object("report"); o.AccessCount != 2 {
+		t.Errorf("expected %d got %d", 2, o.AccessCount)
+	}
+
+	// access counts are aged on each reap, even when no eviction is needed
+	idx.reap(testLogger)
+	if o, _ := idx.object("report"); o.AccessCount != 1 {
+		t.Errorf("expected %d got %d", 1, o.AccessCount)
+	}
+}
@@ -18,7 +18,6 @@
 package index
 
 import (
-	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -26,6 +25,7 @@ import (
 	"github.com/trickstercache/trickster/v2/pkg/cache"
 	"github.com/trickstercache/trickster/v2/pkg/cache/index/options"
 	"github.com/trickstercache/trickster/v2/pkg/cache/metrics"
+	"github.com/trickstercache/trickster/v2/pkg/checksum/fnv"
 	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
 	gm "github.com/trickstercache/trickster/v2/pkg/observability/metrics"
 )
@@ -38,11 +38,14 @@ const IndexKey = "cache.index"
 // Index maintains metadata about a Cache when Retention enforcement is managed internally,
 // like memory or bbolt. It is not used for independently managed caches like Redis.
 type Index struct {
-	// CacheSize represents the size of the cache in bytes
+	// CacheSize represents the size of the cache in bytes. Like Objects, it is
+	// populated only while the Index is serialized; use Size otherwise
 	CacheSize int64 `msg:"cache_size"`
-	// ObjectCount represents the count of objects in the Cache
+	// ObjectCount represents the count of objects in the Cache. Like Objects, it is
+	// populated only while the Index is serialized; use Count otherwise
 	ObjectCount int64 `msg:"object_count"`
-	// Objects is a map of Objects in the Cache
+	// Objects is a map of Objects in the Cache. It is populated only while the Index
+	// is serialized or deserialized, since the working set of Objects is sharded
 	Objects map[string]*Object `msg:"objects"`
 
 	name           string                             `msg:"-"`
@@ -50,15 +53,28 @@ type Index struct {
 	options        *options.Options                   `msg:"-"`
 	bulkRemoveFunc func([]string)                     `msg:"-"`
 	flushFunc      func(cacheKey string, data []byte) `msg:"-"`
-	lastWrite      time.Time                          `msg:"-"`
+	// lastWrite is the time of the last write to the Index, in unix nanoseconds
+	lastWrite atomic.Int64 `msg:"-"`
+	shards    []*shard     `msg:"-"`
 
 	isClosing     bool
 	flusherExited bool
 	reaperExited  bool
 
+	// mtx serializes reap exercises and guards options
 	mtx sync.Mutex
 }
 
+// shard is a partition of the Index's Objects, with its own lock, so that
+// concurrent operations on objects in different shards do not contend. Its
+// size and count are written only under its lock, but can be read without it
+type shard struct {
+	mtx     sync.Mutex
+	objects map[string]*Object
+	size    atomic.Int64
+	count   atomic.Int64
+}
+
 // Close is called to signal the index to shut down any subroutines
 func (idx *Index) Close() {
 	idx.isClosing = true
@@ -66,10 +82,68 @@ func (idx *Index) Close() {
 
 // ToBytes returns a serialized byte slice representing the Index
 func (idx *Index) ToBytes() []byte {
-	bytes, _ := idx.MarshalMsg(nil)
+	bytes, _ := idx.snapshot().MarshalMsg(nil)
 	return bytes
 }
 
+// snapshot returns an unsharded copy of the Index that can be serialized
+func (idx *Index) snapshot() *Index {
+	s := &Index{Objects: make(map[string]*Object, idx.Count())}
+	for _, sh := range idx.shards {
+		sh.mtx.Lock()
+		for k, o := range sh.objects {
+			c := *o
+			s.Objects[k] = &c
+		}
+		s.CacheSize += sh.size.Load()
+		s.ObjectCount += sh.count.Load()
+		sh.mtx.Unlock()
+	}
+	return s
+}
+
+// Size returns the size of the cache in bytes
+func (idx *Index) Size() int64 {
+	var n int64
+	for _, sh := range idx.shards {
+		n += sh.size.Load()
+	}
+	return n
+}
+
+// Count returns the count of objects in the cache
+func (idx *Index) Count() int64 {
+	var n int64
+	for _, sh := range idx.shards {
+		n += sh.count.Load()
+	}
+	return n
+}
+
+// ShardFor returns the shard number, between 0 and shardCount-1, for the provided key
+func ShardFor(key string, shardCount int) int {
+	h := fnv.NewInlineFNV64a()
+	h.WriteString(key)
+	return int(h.Sum64() % uint64(shardCount))
+}
+
+func (idx *Index) shard(key string) *shard {
+	return idx.shards[ShardFor(key, len(idx.shards))]
+}
+
+// object returns the Object for the provided key, and whether it was found
+func (idx *Index) object(key string) (*Object, bool) {
+	sh := idx.shard(key)
+	sh.mtx.Lock()
+	o, ok := sh.objects[key]
+	sh.mtx.Unlock()
+	return o, ok
+}
+
+func (idx *Index) touch() {
+	idx.lastWrite.Store(time.Now().UnixNano())
+}
+
 // Object contains metadata about an item in the Cache
 type Object struct {
 	// Key represents the name of the Object and is the
@@ -83,6 +157,9 @@ type Object struct {
 	LastAccess time.Time `msg:"lastaccess"`
 	// Size the size of the Object in bytes
 	Size int64 `msg:"size"`
+	// AccessCount is the number of times the Object has been accessed since it was
+	// first written, as aged by the LFU eviction policy
+	AccessCount int64 `msg:"access_count"`
 	// Value is the value of the Object stored in the Cache
 	// It is used by Caches but not by the Index
 	Value []byte `msg:"value,omitempty"`
@@ -113,10 +190,25 @@ func NewIndex(cacheName, cacheProvider string, indexData []byte, o *options.Opti
 
 	if len(indexData) > 0 {
 		i.UnmarshalMsg(indexData)
-	} else {
-		i.Objects = make(map[string]*Object)
 	}
 
+	n := o.ShardCount
+	if n < 1 {
+		n = options.DefaultShardCount
+	}
+	i.shards = make([]*shard, n)
+	for j := range i.shards {
+		i.shards[j] = &shard{objects: make(map[string]*Object)}
+	}
+	for k, obj := range i.Objects {
+		sh := i.shard(k)
+		sh.objects[k] = obj
+		sh.size.Add(obj.Size)
+		sh.count.Add(1)
+	}
+	i.Objects = nil
+	i.CacheSize, i.ObjectCount = 0, 0
+
 	i.name = cacheName
 	i.cacheProvider = cacheProvider
 	i.flushFunc = flushFunc
@@ -145,29 +237,33 @@ func NewIndex(cacheName, cacheProvider string, indexData []byte, o *options.Opti
 	return i
 }
 
-// UpdateOptions updates the existing Index with a new Options reference
+// UpdateOptions updates the existing Index with a new Options reference. The
+// number of shards is fixed when the Index is created, so ShardCount is not applied
 func (idx *Index) UpdateOptions(o *options.Options) {
 	idx.mtx.Lock()
 	idx.options = o
 	idx.mtx.Unlock()
 }
 
-// UpdateObjectAccessTime updates the LastAccess for the object with the provided key
+// UpdateObjectAccessTime updates the LastAccess and AccessCount for the object with the provided key
 func (idx *Index) UpdateObjectAccessTime(key string) {
-	idx.mtx.Lock()
-	if _, ok := idx.Objects[key]; ok {
-		idx.Objects[key].LastAccess = time.Now()
+	sh := idx.shard(key)
+	sh.mtx.Lock()
+	if o, ok := sh.objects[key]; ok {
+		o.LastAccess = time.Now()
+		o.AccessCount++
 	}
-	idx.mtx.Unlock()
+	sh.mtx.Unlock()
 }
 
 // UpdateObjectTTL updates the Expiration for the object with the provided key
 func (idx *Index) UpdateObjectTTL(key string, ttl time.Duration) {
-	idx.mtx.Lock()
-	if _, ok := idx.Objects[key]; ok {
-		idx.Objects[key].Expiration = time.Now().Add(ttl)
+	sh := idx.shard(key)
+	sh.mtx.Lock()
+	if o, ok := sh.objects[key]; ok {
+		o.Expiration = time.Now().Add(ttl)
 	}
-	idx.mtx.Unlock()
+	sh.mtx.Unlock()
 }
 
 // UpdateObject writes or updates the Index Metadata for the provided Object
@@ -177,9 +273,10 @@ func (idx *Index) UpdateObject(obj *Object) {
 		return
 	}
 
-	idx.mtx.Lock()
+	sh := idx.shard(key)
+	sh.mtx.Lock()
 
-	idx.lastWrite = time.Now()
+	idx.touch()
 
 	if obj.ReferenceValue != nil {
 		obj.Size = int64(obj.ReferenceValue.Size())
@@ -190,63 +287,59 @@ func (idx *Index) UpdateObject(obj *Object) {
 	obj.LastAccess = time.Now()
 	obj.LastWrite = obj.LastAccess
 
-	if o, ok := idx.Objects[key]; ok {
-		atomic.AddInt64(&idx.CacheSize, obj.Size-o.Size)
+	if o, ok := sh.objects[key]; ok {
+		sh.size.Add(obj.Size - o.Size)
+		obj.AccessCount = o.AccessCount
 	} else {
-		atomic.AddInt64(&idx.CacheSize, obj.Size)
-		atomic.AddInt64(&idx.ObjectCount, 1)
+		sh.size.Add(obj.Size)
+		sh.count.Add(1)
 	}
+	sh.objects[key] = obj
+	sh.mtx.Unlock()
 
-	metrics.ObserveCacheSizeChange(idx.name, idx.cacheProvider, idx.CacheSize, idx.ObjectCount)
-
-	idx.Objects[key] = obj
-	idx.mtx.Unlock()
+	metrics.ObserveCacheSizeChange(idx.name, idx.cacheProvider, idx.Size(), idx.Count())
 }
 
 // RemoveObject removes an Object's Metadata from the Index
 func (idx *Index) RemoveObject(key string) {
-	idx.mtx.Lock()
-	idx.lastWrite = time.Now()
-	if o, ok := idx.Objects[key]; ok {
-		atomic.AddInt64(&idx.CacheSize, -o.Size)
-		atomic.AddInt64(&idx.ObjectCount, -1)
-
-		metrics.ObserveCacheOperation(idx.name, idx.cacheProvider, "del", "none", float64(o.Size))
+	idx.touch()
+	idx.removeObject(key)
+}
 
-		delete(idx.Objects, key)
-		metrics.ObserveCacheSizeChange(idx.name, idx.cacheProvider, idx.CacheSize, idx.ObjectCount)
+func (idx *Index) removeObject(key string) {
+	sh := idx.shard(key)
+	sh.mtx.Lock()
+	o, ok := sh.objects[key]
+	if ok {
+		delete(sh.objects, key)
+		sh.size.Add(-o.Size)
+		sh.count.Add(-1)
 	}
-	idx.mtx.Unlock()
+	sh.mtx.Unlock()
+	if !ok {
+		return
+	}
+	metrics.ObserveCacheOperation(idx.name, idx.cacheProvider, "del", "none", float64(o.Size))
+	metrics.ObserveCacheSizeChange(idx.name, idx.cacheProvider, idx.Size(), idx.Count())
 }
 
-// RemoveObjects removes a list of Objects' Metadata from the Index
+// RemoveObjects removes a list of Objects' Metadata from the Index. Since each
+// Object is removed under its shard's lock, noLock is retained only for compatibility
 func (idx *Index) RemoveObjects(keys []string, noLock bool) {
-	if !noLock {
-		idx.mtx.Lock()
-	}
 	for _, key := range keys {
-		if o, ok := idx.Objects[key]; ok {
-			atomic.AddInt64(&idx.CacheSize, -o.Size)
-			atomic.AddInt64(&idx.ObjectCount, -1)
-			metrics.ObserveCacheOperation(idx.name, idx.cacheProvider, "del", "none", float64(o.Size))
-			delete(idx.Objects, key)
-			metrics.ObserveCacheSizeChange(idx.name, idx.cacheProvider, idx.CacheSize, idx.ObjectCount)
-		}
-	}
-	idx.lastWrite = time.Now()
-	if !noLock {
-		idx.mtx.Unlock()
+		idx.removeObject(key)
 	}
+	idx.touch()
 }
 
 // GetExpiration returns the cache index's expiration for the object of the given key
 func (idx *Index) GetExpiration(cacheKey string) time.Time {
-	idx.mtx.Lock()
-	if o, ok := idx.Objects[cacheKey]; ok {
-		idx.mtx.Unlock()
+	sh := idx.shard(cacheKey)
+	sh.mtx.Lock()
+	defer sh.mtx.Unlock()
+	if o, ok := sh.objects[cacheKey]; ok {
 		return o.Expiration
 	}
-	idx.mtx.Unlock()
 	return time.Time{}
 }
 
@@ -255,7 +348,7 @@ func (idx *Index) flusher(logger interface{}) {
 	var lastFlush time.Time
 	for !idx.isClosing {
 		time.Sleep(idx.options.FlushInterval)
-		if idx.lastWrite.Before(lastFlush) {
+		if time.Unix(0, idx.lastWrite.Load()).Before(lastFlush) {
 			continue
 		}
 		idx.flushOnce(logger)
@@ -265,9 +358,7 @@ func (idx *Index) flusher(logger interface{}) {
 }
 
 func (idx *Index) flushOnce(logger interface{}) {
-	idx.mtx.Lock()
-	bytes, err := idx.MarshalMsg(nil)
-	idx.mtx.Unlock()
+	bytes, err := idx.snapshot().MarshalMsg(nil)
 	if err != nil {
 		tl.Warn(logger, "unable to serialize index for flushing",
 			tl.Pairs{"cacheName": idx.name, "detail": err.Error()})
@@ -288,44 +379,57 @@ func (idx *Index) reaper(logger interface{}) {
 type objectsAtime []*Object
 
 // reap makes a single iteration through the cache index to to find and remove expired elements
-// and evict least-recently-accessed elements to maintain the Maximum allowed Cache Size
+// and evict elements, as selected by the eviction policy, to maintain the Maximum allowed Cache Size
 func (idx *Index) reap(logger interface{}) {
 	idx.mtx.Lock()
 	defer idx.mtx.Unlock()
 
 	removals := make([]string, 0)
-	remainders := make(objectsAtime, 0, idx.ObjectCount)
+	remainders := make(objectsAtime, 0, idx.Count())
 
 	var cacheChanged bool
 
 	now := time.Now()
 
-	for _, o := range idx.Objects {
-		if o.Key == IndexKey {
-			continue
-		}
-		if o.Expiration.Before(now) && !o.Expiration.IsZero() {
-			removals = append(removals, o.Key)
-		} else {
-			remainders = append(remainders, o)
+	// each shard is locked only while its objects are copied, so that the
+	// eviction exercise does not block cache operations on other shards
+	for _, sh := range idx.shards {
+		sh.mtx.Lock()
+		for _, o := range sh.objects {
+			if o.Key == IndexKey {
+				continue
+			}
+			if o.Expiration.Before(now) && !o.Expiration.IsZero() {
+				removals = append(removals, o.Key)
+			} else {
+				c := *o
+				remainders = append(remainders, &c)
+			}
 		}
+		sh.mtx.Unlock()
 	}
 
+	policy := idx.evictionPolicy()
+
 	if len(removals) > 0 {
 		metrics.ObserveCacheEvent(idx.name, idx.cacheProvider, "eviction", "ttl")
+		metrics.ObserveCacheEvictions(idx.name, idx.cacheProvider, policy, "ttl", len(removals))
 		go idx.bulkRemoveFunc(removals)
 		idx.RemoveObjects(removals, true)
 		cacheChanged = true
 	}
 
-	if ((idx.options.MaxSizeBytes > 0 && idx.CacheSize > idx.options.MaxSizeBytes) ||
-		(idx.options.MaxSizeObjects > 0 && idx.ObjectCount > idx.options.MaxSizeObjects)) &&
+	cacheSize := idx.Size()
+	objectCount := idx.Count()
+
+	if ((idx.options.MaxSizeBytes > 0 && cacheSize > idx.options.MaxSizeBytes) ||
+		(idx.options.MaxSizeObjects > 0 && objectCount > idx.options.MaxSizeObjects)) &&
 		len(remainders) > 0 {
 
 		var evictionType string
-		if idx.options.MaxSizeBytes > 0 && idx.CacheSize > idx.options.MaxSizeBytes {
+		if idx.options.MaxSizeBytes > 0 && cacheSize > idx.options.MaxSizeBytes {
 			evictionType = "size_bytes"
-		} else if idx.options.MaxSizeObjects > 0 && idx.ObjectCount > idx.options.MaxSizeObjects {
+		} else if idx.options.MaxSizeObjects > 0 && objectCount > idx.options.MaxSizeObjects {
 			evictionType = "size_objects"
 		} else {
 			return
@@ -335,20 +439,21 @@ func (idx *Index) reap(logger interface{}) {
 			"max cache size reached. evicting least-recently-accessed records",
 			tl.Pairs{
 				"reason":         evictionType,
-				"cacheSizeBytes": idx.CacheSize, "maxSizeBytes": idx.options.MaxSizeBytes,
-				"cacheSizeObjects": idx.ObjectCount, "maxSizeObjects": idx.options.MaxSizeObjects,
+				"cacheSizeBytes": cacheSize, "maxSizeBytes": idx.options.MaxSizeBytes,
+				"cacheSizeObjects": objectCount, "maxSizeObjects": idx.options.MaxSizeObjects,
+				"evictionPolicy": policy,
 			},
 		)
 
 		removals = make([]string, 0)
 
-		sort.Sort(remainders)
+		sortForEviction(policy, remainders)
 
 		i := 0
 		j := len(remainders)
 
 		if evictionType == "size_bytes" {
-			bytesNeeded := (idx.CacheSize - idx.options.MaxSizeBytes)
+			bytesNeeded := (cacheSize - idx.options.MaxSizeBytes)
 			if idx.options.MaxSizeBytes > idx.options.MaxSizeBackoffBytes {
 				bytesNeeded += idx.options.MaxSizeBackoffBytes
 			}
@@ -359,7 +464,7 @@ func (idx *Index) reap(logger interface{}) {
 				i++
 			}
 		} else {
-			objectsNeeded := (idx.ObjectCount - idx.options.MaxSizeObjects)
+			objectsNeeded := (objectCount - idx.options.MaxSizeObjects)
 			if idx.options.MaxSizeObjects > idx.options.MaxSizeBackoffObjects {
 				objectsNeeded += idx.options.MaxSizeBackoffObjects
 			}
@@ -373,6 +478,7 @@ func (idx *Index) reap(logger interface{}) {
 
 		if len(removals) > 0 {
 			metrics.ObserveCacheEvent(idx.name, idx.cacheProvider, "eviction", evictionType)
+			metrics.ObserveCacheEvictions(idx.name, idx.cacheProvider, policy, evictionType, len(removals))
 			go idx.bulkRemoveFunc(removals)
 			idx.RemoveObjects(removals, true)
 			cacheChanged = true
@@ -381,13 +487,17 @@ func (idx *Index) reap(logger interface{}) {
 		tl.Debug(logger, "size-based cache eviction exercise completed",
 			tl.Pairs{
 				"reason":         evictionType,
-				"cacheSizeBytes": idx.CacheSize, "maxSizeBytes": idx.options.MaxSizeBytes,
-				"cacheSizeObjects": idx.ObjectCount, "maxSizeObjects": idx.options.MaxSizeObjects,
+				"cacheSizeBytes": idx.Size(), "maxSizeBytes": idx.options.MaxSizeBytes,
+				"cacheSizeObjects": idx.Count(), "maxSizeObjects": idx.options.MaxSizeObjects,
 			})
 
 	}
+	// access counts are aged after eviction selection, on every reap cycle
+	if policy == options.EvictionPolicyLFU {
+		idx.ageAccessCounts()
+	}
 	if cacheChanged {
-		idx.lastWrite = time.Now()
+		idx.touch()
 	}
 }
 
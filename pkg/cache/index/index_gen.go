@@ -336,6 +336,12 @@ func (z *Object) DecodeMsg(dc *msgp.Reader) (err error) {
 				err = msgp.WrapError(err, "Size")
 				return
 			}
+		case "access_count":
+			z.AccessCount, err = dc.ReadInt64()
+			if err != nil {
+				err = msgp.WrapError(err, "AccessCount")
+				return
+			}
 		case "value":
 			z.Value, err = dc.ReadBytes(z.Value)
 			if err != nil {
@@ -356,11 +362,11 @@ func (z *Object) DecodeMsg(dc *msgp.Reader) (err error) {
 // EncodeMsg implements msgp.Encodable
 func (z *Object) EncodeMsg(en *msgp.Writer) (err error) {
 	// omitempty: check for empty values
-	zb0001Len := uint32(6)
-	var zb0001Mask uint8 /* 6 bits */
+	zb0001Len := uint32(7)
+	var zb0001Mask uint8 /* 7 bits */
 	if z.Value == nil {
 		zb0001Len--
-		zb0001Mask |= 0x20
+		zb0001Mask |= 0x40
 	}
 	// variable map header, size zb0001Len
 	err = en.Append(0x80 | uint8(zb0001Len))
@@ -420,7 +426,17 @@ func (z *Object) EncodeMsg(en *msgp.Writer) (err error) {
 		err = msgp.WrapError(err, "Size")
 		return
 	}
-	if (zb0001Mask & 0x20) == 0 { // if not empty
+	// write "access_count"
+	err = en.Append(0xac, 0x61, 0x63, 0x63, 0x65, 0x73, 0x73, 0x5f, 0x63, 0x6f, 0x75, 0x6e, 0x74)
+	if err != nil {
+		return
+	}
+	err = en.WriteInt64(z.AccessCount)
+	if err != nil {
+		err = msgp.WrapError(err, "AccessCount")
+		return
+	}
+	if (zb0001Mask & 0x40) == 0 { // if not empty
 		// write "value"
 		err = en.Append(0xa5, 0x76, 0x61, 0x6c, 0x75, 0x65)
 		if err != nil {
@@ -439,11 +455,11 @@ func (z *Object) EncodeMsg(en *msgp.Writer) (err error) {
 func (z *Object) MarshalMsg(b []byte) (o []byte, err error) {
 	o = msgp.Require(b, z.Msgsize())
 	// omitempty: check for empty values
-	zb0001Len := uint32(6)
-	var zb0001Mask uint8 /* 6 bits */
+	zb0001Len := uint32(7)
+	var zb0001Mask uint8 /* 7 bits */
 	if z.Value == nil {
 		zb0001Len--
-		zb0001Mask |= 0x20
+		zb0001Mask |= 0x40
 	}
 	// variable map header, size zb0001Len
 	o = append(o, 0x80|uint8(zb0001Len))
@@ -465,7 +481,10 @@ func (z *Object) MarshalMsg(b []byte) (o []byte, err error) {
 	// string "size"
 	o = append(o, 0xa4, 0x73, 0x69, 0x7a, 0x65)
 	o = msgp.AppendInt64(o, z.Size)
-	if (zb0001Mask & 0x20) == 0 { // if not empty
+	// string "access_count"
+	o = append(o, 0xac, 0x61, 0x63, 0x63, 0x65, 0x73, 0x73, 0x5f, 0x63, 0x6f, 0x75, 0x6e, 0x74)
+	o = msgp.AppendInt64(o, z.AccessCount)
+	if (zb0001Mask & 0x40) == 0 { // if not empty
 		// string "value"
 		o = append(o, 0xa5, 0x76, 0x61, 0x6c, 0x75, 0x65)
 		o = msgp.AppendBytes(o, z.Value)
@@ -521,6 +540,12 @@ func (z *Object) UnmarshalMsg(bts []byte) (o []byte, err error) {
 				err = msgp.WrapError(err, "Size")
 				return
 			}
+		case "access_count":
+			z.AccessCount, bts, err = msgp.ReadInt64Bytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "AccessCount")
+				return
+			}
 		case "value":
 			z.Value, bts, err = msgp.ReadBytesBytes(bts, z.Value)
 			if err != nil {
@@ -541,6 +566,6 @@ func (z *Object) UnmarshalMsg(bts []byte) (o []byte, err error) {
 
 // Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
 func (z *Object) Msgsize() (s int) {
-	s = 1 + 4 + msgp.StringPrefixSize + len(z.Key) + 11 + msgp.TimeSize + 10 + msgp.TimeSize + 11 + msgp.TimeSize + 5 + msgp.Int64Size + 6 + msgp.BytesPrefixSize + len(z.Value)
+	s = 1 + 4 + msgp.StringPrefixSize + len(z.Key) + 11 + msgp.TimeSize + 10 + msgp.TimeSize + 11 + msgp.TimeSize + 5 + msgp.Int64Size + 13 + msgp.Int64Size + 6 + msgp.BytesPrefixSize + len(z.Value)
 	return
 }
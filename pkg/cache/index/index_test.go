@@ -18,6 +18,7 @@ package index
 
 import (
 	"sort"
+	"strconv"
 	"testing"
 	"time"
 
@@ -128,27 +129,27 @@ func TestReap(t *testing.T) {
 	// trigger size-based reap eviction of some elements
 	idx.reap(testLogger)
 
-	if _, ok := idx.Objects["test.1"]; ok {
+	if _, ok := idx.object("test.1"); ok {
 		t.Errorf("expected key %s to be missing", "test.1")
 	}
 
-	if _, ok := idx.Objects["test.2"]; ok {
+	if _, ok := idx.object("test.2"); ok {
 		t.Errorf("expected key %s to be missing", "test.2")
 	}
 
-	if _, ok := idx.Objects["test.3"]; ok {
+	if _, ok := idx.object("test.3"); ok {
 		t.Errorf("expected key %s to be missing", "test.3")
 	}
 
-	if _, ok := idx.Objects["test.4"]; ok {
+	if _, ok := idx.object("test.4"); ok {
 		t.Errorf("expected key %s to be missing", "test.4")
 	}
 
-	if _, ok := idx.Objects["test.5"]; ok {
+	if _, ok := idx.object("test.5"); ok {
 		t.Errorf("expected key %s to be missing", "test.5")
 	}
 
-	if _, ok := idx.Objects["test.6"]; !ok {
+	if _, ok := idx.object("test.6"); !ok {
 		t.Errorf("expected key %s to be present", "test.6")
 	}
 
@@ -164,11 +165,11 @@ func TestReap(t *testing.T) {
 
 	// only cache index should be left
 
-	if _, ok := idx.Objects["test.6"]; ok {
+	if _, ok := idx.object("test.6"); ok {
 		t.Errorf("expected key %s to be missing", "test.6")
 	}
 
-	if _, ok := idx.Objects["test.7"]; ok {
+	if _, ok := idx.object("test.7"); ok {
 		t.Errorf("expected key %s to be missing", "test.7")
 	}
 }
@@ -198,34 +199,35 @@ func TestUpdateObject(t *testing.T) {
 	idx := NewIndex("test", "test", nil, cacheConfig.Index, testBulkRemoveFunc, fakeFlusherFunc, testLogger)
 
 	idx.UpdateObject(&obj)
-	if _, ok := idx.Objects["test"]; ok {
+	if _, ok := idx.object("test"); ok {
 		t.Errorf("test object should be missing from index")
 	}
 
 	obj.Key = "test"
 
 	idx.UpdateObject(&obj)
-	if _, ok := idx.Objects["test"]; !ok {
+	if _, ok := idx.object("test"); !ok {
 		t.Errorf("test object missing from index")
 	}
 
 	// do it again to cover the index hit case
 	idx.UpdateObject(&obj)
-	if _, ok := idx.Objects["test"]; !ok {
+	if _, ok := idx.object("test"); !ok {
 		t.Errorf("test object missing from index")
 	}
 
-	idx.Objects["test"].LastAccess = time.Time{}
+	o, _ := idx.object("test")
+	o.LastAccess = time.Time{}
 	idx.UpdateObjectAccessTime("test")
 
-	if idx.Objects["test"].LastAccess.IsZero() {
+	if o.LastAccess.IsZero() {
 		t.Errorf("test object last access time is wrong")
 	}
 
 	obj = Object{Key: "test2", ReferenceValue: &testReferenceObject{}}
 
 	idx.UpdateObject(&obj)
-	if _, ok := idx.Objects["test2"]; !ok {
+	if _, ok := idx.object("test2"); !ok {
 		t.Errorf("test object missing from index")
 	}
 }
@@ -242,12 +244,12 @@ func TestRemoveObject(t *testing.T) {
 	idx := NewIndex("test", "test", nil, cacheConfig.Index, testBulkRemoveFunc, fakeFlusherFunc, testLogger)
 
 	idx.UpdateObject(&obj)
-	if _, ok := idx.Objects["test"]; !ok {
+	if _, ok := idx.object("test"); !ok {
 		t.Errorf("test object missing from index")
 	}
 
 	idx.RemoveObject("test")
-	if _, ok := idx.Objects["test"]; ok {
+	if _, ok := idx.object("test"); ok {
 		t.Errorf("test object should be missing from index")
 	}
 }
@@ -344,7 +346,36 @@ func TestRemoveObjects(t *testing.T) {
 	obj := &Object{Key: "test", Value: []byte("test_value")}
 	idx.UpdateObject(obj)
 	idx.RemoveObjects([]string{"test"}, false)
-	if _, ok := idx.Objects["test"]; ok {
+	if _, ok := idx.object("test"); ok {
 		t.Error("key should not be in map")
 	}
 }
+
+func TestShards(t *testing.T) {
+	o := io.New()
+	o.ReapInterval = 0
+	o.ShardCount = 4
+	idx := NewIndex("test", "test", nil, o, testBulkRemoveFunc, nil, testLogger)
+	for i := 0; i < 100; i++ {
+		idx.UpdateObject(&Object{Key: "test." + strconv.Itoa(i), Value: []byte("value")})
+	}
+	for i, sh := range idx.shards {
+		if len(sh.objects) == 0 {
+			t.Errorf("expected objects in shard %d", i)
+		}
+	}
+
+	// the serialized index is unsharded, and can be loaded with a different shard count
+	o.ShardCount = 3
+	idx2 := NewIndex("test", "test", idx.ToBytes(), o, testBulkRemoveFunc, nil, testLogger)
+	if idx2.Count() != 100 || idx2.Size() != 500 || idx2.Objects != nil {
+		t.Errorf("unexpected index %d objects %d bytes", idx2.Count(), idx2.Size())
+	}
+	if _, ok := idx2.object("test.42"); !ok {
+		t.Error("expected object to be loaded")
+	}
+
+	if s := ShardFor("test", 1); s != 0 {
+		t.Errorf("expected %d got %d", 0, s)
+	}
+}
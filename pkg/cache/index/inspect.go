@@ -0,0 +1,104 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package index
+
+import (
+	"errors"
+	"sort"
+)
+
+// Orders in which TopObjects can list objects
+const (
+	// OrderSize lists the largest objects first
+	OrderSize = "size"
+	// OrderAccessCount lists the most-accessed objects first
+	OrderAccessCount = "access_count"
+	// OrderLastAccess lists the most-recently accessed objects first
+	OrderLastAccess = "last_access"
+	// OrderEviction lists the objects in the order the eviction policy would evict them
+	OrderEviction = "eviction"
+)
+
+// ErrInvalidOrder is returned when the order provided to TopObjects is not supported
+var ErrInvalidOrder = errors.New("invalid order: must be size, access_count, last_access or eviction")
+
+// Lookup returns a copy of the metadata for the object with the provided key
+func (idx *Index) Lookup(key string) (Object, bool) {
+	sh := idx.shard(key)
+	sh.mtx.Lock()
+	defer sh.mtx.Unlock()
+	o, ok := sh.objects[key]
+	if !ok {
+		return Object{}, false
+	}
+	return Object{
+		Key:         o.Key,
+		Expiration:  o.Expiration,
+		LastWrite:   o.LastWrite,
+		LastAccess:  o.LastAccess,
+		Size:        o.Size,
+		AccessCount: o.AccessCount,
+	}, true
+}
+
+// TopObjects returns copies of the metadata for up to n objects in the provided
+// order, or for all objects when n is less than 1
+func (idx *Index) TopObjects(order string, n int) ([]Object, error) {
+	objects := make(objectsAtime, 0, idx.Count())
+	for _, sh := range idx.shards {
+		sh.mtx.Lock()
+		for _, o := range sh.objects {
+			objects = append(objects, &Object{
+				Key:         o.Key,
+				Expiration:  o.Expiration,
+				LastWrite:   o.LastWrite,
+				LastAccess:  o.LastAccess,
+				Size:        o.Size,
+				AccessCount: o.AccessCount,
+			})
+		}
+		sh.mtx.Unlock()
+	}
+	switch order {
+	case OrderSize:
+		sort.SliceStable(objects, func(i, j int) bool {
+			return objects[i].Size > objects[j].Size
+		})
+	case OrderAccessCount:
+		sort.SliceStable(objects, func(i, j int) bool {
+			return objects[i].AccessCount > objects[j].AccessCount
+		})
+	case OrderLastAccess:
+		sort.SliceStable(objects, func(i, j int) bool {
+			return objects[i].LastAccess.After(objects[j].LastAccess)
+		})
+	case OrderEviction:
+		idx.mtx.Lock()
+		policy := idx.evictionPolicy()
+		idx.mtx.Unlock()
+		sortForEviction(policy, objects)
+	default:
+		return nil, ErrInvalidOrder
+	}
+	if n > 0 && n < len(objects) {
+		objects = objects[:n]
+	}
+	out := make([]Object, len(objects))
+	for i, o := range objects {
+		out[i] = *o
+	}
+	return out, nil
+}
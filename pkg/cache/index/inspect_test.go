@@ -0,0 +1,83 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package index
+
+import (
+	"testing"
+
+	io "github.com/trickstercache/trickster/v2/pkg/cache/index/options"
+)
+
+func TestLookup(t *testing.T) {
+	o := io.New()
+	o.ReapInterval = 0
+	idx := NewIndex("test", "test", nil, o, testBulkRemoveFunc, nil, testLogger)
+	if _, ok := idx.Lookup("missing"); ok {
+		t.Error("expected missing object")
+	}
+	idx.UpdateObject(&Object{Key: "key", Value: []byte("value")})
+	idx.UpdateObjectAccessTime("key")
+	obj, ok := idx.Lookup("key")
+	if !ok || obj.Size != 5 || obj.AccessCount != 1 || obj.LastAccess.IsZero() {
+		t.Errorf("unexpected object %+v", obj)
+	}
+}
+
+func TestTopObjects(t *testing.T) {
+	o := io.New()
+	o.ReapInterval = 0
+	o.EvictionPolicy = io.EvictionPolicyLFU
+	idx := NewIndex("test", "test", nil, o, testBulkRemoveFunc, nil, testLogger)
+	idx.UpdateObject(&Object{Key: "small", Value: []byte("v")})
+	idx.UpdateObject(&Object{Key: "large", Value: []byte("value value")})
+	idx.UpdateObject(&Object{Key: "medium", Value: []byte("value")})
+	for i := 0; i < 3; i++ {
+		idx.UpdateObjectAccessTime("small")
+	}
+	idx.UpdateObjectAccessTime("medium")
+
+	tests := []struct {
+		order    string
+		n        int
+		expected []string
+	}{
+		{OrderSize, 0, []string{"large", "medium", "small"}},
+		{OrderSize, 2, []string{"large", "medium"}},
+		{OrderAccessCount, 1, []string{"small"}},
+		{OrderLastAccess, 1, []string{"medium"}},
+		{OrderEviction, 1, []string{"large"}},
+	}
+	for _, test := range tests {
+		t.Run(test.order, func(t *testing.T) {
+			objects, err := idx.TopObjects(test.order, test.n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(objects) != len(test.expected) {
+				t.Fatalf("expected %d got %d", len(test.expected), len(objects))
+			}
+			for i, k := range test.expected {
+				if objects[i].Key != k {
+					t.Errorf("expected %s got %s", k, objects[i].Key)
+				}
+			}
+		})
+	}
+
+	if _, err := idx.TopObjects("invalid", 0); err != ErrInvalidOrder {
+		t.Errorf("expected %v got %v", ErrInvalidOrder, err)
+	}
+}
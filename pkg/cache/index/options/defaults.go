@@ -29,4 +29,8 @@ const (
 	DefaultMaxSizeObjects = 0
 	// DefaultMaxSizeBackoffObjects is the default Max Cache Backoff Object Count
 	DefaultMaxSizeBackoffObjects = 100
+	// DefaultShardCount is the default number of independently-locked shards in the Cache Index
+	DefaultShardCount = 16
+	// DefaultEvictionPolicy is the default policy for selecting objects to evict
+	DefaultEvictionPolicy = EvictionPolicyLRU
 )
@@ -17,9 +17,35 @@
 package options
 
 import (
+	"errors"
 	"time"
 )
 
+// Eviction Policies select the objects to evict when the cache exceeds its max size
+const (
+	// EvictionPolicyLRU evicts the least-recently accessed objects first
+	EvictionPolicyLRU = "lru"
+	// EvictionPolicyLFU evicts the least-frequently accessed objects first. Access
+	// counts are halved on each reap cycle, so formerly-popular objects age out
+	EvictionPolicyLFU = "lfu"
+	// EvictionPolicySLRU is a segmented LRU, which evicts objects in the probationary
+	// segment (not accessed since they were written) before those in the protected
+	// segment, each in least-recently accessed order, so that a scan of one-time
+	// queries can't flush frequently-used objects from the cache
+	EvictionPolicySLRU = "slru"
+	// EvictionPolicyTTL evicts the objects closest to expiration first
+	EvictionPolicyTTL = "ttl"
+)
+
+var (
+	// ErrInvalidEvictionPolicy is returned when the eviction policy is not supported
+	ErrInvalidEvictionPolicy = errors.New("invalid eviction_policy: must be lru, lfu, slru or ttl")
+	// ErrInvalidShardCount is returned when the shard count is negative
+	ErrInvalidShardCount = errors.New("invalid shard_count: must not be negative")
+	// ErrInvalidScrubInterval is returned when the scrub interval is negative
+	ErrInvalidScrubInterval = errors.New("invalid scrub_interval_ms: must not be negative")
+)
+
 // Options defines the operation of the Cache Indexer
 type Options struct {
 	// ReapIntervalMS defines how long the Cache Index reaper sleeps between reap cycles
@@ -38,6 +64,15 @@ type Options struct {
 	// MaxSizeBackoffObjects indicates how far under max_size_objects the cache size must
 	// be to complete object-size-based eviction exercise.
 	MaxSizeBackoffObjects int64 `json:"max_size_backoff_objects,omitempty"`
+	// ShardCount is the number of independently-locked shards across which the Index,
+	// and the memory cache's objects, are partitioned. The size limits apply across all shards
+	ShardCount int `json:"shard_count,omitempty"`
+	// EvictionPolicy selects the objects evicted by a size-based eviction exercise
+	EvictionPolicy string `json:"eviction_policy,omitempty"`
+	// ScrubIntervalMS sets how often a filesystem, bbolt or badger cache verifies the
+	// checksums of its stored objects, removing those that are corrupt, and reclaims
+	// stored objects that are not tracked by the Index. 0 disables the scrubber
+	ScrubIntervalMS int `json:"scrub_interval_ms,omitempty"`
 
 	ReapInterval  time.Duration `json:"-"`
 	FlushInterval time.Duration `json:"-"`
@@ -54,6 +89,8 @@ func New() *Options {
 		MaxSizeBackoffBytes:   DefaultMaxSizeBackoffBytes,
 		MaxSizeObjects:        DefaultMaxSizeObjects,
 		MaxSizeBackoffObjects: DefaultMaxSizeBackoffObjects,
+		ShardCount:            DefaultShardCount,
+		EvictionPolicy:        DefaultEvictionPolicy,
 	}
 }
 
@@ -69,5 +106,23 @@ func (o *Options) Equal(o2 *Options) bool {
 		o.MaxSizeBytes == o2.MaxSizeBytes &&
 		o.MaxSizeBackoffBytes == o2.MaxSizeBackoffBytes &&
 		o.MaxSizeObjects == o2.MaxSizeObjects &&
-		o.MaxSizeBackoffObjects == o2.MaxSizeBackoffObjects
+		o.MaxSizeBackoffObjects == o2.MaxSizeBackoffObjects &&
+		o.ShardCount == o2.ShardCount &&
+		o.EvictionPolicy == o2.EvictionPolicy &&
+		o.ScrubIntervalMS == o2.ScrubIntervalMS
+}
+
+// Validate returns an error if the Options are invalid
+func (o *Options) Validate() error {
+	if o.ShardCount < 0 {
+		return ErrInvalidShardCount
+	}
+	if o.ScrubIntervalMS < 0 {
+		return ErrInvalidScrubInterval
+	}
+	switch o.EvictionPolicy {
+	case "", EvictionPolicyLRU, EvictionPolicyLFU, EvictionPolicySLRU, EvictionPolicyTTL:
+		return nil
+	}
+	return ErrInvalidEvictionPolicy
 }
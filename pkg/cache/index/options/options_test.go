@@ -36,3 +36,28 @@ func TestEqual(t *testing.T) {
 		t.Error("expected true")
 	}
 }
+
+func TestValidate(t *testing.T) {
+	o := New()
+	for _, p := range []string{"", EvictionPolicyLRU, EvictionPolicyLFU,
+		EvictionPolicySLRU, EvictionPolicyTTL} {
+		o.EvictionPolicy = p
+		if err := o.Validate(); err != nil {
+			t.Errorf("unexpected error for %q: %v", p, err)
+		}
+	}
+	o.EvictionPolicy = "mru"
+	if err := o.Validate(); err != ErrInvalidEvictionPolicy {
+		t.Errorf("expected %v got %v", ErrInvalidEvictionPolicy, err)
+	}
+	o.EvictionPolicy = EvictionPolicyLRU
+	o.ShardCount = -1
+	if err := o.Validate(); err != ErrInvalidShardCount {
+		t.Errorf("expected %v got %v", ErrInvalidShardCount, err)
+	}
+	o.ShardCount = DefaultShardCount
+	o.ScrubIntervalMS = -1
+	if err := o.Validate(); err != ErrInvalidScrubInterval {
+		t.Errorf("expected %v got %v", ErrInvalidScrubInterval, err)
+	}
+}
@@ -0,0 +1,226 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+// Package inspect reports on the objects held by Trickster caches, and on how the
+// caching engines would derive the cache key for a request, without serving it
+package inspect
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/backends"
+	"github.com/trickstercache/trickster/v2/pkg/cache"
+	"github.com/trickstercache/trickster/v2/pkg/cache/index"
+	"github.com/trickstercache/trickster/v2/pkg/cache/invalidation"
+	tctx "github.com/trickstercache/trickster/v2/pkg/proxy/context"
+)
+
+// DetailNotCached describes a request that the backend would serve without caching
+const DetailNotCached = "the request is proxied without caching, or does not match a cacheable path"
+
+var (
+	// ErrNoIndex is returned when listing the keys of a cache that does not maintain an index
+	ErrNoIndex = errors.New("the cache does not maintain an index of its keys")
+	// ErrUnknownBackend is returned when inspecting a request for an unknown backend
+	ErrUnknownBackend = errors.New("unknown backend")
+	// ErrInvalidURL is returned when the inspected request URL can't be parsed
+	ErrInvalidURL = errors.New("invalid request url")
+)
+
+// KeyInfo describes a cached object, per the cache's index
+type KeyInfo struct {
+	Key            string     `json:"key"`
+	SizeBytes      int64      `json:"size_bytes"`
+	Expiration     *time.Time `json:"expiration,omitempty"`
+	TTLRemainingMS int64      `json:"ttl_remaining_ms,omitempty"`
+	Expired        bool       `json:"expired,omitempty"`
+	LastWrite      *time.Time `json:"last_write,omitempty"`
+	LastAccess     *time.Time `json:"last_access,omitempty"`
+	AccessCount    int64      `json:"access_count"`
+}
+
+// Report describes how a backend would cache a request, and what is cached for it
+type Report struct {
+	Backend  string `json:"backend"`
+	Method   string `json:"method"`
+	URL      string `json:"url"`
+	Cache    string `json:"cache,omitempty"`
+	Provider string `json:"provider,omitempty"`
+	// Engine is the caching engine that would serve the request, and is empty when
+	// the request would not be cached
+	Engine string `json:"engine,omitempty"`
+	Key    string `json:"key,omitempty"`
+	Exists bool   `json:"exists"`
+	// Index is the index metadata for the cached object, for caches that maintain an index
+	Index *KeyInfo `json:"index,omitempty"`
+	// SizeBytes is the size of the cached object as stored
+	SizeBytes     int64       `json:"size_bytes,omitempty"`
+	StatusCode    int         `json:"status_code,omitempty"`
+	Headers       http.Header `json:"headers,omitempty"`
+	CachingPolicy interface{} `json:"caching_policy,omitempty"`
+	// Extents and VolatileExtents are the time ranges held by a cached timeseries
+	Extents         []Extent `json:"extents,omitempty"`
+	VolatileExtents []Extent `json:"volatile_extents,omitempty"`
+	Detail          string   `json:"detail,omitempty"`
+}
+
+// Extent is a time range held by a cached timeseries
+type Extent struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// indexer is implemented by caches whose objects are tracked by an Index
+type indexer interface {
+	CacheIndex() *index.Index
+}
+
+// IndexOf returns the Index of the cache, or nil if the cache does not maintain one
+func IndexOf(c cache.Cache) *index.Index {
+	if ix, ok := invalidation.Unwrap(c).(indexer); ok {
+		return ix.CacheIndex()
+	}
+	return nil
+}
+
+// NewKeyInfo returns the KeyInfo for the index object, as of the provided time
+func NewKeyInfo(o index.Object, now time.Time) KeyInfo {
+	ki := KeyInfo{Key: o.Key, SizeBytes: o.Size, AccessCount: o.AccessCount}
+	if !o.Expiration.IsZero() {
+		exp := o.Expiration
+		ki.Expiration = &exp
+		ki.TTLRemainingMS = exp.Sub(now).Milliseconds()
+		ki.Expired = !exp.After(now)
+	}
+	if !o.LastWrite.IsZero() {
+		lw := o.LastWrite
+		ki.LastWrite = &lw
+	}
+	if !o.LastAccess.IsZero() {
+		la := o.LastAccess
+		ki.LastAccess = &la
+	}
+	return ki
+}
+
+// Lookup returns the KeyInfo for the key in the cache's index, or nil if the cache
+// does not maintain an index or the key is not indexed
+func Lookup(c cache.Cache, key string) *KeyInfo {
+	idx := IndexOf(c)
+	if idx == nil {
+		return nil
+	}
+	o, ok := idx.Lookup(key)
+	if !ok {
+		return nil
+	}
+	ki := NewKeyInfo(o, time.Now())
+	return &ki
+}
+
+// TopKeys returns up to n of the cache's keys in the provided index.Order*
+func TopKeys(c cache.Cache, order string, n int) ([]KeyInfo, error) {
+	idx := IndexOf(c)
+	if idx == nil {
+		return nil, ErrNoIndex
+	}
+	objects, err := idx.TopObjects(order, n)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	out := make([]KeyInfo, len(objects))
+	for i, o := range objects {
+		out[i] = NewKeyInfo(o, now)
+	}
+	return out, nil
+}
+
+// Request reports how the named backend would cache a request for the provided
+// method, URL and headers. The request is routed through the backend's handlers,
+// which derive the cache key and look up the cached object, but it is never sent
+// upstream. The URL may be absolute, and its path may include the backend name prefix
+func Request(clients backends.Backends, backendName, method, rawURL string,
+	h http.Header,
+) (*Report, error) {
+	client := clients.Get(backendName)
+	if client == nil || client.Router() == nil {
+		return nil, ErrUnknownBackend
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidURL, err)
+	}
+	if method == "" {
+		method = http.MethodGet
+	}
+	path := u.Path
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	if p := "/" + backendName; strings.HasPrefix(path, p+"/") {
+		path = strings.TrimPrefix(path, p)
+	}
+	r, err := http.NewRequest(method, (&url.URL{Path: path, RawQuery: u.RawQuery}).String(), nil)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidURL, err)
+	}
+	if u.Host != "" {
+		r.Host = u.Host
+	}
+	for k, v := range h {
+		r.Header[k] = v
+	}
+	rpt := &Report{Backend: backendName, Method: method, URL: r.URL.String()}
+	if o := client.Configuration(); o != nil {
+		rpt.Cache = o.CacheName
+	}
+	r = r.WithContext(tctx.WithCacheInspection(r.Context(), rpt))
+	client.Router().ServeHTTP(&discardWriter{h: make(http.Header)}, r)
+	if rpt.Engine == "" && rpt.Detail == "" {
+		rpt.Detail = DetailNotCached
+	}
+	return rpt, nil
+}
+
+// FromRequest returns the request's cache inspection Report, or nil if the
+// request is not a cache inspection
+func FromRequest(r *http.Request) *Report {
+	if r == nil {
+		return nil
+	}
+	rpt, _ := tctx.CacheInspection(r.Context()).(*Report)
+	return rpt
+}
+
+// discardWriter is an http.ResponseWriter that discards the response to an
+// inspected request
+type discardWriter struct {
+	h http.Header
+}
+
+func (dw *discardWriter) Header() http.Header {
+	return dw.h
+}
+
+func (dw *discardWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (dw *discardWriter) WriteHeader(int) {}
@@ -0,0 +1,121 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package inspect
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/backends"
+	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache/index"
+	"github.com/trickstercache/trickster/v2/pkg/cache/invalidation"
+	"github.com/trickstercache/trickster/v2/pkg/cache/memory"
+	"github.com/trickstercache/trickster/v2/pkg/cache/redis"
+)
+
+func TestRequest(t *testing.T) {
+	var served *http.Request
+	router := http.NewServeMux()
+	router.HandleFunc("/api/v1/query", func(w http.ResponseWriter, r *http.Request) {
+		served = r
+		if rpt := FromRequest(r); rpt != nil {
+			rpt.Engine = "test"
+			rpt.Key = "key"
+		}
+	})
+	o := bo.New()
+	o.CacheName = "default"
+	b, err := backends.New("prom", o, nil, router, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clients := backends.Backends{"prom": b}
+
+	if _, err = Request(clients, "missing", "", "/api/v1/query", nil); err != ErrUnknownBackend {
+		t.Errorf("expected %v got %v", ErrUnknownBackend, err)
+	}
+	if _, err = Request(clients, "prom", "", "http://[::1", nil); !errors.Is(err, ErrInvalidURL) {
+		t.Errorf("expected %v got %v", ErrInvalidURL, err)
+	}
+
+	for _, u := range []string{
+		"/api/v1/query?query=up",
+		"/prom/api/v1/query?query=up",
+		"http://trickster:8480/prom/api/v1/query?query=up",
+	} {
+		rpt, err := Request(clients, "prom", "", u, http.Header{"Authorization": {"test"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rpt.Engine != "test" || rpt.Key != "key" || rpt.Cache != "default" ||
+			rpt.Method != http.MethodGet || rpt.URL != "/api/v1/query?query=up" {
+			t.Errorf("unexpected report %+v", rpt)
+		}
+		if served.Header.Get("Authorization") != "test" {
+			t.Error("expected request headers")
+		}
+	}
+
+	rpt, err := Request(clients, "prom", http.MethodPost, "/api/v1/series", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rpt.Engine != "" || rpt.Detail != DetailNotCached {
+		t.Errorf("unexpected report %+v", rpt)
+	}
+}
+
+func TestTopKeys(t *testing.T) {
+	c, err := memory.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.Store("key1", []byte("value"), time.Minute)
+	c.Store("key2", []byte("value value"), 0)
+
+	// wrapped caches are unwrapped to their index
+	wc := invalidation.Wrap("default", c)
+	keys, err := TopKeys(wc, index.OrderSize, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0].Key != "key2" || keys[0].Expiration != nil ||
+		keys[1].Expiration == nil || keys[1].TTLRemainingMS <= 0 || keys[1].Expired {
+		t.Errorf("unexpected keys %+v", keys)
+	}
+	if _, err = TopKeys(wc, "invalid", 0); err != index.ErrInvalidOrder {
+		t.Errorf("expected %v got %v", index.ErrInvalidOrder, err)
+	}
+
+	if ki := Lookup(wc, "key1"); ki == nil || ki.SizeBytes != 5 {
+		t.Errorf("unexpected key info %+v", ki)
+	}
+	if ki := Lookup(wc, "missing"); ki != nil {
+		t.Errorf("unexpected key info %+v", ki)
+	}
+
+	rc := &redis.Cache{}
+	if _, err = TopKeys(rc, index.OrderSize, 0); err != ErrNoIndex {
+		t.Errorf("expected %v got %v", ErrNoIndex, err)
+	}
+	if ki := Lookup(rc, "key1"); ki != nil {
+		t.Errorf("unexpected key info %+v", ki)
+	}
+}
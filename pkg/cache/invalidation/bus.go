@@ -0,0 +1,119 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+// Package invalidation provides a bus for exchanging cache invalidation events
+// between Trickster instances, so that keys removed or purged by one instance
+// are also removed from the instance-local caches of the others
+package invalidation
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Event describes cache keys, or a cache key prefix, to be invalidated
+type Event struct {
+	// Source identifies the Trickster instance that published the Event
+	Source string `json:"source"`
+	// Cache is the name of the cache the Event applies to
+	Cache string `json:"cache"`
+	// Keys is the list of cache keys to invalidate
+	Keys []string `json:"keys,omitempty"`
+	// Prefix, when set, invalidates all cache keys beginning with the Prefix
+	Prefix string `json:"prefix,omitempty"`
+}
+
+// Bus is a transport for invalidation Events
+type Bus interface {
+	// Publish sends the Event to the Bus's subscribers
+	Publish(*Event) error
+	// Subscribe registers the function to be called for each received Event,
+	// and returns a function that cancels the subscription
+	Subscribe(func(*Event)) (func(), error)
+}
+
+// LocalBus is an in-process Bus that delivers Events synchronously
+type LocalBus struct {
+	mtx         sync.RWMutex
+	nextID      int
+	subscribers map[int]func(*Event)
+}
+
+// NewLocalBus returns a new LocalBus
+func NewLocalBus() *LocalBus {
+	return &LocalBus{subscribers: make(map[int]func(*Event))}
+}
+
+// Publish delivers the Event to each of the LocalBus's subscribers
+func (b *LocalBus) Publish(e *Event) error {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+	for _, f := range b.subscribers {
+		f(e)
+	}
+	return nil
+}
+
+// Subscribe registers the function to be called for each published Event
+func (b *LocalBus) Subscribe(f func(*Event)) (func(), error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	id := b.nextID
+	b.nextID++
+	b.subscribers[id] = f
+	return func() {
+		b.mtx.Lock()
+		delete(b.subscribers, id)
+		b.mtx.Unlock()
+	}, nil
+}
+
+// NATSBus is a Bus that exchanges Events over a NATS subject
+type NATSBus struct {
+	nc      *nats.Conn
+	subject string
+}
+
+// NewNATSBus returns a new NATSBus using the provided connection and subject
+func NewNATSBus(nc *nats.Conn, subject string) *NATSBus {
+	return &NATSBus{nc: nc, subject: subject}
+}
+
+// Publish publishes the Event to the NATSBus's subject
+func (b *NATSBus) Publish(e *Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return b.nc.Publish(b.subject, data)
+}
+
+// Subscribe subscribes to the NATSBus's subject, calling the function for each
+// Event received. Messages that are not valid Events are ignored
+func (b *NATSBus) Subscribe(f func(*Event)) (func(), error) {
+	sub, err := b.nc.Subscribe(b.subject, func(m *nats.Msg) {
+		e := &Event{}
+		if err := json.Unmarshal(m.Data, e); err != nil {
+			return
+		}
+		f(e)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return func() { sub.Unsubscribe() }, nil
+}
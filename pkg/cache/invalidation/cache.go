@@ -0,0 +1,88 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package invalidation
+
+import (
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/cache"
+	"github.com/trickstercache/trickster/v2/pkg/cache/status"
+)
+
+// Cache wraps a cache.Cache, publishing its key removals to the invalidation Bus
+type Cache struct {
+	cache.Cache
+	name string
+}
+
+// memoryCache wraps a cache.MemoryCache, preserving its reference storage methods
+type memoryCache struct {
+	*Cache
+	mc cache.MemoryCache
+}
+
+// Wrap returns the cache wrapped so that its removals are published to the invalidation
+// Bus. Wrapping an already-wrapped cache returns it unchanged
+func Wrap(name string, c cache.Cache) cache.Cache {
+	switch c.(type) {
+	case nil, *Cache, *memoryCache:
+		return c
+	}
+	w := &Cache{Cache: c, name: name}
+	if mc, ok := c.(cache.MemoryCache); ok {
+		return &memoryCache{Cache: w, mc: mc}
+	}
+	return w
+}
+
+// Unwrap returns the cache underlying a wrapped cache, or the cache itself if not wrapped
+func Unwrap(c cache.Cache) cache.Cache {
+	switch w := c.(type) {
+	case *Cache:
+		return w.Cache
+	case *memoryCache:
+		return w.Cache.Cache
+	}
+	return c
+}
+
+// Remove removes the key from the cache and publishes the invalidation
+func (c *Cache) Remove(cacheKey string) {
+	c.Cache.Remove(cacheKey)
+	Publish(&Event{Cache: c.name, Keys: []string{cacheKey}})
+}
+
+// BulkRemove removes the keys from the cache and publishes the invalidation
+func (c *Cache) BulkRemove(cacheKeys []string) {
+	c.Cache.BulkRemove(cacheKeys)
+	if len(cacheKeys) > 0 {
+		Publish(&Event{Cache: c.name, Keys: cacheKeys})
+	}
+}
+
+// StoreReference stores an object directly to the memory cache
+func (c *memoryCache) StoreReference(cacheKey string, data cache.ReferenceObject,
+	ttl time.Duration,
+) error {
+	return c.mc.StoreReference(cacheKey, data, ttl)
+}
+
+// RetrieveReference retrieves an object directly from the memory cache
+func (c *memoryCache) RetrieveReference(cacheKey string, allowExpired bool) (interface{},
+	status.LookupStatus, error,
+) {
+	return c.mc.RetrieveReference(cacheKey, allowExpired)
+}
@@ -0,0 +1,142 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package invalidation
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+
+	"github.com/trickstercache/trickster/v2/pkg/cache"
+	"github.com/trickstercache/trickster/v2/pkg/cache/metrics"
+	"github.com/trickstercache/trickster/v2/pkg/cache/providers"
+	"github.com/trickstercache/trickster/v2/pkg/cache/tiered"
+)
+
+// prefixRemover is implemented by caches that can remove keys by prefix
+type prefixRemover interface {
+	RemoveByPrefix(string) int
+}
+
+var (
+	mtx         sync.RWMutex
+	bus         Bus
+	unsubscribe func()
+	targets     map[string]cache.Cache
+	source      = newSourceID()
+)
+
+func newSourceID() string {
+	hn, _ := os.Hostname()
+	return fmt.Sprintf("%s.%d.%x", hn, os.Getpid(), rand.Uint64())
+}
+
+// Start sets the Bus over which invalidations are published and received for
+// the provided caches, replacing any previously started Bus. Passing a nil Bus
+// disables invalidation
+func Start(b Bus, caches map[string]cache.Cache) error {
+	mtx.Lock()
+	defer mtx.Unlock()
+	if unsubscribe != nil {
+		unsubscribe()
+		unsubscribe = nil
+	}
+	bus = nil
+	targets = make(map[string]cache.Cache, len(caches))
+	for k, c := range caches {
+		targets[k] = Unwrap(c)
+	}
+	if b == nil {
+		return nil
+	}
+	u, err := b.Subscribe(apply)
+	if err != nil {
+		return err
+	}
+	bus = b
+	unsubscribe = u
+	return nil
+}
+
+// Stop stops publishing and receiving invalidations
+func Stop() {
+	Start(nil, nil)
+}
+
+// Publish publishes the Event to the active Bus, if any
+func Publish(e *Event) {
+	mtx.RLock()
+	b := bus
+	c := targets[e.Cache]
+	mtx.RUnlock()
+	if b == nil {
+		return
+	}
+	e.Source = source
+	if b.Publish(e) == nil && c != nil {
+		metrics.ObserveCacheEvent(e.Cache, c.Configuration().Provider, "invalidation", "published")
+	}
+}
+
+// InvalidatePrefix removes all keys beginning with prefix from the named local cache,
+// and publishes the invalidation to the other instances
+func InvalidatePrefix(cacheName, prefix string) {
+	if prefix == "" {
+		return
+	}
+	e := &Event{Cache: cacheName, Prefix: prefix}
+	mtx.RLock()
+	c := targets[cacheName]
+	mtx.RUnlock()
+	if pr, ok := c.(prefixRemover); ok {
+		pr.RemoveByPrefix(prefix)
+	}
+	Publish(e)
+}
+
+// apply applies an Event received from another instance to the local cache
+func apply(e *Event) {
+	if e == nil || e.Source == source {
+		return
+	}
+	mtx.RLock()
+	c, ok := targets[e.Cache]
+	mtx.RUnlock()
+	// the shared L2 of a tiered cache has already been updated by the publishing
+	// instance, so only the local L1 needs the invalidation
+	if tc, isTiered := c.(*tiered.Cache); isTiered {
+		c = tc.L1
+	}
+	// shared caches have already been updated by the publishing instance
+	if !ok || c == nil || !appliesInvalidations(c) {
+		return
+	}
+	for _, k := range e.Keys {
+		c.Remove(k)
+	}
+	if e.Prefix != "" {
+		if pr, ok := c.(prefixRemover); ok {
+			pr.RemoveByPrefix(e.Prefix)
+		}
+	}
+	metrics.ObserveCacheEvent(e.Cache, c.Configuration().Provider, "invalidation", "applied")
+}
+
+func appliesInvalidations(c cache.Cache) bool {
+	o := c.Configuration()
+	return o != nil && o.ProviderID == providers.Memory
+}
@@ -0,0 +1,151 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package invalidation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/cache"
+	"github.com/trickstercache/trickster/v2/pkg/cache/memory"
+	co "github.com/trickstercache/trickster/v2/pkg/cache/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache/providers"
+	"github.com/trickstercache/trickster/v2/pkg/cache/tiered"
+	"github.com/trickstercache/trickster/v2/pkg/locks"
+)
+
+func newTestCache(t *testing.T) *memory.Cache {
+	mc := &memory.Cache{Name: "test", Config: co.New()}
+	mc.SetLocker(locks.NewNamedLocker())
+	if err := mc.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	return mc
+}
+
+func TestWrap(t *testing.T) {
+	mc := newTestCache(t)
+	w := Wrap("test", mc)
+	if _, ok := w.(cache.MemoryCache); !ok {
+		t.Error("expected wrapped memory cache to implement MemoryCache")
+	}
+	if w2 := Wrap("test", w); w2 != w {
+		t.Error("expected wrapping to be idempotent")
+	}
+	if u := Unwrap(w); u != mc {
+		t.Error("expected unwrapped memory cache")
+	}
+	if u := Unwrap(mc); u != mc {
+		t.Error("expected unwrapped memory cache")
+	}
+	if Wrap("test", nil) != nil {
+		t.Error("expected nil")
+	}
+}
+
+func TestLocalBusInvalidation(t *testing.T) {
+	defer Stop()
+
+	// the local instance's cache, and a cache standing in for another instance's
+	local := newTestCache(t)
+	remote := newTestCache(t)
+	wl := Wrap("test", local)
+
+	b := NewLocalBus()
+	if err := Start(b, map[string]cache.Cache{"test": wl}); err != nil {
+		t.Fatal(err)
+	}
+
+	var received []*Event
+	unsub, _ := b.Subscribe(func(e *Event) {
+		received = append(received, e)
+		// simulate delivery to the other instance
+		if e.Cache == "test" {
+			for _, k := range e.Keys {
+				remote.Remove(k)
+			}
+			if e.Prefix != "" {
+				remote.RemoveByPrefix(e.Prefix)
+			}
+		}
+	})
+	defer unsub()
+
+	for _, c := range []cache.Cache{local, remote} {
+		c.Store("a.dpc.1", []byte("1"), time.Minute)
+		c.Store("a.dpc.2", []byte("2"), time.Minute)
+		c.Store("b.dpc.3", []byte("3"), time.Minute)
+	}
+
+	wl.Remove("a.dpc.1")
+	if len(received) != 1 || received[0].Source != source || received[0].Keys[0] != "a.dpc.1" {
+		t.Fatalf("unexpected events %v", received)
+	}
+	if _, _, err := remote.Retrieve("a.dpc.1", false); err == nil {
+		t.Error("expected key to be invalidated in remote cache")
+	}
+
+	InvalidatePrefix("test", "a.")
+	if _, _, err := local.Retrieve("a.dpc.2", false); err == nil {
+		t.Error("expected key to be invalidated in local cache")
+	}
+	if _, _, err := remote.Retrieve("a.dpc.2", false); err == nil {
+		t.Error("expected key to be invalidated in remote cache")
+	}
+
+	// events from other instances are applied to the local cache
+	b.Publish(&Event{Source: "other", Cache: "test", Keys: []string{"b.dpc.3"}})
+	if _, _, err := local.Retrieve("b.dpc.3", false); err == nil {
+		t.Error("expected key to be invalidated in local cache")
+	}
+
+	// events for unknown caches are ignored
+	b.Publish(&Event{Source: "other", Cache: "unknown", Keys: []string{"x"}})
+
+	// once stopped, removals are no longer published
+	Stop()
+	n := len(received)
+	wl.BulkRemove([]string{"b.dpc.3"})
+	if len(received) != n {
+		t.Error("expected no events after stop")
+	}
+}
+
+func TestTieredInvalidation(t *testing.T) {
+	defer Stop()
+
+	l1, l2 := newTestCache(t), newTestCache(t)
+	tc := &tiered.Cache{Name: "test", Config: co.New(), L1: l1, L2: l2}
+	tc.Config.ProviderID = providers.Tiered
+	if err := tc.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	b := NewLocalBus()
+	if err := Start(b, map[string]cache.Cache{"test": Wrap("test", tc)}); err != nil {
+		t.Fatal(err)
+	}
+
+	tc.Store("a.dpc.1", []byte("1"), time.Minute)
+	// events from other instances are only applied to the local L1
+	b.Publish(&Event{Source: "other", Cache: "test", Keys: []string{"a.dpc.1"}})
+	if _, _, err := l1.Retrieve("a.dpc.1", false); err == nil {
+		t.Error("expected key to be invalidated in l1")
+	}
+	if _, _, err := l2.Retrieve("a.dpc.1", false); err != nil {
+		t.Error("expected key to remain in the shared l2")
+	}
+}
@@ -0,0 +1,328 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package memcached
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+var (
+	errCacheMiss      = errors.New("memcached: cache miss")
+	errNotStored      = errors.New("memcached: item not stored")
+	errNoServers      = errors.New("memcached: no servers configured")
+	errServerError    = errors.New("memcached: server error")
+	errMalformedReply = errors.New("memcached: malformed reply")
+)
+
+var crlf = []byte("\r\n")
+
+// maxRelativeExpiration is the longest expiration memcached treats as relative;
+// longer expirations must be provided as a unix timestamp
+const maxRelativeExpiration = 30 * 24 * time.Hour
+
+// item is a memcached item
+type item struct {
+	key   string
+	value []byte
+	flags uint32
+}
+
+// server is a memcached server and its pool of idle connections
+type server struct {
+	addr        string
+	dialTimeout time.Duration
+	timeout     time.Duration
+	idle        chan *conn
+}
+
+type conn struct {
+	nc net.Conn
+	rw *bufio.ReadWriter
+}
+
+func newServer(addr string, dialTimeout, timeout time.Duration, maxIdle int) *server {
+	return &server{
+		addr:        addr,
+		dialTimeout: dialTimeout,
+		timeout:     timeout,
+		idle:        make(chan *conn, maxIdle),
+	}
+}
+
+func (s *server) getConn() (*conn, error) {
+	select {
+	case c := <-s.idle:
+		return c, nil
+	default:
+	}
+	nc, err := net.DialTimeout("tcp", s.addr, s.dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{nc: nc, rw: bufio.NewReadWriter(bufio.NewReader(nc), bufio.NewWriter(nc))}, nil
+}
+
+// putConn returns the connection to the idle pool, or closes it if the command
+// failed (leaving the connection in an unknown state) or the pool is full
+func (s *server) putConn(c *conn, err error) {
+	if err != nil && !errors.Is(err, errCacheMiss) && !errors.Is(err, errNotStored) {
+		c.nc.Close()
+		return
+	}
+	select {
+	case s.idle <- c:
+	default:
+		c.nc.Close()
+	}
+}
+
+// do runs fn with a connection to the server
+func (s *server) do(fn func(*bufio.ReadWriter) error) error {
+	c, err := s.getConn()
+	if err != nil {
+		return err
+	}
+	if s.timeout > 0 {
+		c.nc.SetDeadline(time.Now().Add(s.timeout))
+	}
+	err = fn(c.rw)
+	s.putConn(c, err)
+	return err
+}
+
+func (s *server) close() {
+	for {
+		select {
+		case c := <-s.idle:
+			c.nc.Close()
+		default:
+			return
+		}
+	}
+}
+
+// client is a memcached text protocol client
+type client struct {
+	servers []*server
+	ring    *ring
+}
+
+func newClient(addrs []string, dialTimeout, timeout time.Duration, maxIdle int) *client {
+	servers := make([]*server, 0, len(addrs))
+	for _, a := range addrs {
+		servers = append(servers, newServer(a, dialTimeout, timeout, maxIdle))
+	}
+	return &client{servers: servers, ring: newRing(servers)}
+}
+
+func (c *client) server(key string) (*server, error) {
+	s := c.ring.get(key)
+	if s == nil {
+		return nil, errNoServers
+	}
+	return s, nil
+}
+
+// expiration returns the memcached exptime for the ttl
+func expiration(ttl time.Duration) int64 {
+	switch {
+	case ttl <= 0:
+		return 0
+	case ttl < time.Second:
+		return 1
+	case ttl > maxRelativeExpiration:
+		return time.Now().Add(ttl).Unix()
+	}
+	return int64(ttl / time.Second)
+}
+
+// ping checks each server's availability
+func (c *client) ping() error {
+	if len(c.servers) == 0 {
+		return errNoServers
+	}
+	for _, s := range c.servers {
+		err := s.do(func(rw *bufio.ReadWriter) error {
+			if _, err := rw.WriteString("version\r\n"); err != nil {
+				return err
+			}
+			if err := rw.Flush(); err != nil {
+				return err
+			}
+			line, err := rw.ReadSlice('\n')
+			if err != nil {
+				return err
+			}
+			if !bytes.HasPrefix(line, []byte("VERSION ")) {
+				return replyError(line)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", s.addr, err)
+		}
+	}
+	return nil
+}
+
+func (c *client) set(it *item, ttl time.Duration) error {
+	s, err := c.server(it.key)
+	if err != nil {
+		return err
+	}
+	return s.do(func(rw *bufio.ReadWriter) error {
+		if _, err := fmt.Fprintf(rw, "set %s %d %d %d\r\n", it.key, it.flags,
+			expiration(ttl), len(it.value)); err != nil {
+			return err
+		}
+		if _, err := rw.Write(it.value); err != nil {
+			return err
+		}
+		if _, err := rw.Write(crlf); err != nil {
+			return err
+		}
+		if err := rw.Flush(); err != nil {
+			return err
+		}
+		line, err := rw.ReadSlice('\n')
+		if err != nil {
+			return err
+		}
+		switch string(line) {
+		case "STORED\r\n":
+			return nil
+		case "NOT_STORED\r\n":
+			return errNotStored
+		}
+		return replyError(line)
+	})
+}
+
+// get returns the item for the key
+func (c *client) get(key string) (*item, error) {
+	s, err := c.server(key)
+	if err != nil {
+		return nil, err
+	}
+	var it *item
+	err = s.do(func(rw *bufio.ReadWriter) error {
+		if _, err := fmt.Fprintf(rw, "get %s\r\n", key); err != nil {
+			return err
+		}
+		if err := rw.Flush(); err != nil {
+			return err
+		}
+		for {
+			line, err := rw.ReadSlice('\n')
+			if err != nil {
+				return err
+			}
+			if bytes.Equal(line, []byte("END\r\n")) {
+				break
+			}
+			i, err := readValue(rw, line)
+			if err != nil {
+				return err
+			}
+			it = i
+		}
+		if it == nil {
+			return errCacheMiss
+		}
+		return nil
+	})
+	return it, err
+}
+
+// readValue reads an item from a "VALUE <key> <flags> <bytes>" line and its data block
+func readValue(rw *bufio.ReadWriter, line []byte) (*item, error) {
+	f := bytes.Fields(line)
+	if len(f) < 4 || string(f[0]) != "VALUE" {
+		return nil, replyError(line)
+	}
+	flags, err := strconv.ParseUint(string(f[2]), 10, 32)
+	if err != nil {
+		return nil, errMalformedReply
+	}
+	n, err := strconv.Atoi(string(f[3]))
+	if err != nil || n < 0 {
+		return nil, errMalformedReply
+	}
+	b := make([]byte, n+2)
+	if _, err := io.ReadFull(rw, b); err != nil {
+		return nil, err
+	}
+	if !bytes.HasSuffix(b, crlf) {
+		return nil, errMalformedReply
+	}
+	return &item{key: string(f[1]), value: b[:n], flags: uint32(flags)}, nil
+}
+
+// delete removes the key, and returns errCacheMiss if it did not exist
+func (c *client) delete(key string) error {
+	return c.simple(key, "delete "+key+"\r\n", "DELETED\r\n")
+}
+
+// touch updates the key's expiration, and returns errCacheMiss if it did not exist
+func (c *client) touch(key string, ttl time.Duration) error {
+	return c.simple(key, fmt.Sprintf("touch %s %d\r\n", key, expiration(ttl)), "TOUCHED\r\n")
+}
+
+// simple sends a single-line command for the key, and expects the success
+// reply or NOT_FOUND
+func (c *client) simple(key, cmd, success string) error {
+	s, err := c.server(key)
+	if err != nil {
+		return err
+	}
+	return s.do(func(rw *bufio.ReadWriter) error {
+		if _, err := rw.WriteString(cmd); err != nil {
+			return err
+		}
+		if err := rw.Flush(); err != nil {
+			return err
+		}
+		line, err := rw.ReadSlice('\n')
+		if err != nil {
+			return err
+		}
+		switch string(line) {
+		case success:
+			return nil
+		case "NOT_FOUND\r\n":
+			return errCacheMiss
+		}
+		return replyError(line)
+	})
+}
+
+func (c *client) close() {
+	for _, s := range c.servers {
+		s.close()
+	}
+}
+
+func replyError(line []byte) error {
+	return fmt.Errorf("%w: %s", errServerError, bytes.TrimSpace(line))
+}
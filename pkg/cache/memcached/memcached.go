@@ -0,0 +1,291 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package memcached is the memcached implementation of the Trickster Cache,
+// which distributes keys across a pool of servers by consistent hashing
+package memcached
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/cache"
+	mo "github.com/trickstercache/trickster/v2/pkg/cache/memcached/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache/metrics"
+	"github.com/trickstercache/trickster/v2/pkg/cache/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache/status"
+	"github.com/trickstercache/trickster/v2/pkg/locks"
+	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
+)
+
+// Memcached is the string "memcached"
+const Memcached = "memcached"
+
+const (
+	// flagChunked is the item flag indicating the item is a manifest of chunks
+	flagChunked uint32 = 1
+	// maxKeyLength is the maximum length of a memcached key
+	maxKeyLength = 250
+	// itemOverhead is reserved from the max item size for the key and item header
+	itemOverhead = 1024
+)
+
+// ErrObjectTooLarge is returned when storing an object larger than the max item
+// size, and chunking is disabled
+var ErrObjectTooLarge = errors.New("object exceeds the memcached max item size")
+
+// Cache represents a memcached cache object that conforms to the Cache interface
+type Cache struct {
+	Name   string
+	Config *options.Options
+	Logger interface{}
+	locker locks.NamedLocker
+
+	client    *client
+	chunkSize int
+}
+
+// Locker returns the cache's locker
+func (c *Cache) Locker() locks.NamedLocker {
+	return c.locker
+}
+
+// SetLocker sets the cache's locker
+func (c *Cache) SetLocker(l locks.NamedLocker) {
+	c.locker = l
+}
+
+// Configuration returns the Configuration for the Cache object
+func (c *Cache) Configuration() *options.Options {
+	return c.Config
+}
+
+// Connect creates the connection pools to the configured memcached servers,
+// and checks that each server is available
+func (c *Cache) Connect() error {
+	o := c.Config.Memcached
+	if o == nil {
+		o = mo.New()
+		c.Config.Memcached = o
+	}
+	tl.Info(c.Logger, "connecting to memcached", tl.Pairs{"servers": o.Servers})
+	c.client = newClient(o.Servers, durationFromMS(o.DialTimeoutMS),
+		durationFromMS(o.TimeoutMS), o.MaxIdleConnsPerServer)
+	c.chunkSize = o.MaxItemSizeBytes - itemOverhead
+	if c.chunkSize < itemOverhead {
+		c.chunkSize = itemOverhead
+	}
+	return c.client.ping()
+}
+
+// mcKey returns the cache key as a valid memcached key, which is at most 250
+// bytes and has no whitespace or control characters
+func mcKey(cacheKey string) string {
+	if len(cacheKey) <= maxKeyLength {
+		valid := true
+		for i := 0; i < len(cacheKey); i++ {
+			if cacheKey[i] <= ' ' || cacheKey[i] == 0x7f {
+				valid = false
+				break
+			}
+		}
+		if valid && cacheKey != "" {
+			return cacheKey
+		}
+	}
+	h := sha256.Sum256([]byte(cacheKey))
+	return "trickster." + hex.EncodeToString(h[:])
+}
+
+func chunkKey(key, gen string, i int) string {
+	return mcKey(fmt.Sprintf("%s.%s.%d", key, gen, i))
+}
+
+// Store places the the data into the memcached cache using the provided Key and TTL.
+// Objects larger than the max item size are stored as chunks, referenced by a
+// manifest stored at the key
+func (c *Cache) Store(cacheKey string, data []byte, ttl time.Duration) error {
+	metrics.ObserveCacheOperation(c.Name, c.Config.Provider, "set", "none", float64(len(data)))
+	tl.Debug(c.Logger, "memcached cache store", tl.Pairs{"key": cacheKey})
+	key := mcKey(cacheKey)
+	if len(data) <= c.chunkSize {
+		return c.client.set(&item{key: key, value: data}, ttl)
+	}
+	if c.Config.Memcached.DisableChunking {
+		metrics.ObserveCacheEvent(c.Name, c.Config.Provider, "oversize", "refused")
+		return ErrObjectTooLarge
+	}
+	metrics.ObserveCacheEvent(c.Name, c.Config.Provider, "oversize", "chunked")
+	return c.storeChunks(key, data, ttl)
+}
+
+func (c *Cache) storeChunks(key string, data []byte, ttl time.Duration) error {
+	// each write of the object uses a new generation of chunk keys, so that
+	// concurrent writers can't interleave their chunks
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	gen := hex.EncodeToString(b)
+	n := (len(data) + c.chunkSize - 1) / c.chunkSize
+	for i := 0; i < n; i++ {
+		end := min((i+1)*c.chunkSize, len(data))
+		err := c.client.set(&item{key: chunkKey(key, gen, i),
+			value: data[i*c.chunkSize : end]}, ttl)
+		if err != nil {
+			return err
+		}
+	}
+	m := fmt.Sprintf("%s %d %d", gen, n, len(data))
+	return c.client.set(&item{key: key, value: []byte(m), flags: flagChunked}, ttl)
+}
+
+// manifest is the parsed value of a chunked object's manifest item
+type manifest struct {
+	gen   string
+	n     int
+	total int
+}
+
+func parseManifest(b []byte) (*manifest, error) {
+	m := &manifest{}
+	if _, err := fmt.Sscanf(string(b), "%s %d %d", &m.gen, &m.n, &m.total); err != nil {
+		return nil, errMalformedReply
+	}
+	return m, nil
+}
+
+func (m *manifest) keys(key string) []string {
+	keys := make([]string, m.n)
+	for i := range keys {
+		keys[i] = chunkKey(key, m.gen, i)
+	}
+	return keys
+}
+
+// Retrieve gets data from the memcached cache using the provided Key.
+// because memcached manages Object Expiration internally, allowExpired is not used.
+func (c *Cache) Retrieve(cacheKey string, allowExpired bool) ([]byte, status.LookupStatus, error) {
+	key := mcKey(cacheKey)
+	it, err := c.client.get(key)
+	var data []byte
+	if err == nil {
+		data = it.value
+		if it.flags&flagChunked == flagChunked {
+			data, err = c.retrieveChunks(key, it.value)
+		}
+	}
+
+	if err == nil {
+		tl.Debug(c.Logger, "memcached cache retrieve", tl.Pairs{"key": cacheKey})
+		metrics.ObserveCacheOperation(c.Name, c.Config.Provider, "get", "hit", float64(len(data)))
+		return data, status.LookupStatusHit, nil
+	}
+
+	if errors.Is(err, errCacheMiss) {
+		tl.Debug(c.Logger, "memcached cache miss", tl.Pairs{"key": cacheKey})
+		metrics.ObserveCacheMiss(cacheKey, c.Name, c.Config.Provider)
+		return nil, status.LookupStatusKeyMiss, cache.ErrKNF
+	}
+
+	tl.Debug(c.Logger, "memcached cache retrieve failed", tl.Pairs{"key": cacheKey, "reason": err.Error()})
+	metrics.ObserveCacheMiss(cacheKey, c.Name, c.Config.Provider)
+	return nil, status.LookupStatusError, err
+}
+
+// retrieveChunks returns the object assembled from the chunks listed in the
+// manifest, or errCacheMiss if any chunk has been evicted
+func (c *Cache) retrieveChunks(key string, value []byte) ([]byte, error) {
+	m, err := parseManifest(value)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, 0, m.total)
+	for _, k := range m.keys(key) {
+		it, err := c.client.get(k)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, it.value...)
+	}
+	if len(data) != m.total {
+		return nil, errCacheMiss
+	}
+	return data, nil
+}
+
+// chunks returns the chunk keys of the object at key, if it is chunked
+func (c *Cache) chunks(key string) []string {
+	it, err := c.client.get(key)
+	if err != nil || it.flags&flagChunked != flagChunked {
+		return nil
+	}
+	m, err := parseManifest(it.value)
+	if err != nil {
+		return nil
+	}
+	return m.keys(key)
+}
+
+// Remove removes an object in cache, if present
+func (c *Cache) Remove(cacheKey string) {
+	tl.Debug(c.Logger, "memcached cache remove", tl.Pairs{"key": cacheKey})
+	c.remove(cacheKey)
+	metrics.ObserveCacheDel(c.Name, c.Config.Provider, 0)
+}
+
+func (c *Cache) remove(cacheKey string) {
+	key := mcKey(cacheKey)
+	for _, k := range c.chunks(key) {
+		c.client.delete(k)
+	}
+	c.client.delete(key)
+}
+
+// SetTTL updates the TTL for the provided cache object
+func (c *Cache) SetTTL(cacheKey string, ttl time.Duration) {
+	key := mcKey(cacheKey)
+	for _, k := range c.chunks(key) {
+		c.client.touch(k, ttl)
+	}
+	c.client.touch(key, ttl)
+}
+
+// BulkRemove removes a list of objects from the cache
+func (c *Cache) BulkRemove(cacheKeys []string) {
+	tl.Debug(c.Logger, "memcached cache bulk remove", tl.Pairs{})
+	for _, k := range cacheKeys {
+		c.remove(k)
+	}
+	metrics.ObserveCacheDel(c.Name, c.Config.Provider, float64(len(cacheKeys)))
+}
+
+// Close closes the idle connections to the memcached servers
+func (c *Cache) Close() error {
+	tl.Info(c.Logger, "closing memcached connections", tl.Pairs{})
+	if c.client != nil {
+		c.client.close()
+	}
+	return nil
+}
+
+func durationFromMS(input int) time.Duration {
+	return time.Duration(int64(input)) * time.Millisecond
+}
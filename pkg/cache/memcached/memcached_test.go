@@ -0,0 +1,276 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package memcached
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/cache"
+	mo "github.com/trickstercache/trickster/v2/pkg/cache/memcached/options"
+	co "github.com/trickstercache/trickster/v2/pkg/cache/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache/status"
+	"github.com/trickstercache/trickster/v2/pkg/locks"
+)
+
+// testServer is a minimal memcached server supporting the commands used by the client
+type testServer struct {
+	l       net.Listener
+	mtx     sync.Mutex
+	items   map[string]*item
+	exp     map[string]int64
+	maxItem int
+}
+
+func newTestServer(t *testing.T, maxItem int) *testServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testServer{l: l, items: make(map[string]*item), exp: make(map[string]int64),
+		maxItem: maxItem}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	t.Cleanup(func() { l.Close() })
+	return s
+}
+
+func (s *testServer) addr() string {
+	return s.l.Addr().String()
+}
+
+func (s *testServer) len() int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return len(s.items)
+}
+
+func (s *testServer) serve(c net.Conn) {
+	defer c.Close()
+	rw := bufio.NewReadWriter(bufio.NewReader(c), bufio.NewWriter(c))
+	for {
+		line, err := rw.ReadString('\n')
+		if err != nil {
+			return
+		}
+		f := strings.Fields(line)
+		if len(f) == 0 {
+			return
+		}
+		s.mtx.Lock()
+		switch f[0] {
+		case "version":
+			rw.WriteString("VERSION 1.6.0\r\n")
+		case "set":
+			flags, _ := strconv.ParseUint(f[2], 10, 32)
+			exp, _ := strconv.ParseInt(f[3], 10, 64)
+			n, _ := strconv.Atoi(f[4])
+			b := make([]byte, n+2)
+			io.ReadFull(rw, b)
+			if n > s.maxItem {
+				rw.WriteString("SERVER_ERROR object too large for cache\r\n")
+				break
+			}
+			s.items[f[1]] = &item{key: f[1], value: b[:n], flags: uint32(flags)}
+			s.exp[f[1]] = exp
+			rw.WriteString("STORED\r\n")
+		case "get":
+			for _, k := range f[1:] {
+				if it, ok := s.items[k]; ok {
+					fmt.Fprintf(rw, "VALUE %s %d %d\r\n", k, it.flags, len(it.value))
+					rw.Write(it.value)
+					rw.WriteString("\r\n")
+				}
+			}
+			rw.WriteString("END\r\n")
+		case "delete":
+			if _, ok := s.items[f[1]]; ok {
+				delete(s.items, f[1])
+				rw.WriteString("DELETED\r\n")
+			} else {
+				rw.WriteString("NOT_FOUND\r\n")
+			}
+		case "touch":
+			if _, ok := s.items[f[1]]; ok {
+				s.exp[f[1]], _ = strconv.ParseInt(f[2], 10, 64)
+				rw.WriteString("TOUCHED\r\n")
+			} else {
+				rw.WriteString("NOT_FOUND\r\n")
+			}
+		default:
+			rw.WriteString("ERROR\r\n")
+		}
+		s.mtx.Unlock()
+		rw.Flush()
+	}
+}
+
+func newTestCache(t *testing.T, servers ...string) *Cache {
+	cfg := co.New()
+	cfg.Provider = Memcached
+	cfg.Memcached = mo.New()
+	cfg.Memcached.Servers = servers
+	cfg.Memcached.MaxItemSizeBytes = 4096
+	c := &Cache{Name: "test", Config: cfg}
+	c.SetLocker(locks.NewNamedLocker())
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestConnect(t *testing.T) {
+	s := newTestServer(t, 4096)
+	newTestCache(t, s.addr())
+
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := l.Addr().String()
+	l.Close()
+	cfg := co.New()
+	cfg.Memcached.Servers = []string{addr}
+	c := &Cache{Name: "test", Config: cfg}
+	if err := c.Connect(); err == nil {
+		t.Error("expected error for unavailable server")
+	}
+	if err := c.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStoreAndRetrieve(t *testing.T) {
+	s := newTestServer(t, 4096)
+	c := newTestCache(t, s.addr())
+
+	if err := c.Store("key", []byte("value"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	b, ls, err := c.Retrieve("key", false)
+	if err != nil || ls != status.LookupStatusHit || string(b) != "value" {
+		t.Errorf("unexpected retrieve result: %s %s %v", string(b), ls, err)
+	}
+
+	c.SetTTL("key", time.Minute)
+	if s.exp["key"] != 60 {
+		t.Errorf("expected %d got %d", 60, s.exp["key"])
+	}
+
+	c.Remove("key")
+	if _, ls, err = c.Retrieve("key", false); err != cache.ErrKNF ||
+		ls != status.LookupStatusKeyMiss {
+		t.Errorf("expected miss, got %s %v", ls, err)
+	}
+
+	c.Store("key1", []byte("value"), time.Hour)
+	c.Store("key2", []byte("value"), time.Hour)
+	c.BulkRemove([]string{"key1", "key2"})
+	if s.len() != 0 {
+		t.Errorf("expected empty server, got %d items", s.len())
+	}
+}
+
+func TestChunking(t *testing.T) {
+	s := newTestServer(t, 4096)
+	c := newTestCache(t, s.addr())
+
+	data := bytes.Repeat([]byte("0123456789"), 2000)
+	if err := c.Store("large", data, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if s.len() < 2 {
+		t.Errorf("expected chunks, got %d items", s.len())
+	}
+	b, _, err := c.Retrieve("large", false)
+	if err != nil || !bytes.Equal(b, data) {
+		t.Errorf("unexpected chunked retrieve result: %v", err)
+	}
+
+	// an evicted chunk is a miss
+	m, _ := parseManifest(s.items["large"].value)
+	delete(s.items, m.keys("large")[1])
+	if _, _, err = c.Retrieve("large", false); err != cache.ErrKNF {
+		t.Errorf("expected %v got %v", cache.ErrKNF, err)
+	}
+
+	c.Remove("large")
+	if s.len() != 0 {
+		t.Errorf("expected chunks to be removed, got %d items", s.len())
+	}
+
+	c.Config.Memcached.DisableChunking = true
+	if err = c.Store("large", data, time.Hour); err != ErrObjectTooLarge {
+		t.Errorf("expected %v got %v", ErrObjectTooLarge, err)
+	}
+}
+
+func TestDistribution(t *testing.T) {
+	s1, s2 := newTestServer(t, 4096), newTestServer(t, 4096)
+	c := newTestCache(t, s1.addr(), s2.addr())
+	for i := 0; i < 100; i++ {
+		k := "key" + strconv.Itoa(i)
+		if err := c.Store(k, []byte("value"), time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := c.Retrieve(k, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s1.len() == 0 || s2.len() == 0 {
+		t.Errorf("expected keys on both servers, got %d and %d", s1.len(), s2.len())
+	}
+}
+
+func TestMCKey(t *testing.T) {
+	if k := mcKey("valid.key"); k != "valid.key" {
+		t.Errorf("expected %s got %s", "valid.key", k)
+	}
+	for _, k := range []string{"", "has space", strings.Repeat("k", 251)} {
+		if mk := mcKey(k); !strings.HasPrefix(mk, "trickster.") || len(mk) > maxKeyLength {
+			t.Errorf("unexpected key for %q: %s", k, mk)
+		}
+	}
+}
+
+func TestExpiration(t *testing.T) {
+	if v := expiration(0); v != 0 {
+		t.Errorf("expected %d got %d", 0, v)
+	}
+	if v := expiration(time.Millisecond); v != 1 {
+		t.Errorf("expected %d got %d", 1, v)
+	}
+	if v := expiration(time.Minute); v != 60 {
+		t.Errorf("expected %d got %d", 60, v)
+	}
+	if v := expiration(60 * 24 * time.Hour); v < time.Now().Unix() {
+		t.Errorf("expected absolute expiration, got %d", v)
+	}
+}
@@ -0,0 +1,30 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+const (
+	// DefaultServer is the default memcached server endpoint
+	DefaultServer = "memcached:11211"
+	// DefaultDialTimeoutMS is the default timeout for establishing new connections
+	DefaultDialTimeoutMS = 1000
+	// DefaultTimeoutMS is the default timeout for socket reads and writes
+	DefaultTimeoutMS = 500
+	// DefaultMaxIdleConnsPerServer is the default number of idle connections kept per server
+	DefaultMaxIdleConnsPerServer = 8
+	// DefaultMaxItemSizeBytes is the default memcached item size limit (the -I flag)
+	DefaultMaxItemSizeBytes = 1048576
+)
@@ -0,0 +1,56 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import "slices"
+
+// Options is a collection of Configurations for Connecting to Memcached
+type Options struct {
+	// Servers is the list of FQDN:port or IP:Port memcached server endpoints, across
+	// which keys are distributed by consistent hashing
+	Servers []string `json:"servers,omitempty"`
+	// DialTimeoutMS is the timeout for establishing new connections
+	DialTimeoutMS int `json:"dial_timeout_ms,omitempty"`
+	// TimeoutMS is the timeout for socket reads and writes of a command
+	TimeoutMS int `json:"timeout_ms,omitempty"`
+	// MaxIdleConnsPerServer is the maximum number of idle connections kept open to each server
+	MaxIdleConnsPerServer int `json:"max_idle_conns_per_server,omitempty"`
+	// MaxItemSizeBytes is the servers' item size limit. Objects larger than this are
+	// split across multiple items, or refused when DisableChunking is true
+	MaxItemSizeBytes int `json:"max_item_size_bytes,omitempty"`
+	// DisableChunking refuses to store objects larger than MaxItemSizeBytes
+	// rather than splitting them across multiple items
+	DisableChunking bool `json:"disable_chunking,omitempty"`
+}
+
+// New returns a new Memcached Options Reference with default values set
+func New() *Options {
+	return &Options{
+		Servers:               []string{DefaultServer},
+		DialTimeoutMS:         DefaultDialTimeoutMS,
+		TimeoutMS:             DefaultTimeoutMS,
+		MaxIdleConnsPerServer: DefaultMaxIdleConnsPerServer,
+		MaxItemSizeBytes:      DefaultMaxItemSizeBytes,
+	}
+}
+
+// Clone returns an exact copy of the subject Options
+func (o *Options) Clone() *Options {
+	c := *o
+	c.Servers = slices.Clone(o.Servers)
+	return &c
+}
@@ -0,0 +1,35 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import "testing"
+
+func TestNew(t *testing.T) {
+	o := New()
+	if o == nil || len(o.Servers) != 1 || o.Servers[0] != DefaultServer {
+		t.Error("expected default options")
+	}
+}
+
+func TestClone(t *testing.T) {
+	o := New()
+	c := o.Clone()
+	c.Servers[0] = "other:11211"
+	if o.Servers[0] != DefaultServer {
+		t.Error("expected cloned servers list")
+	}
+}
@@ -0,0 +1,65 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package memcached
+
+import (
+	"hash/crc32"
+	"slices"
+	"strconv"
+)
+
+// pointsPerServer is the number of points each server occupies on the ring
+const pointsPerServer = 160
+
+// ring distributes keys across servers by consistent hashing, so that adding
+// or removing a server only redistributes the keys mapped to that server
+type ring struct {
+	points  []uint32
+	servers map[uint32]*server
+}
+
+func newRing(servers []*server) *ring {
+	r := &ring{
+		points:  make([]uint32, 0, len(servers)*pointsPerServer),
+		servers: make(map[uint32]*server, len(servers)*pointsPerServer),
+	}
+	for _, s := range servers {
+		for i := 0; i < pointsPerServer; i++ {
+			h := crc32.ChecksumIEEE([]byte(s.addr + "-" + strconv.Itoa(i)))
+			if _, ok := r.servers[h]; ok {
+				continue
+			}
+			r.servers[h] = s
+			r.points = append(r.points, h)
+		}
+	}
+	slices.Sort(r.points)
+	return r
+}
+
+// get returns the server for the key
+func (r *ring) get(key string) *server {
+	if len(r.points) == 0 {
+		return nil
+	}
+	h := crc32.ChecksumIEEE([]byte(key))
+	i, _ := slices.BinarySearch(r.points, h)
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.servers[r.points[i]]
+}
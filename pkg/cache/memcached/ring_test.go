@@ -0,0 +1,44 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package memcached
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestRing(t *testing.T) {
+	if s := newRing(nil).get("key"); s != nil {
+		t.Error("expected nil server")
+	}
+
+	servers := []*server{{addr: "a:11211"}, {addr: "b:11211"}, {addr: "c:11211"}}
+	r := newRing(servers)
+	before := make(map[string]string)
+	for i := 0; i < 1000; i++ {
+		k := "key" + strconv.Itoa(i)
+		before[k] = r.get(k).addr
+	}
+
+	// removing a server only remaps the keys that were on it
+	r = newRing(servers[:2])
+	for k, addr := range before {
+		if addr != "c:11211" && r.get(k).addr != addr {
+			t.Errorf("key %s moved from %s to %s", k, addr, r.get(k).addr)
+		}
+	}
+}
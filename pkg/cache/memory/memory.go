@@ -15,15 +15,17 @@
  */
 
 // Package memory is the memory implementation of the Trickster Cache
-// and uses a sync.Map to manage cache objects
+// and uses a set of independently-locked maps (shards) to manage cache objects
 package memory
 
 import (
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/trickstercache/trickster/v2/pkg/cache"
 	"github.com/trickstercache/trickster/v2/pkg/cache/index"
+	io "github.com/trickstercache/trickster/v2/pkg/cache/index/options"
 	"github.com/trickstercache/trickster/v2/pkg/cache/metrics"
 	"github.com/trickstercache/trickster/v2/pkg/cache/options"
 	"github.com/trickstercache/trickster/v2/pkg/cache/status"
@@ -33,13 +35,41 @@ import (
 
 // Cache defines a a Memory Cache client that conforms to the Cache interface
 type Cache struct {
-	Name       string
-	client     sync.Map
-	Config     *options.Options
-	Index      *index.Index
-	Logger     interface{}
-	locker     locks.NamedLocker
-	lockPrefix string
+	Name   string
+	Config *options.Options
+	Index  *index.Index
+	Logger interface{}
+	locker locks.NamedLocker
+	shards []*shard
+	// shardsOnce allocates the shards on first use, so that a Cache is safe to
+	// use before Connect is called
+	shardsOnce sync.Once
+
+	restoring sync.WaitGroup
+	stopper   chan struct{}
+	closeOnce sync.Once
+}
+
+// shard is a partition of the cache's objects, with its own lock
+type shard struct {
+	mtx     sync.RWMutex
+	objects map[string]*index.Object
+}
+
+func (c *Cache) shard(cacheKey string) *shard {
+	c.shardsOnce.Do(c.allocateShards)
+	return c.shards[index.ShardFor(cacheKey, len(c.shards))]
+}
+
+func (c *Cache) allocateShards() {
+	n := io.DefaultShardCount
+	if c.Config != nil && c.Config.Index != nil && c.Config.Index.ShardCount > 0 {
+		n = c.Config.Index.ShardCount
+	}
+	c.shards = make([]*shard, n)
+	for i := range c.shards {
+		c.shards[i] = &shard{objects: make(map[string]*index.Object)}
+	}
 }
 
 // New returns a new memory cache as a Trickster Cache Interface type
@@ -69,18 +99,50 @@ func (c *Cache) Configuration() *options.Options {
 	return c.Config
 }
 
+// CacheIndex returns the Index that tracks the cache's objects
+func (c *Cache) CacheIndex() *index.Index {
+	return c.Index
+}
+
 // Connect initializes the Cache
 func (c *Cache) Connect() error {
 	tl.Info(c.Logger, "memorycache setup", tl.Pairs{
 		"name":         c.Name,
 		"maxSizeBytes": c.Config.Index.MaxSizeBytes, "maxSizeObjects": c.Config.Index.MaxSizeObjects,
 	})
-	c.lockPrefix = c.Name + ".memory."
-	c.client = sync.Map{}
+	c.shardsOnce.Do(c.allocateShards)
 	c.Index = index.NewIndex(c.Name, c.Config.Provider, nil, c.Config.Index, c.BulkRemove, nil, c.Logger)
+	if c.Config.Memory != nil && c.Config.Memory.SnapshotPath != "" {
+		c.stopper = make(chan struct{})
+		// the snapshot is restored in the background so the cache can serve requests
+		// while it loads; objects written in the meantime take precedence
+		c.restoring.Add(1)
+		go func() {
+			defer c.restoring.Done()
+			c.Restore()
+		}()
+		if c.Config.Memory.SnapshotIntervalMS > 0 {
+			go c.snapshotter(time.Duration(c.Config.Memory.SnapshotIntervalMS) * time.Millisecond)
+		}
+	}
 	return nil
 }
 
+// snapshotter periodically saves a snapshot of the cache until the cache is closed
+func (c *Cache) snapshotter(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	c.restoring.Wait()
+	for {
+		select {
+		case <-t.C:
+			c.Snapshot()
+		case <-c.stopper:
+			return
+		}
+	}
+}
+
 // StoreReference stores an object directly to the memory cache without requiring serialization
 func (c *Cache) StoreReference(cacheKey string, data cache.ReferenceObject, ttl time.Duration) error {
 	return c.store(cacheKey, nil, data, ttl, true)
@@ -114,14 +176,15 @@ func (c *Cache) store(cacheKey string, byteData []byte, refData cache.ReferenceO
 	}
 
 	if o1 != nil && o2 != nil {
-		nl, _ := c.locker.Acquire(c.lockPrefix + cacheKey)
 		tl.Debug(c.Logger, "memorycache cache store",
 			tl.Pairs{"cacheName": c.Name, "cacheKey": cacheKey, "length": l, "ttl": ttl, "is_direct": isDirect})
-		c.client.Store(cacheKey, o1)
+		sh := c.shard(cacheKey)
+		sh.mtx.Lock()
+		sh.objects[cacheKey] = o1
+		sh.mtx.Unlock()
 		if updateIndex {
 			c.Index.UpdateObject(o2)
 		}
-		nl.Release()
 	}
 
 	return nil
@@ -156,21 +219,25 @@ func (c *Cache) Retrieve(cacheKey string, allowExpired bool) ([]byte, status.Loo
 func (c *Cache) retrieve(cacheKey string, allowExpired bool, atime bool) (*index.Object,
 	status.LookupStatus, error,
 ) {
-	nl, _ := c.locker.RAcquire(c.lockPrefix + cacheKey)
-	record, ok := c.client.Load(cacheKey)
-	nl.RRelease()
+	sh := c.shard(cacheKey)
+	sh.mtx.RLock()
+	o, ok := sh.objects[cacheKey]
+	sh.mtx.RUnlock()
 
 	if ok {
-		o := record.(*index.Object)
-		o.Expiration = c.Index.GetExpiration(cacheKey)
+		exp := c.Index.GetExpiration(cacheKey)
 
-		if allowExpired || o.Expiration.IsZero() || o.Expiration.After(time.Now()) {
+		if allowExpired || exp.IsZero() || exp.After(time.Now()) {
 			tl.Debug(c.Logger, "memory cache retrieve", tl.Pairs{"cacheKey": cacheKey})
 			if atime {
-				go c.Index.UpdateObjectAccessTime(cacheKey)
+				c.Index.UpdateObjectAccessTime(cacheKey)
 			}
 			metrics.ObserveCacheOperation(c.Name, c.Config.Provider, "get", "hit", float64(len(o.Value)))
-			return o, status.LookupStatusHit, nil
+			// the stored object is shared by concurrent readers, so its expiration
+			// is provided on a copy
+			ro := *o
+			ro.Expiration = exp
+			return &ro, status.LookupStatusHit, nil
 		}
 		// Cache Object has been expired but not reaped, go ahead and delete it
 		go c.remove(cacheKey, false)
@@ -190,9 +257,10 @@ func (c *Cache) Remove(cacheKey string) {
 }
 
 func (c *Cache) remove(cacheKey string, isBulk bool) {
-	nl, _ := c.locker.Acquire(c.lockPrefix + cacheKey)
-	c.client.Delete(cacheKey)
-	nl.Release()
+	sh := c.shard(cacheKey)
+	sh.mtx.Lock()
+	delete(sh.objects, cacheKey)
+	sh.mtx.Unlock()
 	if !isBulk {
 		go c.Index.RemoveObject(cacheKey)
 	}
@@ -201,21 +269,47 @@ func (c *Cache) remove(cacheKey string, isBulk bool) {
 
 // BulkRemove removes a list of objects from the cache
 func (c *Cache) BulkRemove(cacheKeys []string) {
-	wg := &sync.WaitGroup{}
 	for _, cacheKey := range cacheKeys {
-		wg.Add(1)
-		go func(key string) {
-			c.remove(key, true)
-			wg.Done()
-		}(cacheKey)
+		c.remove(cacheKey, true)
 	}
-	wg.Wait()
 }
 
-// Close is not used for Cache, and is here to fully prototype the Cache Interface
-func (c *Cache) Close() error {
-	if c.Index != nil {
-		c.Index.Close()
+// RemoveByPrefix removes all objects whose keys begin with the provided prefix,
+// and returns the number of objects removed. Matching objects are removed from
+// each shard as it is scanned, and then from the Index in a single pass
+func (c *Cache) RemoveByPrefix(prefix string) int {
+	keys := make([]string, 0)
+	for _, sh := range c.shards {
+		sh.mtx.Lock()
+		for key := range sh.objects {
+			if strings.HasPrefix(key, prefix) {
+				delete(sh.objects, key)
+				keys = append(keys, key)
+			}
+		}
+		sh.mtx.Unlock()
 	}
-	return nil
+	if len(keys) == 0 {
+		return 0
+	}
+	c.Index.RemoveObjects(keys, false)
+	metrics.ObserveCacheDel(c.Name, c.Config.Provider, float64(len(keys)))
+	return len(keys)
+}
+
+// Close saves a snapshot of the cache, when snapshots are configured, and closes the Index
+func (c *Cache) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		if c.stopper != nil {
+			close(c.stopper)
+			// a snapshot taken while restoring would leave out the objects not yet restored
+			c.restoring.Wait()
+			err = c.Snapshot()
+		}
+		if c.Index != nil {
+			c.Index.Close()
+		}
+	})
+	return err
 }
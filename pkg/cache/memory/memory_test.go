@@ -18,10 +18,13 @@ package memory
 
 import (
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/trickstercache/trickster/v2/pkg/cache"
+	"github.com/trickstercache/trickster/v2/pkg/cache/index"
 	io "github.com/trickstercache/trickster/v2/pkg/cache/index/options"
 	co "github.com/trickstercache/trickster/v2/pkg/cache/options"
 	"github.com/trickstercache/trickster/v2/pkg/cache/status"
@@ -352,6 +355,39 @@ func TestCache_BulkRemove(t *testing.T) {
 	}
 }
 
+func TestCache_RemoveByPrefix(t *testing.T) {
+	cacheConfig := newCacheConfig(t)
+	mc := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: testLocker}
+
+	err := mc.Connect()
+	if err != nil {
+		t.Error(err)
+	}
+	defer mc.Close()
+
+	for _, k := range []string{"a.dpc.1", "a.opc.2", "ab.dpc.3"} {
+		if err = mc.Store(k, []byte("data"), time.Duration(60)*time.Second); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if n := mc.RemoveByPrefix("a."); n != 2 {
+		t.Errorf("expected %d got %d", 2, n)
+	}
+	if _, _, err = mc.Retrieve("a.dpc.1", false); err == nil {
+		t.Errorf("expected key not found error for %s", "a.dpc.1")
+	}
+	if _, _, err = mc.Retrieve("ab.dpc.3", false); err != nil {
+		t.Error(err)
+	}
+	if n := mc.Index.Count(); n != 1 {
+		t.Errorf("expected %d got %d", 1, n)
+	}
+	if n := mc.RemoveByPrefix("z"); n != 0 {
+		t.Errorf("expected %d got %d", 0, n)
+	}
+}
+
 func BenchmarkCache_BulkRemove(b *testing.B) {
 	var keyArray []string
 	for n := 0; n < b.N; n++ {
@@ -461,3 +497,216 @@ func TestLocker(t *testing.T) {
 		t.Errorf("error setting locker")
 	}
 }
+
+func TestCache_Shards(t *testing.T) {
+	cacheConfig := newCacheConfig(t)
+	cacheConfig.Index.ShardCount = 8
+	mc := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: testLocker}
+	if err := mc.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer mc.Close()
+	if len(mc.shards) != 8 {
+		t.Fatalf("expected %d got %d", 8, len(mc.shards))
+	}
+
+	// concurrent writers and readers across all shards should be accounted for globally
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for n := 0; n < 100; n++ {
+				k := cacheKey + strconv.Itoa(g) + "." + strconv.Itoa(n)
+				mc.Store(k, []byte("data"), time.Minute)
+				if _, _, err := mc.Retrieve(k, false); err != nil {
+					t.Error(err)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if n := mc.Index.Count(); n != 800 {
+		t.Errorf("expected %d got %d", 800, n)
+	}
+	for i, sh := range mc.shards {
+		if len(sh.objects) == 0 {
+			t.Errorf("expected objects in shard %d", i)
+		}
+	}
+	if n := mc.RemoveByPrefix(cacheKey + "1."); n != 100 {
+		t.Errorf("expected %d got %d", 100, n)
+	}
+}
+
+func TestCache_ZeroValue(t *testing.T) {
+	cacheConfig := newCacheConfig(t)
+	mc := &Cache{Config: &cacheConfig}
+	// the shards are allocated on first use, before the cache is connected
+	if _, _, err := mc.Retrieve(cacheKey, false); err != cache.ErrKNF {
+		t.Errorf("expected %v got %v", cache.ErrKNF, err)
+	}
+	mc.BulkRemove([]string{cacheKey})
+	if n := mc.RemoveByPrefix(cacheKey); n != 0 {
+		t.Errorf("expected %d got %d", 0, n)
+	}
+	if len(mc.shards) != io.DefaultShardCount {
+		t.Errorf("expected %d got %d", io.DefaultShardCount, len(mc.shards))
+	}
+}
+
+func TestCache_RetrieveExpiration(t *testing.T) {
+	cacheConfig := newCacheConfig(t)
+	mc := &Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: testLocker}
+	if err := mc.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer mc.Close()
+	mc.Store(cacheKey, []byte("data"), time.Minute)
+	exp := mc.Index.GetExpiration(cacheKey)
+	o, _, err := mc.retrieve(cacheKey, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !o.Expiration.Equal(exp) {
+		t.Errorf("expected %v got %v", exp, o.Expiration)
+	}
+	// updated TTLs are reflected in the retrieved object
+	mc.Index.UpdateObjectTTL(cacheKey, time.Hour)
+	o, _, _ = mc.retrieve(cacheKey, false, false)
+	if !o.Expiration.After(exp) {
+		t.Errorf("expected expiration after %v got %v", exp, o.Expiration)
+	}
+}
+
+// syncMapCache is the previous, unsharded memory cache implementation, which
+// stores objects in a sync.Map under a named lock per key. It is retained here
+// as the baseline for BenchmarkCache_Parallel, along with syncMapIndex.
+type syncMapCache struct {
+	client sync.Map
+	index  *syncMapIndex
+	locker locks.NamedLocker
+}
+
+// syncMapIndex reproduces the previous Index's locking, where every operation,
+// including reads, holds a single exclusive lock across all objects
+type syncMapIndex struct {
+	mtx     sync.Mutex
+	objects map[string]*index.Object
+	size    int64
+}
+
+func (idx *syncMapIndex) UpdateObject(obj *index.Object) {
+	idx.mtx.Lock()
+	obj.Size = int64(len(obj.Value))
+	obj.Value = nil
+	obj.LastAccess = time.Now()
+	obj.LastWrite = obj.LastAccess
+	if o, ok := idx.objects[obj.Key]; ok {
+		idx.size += obj.Size - o.Size
+		obj.AccessCount = o.AccessCount
+	} else {
+		idx.size += obj.Size
+	}
+	idx.objects[obj.Key] = obj
+	idx.mtx.Unlock()
+}
+
+func (idx *syncMapIndex) UpdateObjectAccessTime(key string) {
+	idx.mtx.Lock()
+	if o, ok := idx.objects[key]; ok {
+		o.LastAccess = time.Now()
+		o.AccessCount++
+	}
+	idx.mtx.Unlock()
+}
+
+func (idx *syncMapIndex) GetExpiration(key string) time.Time {
+	idx.mtx.Lock()
+	defer idx.mtx.Unlock()
+	if o, ok := idx.objects[key]; ok {
+		return o.Expiration
+	}
+	return time.Time{}
+}
+
+func (c *syncMapCache) Store(cacheKey string, data []byte, ttl time.Duration) error {
+	exp := time.Now().Add(ttl)
+	o1 := &index.Object{Key: cacheKey, Value: data, Expiration: exp}
+	o2 := &index.Object{Key: cacheKey, Value: data, Expiration: exp}
+	nl, _ := c.locker.Acquire("memory." + cacheKey)
+	c.client.Store(cacheKey, o1)
+	c.index.UpdateObject(o2)
+	nl.Release()
+	return nil
+}
+
+func (c *syncMapCache) Retrieve(cacheKey string, allowExpired bool) ([]byte, status.LookupStatus, error) {
+	nl, _ := c.locker.RAcquire("memory." + cacheKey)
+	record, ok := c.client.Load(cacheKey)
+	nl.RRelease()
+	if !ok {
+		return nil, status.LookupStatusKeyMiss, cache.ErrKNF
+	}
+	o := record.(*index.Object)
+	exp := c.index.GetExpiration(cacheKey)
+	if allowExpired || exp.IsZero() || exp.After(time.Now()) {
+		go c.index.UpdateObjectAccessTime(cacheKey)
+		return o.Value, status.LookupStatusHit, nil
+	}
+	return nil, status.LookupStatusKeyMiss, cache.ErrKNF
+}
+
+// BenchmarkCache_Parallel measures the throughput of a mixed read/write workload
+// from concurrent clients, for the previous sync.Map implementation and for a
+// range of shard counts. No logger is used, so that only the cache's own
+// locking is measured.
+func BenchmarkCache_Parallel(b *testing.B) {
+	const keyCount = 4096
+	keys := make([]string, keyCount)
+	for i := range keys {
+		keys[i] = cacheKey + strconv.Itoa(i)
+	}
+	data := []byte("data")
+	run := func(b *testing.B, c interface {
+		Store(string, []byte, time.Duration) error
+		Retrieve(string, bool) ([]byte, status.LookupStatus, error)
+	}) {
+		for _, k := range keys {
+			c.Store(k, data, time.Minute)
+		}
+		var seq atomic.Int64
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			i := int(seq.Add(1)) * 7919
+			for pb.Next() {
+				k := keys[i%keyCount]
+				// one write for every nine reads
+				if i%10 == 0 {
+					c.Store(k, data, time.Minute)
+				} else {
+					c.Retrieve(k, false)
+				}
+				i++
+			}
+		})
+	}
+	b.Run("baseline=sync.Map", func(b *testing.B) {
+		c := &syncMapCache{locker: locks.NewNamedLocker(),
+			index: &syncMapIndex{objects: make(map[string]*index.Object)}}
+		run(b, c)
+	})
+	for _, shards := range []int{1, 4, 16, 64} {
+		b.Run("shards="+strconv.Itoa(shards), func(b *testing.B) {
+			cacheConfig := co.Options{Provider: provider,
+				Index: &io.Options{ReapInterval: 0, ShardCount: shards}}
+			mc := &Cache{Config: &cacheConfig, locker: testLocker}
+			if err := mc.Connect(); err != nil {
+				b.Fatal(err)
+			}
+			defer mc.Close()
+			run(b, mc)
+		})
+	}
+}
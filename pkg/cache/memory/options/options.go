@@ -0,0 +1,46 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package options
+
+// Options is a collection of Configurations for the Memory cache
+type Options struct {
+	// SnapshotPath is the file to which the cache's unexpired objects are saved on
+	// shutdown and at SnapshotIntervalMS, and from which they are restored at startup.
+	// Snapshots are disabled when empty
+	SnapshotPath string `json:"snapshot_path,omitempty"`
+	// SnapshotIntervalMS is how often a snapshot is saved while running. When 0, a
+	// snapshot is only saved when the cache is closed
+	SnapshotIntervalMS int `json:"snapshot_interval_ms,omitempty"`
+}
+
+// New returns a new Memory Options Reference with default values set
+func New() *Options {
+	return &Options{SnapshotIntervalMS: DefaultSnapshotIntervalMS}
+}
+
+// Clone returns an exact copy of the subject Options
+func (o *Options) Clone() *Options {
+	c := *o
+	return &c
+}
+
+// Equal returns true if all values in the Options references are identical
+func (o *Options) Equal(o2 *Options) bool {
+	if o == nil || o2 == nil {
+		return o == o2
+	}
+	return *o == *o2
+}
@@ -0,0 +1,371 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package memory
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/cache"
+	"github.com/trickstercache/trickster/v2/pkg/cache/index"
+	"github.com/trickstercache/trickster/v2/pkg/cache/metrics"
+	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
+)
+
+// snapshotMagic identifies a memory cache snapshot file and its format version
+const snapshotMagic = "TRKSNAP1"
+
+// snapshot record kinds
+const (
+	recordBytes     byte = 1
+	recordReference byte = 2
+)
+
+// ErrInvalidSnapshot is returned when a snapshot file is not in the expected format
+var ErrInvalidSnapshot = errors.New("invalid memory cache snapshot")
+
+// Snapshot writes the cache's unexpired objects and their expirations to the
+// configured snapshot path. It is a no-op when no snapshot path is configured
+func (c *Cache) Snapshot() error {
+	if c.Config == nil || c.Config.Memory == nil || c.Config.Memory.SnapshotPath == "" {
+		return nil
+	}
+	path := c.Config.Memory.SnapshotPath
+	start := time.Now()
+	n, b, err := c.writeSnapshot(path)
+	if err != nil {
+		metrics.ObserveCacheOperation(c.Name, c.Config.Provider, "snapshot", "error", 0)
+		tl.Error(c.Logger, "memory cache snapshot failed",
+			tl.Pairs{"cacheName": c.Name, "path": path, "detail": err.Error()})
+		return err
+	}
+	metrics.ObserveCacheOperation(c.Name, c.Config.Provider, "snapshot", "none", float64(b))
+	tl.Info(c.Logger, "memory cache snapshot saved",
+		tl.Pairs{
+			"cacheName": c.Name, "path": path, "objects": n, "bytes": b,
+			"elapsedMS": time.Since(start).Milliseconds(),
+		})
+	return nil
+}
+
+// writeSnapshot writes the snapshot to a temporary file that replaces path once
+// complete, and returns the number of objects and bytes written
+func (c *Cache) writeSnapshot(path string) (int, int64, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer os.Remove(f.Name())
+	cw := &countingWriter{w: f}
+	w := bufio.NewWriter(cw)
+	if _, err = w.WriteString(snapshotMagic); err != nil {
+		f.Close()
+		return 0, 0, err
+	}
+	var n int
+	now := time.Now()
+	for _, sh := range c.shards {
+		sh.mtx.RLock()
+		objects := make([]*index.Object, 0, len(sh.objects))
+		for _, o := range sh.objects {
+			objects = append(objects, o)
+		}
+		sh.mtx.RUnlock()
+		for _, o := range objects {
+			exp := c.Index.GetExpiration(o.Key)
+			if !exp.IsZero() && !exp.After(now) {
+				continue
+			}
+			kind, b, err := c.marshalObject(o)
+			if err != nil {
+				tl.Warn(c.Logger, "memory cache snapshot skipped object",
+					tl.Pairs{"cacheName": c.Name, "cacheKey": o.Key, "detail": err.Error()})
+				continue
+			}
+			if kind == 0 {
+				continue
+			}
+			if err = writeRecord(w, kind, o.Key, exp, b); err != nil {
+				f.Close()
+				return 0, 0, err
+			}
+			n++
+		}
+	}
+	if err = w.Flush(); err != nil {
+		f.Close()
+		return 0, 0, err
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return 0, 0, err
+	}
+	if err = f.Close(); err != nil {
+		return 0, 0, err
+	}
+	if err = os.Rename(f.Name(), path); err != nil {
+		return 0, 0, err
+	}
+	return n, cw.n, nil
+}
+
+// marshalObject returns the snapshot record kind and payload for the object, or a
+// kind of 0 when the object can't be included in the snapshot
+func (c *Cache) marshalObject(o *index.Object) (byte, []byte, error) {
+	if o.ReferenceValue == nil {
+		if o.Value == nil {
+			return 0, nil, nil
+		}
+		return recordBytes, o.Value, nil
+	}
+	// reference objects are left out of snapshots when no codec is registered
+	rc := cache.GetReferenceCodec()
+	if rc == nil {
+		return 0, nil, nil
+	}
+	// the object's key lock is held while marshaling, so that requests holding
+	// the write lock can't modify the reference object in the meantime
+	if c.locker != nil {
+		nl, err := c.locker.RAcquire(o.Key)
+		if err != nil {
+			return 0, nil, err
+		}
+		defer nl.RRelease()
+	}
+	b, err := rc.MarshalReference(o.ReferenceValue)
+	if err != nil {
+		return 0, nil, err
+	}
+	return recordReference, b, nil
+}
+
+// Restore loads the unexpired objects from the configured snapshot path into the
+// cache. Objects that are already in the cache are not overwritten, so Restore can
+// run while the cache is serving requests. It is a no-op when no snapshot path is
+// configured or the snapshot file does not exist
+func (c *Cache) Restore() error {
+	if c.Config == nil || c.Config.Memory == nil || c.Config.Memory.SnapshotPath == "" {
+		return nil
+	}
+	path := c.Config.Memory.SnapshotPath
+	start := time.Now()
+	n, err := c.readSnapshot(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		metrics.ObserveCacheOperation(c.Name, c.Config.Provider, "restore", "error", 0)
+		tl.Error(c.Logger, "memory cache snapshot restore failed",
+			tl.Pairs{"cacheName": c.Name, "path": path, "objects": n, "detail": err.Error()})
+		return err
+	}
+	metrics.ObserveCacheOperation(c.Name, c.Config.Provider, "restore", "none", float64(n))
+	tl.Info(c.Logger, "memory cache snapshot restored",
+		tl.Pairs{
+			"cacheName": c.Name, "path": path, "objects": n,
+			"elapsedMS": time.Since(start).Milliseconds(),
+		})
+	return nil
+}
+
+// readSnapshot loads the snapshot at path and returns the number of objects restored
+func (c *Cache) readSnapshot(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	r := &recordReader{r: bufio.NewReader(f), remaining: fi.Size()}
+	magic := make([]byte, len(snapshotMagic))
+	if _, err = io.ReadFull(r, magic); err != nil || string(magic) != snapshotMagic {
+		return 0, ErrInvalidSnapshot
+	}
+	var n int
+	for {
+		kind, key, exp, b, err := readRecord(r)
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		var ttl time.Duration
+		if !exp.IsZero() {
+			if ttl = time.Until(exp); ttl <= 0 {
+				continue
+			}
+		}
+		o := &index.Object{Key: key, Expiration: exp}
+		switch kind {
+		case recordBytes:
+			o.Value = b
+		case recordReference:
+			rc := cache.GetReferenceCodec()
+			if rc == nil {
+				continue
+			}
+			ro, err := rc.UnmarshalReference(b)
+			if err != nil {
+				tl.Warn(c.Logger, "memory cache restore skipped object",
+					tl.Pairs{"cacheName": c.Name, "cacheKey": key, "detail": err.Error()})
+				continue
+			}
+			o.ReferenceValue = ro
+		default:
+			return n, ErrInvalidSnapshot
+		}
+		if c.storeIfAbsent(o) {
+			n++
+		}
+	}
+}
+
+// storeIfAbsent stores the object, and adds it to the index, only if the cache
+// does not already hold an object with the same key
+func (c *Cache) storeIfAbsent(o *index.Object) bool {
+	sh := c.shard(o.Key)
+	sh.mtx.Lock()
+	if _, ok := sh.objects[o.Key]; ok {
+		sh.mtx.Unlock()
+		return false
+	}
+	sh.objects[o.Key] = o
+	sh.mtx.Unlock()
+	ic := *o
+	c.Index.UpdateObject(&ic)
+	return true
+}
+
+// writeRecord writes a snapshot record: the kind, the length-prefixed key, the
+// expiration in Unix nanoseconds (0 for none) and the length-prefixed value
+func writeRecord(w *bufio.Writer, kind byte, key string, exp time.Time, b []byte) error {
+	var buf [binary.MaxVarintLen64]byte
+	if err := w.WriteByte(kind); err != nil {
+		return err
+	}
+	if _, err := w.Write(buf[:binary.PutUvarint(buf[:], uint64(len(key)))]); err != nil {
+		return err
+	}
+	if _, err := w.WriteString(key); err != nil {
+		return err
+	}
+	var ns int64
+	if !exp.IsZero() {
+		ns = exp.UnixNano()
+	}
+	if _, err := w.Write(buf[:binary.PutVarint(buf[:], ns)]); err != nil {
+		return err
+	}
+	if _, err := w.Write(buf[:binary.PutUvarint(buf[:], uint64(len(b)))]); err != nil {
+		return err
+	}
+	_, err := w.Write(b)
+	return err
+}
+
+// recordReader reads snapshot records while tracking the number of bytes left in
+// the snapshot file, which bounds the length of any record field
+type recordReader struct {
+	r         *bufio.Reader
+	remaining int64
+}
+
+func (rr *recordReader) Read(p []byte) (int, error) {
+	n, err := rr.r.Read(p)
+	rr.remaining -= int64(n)
+	return n, err
+}
+
+func (rr *recordReader) ReadByte() (byte, error) {
+	b, err := rr.r.ReadByte()
+	if err == nil {
+		rr.remaining--
+	}
+	return b, err
+}
+
+// readRecord reads a snapshot record written by writeRecord. It returns io.EOF
+// only when there are no more records
+func readRecord(r *recordReader) (byte, string, time.Time, []byte, error) {
+	var exp time.Time
+	kind, err := r.ReadByte()
+	if err != nil {
+		return 0, "", exp, nil, err
+	}
+	key, err := readField(r)
+	if err != nil {
+		return 0, "", exp, nil, err
+	}
+	ns, err := binary.ReadVarint(r)
+	if err != nil {
+		return 0, "", exp, nil, unexpectedEOF(err)
+	}
+	if ns != 0 {
+		exp = time.Unix(0, ns)
+	}
+	b, err := readField(r)
+	if err != nil {
+		return 0, "", exp, nil, err
+	}
+	return kind, string(key), exp, b, nil
+}
+
+// readField reads a length-prefixed record field. A length longer than the rest
+// of the snapshot file can only come from a corrupt length prefix, so it is
+// rejected before anything is allocated for the field
+func readField(r *recordReader) ([]byte, error) {
+	l, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if r.remaining < 0 || l > uint64(r.remaining) {
+		return nil, fmt.Errorf("%w: field length %d", ErrInvalidSnapshot, l)
+	}
+	b := make([]byte, l)
+	if _, err = io.ReadFull(r, b); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return b, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
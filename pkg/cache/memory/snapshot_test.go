@@ -0,0 +1,206 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package memory
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/cache"
+	mo "github.com/trickstercache/trickster/v2/pkg/cache/memory/options"
+	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
+)
+
+type testSnapshotObject struct {
+	value string
+}
+
+func (r *testSnapshotObject) Size() int {
+	return len(r.value)
+}
+
+type testReferenceCodec struct{}
+
+func (testReferenceCodec) MarshalReference(ro cache.ReferenceObject) ([]byte, error) {
+	o, ok := ro.(*testSnapshotObject)
+	if !ok {
+		return nil, errors.New("unsupported")
+	}
+	return []byte(o.value), nil
+}
+
+func (testReferenceCodec) UnmarshalReference(b []byte) (cache.ReferenceObject, error) {
+	return &testSnapshotObject{value: string(b)}, nil
+}
+
+func newSnapshotCache(t *testing.T, path string) *Cache {
+	cacheConfig := newCacheConfig(t)
+	cacheConfig.Memory = &mo.Options{SnapshotPath: path}
+	mc := &Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: testLocker}
+	if err := mc.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	return mc
+}
+
+func TestCache_Snapshot(t *testing.T) {
+	cache.RegisterReferenceCodec(testReferenceCodec{})
+	defer cache.RegisterReferenceCodec(nil)
+
+	path := filepath.Join(t.TempDir(), "memory.snapshot")
+	mc := newSnapshotCache(t, path)
+	mc.Store("bytes", []byte("data"), time.Minute)
+	mc.Store("forever", []byte("data2"), 0)
+	mc.StoreReference("ref", &testSnapshotObject{value: "refdata"}, time.Minute)
+	mc.StoreReference("unsupported", &testReferenceObject{}, time.Minute)
+	mc.Store("expired", []byte("data3"), time.Minute)
+	mc.Index.UpdateObjectTTL("expired", -time.Second)
+	if err := mc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	}
+
+	mc2 := newSnapshotCache(t, path)
+	defer mc2.Close()
+	mc2.restoring.Wait()
+
+	b, _, err := mc2.Retrieve("bytes", false)
+	if err != nil || string(b) != "data" {
+		t.Errorf("expected %s got %s (%v)", "data", string(b), err)
+	}
+	if exp := mc2.Index.GetExpiration("bytes"); time.Until(exp) <= 0 || time.Until(exp) > time.Minute {
+		t.Errorf("expected restored expiration, got %v", exp)
+	}
+	b, _, err = mc2.Retrieve("forever", false)
+	if err != nil || string(b) != "data2" {
+		t.Errorf("expected %s got %s (%v)", "data2", string(b), err)
+	}
+	if exp := mc2.Index.GetExpiration("forever"); !exp.IsZero() {
+		t.Errorf("expected zero expiration, got %v", exp)
+	}
+	ifc, _, err := mc2.RetrieveReference("ref", false)
+	if o, ok := ifc.(*testSnapshotObject); err != nil || !ok || o.value != "refdata" {
+		t.Errorf("expected restored reference object, got %v (%v)", ifc, err)
+	}
+	for _, key := range []string{"expired", "unsupported"} {
+		if _, _, err = mc2.Retrieve(key, true); err != cache.ErrKNF {
+			t.Errorf("expected %v for %s got %v", cache.ErrKNF, key, err)
+		}
+	}
+	if mc2.Index.Count() != 3 {
+		t.Errorf("expected %d got %d", 3, mc2.Index.Count())
+	}
+}
+
+func TestCache_RestoreKeepsNewerObjects(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.snapshot")
+	mc := newSnapshotCache(t, path)
+	mc.Store(cacheKey, []byte("old"), time.Minute)
+	mc.Close()
+
+	mc2 := newSnapshotCache(t, path)
+	defer mc2.Close()
+	mc2.restoring.Wait()
+	mc2.Store(cacheKey, []byte("new"), time.Minute)
+	if n, err := mc2.readSnapshot(path); err != nil || n != 0 {
+		t.Errorf("expected 0 restored objects got %d (%v)", n, err)
+	}
+	b, _, _ := mc2.Retrieve(cacheKey, false)
+	if string(b) != "new" {
+		t.Errorf("expected %s got %s", "new", string(b))
+	}
+}
+
+func TestCache_RestoreInvalid(t *testing.T) {
+	dir := t.TempDir()
+	mc := newSnapshotCache(t, filepath.Join(dir, "missing.snapshot"))
+	mc.restoring.Wait()
+	if err := mc.Restore(); err != nil {
+		t.Error(err)
+	}
+	mc.Close()
+
+	path := filepath.Join(dir, "invalid.snapshot")
+	if err := os.WriteFile(path, []byte("not a snapshot"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	mc = newSnapshotCache(t, path)
+	mc.restoring.Wait()
+	if err := mc.Restore(); !errors.Is(err, ErrInvalidSnapshot) {
+		t.Errorf("expected %v got %v", ErrInvalidSnapshot, err)
+	}
+
+	// a field length longer than the rest of the file is rejected before allocating
+	corrupt := binary.AppendUvarint([]byte(snapshotMagic+string(recordBytes)), 1<<30)
+	if err := os.WriteFile(path, append(corrupt, "key"...), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := mc.Restore(); !errors.Is(err, ErrInvalidSnapshot) {
+		t.Errorf("expected %v got %v", ErrInvalidSnapshot, err)
+	}
+
+	// a truncated snapshot restores the complete records that precede the truncation
+	mc.Store(cacheKey, []byte("data"), time.Minute)
+	mc.Store("key2", []byte("data2"), time.Minute)
+	mc.Close()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(path, b[:len(b)-2], 0o600); err != nil {
+		t.Fatal(err)
+	}
+	mc = newSnapshotCache(t, path)
+	defer mc.Close()
+	mc.restoring.Wait()
+	if mc.Index.Count() != 1 {
+		t.Errorf("expected %d got %d", 1, mc.Index.Count())
+	}
+}
+
+func TestCache_Snapshotter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.snapshot")
+	cacheConfig := newCacheConfig(t)
+	cacheConfig.Memory = &mo.Options{SnapshotPath: path, SnapshotIntervalMS: 10}
+	mc := &Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: testLocker}
+	if err := mc.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	mc.Store(cacheKey, []byte("data"), time.Minute)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected a periodic snapshot")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := mc.Close(); err != nil {
+		t.Error(err)
+	}
+	// closing again is a no-op
+	if err := mc.Close(); err != nil {
+		t.Error(err)
+	}
+}
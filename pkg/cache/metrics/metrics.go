@@ -51,8 +51,24 @@ func ObserveCacheEvent(cache, cacheProvider, event, reason string) {
 	metrics.CacheEvents.WithLabelValues(cache, cacheProvider, event, reason).Inc()
 }
 
+// ObserveCacheEvictions records the number of objects evicted from a cache under the eviction policy
+func ObserveCacheEvictions(cache, cacheProvider, policy, reason string, count int) {
+	metrics.CacheEvictions.WithLabelValues(cache, cacheProvider, policy, reason).Add(float64(count))
+}
+
 // ObserveCacheSizeChange adjust counters and gauges as the cache size changes due to object operations
 func ObserveCacheSizeChange(cache, cacheProvider string, byteCount, objectCount int64) {
 	metrics.CacheObjects.WithLabelValues(cache, cacheProvider).Set(float64(objectCount))
 	metrics.CacheBytes.WithLabelValues(cache, cacheProvider).Set(float64(byteCount))
 }
+
+// ObserveCacheCorruption records a corrupt object being removed from a cache, upon being
+// read from the cache ("read") or verified by the cache's scrubber ("scrub")
+func ObserveCacheCorruption(cache, cacheProvider, source string) {
+	metrics.CacheCorruptObjects.WithLabelValues(cache, cacheProvider, source).Inc()
+}
+
+// ObserveCacheTierLookup increments counters as lookups occur on the tiers of a tiered cache
+func ObserveCacheTierLookup(cache, tier, status string) {
+	metrics.CacheTierLookups.WithLabelValues(cache, tier, status).Inc()
+}
@@ -56,3 +56,11 @@ func TestObserveCacheEvent(t *testing.T) {
 func TestObserveCacheSizeChange(t *testing.T) {
 	ObserveCacheSizeChange(testCacheName, testCacheProvider, 0, 0)
 }
+
+func TestObserveCacheTierLookup(t *testing.T) {
+	ObserveCacheTierLookup(testCacheName, "l1", "hit")
+}
+
+func TestObserveCacheCorruption(t *testing.T) {
+	ObserveCacheCorruption(testCacheName, testCacheProvider, "read")
+}
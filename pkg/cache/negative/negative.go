@@ -15,12 +15,14 @@
  */
 
 // Package negative defines the Negative Cache
-// which is a simple lookup map of httpStatus to TTL in milliseconds
+// which is a simple lookup map of httpStatus to TTL in milliseconds, along
+// with Rules that match responses by status class, headers and body content
 package negative
 
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -32,7 +34,9 @@ func New() Config {
 	return Config{}
 }
 
-// Config is a collection of response codes and their TTLs in milliseconds
+// Config is a collection of response codes and their TTLs in milliseconds.
+// A key may also be a status class (e.g., '5xx'), which applies to every code
+// in the class that is not otherwise listed explicitly
 type Config map[string]int
 
 // Lookup is a collection of response codes and their TTLs as Durations
@@ -66,7 +70,23 @@ func (l ConfigLookup) Validate() (Lookups, error) {
 	}
 	for k, n := range l {
 		lk := make(Lookup)
+		// status classes are expanded first so that explicit codes take precedence
 		for c, t := range n {
+			lo, hi, ok := parseClass(c)
+			if !ok {
+				continue
+			}
+			if lo < 400 {
+				return nil, fmt.Errorf(`invalid negative cache config in %s: %s is not >= 400 and < 600`, k, c)
+			}
+			for ci := lo; ci <= hi; ci++ {
+				lk[ci] = time.Duration(t) * time.Millisecond
+			}
+		}
+		for c, t := range n {
+			if _, _, ok := parseClass(c); ok {
+				continue
+			}
 			ci, err := strconv.Atoi(c)
 			if err != nil {
 				return nil, fmt.Errorf(`invalid negative cache config in %s: %s is not a valid status code`, k, c)
@@ -80,3 +100,13 @@ func (l ConfigLookup) Validate() (Lookups, error) {
 	}
 	return ml, nil
 }
+
+// parseClass returns the inclusive range of status codes covered by a status
+// class like '5xx', and false if the input is not a valid status class
+func parseClass(s string) (int, int, bool) {
+	if len(s) != 3 || !strings.EqualFold(s[1:], "xx") || s[0] < '1' || s[0] > '5' {
+		return 0, 0, false
+	}
+	lo := int(s[0]-'0') * 100
+	return lo, lo + 99, true
+}
@@ -0,0 +1,47 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package negative
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	l := ConfigLookup{"test": Config{"5xx": 1000, "503": 2000}}
+	ml, err := l.Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lk := ml.Get("test")
+	if len(lk) != 100 {
+		t.Errorf("expected %d got %d", 100, len(lk))
+	}
+	if lk[500] != time.Second {
+		t.Errorf("expected %s got %s", time.Second, lk[500])
+	}
+	if lk[503] != 2*time.Second {
+		t.Errorf("expected %s got %s", 2*time.Second, lk[503])
+	}
+
+	for _, c := range []string{"3xx", "399", "600", "abc"} {
+		_, err = ConfigLookup{"test": Config{c: 1000}}.Validate()
+		if err == nil {
+			t.Errorf("expected error for %s", c)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package negative
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// ActionCache caches the matching response for the Rule's TTL
+	ActionCache = "cache"
+	// ActionServeLastGood never caches the matching response, and serves the
+	// last good cached response in its place when one is available
+	ActionServeLastGood = "serve_last_good"
+)
+
+// MaxBodySize is the maximum number of bytes of a response body, after any content
+// decoding, that is inspected by body_regex and json_path matchers. Only the first
+// MaxBodySize bytes of a longer body are matched, so a json_path matcher never
+// matches a longer body
+const MaxBodySize = 1 << 20
+
+// ErrNoRuleMatchers is an error for a negative cache rule that has nothing to match on
+var ErrNoRuleMatchers = errors.New(
+	"negative cache rule must set at least one of status, headers, body_regex or json_path")
+
+// RuleOptions defines a negative cache rule that matches upstream responses
+// by status code or class, response headers and body content. All matchers
+// that are set must match for the rule to apply.
+type RuleOptions struct {
+	// Status is an exact status code (e.g., '503') or a status class (e.g., '5xx')
+	Status string `json:"status,omitempty"`
+	// Headers maps response header names to regular expressions their values must match
+	Headers map[string]string `json:"headers,omitempty"`
+	// BodyRegex is a regular expression the response body must match
+	BodyRegex string `json:"body_regex,omitempty"`
+	// JSONPath is a dot-separated path into a JSON response body (e.g., 'status' or 'data.0.error')
+	JSONPath string `json:"json_path,omitempty"`
+	// JSONValue is the value expected at JSONPath; when empty, the path only needs to exist
+	JSONValue string `json:"json_value,omitempty"`
+	// Action is either 'cache' (the default) or 'serve_last_good'
+	Action string `json:"action,omitempty"`
+	// TTLMS is the TTL in milliseconds for responses matching a 'cache' rule
+	TTLMS int `json:"ttl_ms,omitempty"`
+}
+
+// Rule is a compiled RuleOptions that can be matched against a response
+type Rule struct {
+	Options *RuleOptions
+	// TTL is the parsed value of TTLMS
+	TTL time.Duration
+
+	codeMin  int
+	codeMax  int
+	headers  map[string]*regexp.Regexp
+	body     *regexp.Regexp
+	jsonPath []string
+}
+
+// Rules is an ordered list of Rules; the first matching Rule wins
+type Rules []*Rule
+
+// Clone returns an exact copy of the RuleOptions
+func (o *RuleOptions) Clone() *RuleOptions {
+	no := *o
+	if o.Headers != nil {
+		no.Headers = make(map[string]string, len(o.Headers))
+		for k, v := range o.Headers {
+			no.Headers[k] = v
+		}
+	}
+	return &no
+}
+
+// Compile validates the RuleOptions and returns a matchable Rule. The Rule holds
+// its own copy of the RuleOptions with defaults applied, leaving o unchanged.
+func (o *RuleOptions) Compile() (*Rule, error) {
+	if o.Status == "" && len(o.Headers) == 0 && o.BodyRegex == "" && o.JSONPath == "" {
+		return nil, ErrNoRuleMatchers
+	}
+	o = o.Clone()
+	if o.Action == "" {
+		o.Action = ActionCache
+	}
+	r := &Rule{Options: o, codeMax: 599, TTL: time.Duration(o.TTLMS) * time.Millisecond}
+	switch o.Action {
+	case ActionCache:
+		if o.TTLMS <= 0 {
+			return nil, fmt.Errorf("negative cache rule with action '%s' requires a positive ttl_ms",
+				ActionCache)
+		}
+	case ActionServeLastGood:
+	default:
+		return nil, fmt.Errorf("invalid negative cache rule action: %s", o.Action)
+	}
+	if o.Status != "" {
+		if lo, hi, ok := parseClass(o.Status); ok {
+			r.codeMin, r.codeMax = lo, hi
+		} else {
+			c, err := strconv.Atoi(o.Status)
+			if err != nil || c < 100 || c >= 600 {
+				return nil, fmt.Errorf("invalid negative cache rule status: %s", o.Status)
+			}
+			r.codeMin, r.codeMax = c, c
+		}
+	}
+	if len(o.Headers) > 0 {
+		r.headers = make(map[string]*regexp.Regexp, len(o.Headers))
+		for k, v := range o.Headers {
+			re, err := regexp.Compile(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid negative cache rule header regex for %s: %w", k, err)
+			}
+			r.headers[http.CanonicalHeaderKey(k)] = re
+		}
+	}
+	if o.BodyRegex != "" {
+		re, err := regexp.Compile(o.BodyRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid negative cache rule body regex: %w", err)
+		}
+		r.body = re
+	}
+	if o.JSONPath != "" {
+		r.jsonPath = strings.Split(o.JSONPath, ".")
+	}
+	return r, nil
+}
+
+// CompileRules compiles a list of RuleOptions into Rules
+func CompileRules(opts []*RuleOptions) (Rules, error) {
+	if len(opts) == 0 {
+		return nil, nil
+	}
+	rs := make(Rules, len(opts))
+	for i, o := range opts {
+		r, err := o.Compile()
+		if err != nil {
+			return nil, err
+		}
+		rs[i] = r
+	}
+	return rs, nil
+}
+
+// NeedsBody returns true if any Rule in the list inspects the response body
+func (rs Rules) NeedsBody() bool {
+	for _, r := range rs {
+		if r.body != nil || r.jsonPath != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// NeedsBodyFor returns true if any Rule in the list inspects the response body, and
+// its status and header matchers match the provided response, so that a body is
+// only read when it can affect the outcome
+func (rs Rules) NeedsBodyFor(code int, h http.Header) bool {
+	for _, r := range rs {
+		if (r.body != nil || r.jsonPath != nil) && r.matchesHead(code, h) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match returns the first Rule that matches the provided response, or nil. Only the
+// first MaxBodySize bytes of the body are inspected
+func (rs Rules) Match(code int, h http.Header, body []byte) *Rule {
+	if len(body) > MaxBodySize {
+		body = body[:MaxBodySize]
+	}
+	for _, r := range rs {
+		if r.Matches(code, h, body) {
+			return r
+		}
+	}
+	return nil
+}
+
+// Matches returns true if the provided response satisfies all of the Rule's matchers
+func (r *Rule) Matches(code int, h http.Header, body []byte) bool {
+	if !r.matchesHead(code, h) {
+		return false
+	}
+	if r.body != nil && !r.body.Match(body) {
+		return false
+	}
+	if r.jsonPath != nil {
+		v, ok := lookupJSONPath(body, r.jsonPath)
+		if !ok || (r.Options.JSONValue != "" && v != r.Options.JSONValue) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesHead returns true if the response satisfies the Rule's status and header matchers
+func (r *Rule) matchesHead(code int, h http.Header) bool {
+	if r.Options.Status != "" && (code < r.codeMin || code > r.codeMax) {
+		return false
+	}
+	for k, re := range r.headers {
+		if !re.MatchString(h.Get(k)) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsServeLastGood returns true if the Rule's action is serve_last_good
+func (r *Rule) IsServeLastGood() bool {
+	return r != nil && r.Options.Action == ActionServeLastGood
+}
+
+func lookupJSONPath(body []byte, path []string) (string, bool) {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return "", false
+	}
+	for _, p := range path {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if v, ok = t[p]; !ok {
+				return "", false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(p)
+			if err != nil || i < 0 || i >= len(t) {
+				return "", false
+			}
+			v = t[i]
+		default:
+			return "", false
+		}
+	}
+	switch t := v.(type) {
+	case string:
+		return t, true
+	case nil:
+		return "null", true
+	case map[string]interface{}, []interface{}:
+		return "", true
+	default:
+		return fmt.Sprint(t), true
+	}
+}
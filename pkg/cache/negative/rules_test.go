@@ -0,0 +1,147 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package negative
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompileRules(t *testing.T) {
+	rs, err := CompileRules(nil)
+	if err != nil || rs != nil {
+		t.Error("expected nil rules and nil error")
+	}
+
+	tests := []struct {
+		o      *RuleOptions
+		expErr bool
+	}{
+		{&RuleOptions{TTLMS: 1000}, true},
+		{&RuleOptions{Status: "5xx", TTLMS: 1000}, false},
+		{&RuleOptions{Status: "5xx"}, true},
+		{&RuleOptions{Status: "5xx", Action: ActionServeLastGood}, false},
+		{&RuleOptions{Status: "5xx", Action: "invalid"}, true},
+		{&RuleOptions{Status: "600", TTLMS: 1000}, true},
+		{&RuleOptions{Status: "x", TTLMS: 1000}, true},
+		{&RuleOptions{BodyRegex: "(", TTLMS: 1000}, true},
+		{&RuleOptions{Headers: map[string]string{"a": "("}, TTLMS: 1000}, true},
+	}
+	for i, test := range tests {
+		_, err := CompileRules([]*RuleOptions{test.o})
+		if (err != nil) != test.expErr {
+			t.Errorf("test %d: expected error %t got %v", i, test.expErr, err)
+		}
+	}
+
+	ro := &RuleOptions{Status: "503", TTLMS: 1500}
+	rs, _ = CompileRules([]*RuleOptions{ro})
+	if ro.Action != "" {
+		t.Errorf("expected compile to leave the options unchanged, got action %s", ro.Action)
+	}
+	if rs[0].TTL != 1500*time.Millisecond {
+		t.Errorf("expected %s got %s", 1500*time.Millisecond, rs[0].TTL)
+	}
+	if rs[0].Options.Action != ActionCache {
+		t.Errorf("expected %s got %s", ActionCache, rs[0].Options.Action)
+	}
+	if rs.NeedsBody() {
+		t.Error("expected false")
+	}
+}
+
+func TestRulesMatch(t *testing.T) {
+	rs, err := CompileRules([]*RuleOptions{
+		{Status: "200", JSONPath: "status", JSONValue: "error", Action: ActionServeLastGood},
+		{Status: "422", BodyRegex: "query timed out", TTLMS: 1000},
+		{Status: "5xx", Headers: map[string]string{"x-upstream": "^overloaded$"}, TTLMS: 2000},
+		{JSONPath: "data.0.errorType", TTLMS: 3000},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rs.NeedsBody() {
+		t.Error("expected true")
+	}
+
+	h := http.Header{"X-Upstream": []string{"overloaded"}}
+	tests := []struct {
+		code int
+		h    http.Header
+		body string
+		exp  int
+	}{
+		{200, nil, `{"status":"error","error":"x"}`, 0},
+		{200, nil, `{"status":"success"}`, -1},
+		{200, nil, `not json`, -1},
+		{422, nil, `{"error":"query timed out"}`, 1},
+		{422, nil, `{"error":"bad query"}`, -1},
+		{503, h, ``, 2},
+		{503, nil, ``, -1},
+		{400, nil, `{"data":[{"errorType":"bad_data"}]}`, 3},
+		{400, nil, `{"data":[]}`, -1},
+	}
+	for i, test := range tests {
+		r := rs.Match(test.code, test.h, []byte(test.body))
+		if test.exp < 0 {
+			if r != nil {
+				t.Errorf("test %d: expected no match", i)
+			}
+			continue
+		}
+		if r != rs[test.exp] {
+			t.Errorf("test %d: expected rule %d", i, test.exp)
+		}
+	}
+
+	// the body is only needed when a body rule's status and headers match
+	if !rs.NeedsBodyFor(200, nil) || !rs.NeedsBodyFor(400, nil) {
+		t.Error("expected true")
+	}
+	rs = rs[:3]
+	if rs.NeedsBodyFor(503, h) || rs.NeedsBodyFor(404, nil) {
+		t.Error("expected false")
+	}
+
+	// only the first MaxBodySize bytes of the body are inspected
+	body := []byte(strings.Repeat(" ", MaxBodySize) + "query timed out")
+	if r := rs.Match(422, nil, body); r != nil {
+		t.Error("expected no match beyond MaxBodySize")
+	}
+	if r := rs.Match(422, nil, body[MaxBodySize-10:]); r != rs[1] {
+		t.Error("expected rule 1")
+	}
+
+	if !rs[0].IsServeLastGood() || rs[1].IsServeLastGood() {
+		t.Error("unexpected serve_last_good result")
+	}
+	var r *Rule
+	if r.IsServeLastGood() {
+		t.Error("expected false")
+	}
+}
+
+func TestRuleOptionsClone(t *testing.T) {
+	o := &RuleOptions{Status: "5xx", Headers: map[string]string{"a": "b"}, TTLMS: 1}
+	o2 := o.Clone()
+	o2.Headers["a"] = "c"
+	if o.Headers["a"] != "b" {
+		t.Error("clone shares headers map")
+	}
+}
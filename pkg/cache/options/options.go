@@ -23,11 +23,18 @@ import (
 
 	badger "github.com/trickstercache/trickster/v2/pkg/cache/badger/options"
 	bbolt "github.com/trickstercache/trickster/v2/pkg/cache/bbolt/options"
+	encryption "github.com/trickstercache/trickster/v2/pkg/cache/encryption/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache/envelope"
 	filesystem "github.com/trickstercache/trickster/v2/pkg/cache/filesystem/options"
 	index "github.com/trickstercache/trickster/v2/pkg/cache/index/options"
+	memcached "github.com/trickstercache/trickster/v2/pkg/cache/memcached/options"
+	memory "github.com/trickstercache/trickster/v2/pkg/cache/memory/options"
 	"github.com/trickstercache/trickster/v2/pkg/cache/options/defaults"
 	"github.com/trickstercache/trickster/v2/pkg/cache/providers"
 	redis "github.com/trickstercache/trickster/v2/pkg/cache/redis/options"
+	s3 "github.com/trickstercache/trickster/v2/pkg/cache/s3/options"
+	tiered "github.com/trickstercache/trickster/v2/pkg/cache/tiered/options"
+	locker "github.com/trickstercache/trickster/v2/pkg/locks/options"
 	strutil "github.com/trickstercache/trickster/v2/pkg/util/strings"
 	"github.com/trickstercache/trickster/v2/pkg/util/yamlx"
 )
@@ -43,20 +50,42 @@ type Options struct {
 	Provider string `json:"provider,omitempty"`
 	// Index provides options for the Cache Index
 	Index *index.Options `json:"index,omitempty"`
+	// Memory provides options for Memory caching
+	Memory *memory.Options `json:"memory,omitempty"`
 	// Redis provides options for Redis caching
 	Redis *redis.Options `json:"redis,omitempty"`
+	// Memcached provides options for Memcached caching
+	Memcached *memcached.Options `json:"memcached,omitempty"`
+	// S3 provides options for S3-compatible object storage caching
+	S3 *s3.Options `json:"s3,omitempty"`
 	// Filesystem provides options for Filesystem caching
 	Filesystem *filesystem.Options `json:"filesystem,omitempty"`
 	// BBolt provides options for BBolt caching
 	BBolt *bbolt.Options `json:"bbolt,omitempty"`
 	// Badger provides options for BadgerDB caching
 	Badger *badger.Options `json:"badger,omitempty"`
+	// Locker provides options for the cache's Named Locker, which is used for collapsed forwarding
+	Locker *locker.Options `json:"locker,omitempty"`
+	// Tiered provides options for Tiered caching
+	Tiered *tiered.Options `json:"tiered,omitempty"`
+	// Codec is the encoding used to compress serialized documents: brotli (default),
+	// zstd, gzip, deflate, snappy or identity. It does not apply to the memory provider
+	Codec string `json:"codec,omitempty"`
+	// Encryption provides options for encrypting serialized documents at rest
+	Encryption *encryption.Options `json:"encryption,omitempty"`
 
 	//  Synthetic Values
 
 	// ProviderID represents the internal constant for the provided Provider string
 	// and is automatically populated at startup
 	ProviderID providers.Provider `json:"-"`
+	// L1Options and L2Options are the options of the caches referenced by a Tiered
+	// cache's L1CacheName and L2CacheName, and are automatically populated at startup
+	L1Options *Options `json:"-"`
+	L2Options *Options `json:"-"`
+	// Envelope seals and opens serialized documents per the Codec and Encryption
+	// options, and is automatically populated at startup
+	Envelope *envelope.Envelope `json:"-"`
 }
 
 // New will return a pointer to a CacheOptions with the default configuration settings
@@ -65,10 +94,16 @@ func New() *Options {
 		Provider:   defaults.DefaultCacheProvider,
 		ProviderID: defaults.DefaultCacheProviderID,
 		Redis:      redis.New(),
+		Memory:     memory.New(),
+		Memcached:  memcached.New(),
+		S3:         s3.New(),
 		Filesystem: filesystem.New(),
 		BBolt:      bbolt.New(),
 		Badger:     badger.New(),
 		Index:      index.New(),
+		Locker:     locker.New(),
+		Tiered:     tiered.New(),
+		Encryption: encryption.New(),
 	}
 }
 
@@ -78,6 +113,8 @@ func (cc *Options) Clone() *Options {
 	c.Name = cc.Name
 	c.Provider = cc.Provider
 	c.ProviderID = cc.ProviderID
+	c.Codec = cc.Codec
+	c.Envelope = cc.Envelope
 
 	c.Index.FlushInterval = cc.Index.FlushInterval
 	c.Index.FlushIntervalMS = cc.Index.FlushIntervalMS
@@ -87,6 +124,9 @@ func (cc *Options) Clone() *Options {
 	c.Index.MaxSizeObjects = cc.Index.MaxSizeObjects
 	c.Index.ReapInterval = cc.Index.ReapInterval
 	c.Index.ReapIntervalMS = cc.Index.ReapIntervalMS
+	c.Index.EvictionPolicy = cc.Index.EvictionPolicy
+	c.Index.ShardCount = cc.Index.ShardCount
+	c.Index.ScrubIntervalMS = cc.Index.ScrubIntervalMS
 
 	c.Badger.Directory = cc.Badger.Directory
 	c.Badger.ValueDirectory = cc.Badger.ValueDirectory
@@ -116,6 +156,38 @@ func (cc *Options) Clone() *Options {
 	c.Redis.SentinelMaster = cc.Redis.SentinelMaster
 	c.Redis.WriteTimeoutMS = cc.Redis.WriteTimeoutMS
 
+	if cc.Memory != nil {
+		c.Memory = cc.Memory.Clone()
+	}
+
+	if cc.Memcached != nil {
+		c.Memcached = cc.Memcached.Clone()
+	}
+
+	if cc.S3 != nil {
+		c.S3 = cc.S3.Clone()
+	}
+
+	if cc.Locker != nil {
+		c.Locker = cc.Locker.Clone()
+	}
+
+	if cc.Tiered != nil {
+		c.Tiered = cc.Tiered.Clone()
+	}
+
+	if cc.Encryption != nil {
+		c.Encryption = cc.Encryption.Clone()
+	}
+
+	if cc.L1Options != nil {
+		c.L1Options = cc.L1Options.Clone()
+	}
+
+	if cc.L2Options != nil {
+		c.L2Options = cc.L2Options.Clone()
+	}
+
 	return c
 }
 
@@ -128,12 +200,29 @@ func (cc *Options) Equal(cc2 *Options) bool {
 
 	return cc.Name == cc2.Name &&
 		cc.Provider == cc2.Provider &&
-		cc.ProviderID == cc2.ProviderID
+		cc.ProviderID == cc2.ProviderID &&
+		cc.Memory.Equal(cc2.Memory) &&
+		cc.Locker.Equal(cc2.Locker) &&
+		cc.Tiered.Equal(cc2.Tiered) &&
+		cc.Codec == cc2.Codec &&
+		cc.Encryption.Equal(cc2.Encryption) &&
+		tierEqual(cc.L1Options, cc2.L1Options) &&
+		tierEqual(cc.L2Options, cc2.L2Options)
+}
+
+func tierEqual(o1, o2 *Options) bool {
+	if o1 == nil || o2 == nil {
+		return o1 == o2
+	}
+	return o1.Equal(o2)
 }
 
 var (
-	errMaxSizeBackoffBytesTooBig   = errors.New("MaxSizeBackoffBytes can't be larger than MaxSizeBytes")
-	errMaxSizeBackoffObjectsTooBig = errors.New("MaxSizeBackoffObjects can't be larger than MaxSizeObjects")
+	errDistributedLockerRequiresRedis = errors.New("the redis locker provider requires the redis cache provider")
+	errMaxSizeBackoffBytesTooBig      = errors.New("MaxSizeBackoffBytes can't be larger than MaxSizeBytes")
+	errMaxSizeBackoffObjectsTooBig    = errors.New("MaxSizeBackoffObjects can't be larger than MaxSizeObjects")
+	errMemcachedRequiresServers       = errors.New("the memcached cache provider requires at least one server")
+	errNestedTieredCache              = errors.New("a tiered cache can't use another tiered cache as a tier")
 )
 
 // SetDefaults iterates the provided Options, and overlays user-set values onto the default Options
@@ -142,9 +231,23 @@ func (l Lookup) SetDefaults(metadata yamlx.KeyLookup, activeCaches strutil.Looku
 
 	lw := make([]string, 0)
 
+	// caches referenced as a tier of an active tiered cache are instantiated by
+	// the tiered cache, so they are processed even if no backend uses them
+	tierRefs := make(map[string]bool)
 	for k, v := range l {
+		if _, ok := activeCaches[k]; !ok || v == nil || v.Tiered == nil ||
+			strings.ToLower(v.Provider) != providers.Tiered.String() {
+			continue
+		}
+		tierRefs[v.Tiered.L1CacheName] = true
+		tierRefs[v.Tiered.L2CacheName] = true
+	}
 
-		if _, ok := activeCaches[k]; !ok {
+	tieredCaches := make([]*Options, 0)
+
+	for k, v := range l {
+
+		if _, ok := activeCaches[k]; !ok && !tierRefs[k] {
 			// a configured cache was not used by any backend. don't even instantiate it
 			delete(l, k)
 			continue
@@ -192,6 +295,22 @@ func (l Lookup) SetDefaults(metadata yamlx.KeyLookup, activeCaches strutil.Looku
 			return nil, errMaxSizeBackoffObjectsTooBig
 		}
 
+		if metadata.IsDefined("caches", k, "index", "shard_count") {
+			cc.Index.ShardCount = v.Index.ShardCount
+		}
+
+		if metadata.IsDefined("caches", k, "index", "eviction_policy") {
+			cc.Index.EvictionPolicy = strings.ToLower(v.Index.EvictionPolicy)
+		}
+
+		if metadata.IsDefined("caches", k, "index", "scrub_interval_ms") {
+			cc.Index.ScrubIntervalMS = v.Index.ScrubIntervalMS
+		}
+
+		if err := cc.Index.Validate(); err != nil {
+			return nil, err
+		}
+
 		if cc.ProviderID == providers.Redis {
 
 			var hasEndpoint, hasEndpoints bool
@@ -289,6 +408,90 @@ func (l Lookup) SetDefaults(metadata yamlx.KeyLookup, activeCaches strutil.Looku
 			}
 		}
 
+		if cc.ProviderID == providers.Memory {
+			if metadata.IsDefined("caches", k, "memory", "snapshot_path") {
+				cc.Memory.SnapshotPath = v.Memory.SnapshotPath
+			}
+
+			if metadata.IsDefined("caches", k, "memory", "snapshot_interval_ms") {
+				cc.Memory.SnapshotIntervalMS = v.Memory.SnapshotIntervalMS
+			}
+		}
+
+		if cc.ProviderID == providers.Memcached {
+
+			if metadata.IsDefined("caches", k, "memcached", "servers") {
+				cc.Memcached.Servers = v.Memcached.Servers
+			}
+
+			if len(cc.Memcached.Servers) == 0 {
+				return nil, errMemcachedRequiresServers
+			}
+
+			if metadata.IsDefined("caches", k, "memcached", "dial_timeout_ms") {
+				cc.Memcached.DialTimeoutMS = v.Memcached.DialTimeoutMS
+			}
+
+			if metadata.IsDefined("caches", k, "memcached", "timeout_ms") {
+				cc.Memcached.TimeoutMS = v.Memcached.TimeoutMS
+			}
+
+			if metadata.IsDefined("caches", k, "memcached", "max_idle_conns_per_server") {
+				cc.Memcached.MaxIdleConnsPerServer = v.Memcached.MaxIdleConnsPerServer
+			}
+
+			if metadata.IsDefined("caches", k, "memcached", "max_item_size_bytes") {
+				cc.Memcached.MaxItemSizeBytes = v.Memcached.MaxItemSizeBytes
+			}
+
+			if metadata.IsDefined("caches", k, "memcached", "disable_chunking") {
+				cc.Memcached.DisableChunking = v.Memcached.DisableChunking
+			}
+		}
+
+		if cc.ProviderID == providers.S3 {
+
+			if metadata.IsDefined("caches", k, "s3", "endpoint") {
+				cc.S3.Endpoint = v.S3.Endpoint
+			}
+
+			if metadata.IsDefined("caches", k, "s3", "region") {
+				cc.S3.Region = v.S3.Region
+			}
+
+			if metadata.IsDefined("caches", k, "s3", "bucket") {
+				cc.S3.Bucket = v.S3.Bucket
+			}
+
+			if metadata.IsDefined("caches", k, "s3", "prefix") {
+				cc.S3.Prefix = v.S3.Prefix
+			}
+
+			if metadata.IsDefined("caches", k, "s3", "path_style") {
+				cc.S3.PathStyle = v.S3.PathStyle
+			}
+
+			if metadata.IsDefined("caches", k, "s3", "access_key_id") {
+				cc.S3.AccessKeyID = v.S3.AccessKeyID
+			}
+
+			if metadata.IsDefined("caches", k, "s3", "secret_access_key") {
+				cc.S3.SecretAccessKey = v.S3.SecretAccessKey
+			}
+
+			if metadata.IsDefined("caches", k, "s3", "session_token") {
+				cc.S3.SessionToken = v.S3.SessionToken
+			}
+
+			if metadata.IsDefined("caches", k, "s3", "timeout_ms") {
+				cc.S3.TimeoutMS = v.S3.TimeoutMS
+			}
+
+			if err := cc.S3.Validate(); err != nil {
+				return nil, err
+			}
+		}
+
 		if metadata.IsDefined("caches", k, "filesystem", "cache_path") {
 			cc.Filesystem.CachePath = v.Filesystem.CachePath
 		}
@@ -309,8 +512,75 @@ func (l Lookup) SetDefaults(metadata yamlx.KeyLookup, activeCaches strutil.Looku
 			cc.Badger.ValueDirectory = v.Badger.ValueDirectory
 		}
 
+		if metadata.IsDefined("caches", k, "locker") && v.Locker != nil {
+			cc.Locker = v.Locker.Clone()
+		}
+
+		if err := cc.Locker.Validate(); err != nil {
+			return nil, err
+		}
+
+		if metadata.IsDefined("caches", k, "codec") {
+			cc.Codec = strings.ToLower(v.Codec)
+		}
+
+		if metadata.IsDefined("caches", k, "encryption") && v.Encryption != nil {
+			cc.Encryption = v.Encryption.Clone()
+		}
+
+		if err := cc.Encryption.Validate(); err != nil {
+			return nil, fmt.Errorf("cache %s: %w", k, err)
+		}
+
+		e, err := envelope.New(cc.Codec, cc.Encryption)
+		if err != nil {
+			return nil, fmt.Errorf("cache %s: %w", k, err)
+		}
+		cc.Envelope = e
+
+		if cc.ProviderID == providers.Tiered {
+			if metadata.IsDefined("caches", k, "tiered") && v.Tiered != nil {
+				cc.Tiered = v.Tiered.Clone()
+			}
+			if err := cc.Tiered.Validate(); err != nil {
+				return nil, err
+			}
+			tieredCaches = append(tieredCaches, cc)
+		} else if cc.Locker.Provider == locker.ProviderRedis && cc.ProviderID != providers.Redis {
+			return nil, errDistributedLockerRequiresRedis
+		}
+
 		l[k] = cc
 	}
+
+	for _, cc := range tieredCaches {
+		l1, ok := l[cc.Tiered.L1CacheName]
+		if !ok {
+			return nil, fmt.Errorf("tiered cache %s references unknown l1 cache: %s",
+				cc.Name, cc.Tiered.L1CacheName)
+		}
+		l2, ok := l[cc.Tiered.L2CacheName]
+		if !ok {
+			return nil, fmt.Errorf("tiered cache %s references unknown l2 cache: %s",
+				cc.Name, cc.Tiered.L2CacheName)
+		}
+		if l1.ProviderID == providers.Tiered || l2.ProviderID == providers.Tiered {
+			return nil, errNestedTieredCache
+		}
+		// the tiered cache's distributed locker shares the L2 cache's connection
+		if cc.Locker.Provider == locker.ProviderRedis && l2.ProviderID != providers.Redis {
+			return nil, errDistributedLockerRequiresRedis
+		}
+		cc.L1Options, cc.L2Options = l1, l2
+	}
+
+	// caches that are only used as a tier are not instantiated on their own
+	for k := range tierRefs {
+		if _, ok := activeCaches[k]; !ok {
+			delete(l, k)
+		}
+	}
+
 	return lw, nil
 }
 
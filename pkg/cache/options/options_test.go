@@ -17,10 +17,14 @@
 package options
 
 import (
+	"fmt"
 	"strings"
 	"testing"
 
+	io "github.com/trickstercache/trickster/v2/pkg/cache/index/options"
 	"github.com/trickstercache/trickster/v2/pkg/cache/providers"
+	s3 "github.com/trickstercache/trickster/v2/pkg/cache/s3/options"
+	tiered "github.com/trickstercache/trickster/v2/pkg/cache/tiered/options"
 	strutil "github.com/trickstercache/trickster/v2/pkg/util/strings"
 	"github.com/trickstercache/trickster/v2/pkg/util/yamlx"
 )
@@ -117,6 +121,302 @@ func TestSetDefaults(t *testing.T) {
 	}
 }
 
+func TestSetDefaultsMemcached(t *testing.T) {
+	const y = `
+caches:
+  default:
+    provider: memcached
+    memcached:
+      servers: %s
+      timeout_ms: 250
+      max_item_size_bytes: 2097152
+`
+	ac := strutil.Lookup{"default": nil}
+
+	kl, err := yamlx.GetKeyList(fmt.Sprintf(y, "[ 127.0.0.1:11211 ]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := New()
+	o.Provider = "memcached"
+	o.Memcached.Servers = []string{"127.0.0.1:11211"}
+	o.Memcached.TimeoutMS = 250
+	o.Memcached.MaxItemSizeBytes = 2097152
+	l := Lookup{"default": o}
+	if _, err = l.SetDefaults(kl, ac); err != nil {
+		t.Fatal(err)
+	}
+	mo := l["default"].Memcached
+	if mo.TimeoutMS != 250 || mo.MaxItemSizeBytes != 2097152 || len(mo.Servers) != 1 {
+		t.Errorf("unexpected memcached options %+v", mo)
+	}
+	if c := l["default"].Clone(); c.Memcached.Servers[0] != mo.Servers[0] {
+		t.Error("expected cloned memcached options")
+	}
+
+	kl, err = yamlx.GetKeyList(fmt.Sprintf(y, "[]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.Memcached.Servers = nil
+	l = Lookup{"default": o}
+	if _, err = l.SetDefaults(kl, ac); err != errMemcachedRequiresServers {
+		t.Errorf("expected %v got %v", errMemcachedRequiresServers, err)
+	}
+}
+
+func TestSetDefaultsS3(t *testing.T) {
+	const y = `
+caches:
+  default:
+    provider: s3
+    s3:
+      endpoint: http://minio:9000
+      bucket: %s
+      path_style: true
+`
+	ac := strutil.Lookup{"default": nil}
+
+	kl, err := yamlx.GetKeyList(fmt.Sprintf(y, "trickster"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := New()
+	o.Provider = "s3"
+	o.S3.Endpoint = "http://minio:9000"
+	o.S3.Bucket = "trickster"
+	o.S3.PathStyle = true
+	l := Lookup{"default": o}
+	if _, err = l.SetDefaults(kl, ac); err != nil {
+		t.Fatal(err)
+	}
+	so := l["default"].S3
+	if so.Bucket != "trickster" || !so.PathStyle || so.Region != "us-east-1" {
+		t.Errorf("unexpected s3 options %+v", so)
+	}
+	if c := l["default"].Clone(); *c.S3 != *so {
+		t.Error("expected cloned s3 options")
+	}
+
+	kl, err = yamlx.GetKeyList(fmt.Sprintf(y, `""`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.S3.Bucket = ""
+	l = Lookup{"default": o}
+	if _, err = l.SetDefaults(kl, ac); err != s3.ErrNoBucket {
+		t.Errorf("expected %v got %v", s3.ErrNoBucket, err)
+	}
+}
+
+func TestSetDefaultsMemory(t *testing.T) {
+	const y = `
+caches:
+  default:
+    provider: memory
+    memory:
+      snapshot_path: /tmp/memory.snapshot
+`
+	kl, err := yamlx.GetKeyList(y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := New()
+	o.Memory.SnapshotPath = "/tmp/memory.snapshot"
+	l := Lookup{"default": o}
+	if _, err = l.SetDefaults(kl, strutil.Lookup{"default": nil}); err != nil {
+		t.Fatal(err)
+	}
+	mo := l["default"].Memory
+	if mo.SnapshotPath != "/tmp/memory.snapshot" || mo.SnapshotIntervalMS != 300000 {
+		t.Errorf("unexpected memory options %+v", mo)
+	}
+	c := l["default"].Clone()
+	if !c.Equal(l["default"]) {
+		t.Error("expected cloned memory options")
+	}
+	c.Memory.SnapshotPath = ""
+	if c.Equal(l["default"]) {
+		t.Error("expected unequal memory options")
+	}
+}
+
+func TestSetDefaultsEvictionPolicy(t *testing.T) {
+	const y = `
+caches:
+  default:
+    index:
+      eviction_policy: %s
+`
+	ac := strutil.Lookup{"default": nil}
+	tests := []struct {
+		policy   string
+		expected error
+	}{
+		{"LFU", nil},
+		{"mru", io.ErrInvalidEvictionPolicy},
+	}
+	for _, test := range tests {
+		kl, err := yamlx.GetKeyList(fmt.Sprintf(y, test.policy))
+		if err != nil {
+			t.Fatal(err)
+		}
+		o := New()
+		o.Index.EvictionPolicy = test.policy
+		l := Lookup{"default": o}
+		if _, err = l.SetDefaults(kl, ac); err != test.expected {
+			t.Errorf("expected %v got %v", test.expected, err)
+		}
+		if err == nil && l["default"].Index.EvictionPolicy != io.EvictionPolicyLFU {
+			t.Errorf("expected %s got %s", io.EvictionPolicyLFU, l["default"].Index.EvictionPolicy)
+		}
+	}
+}
+
+func TestSetDefaultsLocker(t *testing.T) {
+	const y = `
+caches:
+  default:
+    provider: %s
+    locker:
+      provider: %s
+`
+	ac := strutil.Lookup{"default": nil}
+	tests := []struct {
+		cacheProvider, lockerProvider string
+		expectErr                     bool
+	}{
+		{"redis", "redis", false},
+		{"memory", "local", false},
+		{"memory", "redis", true},
+		{"redis", "invalid", true},
+	}
+	for _, test := range tests {
+		kl, err := yamlx.GetKeyList(fmt.Sprintf(y, test.cacheProvider, test.lockerProvider))
+		if err != nil {
+			t.Fatal(err)
+		}
+		o := New()
+		o.Provider = test.cacheProvider
+		o.Locker.Provider = test.lockerProvider
+		l := Lookup{"default": o}
+		_, err = l.SetDefaults(kl, ac)
+		if (err != nil) != test.expectErr {
+			t.Errorf("unexpected error result for %s/%s: %v", test.cacheProvider,
+				test.lockerProvider, err)
+			continue
+		}
+		if err == nil && l["default"].Locker.Provider != test.lockerProvider {
+			t.Errorf("expected %s got %s", test.lockerProvider, l["default"].Locker.Provider)
+		}
+	}
+}
+
+func TestSetDefaultsTiered(t *testing.T) {
+	const y = `
+caches:
+  default:
+    provider: tiered
+    tiered:
+      l1_cache_name: mem
+      l2_cache_name: %s
+  mem:
+    provider: memory
+  shared:
+    provider: redis
+`
+	ac := strutil.Lookup{"default": nil}
+	newLookup := func(l2 string) Lookup {
+		o := New()
+		o.Provider = "tiered"
+		o.Tiered = &tiered.Options{L1CacheName: "mem", L2CacheName: l2}
+		r := New()
+		r.Provider = "redis"
+		return Lookup{"default": o, "mem": New(), "shared": r}
+	}
+
+	kl, err := yamlx.GetKeyList(fmt.Sprintf(y, "shared"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := newLookup("shared")
+	if _, err = l.SetDefaults(kl, ac); err != nil {
+		t.Fatal(err)
+	}
+	if len(l) != 1 {
+		t.Errorf("expected tier-only caches to be removed, got %d caches", len(l))
+	}
+	o := l["default"]
+	if o.ProviderID != providers.Tiered {
+		t.Errorf("expected %s got %s", providers.Tiered, o.ProviderID)
+	}
+	if o.L1Options == nil || o.L1Options.ProviderID != providers.Memory {
+		t.Error("expected memory l1 options")
+	}
+	if o.L2Options == nil || o.L2Options.ProviderID != providers.Redis {
+		t.Error("expected redis l2 options")
+	}
+	if o.Tiered.WriteMode != tiered.DefaultWriteMode {
+		t.Errorf("expected %s got %s", tiered.DefaultWriteMode, o.Tiered.WriteMode)
+	}
+	if !o.Equal(o.Clone()) {
+		t.Error("expected clone to be equal")
+	}
+
+	kl, err = yamlx.GetKeyList(fmt.Sprintf(y, "missing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l = newLookup("missing")
+	if _, err = l.SetDefaults(kl, ac); err == nil {
+		t.Error("expected error for unknown l2 cache")
+	}
+
+	kl, err = yamlx.GetKeyList(fmt.Sprintf(y, "default"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l = newLookup("default")
+	if _, err = l.SetDefaults(kl, ac); err != errNestedTieredCache {
+		t.Errorf("expected %v got %v", errNestedTieredCache, err)
+	}
+}
+
+func TestSetDefaultsCodec(t *testing.T) {
+	const y = `
+caches:
+  default:
+    provider: redis
+    codec: %s
+`
+	ac := strutil.Lookup{"default": nil}
+	for _, test := range []struct {
+		codec     string
+		expectErr bool
+	}{
+		{"zstd", false},
+		{"identity", false},
+		{"invalid", true},
+	} {
+		kl, err := yamlx.GetKeyList(fmt.Sprintf(y, test.codec))
+		if err != nil {
+			t.Fatal(err)
+		}
+		o := New()
+		o.Provider = "redis"
+		o.Codec = test.codec
+		l := Lookup{"default": o}
+		_, err = l.SetDefaults(kl, ac)
+		if (err != nil) != test.expectErr {
+			t.Errorf("unexpected error result for %s: %v", test.codec, err)
+			continue
+		}
+		if err == nil && l["default"].Envelope == nil {
+			t.Errorf("expected envelope for %s", test.codec)
+		}
+	}
+}
+
 const testYAML = `
 caches:
   default:
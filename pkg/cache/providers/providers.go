@@ -32,6 +32,12 @@ const (
 	Bbolt
 	// BadgerDB indicates a BadgerDB cache
 	BadgerDB
+	// Tiered indicates a Tiered cache composed of two other caches
+	Tiered
+	// Memcached indicates a Memcached cache
+	Memcached
+	// S3 indicates an S3-compatible object storage cache
+	S3
 )
 
 // Names is a map of cache providers keyed by name
@@ -41,6 +47,9 @@ var Names = map[string]Provider{
 	"redis":      Redis,
 	"bbolt":      Bbolt,
 	"badger":     BadgerDB,
+	"tiered":     Tiered,
+	"memcached":  Memcached,
+	"s3":         S3,
 }
 
 // Values is a map of cache providers keyed by internal id
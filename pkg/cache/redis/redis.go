@@ -56,6 +56,11 @@ func (c *Cache) SetLocker(l locks.NamedLocker) {
 	c.locker = l
 }
 
+// Client returns the cache's Redis client, which is nil until the cache is connected
+func (c *Cache) Client() redis.Cmdable {
+	return c.client
+}
+
 // Configuration returns the Configuration for the Cache object
 func (c *Cache) Configuration() *options.Options {
 	return c.Config
@@ -137,6 +142,16 @@ func (c *Cache) SetTTL(cacheKey string, ttl time.Duration) {
 	c.client.Expire(context.Background(), cacheKey, ttl)
 }
 
+// RemainingTTL returns the time until the object expires, and false if the
+// object does not exist, has no expiration, or its TTL can't be retrieved
+func (c *Cache) RemainingTTL(cacheKey string) (time.Duration, bool) {
+	d, err := c.client.PTTL(context.Background(), cacheKey).Result()
+	if err != nil || d < 0 {
+		return 0, false
+	}
+	return d, true
+}
+
 // BulkRemove removes a list of objects from the cache. noLock is not used for Redis
 func (c *Cache) BulkRemove(cacheKeys []string) {
 	tl.Debug(c.Logger, "redis cache bulk remove", tl.Pairs{})
@@ -24,10 +24,15 @@ import (
 	"github.com/trickstercache/trickster/v2/pkg/cache/badger"
 	"github.com/trickstercache/trickster/v2/pkg/cache/bbolt"
 	"github.com/trickstercache/trickster/v2/pkg/cache/filesystem"
+	"github.com/trickstercache/trickster/v2/pkg/cache/memcached"
 	"github.com/trickstercache/trickster/v2/pkg/cache/memory"
 	"github.com/trickstercache/trickster/v2/pkg/cache/options"
 	"github.com/trickstercache/trickster/v2/pkg/cache/redis"
+	"github.com/trickstercache/trickster/v2/pkg/cache/s3"
+	"github.com/trickstercache/trickster/v2/pkg/cache/tiered"
 	"github.com/trickstercache/trickster/v2/pkg/locks"
+	lo "github.com/trickstercache/trickster/v2/pkg/locks/options"
+	redislock "github.com/trickstercache/trickster/v2/pkg/locks/redis"
 )
 
 // Cache Interface Types
@@ -36,6 +41,9 @@ const (
 	ctRedis      = "redis"
 	ctBBolt      = "bbolt"
 	ctBadger     = "badger"
+	ctTiered     = "tiered"
+	ctMemcached  = "memcached"
+	ctS3         = "s3"
 )
 
 // Caches maintains a list of active caches
@@ -49,16 +57,27 @@ const (
 // 	return nil, fmt.Errorf("Could not find Cache named [%s]", cacheName)
 // }
 
-// LoadCachesFromConfig iterates the Caching Config and Connects/Maps each Cache
+// LoadCachesFromConfig iterates the Caching Config and Connects/Maps each Cache.
+// Tiered caches are created last, so that they use the standalone caches as tiers
 func LoadCachesFromConfig(conf *config.Config, logger interface{}) map[string]cache.Cache {
 	caches := make(map[string]cache.Cache)
-	for k, v := range conf.Caches {
-		c := NewCache(k, v, logger)
-		caches[k] = c
+	for _, tiered := range []bool{false, true} {
+		for k, v := range conf.Caches {
+			if IsTiered(v) == tiered {
+				caches[k] = NewCacheWithTiers(k, v, logger, func(name string) cache.Cache {
+					return caches[name]
+				})
+			}
+		}
 	}
 	return caches
 }
 
+// IsTiered returns true if the cache options are for a tiered cache
+func IsTiered(cfg *options.Options) bool {
+	return cfg != nil && cfg.Provider == ctTiered
+}
+
 // CloseCaches iterates the set of caches and closes each
 func CloseCaches(caches map[string]cache.Cache) error {
 	for _, c := range caches {
@@ -69,8 +88,18 @@ func CloseCaches(caches map[string]cache.Cache) error {
 	return nil
 }
 
-// NewCache returns a Cache object based on the provided config.CachingConfig
+// NewCache returns a Cache object based on the provided config.CachingConfig.
+// The tiers of a tiered cache are created as private caches named after the tier
 func NewCache(cacheName string, cfg *options.Options, logger interface{}) cache.Cache {
+	return NewCacheWithTiers(cacheName, cfg, logger, nil)
+}
+
+// NewCacheWithTiers returns a Cache object like NewCache, except that a tiered
+// cache uses the non-nil caches returned by tiers for its L1 and L2, so that a
+// cache that is also configured as a standalone cache isn't opened twice
+func NewCacheWithTiers(cacheName string, cfg *options.Options, logger interface{},
+	tiers func(string) cache.Cache,
+) cache.Cache {
 	var c cache.Cache
 
 	switch cfg.Provider {
@@ -82,6 +111,12 @@ func NewCache(cacheName string, cfg *options.Options, logger interface{}) cache.
 		c = &bbolt.Cache{Name: cacheName, Config: cfg, Logger: logger}
 	case ctBadger:
 		c = &badger.Cache{Name: cacheName, Config: cfg, Logger: logger}
+	case ctMemcached:
+		c = &memcached.Cache{Name: cacheName, Config: cfg, Logger: logger}
+	case ctS3:
+		c = &s3.Cache{Name: cacheName, Config: cfg, Logger: logger}
+	case ctTiered:
+		c = newTieredCache(cacheName, cfg, logger, tiers)
 	default:
 		// Default to MemoryCache
 		c = &memory.Cache{Name: cacheName, Config: cfg, Logger: logger}
@@ -89,5 +124,44 @@ func NewCache(cacheName string, cfg *options.Options, logger interface{}) cache.
 
 	c.SetLocker(locks.NewNamedLocker())
 	c.Connect()
+
+	// the redis locker shares the cache's connection, so it is set once connected
+	if cfg.Locker != nil && cfg.Locker.Provider == lo.ProviderRedis {
+		rc, ok := c.(*redis.Cache)
+		if tc, isTiered := c.(*tiered.Cache); isTiered {
+			rc, ok = tc.L2.(*redis.Cache)
+		}
+		if ok && rc.Client() != nil {
+			c.SetLocker(redislock.New(cacheName, rc.Client(), cfg.Locker, logger))
+		}
+	}
 	return c
 }
+
+// newTieredCache returns a tiered cache, whose L1 and L2 caches are those returned
+// by tiers, or are otherwise instantiated and connected as private instances named
+// after the tier
+func newTieredCache(cacheName string, cfg *options.Options, logger interface{},
+	tiers func(string) cache.Cache,
+) cache.Cache {
+	tc := &tiered.Cache{Name: cacheName, Config: cfg, Logger: logger}
+	if tiers != nil && cfg.Tiered != nil {
+		tc.L1, tc.L2 = tiers(cfg.Tiered.L1CacheName), tiers(cfg.Tiered.L2CacheName)
+		tc.SharedL1, tc.SharedL2 = tc.L1 != nil, tc.L2 != nil
+	}
+	if tc.L1 == nil {
+		l1o := cfg.L1Options
+		if l1o == nil {
+			l1o = options.New()
+		}
+		tc.L1 = NewCache(cacheName+".l1", l1o, logger)
+	}
+	if tc.L2 == nil {
+		l2o := cfg.L2Options
+		if l2o == nil {
+			l2o = options.New()
+		}
+		tc.L2 = NewCache(cacheName+".l2", l2o, logger)
+	}
+	return tc
+}
@@ -27,6 +27,8 @@ import (
 	co "github.com/trickstercache/trickster/v2/pkg/cache/options"
 	"github.com/trickstercache/trickster/v2/pkg/cache/providers"
 	ro "github.com/trickstercache/trickster/v2/pkg/cache/redis/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache/tiered"
+	to "github.com/trickstercache/trickster/v2/pkg/cache/tiered/options"
 	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
 )
 
@@ -70,6 +72,31 @@ func TestLoadCachesFromConfig(t *testing.T) {
 	}
 }
 
+func TestLoadCachesFromConfigSharedTiers(t *testing.T) {
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-log-level", "debug", "-origin-url", "http://1", "-provider", "test"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+	tc := newCacheConfig(t, "tiered")
+	tc.Tiered = &to.Options{L1CacheName: "mem1", L2CacheName: "mem2"}
+	conf.Caches = co.Lookup{
+		"mem1":   newCacheConfig(t, "memory"),
+		"mem2":   newCacheConfig(t, "memory"),
+		"tiered": tc,
+	}
+
+	caches := LoadCachesFromConfig(conf, tl.ConsoleLogger("error"))
+	defer CloseCaches(caches)
+	c, ok := caches["tiered"].(*tiered.Cache)
+	if !ok {
+		t.Fatal("expected tiered cache")
+	}
+	if c.L1 != caches["mem1"] || c.L2 != caches["mem2"] || !c.SharedL1 || !c.SharedL2 {
+		t.Error("expected the tiered cache to use the standalone caches as its tiers")
+	}
+}
+
 func newCacheConfig(t *testing.T, cacheProvider string) *co.Options {
 	bd := "."
 	fd := "."
@@ -0,0 +1,243 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package s3
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	so "github.com/trickstercache/trickster/v2/pkg/cache/s3/options"
+	"github.com/trickstercache/trickster/v2/pkg/util/sigv4"
+)
+
+// maxDeleteObjects is the maximum number of keys in a multi-object delete request
+const maxDeleteObjects = 1000
+
+var errNotFound = errors.New("s3: object not found")
+
+// client is a minimal S3 REST API client
+type client struct {
+	base      *url.URL
+	bucket    string
+	region    string
+	pathStyle bool
+	creds     sigv4.Credentials
+	hc        *http.Client
+}
+
+func newClient(o *so.Options) (*client, error) {
+	endpoint := o.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + o.Region + ".amazonaws.com"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	creds := sigv4.Credentials{
+		AccessKeyID:     o.AccessKeyID,
+		SecretAccessKey: o.SecretAccessKey,
+		SessionToken:    o.SessionToken,
+	}
+	if !creds.Valid() {
+		creds = sigv4.EnvCredentials()
+	}
+	return &client{
+		base:      u,
+		bucket:    o.Bucket,
+		region:    o.Region,
+		pathStyle: o.PathStyle,
+		creds:     creds,
+		hc:        &http.Client{Timeout: time.Duration(o.TimeoutMS) * time.Millisecond},
+	}, nil
+}
+
+// url returns the URL of the object key, or of the bucket when key is empty
+func (c *client) url(key string) *url.URL {
+	u := *c.base
+	p := u.Path + "/"
+	if c.pathStyle {
+		p += c.bucket + "/"
+	} else {
+		u.Host = c.bucket + "." + u.Host
+	}
+	p += key
+	u.Path = p
+	// the request path must be encoded exactly as it is signed
+	u.RawPath = sigv4.Escape(p, false)
+	return &u
+}
+
+func (c *client) do(method string, u *url.URL, body []byte, h http.Header) (*http.Response, error) {
+	r, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	r.ContentLength = int64(len(body))
+	for k, v := range h {
+		r.Header[k] = v
+	}
+	if c.creds.Valid() {
+		sigv4.Sign(r, c.creds, c.region, "s3", sigv4.PayloadHash(body), time.Now())
+	}
+	return c.hc.Do(r)
+}
+
+// responseError returns an error describing a failed response, and closes its body
+func responseError(resp *http.Response) error {
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	var e struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if xml.Unmarshal(b, &e) == nil && e.Code != "" {
+		return fmt.Errorf("s3: %s: %s: %s", resp.Status, e.Code, e.Message)
+	}
+	return fmt.Errorf("s3: %s", resp.Status)
+}
+
+func metaHeaders(meta map[string]string) http.Header {
+	h := make(http.Header, len(meta))
+	for k, v := range meta {
+		h.Set("X-Amz-Meta-"+k, v)
+	}
+	return h
+}
+
+// put stores the object with the provided user metadata
+func (c *client) put(key string, data []byte, meta map[string]string) error {
+	h := metaHeaders(meta)
+	h.Set("Content-Type", "application/octet-stream")
+	resp, err := c.do(http.MethodPut, c.url(key), data, h)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return nil
+}
+
+// get returns the object and its response headers, or errNotFound
+func (c *client) get(key string) ([]byte, http.Header, error) {
+	resp, err := c.do(http.MethodGet, c.url(key), nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, nil, errNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, responseError(resp)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return b, resp.Header, nil
+}
+
+// delete removes the object
+func (c *client) delete(key string) error {
+	resp, err := c.do(http.MethodDelete, c.url(key), nil, nil)
+	if err != nil {
+		return err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		resp.Body.Close()
+		return nil
+	}
+	return responseError(resp)
+}
+
+type deleteObject struct {
+	Key string `xml:"Key"`
+}
+
+type deleteRequest struct {
+	XMLName xml.Name       `xml:"Delete"`
+	Quiet   bool           `xml:"Quiet"`
+	Objects []deleteObject `xml:"Object"`
+}
+
+// deleteMulti removes the objects using multi-object delete requests
+func (c *client) deleteMulti(keys []string) error {
+	var errs []error
+	for len(keys) > 0 {
+		n := min(len(keys), maxDeleteObjects)
+		dr := deleteRequest{Quiet: true, Objects: make([]deleteObject, n)}
+		for i, k := range keys[:n] {
+			dr.Objects[i].Key = k
+		}
+		keys = keys[n:]
+		body, err := xml.Marshal(dr)
+		if err != nil {
+			return err
+		}
+		sum := md5.Sum(body)
+		h := http.Header{}
+		h.Set("Content-Md5", base64.StdEncoding.EncodeToString(sum[:]))
+		h.Set("Content-Type", "application/xml")
+		u := c.url("")
+		u.RawQuery = "delete="
+		resp, err := c.do(http.MethodPost, u, body, h)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			errs = append(errs, responseError(resp))
+			continue
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	return errors.Join(errs...)
+}
+
+// replaceMeta replaces the object's user metadata by copying the object onto itself
+func (c *client) replaceMeta(key string, meta map[string]string) error {
+	h := metaHeaders(meta)
+	h.Set("X-Amz-Copy-Source", sigv4.Escape("/"+c.bucket+"/"+key, false))
+	h.Set("X-Amz-Metadata-Directive", "REPLACE")
+	resp, err := c.do(http.MethodPut, c.url(key), nil, h)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return nil
+}
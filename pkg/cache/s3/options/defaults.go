@@ -0,0 +1,26 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+const (
+	// DefaultRegion is the default S3 region
+	DefaultRegion = "us-east-1"
+	// DefaultPrefix is the default prefix of object keys in the bucket
+	DefaultPrefix = "trickster/"
+	// DefaultTimeoutMS is the default timeout of S3 requests
+	DefaultTimeoutMS = 5000
+)
@@ -0,0 +1,84 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"errors"
+	"net/url"
+)
+
+// Options is a collection of Configurations for storing cached data in an
+// S3-compatible object store
+type Options struct {
+	// Endpoint is the URL of the S3 API, such as http://minio:9000. When empty,
+	// the AWS S3 endpoint for the Region is used
+	Endpoint string `json:"endpoint,omitempty"`
+	// Region is the region of the bucket, used for request signing
+	Region string `json:"region,omitempty"`
+	// Bucket is the name of the bucket in which objects are stored
+	Bucket string `json:"bucket,omitempty"`
+	// Prefix is prepended to the key of each object stored in the bucket
+	Prefix string `json:"prefix,omitempty"`
+	// PathStyle addresses the bucket in the request path (endpoint/bucket/key),
+	// rather than as a virtual host (bucket.endpoint/key). MinIO typically requires it
+	PathStyle bool `json:"path_style,omitempty"`
+	// AccessKeyID, SecretAccessKey and SessionToken are the credentials used to sign
+	// requests. When empty, the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+	// AWS_SESSION_TOKEN environment variables are used, and if those are also empty,
+	// requests are not signed
+	AccessKeyID     string `json:"access_key_id,omitempty"`
+	SecretAccessKey string `json:"secret_access_key,omitempty"`
+	SessionToken    string `json:"session_token,omitempty"`
+	// TimeoutMS is the timeout of each request to S3
+	TimeoutMS int `json:"timeout_ms,omitempty"`
+}
+
+var (
+	// ErrNoBucket is returned when a bucket is not provided
+	ErrNoBucket = errors.New("the s3 cache provider requires a bucket")
+	// ErrInvalidEndpoint is returned when the endpoint is not an absolute URL
+	ErrInvalidEndpoint = errors.New("the s3 endpoint must be an absolute http or https URL")
+)
+
+// New returns a new S3 Options Reference with default values set
+func New() *Options {
+	return &Options{
+		Region:    DefaultRegion,
+		Prefix:    DefaultPrefix,
+		TimeoutMS: DefaultTimeoutMS,
+	}
+}
+
+// Clone returns an exact copy of the subject Options
+func (o *Options) Clone() *Options {
+	c := *o
+	return &c
+}
+
+// Validate returns an error if the Options are invalid
+func (o *Options) Validate() error {
+	if o.Bucket == "" {
+		return ErrNoBucket
+	}
+	if o.Endpoint != "" {
+		u, err := url.Parse(o.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return ErrInvalidEndpoint
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		o        *Options
+		expected error
+	}{
+		{New(), ErrNoBucket},
+		{&Options{Bucket: "trickster"}, nil},
+		{&Options{Bucket: "trickster", Endpoint: "http://minio:9000"}, nil},
+		{&Options{Bucket: "trickster", Endpoint: "minio:9000"}, ErrInvalidEndpoint},
+	}
+	for i, test := range tests {
+		if err := test.o.Validate(); err != test.expected {
+			t.Errorf("test %d: expected %v got %v", i, test.expected, err)
+		}
+	}
+}
+
+func TestClone(t *testing.T) {
+	o := New()
+	o.Bucket = "trickster"
+	if c := o.Clone(); *c != *o {
+		t.Error("expected identical clone")
+	}
+}
@@ -0,0 +1,249 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package s3 is the S3-compatible object storage implementation of the Trickster Cache
+package s3
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/cache"
+	"github.com/trickstercache/trickster/v2/pkg/cache/index"
+	"github.com/trickstercache/trickster/v2/pkg/cache/metrics"
+	"github.com/trickstercache/trickster/v2/pkg/cache/options"
+	so "github.com/trickstercache/trickster/v2/pkg/cache/s3/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache/status"
+	"github.com/trickstercache/trickster/v2/pkg/locks"
+	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
+)
+
+// metaExpires is the user metadata key holding the object's expiration, in unix milliseconds
+const metaExpires = "Trickster-Expires"
+
+// Cache describes an S3 Cache
+type Cache struct {
+	Name   string
+	Config *options.Options
+	Index  *index.Index
+	Logger interface{}
+	locker locks.NamedLocker
+	client *client
+	prefix string
+	// indexKey is the key under which this instance's Index is stored
+	indexKey string
+}
+
+// indexKey returns the key under which the Index of the named cache is stored.
+// Instances sharing a bucket each index the objects they write, so the key is
+// namespaced by the cache name and the host name, so that the instances do not
+// overwrite each other's Index
+func indexKey(cacheName string) string {
+	k := index.IndexKey + "." + cacheName
+	if h, err := os.Hostname(); err == nil && h != "" {
+		k += "." + h
+	}
+	return k
+}
+
+// Locker returns the cache's locker
+func (c *Cache) Locker() locks.NamedLocker {
+	return c.locker
+}
+
+// SetLocker sets the cache's locker
+func (c *Cache) SetLocker(l locks.NamedLocker) {
+	c.locker = l
+}
+
+// Configuration returns the Configuration for the Cache object
+func (c *Cache) Configuration() *options.Options {
+	return c.Config
+}
+
+// CacheIndex returns the Index that tracks the cache's objects
+func (c *Cache) CacheIndex() *index.Index {
+	return c.Index
+}
+
+// Connect validates the S3 configuration, loads the Index from the bucket
+// and starts the Expired Entry Reaper goroutine
+func (c *Cache) Connect() error {
+	o := c.Config.S3
+	if o == nil {
+		o = so.New()
+	}
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	tl.Info(c.Logger, "s3 cache setup", tl.Pairs{
+		"name":     c.Name,
+		"endpoint": o.Endpoint,
+		"bucket":   o.Bucket,
+		"prefix":   o.Prefix,
+	})
+	cl, err := newClient(o)
+	if err != nil {
+		return err
+	}
+	c.client = cl
+	c.prefix = o.Prefix
+	c.indexKey = indexKey(c.Name)
+
+	indexData, _, _ := c.retrieve(c.indexKey, false, false)
+	c.Index = index.NewIndex(c.Name, c.Config.Provider, indexData,
+		c.Config.Index, c.BulkRemove, c.flushIndex, c.Logger)
+	return nil
+}
+
+// flushIndex writes the serialized Index under this instance's index key
+func (c *Cache) flushIndex(_ string, data []byte) {
+	c.storeNoIndex(c.indexKey, data)
+}
+
+// Store places an object in the cache using the specified key and ttl
+func (c *Cache) Store(cacheKey string, data []byte, ttl time.Duration) error {
+	return c.store(cacheKey, data, ttl, true)
+}
+
+func (c *Cache) storeNoIndex(cacheKey string, data []byte) {
+	err := c.store(cacheKey, data, 31536000*time.Second, false)
+	if err != nil {
+		tl.Error(c.Logger,
+			"cache failed to write non-indexed object", tl.Pairs{
+				"cacheName":     c.Name,
+				"cacheProvider": "s3", "cacheKey": cacheKey, "objectSize": len(data),
+				"detail": err.Error(),
+			})
+	}
+}
+
+func (c *Cache) store(cacheKey string, data []byte, ttl time.Duration, updateIndex bool) error {
+	if ttl < 1 {
+		return fmt.Errorf("invalid ttl: %d", int64(ttl.Seconds()))
+	}
+
+	if cacheKey == "" {
+		return fmt.Errorf("cacheKey required")
+	}
+
+	metrics.ObserveCacheOperation(c.Name, c.Config.Provider, "set", "none", float64(len(data)))
+
+	exp := time.Now().Add(ttl)
+	err := c.client.put(c.prefix+cacheKey, data, expiresMeta(exp))
+	if err != nil {
+		return err
+	}
+	tl.Debug(c.Logger, "s3 cache store",
+		tl.Pairs{"key": cacheKey, "indexed": updateIndex})
+	if updateIndex {
+		c.Index.UpdateObject(&index.Object{Key: cacheKey, Value: data, Expiration: exp})
+	}
+	return nil
+}
+
+// Retrieve looks for an object in cache and returns it (or an error if not found)
+func (c *Cache) Retrieve(cacheKey string, allowExpired bool) ([]byte, status.LookupStatus, error) {
+	return c.retrieve(cacheKey, allowExpired, true)
+}
+
+func (c *Cache) retrieve(cacheKey string, allowExpired bool, atime bool) ([]byte, status.LookupStatus, error) {
+	data, h, err := c.client.get(c.prefix + cacheKey)
+	if err != nil {
+		if !errors.Is(err, errNotFound) {
+			tl.Error(c.Logger, "s3 cache retrieve failed",
+				tl.Pairs{"key": cacheKey, "detail": err.Error()})
+		}
+		tl.Debug(c.Logger, "s3 cache miss", tl.Pairs{"key": cacheKey})
+		metrics.ObserveCacheMiss(cacheKey, c.Name, c.Config.Provider)
+		return nil, status.LookupStatusKeyMiss, cache.ErrKNF
+	}
+
+	// objects are expired according to their own metadata, since the object
+	// may have been written by another Trickster instance sharing the bucket
+	var exp time.Time
+	if ms, err := strconv.ParseInt(h.Get("X-Amz-Meta-"+metaExpires), 10, 64); err == nil {
+		exp = time.UnixMilli(ms)
+	}
+	if allowExpired || exp.IsZero() || exp.After(time.Now()) {
+		tl.Debug(c.Logger, "s3 cache retrieve", tl.Pairs{"key": cacheKey})
+		if atime && c.Index != nil {
+			go c.Index.UpdateObjectAccessTime(cacheKey)
+		}
+		metrics.ObserveCacheOperation(c.Name, c.Config.Provider, "get", "hit", float64(len(data)))
+		return data, status.LookupStatusHit, nil
+	}
+	// Cache Object has been expired but not reaped, go ahead and delete it
+	go c.Remove(cacheKey)
+	metrics.ObserveCacheMiss(cacheKey, c.Name, c.Config.Provider)
+	return nil, status.LookupStatusKeyMiss, cache.ErrKNF
+}
+
+// SetTTL updates the TTL for the provided cache object
+func (c *Cache) SetTTL(cacheKey string, ttl time.Duration) {
+	err := c.client.replaceMeta(c.prefix+cacheKey, expiresMeta(time.Now().Add(ttl)))
+	if err != nil {
+		tl.Error(c.Logger, "s3 cache failed to update ttl",
+			tl.Pairs{"key": cacheKey, "detail": err.Error()})
+		return
+	}
+	go c.Index.UpdateObjectTTL(cacheKey, ttl)
+}
+
+// Remove removes an object from the cache
+func (c *Cache) Remove(cacheKey string) {
+	err := c.client.delete(c.prefix + cacheKey)
+	if err != nil {
+		tl.Error(c.Logger, "s3 cache remove failed",
+			tl.Pairs{"key": cacheKey, "detail": err.Error()})
+		return
+	}
+	go c.Index.RemoveObject(cacheKey)
+	metrics.ObserveCacheDel(c.Name, c.Config.Provider, 0)
+}
+
+// BulkRemove removes a list of objects from the cache using multi-object deletes
+func (c *Cache) BulkRemove(cacheKeys []string) {
+	if len(cacheKeys) == 0 {
+		return
+	}
+	keys := make([]string, len(cacheKeys))
+	for i, k := range cacheKeys {
+		keys[i] = c.prefix + k
+	}
+	if err := c.client.deleteMulti(keys); err != nil {
+		tl.Error(c.Logger, "s3 cache bulk remove failed",
+			tl.Pairs{"keyCount": len(keys), "detail": err.Error()})
+	}
+	for range cacheKeys {
+		metrics.ObserveCacheDel(c.Name, c.Config.Provider, 0)
+	}
+}
+
+// Close closes the Cache's Index
+func (c *Cache) Close() error {
+	if c.Index != nil {
+		c.Index.Close()
+	}
+	return nil
+}
+
+func expiresMeta(t time.Time) map[string]string {
+	return map[string]string{metaExpires: strconv.FormatInt(t.UnixMilli(), 10)}
+}
@@ -0,0 +1,242 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package s3
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/cache"
+	"github.com/trickstercache/trickster/v2/pkg/cache/index"
+	co "github.com/trickstercache/trickster/v2/pkg/cache/options"
+	so "github.com/trickstercache/trickster/v2/pkg/cache/s3/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache/status"
+	"github.com/trickstercache/trickster/v2/pkg/locks"
+)
+
+type testObject struct {
+	data []byte
+	meta http.Header
+}
+
+// testServer is a minimal path-style S3 server supporting the operations used by the client
+type testServer struct {
+	*httptest.Server
+	t        *testing.T
+	bucket   string
+	mtx      sync.Mutex
+	objects  map[string]*testObject
+	unsigned int
+}
+
+func newTestServer(t *testing.T, bucket string) *testServer {
+	s := &testServer{t: t, bucket: bucket, objects: make(map[string]*testObject)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *testServer) len() int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return len(s.objects)
+}
+
+func (s *testServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+		s.unsigned++
+	}
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+s.bucket+"/")
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	switch r.Method {
+	case http.MethodPut:
+		meta := http.Header{}
+		for k, v := range r.Header {
+			if strings.HasPrefix(k, "X-Amz-Meta-") {
+				meta[k] = v
+			}
+		}
+		if src := r.Header.Get("X-Amz-Copy-Source"); src != "" {
+			o, ok := s.objects[strings.TrimPrefix(src, "/"+s.bucket+"/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			s.objects[key] = &testObject{data: o.data, meta: meta}
+			return
+		}
+		s.objects[key] = &testObject{data: body, meta: meta}
+	case http.MethodGet:
+		o, ok := s.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("<Error><Code>NoSuchKey</Code></Error>"))
+			return
+		}
+		for k, v := range o.meta {
+			w.Header()[k] = v
+		}
+		w.Write(o.data)
+	case http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodPost:
+		if _, ok := r.URL.Query()["delete"]; !ok || r.Header.Get("Content-Md5") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var dr deleteRequest
+		if err := xml.Unmarshal(body, &dr); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for _, o := range dr.Objects {
+			delete(s.objects, o.Key)
+		}
+		w.Write([]byte("<DeleteResult></DeleteResult>"))
+	}
+}
+
+func newTestCache(t *testing.T, s *testServer) *Cache {
+	cfg := co.New()
+	cfg.Provider = "s3"
+	cfg.S3 = so.New()
+	cfg.S3.Endpoint = s.URL
+	cfg.S3.Bucket = s.bucket
+	cfg.S3.PathStyle = true
+	cfg.S3.AccessKeyID = "AKID"
+	cfg.S3.SecretAccessKey = "SECRET"
+	c := &Cache{Name: "test", Config: cfg}
+	c.SetLocker(locks.NewNamedLocker())
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestConnect(t *testing.T) {
+	cfg := co.New()
+	cfg.S3 = nil
+	c := &Cache{Name: "test", Config: cfg}
+	if err := c.Connect(); err != so.ErrNoBucket {
+		t.Errorf("expected %v got %v", so.ErrNoBucket, err)
+	}
+	if err := c.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStoreAndRetrieve(t *testing.T) {
+	s := newTestServer(t, "trickster")
+	c := newTestCache(t, s)
+
+	if err := c.Store("key", []byte("value"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.objects["trickster/key"]; !ok {
+		t.Error("expected prefixed object key")
+	}
+	b, ls, err := c.Retrieve("key", false)
+	if err != nil || ls != status.LookupStatusHit || string(b) != "value" {
+		t.Errorf("unexpected retrieve result: %s %s %v", string(b), ls, err)
+	}
+	if err = c.Store("key", []byte("value"), 0); err == nil {
+		t.Error("expected error for invalid ttl")
+	}
+
+	c.SetTTL("key", -time.Minute)
+	if _, _, err = c.Retrieve("key", true); err != nil {
+		t.Errorf("expected expired hit, got %v", err)
+	}
+	if _, ls, err = c.Retrieve("key", false); err != cache.ErrKNF ||
+		ls != status.LookupStatusKeyMiss {
+		t.Errorf("expected miss, got %s %v", ls, err)
+	}
+
+	c.Store("key", []byte("value"), time.Hour)
+	c.Remove("key")
+	if _, _, err = c.Retrieve("key", false); err != cache.ErrKNF {
+		t.Errorf("expected %v got %v", cache.ErrKNF, err)
+	}
+
+	c.Store("key1", []byte("value"), time.Hour)
+	c.Store("key2", []byte("value"), time.Hour)
+	c.BulkRemove([]string{"key1", "key2"})
+	if s.len() != 0 {
+		t.Errorf("expected empty bucket, got %d objects", s.len())
+	}
+	if s.unsigned != 0 {
+		t.Errorf("expected all requests to be signed, got %d unsigned", s.unsigned)
+	}
+}
+
+func TestURL(t *testing.T) {
+	o := so.New()
+	o.Bucket = "trickster"
+	cl, err := newClient(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expected = "https://trickster.s3.us-east-1.amazonaws.com/trickster/a%20b"
+	if u := cl.url("trickster/a b").String(); u != expected {
+		t.Errorf("expected %s got %s", expected, u)
+	}
+	o.Endpoint = "http://minio:9000/"
+	o.PathStyle = true
+	cl, _ = newClient(o)
+	if u := cl.url("key").String(); u != "http://minio:9000/trickster/key" {
+		t.Errorf("expected %s got %s", "http://minio:9000/trickster/key", u)
+	}
+}
+
+func TestIndexKey(t *testing.T) {
+	s := newTestServer(t, "trickster")
+	c1, c2 := newTestCache(t, s), newTestCache(t, s)
+	c2.Name = "test2"
+	c2.indexKey = indexKey(c2.Name)
+	if c1.indexKey == c2.indexKey || !strings.HasPrefix(c1.indexKey, index.IndexKey+".test") {
+		t.Errorf("unexpected index keys %s %s", c1.indexKey, c2.indexKey)
+	}
+	c1.Store("key1", []byte("value"), time.Hour)
+	c2.Store("key2", []byte("value"), time.Hour)
+	c1.flushIndex(index.IndexKey, c1.Index.ToBytes())
+	c2.flushIndex(index.IndexKey, c2.Index.ToBytes())
+	// each cache's index is stored separately and is loaded on connect
+	if s.len() != 4 {
+		t.Errorf("expected %d objects got %d", 4, s.len())
+	}
+	c3 := newTestCache(t, s)
+	if _, ok := c3.Index.Lookup("key1"); !ok {
+		t.Error("expected key1 in the loaded index")
+	}
+	if _, ok := c3.Index.Lookup("key2"); ok {
+		t.Error("expected key2 to be absent from the loaded index")
+	}
+}
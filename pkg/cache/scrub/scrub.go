@@ -0,0 +1,161 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+// Package scrub verifies the objects stored by a cache, removing those that are
+// corrupt, and reclaims stored objects that are not tracked by the cache's Index
+package scrub
+
+import (
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/cache/envelope"
+	"github.com/trickstercache/trickster/v2/pkg/cache/index"
+	"github.com/trickstercache/trickster/v2/pkg/cache/metrics"
+	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
+)
+
+// Store is implemented by caches whose stored objects can be scrubbed
+type Store interface {
+	// Walk calls visit with the key and value of each object in the store, and the
+	// error, if any, encountered while decoding the stored object
+	Walk(visit func(key string, value []byte, err error)) error
+	// Remove removes an object from the store and the Index
+	Remove(key string)
+}
+
+// conditionalRemover is implemented by Stores that can remove an object only if
+// its value is unchanged since it was walked, which is nil for an object that
+// could not be decoded
+type conditionalRemover interface {
+	RemoveIfUnchanged(key string, value []byte) bool
+}
+
+// Result summarizes a single scrub of a cache
+type Result struct {
+	// Scanned is the number of stored objects that were verified
+	Scanned int
+	// Corrupt is the number of corrupt objects that were removed
+	Corrupt int
+	// Orphaned is the number of objects not tracked by the Index that were removed
+	Orphaned int
+}
+
+// Scrubber scrubs the objects of a cache's Store
+type Scrubber struct {
+	name     string
+	provider string
+	store    Store
+	idx      *index.Index
+	logger   interface{}
+	// candidates are the untracked keys found by the previous scrub. Untracked objects
+	// are only reclaimed if they are still untracked by the next scrub, so that objects
+	// stored while a scrub is in progress, but not yet indexed, are not reclaimed
+	candidates map[string]struct{}
+}
+
+// New returns a new Scrubber for the named cache
+func New(name, provider string, store Store, idx *index.Index, logger interface{}) *Scrubber {
+	return &Scrubber{
+		name:       name,
+		provider:   provider,
+		store:      store,
+		idx:        idx,
+		logger:     logger,
+		candidates: make(map[string]struct{}),
+	}
+}
+
+// Scrub makes a single pass over the Store, removing corrupt objects and reclaiming
+// objects that were not tracked by the Index during this pass or the previous one
+func (s *Scrubber) Scrub() (Result, error) {
+	var r Result
+	var orphaned []string
+	// corrupt maps the key of each corrupt object to the value that was walked
+	corrupt := make(map[string][]byte)
+	candidates := make(map[string]struct{})
+	err := s.store.Walk(func(key string, value []byte, err error) {
+		if key == index.IndexKey {
+			return
+		}
+		r.Scanned++
+		if err == nil {
+			err = envelope.Verify(value)
+			// objects written by a newer version are left for the instances that can read them
+			if err != nil && !envelope.IsCorrupt(err) {
+				return
+			}
+		}
+		if err != nil {
+			tl.Warn(s.logger, "cache scrubber found corrupt object",
+				tl.Pairs{"cacheName": s.name, "cacheKey": key, "detail": err.Error()})
+			corrupt[key] = value
+			return
+		}
+		if _, ok := s.idx.Lookup(key); ok {
+			return
+		}
+		if _, ok := s.candidates[key]; ok {
+			orphaned = append(orphaned, key)
+			return
+		}
+		candidates[key] = struct{}{}
+	})
+	if err != nil {
+		return r, err
+	}
+	s.candidates = candidates
+	// objects are removed once the walk is complete, since stores may not support
+	// writes while they are being iterated
+	for key, value := range corrupt {
+		if cr, ok := s.store.(conditionalRemover); ok {
+			// the object may have been rewritten since it was walked
+			if !cr.RemoveIfUnchanged(key, value) {
+				continue
+			}
+		} else {
+			s.store.Remove(key)
+		}
+		metrics.ObserveCacheCorruption(s.name, s.provider, "scrub")
+		r.Corrupt++
+	}
+	for _, key := range orphaned {
+		s.store.Remove(key)
+		metrics.ObserveCacheEvent(s.name, s.provider, "orphan", "reclaimed")
+	}
+	r.Orphaned = len(orphaned)
+	return r, nil
+}
+
+// Run scrubs the Store at the provided interval, until stop is closed
+func (s *Scrubber) Run(interval time.Duration, stop <-chan struct{}) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			r, err := s.Scrub()
+			if err != nil {
+				tl.Error(s.logger, "cache scrub failed",
+					tl.Pairs{"cacheName": s.name, "detail": err.Error()})
+				continue
+			}
+			tl.Debug(s.logger, "cache scrub complete",
+				tl.Pairs{"cacheName": s.name, "scanned": r.Scanned,
+					"corrupt": r.Corrupt, "orphaned": r.Orphaned})
+		}
+	}
+}
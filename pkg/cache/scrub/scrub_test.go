@@ -0,0 +1,142 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package scrub
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/cache/envelope"
+	"github.com/trickstercache/trickster/v2/pkg/cache/index"
+	io "github.com/trickstercache/trickster/v2/pkg/cache/index/options"
+)
+
+type testStore struct {
+	mtx     sync.Mutex
+	objects map[string][]byte
+	errs    map[string]error
+	idx     *index.Index
+}
+
+func (s *testStore) Walk(visit func(key string, value []byte, err error)) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for k, v := range s.objects {
+		visit(k, v, s.errs[k])
+	}
+	return nil
+}
+
+func (s *testStore) Remove(key string) {
+	s.mtx.Lock()
+	delete(s.objects, key)
+	s.mtx.Unlock()
+	s.idx.RemoveObject(key)
+}
+
+func (s *testStore) store(key string, value []byte, indexed bool) {
+	s.mtx.Lock()
+	s.objects[key] = value
+	s.mtx.Unlock()
+	if indexed {
+		s.idx.UpdateObject(&index.Object{Key: key, Value: value, Expiration: time.Now().Add(time.Hour)})
+	}
+}
+
+func (s *testStore) has(key string) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	_, ok := s.objects[key]
+	return ok
+}
+
+func newTestStore() *testStore {
+	o := io.New()
+	o.ReapInterval = 0
+	return &testStore{
+		objects: make(map[string][]byte),
+		errs:    make(map[string]error),
+		idx:     index.NewIndex("test", "test", nil, o, func([]string) {}, nil, nil),
+	}
+}
+
+func TestScrub(t *testing.T) {
+	s := newTestStore()
+	sealed, _ := envelope.Default.Seal([]byte("value"), true)
+	s.store("valid", sealed, true)
+	s.store("legacy", []byte{0, 'v'}, true)
+	s.store("truncated", sealed[:len(sealed)-1], true)
+	s.store("newer", append([]byte{sealed[0], envelope.FormatVersion + 1}, sealed[2:]...), true)
+	s.store("undecodable", nil, true)
+	s.errs["undecodable"] = errors.New("test error")
+	s.store("untracked", sealed, false)
+	s.store(index.IndexKey, []byte("index"), false)
+
+	sc := New("test", "test", s, s.idx, nil)
+	r, err := sc.Scrub()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Scanned != 6 || r.Corrupt != 2 || r.Orphaned != 0 {
+		t.Errorf("unexpected result %+v", r)
+	}
+	if s.has("truncated") || s.has("undecodable") {
+		t.Error("expected corrupt objects to be removed")
+	}
+	if _, ok := s.idx.Lookup("truncated"); ok {
+		t.Error("expected corrupt objects to be removed from the index")
+	}
+	if !s.has("untracked") || !s.has("valid") || !s.has("legacy") || !s.has("newer") ||
+		!s.has(index.IndexKey) {
+		t.Error("expected objects to be retained")
+	}
+
+	// an untracked object is reclaimed if it's still untracked by the next scrub,
+	// unless it has been indexed in the meantime
+	s.store("indexed-later", sealed, false)
+	r, _ = sc.Scrub()
+	if r.Orphaned != 1 || s.has("untracked") || !s.has("indexed-later") {
+		t.Errorf("unexpected result %+v", r)
+	}
+	s.store("indexed-later", sealed, true)
+	r, _ = sc.Scrub()
+	if r.Scanned != 4 || r.Orphaned != 0 || !s.has("indexed-later") {
+		t.Errorf("unexpected result %+v", r)
+	}
+}
+
+func TestRun(t *testing.T) {
+	s := newTestStore()
+	s.store("untracked", []byte("value"), false)
+	sc := New("test", "test", s, s.idx, nil)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		sc.Run(5*time.Millisecond, stop)
+		close(done)
+	}()
+	deadline := time.Now().Add(time.Second)
+	for s.has("untracked") && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	close(stop)
+	<-done
+	if s.has("untracked") {
+		t.Error("expected the untracked object to be reclaimed")
+	}
+}
@@ -49,6 +49,9 @@ const (
 	LookupStatusError
 	// LookupStatusProxyHit indicates that the request joined an existing proxy download of the same object
 	LookupStatusProxyHit
+	// LookupStatusDegraded indicates that the upstream request failed and the cached portion
+	// of the requested dataset was served in its place
+	LookupStatusDegraded
 )
 
 var cacheLookupStatusNames = map[string]LookupStatus{
@@ -63,6 +66,7 @@ var cacheLookupStatusNames = map[string]LookupStatus{
 	"nchit":       LookupStatusNegativeCacheHit,
 	"proxy-hit":   LookupStatusProxyHit,
 	"error":       LookupStatusError,
+	"degraded":    LookupStatusDegraded,
 }
 
 var cacheLookupStatusValues = map[LookupStatus]string{
@@ -77,6 +81,7 @@ var cacheLookupStatusValues = map[LookupStatus]string{
 	LookupStatusNegativeCacheHit: "nchit",
 	LookupStatusProxyHit:         "proxy-hit",
 	LookupStatusError:            "error",
+	LookupStatusDegraded:         "degraded",
 }
 
 func (s LookupStatus) String() string {
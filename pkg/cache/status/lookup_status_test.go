@@ -32,6 +32,10 @@ func TestLookupStatusString(t *testing.T) {
 		t.Errorf("expected %s got %s", "kmiss", t2.String())
 	}
 
+	if LookupStatusDegraded.String() != "degraded" {
+		t.Errorf("expected %s got %s", "degraded", LookupStatusDegraded.String())
+	}
+
 	if t3.String() != "99" {
 		t.Errorf("expected %s got %s", "99", t3.String())
 	}
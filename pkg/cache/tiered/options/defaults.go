@@ -0,0 +1,31 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+const (
+	// WriteModeThrough writes objects to L2 before a Store returns
+	WriteModeThrough = "through"
+	// WriteModeBehind queues objects to be written to L2 asynchronously
+	WriteModeBehind = "behind"
+
+	// DefaultWriteMode is the default L2 write mode
+	DefaultWriteMode = WriteModeThrough
+	// DefaultL1TTLMS is the default maximum TTL of objects in the L1 cache
+	DefaultL1TTLMS = 60000
+	// DefaultWriteBehindQueueSize is the default number of queued write-behind objects
+	DefaultWriteBehindQueueSize = 1024
+)
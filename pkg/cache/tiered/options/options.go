@@ -0,0 +1,95 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Options is a collection of Tiered Cache configurations
+type Options struct {
+	// L1CacheName is the name of the cache used as the first (fastest) tier,
+	// typically a memory cache
+	L1CacheName string `json:"l1_cache_name,omitempty"`
+	// L2CacheName is the name of the cache used as the second tier,
+	// typically a shared redis or filesystem cache
+	L2CacheName string `json:"l2_cache_name,omitempty"`
+	// L1TTLMS is the maximum TTL of objects in the L1 cache, including
+	// objects promoted into L1 from L2
+	L1TTLMS int `json:"l1_ttl_ms,omitempty"`
+	// WriteMode is the L2 write mode: "through" (default) or "behind"
+	WriteMode string `json:"write_mode,omitempty"`
+	// WriteBehindQueueSize is the maximum number of objects queued for
+	// writing to L2 when the WriteMode is "behind"
+	WriteBehindQueueSize int `json:"write_behind_queue_size,omitempty"`
+}
+
+var (
+	// ErrMissingTier is returned when an L1 or L2 cache name is not provided
+	ErrMissingTier = errors.New("tiered caches require an l1_cache_name and l2_cache_name")
+	// ErrDuplicateTier is returned when the L1 and L2 cache names are the same
+	ErrDuplicateTier = errors.New("tiered cache l1_cache_name and l2_cache_name must be different")
+)
+
+// New returns a new Options with default values
+func New() *Options {
+	return &Options{
+		L1TTLMS:              DefaultL1TTLMS,
+		WriteMode:            DefaultWriteMode,
+		WriteBehindQueueSize: DefaultWriteBehindQueueSize,
+	}
+}
+
+// Clone returns an exact copy of the subject Options
+func (o *Options) Clone() *Options {
+	c := *o
+	return &c
+}
+
+// Equal returns true if the subject and provided Options are identical
+func (o *Options) Equal(o2 *Options) bool {
+	if o == nil || o2 == nil {
+		return o == o2
+	}
+	return *o == *o2
+}
+
+// Validate sets default values for any unset Options, and returns an error
+// if the Options are invalid
+func (o *Options) Validate() error {
+	if o.L1CacheName == "" || o.L2CacheName == "" {
+		return ErrMissingTier
+	}
+	if o.L1CacheName == o.L2CacheName {
+		return ErrDuplicateTier
+	}
+	switch o.WriteMode {
+	case "":
+		o.WriteMode = DefaultWriteMode
+	case WriteModeThrough, WriteModeBehind:
+	default:
+		return fmt.Errorf("invalid tiered cache write_mode: %s", o.WriteMode)
+	}
+	if o.L1TTLMS <= 0 {
+		o.L1TTLMS = DefaultL1TTLMS
+	}
+	if o.WriteBehindQueueSize <= 0 {
+		o.WriteBehindQueueSize = DefaultWriteBehindQueueSize
+	}
+	return nil
+}
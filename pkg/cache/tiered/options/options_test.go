@@ -0,0 +1,65 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	o := &Options{L1CacheName: "mem", L2CacheName: "redis"}
+	if err := o.Validate(); err != nil {
+		t.Error(err)
+	}
+	if o.WriteMode != DefaultWriteMode || o.L1TTLMS != DefaultL1TTLMS ||
+		o.WriteBehindQueueSize != DefaultWriteBehindQueueSize {
+		t.Errorf("expected default options, got %+v", o)
+	}
+
+	o = New()
+	if err := o.Validate(); err != ErrMissingTier {
+		t.Errorf("expected %v got %v", ErrMissingTier, err)
+	}
+
+	o = &Options{L1CacheName: "mem", L2CacheName: "mem"}
+	if err := o.Validate(); err != ErrDuplicateTier {
+		t.Errorf("expected %v got %v", ErrDuplicateTier, err)
+	}
+
+	o = &Options{L1CacheName: "mem", L2CacheName: "redis", WriteMode: "invalid"}
+	if err := o.Validate(); err == nil {
+		t.Error("expected error for invalid write mode")
+	}
+}
+
+func TestCloneAndEqual(t *testing.T) {
+	o := New()
+	o.L1CacheName = "mem"
+	c := o.Clone()
+	if !o.Equal(c) {
+		t.Error("expected true")
+	}
+	c.WriteMode = WriteModeBehind
+	if o.Equal(c) {
+		t.Error("expected false")
+	}
+	if o.Equal(nil) {
+		t.Error("expected false")
+	}
+	var n *Options
+	if !n.Equal(nil) {
+		t.Error("expected true")
+	}
+}
@@ -0,0 +1,356 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package tiered is the tiered implementation of the Trickster Cache, which
+// reads through a fast L1 cache (typically memory) to a shared L2 cache
+// (typically redis or filesystem)
+package tiered
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/cache"
+	"github.com/trickstercache/trickster/v2/pkg/cache/envelope"
+	"github.com/trickstercache/trickster/v2/pkg/cache/index"
+	"github.com/trickstercache/trickster/v2/pkg/cache/metrics"
+	"github.com/trickstercache/trickster/v2/pkg/cache/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache/status"
+	to "github.com/trickstercache/trickster/v2/pkg/cache/tiered/options"
+	"github.com/trickstercache/trickster/v2/pkg/locks"
+	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
+)
+
+// Tier label values
+const (
+	tierL1 = "l1"
+	tierL2 = "l2"
+)
+
+// ErrL1NotMemoryCache is returned when storing or retrieving a reference
+// object, and the L1 cache is not a MemoryCache
+var ErrL1NotMemoryCache = errors.New("tiered cache l1 does not support reference objects")
+
+// Cache represents a tiered cache object that conforms to the Cache and
+// MemoryCache interfaces. L1 and L2 must be connected before the tiered
+// cache is connected
+type Cache struct {
+	Name   string
+	Config *options.Options
+	Logger interface{}
+	L1     cache.Cache
+	L2     cache.Cache
+	// SharedL1 and SharedL2 are true when the tier is also a standalone cache,
+	// which is closed by its owner rather than by the tiered cache
+	SharedL1 bool
+	SharedL2 bool
+	locker   locks.NamedLocker
+
+	l1TTL     time.Duration
+	queue     chan *write
+	qmtx      sync.RWMutex
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// write is an object queued for writing to L2
+type write struct {
+	key  string
+	data []byte
+	ttl  time.Duration
+}
+
+// Locker returns the cache's locker
+func (c *Cache) Locker() locks.NamedLocker {
+	return c.locker
+}
+
+// SetLocker sets the cache's locker
+func (c *Cache) SetLocker(l locks.NamedLocker) {
+	c.locker = l
+}
+
+// Configuration returns the Configuration for the Cache object
+func (c *Cache) Configuration() *options.Options {
+	return c.Config
+}
+
+// Connect initializes the Cache, and starts the L2 writer when the WriteMode is behind
+func (c *Cache) Connect() error {
+	if c.L1 == nil || c.L2 == nil {
+		return to.ErrMissingTier
+	}
+	o := c.Config.Tiered
+	if o == nil {
+		o = to.New()
+	}
+	c.l1TTL = time.Duration(o.L1TTLMS) * time.Millisecond
+	tl.Info(c.Logger, "tieredcache setup", tl.Pairs{
+		"name": c.Name, "l1": c.L1.Configuration().Provider,
+		"l2": c.L2.Configuration().Provider, "writeMode": o.WriteMode,
+	})
+	if o.WriteMode == to.WriteModeBehind {
+		c.queue = make(chan *write, o.WriteBehindQueueSize)
+		c.wg.Add(1)
+		go c.writeBehind(c.queue)
+	}
+	return nil
+}
+
+func (c *Cache) writeBehind(queue <-chan *write) {
+	defer c.wg.Done()
+	for w := range queue {
+		c.storeL2(w.key, w.data, w.ttl)
+	}
+}
+
+func (c *Cache) storeL2(cacheKey string, data []byte, ttl time.Duration) error {
+	err := c.L2.Store(cacheKey, data, ttl)
+	if err != nil {
+		tl.Warn(c.Logger, "tieredcache l2 store failed",
+			tl.Pairs{"cacheName": c.Name, "cacheKey": cacheKey, "detail": err.Error()})
+	}
+	return err
+}
+
+// capTTL returns the ttl, reduced to the L1 TTL if it is longer
+func (c *Cache) capTTL(ttl time.Duration) time.Duration {
+	if c.l1TTL > 0 && (ttl <= 0 || ttl > c.l1TTL) {
+		return c.l1TTL
+	}
+	return ttl
+}
+
+// Store places an object in L1, and writes it through or behind to L2
+func (c *Cache) Store(cacheKey string, data []byte, ttl time.Duration) error {
+	if err := c.L1.Store(cacheKey, data, c.capTTL(ttl)); err != nil {
+		return err
+	}
+	if c.enqueue(cacheKey, data, ttl) {
+		return nil
+	}
+	return c.storeL2(cacheKey, data, ttl)
+}
+
+// enqueue queues the object for writing to L2, and returns false if the WriteMode is
+// not behind, or the queue is full or closed, in which case the write falls back
+// to write-through
+func (c *Cache) enqueue(cacheKey string, data []byte, ttl time.Duration) bool {
+	c.qmtx.RLock()
+	defer c.qmtx.RUnlock()
+	if c.queue == nil {
+		return false
+	}
+	select {
+	case c.queue <- &write{key: cacheKey, data: data, ttl: ttl}:
+		return true
+	default:
+		metrics.ObserveCacheEvent(c.Name, c.Config.Provider, "writeBehind", "queueFull")
+		return false
+	}
+}
+
+// Retrieve looks for an object in L1, then in L2, and promotes L2 hits into L1
+func (c *Cache) Retrieve(cacheKey string, allowExpired bool) ([]byte, status.LookupStatus, error) {
+	b, s, err := c.L1.Retrieve(cacheKey, allowExpired)
+	if err == nil && b != nil {
+		metrics.ObserveCacheTierLookup(c.Name, tierL1, "hit")
+		return b, s, nil
+	}
+	// an L1 hit without a value is a reference object, which is read from L2 in its
+	// serialized form, and isn't replaced in L1 by that form
+	isReference := err == nil
+	metrics.ObserveCacheTierLookup(c.Name, tierL1, "miss")
+	b, s, err = c.L2.Retrieve(cacheKey, allowExpired)
+	if err != nil {
+		metrics.ObserveCacheTierLookup(c.Name, tierL2, "miss")
+		return nil, s, err
+	}
+	metrics.ObserveCacheTierLookup(c.Name, tierL2, "hit")
+	if ttl, ok := c.promotionTTL(cacheKey); ok && !isReference {
+		c.L1.Store(cacheKey, b, ttl)
+	}
+	return b, s, nil
+}
+
+// promotionTTL returns the TTL of an object promoted from L2 into L1, and false
+// if the object is expired in L2 and shouldn't be promoted
+func (c *Cache) promotionTTL(cacheKey string) (time.Duration, bool) {
+	rem, ok := c.remainingL2TTL(cacheKey)
+	if !ok {
+		return c.l1TTL, true
+	}
+	if rem <= 0 {
+		return 0, false
+	}
+	return c.capTTL(rem), true
+}
+
+// StoreReference stores an object by reference in the L1 memory cache, and writes
+// it through or behind to L2 in the form serialized by the registered ReferenceCodec.
+// The object is only stored in L1 when no codec is registered
+func (c *Cache) StoreReference(cacheKey string, data cache.ReferenceObject,
+	ttl time.Duration,
+) error {
+	mc, ok := c.L1.(cache.MemoryCache)
+	if !ok {
+		return ErrL1NotMemoryCache
+	}
+	if err := mc.StoreReference(cacheKey, data, c.capTTL(ttl)); err != nil {
+		return err
+	}
+	rc := cache.GetReferenceCodec()
+	if rc == nil {
+		return nil
+	}
+	b, err := rc.MarshalReference(data)
+	if err == nil {
+		// the object reports whether its serialized form benefits from compression
+		cr, ok := data.(interface{ Compressible() bool })
+		b, err = c.envelope().Seal(b, ok && cr.Compressible())
+	}
+	if err != nil {
+		tl.Warn(c.Logger, "tieredcache l2 reference marshaling failed",
+			tl.Pairs{"cacheName": c.Name, "cacheKey": cacheKey, "detail": err.Error()})
+		return err
+	}
+	if c.enqueue(cacheKey, b, ttl) {
+		return nil
+	}
+	return c.storeL2(cacheKey, b, ttl)
+}
+
+// RetrieveReference looks for a reference object in the L1 memory cache, then in L2,
+// and promotes L2 hits into L1 after unmarshaling them with the registered ReferenceCodec
+func (c *Cache) RetrieveReference(cacheKey string, allowExpired bool) (interface{},
+	status.LookupStatus, error,
+) {
+	mc, ok := c.L1.(cache.MemoryCache)
+	if !ok {
+		return nil, status.LookupStatusError, ErrL1NotMemoryCache
+	}
+	o, s, err := mc.RetrieveReference(cacheKey, allowExpired)
+	if err == nil && o != nil {
+		metrics.ObserveCacheTierLookup(c.Name, tierL1, "hit")
+		return o, s, nil
+	}
+	metrics.ObserveCacheTierLookup(c.Name, tierL1, "miss")
+	rc := cache.GetReferenceCodec()
+	if rc == nil {
+		return nil, status.LookupStatusKeyMiss, cache.ErrKNF
+	}
+	b, s, err := c.L2.Retrieve(cacheKey, allowExpired)
+	if err != nil {
+		metrics.ObserveCacheTierLookup(c.Name, tierL2, "miss")
+		return nil, s, err
+	}
+	b, err = c.envelope().Open(b)
+	var ro cache.ReferenceObject
+	if err == nil {
+		ro, err = rc.UnmarshalReference(b)
+	}
+	if err != nil {
+		tl.Warn(c.Logger, "tieredcache l2 reference unmarshaling failed",
+			tl.Pairs{"cacheName": c.Name, "cacheKey": cacheKey, "detail": err.Error()})
+		metrics.ObserveCacheTierLookup(c.Name, tierL2, "miss")
+		return nil, status.LookupStatusKeyMiss, cache.ErrKNF
+	}
+	metrics.ObserveCacheTierLookup(c.Name, tierL2, "hit")
+	if ttl, ok := c.promotionTTL(cacheKey); ok {
+		mc.StoreReference(cacheKey, ro, ttl)
+	}
+	return ro, s, nil
+}
+
+// envelope returns the Envelope used to seal reference objects written to L2
+func (c *Cache) envelope() *envelope.Envelope {
+	if c.Config != nil && c.Config.Envelope != nil {
+		return c.Config.Envelope
+	}
+	return envelope.Default
+}
+
+// remainingL2TTL returns the time until the object expires in L2, and false
+// if L2 can't report the object's expiration
+func (c *Cache) remainingL2TTL(cacheKey string) (time.Duration, bool) {
+	switch l2 := c.L2.(type) {
+	case interface {
+		RemainingTTL(string) (time.Duration, bool)
+	}:
+		return l2.RemainingTTL(cacheKey)
+	case interface{ CacheIndex() *index.Index }:
+		if idx := l2.CacheIndex(); idx != nil {
+			if exp := idx.GetExpiration(cacheKey); !exp.IsZero() {
+				return time.Until(exp), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// SetTTL updates the TTL for the provided cache object in both tiers
+func (c *Cache) SetTTL(cacheKey string, ttl time.Duration) {
+	c.L1.SetTTL(cacheKey, c.capTTL(ttl))
+	c.L2.SetTTL(cacheKey, ttl)
+}
+
+// Remove removes an object from both tiers
+func (c *Cache) Remove(cacheKey string) {
+	c.L1.Remove(cacheKey)
+	c.L2.Remove(cacheKey)
+}
+
+// BulkRemove removes a list of objects from both tiers
+func (c *Cache) BulkRemove(cacheKeys []string) {
+	c.L1.BulkRemove(cacheKeys)
+	c.L2.BulkRemove(cacheKeys)
+}
+
+// RemoveByPrefix removes all objects whose keys begin with the provided prefix from
+// each tier that supports it, and returns the number of objects removed
+func (c *Cache) RemoveByPrefix(prefix string) int {
+	var n int
+	for _, t := range []cache.Cache{c.L1, c.L2} {
+		if pr, ok := t.(interface{ RemoveByPrefix(string) int }); ok {
+			n += pr.RemoveByPrefix(prefix)
+		}
+	}
+	return n
+}
+
+// Close flushes any queued L2 writes and closes any tiers that aren't shared
+func (c *Cache) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		c.qmtx.Lock()
+		if c.queue != nil {
+			close(c.queue)
+			c.queue = nil
+		}
+		c.qmtx.Unlock()
+		c.wg.Wait()
+		var errs []error
+		if !c.SharedL1 {
+			errs = append(errs, c.L1.Close())
+		}
+		if !c.SharedL2 {
+			errs = append(errs, c.L2.Close())
+		}
+		err = errors.Join(errs...)
+	})
+	return err
+}
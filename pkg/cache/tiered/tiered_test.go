@@ -0,0 +1,271 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tiered
+
+import (
+	"testing"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/cache"
+	"github.com/trickstercache/trickster/v2/pkg/cache/memory"
+	"github.com/trickstercache/trickster/v2/pkg/cache/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache/status"
+	to "github.com/trickstercache/trickster/v2/pkg/cache/tiered/options"
+	"github.com/trickstercache/trickster/v2/pkg/locks"
+)
+
+func newTestTier(t *testing.T, name string) *memory.Cache {
+	c := &memory.Cache{Name: name, Config: options.New()}
+	c.SetLocker(locks.NewNamedLocker())
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func newTestCache(t *testing.T, writeMode string) (*Cache, *memory.Cache, *memory.Cache) {
+	l1, l2 := newTestTier(t, "test.l1"), newTestTier(t, "test.l2")
+	cfg := options.New()
+	cfg.Provider = "tiered"
+	cfg.Tiered = &to.Options{L1CacheName: "l1", L2CacheName: "l2", WriteMode: writeMode}
+	if err := cfg.Tiered.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	c := &Cache{Name: "test", Config: cfg, L1: l1, L2: l2}
+	c.SetLocker(locks.NewNamedLocker())
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	return c, l1, l2
+}
+
+func TestConnect(t *testing.T) {
+	c := &Cache{Name: "test", Config: options.New()}
+	if err := c.Connect(); err != to.ErrMissingTier {
+		t.Errorf("expected %v got %v", to.ErrMissingTier, err)
+	}
+}
+
+func TestStoreAndRetrieve(t *testing.T) {
+	c, l1, l2 := newTestCache(t, to.WriteModeThrough)
+	defer c.Close()
+
+	if err := c.Store("key", []byte("value"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	for _, tier := range []cache.Cache{l1, l2} {
+		if _, _, err := tier.Retrieve("key", false); err != nil {
+			t.Errorf("expected object in %s: %v", tier.Configuration().Name, err)
+		}
+	}
+
+	b, s, err := c.Retrieve("key", false)
+	if err != nil || s != status.LookupStatusHit || string(b) != "value" {
+		t.Errorf("unexpected retrieve result: %s %s %v", string(b), s, err)
+	}
+
+	// an L2 hit is promoted into L1
+	l1.Remove("key")
+	b, s, err = c.Retrieve("key", false)
+	if err != nil || s != status.LookupStatusHit || string(b) != "value" {
+		t.Errorf("unexpected retrieve result: %s %s %v", string(b), s, err)
+	}
+	if _, _, err = l1.Retrieve("key", false); err != nil {
+		t.Error("expected L2 hit to be promoted into L1")
+	}
+
+	c.Remove("key")
+	if _, s, err = c.Retrieve("key", false); err != cache.ErrKNF ||
+		s != status.LookupStatusKeyMiss {
+		t.Errorf("expected miss, got %s %v", s, err)
+	}
+
+	c.Store("key1", []byte("value"), time.Hour)
+	c.Store("key2", []byte("value"), time.Hour)
+	c.BulkRemove([]string{"key1", "key2"})
+	if _, _, err = l2.Retrieve("key1", false); err == nil {
+		t.Error("expected key1 to be removed from L2")
+	}
+}
+
+func TestWriteBehind(t *testing.T) {
+	c, _, l2 := newTestCache(t, to.WriteModeBehind)
+	if err := c.Store("key", []byte("value"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	// Close flushes the queued writes to L2
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := l2.Retrieve("key", false); err != nil {
+		t.Error("expected queued write to be flushed to L2")
+	}
+	// a store after close falls back to write-through
+	if err := c.Store("key2", []byte("value"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := l2.Retrieve("key2", false); err != nil {
+		t.Error("expected write-through after close")
+	}
+}
+
+func TestCapTTL(t *testing.T) {
+	c := &Cache{l1TTL: time.Minute}
+	tests := []struct {
+		ttl, expected time.Duration
+	}{
+		{0, time.Minute},
+		{time.Second, time.Second},
+		{time.Hour, time.Minute},
+	}
+	for _, test := range tests {
+		if v := c.capTTL(test.ttl); v != test.expected {
+			t.Errorf("expected %s got %s", test.expected, v)
+		}
+	}
+}
+
+func TestPromotionTTL(t *testing.T) {
+	c, l1, l2 := newTestCache(t, to.WriteModeThrough)
+	defer c.Close()
+	c.l1TTL = time.Hour
+
+	// an L2 object expiring before the L1 TTL is promoted with its remaining TTL
+	l2.Store("key", []byte("value"), time.Minute)
+	if _, _, err := c.Retrieve("key", false); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := time.Until(l1.Index.GetExpiration("key")); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("expected promoted ttl <= %s got %s", time.Minute, ttl)
+	}
+
+	// an L2 object expiring after the L1 TTL is promoted with the L1 TTL
+	l2.Store("key2", []byte("value"), 2*time.Hour)
+	if _, _, err := c.Retrieve("key2", false); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := time.Until(l1.Index.GetExpiration("key2")); ttl <= time.Minute || ttl > time.Hour {
+		t.Errorf("expected promoted ttl <= %s got %s", time.Hour, ttl)
+	}
+}
+
+type testCloser struct {
+	cache.Cache
+	closed bool
+}
+
+func (c *testCloser) Close() error {
+	c.closed = true
+	return c.Cache.Close()
+}
+
+func TestCloseSharedTiers(t *testing.T) {
+	c, l1, l2 := newTestCache(t, to.WriteModeThrough)
+	t1, t2 := &testCloser{Cache: l1}, &testCloser{Cache: l2}
+	c.L1, c.L2, c.SharedL1 = t1, t2, true
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if t1.closed {
+		t.Error("expected shared L1 not to be closed")
+	}
+	if !t2.closed {
+		t.Error("expected private L2 to be closed")
+	}
+}
+
+func TestRemoveByPrefix(t *testing.T) {
+	c, _, _ := newTestCache(t, to.WriteModeThrough)
+	defer c.Close()
+	c.Store("prefix.1", []byte("value"), time.Hour)
+	c.Store("prefix.2", []byte("value"), time.Hour)
+	c.Store("other", []byte("value"), time.Hour)
+	if n := c.RemoveByPrefix("prefix."); n != 4 {
+		t.Errorf("expected %d got %d", 4, n)
+	}
+	if _, _, err := c.Retrieve("other", false); err != nil {
+		t.Error(err)
+	}
+}
+
+var _ cache.MemoryCache = (*Cache)(nil)
+
+type testReference struct {
+	value string
+}
+
+func (r *testReference) Size() int {
+	return len(r.value)
+}
+
+type testReferenceCodec struct{}
+
+func (testReferenceCodec) MarshalReference(ro cache.ReferenceObject) ([]byte, error) {
+	return []byte(ro.(*testReference).value), nil
+}
+
+func (testReferenceCodec) UnmarshalReference(b []byte) (cache.ReferenceObject, error) {
+	return &testReference{value: string(b)}, nil
+}
+
+func TestReferences(t *testing.T) {
+	cache.RegisterReferenceCodec(testReferenceCodec{})
+	defer cache.RegisterReferenceCodec(nil)
+
+	c, l1, l2 := newTestCache(t, to.WriteModeThrough)
+	defer c.Close()
+	if err := c.StoreReference("key", &testReference{value: "value"}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	// the reference is held in L1, and its serialized form in L2
+	if o, _, _ := l1.RetrieveReference("key", false); o == nil {
+		t.Error("expected reference in L1")
+	}
+	if b, _, err := l2.Retrieve("key", false); err != nil || len(b) == 0 {
+		t.Errorf("expected serialized reference in L2, got %v", err)
+	}
+	// the byte form is read from L2 without replacing the reference in L1
+	if _, _, err := c.Retrieve("key", false); err != nil {
+		t.Error(err)
+	}
+	if o, _, _ := l1.RetrieveReference("key", false); o == nil {
+		t.Error("expected reference in L1")
+	}
+
+	// an L1 miss is unmarshaled from L2 and promoted into L1
+	l1.Remove("key")
+	o, s, err := c.RetrieveReference("key", false)
+	if err != nil || s != status.LookupStatusHit {
+		t.Fatalf("expected %s got %s %v", status.LookupStatusHit, s, err)
+	}
+	if r, ok := o.(*testReference); !ok || r.value != "value" {
+		t.Errorf("unexpected reference %v", o)
+	}
+	if o, _, _ := l1.RetrieveReference("key", false); o == nil {
+		t.Error("expected promoted reference in L1")
+	}
+
+	// references require a memory L1
+	c.L1 = &testCloser{Cache: l1}
+	if err = c.StoreReference("key", &testReference{}, time.Hour); err != ErrL1NotMemoryCache {
+		t.Errorf("expected %v got %v", ErrL1NotMemoryCache, err)
+	}
+	if _, _, err = c.RetrieveReference("key", false); err != ErrL1NotMemoryCache {
+		t.Errorf("expected %v got %v", ErrL1NotMemoryCache, err)
+	}
+	c.L1 = l1
+}
@@ -0,0 +1,59 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package warningpolicies enumerates how the Delta Proxy Cache handles
+// timeseries responses that carry warnings, such as partial responses
+package warningpolicies
+
+import "strconv"
+
+// TimeseriesWarningPolicy enumerates the policies for caching timeseries data
+// from upstream responses that carry warnings
+type TimeseriesWarningPolicy int
+
+const (
+	// WarningPolicyVolatile indicates that warning-bearing data is cached, but its
+	// extents are marked volatile so they are re-fetched on subsequent requests
+	WarningPolicyVolatile = TimeseriesWarningPolicy(iota)
+	// WarningPolicySkip indicates that warning-bearing data is served to the client
+	// but is never written to the cache
+	WarningPolicySkip
+	// WarningPolicyCache indicates that warning-bearing data is cached like any other
+	WarningPolicyCache
+)
+
+// Names is a map of TimeseriesWarningPolicies keyed by string name
+var Names = map[string]TimeseriesWarningPolicy{
+	"volatile": WarningPolicyVolatile,
+	"skip":     WarningPolicySkip,
+	"cache":    WarningPolicyCache,
+}
+
+// Values is a map of TimeseriesWarningPolicies valued by string name
+var Values = make(map[TimeseriesWarningPolicy]string)
+
+func init() {
+	for k, v := range Names {
+		Values[v] = k
+	}
+}
+
+func (t TimeseriesWarningPolicy) String() string {
+	if v, ok := Values[t]; ok {
+		return v
+	}
+	return strconv.Itoa(int(t))
+}
@@ -0,0 +1,44 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package warningpolicies
+
+import (
+	"testing"
+)
+
+func TestTWPString(t *testing.T) {
+	t1 := WarningPolicyVolatile
+	t2 := WarningPolicySkip
+	t3 := WarningPolicyCache
+	var t4 TimeseriesWarningPolicy = 4
+
+	if t1.String() != "volatile" {
+		t.Errorf("expected %s got %s", "volatile", t1.String())
+	}
+
+	if t2.String() != "skip" {
+		t.Errorf("expected %s got %s", "skip", t2.String())
+	}
+
+	if t3.String() != "cache" {
+		t.Errorf("expected %s got %s", "cache", t3.String())
+	}
+
+	if t4.String() != "4" {
+		t.Errorf("expected %s got %s", "4", t4.String())
+	}
+}
@@ -48,6 +48,17 @@ func (s *InlineFNV64a) Write(data []byte) (int, error) {
 	return len(data), nil
 }
 
+// WriteString adds the string to the running hash without converting it to a byte slice.
+func (s *InlineFNV64a) WriteString(data string) (int, error) {
+	hash := uint64(*s)
+	for i := 0; i < len(data); i++ {
+		hash ^= uint64(data[i])
+		hash *= prime64
+	}
+	*s = InlineFNV64a(hash)
+	return len(data), nil
+}
+
 // Sum64 returns the uint64 of the current resulting hash.
 func (s *InlineFNV64a) Sum64() uint64 {
 	return uint64(*s)
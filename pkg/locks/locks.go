@@ -16,11 +16,16 @@
 package locks
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 	"sync/atomic"
 )
 
+// ErrAcquireTimeout is returned when a lock held elsewhere was not released
+// within the locker's acquire timeout
+var ErrAcquireTimeout = errors.New("lock acquire timed out")
+
 // NamedLocker provides a locker for handling Named Locks
 type NamedLocker interface {
 	Acquire(string) (NamedLock, error)
@@ -43,7 +48,18 @@ func NewNamedLocker() NamedLocker {
 type NamedLock interface {
 	Release() error
 	RRelease() error
-	Upgrade() bool
+	Upgrade() (bool, error)
+}
+
+// FencedLock is implemented by Named Locks that coordinate write locks across
+// instances. A writer holding such a lock should check that the lock is still
+// Held before writing, since the lock can be lost when its lease expires
+type FencedLock interface {
+	NamedLock
+	// Fence returns the monotonic fencing token issued when the write lock was acquired
+	Fence() int64
+	// Held returns true if the write lock is still held by the caller
+	Held() bool
 }
 
 func newNamedLock(name string, locker *namedLocker) *namedLock {
@@ -94,14 +110,15 @@ func (nl *namedLock) RRelease() error {
 // concurrently and the caller was not the first in the queue to receive it.
 // This helps the caller know if any extra state checks are required
 // (e.g., re-querying a cache that might have changed) before proceeding.
-func (nl *namedLock) Upgrade() bool {
+// The process-local Named Lock never returns an error.
+func (nl *namedLock) Upgrade() (bool, error) {
 	nl.RUnlock()
 	nl.Lock()
 	if nl.subsequentWriter {
-		return false
+		return false, nil
 	}
 	nl.subsequentWriter = true
-	return true
+	return true, nil
 }
 
 func (lk *namedLocker) acquire(lockName string, isWrite bool) (NamedLock, error) {
@@ -64,7 +64,7 @@ func TestLocksOrdering(t *testing.T) {
 	go func() {
 		nl, _ := lk.RAcquire("testLock")
 		time.Sleep(500 * time.Millisecond)
-		b := nl.Upgrade()
+		b, _ := nl.Upgrade()
 		if !b {
 			t.Error("expected true")
 		}
@@ -76,7 +76,7 @@ func TestLocksOrdering(t *testing.T) {
 		time.Sleep(300 * time.Millisecond)
 		nl, _ := lk.RAcquire("testLock")
 		time.Sleep(300 * time.Millisecond)
-		b := nl.Upgrade()
+		b, _ := nl.Upgrade()
 		if b {
 			t.Error("expected false")
 		}
@@ -88,7 +88,7 @@ func TestLocksOrdering(t *testing.T) {
 		time.Sleep(300 * time.Millisecond)
 		nl, _ := lk.RAcquire("testLock")
 		time.Sleep(300 * time.Millisecond)
-		b := nl.Upgrade()
+		b, _ := nl.Upgrade()
 		if b {
 			t.Error("expected false")
 		}
@@ -251,7 +251,7 @@ func TestUpgrade(t *testing.T) {
 	locker := NewNamedLocker()
 	nl, _ := locker.RAcquire("test")
 
-	b := nl.Upgrade()
+	b, _ := nl.Upgrade()
 	nl.Release()
 	if !b {
 		t.Errorf("expected firstWrite to be true")
@@ -260,7 +260,7 @@ func TestUpgrade(t *testing.T) {
 	nl, _ = locker.RAcquire("test2")
 	nl1 := nl.(*namedLock)
 	nl1.subsequentWriter = true
-	b = nl.Upgrade()
+	b, _ = nl.Upgrade()
 	nl.Release()
 	if b {
 		t.Errorf("expected firstWrite to be false")
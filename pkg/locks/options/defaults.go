@@ -0,0 +1,36 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package options
+
+const (
+	// ProviderLocal is the process-local Named Locker provider
+	ProviderLocal = "local"
+	// ProviderRedis is the Redis-backed, distributed Named Locker provider
+	ProviderRedis = "redis"
+
+	// DefaultProvider is the default Named Locker provider
+	DefaultProvider = ProviderLocal
+	// DefaultLeaseMS is the default lease duration of a distributed write lock
+	DefaultLeaseMS = 10000
+	// DefaultAcquireTimeoutMS is the default time to wait for a distributed
+	// write lock held by another instance before the acquire times out
+	DefaultAcquireTimeoutMS = 5000
+	// DefaultPollIntervalMS is the default interval at which a held distributed
+	// write lock is polled for release
+	DefaultPollIntervalMS = 10
+	// DefaultKeyPrefix is the default prefix for distributed lock keys
+	DefaultKeyPrefix = "trickster.lock."
+)
@@ -0,0 +1,89 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+// Package options provides options for Named Lockers
+package options
+
+import "fmt"
+
+// Options is a collection of Named Locker configurations
+type Options struct {
+	// Provider is the Named Locker provider: "local" (default) or "redis". The redis
+	// provider coordinates write locks across all Trickster instances sharing the cache
+	Provider string `json:"provider,omitempty"`
+	// LeaseMS is the lease duration of a distributed write lock, which is renewed
+	// while the lock is held, so that locks held by a failed instance expire
+	LeaseMS int `json:"lease_ms,omitempty"`
+	// AcquireTimeoutMS is the maximum time to wait for a distributed write lock held
+	// by another instance, after which the acquire fails with a timeout error
+	AcquireTimeoutMS int `json:"acquire_timeout_ms,omitempty"`
+	// PollIntervalMS is the interval at which a held distributed write lock is polled for release
+	PollIntervalMS int `json:"poll_interval_ms,omitempty"`
+	// KeyPrefix is the prefix applied to distributed lock keys
+	KeyPrefix string `json:"key_prefix,omitempty"`
+	// RequireRemote, when true, fails a write lock acquire when Redis is unreachable,
+	// rather than falling back to process-local locking
+	RequireRemote bool `json:"require_remote,omitempty"`
+}
+
+// New returns a new Options with default values
+func New() *Options {
+	return &Options{
+		Provider:         DefaultProvider,
+		LeaseMS:          DefaultLeaseMS,
+		AcquireTimeoutMS: DefaultAcquireTimeoutMS,
+		PollIntervalMS:   DefaultPollIntervalMS,
+		KeyPrefix:        DefaultKeyPrefix,
+	}
+}
+
+// Clone returns an exact copy of the subject Options
+func (o *Options) Clone() *Options {
+	c := *o
+	return &c
+}
+
+// Equal returns true if the subject and provided Options are identical
+func (o *Options) Equal(o2 *Options) bool {
+	if o == nil || o2 == nil {
+		return o == o2
+	}
+	return *o == *o2
+}
+
+// Validate sets default values for any unset Options, and returns an error
+// if the Provider is invalid
+func (o *Options) Validate() error {
+	switch o.Provider {
+	case "":
+		o.Provider = DefaultProvider
+	case ProviderLocal, ProviderRedis:
+	default:
+		return fmt.Errorf("invalid locker provider: %s", o.Provider)
+	}
+	if o.LeaseMS <= 0 {
+		o.LeaseMS = DefaultLeaseMS
+	}
+	if o.AcquireTimeoutMS <= 0 {
+		o.AcquireTimeoutMS = DefaultAcquireTimeoutMS
+	}
+	if o.PollIntervalMS <= 0 {
+		o.PollIntervalMS = DefaultPollIntervalMS
+	}
+	if o.KeyPrefix == "" {
+		o.KeyPrefix = DefaultKeyPrefix
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package options
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	o := &Options{}
+	if err := o.Validate(); err != nil {
+		t.Error(err)
+	}
+	if !o.Equal(New()) {
+		t.Errorf("expected default options, got %+v", o)
+	}
+
+	o = &Options{Provider: "invalid"}
+	if err := o.Validate(); err == nil {
+		t.Error("expected error for invalid provider")
+	}
+}
+
+func TestCloneAndEqual(t *testing.T) {
+	o := New()
+	o.Provider = ProviderRedis
+	c := o.Clone()
+	if !o.Equal(c) {
+		t.Error("expected true")
+	}
+	c.LeaseMS = 1
+	if o.Equal(c) {
+		t.Error("expected false")
+	}
+	if o.Equal(nil) {
+		t.Error("expected false")
+	}
+	var n *Options
+	if !n.Equal(nil) {
+		t.Error("expected true")
+	}
+}
@@ -0,0 +1,311 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+// Package redis provides a Redis-backed Named Locker, which coordinates write
+// locks across all Trickster instances sharing a Redis cache
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/locks"
+	"github.com/trickstercache/trickster/v2/pkg/locks/options"
+	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
+	"github.com/trickstercache/trickster/v2/pkg/observability/metrics"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// fenceRetentionLeases is the number of lock leases that a lock's fencing counter
+// is retained after its most recent acquire
+const fenceRetentionLeases = 100
+
+// lockScript sets the lock key to the caller's token when it is not already held,
+// and returns the lock's next fencing token, or 0 when the lock is held elsewhere
+var lockScript = redis.NewScript(`
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	local fence = redis.call("INCR", KEYS[2])
+	redis.call("PEXPIRE", KEYS[2], ARGV[3])
+	return fence
+end
+return 0`)
+
+// heldScript returns 1 if the lock key still holds the caller's token
+var heldScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return 1
+end
+return 0`)
+
+// unlockScript deletes the lock key only if it still holds the caller's token
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// renewScript extends the lock key's lease only if it still holds the caller's token
+var renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+// Locker is a Named Locker that coordinates write locks across instances using
+// Redis (SET NX PX with a unique token and lease renewal). A process-local Named
+// Locker is also applied, so that requests within the same instance queue locally.
+//
+// Each write lock acquisition is issued a monotonic fencing token, and its holder
+// can check that it still holds the lock before writing. The cache providers do
+// not compare fencing tokens, so a write that begins just before a lease expires
+// can still land after the next holder's write
+type Locker struct {
+	name   string
+	client redis.Cmdable
+	local  locks.NamedLocker
+	o      *options.Options
+	logger interface{}
+}
+
+// New returns a new Redis-backed Named Locker for the named cache, using the
+// provided client
+func New(cacheName string, client redis.Cmdable, o *options.Options,
+	logger interface{}) locks.NamedLocker {
+	if o == nil {
+		o = options.New()
+	}
+	return &Locker{
+		name:   cacheName,
+		client: client,
+		local:  locks.NewNamedLocker(),
+		o:      o,
+		logger: logger,
+	}
+}
+
+// NamedLock is a distributed Named Lock
+type NamedLock struct {
+	local    locks.NamedLock
+	locker   *Locker
+	key      string
+	fenceKey string
+	token    string
+	fence    int64
+	cancel   context.CancelFunc
+}
+
+// Acquire locks the named lock for writing, blocking until the lock is acquired
+// locally and in Redis. If another instance does not release the lock within the
+// acquire timeout, locks.ErrAcquireTimeout is returned and no lock is held
+func (lk *Locker) Acquire(lockName string) (locks.NamedLock, error) {
+	ll, err := lk.local.Acquire(lockName)
+	if err != nil {
+		return nil, err
+	}
+	nl := lk.newNamedLock(lockName, ll)
+	if _, err := nl.lockRemote(); err != nil {
+		ll.Release()
+		return nil, err
+	}
+	return nl, nil
+}
+
+// RAcquire locks the named lock for reading, blocking until the lock is acquired
+// locally and no other instance holds the write lock in Redis, or until the
+// acquire timeout elapses. Readers don't require exclusivity, so RAcquire only
+// fails for an invalid lock name
+func (lk *Locker) RAcquire(lockName string) (locks.NamedLock, error) {
+	ll, err := lk.local.RAcquire(lockName)
+	if err != nil {
+		return nil, err
+	}
+	nl := lk.newNamedLock(lockName, ll)
+	nl.waitRemote()
+	return nl, nil
+}
+
+func (lk *Locker) newNamedLock(lockName string, ll locks.NamedLock) *NamedLock {
+	// the lock name is hash-tagged so that the lock and fence keys share a
+	// Redis Cluster slot, as both are used in the same script
+	key := lk.o.KeyPrefix + "{" + lockName + "}"
+	return &NamedLock{local: ll, locker: lk, key: key, fenceKey: key + ":fence"}
+}
+
+// Fence returns the fencing token issued when the write lock was acquired in
+// Redis. Tokens increase with each acquisition of the same lock, and 0 is
+// returned when the write lock is not held in Redis
+func (nl *NamedLock) Fence() int64 {
+	return nl.fence
+}
+
+// Held returns true if the write lock is still held by the caller. A lock that
+// fell back to process-local locking is reported as held, unless RequireRemote
+// is set and Redis can't be reached
+func (nl *NamedLock) Held() bool {
+	if nl.token == "" {
+		return true
+	}
+	n, err := heldScript.Run(context.Background(), nl.locker.client,
+		[]string{nl.key}, nl.token).Int64()
+	if err != nil {
+		return nl.locker.fallback("check", nl.key, err) == nil
+	}
+	return n == 1
+}
+
+// Release releases the write lock
+func (nl *NamedLock) Release() error {
+	nl.unlockRemote()
+	return nl.local.Release()
+}
+
+// RRelease releases the read lock
+func (nl *NamedLock) RRelease() error {
+	return nl.local.RRelease()
+}
+
+// Upgrade upgrades the read lock to a write lock. The return value is true only
+// if the caller was first to receive the write lock both locally and across
+// instances, and is false when the caller had to wait on another holder, in
+// which case any state protected by the lock should be rechecked. An error is
+// returned when the write lock was not acquired in Redis, in which case the
+// caller holds only the process-local write lock, and must still Release it
+func (nl *NamedLock) Upgrade() (bool, error) {
+	first, _ := nl.local.Upgrade()
+	waited, err := nl.lockRemote()
+	return first && !waited, err
+}
+
+// lockRemote acquires the write lock in Redis. The return value is true when the
+// lock was held by another instance and had to be waited on
+func (nl *NamedLock) lockRemote() (bool, error) {
+	lk := nl.locker
+	ctx := context.Background()
+	token, err := newToken()
+	if err != nil {
+		return false, err
+	}
+	lease := time.Duration(lk.o.LeaseMS) * time.Millisecond
+	deadline := time.Now().Add(time.Duration(lk.o.AcquireTimeoutMS) * time.Millisecond)
+	var waited bool
+	for {
+		fence, err := lockScript.Run(ctx, lk.client, []string{nl.key, nl.fenceKey},
+			token, lease.Milliseconds(),
+			lease.Milliseconds()*fenceRetentionLeases).Int64()
+		if err != nil {
+			return waited, lk.fallback("acquire", nl.key, err)
+		}
+		if fence > 0 {
+			nl.token = token
+			nl.fence = fence
+			nl.startRenewal(lease)
+			return waited, nil
+		}
+		waited = true
+		if time.Now().After(deadline) {
+			return waited, locks.ErrAcquireTimeout
+		}
+		time.Sleep(time.Duration(lk.o.PollIntervalMS) * time.Millisecond)
+	}
+}
+
+// waitRemote waits until no instance holds the write lock in Redis
+func (nl *NamedLock) waitRemote() {
+	lk := nl.locker
+	ctx := context.Background()
+	deadline := time.Now().Add(time.Duration(lk.o.AcquireTimeoutMS) * time.Millisecond)
+	for {
+		n, err := lk.client.Exists(ctx, nl.key).Result()
+		if err != nil {
+			lk.fallback("wait", nl.key, err)
+			return
+		}
+		if n == 0 || time.Now().After(deadline) {
+			return
+		}
+		time.Sleep(time.Duration(lk.o.PollIntervalMS) * time.Millisecond)
+	}
+}
+
+func (nl *NamedLock) startRenewal(lease time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	nl.cancel = cancel
+	client, key, token := nl.locker.client, nl.key, nl.token
+	go func() {
+		t := time.NewTicker(lease / 3)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				n, err := renewScript.Run(ctx, client, []string{key}, token,
+					lease.Milliseconds()).Int64()
+				if err != nil || n == 0 {
+					// the lock was lost or can't be renewed, so let the lease run out
+					return
+				}
+			}
+		}
+	}()
+}
+
+func (nl *NamedLock) unlockRemote() {
+	if nl.token == "" {
+		return
+	}
+	if nl.cancel != nil {
+		nl.cancel()
+		nl.cancel = nil
+	}
+	err := unlockScript.Run(context.Background(), nl.locker.client,
+		[]string{nl.key}, nl.token).Err()
+	if err != nil {
+		tl.Debug(nl.locker.logger, "distributed lock release failed",
+			tl.Pairs{"lockKey": nl.key, "detail": err.Error()})
+	}
+	nl.token = ""
+	nl.fence = 0
+}
+
+// fallback handles a lock operation that could not reach Redis. When RequireRemote
+// is set, the failure is returned as an error. Otherwise it is counted, and the
+// operation proceeds with only the process-local lock
+func (lk *Locker) fallback(op, key string, err error) error {
+	if lk.o.RequireRemote {
+		tl.Error(lk.logger, "distributed lock unavailable",
+			tl.Pairs{"lockKey": key, "operation": op, "detail": err.Error()})
+		return fmt.Errorf("distributed lock %s failed: %w", op, err)
+	}
+	metrics.CacheLockFallbacks.WithLabelValues(lk.name, op).Inc()
+	tl.WarnOnce(lk.logger, "redislock.fallback."+lk.name,
+		"distributed lock unavailable, falling back to process-local locking",
+		tl.Pairs{"cacheName": lk.name, "operation": op, "detail": err.Error()})
+	return nil
+}
+
+// newToken returns a random token identifying a single write lock acquisition,
+// so that a lock is only renewed or released by its holder
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
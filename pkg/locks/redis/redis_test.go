@@ -0,0 +1,235 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package redis
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/locks"
+	"github.com/trickstercache/trickster/v2/pkg/locks/options"
+
+	"github.com/alicebob/miniredis"
+	"github.com/redis/go-redis/v9"
+)
+
+// testKey is the Redis key of the "test" lock
+const testKey = options.DefaultKeyPrefix + "{test}"
+
+func newTestLockers(t *testing.T, n int) ([]*Locker, *miniredis.Miniredis) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	o := options.New()
+	o.AcquireTimeoutMS = 2000
+	out := make([]*Locker, n)
+	for i := range out {
+		client := redis.NewClient(&redis.Options{Addr: s.Addr(), MaxRetries: -1})
+		t.Cleanup(func() { client.Close() })
+		// each locker stands in for a separate Trickster instance
+		out[i] = New("test", client, o, nil).(*Locker)
+	}
+	return out, s
+}
+
+func TestAcquireRelease(t *testing.T) {
+	lks, s := newTestLockers(t, 2)
+
+	nl, err := lks[0].Acquire("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := nl.(*NamedLock).token
+	if token == "" {
+		t.Error("expected non-empty token")
+	}
+	if v, _ := s.Get(testKey); v != token {
+		t.Errorf("expected lock key to hold %s got %s", token, v)
+	}
+
+	acquired := make(chan string)
+	go func() {
+		nl2, _ := lks[1].Acquire("test")
+		token2 := nl2.(*NamedLock).token
+		nl2.Release()
+		acquired <- token2
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("expected second instance to wait for the lock")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	nl.Release()
+	if token2 := <-acquired; token2 == "" || token2 == token {
+		t.Errorf("expected a new token got %s", token2)
+	}
+	if s.Exists(testKey) {
+		t.Error("expected lock key to be removed")
+	}
+
+	if _, err := lks[0].Acquire(""); err == nil {
+		t.Error("expected error for invalid lock name")
+	}
+	if _, err := lks[0].RAcquire(""); err == nil {
+		t.Error("expected error for invalid lock name")
+	}
+}
+
+func TestReleaseDoesNotRemoveOtherHolder(t *testing.T) {
+	lks, s := newTestLockers(t, 1)
+	nl, _ := lks[0].Acquire("test")
+	// simulate the lease expiring and another instance acquiring the lock
+	s.Set(testKey, "999999")
+	nl.Release()
+	if v, _ := s.Get(testKey); v != "999999" {
+		t.Errorf("expected lock held by other instance to remain, got %s", v)
+	}
+}
+
+func TestFence(t *testing.T) {
+	lks, s := newTestLockers(t, 2)
+	var _ locks.FencedLock = (*NamedLock)(nil)
+
+	nl, _ := lks[0].Acquire("test")
+	fl := nl.(*NamedLock)
+	if fl.Fence() != 1 || !fl.Held() {
+		t.Errorf("expected held lock with fence 1 got %d", fl.Fence())
+	}
+	if s.TTL(testKey+":fence") <= 0 {
+		t.Error("expected the fence key to expire")
+	}
+	nl.Release()
+	if fl.Fence() != 0 {
+		t.Errorf("expected fence 0 after release got %d", fl.Fence())
+	}
+
+	nl, _ = lks[1].Acquire("test")
+	fl = nl.(*NamedLock)
+	if fl.Fence() != 2 {
+		t.Errorf("expected fence 2 got %d", fl.Fence())
+	}
+	// simulate the lease expiring and another instance acquiring the lock
+	s.Set(testKey, "999999")
+	if fl.Held() {
+		t.Error("expected lock not to be held")
+	}
+	nl.Release()
+}
+
+func TestUpgradeAcrossInstances(t *testing.T) {
+	lks, _ := newTestLockers(t, 3)
+
+	var firsts, origin int32
+	var cached atomic.Bool
+	wg := &sync.WaitGroup{}
+	for _, lk := range lks {
+		wg.Add(1)
+		go func(lk *Locker) {
+			defer wg.Done()
+			nl, _ := lk.RAcquire("key")
+			for i := 0; i < 3; i++ {
+				if cached.Load() {
+					nl.RRelease()
+					return
+				}
+				if first, _ := nl.Upgrade(); first {
+					atomic.AddInt32(&firsts, 1)
+					break
+				}
+				// not first, so recheck the cache under a new read lock
+				nl.Release()
+				nl, _ = lk.RAcquire("key")
+			}
+			// simulate an origin request that populates the cache
+			atomic.AddInt32(&origin, 1)
+			time.Sleep(20 * time.Millisecond)
+			cached.Store(true)
+			nl.Release()
+		}(lk)
+	}
+	wg.Wait()
+	if origin != 1 {
+		t.Errorf("expected 1 origin request got %d", origin)
+	}
+}
+
+func TestUnavailable(t *testing.T) {
+	lks, s := newTestLockers(t, 1)
+	s.Close()
+	// when redis is unavailable, the lock degrades to the process-local lock
+	nl, err := lks[0].RAcquire("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first, err := nl.Upgrade(); !first || err != nil {
+		t.Errorf("expected true and no error got %t %v", first, err)
+	}
+	nl.Release()
+
+	// when a remote lock is required, the write lock fails rather than falling back
+	lks[0].o.RequireRemote = true
+	if _, err := lks[0].Acquire("test"); err == nil {
+		t.Error("expected error")
+	}
+	nl, _ = lks[0].RAcquire("test")
+	if _, err := nl.Upgrade(); err == nil {
+		t.Error("expected error")
+	}
+	nl.Release()
+}
+
+func TestAcquireTimeout(t *testing.T) {
+	lks, s := newTestLockers(t, 1)
+	lks[0].o.AcquireTimeoutMS = 50
+	// another instance holds the lock
+	s.Set(testKey, "other")
+
+	start := time.Now()
+	nl, err := lks[0].Acquire("test")
+	if err != locks.ErrAcquireTimeout {
+		t.Errorf("expected %v got %v", locks.ErrAcquireTimeout, err)
+	}
+	if nl != nil {
+		t.Error("expected nil lock")
+	}
+	if time.Since(start) > time.Second {
+		t.Error("expected acquire to time out")
+	}
+
+	nl, _ = lks[0].RAcquire("test")
+	first, err := nl.Upgrade()
+	if first || err != locks.ErrAcquireTimeout {
+		t.Errorf("expected false and %v got %t %v", locks.ErrAcquireTimeout, first, err)
+	}
+	nl.Release()
+	if v, _ := s.Get(testKey); v != "other" {
+		t.Errorf("expected lock held by other instance to remain, got %s", v)
+	}
+
+	// the local lock was released by the failed acquire
+	s.Del(testKey)
+	nl, err = lks[0].Acquire("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nl.Release()
+}
@@ -31,6 +31,10 @@ const (
 	configSubsystem   = "config"
 	buildSubsystem    = "build"
 	frontendSubsystem = "frontend"
+	warmerSubsystem   = "warmer"
+	healthSubsystem   = "health"
+	mirrorSubsystem   = "mirror"
+	faultSubsystem    = "fault"
 )
 
 // Default histogram buckets used by trickster
@@ -47,6 +51,39 @@ var LastReloadSuccessful prometheus.Gauge
 // LastReloadSuccessfulTimestamp gauge is the epoch time of the most recent successful config load
 var LastReloadSuccessfulTimestamp prometheus.Gauge
 
+// ReloadRollbacks is a Counter of guarded configuration reloads that were rolled back
+var ReloadRollbacks prometheus.Counter
+
+// WarmerRequests is a Counter of requests issued by cache warmers
+var WarmerRequests *prometheus.CounterVec
+
+// WarmerHitsServed is a Counter of client requests served from cache entries populated by a warmer
+var WarmerHitsServed *prometheus.CounterVec
+
+// HealthStatus is a Gauge of each backend's health status: 1 (available), 0 (unknown) or -1 (unavailable)
+var HealthStatus *prometheus.GaugeVec
+
+// HealthFailingSince is a Gauge of the epoch time since which a backend has been unavailable, or 0
+var HealthFailingSince *prometheus.GaugeVec
+
+// HealthTransitions is a Counter of backend health status transitions
+var HealthTransitions *prometheus.CounterVec
+
+// HealthWebhookDeliveries is a Counter of health transition webhook deliveries by result
+var HealthWebhookDeliveries *prometheus.CounterVec
+
+// MirrorRequests is a Counter of requests mirrored to another backend
+var MirrorRequests *prometheus.CounterVec
+
+// MirrorSkipped is a Counter of sampled requests that were not mirrored
+var MirrorSkipped *prometheus.CounterVec
+
+// MirrorComparisons is a Counter of comparisons of primary and mirrored timeseries responses
+var MirrorComparisons *prometheus.CounterVec
+
+// FaultsInjected is a Counter of faults injected into requests
+var FaultsInjected *prometheus.CounterVec
+
 // FrontendRequestStatus is a Counter of front end requests that have been processed with their status
 var FrontendRequestStatus *prometheus.CounterVec
 
@@ -86,6 +123,18 @@ var CacheMaxObjects *prometheus.GaugeVec
 // CacheMaxBytes is a Gauge for the Trickster cache's Max Object Threshold for triggering an eviction exercise
 var CacheMaxBytes *prometheus.GaugeVec
 
+// CacheEvictions is a Counter of objects evicted from a Trickster cache by its Index
+var CacheEvictions *prometheus.CounterVec
+
+// CacheTierLookups is a Counter of lookups performed on each tier of a Trickster tiered cache
+var CacheTierLookups *prometheus.CounterVec
+
+// CacheCorruptObjects is a Counter of corrupt objects found in, and removed from, a Trickster cache
+var CacheCorruptObjects *prometheus.CounterVec
+
+// CacheLockFallbacks is a Counter of distributed lock operations that fell back to process-local locking
+var CacheLockFallbacks *prometheus.CounterVec
+
 // ProxyMaxConnections is a Gauge representing the max number of active concurrent connections in the server
 var ProxyMaxConnections prometheus.Gauge
 
@@ -134,6 +183,115 @@ func init() {
 		},
 	)
 
+	ReloadRollbacks = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: configSubsystem,
+			Name:      "reload_rollbacks_total",
+			Help:      "Count of guarded configuration reloads that were rolled back.",
+		},
+	)
+
+	WarmerRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: warmerSubsystem,
+			Name:      "requests_total",
+			Help:      "Count of requests issued by cache warmers.",
+		},
+		[]string{"warmer_name", "backend_name", "http_status"},
+	)
+
+	WarmerHitsServed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: warmerSubsystem,
+			Name:      "hits_served_total",
+			Help:      "Count of client requests served from cache entries populated by a warmer.",
+		},
+		[]string{"backend_name", "cache_status"},
+	)
+
+	HealthStatus = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: healthSubsystem,
+			Name:      "status",
+			Help:      "Health status of a backend: 1 (available), 0 (unknown) or -1 (unavailable).",
+		},
+		[]string{"backend_name"},
+	)
+
+	HealthFailingSince = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: healthSubsystem,
+			Name:      "failing_since_time_seconds",
+			Help:      "Timestamp since which a backend has been unavailable, or 0 when it is not.",
+		},
+		[]string{"backend_name"},
+	)
+
+	HealthTransitions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: healthSubsystem,
+			Name:      "transitions_total",
+			Help:      "Count of backend health status transitions, by new status.",
+		},
+		[]string{"backend_name", "status"},
+	)
+
+	HealthWebhookDeliveries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: healthSubsystem,
+			Name:      "webhook_deliveries_total",
+			Help:      "Count of health transition webhook deliveries, by result.",
+		},
+		[]string{"webhook_name", "result"},
+	)
+
+	MirrorRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: mirrorSubsystem,
+			Name:      "requests_total",
+			Help:      "Count of requests mirrored to another backend.",
+		},
+		[]string{"backend_name", "mirror_backend", "http_status"},
+	)
+
+	MirrorSkipped = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: mirrorSubsystem,
+			Name:      "skipped_total",
+			Help:      "Count of sampled requests that were not mirrored, by reason.",
+		},
+		[]string{"backend_name", "mirror_backend", "reason"},
+	)
+
+	MirrorComparisons = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: mirrorSubsystem,
+			Name:      "comparisons_total",
+			Help:      "Count of comparisons of primary and mirrored timeseries responses, by result.",
+		},
+		[]string{"backend_name", "mirror_backend", "result"},
+	)
+
+	FaultsInjected = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: faultSubsystem,
+			Name:      "injected_total",
+			Help:      "Count of faults injected into requests, by fault.",
+		},
+		[]string{"backend_name", "fault"},
+	)
+
 	FrontendRequestStatus = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: metricNamespace,
@@ -318,6 +476,46 @@ func init() {
 		[]string{"cache_name", "provider"},
 	)
 
+	CacheEvictions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: cacheSubsystem,
+			Name:      "evictions_total",
+			Help:      "Count of objects evicted from a Trickster cache, by eviction policy.",
+		},
+		[]string{"cache_name", "provider", "policy", "reason"},
+	)
+
+	CacheTierLookups = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: cacheSubsystem,
+			Name:      "tier_lookups_total",
+			Help:      "Count of lookups performed on each tier of a Trickster tiered cache.",
+		},
+		[]string{"cache_name", "tier", "status"},
+	)
+
+	CacheCorruptObjects = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: cacheSubsystem,
+			Name:      "corrupt_objects_total",
+			Help:      "Count of corrupt objects found in, and removed from, a Trickster cache.",
+		},
+		[]string{"cache_name", "provider", "source"},
+	)
+
+	CacheLockFallbacks = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: cacheSubsystem,
+			Name:      "lock_fallbacks_total",
+			Help:      "Count of distributed lock operations that fell back to process-local locking.",
+		},
+		[]string{"cache_name", "operation"},
+	)
+
 	// Register Metrics
 	prometheus.MustRegister(FrontendRequestStatus)
 	prometheus.MustRegister(FrontendRequestDuration)
@@ -338,9 +536,24 @@ func init() {
 	prometheus.MustRegister(CacheBytes)
 	prometheus.MustRegister(CacheMaxObjects)
 	prometheus.MustRegister(CacheMaxBytes)
+	prometheus.MustRegister(CacheEvictions)
+	prometheus.MustRegister(CacheTierLookups)
+	prometheus.MustRegister(CacheCorruptObjects)
+	prometheus.MustRegister(CacheLockFallbacks)
 	prometheus.MustRegister(BuildInfo)
 	prometheus.MustRegister(LastReloadSuccessful)
 	prometheus.MustRegister(LastReloadSuccessfulTimestamp)
+	prometheus.MustRegister(ReloadRollbacks)
+	prometheus.MustRegister(WarmerRequests)
+	prometheus.MustRegister(WarmerHitsServed)
+	prometheus.MustRegister(HealthStatus)
+	prometheus.MustRegister(HealthFailingSince)
+	prometheus.MustRegister(HealthTransitions)
+	prometheus.MustRegister(HealthWebhookDeliveries)
+	prometheus.MustRegister(MirrorRequests)
+	prometheus.MustRegister(MirrorSkipped)
+	prometheus.MustRegister(MirrorComparisons)
+	prometheus.MustRegister(FaultsInjected)
 }
 
 // Handler returns the http handler for the listener
@@ -0,0 +1,234 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package circuitbreaker opens a circuit on a Backend when the outcomes of
+// its live upstream requests show it to be failing or slow, so that further
+// requests fail fast (or are served from cache) until it recovers
+package circuitbreaker
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/proxy/circuitbreaker/options"
+)
+
+// ErrOpen is returned when a request is refused because the circuit is open
+var ErrOpen = errors.New("circuit breaker is open")
+
+// State enumerates the states of a circuit
+type State int32
+
+const (
+	// StateClosed indicates requests flow normally to the upstream
+	StateClosed State = iota
+	// StateOpen indicates requests are refused without contacting the upstream
+	StateOpen
+	// StateHalfOpen indicates a limited number of probe requests are admitted
+	// to determine whether the upstream has recovered
+	StateHalfOpen
+)
+
+var stateNames = map[State]string{
+	StateClosed:   "closed",
+	StateOpen:     "open",
+	StateHalfOpen: "half_open",
+}
+
+func (s State) String() string {
+	if v, ok := stateNames[s]; ok {
+		return v
+	}
+	return strconv.Itoa(int(s))
+}
+
+// Observer is called with the new State and a description of the cause each
+// time the circuit changes state
+type Observer func(State, string)
+
+// Breaker is the circuit breaker for a single Backend. Since it tracks the
+// outcomes of all requests to the Backend, a single Breaker is shared by them
+type Breaker struct {
+	options  *options.Options
+	mtx      sync.Mutex
+	state    State
+	window   *window
+	probes   int
+	passed   int
+	observer Observer
+}
+
+// New returns a new Breaker for the provided Options, or nil if they are nil
+func New(o *options.Options) *Breaker {
+	if o == nil {
+		return nil
+	}
+	return &Breaker{
+		options: o,
+		window:  newWindow(time.Duration(o.WindowMS) * time.Millisecond),
+	}
+}
+
+// Options returns the Options the Breaker was created with
+func (b *Breaker) Options() *options.Options {
+	return b.options
+}
+
+// State returns the current State of the circuit
+func (b *Breaker) State() State {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.state
+}
+
+// ServesStale returns true if the circuit is not closed and is configured to
+// serve stale cached content in place of failing fast. While the circuit is
+// half-open, this applies to the requests refused by Allow. It is safe to call
+// on a nil Breaker
+func (b *Breaker) ServesStale() bool {
+	return b != nil && b.options.OpenAction == options.OpenActionServeStale &&
+		b.State() != StateClosed
+}
+
+// IsOpen returns true if the circuit is open. It is safe to call on a nil Breaker
+func (b *Breaker) IsOpen() bool {
+	return b != nil && b.State() == StateOpen
+}
+
+// OnStateChange sets the Observer notified when the circuit changes state
+func (b *Breaker) OnStateChange(f Observer) {
+	b.mtx.Lock()
+	b.observer = f
+	b.mtx.Unlock()
+}
+
+// Allow returns true if a request may be sent upstream. While the circuit is
+// half-open, only the configured number of probe requests are allowed
+func (b *Breaker) Allow() bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	switch b.state {
+	case StateOpen:
+		return false
+	case StateHalfOpen:
+		if b.probes >= b.options.HalfOpenProbes {
+			return false
+		}
+		b.probes++
+	}
+	return true
+}
+
+// Release returns a half-open probe allowed by Allow, for a request whose outcome
+// is not recorded, such as one canceled by its client, so that another request
+// can probe the upstream in its place
+func (b *Breaker) Release() {
+	b.mtx.Lock()
+	if b.state == StateHalfOpen && b.probes > b.passed {
+		b.probes--
+	}
+	b.mtx.Unlock()
+}
+
+// Record records the outcome of an upstream request, where failed is true for
+// network errors and 5xx responses, and elapsed is the upstream response time
+func (b *Breaker) Record(failed bool, elapsed time.Duration) {
+	slow := b.options.SlowRequestMS > 0 &&
+		elapsed >= time.Duration(b.options.SlowRequestMS)*time.Millisecond
+	b.mtx.Lock()
+	var detail string
+	switch b.state {
+	case StateOpen:
+		// outcomes of requests sent before the circuit opened are ignored
+		b.mtx.Unlock()
+		return
+	case StateHalfOpen:
+		switch {
+		case failed || slow:
+			detail = "circuit open: half-open probe failed"
+			b.open()
+		case b.passed+1 >= b.options.HalfOpenProbes:
+			b.close()
+		default:
+			b.passed++
+			b.mtx.Unlock()
+			return
+		}
+	default:
+		now := time.Now()
+		b.window.add(now, failed, slow)
+		if detail = b.trip(now); detail == "" {
+			b.mtx.Unlock()
+			return
+		}
+		b.open()
+	}
+	s, f := b.state, b.observer
+	b.mtx.Unlock()
+	if f != nil {
+		f(s, detail)
+	}
+}
+
+// trip returns a description of the cause if the outcomes in the window
+// should open the circuit, and otherwise an empty string
+func (b *Breaker) trip(now time.Time) string {
+	total, failed, slow := b.window.sum(now)
+	if total == 0 || total < b.options.MinRequests {
+		return ""
+	}
+	o := b.options
+	if r := float64(failed) / float64(total); o.ErrorRate > 0 && r >= o.ErrorRate {
+		return fmt.Sprintf("circuit open: error rate %.2f over %dms", r, o.WindowMS)
+	}
+	if r := float64(slow) / float64(total); o.SlowRequestMS > 0 && o.SlowRate > 0 &&
+		r >= o.SlowRate {
+		return fmt.Sprintf("circuit open: slow request rate %.2f over %dms", r, o.WindowMS)
+	}
+	return ""
+}
+
+// open opens the circuit and schedules it to half-open. the caller must hold the lock
+func (b *Breaker) open() {
+	b.state = StateOpen
+	b.window.reset()
+	time.AfterFunc(time.Duration(b.options.OpenMS)*time.Millisecond, b.halfOpen)
+}
+
+// close closes the circuit. the caller must hold the lock
+func (b *Breaker) close() {
+	b.state = StateClosed
+	b.probes, b.passed = 0, 0
+	b.window.reset()
+}
+
+func (b *Breaker) halfOpen() {
+	b.mtx.Lock()
+	if b.state != StateOpen {
+		b.mtx.Unlock()
+		return
+	}
+	b.state = StateHalfOpen
+	b.probes, b.passed = 0, 0
+	f := b.observer
+	b.mtx.Unlock()
+	if f != nil {
+		f(StateHalfOpen, "circuit half-open")
+	}
+}
@@ -0,0 +1,264 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package circuitbreaker
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/proxy/circuitbreaker/options"
+)
+
+func testOptions() *options.Options {
+	o := options.New()
+	o.MinRequests = 4
+	o.OpenMS = 50
+	o.HalfOpenProbes = 2
+	return o
+}
+
+type observed struct {
+	mtx     sync.Mutex
+	states  []State
+	details []string
+}
+
+func (ob *observed) observe(s State, d string) {
+	ob.mtx.Lock()
+	ob.states = append(ob.states, s)
+	ob.details = append(ob.details, d)
+	ob.mtx.Unlock()
+}
+
+func (ob *observed) get() ([]State, []string) {
+	ob.mtx.Lock()
+	defer ob.mtx.Unlock()
+	return append([]State(nil), ob.states...), append([]string(nil), ob.details...)
+}
+
+func TestNew(t *testing.T) {
+	if New(nil) != nil {
+		t.Error("expected nil breaker")
+	}
+	o := options.New()
+	b := New(o)
+	if b.Options() != o {
+		t.Error("options mismatch")
+	}
+	if b.State() != StateClosed {
+		t.Errorf("expected %s got %s", StateClosed, b.State())
+	}
+}
+
+func TestStateString(t *testing.T) {
+	if StateHalfOpen.String() != "half_open" {
+		t.Errorf("expected %s got %s", "half_open", StateHalfOpen.String())
+	}
+	if State(9).String() != "9" {
+		t.Errorf("expected %s got %s", "9", State(9).String())
+	}
+}
+
+func TestErrorRate(t *testing.T) {
+	b := New(testOptions())
+	ob := &observed{}
+	b.OnStateChange(ob.observe)
+
+	// below min_requests, the circuit stays closed regardless of error rate
+	for range 3 {
+		b.Record(true, 0)
+	}
+	if b.State() != StateClosed {
+		t.Fatalf("expected %s got %s", StateClosed, b.State())
+	}
+	b.Record(false, 0)
+	if b.State() != StateOpen {
+		t.Fatalf("expected %s got %s", StateOpen, b.State())
+	}
+	if b.Allow() {
+		t.Error("expected open circuit to refuse requests")
+	}
+	// outcomes of in-flight requests are ignored while open
+	b.Record(false, 0)
+	if b.State() != StateOpen {
+		t.Fatalf("expected %s got %s", StateOpen, b.State())
+	}
+	s, d := ob.get()
+	if len(s) != 1 || s[0] != StateOpen {
+		t.Fatalf("unexpected state changes %v", s)
+	}
+	if d[0] != "circuit open: error rate 0.75 over 10000ms" {
+		t.Errorf("unexpected detail %s", d[0])
+	}
+}
+
+func TestSlowRate(t *testing.T) {
+	o := testOptions()
+	o.ErrorRate = 0
+	o.SlowRequestMS = 100
+	b := New(o)
+	for range 2 {
+		b.Record(true, 0)
+		b.Record(false, 200*time.Millisecond)
+	}
+	if b.State() != StateOpen {
+		t.Errorf("expected %s got %s", StateOpen, b.State())
+	}
+
+	o.SlowRequestMS = 0
+	b = New(o)
+	for range 4 {
+		b.Record(true, 200*time.Millisecond)
+	}
+	if b.State() != StateClosed {
+		t.Errorf("expected %s got %s", StateClosed, b.State())
+	}
+}
+
+func TestHalfOpen(t *testing.T) {
+	b := New(testOptions())
+	ob := &observed{}
+	b.OnStateChange(ob.observe)
+	for range 4 {
+		b.Record(true, 0)
+	}
+	if b.State() != StateOpen {
+		t.Fatalf("expected %s got %s", StateOpen, b.State())
+	}
+	time.Sleep(100 * time.Millisecond)
+	if b.State() != StateHalfOpen {
+		t.Fatalf("expected %s got %s", StateHalfOpen, b.State())
+	}
+
+	// only half_open_probes requests are admitted
+	if !b.Allow() || !b.Allow() || b.Allow() {
+		t.Error("expected exactly 2 probes to be allowed")
+	}
+	b.Record(false, 0)
+	if b.State() != StateHalfOpen {
+		t.Fatalf("expected %s got %s", StateHalfOpen, b.State())
+	}
+	b.Record(false, 0)
+	if b.State() != StateClosed {
+		t.Fatalf("expected %s got %s", StateClosed, b.State())
+	}
+	if !b.Allow() {
+		t.Error("expected closed circuit to allow requests")
+	}
+
+	// a failed probe re-opens the circuit
+	for range 4 {
+		b.Record(true, 0)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if !b.Allow() {
+		t.Fatal("expected probe to be allowed")
+	}
+	b.Record(true, 0)
+	if b.State() != StateOpen {
+		t.Fatalf("expected %s got %s", StateOpen, b.State())
+	}
+
+	expected := []State{StateOpen, StateHalfOpen, StateClosed, StateOpen,
+		StateHalfOpen, StateOpen}
+	s, _ := ob.get()
+	if len(s) != len(expected) {
+		t.Fatalf("expected %v got %v", expected, s)
+	}
+	for i := range s {
+		if s[i] != expected[i] {
+			t.Errorf("expected %v got %v", expected, s)
+			break
+		}
+	}
+}
+
+func TestRelease(t *testing.T) {
+	b := New(testOptions())
+	for range 4 {
+		b.Record(true, 0)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if !b.Allow() || !b.Allow() || b.Allow() {
+		t.Fatal("expected exactly 2 probes to be allowed")
+	}
+	// a released probe can be taken by another request
+	b.Release()
+	if !b.Allow() || b.Allow() {
+		t.Error("expected the released probe to be allowed")
+	}
+	// a probe whose outcome was recorded is not released, so only the one
+	// that is still outstanding can be released
+	b.Record(false, 0)
+	b.Release()
+	b.Release()
+	if !b.Allow() || b.Allow() {
+		t.Error("expected exactly 1 probe to be allowed")
+	}
+	if b.State() != StateHalfOpen {
+		t.Errorf("expected %s got %s", StateHalfOpen, b.State())
+	}
+}
+
+func TestWindow(t *testing.T) {
+	w := newWindow(100 * time.Millisecond)
+	now := time.Now()
+	w.add(now, true, false)
+	w.add(now, false, true)
+	if total, failed, slow := w.sum(now); total != 2 || failed != 1 || slow != 1 {
+		t.Errorf("unexpected sums %d %d %d", total, failed, slow)
+	}
+	// outcomes age out of the window
+	later := now.Add(200 * time.Millisecond)
+	w.add(later, false, false)
+	if total, failed, _ := w.sum(later); total != 1 || failed != 0 {
+		t.Errorf("unexpected sums %d %d", total, failed)
+	}
+	w.reset()
+	if total, _, _ := w.sum(later); total != 0 {
+		t.Errorf("expected %d got %d", 0, total)
+	}
+}
+
+func TestServesStale(t *testing.T) {
+	var nb *Breaker
+	if nb.ServesStale() || nb.IsOpen() {
+		t.Error("expected false for nil breaker")
+	}
+	o := testOptions()
+	o.OpenAction = options.OpenActionServeStale
+	b := New(o)
+	if b.ServesStale() {
+		t.Error("expected closed circuit not to serve stale")
+	}
+	for range 4 {
+		b.Record(true, 0)
+	}
+	if !b.ServesStale() || !b.IsOpen() {
+		t.Error("expected open circuit to serve stale")
+	}
+	time.Sleep(100 * time.Millisecond)
+	// requests refused while half-open are also served stale content
+	if !b.ServesStale() || b.IsOpen() {
+		t.Errorf("expected half-open circuit to serve stale, state %s", b.State())
+	}
+	o.OpenAction = options.OpenActionFail
+	if b.ServesStale() {
+		t.Error("expected circuit not to serve stale with the fail action")
+	}
+}
@@ -0,0 +1,197 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package options provides the circuit breaker options for Backends
+package options
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/trickstercache/trickster/v2/pkg/util/yamlx"
+)
+
+// OpenAction enumerates how requests are handled while a circuit is open
+type OpenAction int
+
+const (
+	// OpenActionFail fails requests immediately with a 503 Service Unavailable
+	OpenActionFail OpenAction = iota
+	// OpenActionServeStale serves stale cached content when it is available,
+	// and otherwise fails the request immediately
+	OpenActionServeStale
+)
+
+// OpenActionNames is a map of OpenActions keyed by name
+var OpenActionNames = map[string]OpenAction{
+	"fail":        OpenActionFail,
+	"serve_stale": OpenActionServeStale,
+}
+
+// OpenActionValues is a map of OpenActions valued by name
+var OpenActionValues = map[OpenAction]string{
+	OpenActionFail:       "fail",
+	OpenActionServeStale: "serve_stale",
+}
+
+func (a OpenAction) String() string {
+	if v, ok := OpenActionValues[a]; ok {
+		return v
+	}
+	return strconv.Itoa(int(a))
+}
+
+// Options defines the circuit breaker policy for a Backend
+type Options struct {
+	// WindowMS is the duration of the rolling window of request outcomes
+	WindowMS int `json:"window_ms,omitempty"`
+	// MinRequests is the number of requests required in the window before the
+	// circuit can open
+	MinRequests int `json:"min_requests,omitempty"`
+	// ErrorRate is the ratio (0 to 1) of failed requests in the window, whether
+	// by network error or a 5xx response, that opens the circuit. 0 disables
+	ErrorRate float64 `json:"error_rate,omitempty"`
+	// SlowRequestMS is the upstream response time at which a request is slow. 0 disables
+	SlowRequestMS int `json:"slow_request_ms,omitempty"`
+	// SlowRate is the ratio (0 to 1) of slow requests in the window that opens the circuit
+	SlowRate float64 `json:"slow_rate,omitempty"`
+	// OpenMS is how long the circuit stays open before it is half-open
+	OpenMS int `json:"open_ms,omitempty"`
+	// HalfOpenProbes is the number of requests admitted while the circuit is
+	// half-open, all of which must succeed for the circuit to close
+	HalfOpenProbes int `json:"half_open_probes,omitempty"`
+	// OpenActionName is the action ("fail", "serve_stale") taken for requests while the circuit is open
+	OpenActionName string `json:"open_action,omitempty"`
+	//
+	// synthetic values
+	// OpenAction is the parsed value of OpenActionName
+	OpenAction OpenAction `json:"-"`
+}
+
+const (
+	// DefaultWindowMS is the default duration of the rolling window
+	DefaultWindowMS = 10000
+	// DefaultMinRequests is the default number of requests required to open the circuit
+	DefaultMinRequests = 20
+	// DefaultErrorRate is the default ratio of failed requests that opens the circuit
+	DefaultErrorRate = 0.5
+	// DefaultSlowRate is the default ratio of slow requests that opens the circuit
+	DefaultSlowRate = 0.5
+	// DefaultOpenMS is the default duration the circuit stays open
+	DefaultOpenMS = 30000
+	// DefaultHalfOpenProbes is the default number of requests admitted while half-open
+	DefaultHalfOpenProbes = 3
+	// DefaultOpenActionName is the default action taken while the circuit is open
+	DefaultOpenActionName = "fail"
+)
+
+var (
+	// ErrInvalidWindow is returned when the window or open durations are not positive
+	ErrInvalidWindow = errors.New("window_ms and open_ms must be > 0")
+	// ErrInvalidRate is returned when a rate is not between 0 and 1
+	ErrInvalidRate = errors.New("error_rate and slow_rate must be between 0 and 1")
+	// ErrInvalidHalfOpenProbes is returned when the number of half-open probes is not positive
+	ErrInvalidHalfOpenProbes = errors.New("half_open_probes must be > 0")
+	// ErrInvalidOpenAction is returned when the open action name is unknown
+	ErrInvalidOpenAction = errors.New("open_action must be one of: fail, serve_stale")
+)
+
+// New returns a New Options object with the default values
+func New() *Options {
+	return &Options{
+		WindowMS:       DefaultWindowMS,
+		MinRequests:    DefaultMinRequests,
+		ErrorRate:      DefaultErrorRate,
+		SlowRate:       DefaultSlowRate,
+		OpenMS:         DefaultOpenMS,
+		HalfOpenProbes: DefaultHalfOpenProbes,
+		OpenActionName: DefaultOpenActionName,
+		OpenAction:     OpenActionFail,
+	}
+}
+
+// Clone returns a perfect copy of the Options
+func (o *Options) Clone() *Options {
+	no := *o
+	return &no
+}
+
+// Validate returns an error if the Options are invalid, and otherwise sets
+// the parsed OpenAction
+func (o *Options) Validate() error {
+	if o.WindowMS <= 0 || o.OpenMS <= 0 {
+		return ErrInvalidWindow
+	}
+	if o.ErrorRate < 0 || o.ErrorRate > 1 || o.SlowRate < 0 || o.SlowRate > 1 {
+		return ErrInvalidRate
+	}
+	if o.HalfOpenProbes <= 0 {
+		return ErrInvalidHalfOpenProbes
+	}
+	a, ok := OpenActionNames[strings.ToLower(o.OpenActionName)]
+	if !ok {
+		return ErrInvalidOpenAction
+	}
+	o.OpenAction = a
+	return nil
+}
+
+// SetDefaults overlays the options defined in the yaml metadata for the named
+// Backend onto the default Options. It returns nil if no circuit breaker
+// options are defined
+func SetDefaults(name string, options *Options, metadata yamlx.KeyLookup) (*Options, error) {
+	if metadata == nil || options == nil ||
+		!metadata.IsDefined("backends", name, "circuit_breaker") {
+		return nil, nil
+	}
+
+	o := New()
+
+	if metadata.IsDefined("backends", name, "circuit_breaker", "window_ms") {
+		o.WindowMS = options.WindowMS
+	}
+
+	if metadata.IsDefined("backends", name, "circuit_breaker", "min_requests") {
+		o.MinRequests = options.MinRequests
+	}
+
+	if metadata.IsDefined("backends", name, "circuit_breaker", "error_rate") {
+		o.ErrorRate = options.ErrorRate
+	}
+
+	if metadata.IsDefined("backends", name, "circuit_breaker", "slow_request_ms") {
+		o.SlowRequestMS = options.SlowRequestMS
+	}
+
+	if metadata.IsDefined("backends", name, "circuit_breaker", "slow_rate") {
+		o.SlowRate = options.SlowRate
+	}
+
+	if metadata.IsDefined("backends", name, "circuit_breaker", "open_ms") {
+		o.OpenMS = options.OpenMS
+	}
+
+	if metadata.IsDefined("backends", name, "circuit_breaker", "half_open_probes") {
+		o.HalfOpenProbes = options.HalfOpenProbes
+	}
+
+	if metadata.IsDefined("backends", name, "circuit_breaker", "open_action") {
+		o.OpenActionName = strings.ToLower(options.OpenActionName)
+	}
+
+	return o, nil
+}
@@ -0,0 +1,168 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/trickstercache/trickster/v2/pkg/util/yamlx"
+
+	"sigs.k8s.io/yaml"
+)
+
+type testOptions1 struct {
+	Backends map[string]*testOptions2 `json:"backends,omitempty"`
+}
+
+type testOptions2 struct {
+	CircuitBreaker *Options `json:"circuit_breaker,omitempty"`
+}
+
+func fromYAML(conf string) (*Options, yamlx.KeyLookup, error) {
+	to := &testOptions1{}
+	err := yaml.Unmarshal([]byte(conf), to)
+	if err != nil {
+		return nil, nil, err
+	}
+	md, err := yamlx.GetKeyList(conf)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, v := range to.Backends {
+		if v != nil && v.CircuitBreaker != nil {
+			return v.CircuitBreaker, md, nil
+		}
+	}
+	return nil, md, nil
+}
+
+const testYAML = `
+backends:
+  test:
+    circuit_breaker:
+      window_ms: 5000
+      min_requests: 10
+      error_rate: 0.25
+      slow_request_ms: 750
+      slow_rate: 0.75
+      open_ms: 15000
+      half_open_probes: 2
+      open_action: Serve_Stale
+`
+
+func TestSetDefaults(t *testing.T) {
+	o, md, err := fromYAML(testYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o2, err := SetDefaults("test", o, md)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o2 == nil {
+		t.Fatal("expected non-nil options")
+	}
+	if o2.WindowMS != 5000 || o2.MinRequests != 10 || o2.ErrorRate != 0.25 ||
+		o2.SlowRequestMS != 750 || o2.SlowRate != 0.75 || o2.OpenMS != 15000 ||
+		o2.HalfOpenProbes != 2 || o2.OpenActionName != "serve_stale" {
+		t.Errorf("unexpected options: %+v", o2)
+	}
+	if err := o2.Validate(); err != nil {
+		t.Error(err)
+	}
+	if o2.OpenAction != OpenActionServeStale {
+		t.Errorf("expected %s got %s", OpenActionServeStale, o2.OpenAction)
+	}
+
+	o3, err := SetDefaults("test2", o, md)
+	if err != nil {
+		t.Error(err)
+	}
+	if o3 != nil {
+		t.Error("expected nil options for undefined backend")
+	}
+
+	o3, err = SetDefaults("test", nil, md)
+	if err != nil || o3 != nil {
+		t.Error("expected nil options")
+	}
+}
+
+func TestSetDefaultsPartial(t *testing.T) {
+	o, md, err := fromYAML(`
+backends:
+  test:
+    circuit_breaker:
+      error_rate: 0.1
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o2, err := SetDefaults("test", o, md)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o2.ErrorRate != 0.1 {
+		t.Errorf("expected %f got %f", 0.1, o2.ErrorRate)
+	}
+	if o2.WindowMS != DefaultWindowMS || o2.OpenMS != DefaultOpenMS ||
+		o2.HalfOpenProbes != DefaultHalfOpenProbes || o2.OpenActionName != DefaultOpenActionName {
+		t.Errorf("unexpected options: %+v", o2)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		f   func(*Options)
+		err error
+	}{
+		{func(o *Options) {}, nil},
+		{func(o *Options) { o.WindowMS = 0 }, ErrInvalidWindow},
+		{func(o *Options) { o.OpenMS = -1 }, ErrInvalidWindow},
+		{func(o *Options) { o.ErrorRate = 1.5 }, ErrInvalidRate},
+		{func(o *Options) { o.SlowRate = -0.5 }, ErrInvalidRate},
+		{func(o *Options) { o.HalfOpenProbes = 0 }, ErrInvalidHalfOpenProbes},
+		{func(o *Options) { o.OpenActionName = "retry" }, ErrInvalidOpenAction},
+	}
+	for i, test := range tests {
+		o := New()
+		test.f(o)
+		if err := o.Validate(); !errors.Is(err, test.err) {
+			t.Errorf("test %d: expected %v got %v", i, test.err, err)
+		}
+	}
+}
+
+func TestClone(t *testing.T) {
+	o := New()
+	o.ErrorRate = 0.9
+	o2 := o.Clone()
+	o2.ErrorRate = 0.1
+	if o.ErrorRate != 0.9 || o2.ErrorRate != 0.1 {
+		t.Error("clone mismatch")
+	}
+}
+
+func TestOpenActionString(t *testing.T) {
+	if OpenActionServeStale.String() != "serve_stale" {
+		t.Errorf("expected %s got %s", "serve_stale", OpenActionServeStale.String())
+	}
+	if OpenAction(5).String() != "5" {
+		t.Errorf("expected %s got %s", "5", OpenAction(5).String())
+	}
+}
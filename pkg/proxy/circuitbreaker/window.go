@@ -0,0 +1,75 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package circuitbreaker
+
+import "time"
+
+// windowBuckets is the number of buckets the rolling window is divided into
+const windowBuckets = 10
+
+// bucket holds the request outcomes for one slice of the rolling window
+type bucket struct {
+	epoch  int64
+	total  int
+	failed int
+	slow   int
+}
+
+// window counts request outcomes over a rolling duration. It is not safe for
+// concurrent use; the Breaker serializes access to it
+type window struct {
+	width   int64
+	buckets [windowBuckets]bucket
+}
+
+func newWindow(d time.Duration) *window {
+	width := max(int64(d/windowBuckets), int64(time.Millisecond))
+	return &window{width: width}
+}
+
+// add records an outcome in the bucket for the provided time
+func (w *window) add(now time.Time, failed, slow bool) {
+	epoch := now.UnixNano() / w.width
+	b := &w.buckets[epoch%windowBuckets]
+	if b.epoch != epoch {
+		*b = bucket{epoch: epoch}
+	}
+	b.total++
+	if failed {
+		b.failed++
+	}
+	if slow {
+		b.slow++
+	}
+}
+
+// sum returns the outcomes recorded in the window ending at the provided time
+func (w *window) sum(now time.Time) (total, failed, slow int) {
+	epoch := now.UnixNano() / w.width
+	for _, b := range w.buckets {
+		if b.epoch > epoch-windowBuckets && b.epoch <= epoch {
+			total += b.total
+			failed += b.failed
+			slow += b.slow
+		}
+	}
+	return
+}
+
+func (w *window) reset() {
+	w.buckets = [windowBuckets]bucket{}
+}
@@ -0,0 +1,35 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package context
+
+import (
+	"context"
+)
+
+// WithCacheInspection returns a copy of the provided context that also includes the
+// report to be populated by the caching engine, in place of serving the request
+func WithCacheInspection(ctx context.Context, report interface{}) context.Context {
+	return context.WithValue(ctx, cacheInspectionKey, report)
+}
+
+// CacheInspection returns the interface reference to the request's cache inspection
+// report, or nil if the request is not a cache inspection
+func CacheInspection(ctx context.Context) interface{} {
+	if ctx == nil {
+		return nil
+	}
+	return ctx.Value(cacheInspectionKey)
+}
@@ -24,4 +24,8 @@ const (
 	rewriterHopsKey
 	healthCheckKey
 	requestBodyKey
+	warmerKey
+	cacheInspectionKey
+	mirrorKey
+	upstreamDelayKey
 )
@@ -0,0 +1,39 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package context
+
+import (
+	"context"
+)
+
+// WithMirror returns a copy of the provided context that also includes the name
+// of the backend whose request is being mirrored
+func WithMirror(ctx context.Context, backendName string) context.Context {
+	return context.WithValue(ctx, mirrorKey, backendName)
+}
+
+// Mirror returns the name of the backend whose request is being mirrored, or an
+// empty string if the request is not a mirrored request
+func Mirror(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if v, ok := ctx.Value(mirrorKey).(string); ok {
+		return v
+	}
+	return ""
+}
@@ -0,0 +1,40 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package context
+
+import (
+	"context"
+	"time"
+)
+
+// WithUpstreamDelay returns a copy of the provided context that also includes
+// an injected delay to apply to each request sent upstream
+func WithUpstreamDelay(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, upstreamDelayKey, d)
+}
+
+// UpstreamDelay returns the injected delay to apply to each request sent
+// upstream, or 0 if there is none
+func UpstreamDelay(ctx context.Context) time.Duration {
+	if ctx == nil {
+		return 0
+	}
+	if v, ok := ctx.Value(upstreamDelayKey).(time.Duration); ok {
+		return v
+	}
+	return 0
+}
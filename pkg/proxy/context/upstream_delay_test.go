@@ -0,0 +1,37 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package context
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestUpstreamDelay(t *testing.T) {
+	if d := UpstreamDelay(nil); d != 0 {
+		t.Errorf("expected 0 got %s", d)
+	}
+	ctx := context.Background()
+	if d := UpstreamDelay(ctx); d != 0 {
+		t.Errorf("expected 0 got %s", d)
+	}
+	ctx = WithUpstreamDelay(ctx, time.Second)
+	if d := UpstreamDelay(ctx); d != time.Second {
+		t.Errorf("expected %s got %s", time.Second, d)
+	}
+}
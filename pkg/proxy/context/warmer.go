@@ -0,0 +1,39 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package context
+
+import (
+	"context"
+)
+
+// WithWarmer returns a copy of the provided context that also includes the name
+// of the cache warmer issuing the request
+func WithWarmer(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, warmerKey, name)
+}
+
+// Warmer returns the name of the cache warmer issuing the request, or an empty
+// string if the request did not originate from a warmer
+func Warmer(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if v, ok := ctx.Value(warmerKey).(string); ok {
+		return v
+	}
+	return ""
+}
@@ -0,0 +1,36 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package context
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWarmer(t *testing.T) {
+	if s := Warmer(nil); s != "" {
+		t.Errorf("expected empty string got %s", s)
+	}
+	ctx := context.Background()
+	if s := Warmer(ctx); s != "" {
+		t.Errorf("expected empty string got %s", s)
+	}
+	ctx = WithWarmer(ctx, "test")
+	if s := Warmer(ctx); s != "test" {
+		t.Errorf("expected %s got %s", "test", s)
+	}
+}
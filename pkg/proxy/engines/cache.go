@@ -17,15 +17,16 @@
 package engines
 
 import (
-	"bytes"
 	"context"
-	"io"
 	"mime"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/trickstercache/trickster/v2/pkg/cache"
+	"github.com/trickstercache/trickster/v2/pkg/cache/envelope"
+	"github.com/trickstercache/trickster/v2/pkg/cache/metrics"
+	co "github.com/trickstercache/trickster/v2/pkg/cache/options"
 	"github.com/trickstercache/trickster/v2/pkg/cache/status"
 	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
 	tspan "github.com/trickstercache/trickster/v2/pkg/observability/tracing/span"
@@ -33,12 +34,24 @@ import (
 	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/ranges/byterange"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/request"
-
-	"github.com/andybalholm/brotli"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
 
+// removeCorruptDocument removes a cached document that failed its checksum,
+// decryption or decoding, so it is replaced by the next write, rather than failing
+// every read. Documents that are unreadable by this instance, but not corrupt, such
+// as those written by a newer version or encrypted with a key that is no longer
+// loaded, are treated as a miss and left in place for instances that can read them
+func removeCorruptDocument(c cache.Cache, key string, err error) {
+	if !envelope.IsCorrupt(err) {
+		return
+	}
+	cc := c.Configuration()
+	metrics.ObserveCacheCorruption(cc.Name, cc.Provider, "read")
+	go c.Remove(key)
+}
+
 // QueryCache queries the cache for an HTTPDocument and returns it
 func QueryCache(ctx context.Context, c cache.Cache, key string,
 	ranges byterange.Ranges,
@@ -55,8 +68,7 @@ func QueryCache(ctx context.Context, c cache.Cache, key string,
 	var b []byte
 	var err error
 
-	if c.Configuration().Provider == "memory" {
-		mc := c.(cache.MemoryCache)
+	if mc, ok := referenceCache(c); ok {
 		var ifc interface{}
 		ifc, lookupStatus, err = mc.RetrieveReference(key, true)
 
@@ -91,37 +103,26 @@ func QueryCache(ctx context.Context, c cache.Cache, key string,
 			return d, lookupStatus, nr, err
 		}
 
-		var inflate bool
-		// check and remove compression bit
-		if len(b) > 0 {
-			if b[0] == 1 {
-				inflate = true
-			}
-			b = b[1:]
+		b, err = cacheEnvelope(c).Open(b)
+		if err != nil {
+			tl.Error(rsc.Logger, "error decoding cache document", tl.Pairs{
+				"cacheKey": key,
+				"detail":   err.Error(),
+			})
+			removeCorruptDocument(c, key, err)
+			tspan.SetAttributes(rsc.Tracer, span, attribute.String("cache.status", status.LookupStatusKeyMiss.String()))
+			return d, status.LookupStatusKeyMiss, ranges, cache.ErrKNF
 		}
 
-		if inflate {
-			tl.Debug(rsc.Logger, "decompressing cached data", tl.Pairs{"cacheKey": key})
-			decoder := brotli.NewReader(bytes.NewReader(b))
-			b, err = io.ReadAll(decoder)
-			if err != nil {
-				tl.Error(rsc.Logger, "error decoding cache document", tl.Pairs{
-					"cacheKey": key,
-					"detail":   err.Error(),
-				})
-				tspan.SetAttributes(rsc.Tracer, span, attribute.String("cache.status", status.LookupStatusKeyMiss.String()))
-				return d, status.LookupStatusKeyMiss, ranges, err
-			}
-
-		}
 		_, err = d.UnmarshalMsg(b)
 		if err != nil {
 			tl.Error(rsc.Logger, "error unmarshaling cache document", tl.Pairs{
 				"cacheKey": key,
 				"detail":   err.Error(),
 			})
+			removeCorruptDocument(c, key, err)
 			tspan.SetAttributes(rsc.Tracer, span, attribute.String("cache.status", status.LookupStatusKeyMiss.String()))
-			return d, status.LookupStatusKeyMiss, ranges, err
+			return d, status.LookupStatusKeyMiss, ranges, cache.ErrKNF
 		}
 
 	}
@@ -154,6 +155,34 @@ func QueryCache(ctx context.Context, c cache.Cache, key string,
 	return d, lookupStatus, delta, nil
 }
 
+// referenceCache returns the cache as a MemoryCache when it stores documents by
+// reference, which is true of memory caches and tiered caches with a memory L1
+func referenceCache(c cache.Cache) (cache.MemoryCache, bool) {
+	mc, ok := c.(cache.MemoryCache)
+	return mc, ok && storesReferences(c.Configuration())
+}
+
+// storesReferences returns true if caches with the provided options store documents
+// by reference
+func storesReferences(o *co.Options) bool {
+	if o == nil {
+		return false
+	}
+	if o.Provider == "tiered" {
+		return o.L1Options != nil && o.L1Options.Provider == "memory"
+	}
+	return o.Provider == "memory"
+}
+
+// cacheEnvelope returns the Envelope used to seal and open serialized documents
+// in the cache, which is the Default Envelope if the cache options don't provide one
+func cacheEnvelope(c cache.Cache) *envelope.Envelope {
+	if o := c.Configuration(); o != nil && o.Envelope != nil {
+		return o.Envelope
+	}
+	return envelope.Default
+}
+
 func stripConditionalHeaders(h http.Header) {
 	h.Del(headers.NameIfMatch)
 	h.Del(headers.NameIfUnmodifiedSince)
@@ -195,9 +224,7 @@ func WriteCache(ctx context.Context, c cache.Cache, key string, d *HTTPDocument,
 	}
 
 	// for memory cache, don't serialize the document, since we can retrieve it by reference.
-	if c.Configuration().Provider == "memory" {
-		mc := c.(cache.MemoryCache)
-
+	if mc, ok := referenceCache(c); ok {
 		if d != nil {
 			// during unmarshal, these would come back as false, so lets set them as such even for direct access
 			d.rangePartsLoaded = false
@@ -208,6 +235,7 @@ func WriteCache(ctx context.Context, c cache.Cache, key string, d *HTTPDocument,
 			if d.CachingPolicy != nil {
 				d.CachingPolicy.ResetClientConditionals()
 			}
+			d.compressible = compress
 		}
 
 		return mc.StoreReference(key, d, ttl)
@@ -222,15 +250,13 @@ func WriteCache(ctx context.Context, c cache.Cache, key string, d *HTTPDocument,
 		})
 	}
 
-	if compress {
-		tl.Debug(rsc.Logger, "compressing cache data", tl.Pairs{"cacheKey": key})
-		buf := bytes.NewBuffer([]byte{1})
-		encoder := brotli.NewWriter(buf)
-		encoder.Write(b)
-		encoder.Close()
-		b = buf.Bytes()
-	} else {
-		b = append([]byte{0}, b...)
+	b, err = cacheEnvelope(c).Seal(b, compress)
+	if err != nil {
+		tl.Error(rsc.Logger, "error encoding cache document", tl.Pairs{
+			"cacheKey": key,
+			"detail":   err.Error(),
+		})
+		return err
 	}
 
 	err = c.Store(key, b, ttl)
@@ -29,6 +29,8 @@ import (
 	"time"
 
 	"github.com/trickstercache/trickster/v2/cmd/trickster/config"
+	"github.com/trickstercache/trickster/v2/pkg/cache"
+	"github.com/trickstercache/trickster/v2/pkg/cache/envelope"
 	co "github.com/trickstercache/trickster/v2/pkg/cache/options"
 	"github.com/trickstercache/trickster/v2/pkg/cache/registration"
 	cr "github.com/trickstercache/trickster/v2/pkg/cache/registration"
@@ -432,6 +434,53 @@ func TestQueryCache(t *testing.T) {
 	}
 }
 
+func TestQueryCacheCorrupt(t *testing.T) {
+	conf, _, err := config.Load("trickster", "test", []string{"-origin-url", "http://1", "-provider", "test"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+	caches := registration.LoadCachesFromConfig(conf, testLogger)
+	defer registration.CloseCaches(caches)
+	c := caches["default"]
+	// make the cache not appear to be a memory cache, so documents are decoded
+	c.Configuration().Provider = "test"
+
+	ctx := tc.WithResources(context.Background(), &request.Resources{
+		BackendOptions: conf.Backends["default"], Tracer: tu.NewTestTracer(), Logger: testLogger})
+
+	sealed, _ := envelope.Default.Seal([]byte("document"), true)
+	for _, b := range [][]byte{sealed[:len(sealed)-1], sealed} {
+		c.Store("testKey", b, time.Minute)
+		_, ls, _, err := QueryCache(ctx, c, "testKey", nil)
+		if err != cache.ErrKNF || ls != status.LookupStatusKeyMiss {
+			t.Errorf("expected %v %s got %v %s", cache.ErrKNF, status.LookupStatusKeyMiss, err, ls)
+		}
+		// the corrupt document should be removed
+		deadline := time.Now().Add(time.Second)
+		for time.Now().Before(deadline) {
+			if _, ls, _ = c.Retrieve("testKey", false); ls == status.LookupStatusKeyMiss {
+				break
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		if ls != status.LookupStatusKeyMiss {
+			t.Errorf("expected %s got %s", status.LookupStatusKeyMiss, ls)
+		}
+	}
+
+	// a document written by a newer version is a miss, but is not removed
+	newer := append([]byte{sealed[0], envelope.FormatVersion + 1}, sealed[2:]...)
+	c.Store("testKey", newer, time.Minute)
+	_, ls, _, err := QueryCache(ctx, c, "testKey", nil)
+	if err != cache.ErrKNF || ls != status.LookupStatusKeyMiss {
+		t.Errorf("expected %v %s got %v %s", cache.ErrKNF, status.LookupStatusKeyMiss, err, ls)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, ls, _ = c.Retrieve("testKey", false); ls != status.LookupStatusHit {
+		t.Errorf("expected %s got %s", status.LookupStatusHit, ls)
+	}
+}
+
 // Mock Cache for testing error conditions
 type testCache struct {
 	configuration *co.Options
@@ -21,6 +21,7 @@ import (
 	"context"
 	"io"
 	"net/http"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -28,7 +29,9 @@ import (
 	"github.com/trickstercache/trickster/v2/pkg/backends"
 	tc "github.com/trickstercache/trickster/v2/pkg/cache"
 	"github.com/trickstercache/trickster/v2/pkg/cache/evictionmethods"
+	cm "github.com/trickstercache/trickster/v2/pkg/cache/metrics"
 	"github.com/trickstercache/trickster/v2/pkg/cache/status"
+	"github.com/trickstercache/trickster/v2/pkg/cache/warningpolicies"
 	"github.com/trickstercache/trickster/v2/pkg/encoding/profile"
 	"github.com/trickstercache/trickster/v2/pkg/encoding/providers"
 	"github.com/trickstercache/trickster/v2/pkg/locks"
@@ -111,6 +114,22 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request, modeler *tim
 
 	client.SetExtent(pr.upstreamRequest, trq, &trq.Extent)
 	key := o.CacheKeyPrefix + ".dpc." + pr.DeriveCacheKey("")
+	rsc.CacheKey = key
+	if inspectCache(r, "DeltaProxyCache", cache, key, modeler, trq) {
+		return
+	}
+
+	coReq := GetRequestCachingPolicy(r.Header)
+	if len(o.NegativeRules) > 0 && !coReq.NoCache {
+		if d := queryDPCNegativeCache(ctx, cache, key); d != nil {
+			h := d.SafeHeaderClone()
+			recordDPCResult(r, status.LookupStatusNegativeCacheHit, d.StatusCode,
+				r.URL.Path, "", 0, nil, h)
+			Respond(w, d.StatusCode, h, bytes.NewReader(d.Body))
+			return
+		}
+	}
+
 	pr.cacheLock, _ = locker.RAcquire(key)
 
 	// this is used to determine if Fast Forward should be activated for this request
@@ -124,7 +143,6 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request, modeler *tim
 	var doc *HTTPDocument
 	var elapsed time.Duration
 
-	coReq := GetRequestCachingPolicy(r.Header)
 checkCache:
 	if coReq.NoCache {
 		if span != nil {
@@ -147,6 +165,7 @@ checkCache:
 			cts, doc, elapsed, err = fetchTimeseries(pr, trq, client, modeler)
 			if err != nil {
 				pr.cacheLock.RRelease()
+				pr.cacheDPCNegativeResponse(ctx, cache, key, doc)
 				h := doc.SafeHeaderClone()
 				recordDPCResult(r, status.LookupStatusProxyError, doc.StatusCode,
 					r.URL.Path, "", elapsed.Seconds(), nil, h)
@@ -158,7 +177,9 @@ checkCache:
 			if doc == nil {
 				err = tpe.ErrEmptyDocumentBody
 			} else {
-				if cc.Provider == "memory" {
+				// documents restored from a memory cache snapshot or a tiered cache's
+				// L2 hold the serialized timeseries in the body until they are next written
+				if storesReferences(cc) && doc.timeseries != nil {
 					cts = doc.timeseries
 				} else {
 					cts, err = modeler.CacheUnmarshaler(doc.Body, trq)
@@ -167,10 +188,14 @@ checkCache:
 			if err != nil {
 				tl.Error(pr.Logger, "cache object unmarshaling failed",
 					tl.Pairs{"key": key, "backendName": client.Name(), "detail": err.Error()})
+				if doc != nil {
+					cm.ObserveCacheCorruption(cc.Name, cc.Provider, "read")
+				}
 				go cache.Remove(key)
 				cts, doc, elapsed, err = fetchTimeseries(pr, trq, client, modeler)
 				if err != nil {
 					pr.cacheLock.RRelease()
+					pr.cacheDPCNegativeResponse(ctx, cache, key, doc)
 					h := doc.SafeHeaderClone()
 					recordDPCResult(r, status.LookupStatusProxyError, doc.StatusCode,
 						r.URL.Path, "", elapsed.Seconds(), nil, h)
@@ -202,13 +227,22 @@ checkCache:
 	if cts != nil {
 		vr = cts.VolatileExtents()
 	}
+	// under the volatile warning policy, a cached timeseries carrying warnings
+	// has warning-bearing data in its volatile ranges, which are always refetched
+	var cachedWarnings []string
+	var keepCachedWarnings bool
+	if cacheStatus == status.LookupStatusPartialHit &&
+		o.TimeseriesWarningPolicy == warningpolicies.WarningPolicyVolatile {
+		cachedWarnings = slices.Clone(timeseries.Warnings(cts))
+		keepCachedWarnings = len(cachedWarnings) > 0 && extentsOutside(vr, trq.Extent)
+	}
 	if cacheStatus == status.LookupStatusPartialHit {
 		missRanges = cts.Extents().CalculateDeltas(trq.Extent, trq.Step)
 		// this is the backfill part of backfill tolerance. if there are any volatile
 		// ranges in the timeseries, this determines if any fall within the client's
 		// requested range and ensures they are re-requested. this only happens if
-		// the request is already a phit
-		if bt > 0 && len(missRanges) > 0 && len(vr) > 0 {
+		// the request is already a phit, or if the cached timeseries carries warnings
+		if (bt > 0 && len(missRanges) > 0 || len(cachedWarnings) > 0) && len(vr) > 0 {
 			// this checks the timeseries's volatile ranges for any overlap with
 			// the request extent, and adds those to the missRanges to refresh
 			if cvr = vr.Crop(trq.Extent); len(cvr) > 0 {
@@ -243,12 +277,17 @@ checkCache:
 		// acquire a write lock via the Upgrade method, which will swap the read lock for a
 		// write lock, and return true if this client was the only one, or otherwise the first
 		// client in a concurrent read lock group to request an Upgrade.
-		wasFirst := pr.cacheLock.Upgrade()
+		// when the write lock wasn't acquired (e.g., a distributed lock timed out), the
+		// request proceeds without rerunning, since a rerun would only wait again.
+		wasFirst, err := pr.cacheLock.Upgrade()
+		if err != nil {
+			logLockUpgradeError(pr, key, err)
+		}
 
 		// if this request was first, it is good to proceed with upstream communications and caching.
 		// when another requests was first to acquire the mutex, we will jump up to checkCache
 		// to get the refreshed version. after 3 reiterations, we'll proceed anyway to avoid long loops.
-		if !wasFirst && pr.rerunCount < 3 {
+		if !wasFirst && err == nil && pr.rerunCount < 3 {
 			// we weren't first, so quickly drop our write lock, and re-run the request
 			pr.cacheLock.Release()
 			pr.cacheLock, _ = locker.RAcquire(key)
@@ -348,23 +387,78 @@ checkCache:
 	wg.Wait()
 
 	if ferr != nil {
+		var b []byte
+		if mresp.Body != nil {
+			b, _ = io.ReadAll(mresp.Body)
+		}
+		nr := pr.cacheDPCNegativeResponse(ctx, cache, key, &HTTPDocument{
+			Status: mresp.Status, StatusCode: mresp.StatusCode,
+			Headers: mresp.Header, Body: b,
+		})
 		if writeLock != nil {
 			writeLock.Release()
+			writeLock = nil
+		}
+		// when the failure matched a serve_last_good negative cache rule, the
+		// backend's circuit is open and configured to serve stale content, or the
+		// backend serves stale content on error and the upstream was unreachable or
+		// responded with a 5xx, and the cached timeseries covers part of the request,
+		// serve it without the delta. a network error is made into a 502 by the fetch
+		staleOnError := o.ServeStaleOnError &&
+			mresp.StatusCode >= http.StatusInternalServerError
+		serveCached := nr.IsServeLastGood() || o.Breaker.ServesStale() || staleOnError
+		if !serveCached || cts == nil || len(cts.Extents().Crop(trq.Extent)) == 0 {
+			Respond(w, mresp.StatusCode, mresp.Header, bytes.NewReader(b))
+			return
+		}
+		mts = nil
+		uncachedValueCount = 0
+		dpStatus["servedLastGood"] = true
+		if staleOnError {
+			cacheStatus = status.LookupStatusDegraded
+			dpStatus["degraded"] = true
 		}
-		Respond(w, mresp.StatusCode, mresp.Header, mresp.Body)
-		return
+	}
+
+	// separate any deltas whose upstream responses carried warnings (e.g., partial
+	// results), so they are handled according to the backend's warning policy
+	var warned []timeseries.Timeseries
+	var warnings []string
+	var warnedExtents timeseries.ExtentList
+	if cacheStatus == status.LookupStatusKeyMiss {
+		if warnings = timeseries.Warnings(cts); len(warnings) > 0 {
+			warnedExtents = cts.Extents()
+		}
+	} else if len(mts) > 0 {
+		var clean []timeseries.Timeseries
+		clean, warned, warnings = splitWarnedTimeseries(mts)
+		for _, ts := range warned {
+			warnedExtents = append(warnedExtents, ts.Extents()...)
+		}
+		if o.TimeseriesWarningPolicy == warningpolicies.WarningPolicySkip {
+			mts = clean
+		} else {
+			warned = nil
+		}
+	}
+	if len(warnings) > 0 {
+		dpStatus["warnings"] = len(warnings)
 	}
 
 	// Merge the new delta timeseries into the cached timeseries
-	if len(mts) > 0 {
+	if len(mts) > 0 || len(warned) > 0 {
 		// on phit, elapsed records the time spent waiting for all upstream requests to complete
 		elapsed = time.Since(now)
-		cts.Merge(true, mts...)
+		if len(mts) > 0 {
+			cts.Merge(true, mts...)
+		}
 	}
 
 	// this handles the tolerance part of backfill tolerance, by adding new tolerable ranges to
-	// the timeseries's volatile list, and removing those that no longer tolerate backfill
-	if bt > 0 && cacheStatus != status.LookupStatusHit {
+	// the timeseries's volatile list, and removing those that no longer tolerate backfill.
+	// warning-bearing ranges are also added here under the volatile warning policy
+	if cacheStatus != status.LookupStatusHit && cacheStatus != status.LookupStatusDegraded &&
+		(bt > 0 || len(cvr) > 0 || len(warnedExtents) > 0) {
 
 		var shouldCompress bool
 		ve := cts.VolatileExtents()
@@ -379,7 +473,7 @@ checkCache:
 
 		// now add in any new time ranges that should tolerate backfill
 		var adds timeseries.Extent
-		if trq.Extent.End.After(bfs) {
+		if bt > 0 && trq.Extent.End.After(bfs) {
 			adds.End = trq.Extent.End
 			if trq.Extent.Start.Before(bfs) {
 				adds.Start = bfs
@@ -392,6 +486,13 @@ checkCache:
 			shouldCompress = true
 		}
 
+		// and any ranges just fetched with warnings, so they are refetched next time
+		if len(warnedExtents) > 0 &&
+			o.TimeseriesWarningPolicy == warningpolicies.WarningPolicyVolatile {
+			ve = append(ve, warnedExtents...)
+			shouldCompress = true
+		}
+
 		// if any changes happened to the volatile list, set it in the cached timeseries
 		if shouldCompress {
 			cts.SetVolatileExtents(ve.Compress(trq.Step))
@@ -406,6 +507,31 @@ checkCache:
 	} else {
 		rts = cts.Clone()
 	}
+	if len(warned) > 0 {
+		rts.Merge(true, warned...)
+	}
+	// the client receives only the warnings from this round's upstream responses
+	timeseries.SetWarnings(rts, slices.Clone(warnings))
+
+	// the cached timeseries only retains warnings under the volatile policy, where
+	// they mark that its volatile list includes warning-bearing ranges
+	if writeLock != nil {
+		switch o.TimeseriesWarningPolicy {
+		case warningpolicies.WarningPolicyVolatile:
+			if keepCachedWarnings {
+				warnings = timeseries.MergeWarnings(cachedWarnings, warnings)
+			}
+			timeseries.SetWarnings(cts, warnings)
+		case warningpolicies.WarningPolicySkip:
+			timeseries.SetWarnings(cts, nil)
+			if cacheStatus == status.LookupStatusKeyMiss && len(warnings) > 0 {
+				writeLock.Release()
+				writeLock = nil
+			}
+		default:
+			timeseries.SetWarnings(cts, nil)
+		}
+	}
 
 	if writeLock != nil {
 		// if the mutex is still locked, it means we need to write the time series to cache
@@ -422,8 +548,9 @@ checkCache:
 			// Don't cache datasets with empty extents
 			// (everything was cropped so there is nothing to cache)
 			if len(cts.Extents()) > 0 {
-				if cc.Provider == "memory" {
+				if storesReferences(cc) {
 					doc.timeseries = cts
+					doc.cacheMarshaler = modeler.CacheMarshaler
 				} else {
 					cdata, err := modeler.CacheMarshaler(cts, nil, 0)
 					if err != nil {
@@ -435,6 +562,9 @@ checkCache:
 					}
 					doc.Body = cdata
 				}
+				if writeLockLost(pr, key, writeLock) {
+					return
+				}
 				if err := WriteCache(ctx, cache, key, doc, o.TimeseriesTTL, o.CompressibleTypes); err != nil {
 					tl.Error(pr.Logger, "error writing object to cache",
 						tl.Pairs{
@@ -473,6 +603,9 @@ checkCache:
 	// rts.SetTimeRangeQuery(&timeseries.TimeRangeQuery{})
 	rh := doc.SafeHeaderClone()
 	sc := doc.StatusCode
+	if cacheStatus == status.LookupStatusDegraded {
+		rh.Set(headers.NameWarning, headers.ValueWarningStale)
+	}
 
 	// Respond to the user. Using the response headers from a Delta Response,
 	// so as to not map conflict with cacheData on WriteCache
@@ -545,6 +678,9 @@ func fetchTimeseries(pr *proxyRequest, trq *timeseries.TimeRangeQuery,
 	}
 
 	if err != nil {
+		if resp.Body != nil {
+			d.Body, _ = io.ReadAll(resp.Body)
+		}
 		return nil, d, time.Duration(0), err
 	}
 
@@ -623,7 +759,13 @@ func fetchExtents(el timeseries.ExtentList, rsc *request.Resources, h http.Heade
 			}
 			respLock.Unlock()
 
-			if resp.StatusCode == http.StatusOK && len(body) > 0 {
+			// a successful response that matches a negative cache rule (e.g., a 200 OK
+			// with an error in the body) is handled as an unexpected upstream response
+			isNegative := resp.StatusCode == http.StatusOK &&
+				matchNegativeRule(rsc.BackendOptions.NegativeRules, resp.StatusCode,
+					resp.Header, body) != nil
+
+			if resp.StatusCode == http.StatusOK && len(body) > 0 && !isNegative {
 				nts, ferr := wur(getDecoderReader(resp), rsc.TimeRangeQuery)
 				if ferr != nil {
 					tl.Error(pr.Logger, "proxy object unmarshaling failed",
@@ -642,8 +784,10 @@ func fetchExtents(el timeseries.ExtentList, rsc *request.Resources, h http.Heade
 				headers.Merge(h, resp.Header)
 				mts = append(mts, nts)
 				appendLock.Unlock()
-			} else if resp.StatusCode != 200 {
+			} else if resp.StatusCode != 200 || isNegative {
+				appendLock.Lock()
 				err = tpe.ErrUnexpectedUpstreamResponse
+				appendLock.Unlock()
 				var b []byte
 				var s string
 				if resp.Body != nil {
@@ -656,6 +800,10 @@ func fetchExtents(el timeseries.ExtentList, rsc *request.Resources, h http.Heade
 				if len(s) > 128 {
 					s = s[:128]
 				}
+				// open circuits are already logged when failing fast
+				if resp.Header.Get(headers.NameTrkCircuitBreaker) != "" {
+					return
+				}
 				tl.Error(pr.Logger, "unexpected upstream response",
 					tl.Pairs{
 						"statusCode":              resp.StatusCode,
@@ -26,6 +26,14 @@ import (
 
 	mockprom "github.com/trickstercache/mockster/pkg/mocks/prometheus"
 	"github.com/trickstercache/trickster/v2/pkg/backends"
+	"github.com/trickstercache/trickster/v2/pkg/cache"
+	"github.com/trickstercache/trickster/v2/pkg/cache/memory"
+	co "github.com/trickstercache/trickster/v2/pkg/cache/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache/tiered"
+	to "github.com/trickstercache/trickster/v2/pkg/cache/tiered/options"
+	"github.com/trickstercache/trickster/v2/pkg/locks"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/circuitbreaker"
+	cbo "github.com/trickstercache/trickster/v2/pkg/proxy/circuitbreaker/options"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/request"
 	tu "github.com/trickstercache/trickster/v2/pkg/testutil"
@@ -152,6 +160,87 @@ func TestDeltaProxyCacheRequestMissThenHit(t *testing.T) {
 	}
 }
 
+var _ cache.MemoryCache = (*tiered.Cache)(nil)
+
+func newTestTier(t *testing.T, name string) *memory.Cache {
+	c := &memory.Cache{Name: name, Config: co.New()}
+	c.SetLocker(locks.NewNamedLocker())
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestDeltaProxyCacheRequestTiered(t *testing.T) {
+	ts, w, r, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+
+	// a tiered cache with a memory L1 stores documents by reference in L1, and
+	// serialized in L2
+	l1, l2 := newTestTier(t, "test.l1"), newTestTier(t, "test.l2")
+	cfg := co.New()
+	cfg.Provider = "tiered"
+	cfg.Tiered = to.New()
+	cfg.L1Options, cfg.L2Options = l1.Config, l2.Config
+	cfg.L1Options.Provider = "memory"
+	tc := &tiered.Cache{Name: "test", Config: cfg, L1: l1, L2: l2}
+	tc.SetLocker(locks.NewNamedLocker())
+	if err = tc.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer tc.Close()
+	rsc.CacheClient, rsc.CacheConfig = tc, cfg
+
+	client := rsc.BackendClient.(*TestClient)
+	rsc.BackendOptions.FastForwardDisable = true
+	step := time.Duration(300) * time.Second
+	end := time.Now().Add(-time.Duration(12) * time.Hour)
+	extr := timeseries.Extent{Start: end.Add(-time.Duration(18) * time.Hour), End: end}
+	extn := timeseries.Extent{Start: extr.Start.Truncate(step), End: extr.End.Truncate(step)}
+	u := r.URL
+	u.Path = "/prometheus/api/v1/query_range"
+	u.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s",
+		int(step.Seconds()), extn.Start.Unix(), extn.End.Unix(), queryReturnsOKNoLatency)
+
+	// cached responses must match the response of the initial miss
+	var expected string
+	request := func(status string) {
+		t.Helper()
+		w = httptest.NewRecorder()
+		client.QueryRangeHandler(w, r)
+		resp := w.Result()
+		b, _ := io.ReadAll(resp.Body)
+		if expected == "" {
+			expected = string(b)
+		} else if err := testStringMatch(string(b), expected); err != nil {
+			t.Error(err)
+		}
+		if err := testResultHeaderPartMatch(resp.Header,
+			map[string]string{"status": status}); err != nil {
+			t.Error(err)
+		}
+	}
+
+	request("kmiss")
+	time.Sleep(time.Millisecond * 10)
+	if l1.Index.Count() != 1 || l2.Index.Count() != 1 {
+		t.Fatalf("expected the document in both tiers, got %d and %d",
+			l1.Index.Count(), l2.Index.Count())
+	}
+	request("hit")
+
+	// another instance sharing L2 reads the serialized document and promotes it into L1
+	l1b := newTestTier(t, "test.l1b")
+	tc.L1 = l1b
+	request("hit")
+	if l1b.Index.Count() != 1 {
+		t.Error("expected the document to be promoted into L1")
+	}
+}
+
 func TestDeltaProxyCacheRequestRemoveStale(t *testing.T) {
 	ts, w, r, rsc, err := setupTestHarnessDPC()
 	if err != nil {
@@ -1596,3 +1685,152 @@ func TestDeltaProxyCacheRequestShardByPoints(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestDeltaProxyCacheRequestCircuitOpen(t *testing.T) {
+	ts, w, r, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	client := rsc.BackendClient.(*TestClient)
+	o := rsc.BackendOptions
+	rsc.CacheConfig.Provider = "test"
+	o.FastForwardDisable = true
+	cbOpts := cbo.New()
+	cbOpts.MinRequests = 1
+	cbOpts.OpenAction = cbo.OpenActionServeStale
+	o.Breaker = circuitbreaker.New(cbOpts)
+	client.RangeCacheKey = "test-range-key-circuit"
+	client.InstantCacheKey = "test-instant-key-circuit"
+
+	step := time.Duration(300) * time.Second
+	end := time.Now().Add(-time.Duration(12) * time.Hour)
+	extr := timeseries.Extent{Start: end.Add(-time.Duration(18) * time.Hour), End: end}
+
+	u := r.URL
+	u.Path = "/prometheus/api/v1/query_range"
+	u.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s&rk=%s&ik=%s", int(step.Seconds()),
+		extr.Start.Unix(), extr.End.Unix(), queryReturnsOKNoLatency, client.RangeCacheKey, client.InstantCacheKey)
+
+	client.QueryRangeHandler(w, r)
+	resp := w.Result()
+	if err = testStatusCodeMatch(resp.StatusCode, http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	expected, _ := io.ReadAll(resp.Body)
+
+	// open the circuit and extend the range; the delta is not fetched, and the
+	// cached portion of the timeseries is served in its place
+	o.Breaker.Record(true, 0)
+	extr.End = extr.End.Add(time.Duration(1) * time.Hour)
+	u.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s&rk=%s&ik=%s", int(step.Seconds()),
+		extr.Start.Unix(), extr.End.Unix(), queryReturnsOKNoLatency, client.RangeCacheKey, client.InstantCacheKey)
+	r.URL = u
+
+	time.Sleep(time.Millisecond * 10)
+
+	w = httptest.NewRecorder()
+	client.QueryRangeHandler(w, r)
+	resp = w.Result()
+	if err = testStatusCodeMatch(resp.StatusCode, http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err = testResultHeaderPartMatch(resp.Header, map[string]string{"status": "phit"}); err != nil {
+		t.Error(err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	if err = testStringMatch(string(b), string(expected)); err != nil {
+		t.Error(err)
+	}
+
+	// without serve_stale, the request fails fast
+	cbOpts.OpenAction = cbo.OpenActionFail
+	w = httptest.NewRecorder()
+	client.QueryRangeHandler(w, r)
+	if err = testStatusCodeMatch(w.Result().StatusCode, http.StatusServiceUnavailable); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDeltaProxyCacheRequestServeStaleOnError(t *testing.T) {
+	ts, w, r, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	client := rsc.BackendClient.(*TestClient)
+	o := rsc.BackendOptions
+	rsc.CacheConfig.Provider = "test"
+	o.FastForwardDisable = true
+	o.ServeStaleOnError = true
+	client.RangeCacheKey = "test-range-key-degraded"
+	client.InstantCacheKey = "test-instant-key-degraded"
+
+	step := time.Duration(300) * time.Second
+	end := time.Now().Add(-time.Duration(12) * time.Hour)
+	extr := timeseries.Extent{Start: end.Add(-time.Duration(18) * time.Hour), End: end}
+
+	u := r.URL
+	u.Path = "/prometheus/api/v1/query_range"
+	u.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s&rk=%s&ik=%s", int(step.Seconds()),
+		extr.Start.Unix(), extr.End.Unix(), queryReturnsOKNoLatency, client.RangeCacheKey, client.InstantCacheKey)
+
+	client.QueryRangeHandler(w, r)
+	resp := w.Result()
+	if err = testStatusCodeMatch(resp.StatusCode, http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	expected, _ := io.ReadAll(resp.Body)
+
+	// extend the range so the delta is fetched with a query that fails upstream;
+	// the cached portion of the timeseries is served as a degraded response
+	extr.End = extr.End.Add(time.Duration(1) * time.Hour)
+	u.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s&rk=%s&ik=%s", int(step.Seconds()),
+		extr.Start.Unix(), extr.End.Unix(), queryReturnsBadGateway, client.RangeCacheKey, client.InstantCacheKey)
+	r.URL = u
+
+	time.Sleep(time.Millisecond * 10)
+
+	w = httptest.NewRecorder()
+	client.QueryRangeHandler(w, r)
+	resp = w.Result()
+	if err = testStatusCodeMatch(resp.StatusCode, http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err = testResultHeaderPartMatch(resp.Header, map[string]string{"status": "degraded"}); err != nil {
+		t.Error(err)
+	}
+	if v := resp.Header.Get(headers.NameWarning); v != headers.ValueWarningStale {
+		t.Errorf("expected %s got %s", headers.ValueWarningStale, v)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	if err = testStringMatch(string(b), string(expected)); err != nil {
+		t.Error(err)
+	}
+
+	// without serve_stale_on_error, the upstream error is returned
+	o.ServeStaleOnError = false
+	w = httptest.NewRecorder()
+	client.QueryRangeHandler(w, r)
+	resp = w.Result()
+	if err = testStatusCodeMatch(resp.StatusCode, http.StatusBadGateway); err != nil {
+		t.Error(err)
+	}
+
+	// a 4xx delta is returned to the client, even with serve_stale_on_error
+	o.ServeStaleOnError = true
+	u.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s&rk=%s&ik=%s", int(step.Seconds()),
+		extr.Start.Unix(), extr.End.Unix(), queryReturnsBadRequest, client.RangeCacheKey, client.InstantCacheKey)
+	r.URL = u
+	w = httptest.NewRecorder()
+	client.QueryRangeHandler(w, r)
+	resp = w.Result()
+	if err = testStatusCodeMatch(resp.StatusCode, http.StatusBadRequest); err != nil {
+		t.Error(err)
+	}
+	if v := resp.Header.Get(headers.NameWarning); v != "" {
+		t.Errorf("expected no warning header got %s", v)
+	}
+}
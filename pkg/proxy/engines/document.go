@@ -53,7 +53,17 @@ type HTTPDocument struct {
 	isFulfillment    bool
 	isLoaded         bool
 	timeseries       timeseries.Timeseries
-	headerLock       sync.Mutex
+	// cacheMarshaler serializes the timeseries when the document is snapshotted
+	cacheMarshaler timeseries.MarshalerFunc
+	// compressible is true when the document is compressed once serialized
+	compressible bool
+	headerLock   sync.Mutex
+}
+
+// Compressible returns true if the document stored by reference should be compressed
+// when it is serialized for a tiered cache's L2
+func (d *HTTPDocument) Compressible() bool {
+	return d.compressible
 }
 
 // SafeHeaderClone returns a threadsafe copy of the Document Header
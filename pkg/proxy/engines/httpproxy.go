@@ -18,6 +18,7 @@ package engines
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"math"
 	"net/http"
@@ -26,17 +27,22 @@ import (
 	"sync"
 	"time"
 
+	"github.com/trickstercache/trickster/v2/pkg/cache/inspect"
 	"github.com/trickstercache/trickster/v2/pkg/cache/status"
 	"github.com/trickstercache/trickster/v2/pkg/encoding/profile"
 	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
 	"github.com/trickstercache/trickster/v2/pkg/observability/metrics"
 	"github.com/trickstercache/trickster/v2/pkg/observability/tracing"
 	tspan "github.com/trickstercache/trickster/v2/pkg/observability/tracing/span"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/circuitbreaker"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/faults"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/forwarding"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/methods"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/params"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/request"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/retry"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/warmer"
 	"github.com/trickstercache/trickster/v2/pkg/timeseries"
 
 	othttptrace "go.opentelemetry.io/contrib/instrumentation/net/http/httptrace/otelhttptrace"
@@ -55,6 +61,9 @@ const ClockOffsetWarning = "clock offset between trickster host and origin is hi
 
 // DoProxy proxies an inbound request to its corresponding upstream origin with no caching features
 func DoProxy(w io.Writer, r *http.Request, closeResponse bool) *http.Response {
+	if inspect.FromRequest(r) != nil {
+		return inspectionResponse(r)
+	}
 	rsc := request.GetResources(r)
 	o := rsc.BackendOptions
 
@@ -152,6 +161,9 @@ func PrepareResponseWriter(w io.Writer, code int, header http.Header) io.Writer
 // provide the response data, the response object and the content length.
 // Used in Fetch.
 func PrepareFetchReader(r *http.Request) (io.ReadCloser, *http.Response, int64) {
+	if inspect.FromRequest(r) != nil {
+		return http.NoBody, inspectionResponse(r), 0
+	}
 	rsc := request.GetResources(r)
 
 	ep := profile.FromContext(r.Context())
@@ -198,10 +210,20 @@ func PrepareFetchReader(r *http.Request) (io.ReadCloser, *http.Response, int64)
 	// clear the Host header before proxying or it will be forwarded upstream
 	r.Host = ""
 
-	resp, err := o.HTTPClient.Do(r)
+	resp, err := doUpstream(r, rsc, doSpan)
 	if err != nil {
-		tl.Error(rsc.Logger,
-			"error downloading url", tl.Pairs{"url": r.URL.String(), "detail": err.Error()})
+		if errors.Is(err, circuitbreaker.ErrOpen) {
+			// the upstream was not contacted, so fail fast with a 503
+			tl.Debug(rsc.Logger, "circuit breaker is open",
+				tl.Pairs{"backendName": o.Name, "url": r.URL.String()})
+			resp = &http.Response{
+				StatusCode: http.StatusServiceUnavailable,
+				Request:    r, Header: http.Header{headers.NameTrkCircuitBreaker: {"open"}},
+			}
+		} else {
+			tl.Error(rsc.Logger,
+				"error downloading url", tl.Pairs{"url": r.URL.String(), "detail": err.Error()})
+		}
 		// if there is an err and the response is nil, the server could not be reached
 		// so make a 502 for the downstream response
 		if resp == nil {
@@ -281,6 +303,78 @@ func PrepareFetchReader(r *http.Request) (io.ReadCloser, *http.Response, int64)
 	return rc, resp, originalLen
 }
 
+// doUpstream sends the request to the origin unless the Backend's circuit
+// breaker is open, in which case circuitbreaker.ErrOpen is returned. The outcome
+// of the request is recorded by the circuit breaker, unless the request's context
+// was canceled, which says nothing about the origin's health
+func doUpstream(r *http.Request, rsc *request.Resources, span trace.Span) (*http.Response, error) {
+	cb := rsc.BackendOptions.Breaker
+	if cb == nil {
+		return sendUpstream(r, rsc, span)
+	}
+	if !cb.Allow() {
+		return nil, circuitbreaker.ErrOpen
+	}
+	start := time.Now()
+	resp, err := sendUpstream(r, rsc, span)
+	if r.Context().Err() != nil {
+		cb.Release()
+		return resp, err
+	}
+	cb.Record(err != nil || resp.StatusCode >= http.StatusInternalServerError,
+		time.Since(start))
+	return resp, err
+}
+
+// sendUpstream sends the request to the origin, retrying or hedging it according
+// to the Backend's retry policy. Each additional attempt is recorded as an event
+// on the span, and in the ProxyRequestStatus metric as a "retry" or "hedge".
+// Any upstream delay injected by fault injection is applied first
+func sendUpstream(r *http.Request, rsc *request.Resources, span trace.Span) (*http.Response, error) {
+	o := rsc.BackendOptions
+	if err := faults.DelayUpstream(r, o.Name); err != nil {
+		return nil, err
+	}
+	if o.RetryPolicy == nil {
+		return o.HTTPClient.Do(r)
+	}
+	attempts := 1
+	path := r.URL.Path
+	resp, err := o.RetryPolicy.Do(o.HTTPClient, r, func(a retry.Attempt) {
+		attempts = a.Number
+		event, code := "Retry", a.StatusCode
+		if a.Hedged {
+			event = "Hedge"
+		} else if a.Err != nil {
+			code = http.StatusBadGateway
+		}
+		if span != nil {
+			attrs := []attribute.KeyValue{
+				attribute.Int("attempt", a.Number),
+				attribute.Int64("delayMS", a.Delay.Milliseconds()),
+			}
+			if code > 0 {
+				attrs = append(attrs, attribute.Int("httpStatus", code))
+			}
+			if a.Err != nil {
+				attrs = append(attrs, attribute.String("error", a.Err.Error()))
+			}
+			span.AddEvent(event, trace.EventOption(trace.WithAttributes(attrs...)))
+		}
+		if pc := rsc.PathConfig; pc == nil || !pc.NoMetrics {
+			metrics.ProxyRequestStatus.WithLabelValues(o.Name, o.Provider, r.Method,
+				strings.ToLower(event), strconv.Itoa(code), path).Inc()
+		}
+		tl.Debug(rsc.Logger, "upstream request attempt",
+			tl.Pairs{"backendName": o.Name, "attempt": a.Number, "hedged": a.Hedged,
+				"httpStatus": code, "delay": a.Delay.String()})
+	})
+	if span != nil && attempts > 1 {
+		span.SetAttributes(attribute.Int("attempts", attempts))
+	}
+	return resp, err
+}
+
 // Respond sends an HTTP Response down to the requesting client
 func Respond(w io.Writer, code int, header http.Header, body io.Reader) {
 	PrepareResponseWriter(w, code, header)
@@ -318,4 +412,5 @@ func recordResults(r *http.Request, engine string, cacheStatus status.LookupStat
 		}
 	}
 	headers.SetResultsHeader(header, engine, status, ffStatus, extents)
+	warmer.Observe(r, rsc, cacheStatus)
 }
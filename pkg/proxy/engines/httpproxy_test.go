@@ -18,19 +18,25 @@ package engines
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/trickstercache/trickster/v2/cmd/trickster/config"
 	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/circuitbreaker"
+	cbo "github.com/trickstercache/trickster/v2/pkg/proxy/circuitbreaker/options"
 	tc "github.com/trickstercache/trickster/v2/pkg/proxy/context"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/forwarding"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
 	po "github.com/trickstercache/trickster/v2/pkg/proxy/paths/options"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/request"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/retry"
+	rto "github.com/trickstercache/trickster/v2/pkg/proxy/retry/options"
 	tu "github.com/trickstercache/trickster/v2/pkg/testutil"
 )
 
@@ -87,6 +93,50 @@ func TestDoProxy(t *testing.T) {
 	}
 }
 
+func TestDoProxyRetry(t *testing.T) {
+	var count int32
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&count, 1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("test"))
+	}))
+	defer es.Close()
+
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-origin-url", es.URL, "-provider", "test", "-log-level", "debug"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+
+	o := conf.Backends["default"]
+	o.Retry = rto.New()
+	o.Retry.BackoffMS = 1
+	o.RetryPolicy = retry.New(o.Retry)
+	o.HTTPClient = http.DefaultClient
+	pc := &po.Options{Path: "/"}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", es.URL, nil)
+	r = r.WithContext(tc.WithResources(r.Context(),
+		request.NewResources(o, pc, nil, nil, nil, tu.NewTestTracer(), testLogger)))
+
+	DoProxy(w, r, true)
+	resp := w.Result()
+
+	if err = testStatusCodeMatch(resp.StatusCode, http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	bodyBytes, _ := io.ReadAll(resp.Body)
+	if err = testStringMatch(string(bodyBytes), "test"); err != nil {
+		t.Error(err)
+	}
+	if count != 2 {
+		t.Errorf("expected %d upstream requests got %d", 2, count)
+	}
+}
+
 func TestProxyRequestBadGateway(t *testing.T) {
 	const badUpstream = "http://127.0.0.1:64389"
 
@@ -301,3 +351,58 @@ func TestPrepareFetchReaderErr(t *testing.T) {
 		t.Errorf("expected 0 got %d", i)
 	}
 }
+
+func TestDoProxyCircuitBreaker(t *testing.T) {
+	var count int32
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer es.Close()
+
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-origin-url", es.URL, "-provider", "test", "-log-level", "debug"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+
+	o := conf.Backends["default"]
+	o.CircuitBreaker = cbo.New()
+	o.CircuitBreaker.MinRequests = 2
+	o.Breaker = circuitbreaker.New(o.CircuitBreaker)
+	o.HTTPClient = http.DefaultClient
+	pc := &po.Options{Path: "/"}
+
+	expected := []int{http.StatusInternalServerError, http.StatusInternalServerError,
+		http.StatusServiceUnavailable}
+	for _, code := range expected {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", es.URL, nil)
+		r = r.WithContext(tc.WithResources(r.Context(),
+			request.NewResources(o, pc, nil, nil, nil, tu.NewTestTracer(), testLogger)))
+		DoProxy(w, r, true)
+		if err = testStatusCodeMatch(w.Result().StatusCode, code); err != nil {
+			t.Error(err)
+		}
+	}
+	if count != 2 {
+		t.Errorf("expected %d upstream requests got %d", 2, count)
+	}
+	if o.Breaker.State() != circuitbreaker.StateOpen {
+		t.Errorf("expected %s got %s", circuitbreaker.StateOpen, o.Breaker.State())
+	}
+
+	// requests canceled by the client are not recorded
+	o.Breaker = circuitbreaker.New(o.CircuitBreaker)
+	for range 3 {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		r := httptest.NewRequest("GET", es.URL, nil)
+		r = r.WithContext(tc.WithResources(ctx,
+			request.NewResources(o, pc, nil, nil, nil, tu.NewTestTracer(), testLogger)))
+		DoProxy(httptest.NewRecorder(), r, true)
+	}
+	if o.Breaker.State() != circuitbreaker.StateClosed {
+		t.Errorf("expected %s got %s", circuitbreaker.StateClosed, o.Breaker.State())
+	}
+}
@@ -0,0 +1,157 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package engines
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/cache"
+	"github.com/trickstercache/trickster/v2/pkg/cache/inspect"
+	"github.com/trickstercache/trickster/v2/pkg/timeseries"
+)
+
+// inspectedPolicy is the cached portion of a CachingPolicy, as shown in an inspection report
+type inspectedPolicy struct {
+	IsFresh           bool      `json:"is_fresh"`
+	NoCache           bool      `json:"no_cache"`
+	NoTransform       bool      `json:"no_transform"`
+	CanRevalidate     bool      `json:"can_revalidate"`
+	MustRevalidate    bool      `json:"must_revalidate"`
+	IsNegativeCache   bool      `json:"is_negative_cache"`
+	FreshnessLifetime int       `json:"freshness_lifetime"`
+	LastModified      time.Time `json:"last_modified,omitempty"`
+	Expires           time.Time `json:"expires,omitempty"`
+	Date              time.Time `json:"date,omitempty"`
+	ETag              string    `json:"etag,omitempty"`
+}
+
+// inspectCache populates the request's cache inspection report with the engine, the
+// derived cache key and the cached object, and returns true, when the request is a
+// cache inspection. The caller must not serve the request when true is returned.
+// modeler is provided by the DeltaProxyCache engine to report the cached extents
+func inspectCache(r *http.Request, engine string, c cache.Cache, key string,
+	modeler *timeseries.Modeler, trq *timeseries.TimeRangeQuery,
+) bool {
+	rpt := inspect.FromRequest(r)
+	if rpt == nil {
+		return false
+	}
+	rpt.Engine = engine
+	rpt.Key = key
+	if c == nil {
+		return true
+	}
+	if cc := c.Configuration(); cc != nil {
+		rpt.Cache = cc.Name
+		rpt.Provider = cc.Provider
+	}
+	rpt.Index = inspect.Lookup(c, key)
+
+	// the key's read lock is held, so that requests can't modify the document meanwhile
+	if nl, err := c.Locker().RAcquire(key); err == nil {
+		defer nl.RRelease()
+	}
+	d, size, err := inspectDocument(c, key)
+	if err != nil {
+		if err != cache.ErrKNF {
+			rpt.Detail = err.Error()
+		}
+		return true
+	}
+	rpt.Exists = true
+	rpt.SizeBytes = size
+	rpt.StatusCode = d.StatusCode
+	rpt.Headers = d.SafeHeaderClone()
+	if cp := d.CachingPolicy; cp != nil {
+		rpt.CachingPolicy = &inspectedPolicy{
+			IsFresh:           cp.IsFresh,
+			NoCache:           cp.NoCache,
+			NoTransform:       cp.NoTransform,
+			CanRevalidate:     cp.CanRevalidate,
+			MustRevalidate:    cp.MustRevalidate,
+			IsNegativeCache:   cp.IsNegativeCache,
+			FreshnessLifetime: cp.FreshnessLifetime,
+			LastModified:      cp.LastModified,
+			Expires:           cp.Expires,
+			Date:              cp.Date,
+			ETag:              cp.ETag,
+		}
+	}
+	if modeler == nil {
+		return true
+	}
+	ts := d.timeseries
+	if ts == nil {
+		if ts, err = modeler.CacheUnmarshaler(d.Body, trq); err != nil {
+			rpt.Detail = "cached timeseries could not be unmarshaled: " + err.Error()
+			return true
+		}
+	}
+	rpt.Extents = inspectedExtents(ts.Extents())
+	rpt.VolatileExtents = inspectedExtents(ts.VolatileExtents())
+	return true
+}
+
+// inspectDocument returns the cached document for the key, and its size as stored
+func inspectDocument(c cache.Cache, key string) (*HTTPDocument, int64, error) {
+	if mc, ok := c.(cache.MemoryCache); ok && c.Configuration().Provider == "memory" {
+		ifc, _, err := mc.RetrieveReference(key, true)
+		if err != nil {
+			return nil, 0, err
+		}
+		d, ok := ifc.(*HTTPDocument)
+		if !ok || d == nil {
+			return nil, 0, cache.ErrKNF
+		}
+		return d, int64(d.Size()), nil
+	}
+	b, _, err := c.Retrieve(key, true)
+	if err != nil {
+		return nil, 0, err
+	}
+	size := int64(len(b))
+	if b, err = cacheEnvelope(c).Open(b); err != nil {
+		return nil, size, err
+	}
+	d := &HTTPDocument{}
+	if _, err = d.UnmarshalMsg(b); err != nil {
+		return nil, size, err
+	}
+	return d, size, nil
+}
+
+func inspectedExtents(el timeseries.ExtentList) []inspect.Extent {
+	if len(el) == 0 {
+		return nil
+	}
+	out := make([]inspect.Extent, len(el))
+	for i, e := range el {
+		out[i] = inspect.Extent{Start: e.Start, End: e.End}
+	}
+	return out
+}
+
+// inspectionResponse returns an empty upstream response for a cache inspection,
+// which is never proxied upstream
+func inspectionResponse(r *http.Request) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusNoContent,
+		Header:     make(http.Header),
+		Body:       http.NoBody,
+		Request:    r,
+	}
+}
@@ -0,0 +1,141 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package engines
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/cache/inspect"
+	tctx "github.com/trickstercache/trickster/v2/pkg/proxy/context"
+)
+
+func inspectionRequest(r *http.Request) (*http.Request, *inspect.Report) {
+	rpt := &inspect.Report{}
+	return r.WithContext(tctx.WithCacheInspection(r.Context(), rpt)), rpt
+}
+
+func TestInspectCacheOPC(t *testing.T) {
+	hdrs := map[string]string{"Cache-Control": "max-age=60", "ETag": "test-etag"}
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusOK, hdrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+
+	// the object is not yet cached
+	ir, rpt := inspectionRequest(r)
+	w := httptest.NewRecorder()
+	ObjectProxyCacheRequest(w, ir)
+	if rpt.Engine != "ObjectProxyCache" || rpt.Key == "" || rpt.Exists {
+		t.Errorf("unexpected report %+v", rpt)
+	}
+	if w.Body.Len() != 0 {
+		t.Error("expected inspection to not be served")
+	}
+	if _, _, err = rsc.CacheClient.Retrieve(rpt.Key, true); err == nil {
+		t.Error("expected inspection to not be cached")
+	}
+
+	_, e := testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	ir, rpt2 := inspectionRequest(r)
+	ObjectProxyCacheRequest(httptest.NewRecorder(), ir)
+	if !rpt2.Exists || rpt2.Key != rpt.Key || rpt2.StatusCode != http.StatusOK ||
+		rpt2.SizeBytes == 0 || rpt2.Provider != rsc.CacheConfig.Provider {
+		t.Errorf("unexpected report %+v", rpt2)
+	}
+	if rpt2.Headers.Get("ETag") != "test-etag" {
+		t.Errorf("expected %s got %s", "test-etag", rpt2.Headers.Get("ETag"))
+	}
+	cp, ok := rpt2.CachingPolicy.(*inspectedPolicy)
+	if !ok || cp.FreshnessLifetime != 60 || cp.ETag != "test-etag" {
+		t.Errorf("unexpected caching policy %+v", rpt2.CachingPolicy)
+	}
+	if rpt2.Index == nil || rpt2.Index.Key != rpt.Key || rpt2.Index.Expiration == nil {
+		t.Errorf("unexpected index info %+v", rpt2.Index)
+	}
+}
+
+func TestInspectCacheDPC(t *testing.T) {
+	ts, w, r, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+
+	client := rsc.BackendClient.(*TestClient)
+	rsc.BackendOptions.FastForwardDisable = true
+	step := 300 * time.Second
+	end := time.Now().Add(-12 * time.Hour)
+	start := end.Add(-6 * time.Hour)
+	r.URL.Path = "/prometheus/api/v1/query_range"
+	r.URL.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s",
+		int(step.Seconds()), start.Unix(), end.Unix(), queryReturnsOKNoLatency)
+
+	ir, rpt := inspectionRequest(r)
+	client.QueryRangeHandler(httptest.NewRecorder(), ir)
+	if rpt.Engine != "DeltaProxyCache" || rpt.Key == "" || rpt.Exists {
+		t.Errorf("unexpected report %+v", rpt)
+	}
+
+	client.QueryRangeHandler(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d", http.StatusOK, w.Code)
+	}
+	// the cache is written asynchronously
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, _, err = rsc.CacheClient.Retrieve(rpt.Key, true); err == nil ||
+			time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ir, rpt2 := inspectionRequest(r)
+	client.QueryRangeHandler(httptest.NewRecorder(), ir)
+	if !rpt2.Exists || rpt2.Key != rpt.Key || len(rpt2.Extents) != 1 {
+		t.Fatalf("unexpected report %+v", rpt2)
+	}
+	if rpt2.Extents[0].Start.After(start) || rpt2.Extents[0].End.Before(end.Add(-step)) {
+		t.Errorf("unexpected extents %v", rpt2.Extents)
+	}
+}
+
+func TestInspectionNotProxied(t *testing.T) {
+	ts, _, r, _, err := setupTestHarnessOPC("", "test", http.StatusOK, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+	ir, rpt := inspectionRequest(r)
+	w := httptest.NewRecorder()
+	resp := DoProxy(w, ir, true)
+	if resp.StatusCode != http.StatusNoContent || w.Body.Len() != 0 || rpt.Engine != "" {
+		t.Errorf("expected inspection to not be proxied, got %d", resp.StatusCode)
+	}
+	rc, resp, _ := PrepareFetchReader(ir)
+	if rc != http.NoBody || resp.StatusCode != http.StatusNoContent {
+		t.Errorf("expected inspection to not be fetched, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,136 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/cache"
+	"github.com/trickstercache/trickster/v2/pkg/cache/negative"
+	"github.com/trickstercache/trickster/v2/pkg/cache/status"
+	"github.com/trickstercache/trickster/v2/pkg/encoding/providers"
+	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/request"
+)
+
+// negativeKeySuffix is appended to a Delta Proxy Cache key to store the negatively
+// cached response for that key, without disturbing the cached timeseries
+const negativeKeySuffix = ".negative"
+
+// matchNegativeRule returns the first rule that matches the response, decoding
+// the body first if it is content-encoded. At most negative.MaxBodySize bytes are
+// decoded, so that a small compressed body can't expand without bound
+func matchNegativeRule(rules negative.Rules, code int, h http.Header, body []byte) *negative.Rule {
+	if len(rules) == 0 {
+		return nil
+	}
+	if len(body) > 0 && rules.NeedsBodyFor(code, h) {
+		if ce := h.Get(headers.NameContentEncoding); ce != "" {
+			if decoderInit := providers.GetDecoderInitializer(ce); decoderInit != nil {
+				r := io.LimitReader(decoderInit(io.NopCloser(bytes.NewReader(body))),
+					negative.MaxBodySize)
+				if b, err := io.ReadAll(r); err == nil {
+					body = b
+				}
+			}
+		}
+	}
+	return rules.Match(code, h, body)
+}
+
+// applyNegativeCacheRules evaluates the backend's negative cache rules against the
+// upstream response, buffering up to negative.MaxBodySize bytes of the response body
+// when a rule whose status and header matchers match needs to inspect it. A matching
+// 'cache' rule overrides the caching policy with the rule's TTL, while a matching
+// 'serve_last_good' rule ensures the response is never cached
+func (pr *proxyRequest) applyNegativeCacheRules(rules negative.Rules) {
+	resp := pr.upstreamResponse
+	if len(rules) == 0 || resp == nil {
+		return
+	}
+	var body []byte
+	if pr.upstreamReader != nil && rules.NeedsBodyFor(resp.StatusCode, resp.Header) {
+		// the remainder of a longer body is left to be read from the upstream
+		body, _ = io.ReadAll(io.LimitReader(pr.upstreamReader, negative.MaxBodySize))
+		pr.upstreamReader = io.MultiReader(bytes.NewReader(body), pr.upstreamReader)
+	}
+	r := matchNegativeRule(rules, resp.StatusCode, resp.Header, body)
+	if r == nil {
+		return
+	}
+	pr.negativeRule = r
+	if r.IsServeLastGood() {
+		pr.cachingPolicy.IsNegativeCache = false
+		return
+	}
+	pr.cachingPolicy.LocalDate = time.Now()
+	pr.cachingPolicy.FreshnessLifetime = freshnessLifetime(r.TTL)
+	pr.cachingPolicy.Expires = pr.cachingPolicy.LocalDate.Add(r.TTL)
+	pr.cachingPolicy.IsNegativeCache = true
+}
+
+// freshnessLifetime returns the TTL in whole seconds, rounded up so that a sub-second
+// TTL does not make a response that is immediately stale
+func freshnessLifetime(ttl time.Duration) int {
+	return int((ttl + time.Second - 1) / time.Second)
+}
+
+// queryDPCNegativeCache returns the negatively cached response for the Delta Proxy
+// Cache key, if one exists
+func queryDPCNegativeCache(ctx context.Context, c cache.Cache, key string) *HTTPDocument {
+	d, s, _, err := QueryCache(ctx, c, key+negativeKeySuffix, nil)
+	if err != nil || s != status.LookupStatusHit || d == nil {
+		return nil
+	}
+	return d
+}
+
+// cacheDPCNegativeResponse evaluates the backend's negative cache rules against a
+// failed Delta Proxy Cache upstream response, writing the response to the negative
+// cache when the matching rule's action is 'cache'. The matching rule is returned
+func (pr *proxyRequest) cacheDPCNegativeResponse(ctx context.Context, c cache.Cache,
+	key string, d *HTTPDocument,
+) *negative.Rule {
+	o := request.GetResources(pr.Request).BackendOptions
+	r := matchNegativeRule(o.NegativeRules, d.StatusCode, d.SafeHeaderClone(), d.Body)
+	if r == nil || r.IsServeLastGood() {
+		return r
+	}
+	now := time.Now()
+	d.CachingPolicy = &CachingPolicy{
+		LocalDate:         now,
+		FreshnessLifetime: freshnessLifetime(r.TTL),
+		Expires:           now.Add(r.TTL),
+		IsNegativeCache:   true,
+	}
+	if err := WriteCache(ctx, c, key+negativeKeySuffix, d, r.TTL, o.CompressibleTypes); err != nil {
+		tl.Error(pr.Logger, "error writing object to cache",
+			tl.Pairs{
+				"backendName": o.Name,
+				"cacheName":   c.Configuration().Name,
+				"cacheKey":    key + negativeKeySuffix,
+				"detail":      err.Error(),
+			},
+		)
+	}
+	return r
+}
@@ -0,0 +1,290 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/cache/negative"
+	tc "github.com/trickstercache/trickster/v2/pkg/proxy/context"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+	po "github.com/trickstercache/trickster/v2/pkg/proxy/paths/options"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/request"
+	"github.com/trickstercache/trickster/v2/pkg/timeseries"
+)
+
+func TestMatchNegativeRule(t *testing.T) {
+	if r := matchNegativeRule(nil, 500, nil, nil); r != nil {
+		t.Error("expected nil rule")
+	}
+	rules, err := negative.CompileRules([]*negative.RuleOptions{
+		{JSONPath: "status", JSONValue: "error", TTLMS: 1000},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := []byte(`{"status":"error"}`)
+	if r := matchNegativeRule(rules, 200, http.Header{}, body); r != rules[0] {
+		t.Error("expected rule match")
+	}
+
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	gz.Write(body)
+	gz.Close()
+	h := http.Header{headers.NameContentEncoding: []string{"gzip"}}
+	if r := matchNegativeRule(rules, 200, h, buf.Bytes()); r != rules[0] {
+		t.Error("expected rule match on encoded body")
+	}
+
+	// an encoded body is only decoded up to negative.MaxBodySize bytes
+	rules, _ = negative.CompileRules([]*negative.RuleOptions{
+		{BodyRegex: "query timed out", TTLMS: 1000},
+	})
+	buf.Reset()
+	gz = gzip.NewWriter(buf)
+	gz.Write(bytes.Repeat([]byte(" "), negative.MaxBodySize))
+	gz.Write([]byte("query timed out"))
+	gz.Close()
+	if r := matchNegativeRule(rules, 200, h, buf.Bytes()); r != nil {
+		t.Error("expected no rule match beyond the decoded body limit")
+	}
+}
+
+func TestFreshnessLifetime(t *testing.T) {
+	tests := []struct {
+		ttl time.Duration
+		exp int
+	}{
+		{0, 0},
+		{time.Millisecond, 1},
+		{time.Second, 1},
+		{1500 * time.Millisecond, 2},
+	}
+	for _, test := range tests {
+		if v := freshnessLifetime(test.ttl); v != test.exp {
+			t.Errorf("expected %d for %s got %d", test.exp, test.ttl, v)
+		}
+	}
+}
+
+func TestObjectProxyCacheRequestNegativeCacheRules(t *testing.T) {
+	const body = `{"status":"error","error":"query timed out"}`
+	ts, _, r, rsc, err := setupTestHarnessOPC("", body, http.StatusOK, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	pc := po.New()
+	cfg := rsc.BackendOptions
+	cfg.Paths = map[string]*po.Options{
+		"/": pc,
+	}
+	cfg.NegativeRules, err = negative.CompileRules([]*negative.RuleOptions{
+		{Status: "2xx", JSONPath: "status", JSONValue: "error", TTLMS: 30000},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = r.WithContext(tc.WithResources(r.Context(), request.NewResources(cfg, pc, rsc.CacheConfig,
+		rsc.CacheClient, rsc.BackendClient, nil, rsc.Logger)))
+
+	_, e := testFetchOPC(r, http.StatusOK, body, map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	// the error response is negatively cached, despite its 200 status code
+	_, e = testFetchOPC(r, http.StatusOK, body, map[string]string{"status": "nchit"})
+	for _, err = range e {
+		t.Error(err)
+	}
+}
+
+func TestApplyNegativeCacheRules(t *testing.T) {
+	ts, _, r, _, err := setupTestHarnessOPC("", "test", http.StatusServiceUnavailable, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	rules, err := negative.CompileRules([]*negative.RuleOptions{
+		{Status: "5xx", BodyRegex: "^test$", Action: negative.ActionServeLastGood},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pr := newProxyRequest(r, nil)
+	pr.cachingPolicy = &CachingPolicy{IsNegativeCache: true}
+	pr.upstreamResponse = &http.Response{StatusCode: http.StatusServiceUnavailable,
+		Header: http.Header{}}
+	pr.upstreamReader = bytes.NewReader([]byte("test"))
+	pr.applyNegativeCacheRules(rules)
+	if !pr.negativeRule.IsServeLastGood() {
+		t.Error("expected serve_last_good rule")
+	}
+	if pr.cachingPolicy.IsNegativeCache {
+		t.Error("expected serve_last_good to override the negative cache")
+	}
+	// the buffered body must still be readable for the client response
+	b, _ := io.ReadAll(pr.upstreamReader)
+	if string(b) != "test" {
+		t.Errorf("expected %s got %s", "test", string(b))
+	}
+	pr.determineCacheability()
+	if pr.writeToCache {
+		t.Error("expected writeToCache to be false")
+	}
+
+	// the body is not read when no body rule's status matches
+	pr = newProxyRequest(r, nil)
+	pr.cachingPolicy = &CachingPolicy{}
+	pr.upstreamResponse = &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+	br := bytes.NewReader([]byte("test"))
+	pr.upstreamReader = br
+	pr.applyNegativeCacheRules(rules)
+	if pr.negativeRule != nil || pr.upstreamReader != br || br.Len() != 4 {
+		t.Error("expected the body not to be read")
+	}
+
+	// a body longer than negative.MaxBodySize is only partly buffered, and is
+	// still read in full for the client response
+	long := append(bytes.Repeat([]byte("a"), negative.MaxBodySize), []byte("test")...)
+	pr = newProxyRequest(r, nil)
+	pr.cachingPolicy = &CachingPolicy{}
+	pr.upstreamResponse = &http.Response{StatusCode: http.StatusServiceUnavailable,
+		Header: http.Header{}}
+	pr.upstreamReader = bytes.NewReader(long)
+	pr.applyNegativeCacheRules(rules)
+	if pr.negativeRule != nil {
+		t.Error("expected no rule match")
+	}
+	if b, _ = io.ReadAll(pr.upstreamReader); !bytes.Equal(b, long) {
+		t.Errorf("expected %d bytes got %d", len(long), len(b))
+	}
+}
+
+func TestDeltaProxyCacheRequestNegativeCacheRules(t *testing.T) {
+	ts, w, r, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	client := rsc.BackendClient.(*TestClient)
+	o := rsc.BackendOptions
+	rsc.CacheConfig.Provider = "test"
+	o.FastForwardDisable = true
+	o.NegativeRules, err = negative.CompileRules([]*negative.RuleOptions{
+		{Status: "5xx", TTLMS: 30000},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.RangeCacheKey = "test-range-key-negative"
+	client.InstantCacheKey = "test-instant-key-negative"
+
+	step := time.Duration(300) * time.Second
+	end := time.Now().Add(-time.Duration(12) * time.Hour)
+	extr := timeseries.Extent{Start: end.Add(-time.Duration(18) * time.Hour), End: end}
+
+	u := r.URL
+	u.Path = "/prometheus/api/v1/query_range"
+	u.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s&rk=%s&ik=%s", int(step.Seconds()),
+		extr.Start.Unix(), extr.End.Unix(), queryReturnsBadGateway, client.RangeCacheKey, client.InstantCacheKey)
+
+	for _, s := range []string{"proxy-error", "nchit"} {
+		w = httptest.NewRecorder()
+		client.QueryRangeHandler(w, r)
+		resp := w.Result()
+		if err = testStatusCodeMatch(resp.StatusCode, http.StatusBadGateway); err != nil {
+			t.Error(err)
+		}
+		if err = testResultHeaderPartMatch(resp.Header, map[string]string{"status": s}); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestDeltaProxyCacheRequestServeLastGood(t *testing.T) {
+	ts, w, r, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	client := rsc.BackendClient.(*TestClient)
+	o := rsc.BackendOptions
+	rsc.CacheConfig.Provider = "test"
+	o.FastForwardDisable = true
+	o.NegativeRules, err = negative.CompileRules([]*negative.RuleOptions{
+		{Status: "5xx", Action: negative.ActionServeLastGood},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.RangeCacheKey = "test-range-key-lastgood"
+	client.InstantCacheKey = "test-instant-key-lastgood"
+
+	step := time.Duration(300) * time.Second
+	end := time.Now().Add(-time.Duration(12) * time.Hour)
+	extr := timeseries.Extent{Start: end.Add(-time.Duration(18) * time.Hour), End: end}
+
+	u := r.URL
+	u.Path = "/prometheus/api/v1/query_range"
+	u.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s&rk=%s&ik=%s", int(step.Seconds()),
+		extr.Start.Unix(), extr.End.Unix(), queryReturnsOKNoLatency, client.RangeCacheKey, client.InstantCacheKey)
+
+	client.QueryRangeHandler(w, r)
+	resp := w.Result()
+	if err = testStatusCodeMatch(resp.StatusCode, http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	expected, _ := io.ReadAll(resp.Body)
+
+	// extend the range so the delta is fetched with a query that fails upstream;
+	// the cached portion of the timeseries is served in place of the error
+	extr.End = extr.End.Add(time.Duration(1) * time.Hour)
+	u.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s&rk=%s&ik=%s", int(step.Seconds()),
+		extr.Start.Unix(), extr.End.Unix(), queryReturnsBadGateway, client.RangeCacheKey, client.InstantCacheKey)
+	r.URL = u
+
+	time.Sleep(time.Millisecond * 10)
+
+	w = httptest.NewRecorder()
+	client.QueryRangeHandler(w, r)
+	resp = w.Result()
+	if err = testStatusCodeMatch(resp.StatusCode, http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err = testResultHeaderPartMatch(resp.Header, map[string]string{"status": "phit"}); err != nil {
+		t.Error(err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	if err = testStringMatch(string(b), string(expected)); err != nil {
+		t.Error(err)
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/trickstercache/trickster/v2/pkg/cache"
 	"github.com/trickstercache/trickster/v2/pkg/cache/status"
 	"github.com/trickstercache/trickster/v2/pkg/encoding/profile"
+	"github.com/trickstercache/trickster/v2/pkg/locks"
 	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
 	tspan "github.com/trickstercache/trickster/v2/pkg/observability/tracing/span"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/errors"
@@ -127,7 +128,17 @@ func handleCachePartialHit(pr *proxyRequest) error {
 func confirmTrueCacheHit(pr *proxyRequest) (bool, error) {
 	pr.cachingPolicy.Merge(pr.cacheDocument.CachingPolicy)
 
-	if (!pr.checkCacheFreshness()) && (pr.cachingPolicy.CanRevalidate) {
+	fresh := pr.checkCacheFreshness()
+	// while the backend's circuit is open, a stale document may be served as-is
+	// since the origin will not be contacted to revalidate or replace it
+	if !fresh {
+		if rsc := request.GetResources(pr.Request); rsc != nil &&
+			rsc.BackendOptions != nil && rsc.BackendOptions.Breaker.IsOpen() &&
+			rsc.BackendOptions.Breaker.ServesStale() {
+			return true, nil
+		}
+	}
+	if !fresh && pr.cachingPolicy.CanRevalidate {
 		return false, handleCacheRevalidation(pr)
 	}
 	if !pr.cachingPolicy.IsFresh {
@@ -210,10 +221,33 @@ func handleCacheRevalidationResponse(pr *proxyRequest) error {
 	}
 
 	pr.revalidation = RevalStatusFailed
+
+	// when the failed revalidation matched a serve_last_good negative cache rule,
+	// or the revalidation was refused by a half-open circuit that serves stale
+	// content, the stale cached document is served in place of the upstream response
+	if pr.negativeRule.IsServeLastGood() && pr.cacheDocument != nil || refusedServesStale(pr) {
+		pr.writeToCache = false
+		pr.cacheStatus = status.LookupStatusHit
+		return handleTrueCacheHit(pr)
+	}
+
 	pr.cacheStatus = status.LookupStatusKeyMiss
 	return handleAllWrites(pr)
 }
 
+// refusedServesStale returns true if there is a cached document, and the upstream
+// request was refused by the backend's circuit breaker, which is configured to
+// serve stale content
+func refusedServesStale(pr *proxyRequest) bool {
+	if pr.cacheDocument == nil || pr.cacheDocument.StatusCode == 0 ||
+		pr.upstreamResponse == nil ||
+		pr.upstreamResponse.Header.Get(headers.NameTrkCircuitBreaker) != "open" {
+		return false
+	}
+	rsc := request.GetResources(pr.Request)
+	return rsc != nil && rsc.BackendOptions != nil && rsc.BackendOptions.Breaker.ServesStale()
+}
+
 func handleTrueCacheHit(pr *proxyRequest) error {
 	d := pr.cacheDocument
 	if d == nil {
@@ -265,6 +299,15 @@ func handleCacheKeyMiss(pr *proxyRequest) error {
 
 	pr.prepareUpstreamRequests()
 	handleUpstreamTransactions(pr)
+	// there is no last good response to serve on a miss, so a matching
+	// serve_last_good rule only keeps the failure out of the cache.
+	// a stale document is served when the request was refused by a half-open
+	// circuit that serves stale content
+	if refusedServesStale(pr) {
+		pr.writeToCache = false
+		pr.cacheStatus = status.LookupStatusHit
+		return handleTrueCacheHit(pr)
+	}
 	return handleAllWrites(pr)
 }
 
@@ -315,6 +358,8 @@ func handlePCF(pr *proxyRequest) error {
 
 		pr.cachingPolicy.Merge(GetResponseCachingPolicy(pr.upstreamResponse.StatusCode,
 			rsc.BackendOptions.NegativeCache, pr.upstreamResponse.Header))
+		// the body streams to clients as it arrives, so only status and header rules apply
+		pr.applyNegativeCacheRules(rsc.BackendOptions.NegativeRules)
 		pr.determineCacheability()
 
 		go func() {
@@ -392,6 +437,10 @@ func fetchViaObjectProxyCache(w io.Writer, r *http.Request) (*http.Response, sta
 	pr.cachingPolicy = GetRequestCachingPolicy(pr.Header)
 
 	pr.key = o.CacheKeyPrefix + ".opc." + pr.DeriveCacheKey("")
+	rsc.CacheKey = pr.key
+	if inspectCache(r, "ObjectProxyCache", cc, pr.key, nil, nil) {
+		return nil, status.LookupStatusProxyOnly
+	}
 
 	// if a PCF entry exists, or the client requested no-cache for this object, proxy out to it
 	pcfResult, pcfExists := reqs.Load(pr.key)
@@ -493,10 +542,15 @@ func recordOPCResult(pr *proxyRequest, cacheStatus status.LookupStatus, httpStat
 
 func upgradeLock(pr *proxyRequest) (bool, bool) {
 	if pr.hasReadLock && !pr.hasWriteLock {
-		wasFirst := pr.cacheLock.Upgrade()
+		wasFirst, err := pr.cacheLock.Upgrade()
 		pr.hasReadLock = false
 		pr.hasWriteLock = true
-		if wasFirst {
+		// when the write lock wasn't acquired, proceed without rerunning,
+		// since a rerun would only wait on the lock again
+		if wasFirst || err != nil {
+			if err != nil {
+				logLockUpgradeError(pr, pr.key, err)
+			}
 			return true, true
 		}
 		return true, false
@@ -504,6 +558,24 @@ func upgradeLock(pr *proxyRequest) (bool, bool) {
 	return false, false
 }
 
+func logLockUpgradeError(pr *proxyRequest, key string, err error) {
+	tl.Warn(pr.Logger, "cache write lock not acquired, proceeding without it",
+		tl.Pairs{"cacheKey": key, "detail": err.Error()})
+}
+
+// writeLockLost returns true if the request's distributed write lock was lost, for
+// example when its lease expired while the request was upstream, in which case the
+// request must not write to the cache, since it could overwrite the next holder's write
+func writeLockLost(pr *proxyRequest, key string, nl locks.NamedLock) bool {
+	fl, ok := nl.(locks.FencedLock)
+	if !ok || fl.Held() {
+		return false
+	}
+	tl.Warn(pr.Logger, "cache write lock lost, skipping cache write",
+		tl.Pairs{"cacheKey": key, "fence": fl.Fence()})
+	return true
+}
+
 func rerunRequest(pr *proxyRequest) {
 	pr.wasReran = true
 	if w, ok := pr.responseWriter.(http.ResponseWriter); ok {
@@ -29,6 +29,8 @@ import (
 	"github.com/trickstercache/mockster/pkg/mocks/byterange"
 	"github.com/trickstercache/trickster/v2/pkg/cache/status"
 	"github.com/trickstercache/trickster/v2/pkg/locks"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/circuitbreaker"
+	cbo "github.com/trickstercache/trickster/v2/pkg/proxy/circuitbreaker/options"
 	tc "github.com/trickstercache/trickster/v2/pkg/proxy/context"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/errors"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/forwarding"
@@ -708,6 +710,61 @@ func TestObjectProxyCacheNoRevalidate(t *testing.T) {
 	}
 }
 
+func TestObjectProxyCacheCircuitOpen(t *testing.T) {
+	hdrs := map[string]string{headers.NameCacheControl: headers.ValueMaxAge + "=1"}
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusOK, hdrs)
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	p := rsc.PathConfig
+	p.ResponseHeaders = hdrs
+	cbOpts := cbo.New()
+	cbOpts.MinRequests = 1
+	cbOpts.OpenAction = cbo.OpenActionServeStale
+	rsc.BackendOptions.Breaker = circuitbreaker.New(cbOpts)
+
+	_, e := testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	time.Sleep(1010 * time.Millisecond)
+	rsc.BackendOptions.Breaker.Record(true, 0)
+
+	// the stale document is served while the circuit is open
+	_, e = testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "hit"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	// once half-open, the stale document is served to requests refused by the circuit
+	cbOpts.OpenMS = 1
+	cbOpts.HalfOpenProbes = 1
+	rsc.BackendOptions.Breaker = circuitbreaker.New(cbOpts)
+	rsc.BackendOptions.Breaker.Record(true, 0)
+	time.Sleep(20 * time.Millisecond)
+	if s := rsc.BackendOptions.Breaker.State(); s != circuitbreaker.StateHalfOpen {
+		t.Fatalf("expected %s got %s", circuitbreaker.StateHalfOpen, s)
+	}
+	rsc.BackendOptions.Breaker.Allow() // the only probe is in flight
+	_, e = testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "hit"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	// without serve_stale, requests fail fast
+	cbOpts.OpenAction = cbo.OpenActionFail
+	w, e := testFetchOPC(r, http.StatusServiceUnavailable, "", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+	if v := w.Result().Header.Get(headers.NameTrkCircuitBreaker); v != "open" {
+		t.Errorf("expected %s got %s", "open", v)
+	}
+}
+
 func TestObjectProxyCacheCanRevalidate(t *testing.T) {
 	headers := map[string]string{
 		headers.NameCacheControl: headers.ValueMaxAge + "=1",
@@ -1160,3 +1217,35 @@ func TestRerunRequest(t *testing.T) {
 		t.Error("expected true")
 	}
 }
+
+// testFencedLock is a process-local Named Lock that reports whether it is still held
+type testFencedLock struct {
+	locks.NamedLock
+	held bool
+}
+
+func (l *testFencedLock) Fence() int64 { return 1 }
+func (l *testFencedLock) Held() bool   { return l.held }
+
+func TestStoreWriteLockLost(t *testing.T) {
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusOK, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+	pr := newProxyRequest(r, httptest.NewRecorder())
+	nl, _ := locks.NewNamedLocker().Acquire("test")
+	defer nl.Release()
+	pr.cacheLock = &testFencedLock{NamedLock: nl}
+	pr.hasWriteLock = true
+	pr.writeToCache = true
+	pr.key = "test"
+	pr.cacheDocument = &HTTPDocument{Body: []byte("test")}
+	pr.cachingPolicy = &CachingPolicy{FreshnessLifetime: 60}
+	if err := pr.store(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := rsc.CacheClient.Retrieve(pr.key, false); err == nil {
+		t.Error("expected no cache write after the write lock was lost")
+	}
+}
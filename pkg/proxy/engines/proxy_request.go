@@ -24,6 +24,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/trickstercache/trickster/v2/pkg/cache/negative"
 	"github.com/trickstercache/trickster/v2/pkg/cache/status"
 	"github.com/trickstercache/trickster/v2/pkg/locks"
 	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
@@ -79,6 +80,7 @@ type proxyRequest struct {
 
 	collapsedForwarder ProgressiveCollapseForwarder
 	cachingPolicy      *CachingPolicy
+	negativeRule       *negative.Rule
 
 	Logger            interface{}
 	isPCF             bool
@@ -398,7 +400,7 @@ func (pr *proxyRequest) determineCacheability() {
 	rsc := request.GetResources(pr.Request)
 	resp := pr.upstreamResponse
 
-	if resp != nil && resp.StatusCode >= 400 {
+	if (resp != nil && resp.StatusCode >= 400) || pr.negativeRule != nil {
 		pr.writeToCache = pr.cachingPolicy.IsNegativeCache
 		resp.Header.Del(headers.NameCacheControl)
 		resp.Header.Del(headers.NameExpires)
@@ -444,6 +446,10 @@ func (pr *proxyRequest) store() error {
 	if !pr.writeToCache || pr.cacheDocument == nil {
 		return nil
 	}
+	if pr.hasWriteLock && writeLockLost(pr, pr.key, pr.cacheLock) {
+		pr.writeToCache = false
+		return nil
+	}
 
 	d := pr.cacheDocument
 
@@ -723,6 +729,7 @@ func (pr *proxyRequest) reconstituteResponses() {
 		pr.mapLock.Lock()
 		pr.cachingPolicy.Merge(GetResponseCachingPolicy(pr.upstreamResponse.StatusCode,
 			rsc.BackendOptions.NegativeCache, pr.upstreamResponse.Header))
+		pr.applyNegativeCacheRules(rsc.BackendOptions.NegativeRules)
 		pr.mapLock.Unlock()
 
 	}
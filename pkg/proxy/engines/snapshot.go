@@ -0,0 +1,77 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package engines
+
+import (
+	"errors"
+
+	"github.com/trickstercache/trickster/v2/pkg/cache"
+)
+
+func init() {
+	cache.RegisterReferenceCodec(documentCodec{})
+}
+
+var (
+	errUnsupportedReference = errors.New("unsupported reference object type")
+	errNoCacheMarshaler     = errors.New("document timeseries has no cache marshaler")
+)
+
+// documentCodec marshals the HTTPDocuments stored by reference in memory caches, so
+// they can be included in memory cache snapshots and written to tiered cache L2s.
+// A document's timeseries is serialized into the Body, as it would be for any
+// other cache provider
+type documentCodec struct{}
+
+func (documentCodec) MarshalReference(ro cache.ReferenceObject) ([]byte, error) {
+	d, ok := ro.(*HTTPDocument)
+	if !ok || d == nil {
+		return nil, errUnsupportedReference
+	}
+	body := d.Body
+	if d.timeseries != nil {
+		if d.cacheMarshaler == nil {
+			return nil, errNoCacheMarshaler
+		}
+		b, err := d.cacheMarshaler(d.timeseries, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		body = b
+	}
+	d.headerLock.Lock()
+	defer d.headerLock.Unlock()
+	sd := &HTTPDocument{
+		StatusCode:       d.StatusCode,
+		Status:           d.Status,
+		Headers:          d.Headers,
+		Body:             body,
+		ContentLength:    d.ContentLength,
+		ContentType:      d.ContentType,
+		CachingPolicy:    d.CachingPolicy,
+		Ranges:           d.Ranges,
+		StoredRangeParts: d.StoredRangeParts,
+	}
+	return sd.MarshalMsg(nil)
+}
+
+func (documentCodec) UnmarshalReference(b []byte) (cache.ReferenceObject, error) {
+	d := &HTTPDocument{}
+	if _, err := d.UnmarshalMsg(b); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
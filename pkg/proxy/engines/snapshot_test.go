@@ -0,0 +1,91 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package engines
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/trickstercache/trickster/v2/pkg/timeseries"
+	"github.com/trickstercache/trickster/v2/pkg/timeseries/dataset"
+)
+
+type testReferenceObject struct{}
+
+func (testReferenceObject) Size() int { return 0 }
+
+func TestDocumentCodec(t *testing.T) {
+	dc := documentCodec{}
+
+	if _, err := dc.MarshalReference(testReferenceObject{}); err != errUnsupportedReference {
+		t.Errorf("expected %v got %v", errUnsupportedReference, err)
+	}
+
+	d := &HTTPDocument{
+		StatusCode:    200,
+		Status:        "200 OK",
+		Headers:       map[string][]string{"Content-Type": {"application/json"}},
+		Body:          []byte("body"),
+		ContentLength: 4,
+		ContentType:   "application/json",
+	}
+	b, err := dc.MarshalReference(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ro, err := dc.UnmarshalReference(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d2, ok := ro.(*HTTPDocument)
+	if !ok || d2.StatusCode != 200 || string(d2.Body) != "body" ||
+		d2.ContentType != "application/json" || len(d2.Headers["Content-Type"]) != 1 {
+		t.Errorf("unexpected document %v", ro)
+	}
+
+	// a document's timeseries is serialized into the body with its cache marshaler
+	d.timeseries = &dataset.DataSet{}
+	if _, err = dc.MarshalReference(d); err != errNoCacheMarshaler {
+		t.Errorf("expected %v got %v", errNoCacheMarshaler, err)
+	}
+	d.cacheMarshaler = func(timeseries.Timeseries, *timeseries.RequestOptions, int) ([]byte, error) {
+		return []byte("timeseries"), nil
+	}
+	if b, err = dc.MarshalReference(d); err != nil {
+		t.Fatal(err)
+	}
+	if string(d.Body) != "body" {
+		t.Error("expected the stored document to be unchanged")
+	}
+	if ro, err = dc.UnmarshalReference(b); err != nil {
+		t.Fatal(err)
+	}
+	if d2 = ro.(*HTTPDocument); string(d2.Body) != "timeseries" || d2.timeseries != nil {
+		t.Errorf("expected %s got %s", "timeseries", string(d2.Body))
+	}
+
+	expected := errors.New("marshal failure")
+	d.cacheMarshaler = func(timeseries.Timeseries, *timeseries.RequestOptions, int) ([]byte, error) {
+		return nil, expected
+	}
+	if _, err = dc.MarshalReference(d); err != expected {
+		t.Errorf("expected %v got %v", expected, err)
+	}
+
+	if _, err = dc.UnmarshalReference([]byte("invalid")); err == nil {
+		t.Error("expected unmarshal error")
+	}
+}
@@ -0,0 +1,49 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"github.com/trickstercache/trickster/v2/pkg/timeseries"
+)
+
+// splitWarnedTimeseries separates the timeseries carrying upstream warnings
+// (e.g., partial results) from those that do not, and returns the combined
+// warnings of the former
+func splitWarnedTimeseries(mts []timeseries.Timeseries) (clean,
+	warned []timeseries.Timeseries, warnings []string) {
+	clean = make([]timeseries.Timeseries, 0, len(mts))
+	for _, ts := range mts {
+		w := timeseries.Warnings(ts)
+		if len(w) == 0 {
+			clean = append(clean, ts)
+			continue
+		}
+		warned = append(warned, ts)
+		warnings = timeseries.MergeWarnings(warnings, w)
+	}
+	return clean, warned, warnings
+}
+
+// extentsOutside returns true if any extent in el begins or ends outside of e
+func extentsOutside(el timeseries.ExtentList, e timeseries.Extent) bool {
+	for _, x := range el {
+		if x.Start.Before(e.Start) || x.End.After(e.End) {
+			return true
+		}
+	}
+	return false
+}
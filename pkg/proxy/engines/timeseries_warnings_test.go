@@ -0,0 +1,139 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/cache/warningpolicies"
+	tst "github.com/trickstercache/trickster/v2/pkg/testutil/timeseries/model"
+	"github.com/trickstercache/trickster/v2/pkg/timeseries"
+	"github.com/trickstercache/trickster/v2/pkg/timeseries/dataset"
+)
+
+func TestSplitWarnedTimeseries(t *testing.T) {
+	mts := []timeseries.Timeseries{
+		&dataset.DataSet{},
+		&dataset.DataSet{Warnings: []string{"a", "b"}},
+		&dataset.DataSet{Warnings: []string{"b", "c"}},
+	}
+	clean, warned, warnings := splitWarnedTimeseries(mts)
+	if len(clean) != 1 || len(warned) != 2 {
+		t.Errorf("expected 1 clean and 2 warned, got %d and %d", len(clean), len(warned))
+	}
+	if !slices.Equal(warnings, []string{"a", "b", "c"}) {
+		t.Errorf("unexpected warnings %v", warnings)
+	}
+}
+
+func TestExtentsOutside(t *testing.T) {
+	e := timeseries.Extent{Start: time.Unix(100, 0), End: time.Unix(200, 0)}
+	el := timeseries.ExtentList{{Start: time.Unix(120, 0), End: time.Unix(180, 0)}}
+	if extentsOutside(el, e) {
+		t.Error("expected false")
+	}
+	el = append(el, timeseries.Extent{Start: time.Unix(190, 0), End: time.Unix(210, 0)})
+	if !extentsOutside(el, e) {
+		t.Error("expected true")
+	}
+}
+
+func warnedModeler() *timeseries.Modeler {
+	m := tst.Modeler()
+	wur := m.WireUnmarshalerReader
+	m.WireUnmarshalerReader = func(r io.Reader, trq *timeseries.TimeRangeQuery) (timeseries.Timeseries, error) {
+		ts, err := wur(r, trq)
+		if err == nil {
+			timeseries.SetWarnings(ts, []string{"partial result"})
+		}
+		return ts, err
+	}
+	return m
+}
+
+func TestDeltaProxyCacheRequestWarnings(t *testing.T) {
+	tests := []struct {
+		policy   warningpolicies.TimeseriesWarningPolicy
+		statuses []string
+	}{
+		// the warned response is not cached, so the next request misses again
+		{warningpolicies.WarningPolicySkip, []string{"kmiss", "kmiss", "hit"}},
+		// the warned range is volatile, so the next request refetches all of it
+		{warningpolicies.WarningPolicyVolatile, []string{"kmiss", "rmiss", "hit"}},
+		// the warned response is cached like any other
+		{warningpolicies.WarningPolicyCache, []string{"kmiss", "hit", "hit"}},
+	}
+	for i, test := range tests {
+		t.Run(test.policy.String(), func(t *testing.T) {
+			ts, _, r, rsc, err := setupTestHarnessDPC()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ts.Close()
+
+			client := rsc.BackendClient.(*TestClient)
+			o := rsc.BackendOptions
+			rsc.CacheConfig.Provider = "test"
+			o.FastForwardDisable = true
+			o.TimeseriesWarningPolicy = test.policy
+			client.RangeCacheKey = fmt.Sprintf("test-range-key-warnings-%d", i)
+			client.InstantCacheKey = fmt.Sprintf("test-instant-key-warnings-%d", i)
+
+			step := time.Duration(300) * time.Second
+			end := time.Now().Add(-time.Duration(12) * time.Hour)
+			extr := timeseries.Extent{Start: end.Add(-time.Duration(18) * time.Hour), End: end}
+
+			u := r.URL
+			u.Path = "/prometheus/api/v1/query_range"
+			u.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s&rk=%s&ik=%s",
+				int(step.Seconds()), extr.Start.Unix(), extr.End.Unix(), queryReturnsOKNoLatency,
+				client.RangeCacheKey, client.InstantCacheKey)
+
+			for j, expected := range test.statuses {
+				m := tst.Modeler()
+				if j == 0 {
+					m = warnedModeler()
+				}
+				r.URL = u
+				r.URL = client.BuildUpstreamURL(r)
+				w := httptest.NewRecorder()
+				DeltaProxyCacheRequest(w, r, m)
+				resp := w.Result()
+				if err = testStatusCodeMatch(resp.StatusCode, http.StatusOK); err != nil {
+					t.Error(err)
+				}
+				if err = testResultHeaderPartMatch(resp.Header,
+					map[string]string{"status": expected}); err != nil {
+					t.Errorf("request %d: %s", j, err)
+				}
+				warnings := timeseries.Warnings(rsc.TS)
+				if j == 0 && len(warnings) != 1 {
+					t.Errorf("request %d: expected 1 warning, got %v", j, warnings)
+				} else if j > 0 && len(warnings) != 0 {
+					t.Errorf("request %d: expected no warnings, got %v", j, warnings)
+				}
+				time.Sleep(time.Millisecond * 10)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package faults injects errors, aborted connections, truncated responses and
+// upstream delays into a sample of requests, to test how clients and Trickster's
+// failure handling (e.g., ALBs, retries and circuit breakers) behave under failure
+package faults
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache/inspect"
+	"github.com/trickstercache/trickster/v2/pkg/observability/metrics"
+	tctx "github.com/trickstercache/trickster/v2/pkg/proxy/context"
+	fo "github.com/trickstercache/trickster/v2/pkg/proxy/faults/options"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+	po "github.com/trickstercache/trickster/v2/pkg/proxy/paths/options"
+)
+
+// HeaderName is the response header that identifies the fault injected into
+// the response, when the fault allows for a response
+const HeaderName = "x-injected-fault"
+
+// The faults recorded by the faults injected metric and response header
+const (
+	FaultResponseCode  = "response_code"
+	FaultAbort         = "abort"
+	FaultTruncate      = "truncate"
+	FaultUpstreamDelay = "upstream_delay"
+)
+
+type handler struct {
+	backendName string
+	options     *fo.Options
+	next        http.Handler
+}
+
+// Handle returns a handler that injects faults into a sample of the requests
+// it serves, per the path's fault injection options or, when the path has none,
+// the backend's. If neither is configured, next is returned as-is.
+func Handle(o *bo.Options, p *po.Options, next http.Handler) http.Handler {
+	if o == nil {
+		return next
+	}
+	f := o.FaultInjection
+	if p != nil && p.FaultInjection != nil {
+		f = p.FaultInjection
+	}
+	if f == nil {
+		return next
+	}
+	return &handler{backendName: o.Name, options: f, next: next}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f := h.options
+	// cache inspection requests are never sent upstream, so faults are not injected
+	if inspect.FromRequest(r) != nil || !f.Matches(r) || rand.Float64()*100 >= f.Percent {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	if d := delay(f.UpstreamDelayMinMS, f.UpstreamDelayMaxMS); d > 0 {
+		// the delay is applied by the proxy engines when the request is sent
+		// upstream, so that cache hits are not delayed
+		r = r.WithContext(tctx.WithUpstreamDelay(r.Context(), d))
+	}
+	switch {
+	case f.ResponseCode > 0:
+		h.inc(FaultResponseCode)
+		w.Header().Set(HeaderName, FaultResponseCode)
+		if f.ResponseBody != "" {
+			w.Header().Set(headers.NameContentLength, strconv.Itoa(len(f.ResponseBody)))
+		}
+		w.WriteHeader(f.ResponseCode)
+		w.Write([]byte(f.ResponseBody))
+	case f.Abort:
+		h.inc(FaultAbort)
+		if !abort(w) {
+			// the connection can't be taken over from the server (e.g., for
+			// HTTP/2, or requests routed internally by an ALB), so fail instead
+			w.Header().Set(HeaderName, FaultAbort)
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	case f.TruncateBytes > 0:
+		h.inc(FaultTruncate)
+		w.Header().Set(HeaderName, FaultTruncate)
+		tw := &truncateWriter{ResponseWriter: w, remaining: f.TruncateBytes}
+		h.next.ServeHTTP(tw, r)
+		if tw.truncated {
+			// send the truncated body before closing the connection
+			http.NewResponseController(w).Flush()
+			abort(w)
+		}
+	default:
+		h.next.ServeHTTP(w, r)
+	}
+}
+
+func (h *handler) inc(fault string) {
+	metrics.FaultsInjected.WithLabelValues(h.backendName, fault).Inc()
+}
+
+// delay returns the upstream delay to inject, which is a random duration
+// between minMS and maxMS when maxMS > minMS, and otherwise minMS
+func delay(minMS, maxMS int) time.Duration {
+	ms := int64(minMS)
+	if maxMS > minMS {
+		ms += rand.Int63n(int64(maxMS - minMS))
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// abort closes the client connection without completing the response. It
+// returns false if the connection can't be taken over from the server
+func abort(w http.ResponseWriter) bool {
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// truncateWriter passes up to remaining bytes of the response body through to
+// the underlying ResponseWriter, and discards the rest
+type truncateWriter struct {
+	http.ResponseWriter
+	remaining int
+	truncated bool
+}
+
+func (tw *truncateWriter) Write(b []byte) (int, error) {
+	if len(b) > tw.remaining {
+		tw.truncated = true
+		n, err := tw.ResponseWriter.Write(b[:tw.remaining])
+		tw.remaining -= n
+		if err != nil {
+			return n, err
+		}
+		return len(b), nil
+	}
+	n, err := tw.ResponseWriter.Write(b)
+	tw.remaining -= n
+	return n, err
+}
+
+// Flush implements http.Flusher
+func (tw *truncateWriter) Flush() {
+	if f, ok := tw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying ResponseWriter, for use by http.ResponseController
+func (tw *truncateWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
+// DelayUpstream waits for any upstream delay injected into the request's
+// context, and is called by the proxy engines before sending a request upstream.
+// It returns the context's error if the request is canceled during the delay.
+func DelayUpstream(r *http.Request, backendName string) error {
+	d := tctx.UpstreamDelay(r.Context())
+	if d <= 0 {
+		return nil
+	}
+	metrics.FaultsInjected.WithLabelValues(backendName, FaultUpstreamDelay).Inc()
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-r.Context().Done():
+		return r.Context().Err()
+	}
+}
@@ -0,0 +1,190 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package faults
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache/inspect"
+	tctx "github.com/trickstercache/trickster/v2/pkg/proxy/context"
+	fo "github.com/trickstercache/trickster/v2/pkg/proxy/faults/options"
+	po "github.com/trickstercache/trickster/v2/pkg/proxy/paths/options"
+)
+
+const testBody = "0123456789"
+
+var testNext = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Length", "10")
+	w.Write([]byte(testBody))
+})
+
+func testHandler(f *fo.Options) http.Handler {
+	o := bo.New()
+	o.Name = "test"
+	o.FaultInjection = f
+	return Handle(o, nil, testNext)
+}
+
+func TestHandleNoFaults(t *testing.T) {
+	if _, ok := Handle(bo.New(), &po.Options{}, testNext).(*handler); ok {
+		t.Error("expected next handler")
+	}
+	if _, ok := Handle(nil, nil, testNext).(*handler); ok {
+		t.Error("expected next handler")
+	}
+	// path options override backend options
+	o := bo.New()
+	o.FaultInjection = &fo.Options{Percent: 100, Abort: true}
+	h := Handle(o, &po.Options{FaultInjection: &fo.Options{Percent: 100, ResponseCode: 418}}, testNext)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusTeapot {
+		t.Errorf("expected %d got %d", http.StatusTeapot, w.Code)
+	}
+}
+
+func TestHandleResponseCode(t *testing.T) {
+	h := testHandler(&fo.Options{Percent: 100, ResponseCode: http.StatusServiceUnavailable,
+		ResponseBody: "injected", MatchHeaders: map[string]string{"X-Test-Traffic": "true"}})
+
+	// requests without the match headers are not faulted
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK || w.Body.String() != testBody {
+		t.Errorf("expected unfaulted response got %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Test-Traffic", "true")
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusServiceUnavailable || w.Body.String() != "injected" ||
+		w.Header().Get(HeaderName) != FaultResponseCode {
+		t.Errorf("unexpected faulted response %d %s", w.Code, w.Body.String())
+	}
+
+	// a percent of 0 faults no requests
+	h = testHandler(&fo.Options{ResponseCode: http.StatusServiceUnavailable})
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, w.Code)
+	}
+}
+
+func TestHandleAbort(t *testing.T) {
+	h := testHandler(&fo.Options{Percent: 100, Abort: true})
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+	resp, err := http.Get(ts.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Error("expected error for aborted connection")
+	}
+
+	// a connection that can't be hijacked fails with a 502
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusBadGateway || w.Header().Get(HeaderName) != FaultAbort {
+		t.Errorf("expected %d got %d", http.StatusBadGateway, w.Code)
+	}
+}
+
+func TestHandleTruncate(t *testing.T) {
+	h := testHandler(&fo.Options{Percent: 100, TruncateBytes: 4})
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err == nil {
+		t.Error("expected error for truncated body")
+	}
+	if string(b) != "0123" {
+		t.Errorf("expected %s got %s", "0123", string(b))
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Body.String() != "0123" || w.Header().Get(HeaderName) != FaultTruncate {
+		t.Errorf("expected %s got %s", "0123", w.Body.String())
+	}
+
+	// bodies shorter than the truncation are not truncated
+	h = testHandler(&fo.Options{Percent: 100, TruncateBytes: 20})
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Body.String() != testBody {
+		t.Errorf("expected %s got %s", testBody, w.Body.String())
+	}
+}
+
+func TestHandleUpstreamDelay(t *testing.T) {
+	var d time.Duration
+	o := bo.New()
+	o.FaultInjection = &fo.Options{Percent: 100, UpstreamDelayMinMS: 10,
+		UpstreamDelayMaxMS: 20}
+	h := Handle(o, nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d = tctx.UpstreamDelay(r.Context())
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if d < 10*time.Millisecond || d >= 20*time.Millisecond {
+		t.Errorf("unexpected upstream delay %s", d)
+	}
+}
+
+func TestDelayUpstream(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if err := DelayUpstream(r, "test"); err != nil {
+		t.Error(err)
+	}
+	r = r.WithContext(tctx.WithUpstreamDelay(r.Context(), 10*time.Millisecond))
+	start := time.Now()
+	if err := DelayUpstream(r, "test"); err != nil {
+		t.Error(err)
+	}
+	if time.Since(start) < 10*time.Millisecond {
+		t.Error("expected upstream delay")
+	}
+	ctx, cancel := context.WithCancel(tctx.WithUpstreamDelay(context.Background(), time.Hour))
+	cancel()
+	if err := DelayUpstream(r.WithContext(ctx), "test"); err == nil ||
+		!strings.Contains(err.Error(), "canceled") {
+		t.Errorf("expected canceled error got %v", err)
+	}
+}
+
+func TestHandleInspection(t *testing.T) {
+	h := testHandler(&fo.Options{Percent: 100, ResponseCode: http.StatusTeapot})
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r = r.WithContext(tctx.WithCacheInspection(r.Context(), &inspect.Report{}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Header().Get(HeaderName) != "" {
+		t.Errorf("expected no fault for a cache inspection, got %d", w.Code)
+	}
+}
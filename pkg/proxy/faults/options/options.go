@@ -0,0 +1,138 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package options provides the configuration for fault injection
+package options
+
+import (
+	"errors"
+	"maps"
+	"net/http"
+	"slices"
+
+	"github.com/trickstercache/trickster/v2/pkg/util/yamlx"
+)
+
+// DefaultPercent is the default percentage of matching requests that are faulted
+const DefaultPercent = 100
+
+var (
+	// ErrNoFaults is returned when the options do not configure any fault
+	ErrNoFaults = errors.New("fault injection requires at least one fault")
+	// ErrInvalidPercent is returned when the faulted percentage is not between 0 and 100
+	ErrInvalidPercent = errors.New("fault injection percent must be between 0 and 100")
+	// ErrInvalidResponseCode is returned when the injected response code is not
+	// a valid HTTP status code
+	ErrInvalidResponseCode = errors.New("fault injection response_code must be between 200 and 599")
+	// ErrInvalidValues is returned when a byte count or delay is negative
+	ErrInvalidValues = errors.New("fault injection truncate_bytes and upstream delays must not be negative")
+	// ErrConflictingFaults is returned when more than one of response_code, abort
+	// and truncate_bytes are configured
+	ErrConflictingFaults = errors.New("fault injection response_code, abort and truncate_bytes are mutually exclusive")
+)
+
+// Options defines the configuration for injecting faults into requests
+type Options struct {
+	// Percent is the percentage of matching requests that are faulted
+	Percent float64 `json:"percent,omitempty"`
+	// MatchHeaders limits fault injection to requests that include all of these
+	// headers with the provided values. A value of "" or "*" matches any value
+	MatchHeaders map[string]string `json:"match_headers,omitempty"`
+	// ResponseCode, when > 0, responds to faulted requests with this status code
+	// instead of serving them
+	ResponseCode int `json:"response_code,omitempty"`
+	// ResponseBody is the response body used with ResponseCode
+	ResponseBody string `json:"response_body,omitempty"`
+	// Abort, when true, closes the client connection without a response
+	Abort bool `json:"abort,omitempty"`
+	// TruncateBytes, when > 0, closes the client connection after this many
+	// bytes of the response body are written
+	TruncateBytes int `json:"truncate_bytes,omitempty"`
+	// UpstreamDelayMinMS and UpstreamDelayMaxMS delay each request sent upstream
+	// for faulted requests, and thus only affect cache misses. When
+	// UpstreamDelayMaxMS > UpstreamDelayMinMS, the delay is a random duration
+	// between the two; otherwise it is UpstreamDelayMinMS
+	UpstreamDelayMinMS int `json:"upstream_delay_min_ms,omitempty"`
+	UpstreamDelayMaxMS int `json:"upstream_delay_max_ms,omitempty"`
+}
+
+// New returns a New Options object with the default values
+func New() *Options {
+	return &Options{Percent: DefaultPercent}
+}
+
+// Clone returns a perfect copy of the Options
+func (o *Options) Clone() *Options {
+	c := *o
+	c.MatchHeaders = maps.Clone(o.MatchHeaders)
+	return &c
+}
+
+// Validate returns an error if the Options are invalid
+func (o *Options) Validate() error {
+	if o.Percent < 0 || o.Percent > 100 {
+		return ErrInvalidPercent
+	}
+	if o.ResponseCode != 0 && (o.ResponseCode < 200 || o.ResponseCode > 599) {
+		return ErrInvalidResponseCode
+	}
+	if o.TruncateBytes < 0 || o.UpstreamDelayMinMS < 0 || o.UpstreamDelayMaxMS < 0 {
+		return ErrInvalidValues
+	}
+	var n int
+	for _, b := range []bool{o.ResponseCode > 0, o.Abort, o.TruncateBytes > 0} {
+		if b {
+			n++
+		}
+	}
+	if n > 1 {
+		return ErrConflictingFaults
+	}
+	if n == 0 && o.UpstreamDelayMinMS == 0 && o.UpstreamDelayMaxMS == 0 {
+		return ErrNoFaults
+	}
+	return nil
+}
+
+// Matches returns true if the request includes all of the MatchHeaders
+func (o *Options) Matches(r *http.Request) bool {
+	for k, v := range o.MatchHeaders {
+		vals := r.Header.Values(k)
+		if len(vals) == 0 {
+			return false
+		}
+		if v != "" && v != "*" && !slices.Contains(vals, v) {
+			return false
+		}
+	}
+	return true
+}
+
+// SetDefaults overlays the options defined in the yaml metadata at the provided
+// key path (e.g., backends, name, fault_injection) onto the default Options. It
+// returns nil if no fault injection options are defined at the key path
+func SetDefaults(options *Options, metadata yamlx.KeyLookup,
+	keyPath ...string,
+) (*Options, error) {
+	if metadata == nil || options == nil || !metadata.IsDefined(keyPath...) {
+		return nil, nil
+	}
+	o := options.Clone()
+	if !metadata.IsDefined(append(slices.Clone(keyPath), "percent")...) {
+		o.Percent = DefaultPercent
+	}
+	return o, nil
+}
@@ -0,0 +1,151 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/trickstercache/trickster/v2/pkg/util/yamlx"
+
+	"sigs.k8s.io/yaml"
+)
+
+type testOptions1 struct {
+	Backends map[string]*testOptions2 `json:"backends,omitempty"`
+}
+
+type testOptions2 struct {
+	FaultInjection *Options `json:"fault_injection,omitempty"`
+}
+
+func fromYAML(conf string) (*Options, yamlx.KeyLookup, error) {
+	to := &testOptions1{}
+	err := yaml.Unmarshal([]byte(conf), to)
+	if err != nil {
+		return nil, nil, err
+	}
+	md, err := yamlx.GetKeyList(conf)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, v := range to.Backends {
+		if v != nil && v.FaultInjection != nil {
+			return v.FaultInjection, md, nil
+		}
+	}
+	return &Options{}, md, nil
+}
+
+const testYAML = `
+backends:
+  test:
+    fault_injection:
+      response_code: 503
+      response_body: injected
+      upstream_delay_min_ms: 100
+      match_headers:
+        X-Test-Traffic: 'true'
+`
+
+func TestSetDefaults(t *testing.T) {
+	o, md, err := fromYAML(testYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o2, err := SetDefaults(o, md, "backends", "test", "fault_injection")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o2 == nil {
+		t.Fatal("expected non-nil options")
+	}
+	if o2.Percent != DefaultPercent || o2.ResponseCode != 503 ||
+		o2.ResponseBody != "injected" || o2.UpstreamDelayMinMS != 100 ||
+		o2.MatchHeaders["X-Test-Traffic"] != "true" {
+		t.Errorf("unexpected options %+v", o2)
+	}
+	if err = o2.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	o2, err = SetDefaults(o, md, "backends", "other", "fault_injection")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o2 != nil {
+		t.Error("expected nil options for undefined fault injection")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		o   *Options
+		err error
+	}{
+		{New(), ErrNoFaults},
+		{&Options{Percent: 101, Abort: true}, ErrInvalidPercent},
+		{&Options{Percent: 10, ResponseCode: 99}, ErrInvalidResponseCode},
+		{&Options{Percent: 10, ResponseCode: 600}, ErrInvalidResponseCode},
+		{&Options{Percent: 10, TruncateBytes: -1}, ErrInvalidValues},
+		{&Options{Percent: 10, UpstreamDelayMaxMS: -1}, ErrInvalidValues},
+		{&Options{Percent: 10, ResponseCode: 500, Abort: true}, ErrConflictingFaults},
+		{&Options{Percent: 10, Abort: true, TruncateBytes: 10}, ErrConflictingFaults},
+		{&Options{Percent: 10, ResponseCode: 500, UpstreamDelayMinMS: 10}, nil},
+		{&Options{Percent: 10, UpstreamDelayMaxMS: 10}, nil},
+		{&Options{Percent: 10, TruncateBytes: 10}, nil},
+	}
+	for i, test := range tests {
+		if err := test.o.Validate(); !errors.Is(err, test.err) {
+			t.Errorf("test %d: expected %v got %v", i, test.err, err)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	o := &Options{MatchHeaders: map[string]string{"x-test": "true", "X-Any": "*"}}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if o.Matches(r) {
+		t.Error("expected no match without headers")
+	}
+	r.Header.Set("X-Test", "true")
+	if o.Matches(r) {
+		t.Error("expected no match without all headers")
+	}
+	r.Header.Set("X-Any", "anything")
+	if !o.Matches(r) {
+		t.Error("expected match")
+	}
+	r.Header.Set("X-Test", "false")
+	if o.Matches(r) {
+		t.Error("expected no match for a different header value")
+	}
+	if !New().Matches(r) {
+		t.Error("expected match without match headers")
+	}
+}
+
+func TestClone(t *testing.T) {
+	o := &Options{Percent: 50, Abort: true, MatchHeaders: map[string]string{"a": "b"}}
+	o2 := o.Clone()
+	o2.MatchHeaders["a"] = "c"
+	if o.MatchHeaders["a"] != "b" || o2.Percent != 50 || !o2.Abort {
+		t.Errorf("unexpected clone %+v", o2)
+	}
+}
@@ -0,0 +1,131 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	"github.com/trickstercache/trickster/v2/pkg/backends"
+	"github.com/trickstercache/trickster/v2/pkg/cache"
+	"github.com/trickstercache/trickster/v2/pkg/cache/index"
+	"github.com/trickstercache/trickster/v2/pkg/cache/inspect"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+)
+
+// DefaultCacheKeysLimit is the number of keys listed by the cache keys handler
+// when no limit is provided
+const DefaultCacheKeysLimit = 100
+
+// CacheInspectHandleFunc responds with a report of how the backend named by the
+// "backend" query parameter would cache a request for the "url" query parameter,
+// and what is cached for it. The optional "method" parameter sets the inspected
+// request's method, and each "header" parameter ("Name: value") adds a header
+func CacheInspectHandleFunc(clients backends.Backends) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeCacheError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+		qp := r.URL.Query()
+		backendName, u := qp.Get("backend"), qp.Get("url")
+		if backendName == "" || u == "" {
+			writeCacheError(w, http.StatusBadRequest,
+				errors.New("the backend and url parameters are required"))
+			return
+		}
+		h := make(http.Header)
+		for _, v := range qp["header"] {
+			name, value, ok := strings.Cut(v, ":")
+			if !ok {
+				writeCacheError(w, http.StatusBadRequest,
+					errors.New("header parameters must be formatted as 'Name: value'"))
+				return
+			}
+			h.Add(textproto.TrimString(name), textproto.TrimString(value))
+		}
+		rpt, err := inspect.Request(clients, backendName, qp.Get("method"), u, h)
+		if err != nil {
+			code := http.StatusBadRequest
+			if errors.Is(err, inspect.ErrUnknownBackend) {
+				code = http.StatusNotFound
+			}
+			writeCacheError(w, code, err)
+			return
+		}
+		writeCacheJSON(w, rpt)
+	}
+}
+
+// CacheKeysHandleFunc responds with the keys of the cache named by the "cache" query
+// parameter, in the order provided by the "order" parameter (size, access_count,
+// last_access or eviction; default is size), limited to the "limit" parameter
+func CacheKeysHandleFunc(caches map[string]cache.Cache) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeCacheError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+		qp := r.URL.Query()
+		c, ok := caches[qp.Get("cache")]
+		if !ok {
+			writeCacheError(w, http.StatusNotFound, errors.New("unknown cache"))
+			return
+		}
+		order := qp.Get("order")
+		if order == "" {
+			order = index.OrderSize
+		}
+		limit := DefaultCacheKeysLimit
+		if v := qp.Get("limit"); v != "" {
+			var err error
+			if limit, err = strconv.Atoi(v); err != nil {
+				writeCacheError(w, http.StatusBadRequest, errors.New("invalid limit"))
+				return
+			}
+		}
+		keys, err := inspect.TopKeys(c, order, limit)
+		if err != nil {
+			writeCacheError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeCacheJSON(w, keys)
+	}
+}
+
+func writeCacheJSON(w http.ResponseWriter, v interface{}) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		writeCacheError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+	w.Header().Set(headers.NameCacheControl, headers.ValueNoCache)
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+func writeCacheError(w http.ResponseWriter, code int, err error) {
+	b, _ := json.Marshal(map[string]string{"error": err.Error()})
+	w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+	w.Header().Set(headers.NameCacheControl, headers.ValueNoCache)
+	w.WriteHeader(code)
+	w.Write(b)
+}
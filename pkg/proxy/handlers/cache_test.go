@@ -0,0 +1,114 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/backends"
+	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache"
+	"github.com/trickstercache/trickster/v2/pkg/cache/inspect"
+	"github.com/trickstercache/trickster/v2/pkg/cache/memory"
+)
+
+func TestCacheInspectHandler(t *testing.T) {
+	router := http.NewServeMux()
+	router.HandleFunc("/api/v1/query", func(w http.ResponseWriter, r *http.Request) {
+		if rpt := inspect.FromRequest(r); rpt != nil {
+			rpt.Engine = "test"
+			rpt.Key = r.Header.Get("X-Test")
+		}
+	})
+	o := bo.New()
+	o.CacheName = "default"
+	b, err := backends.New("prom", o, nil, router, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := CacheInspectHandleFunc(backends.Backends{"prom": b})
+
+	tests := []struct {
+		method, url string
+		code        int
+	}{
+		{http.MethodPost, "/?backend=prom&url=/api/v1/query", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/?backend=prom", http.StatusBadRequest},
+		{http.MethodGet, "/?backend=prom&url=/api/v1/query&header=invalid", http.StatusBadRequest},
+		{http.MethodGet, "/?backend=missing&url=/api/v1/query", http.StatusNotFound},
+		{http.MethodGet, "/?backend=prom&url=/api/v1/query&header=X-Test:+key", http.StatusOK},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest(test.method, "http://0/trickster/cache/inspect"+test.url, nil))
+		if w.Code != test.code {
+			t.Errorf("%s: expected %d got %d", test.url, test.code, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet,
+		"http://0/trickster/cache/inspect?backend=prom&url=/api/v1/query&header=X-Test:+key", nil))
+	rpt := &inspect.Report{}
+	if err = json.Unmarshal(w.Body.Bytes(), rpt); err != nil {
+		t.Fatal(err)
+	}
+	if rpt.Engine != "test" || rpt.Key != "key" {
+		t.Errorf("unexpected report %+v", rpt)
+	}
+}
+
+func TestCacheKeysHandler(t *testing.T) {
+	c, err := memory.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.Store("key1", []byte("value"), time.Minute)
+	c.Store("key2", []byte("value value"), time.Minute)
+	h := CacheKeysHandleFunc(map[string]cache.Cache{"default": c})
+
+	tests := []struct {
+		url  string
+		code int
+	}{
+		{"/?cache=missing", http.StatusNotFound},
+		{"/?cache=default&order=invalid", http.StatusBadRequest},
+		{"/?cache=default&limit=invalid", http.StatusBadRequest},
+		{"/?cache=default&limit=1", http.StatusOK},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest(http.MethodGet, "http://0/trickster/cache/keys"+test.url, nil))
+		if w.Code != test.code {
+			t.Errorf("%s: expected %d got %d", test.url, test.code, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "http://0/trickster/cache/keys?cache=default", nil))
+	var keys []inspect.KeyInfo
+	if err = json.Unmarshal(w.Body.Bytes(), &keys); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0].Key != "key2" {
+		t.Errorf("unexpected keys %+v", keys)
+	}
+}
@@ -17,6 +17,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"sync"
 
@@ -47,6 +48,13 @@ func ReloadHandleFunc(f reload.ReloaderFunc, conf *config.Config, wg *sync.WaitG
 					w.Write([]byte("configuration reloaded"))
 					return
 				}
+				if errors.Is(err, reload.ErrReloadRolledBack) {
+					w.Header().Set(headers.NameContentType, headers.ValueTextPlain)
+					w.Header().Set(headers.NameCacheControl, headers.ValueNoCache)
+					w.WriteHeader(http.StatusOK)
+					w.Write([]byte(err.Error()))
+					return
+				}
 			}
 		}
 		w.Header().Set(headers.NameContentType, headers.ValueTextPlain)
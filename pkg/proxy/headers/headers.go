@@ -56,6 +56,8 @@ const (
 	ValueSharedMaxAge = "s-maxage"
 	// ValueTextPlain represents the HTTP Header Value of "text/plain"
 	ValueTextPlain = "text/plain"
+	// ValueWarningStale represents the HTTP Warning Header Value for a stale response
+	ValueWarningStale = `110 trickster "Response is Stale"`
 	// ValueXFormURLEncoded represents the HTTP Header Value of "application/x-www-form-urlencoded"
 	ValueXFormURLEncoded = "application/x-www-form-urlencoded"
 
@@ -88,6 +90,8 @@ const (
 	NameAcceptEncoding = "Accept-Encoding"
 	// NameSetCookie represents the HTTP Header Name of "Set-Cookie"
 	NameSetCookie = "Set-Cookie"
+	// NameCookie represents the HTTP Header Name of "Cookie"
+	NameCookie = "Cookie"
 	// NameRange represents the HTTP Header Name of "Range"
 	NameRange = "Range"
 	// NameTransferEncoding represents the HTTP Header Name of "Transfer-Encoding"
@@ -126,11 +130,15 @@ const (
 	NameTrailer = "Trailer"
 	// NameUpgrade represents the HTTP Header Name of "Upgrade"
 	NameUpgrade = "Upgrade"
+	// NameWarning represents the HTTP Header Name of "Warning"
+	NameWarning = "Warning"
 
 	// NameTrkHCStatus represents the HTTP Header Name of "Trk-HC-Status"
 	NameTrkHCStatus = "Trk-HC-Status"
 	// NameTrkHCDetail represents the HTTP Header Name of "Trk-HC-Detail"
 	NameTrkHCDetail = "Trk-HC-Detail"
+	// NameTrkCircuitBreaker represents the HTTP Header Name of "Trk-Circuit-Breaker"
+	NameTrkCircuitBreaker = "Trk-Circuit-Breaker"
 )
 
 // Lookup represents a simple lookup for internal header manipulation
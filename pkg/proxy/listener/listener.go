@@ -302,3 +302,28 @@ func (lg *ListenerGroup) UpdateRouter(routerName string, router http.Handler) {
 	}
 	defer lg.listenersLock.Unlock()
 }
+
+// Routers returns the current router of each Listener in the ListenerGroup, keyed by name
+func (lg *ListenerGroup) Routers() map[string]http.Handler {
+	lg.listenersLock.Lock()
+	defer lg.listenersLock.Unlock()
+	out := make(map[string]http.Handler, len(lg.members))
+	for k, v := range lg.members {
+		if v.routeSwapper != nil {
+			out[k] = v.routeSwapper.Handler()
+		}
+	}
+	return out
+}
+
+// RestoreRouters swaps the provided routers back into the Listeners of the same name, such as
+// when rolling back a configuration reload using routers previously collected with Routers()
+func (lg *ListenerGroup) RestoreRouters(routers map[string]http.Handler) {
+	lg.listenersLock.Lock()
+	defer lg.listenersLock.Unlock()
+	for k, v := range lg.members {
+		if r, ok := routers[k]; ok && r != nil && v.routeSwapper != nil {
+			v.routeSwapper.Update(r)
+		}
+	}
+}
@@ -105,6 +105,22 @@ func TestUpdateRouter(t *testing.T) {
 	}
 }
 
+func TestRestoreRouters(t *testing.T) {
+	testLG := NewListenerGroup()
+	r1 := http.NewServeMux()
+	r2 := http.NewServeMux()
+	testLG.members["test"] = &Listener{routeSwapper: ph.NewSwitchHandler(r1)}
+	routers := testLG.Routers()
+	if routers["test"] != r1 {
+		t.Error("router mismatch")
+	}
+	testLG.UpdateRouter("test", r2)
+	testLG.RestoreRouters(routers)
+	if testLG.members["test"].routeSwapper.Handler() != r1 {
+		t.Error("router mismatch")
+	}
+}
+
 func TestNewListenerErr(t *testing.T) {
 	config.NewConfig()
 	l, err := NewListener("-", 0, 0, nil, 0, tl.ConsoleLogger("error"))
@@ -35,6 +35,7 @@ const (
 const (
 	cacheableMethods   = get + head
 	bodyMethods        = post + put + patch
+	idempotentMethods  = get + head + put + delete + options + trace
 	uncacheableMethods = bodyMethods + delete + options + connect + trace + purge
 	allMethods         = cacheableMethods + uncacheableMethods
 )
@@ -101,6 +102,14 @@ func HasBody(method string) bool {
 	return false
 }
 
+// IsIdempotent returns true if the method is GET, HEAD, PUT, DELETE, OPTIONS or TRACE
+func IsIdempotent(method string) bool {
+	if m, ok := methodsMap[method]; ok {
+		return (idempotentMethods&m != 0)
+	}
+	return false
+}
+
 // MethodMask returns the integer representation of the collection of methods
 // based on the iota bitmask defined above
 func MethodMask(methods ...string) uint16 {
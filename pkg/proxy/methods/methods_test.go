@@ -77,6 +77,21 @@ func TestHasBody(t *testing.T) {
 	}
 }
 
+func TestIsIdempotent(t *testing.T) {
+	if !IsIdempotent(http.MethodGet) {
+		t.Error("expected true")
+	}
+	if !IsIdempotent(http.MethodPut) {
+		t.Error("expected true")
+	}
+	if IsIdempotent(http.MethodPost) {
+		t.Error("expected false")
+	}
+	if IsIdempotent("invalid_method") {
+		t.Error("expected false")
+	}
+}
+
 func TestMethodMask(t *testing.T) {
 	if v := MethodMask(http.MethodGet); v != 1 {
 		t.Errorf("expected 1 got %d", v)
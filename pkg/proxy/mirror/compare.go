@@ -0,0 +1,123 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mirror
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/trickstercache/trickster/v2/pkg/timeseries"
+	"github.com/trickstercache/trickster/v2/pkg/timeseries/dataset"
+)
+
+// Comparison results recorded by the mirror comparisons metric
+const (
+	ResultMatch          = "match"
+	ResultStatus         = "status"
+	ResultSeriesCount    = "series_count"
+	ResultTimestampCount = "timestamp_count"
+	ResultValueCount     = "value_count"
+	ResultValues         = "values"
+	ResultUnparseable    = "unparseable"
+)
+
+// compare parses the primary and mirrored responses with the primary backend's
+// Modeler and returns the comparison result, or an empty string when there is
+// nothing to compare (e.g., both backends returned the same error status)
+func (h *handler) compare(cr *http.Request, pw, mw *captureWriter) string {
+	if pw.statusCode() != mw.statusCode() {
+		return ResultStatus
+	}
+	if pw.statusCode() != http.StatusOK {
+		return ""
+	}
+	modeler := h.client.Modeler()
+	if modeler == nil || modeler.WireUnmarshalerReader == nil {
+		return ""
+	}
+	if pw.truncated || mw.truncated {
+		return ResultUnparseable
+	}
+	trq, _, _, err := h.client.ParseTimeRangeQuery(cr)
+	if err != nil || trq == nil {
+		// not a timeseries request
+		return ""
+	}
+	pts, err := modeler.WireUnmarshalerReader(decodedBody(pw), trq)
+	if err != nil || pts == nil {
+		return ResultUnparseable
+	}
+	mts, err := modeler.WireUnmarshalerReader(decodedBody(mw), trq)
+	if err != nil || mts == nil {
+		return ResultUnparseable
+	}
+	return diff(pts, mts)
+}
+
+// diff returns the first difference found between the two timeseries, or
+// ResultMatch if they are equivalent
+func diff(a, b timeseries.Timeseries) string {
+	switch {
+	case a.SeriesCount() != b.SeriesCount():
+		return ResultSeriesCount
+	case a.TimestampCount() != b.TimestampCount():
+		return ResultTimestampCount
+	case a.ValueCount() != b.ValueCount():
+		return ResultValueCount
+	}
+	da, ok := a.(*dataset.DataSet)
+	if !ok {
+		return ResultMatch
+	}
+	db, ok := b.(*dataset.DataSet)
+	if !ok {
+		return ResultMatch
+	}
+	if !maps.Equal(fingerprint(da), fingerprint(db)) {
+		return ResultValues
+	}
+	return ResultMatch
+}
+
+// fingerprint returns a map of each series in the dataset, keyed by its header
+// hash, to a string of its points, so datasets can be compared regardless of
+// the order of their series and points
+func fingerprint(ds *dataset.DataSet) map[dataset.SeriesLookupKey]string {
+	fp := make(map[dataset.SeriesLookupKey]string)
+	for _, r := range ds.Results {
+		if r == nil {
+			continue
+		}
+		for _, s := range r.SeriesList {
+			if s == nil {
+				continue
+			}
+			pts := s.Points.Clone()
+			sort.Sort(pts)
+			sb := strings.Builder{}
+			for _, p := range pts {
+				fmt.Fprintf(&sb, "%d:%v;", p.Epoch, p.Values)
+			}
+			fp[dataset.SeriesLookupKey{StatementID: r.StatementID,
+				Hash: s.Header.CalculateHash()}] = sb.String()
+		}
+	}
+	return fp
+}
@@ -0,0 +1,215 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package mirror duplicates a sample of requests to another backend, discarding
+// the mirrored responses, and optionally compares the primary and mirrored
+// timeseries to record any divergence between the two backends
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/backends"
+	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache/inspect"
+	"github.com/trickstercache/trickster/v2/pkg/encoding/providers"
+	"github.com/trickstercache/trickster/v2/pkg/observability/metrics"
+	tctx "github.com/trickstercache/trickster/v2/pkg/proxy/context"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+	mo "github.com/trickstercache/trickster/v2/pkg/proxy/mirror/options"
+	po "github.com/trickstercache/trickster/v2/pkg/proxy/paths/options"
+)
+
+const (
+	// maxBodySize is the largest request body that is mirrored
+	maxBodySize = 1 << 20
+	// maxCompareSize is the largest response body that is retained for comparison
+	maxCompareSize = 16 << 20
+)
+
+type handler struct {
+	backendName string
+	options     *mo.Options
+	client      backends.TimeseriesBackend
+	next        http.Handler
+}
+
+// Handle returns a handler that mirrors a sample of the requests it serves, per
+// the path's mirror options or, when the path has none, the backend's. If
+// neither is configured, next is returned as-is. Mirrored requests are issued in
+// the background, so the primary response is never delayed.
+func Handle(client backends.Backend, o *bo.Options, p *po.Options,
+	next http.Handler,
+) http.Handler {
+	if o == nil {
+		return next
+	}
+	m := o.Mirror
+	if p != nil && p.Mirror != nil {
+		m = p.Mirror
+	}
+	if m == nil {
+		return next
+	}
+	h := &handler{backendName: o.Name, options: m, next: next}
+	h.client, _ = client.(backends.TimeseriesBackend)
+	return h
+}
+
+// Resolve sets the mirror backend routers of all backends' mirror options; it
+// is called once the routes of all backends are registered
+func Resolve(clients backends.Backends) {
+	for _, c := range clients {
+		o := c.Configuration()
+		if o == nil {
+			continue
+		}
+		resolve(o.Mirror, clients)
+		for _, p := range o.Paths {
+			resolve(p.Mirror, clients)
+		}
+	}
+}
+
+func resolve(m *mo.Options, clients backends.Backends) {
+	if m == nil {
+		return
+	}
+	if c := clients.Get(m.Backend); c != nil {
+		m.Router = c.Router()
+	}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m := h.options
+	// mirrored, warming and cache inspection requests are never mirrored
+	if m.Router == nil || tctx.Mirror(r.Context()) != "" ||
+		tctx.Warmer(r.Context()) != "" || inspect.FromRequest(r) != nil ||
+		rand.Float64()*100 >= m.Percent {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	if !m.Acquire() {
+		h.skip("concurrency_limit")
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	body, ok := readBody(r)
+	if !ok {
+		m.Release()
+		h.skip("body_too_large")
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(tctx.WithMirror(context.Background(), h.backendName),
+		time.Duration(m.TimeoutMS)*time.Millisecond)
+	mr := cloneRequest(r, ctx, body)
+	// mirrored responses are not compressed, so they can be parsed for comparison
+	mr.Header.Del(headers.NameAcceptEncoding)
+
+	var cr *http.Request
+	var pw *captureWriter
+	var primary chan *captureWriter
+	if m.Compare && h.client != nil {
+		cr = cloneRequest(r, context.Background(), body)
+		pw = &captureWriter{ResponseWriter: w}
+		w = pw
+		primary = make(chan *captureWriter, 1)
+		defer func() { primary <- pw }()
+	}
+	go h.mirror(mr, cancel, cr, primary)
+	h.next.ServeHTTP(w, r)
+}
+
+func (h *handler) skip(reason string) {
+	metrics.MirrorSkipped.WithLabelValues(h.backendName, h.options.Backend, reason).Inc()
+}
+
+// mirror serves the mirrored request through the mirror backend's router and,
+// when comparing, waits for the primary response and compares the two
+func (h *handler) mirror(mr *http.Request, cancel context.CancelFunc,
+	cr *http.Request, primary <-chan *captureWriter,
+) {
+	defer h.options.Release()
+	defer cancel()
+	mw := &captureWriter{ResponseWriter: &discardWriter{h: make(http.Header)},
+		discard: primary == nil}
+	h.options.Router.ServeHTTP(mw, mr)
+	metrics.MirrorRequests.WithLabelValues(h.backendName, h.options.Backend,
+		strconv.Itoa(mw.statusCode())).Inc()
+	if primary == nil {
+		return
+	}
+	pw := <-primary
+	if result := h.compare(cr, pw, mw); result != "" {
+		metrics.MirrorComparisons.WithLabelValues(h.backendName, h.options.Backend,
+			result).Inc()
+	}
+}
+
+// readBody reads and replaces the request body so it can be sent to both
+// backends, returning false if the body is too large to mirror. Since a body
+// of unknown length (e.g., chunked) may be small enough, up to one byte more
+// than the limit is read to find out
+func readBody(r *http.Request) ([]byte, bool) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true
+	}
+	if r.ContentLength > maxBodySize {
+		return nil, false
+	}
+	b, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+	if err != nil || len(b) > maxBodySize {
+		// the primary request is sent the bytes already read, followed by the rest
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(b), r.Body), r.Body}
+		return nil, false
+	}
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(b))
+	return b, true
+}
+
+func cloneRequest(r *http.Request, ctx context.Context, body []byte) *http.Request {
+	c := r.Clone(ctx)
+	c.RequestURI = ""
+	if body != nil {
+		c.Body = io.NopCloser(bytes.NewReader(body))
+	} else {
+		c.Body = http.NoBody
+	}
+	return c
+}
+
+// decodedBody returns a reader of the captured body, decoded per its Content-Encoding
+func decodedBody(cw *captureWriter) io.Reader {
+	var reader io.Reader = bytes.NewReader(cw.buf.Bytes())
+	if ce := cw.encoding; ce != "" {
+		if decoderInit := providers.GetDecoderInitializer(ce); decoderInit != nil {
+			reader = decoderInit(io.NopCloser(reader))
+		}
+	}
+	return reader
+}
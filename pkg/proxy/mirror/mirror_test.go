@@ -0,0 +1,253 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mirror
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
+	"github.com/trickstercache/trickster/v2/pkg/backends/prometheus"
+	"github.com/trickstercache/trickster/v2/pkg/cache/inspect"
+	"github.com/trickstercache/trickster/v2/pkg/observability/metrics"
+	tctx "github.com/trickstercache/trickster/v2/pkg/proxy/context"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+	mo "github.com/trickstercache/trickster/v2/pkg/proxy/mirror/options"
+	po "github.com/trickstercache/trickster/v2/pkg/proxy/paths/options"
+)
+
+const testQuery = "/api/v1/query_range?query=up&start=1700000000&end=1700000060&step=60"
+
+const testMatrix = `{"status":"success","data":{"resultType":"matrix","result":[` +
+	`{"metric":{"__name__":"up","job":"a"},"values":[[1700000000,"1"],[1700000060,"%s"]]}]}}`
+
+func testMirrorOptions(t *testing.T, backend string, router http.Handler) *mo.Options {
+	t.Helper()
+	m := mo.New()
+	m.Backend = backend
+	m.Router = router
+	if err := m.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func testBackendOptions(name string, m *mo.Options) *bo.Options {
+	o := bo.New()
+	o.Name = name
+	o.Provider = "prometheus"
+	o.Mirror = m
+	return o
+}
+
+// waitForMetric polls the metrics handler until the provided metric line is
+// present, as mirrored requests are served in the background
+func waitForMetric(t *testing.T, s string) {
+	t.Helper()
+	var body string
+	for range 200 {
+		w := httptest.NewRecorder()
+		metrics.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		body = w.Body.String()
+		if strings.Contains(body, s) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("expected metric %s", s)
+}
+
+func TestHandleNoMirror(t *testing.T) {
+	next := http.NotFoundHandler()
+	o := testBackendOptions("test-none", nil)
+	if _, ok := Handle(nil, o, &po.Options{}, next).(*handler); ok {
+		t.Error("expected next handler")
+	}
+	if _, ok := Handle(nil, nil, nil, next).(*handler); ok {
+		t.Error("expected next handler")
+	}
+}
+
+func TestHandleMirrors(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	bodies := make(chan string, 1)
+	mirrorRouter := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies <- string(b)
+		received <- r
+		w.WriteHeader(http.StatusAccepted)
+	})
+	var primaryBody string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		primaryBody = string(b)
+		w.Write([]byte("primary"))
+	})
+	o := testBackendOptions("test-mirrors", testMirrorOptions(t, "shadow", mirrorRouter))
+	h := Handle(nil, o, &po.Options{}, next)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/query", strings.NewReader("query=up"))
+	h.ServeHTTP(w, r)
+	if w.Body.String() != "primary" || primaryBody != "query=up" {
+		t.Errorf("unexpected primary response %s / request body %s", w.Body.String(), primaryBody)
+	}
+	select {
+	case mr := <-received:
+		if b := <-bodies; b != "query=up" {
+			t.Errorf("expected mirrored body query=up got %s", b)
+		}
+		if tctx.Mirror(mr.Context()) != "test-mirrors" {
+			t.Error("expected mirrored request context")
+		}
+		if mr.URL.Path != "/api/v1/query" {
+			t.Errorf("unexpected mirrored path %s", mr.URL.Path)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected mirrored request")
+	}
+	waitForMetric(t, `trickster_mirror_requests_total{backend_name="test-mirrors",http_status="202",mirror_backend="shadow"} 1`)
+}
+
+func TestHandleSkips(t *testing.T) {
+	mirrored := make(chan struct{}, 10)
+	block := make(chan struct{})
+	mirrorRouter := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mirrored <- struct{}{}
+		<-block
+	})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	m := testMirrorOptions(t, "shadow", mirrorRouter)
+	m.Percent = 0
+	h := Handle(nil, testBackendOptions("test-skips", m), nil, next)
+
+	// unsampled
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	m.Percent = 100
+	// already a mirrored request
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	h.ServeHTTP(httptest.NewRecorder(), r.WithContext(tctx.WithMirror(r.Context(), "other")))
+	// a cache inspection
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	h.ServeHTTP(httptest.NewRecorder(), r.WithContext(tctx.WithCacheInspection(r.Context(),
+		&inspect.Report{})))
+	// body too large
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("a", maxBodySize+1)))
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	select {
+	case <-mirrored:
+		t.Fatal("expected no mirrored request")
+	case <-time.After(50 * time.Millisecond):
+	}
+	waitForMetric(t, `trickster_mirror_skipped_total{backend_name="test-skips",mirror_backend="shadow",reason="body_too_large"} 1`)
+
+	// concurrency limit
+	for range m.MaxConcurrent + 1 {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	close(block)
+	waitForMetric(t, `trickster_mirror_skipped_total{backend_name="test-skips",mirror_backend="shadow",reason="concurrency_limit"} 1`)
+	if len(mirrored) > m.MaxConcurrent {
+		t.Errorf("expected at most %d mirrored requests got %d", m.MaxConcurrent, len(mirrored))
+	}
+}
+
+func TestHandleCompare(t *testing.T) {
+	tests := []struct {
+		name         string
+		primary      string
+		mirror       string
+		mirrorStatus int
+		result       string
+	}{
+		{"test-compare-match", "1", "1", http.StatusOK, ResultMatch},
+		{"test-compare-values", "1", "0", http.StatusOK, ResultValues},
+		{"test-compare-status", "1", "1", http.StatusBadGateway, ResultStatus},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mirrorRouter := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.mirrorStatus)
+				w.Write([]byte(strings.Replace(testMatrix, "%s", test.mirror, 1)))
+			})
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(strings.Replace(testMatrix, "%s", test.primary, 1)))
+			})
+			m := testMirrorOptions(t, "shadow", mirrorRouter)
+			m.Compare = true
+			o := testBackendOptions(test.name, m)
+			client, err := prometheus.NewClient(test.name, o, nil, nil, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			h := Handle(client, o, nil, next)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, testQuery, nil))
+			if w.Code != http.StatusOK {
+				t.Errorf("expected %d got %d", http.StatusOK, w.Code)
+			}
+			waitForMetric(t, `trickster_mirror_comparisons_total{backend_name="`+
+				test.name+`",mirror_backend="shadow",result="`+test.result+`"} 1`)
+		})
+	}
+}
+
+func TestReadBody(t *testing.T) {
+	// a small body of unknown length is mirrored
+	r := httptest.NewRequest(http.MethodPost, "/", io.NopCloser(strings.NewReader("body")))
+	r.ContentLength = -1
+	b, ok := readBody(r)
+	if !ok || string(b) != "body" {
+		t.Errorf("unexpected body %s %t", string(b), ok)
+	}
+	if rb, _ := io.ReadAll(r.Body); string(rb) != "body" {
+		t.Errorf("unexpected request body %s", string(rb))
+	}
+
+	// a large body of unknown length is not mirrored, but is sent intact
+	large := strings.Repeat("a", maxBodySize+10)
+	r = httptest.NewRequest(http.MethodPost, "/", io.NopCloser(strings.NewReader(large)))
+	r.ContentLength = -1
+	if b, ok = readBody(r); ok || b != nil {
+		t.Error("expected body too large")
+	}
+	if rb, _ := io.ReadAll(r.Body); string(rb) != large {
+		t.Errorf("expected %d bytes got %d", len(large), len(rb))
+	}
+}
+
+func TestCaptureWriterEncoding(t *testing.T) {
+	w := httptest.NewRecorder()
+	cw := &captureWriter{ResponseWriter: w}
+	w.Header().Set(headers.NameContentEncoding, "gzip")
+	cw.WriteHeader(http.StatusAccepted)
+	// later changes to the headers do not affect the captured values
+	w.Header().Del(headers.NameContentEncoding)
+	cw.WriteHeader(http.StatusOK)
+	if cw.statusCode() != http.StatusAccepted || cw.encoding != "gzip" {
+		t.Errorf("unexpected capture %d %s", cw.statusCode(), cw.encoding)
+	}
+	cw = &captureWriter{ResponseWriter: httptest.NewRecorder()}
+	cw.Write([]byte("data"))
+	if cw.statusCode() != http.StatusOK || cw.encoding != "" || cw.buf.String() != "data" {
+		t.Errorf("unexpected capture %d %s %s", cw.statusCode(), cw.encoding, cw.buf.String())
+	}
+}
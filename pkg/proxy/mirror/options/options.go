@@ -0,0 +1,162 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package options provides the configuration for request mirroring
+package options
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/trickstercache/trickster/v2/pkg/util/yamlx"
+)
+
+const (
+	// DefaultPercent is the default percentage of requests that are mirrored
+	DefaultPercent = 100
+	// DefaultTimeoutMS is the default amount of time a mirrored request may take
+	DefaultTimeoutMS = 30000
+	// DefaultMaxConcurrent is the default maximum number of mirrored requests in flight
+	DefaultMaxConcurrent = 16
+)
+
+var (
+	// ErrMissingBackend is returned when the mirror backend name is not provided
+	ErrMissingBackend = errors.New("mirror backend name is required")
+	// ErrInvalidBackend is returned when the mirror backend is not a defined backend,
+	// or is the mirrored backend itself
+	ErrInvalidBackend = errors.New("invalid mirror backend name")
+	// ErrInvalidPercent is returned when the mirrored percentage is not between 0 and 100
+	ErrInvalidPercent = errors.New("mirror percent must be between 0 and 100")
+	// ErrInvalidLimits is returned when the timeout or concurrency limit is not positive
+	ErrInvalidLimits = errors.New("mirror timeout_ms and max_concurrent must be greater than 0")
+)
+
+// Options defines the configuration for mirroring requests to another backend
+type Options struct {
+	// Backend is the name of the backend to which requests are mirrored
+	Backend string `json:"backend,omitempty"`
+	// Percent is the percentage of requests that are mirrored
+	Percent float64 `json:"percent,omitempty"`
+	// Compare, when true, parses the primary and mirrored timeseries responses
+	// and records whether they diverge
+	Compare bool `json:"compare,omitempty"`
+	// TimeoutMS is the amount of time a mirrored request may take
+	TimeoutMS int `json:"timeout_ms,omitempty"`
+	// MaxConcurrent is the maximum number of mirrored requests in flight; requests
+	// sampled while the limit is reached are not mirrored
+	MaxConcurrent int `json:"max_concurrent,omitempty"`
+
+	// Router is the request router of the mirror backend, which is set once
+	// all backends' routes are registered
+	Router http.Handler `json:"-"`
+
+	limiter chan struct{}
+}
+
+// New returns a New Options object with the default values
+func New() *Options {
+	return &Options{
+		Percent:       DefaultPercent,
+		TimeoutMS:     DefaultTimeoutMS,
+		MaxConcurrent: DefaultMaxConcurrent,
+	}
+}
+
+// Clone returns a perfect copy of the Options, with its own concurrency limiter
+func (o *Options) Clone() *Options {
+	c := &Options{
+		Backend:       o.Backend,
+		Percent:       o.Percent,
+		Compare:       o.Compare,
+		TimeoutMS:     o.TimeoutMS,
+		MaxConcurrent: o.MaxConcurrent,
+		Router:        o.Router,
+	}
+	if o.limiter != nil {
+		c.limiter = make(chan struct{}, c.MaxConcurrent)
+	}
+	return c
+}
+
+// Validate returns an error if the Options are invalid, and otherwise sets up
+// the concurrency limiter
+func (o *Options) Validate() error {
+	if o.Backend == "" {
+		return ErrMissingBackend
+	}
+	if o.Percent < 0 || o.Percent > 100 {
+		return ErrInvalidPercent
+	}
+	if o.TimeoutMS <= 0 || o.MaxConcurrent <= 0 {
+		return ErrInvalidLimits
+	}
+	o.limiter = make(chan struct{}, o.MaxConcurrent)
+	return nil
+}
+
+// Acquire reserves a slot for a mirrored request, returning false if the
+// concurrency limit is reached. Each successful Acquire must be Released
+func (o *Options) Acquire() bool {
+	if o.limiter == nil {
+		return false
+	}
+	select {
+	case o.limiter <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Release frees a slot reserved by Acquire
+func (o *Options) Release() {
+	<-o.limiter
+}
+
+// SetDefaults overlays the options defined in the yaml metadata at the provided
+// key path (e.g., backends, name, mirror) onto the default Options. It returns
+// nil if no mirror options are defined at the key path
+func SetDefaults(options *Options, metadata yamlx.KeyLookup,
+	keyPath ...string,
+) (*Options, error) {
+	if metadata == nil || options == nil || !metadata.IsDefined(keyPath...) {
+		return nil, nil
+	}
+
+	isDefined := func(key string) bool {
+		return metadata.IsDefined(append(slices.Clone(keyPath), key)...)
+	}
+
+	o := New()
+	o.Backend = options.Backend
+	o.Compare = options.Compare
+
+	if isDefined("percent") {
+		o.Percent = options.Percent
+	}
+
+	if isDefined("timeout_ms") {
+		o.TimeoutMS = options.TimeoutMS
+	}
+
+	if isDefined("max_concurrent") {
+		o.MaxConcurrent = options.MaxConcurrent
+	}
+
+	return o, nil
+}
@@ -0,0 +1,153 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/trickstercache/trickster/v2/pkg/util/yamlx"
+
+	"sigs.k8s.io/yaml"
+)
+
+type testOptions1 struct {
+	Backends map[string]*testOptions2 `json:"backends,omitempty"`
+}
+
+type testOptions2 struct {
+	Mirror *Options `json:"mirror,omitempty"`
+}
+
+func fromYAML(conf string) (*Options, yamlx.KeyLookup, error) {
+	to := &testOptions1{}
+	err := yaml.Unmarshal([]byte(conf), to)
+	if err != nil {
+		return nil, nil, err
+	}
+	md, err := yamlx.GetKeyList(conf)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, v := range to.Backends {
+		if v != nil && v.Mirror != nil {
+			return v.Mirror, md, nil
+		}
+	}
+	return &Options{}, md, nil
+}
+
+const testYAML = `
+backends:
+  test:
+    mirror:
+      backend: shadow
+      percent: 0
+      compare: true
+      max_concurrent: 4
+`
+
+func TestSetDefaults(t *testing.T) {
+	o, md, err := fromYAML(testYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o2, err := SetDefaults(o, md, "backends", "test", "mirror")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o2 == nil {
+		t.Fatal("expected non-nil options")
+	}
+	// an explicit percent of 0 must not be replaced by the default
+	if o2.Backend != "shadow" || o2.Percent != 0 || !o2.Compare ||
+		o2.MaxConcurrent != 4 || o2.TimeoutMS != DefaultTimeoutMS {
+		t.Errorf("unexpected options %+v", o2)
+	}
+
+	o2, err = SetDefaults(o, md, "backends", "other", "mirror")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o2 != nil {
+		t.Error("expected nil options for undefined mirror")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		o   *Options
+		err error
+	}{
+		{&Options{Percent: 10, TimeoutMS: 1, MaxConcurrent: 1}, ErrMissingBackend},
+		{&Options{Backend: "a", Percent: 101, TimeoutMS: 1, MaxConcurrent: 1}, ErrInvalidPercent},
+		{&Options{Backend: "a", Percent: -1, TimeoutMS: 1, MaxConcurrent: 1}, ErrInvalidPercent},
+		{&Options{Backend: "a", Percent: 10, MaxConcurrent: 1}, ErrInvalidLimits},
+		{&Options{Backend: "a", Percent: 10, TimeoutMS: 1}, ErrInvalidLimits},
+		{&Options{Backend: "a", Percent: 10, TimeoutMS: 1, MaxConcurrent: 1}, nil},
+	}
+	for _, test := range tests {
+		if err := test.o.Validate(); !errors.Is(err, test.err) {
+			t.Errorf("expected %v got %v", test.err, err)
+		}
+	}
+}
+
+func TestAcquire(t *testing.T) {
+	o := New()
+	if o.Acquire() {
+		t.Error("expected Acquire to fail before Validate")
+	}
+	o.Backend = "a"
+	o.MaxConcurrent = 2
+	if err := o.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if !o.Acquire() || !o.Acquire() {
+		t.Fatal("expected Acquire to succeed")
+	}
+	if o.Acquire() {
+		t.Error("expected Acquire to fail at the concurrency limit")
+	}
+	o.Release()
+	if !o.Acquire() {
+		t.Error("expected Acquire to succeed after Release")
+	}
+}
+
+func TestClone(t *testing.T) {
+	o := New()
+	o.Backend = "a"
+	o.Compare = true
+	o.Router = http.NotFoundHandler()
+	if err := o.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	o2 := o.Clone()
+	if o2.Backend != "a" || !o2.Compare || o2.Percent != DefaultPercent ||
+		o2.Router == nil {
+		t.Errorf("unexpected clone %+v", o2)
+	}
+	// the clone has its own limiter
+	for range o.MaxConcurrent {
+		o.Acquire()
+	}
+	if !o2.Acquire() {
+		t.Error("expected clone's Acquire to succeed")
+	}
+}
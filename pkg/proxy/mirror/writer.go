@@ -0,0 +1,92 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mirror
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+)
+
+// captureWriter passes writes through to the underlying ResponseWriter while
+// recording the status code, Content-Encoding and, unless discard is set, the
+// body. They are recorded as the response is written, since the underlying
+// ResponseWriter may not be used once its handler has returned
+type captureWriter struct {
+	http.ResponseWriter
+	code      int
+	encoding  string
+	buf       bytes.Buffer
+	discard   bool
+	truncated bool
+}
+
+// capture records the status code and Content-Encoding of the response
+func (cw *captureWriter) capture(code int) {
+	if cw.code == 0 {
+		cw.code = code
+		cw.encoding = cw.Header().Get(headers.NameContentEncoding)
+	}
+}
+
+func (cw *captureWriter) WriteHeader(code int) {
+	cw.capture(code)
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *captureWriter) Write(b []byte) (int, error) {
+	cw.capture(http.StatusOK)
+	if !cw.discard && !cw.truncated {
+		if cw.buf.Len()+len(b) > maxCompareSize {
+			cw.truncated = true
+			cw.buf.Reset()
+		} else {
+			cw.buf.Write(b)
+		}
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher
+func (cw *captureWriter) Flush() {
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *captureWriter) statusCode() int {
+	if cw.code == 0 {
+		return http.StatusOK
+	}
+	return cw.code
+}
+
+// discardWriter is an http.ResponseWriter that discards the response
+type discardWriter struct {
+	h http.Header
+}
+
+func (w *discardWriter) Header() http.Header {
+	return w.h
+}
+
+func (w *discardWriter) WriteHeader(int) {}
+
+func (w *discardWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
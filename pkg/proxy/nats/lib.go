@@ -25,18 +25,38 @@ var (
 )
 
 func InitNATS(o *no.Options) error {
+	prev, err := SwapNATS(o)
+	if prev != nil {
+		prev.Drain()
+	}
+	return err
+}
+
+// SwapNATS replaces the active connection with a new connection for the provided
+// options, and returns the previous connection without draining it, so that a
+// reload can drain it once committed, or reinstate it with RestoreNATS
+func SwapNATS(o *no.Options) (*nats.Conn, error) {
 	m.Lock()
 	defer m.Unlock()
-	if nc != nil {
-		old := nc
-		defer old.Drain()
-	}
+	prev := nc
+	nc = nil
 	if o == nil {
-		return nil
+		return prev, nil
 	}
 	var err error
 	nc, err = NewConnection(o)
-	return err
+	return prev, err
+}
+
+// RestoreNATS drains the active connection and reinstates the provided connection
+// that was returned by SwapNATS
+func RestoreNATS(prev *nats.Conn) {
+	m.Lock()
+	defer m.Unlock()
+	if nc != nil && nc != prev {
+		nc.Drain()
+	}
+	nc = prev
 }
 
 func Connection() (*nats.Conn, error) {
@@ -8,6 +8,10 @@ type Options struct {
 	PasswordPath string `json:"passwordPath,omitempty"`
 	// +optional
 	CredPath string `json:"credPath,omitempty"`
+	// InvalidationSubject is the subject on which cache invalidation events are
+	// exchanged with other Trickster instances. Invalidation is disabled when empty
+	// +optional
+	InvalidationSubject string `json:"invalidationSubject,omitempty"`
 }
 
 func (o *Options) Clone() *Options {
@@ -16,6 +20,8 @@ func (o *Options) Clone() *Options {
 		Username:     o.Username,
 		PasswordPath: o.PasswordPath,
 		CredPath:     o.CredPath,
+
+		InvalidationSubject: o.InvalidationSubject,
 	}
 }
 
@@ -23,8 +23,10 @@ import (
 	"strings"
 
 	"github.com/trickstercache/trickster/v2/pkg/cache/key"
+	fo "github.com/trickstercache/trickster/v2/pkg/proxy/faults/options"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/forwarding"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/methods"
+	mo "github.com/trickstercache/trickster/v2/pkg/proxy/mirror/options"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/paths/matching"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/request/rewriter"
 	"github.com/trickstercache/trickster/v2/pkg/util/copiers"
@@ -67,6 +69,10 @@ type Options struct {
 	ReqRewriterName string `json:"req_rewriter_name,omitempty"`
 	// NoMetrics, when set to true, disables metrics decoration for the path
 	NoMetrics bool `json:"no_metrics"`
+	// Mirror is the request mirroring policy for this path, which overrides the backend's
+	Mirror *mo.Options `json:"mirror,omitempty"`
+	// FaultInjection is the fault injection policy for this path, which overrides the backend's
+	FaultInjection *fo.Options `json:"fault_injection,omitempty"`
 
 	// Handler is the HTTP Handler represented by the Path's HandlerName
 	Handler http.Handler `json:"-"`
@@ -140,6 +146,12 @@ func (o *Options) Clone() *Options {
 		Custom:                  copiers.CopyStrings(o.Custom),
 		KeyHasher:               o.KeyHasher,
 	}
+	if o.Mirror != nil {
+		c.Mirror = o.Mirror.Clone()
+	}
+	if o.FaultInjection != nil {
+		c.FaultInjection = o.FaultInjection.Clone()
+	}
 	return c
 }
 
@@ -187,6 +199,10 @@ func (o *Options) Merge(o2 *Options) {
 		case "req_rewriter_name":
 			o.ReqRewriterName = o2.ReqRewriterName
 			o.ReqRewriter = o2.ReqRewriter
+		case "mirror":
+			o.Mirror = o2.Mirror
+		case "fault_injection":
+			o.FaultInjection = o2.FaultInjection
 		}
 	}
 	o.Custom = strutil.Unique(o.Custom)
@@ -196,7 +212,7 @@ var pathMembers = []string{
 	"path", "match_type", "handler", "methods", "cache_key_params",
 	"cache_key_headers", "default_ttl_ms", "request_headers", "response_headers",
 	"response_headers", "response_code", "response_body", "no_metrics", "collapsed_forwarding",
-	"req_rewriter_name",
+	"req_rewriter_name", "mirror", "fault_injection",
 }
 
 var errInvalidConfigMetadata = errors.New("invalid config metadata")
@@ -220,6 +236,22 @@ func SetDefaults(
 			}
 			p.ReqRewriter = ri
 		}
+		if metadata.IsDefined("backends", backendName, "paths", k, "mirror") {
+			m, err := mo.SetDefaults(p.Mirror, metadata,
+				"backends", backendName, "paths", k, "mirror")
+			if err != nil {
+				return err
+			}
+			p.Mirror = m
+		}
+		if metadata.IsDefined("backends", backendName, "paths", k, "fault_injection") {
+			f, err := fo.SetDefaults(p.FaultInjection, metadata,
+				"backends", backendName, "paths", k, "fault_injection")
+			if err != nil {
+				return err
+			}
+			p.FaultInjection = f
+		}
 		if len(p.Methods) == 0 {
 			p.Methods = []string{http.MethodGet, http.MethodHead}
 		}
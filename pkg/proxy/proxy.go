@@ -29,6 +29,7 @@ import (
 
 	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/nats"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/upstreamauth"
 
 	ktr "k8s.io/client-go/transport"
 	"kubeops.dev/cluster-connector/pkg/shared"
@@ -36,12 +37,24 @@ import (
 )
 
 // NewHTTPClient returns an HTTP client configured to the specifications of the
-// running Trickster config.
+// running Trickster config. When the backend has upstream auth options, the
+// client authenticates each request it sends.
 func NewHTTPClient(o *bo.Options) (*http.Client, error) {
 	if o == nil {
 		return nil, nil
 	}
+	c, err := newHTTPClient(o)
+	if err != nil || o.UpstreamAuth == nil {
+		return c, err
+	}
+	c.Transport, err = upstreamauth.NewTransport(o.UpstreamAuth, c.Transport)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
 
+func newHTTPClient(o *bo.Options) (*http.Client, error) {
 	if o.Transport != nil && o.Transport.Type == bo.NATSTransport {
 		tlsConfig := ktr.TLSConfig{
 			Insecure: o.TLS.InsecureSkipVerify,
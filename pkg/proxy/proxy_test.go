@@ -17,9 +17,14 @@
 package proxy
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
+	uao "github.com/trickstercache/trickster/v2/pkg/proxy/upstreamauth/options"
 	tlstest "github.com/trickstercache/trickster/v2/pkg/testutil/tls"
 )
 
@@ -97,3 +102,38 @@ func TestNewHTTPClient(t *testing.T) {
 		t.Errorf("failed to find any PEM data in key input for file %s", o.TLS.ClientKeyPath)
 	}
 }
+
+func TestNewHTTPClientUpstreamAuth(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDTEST")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	var auth string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+	}))
+	defer ts.Close()
+
+	o := bo.New()
+	o.UpstreamAuth = &uao.Options{Mode: uao.ModeSigV4,
+		SigV4: &uao.SigV4Options{Region: "us-east-1"}}
+	c, err := NewHTTPClient(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.Get(ts.URL + "/api/v1/query?query=up")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDTEST/") ||
+		!strings.Contains(auth, "/us-east-1/aps/aws4_request") {
+		t.Errorf("expected signed request got %s", auth)
+	}
+
+	// no credentials are available
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_ROLE_ARN", "")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "missing"))
+	if _, err = NewHTTPClient(o); err == nil {
+		t.Error("expected error for missing credentials")
+	}
+}
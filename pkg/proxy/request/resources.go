@@ -38,6 +38,7 @@ type Resources struct {
 	CacheConfig       *co.Options
 	NoLock            bool
 	CacheClient       cache.Cache
+	CacheKey          string
 	BackendClient     backends.Backend
 	AlternateCacheTTL time.Duration
 	TimeRangeQuery    *timeseries.TimeRangeQuery
@@ -62,6 +63,7 @@ func (r *Resources) Clone() *Resources {
 		CacheConfig:       r.CacheConfig,
 		NoLock:            r.NoLock,
 		CacheClient:       r.CacheClient,
+		CacheKey:          r.CacheKey,
 		BackendClient:     r.BackendClient,
 		AlternateCacheTTL: r.AlternateCacheTTL,
 		TimeRangeQuery:    r.TimeRangeQuery,
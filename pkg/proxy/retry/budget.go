@@ -0,0 +1,61 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package retry
+
+import "sync"
+
+// budgetBurst is the number of retries a budget holds in reserve, so that
+// backends with low request volumes are still able to retry
+const budgetBurst = 10.0
+
+// budget limits retries and hedged requests to a ratio of the request volume.
+// each request deposits the ratio into the budget, and each additional attempt
+// withdraws one whole token. A nil budget is unlimited
+type budget struct {
+	mtx    sync.Mutex
+	ratio  float64
+	tokens float64
+}
+
+func newBudget(ratio float64) *budget {
+	if ratio <= 0 {
+		return nil
+	}
+	return &budget{ratio: ratio, tokens: budgetBurst}
+}
+
+func (b *budget) deposit() {
+	if b == nil {
+		return
+	}
+	b.mtx.Lock()
+	b.tokens = min(b.tokens+b.ratio, budgetBurst)
+	b.mtx.Unlock()
+}
+
+func (b *budget) withdraw() bool {
+	if b == nil {
+		return true
+	}
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
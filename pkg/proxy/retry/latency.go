@@ -0,0 +1,62 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package retry
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// latencySamples is the number of recent upstream latencies retained
+	latencySamples = 256
+	// minLatencySamples is the number of latencies required before hedging
+	minLatencySamples = 20
+)
+
+// latencies is a ring of recent upstream response latencies
+type latencies struct {
+	mtx     sync.Mutex
+	samples [latencySamples]time.Duration
+	count   int
+	next    int
+}
+
+func (l *latencies) observe(d time.Duration) {
+	l.mtx.Lock()
+	l.samples[l.next] = d
+	l.next = (l.next + 1) % latencySamples
+	if l.count < latencySamples {
+		l.count++
+	}
+	l.mtx.Unlock()
+}
+
+// percentile returns the latency at percentile p (0 to 1) of the retained
+// samples, and false if there are not yet enough samples
+func (l *latencies) percentile(p float64) (time.Duration, bool) {
+	l.mtx.Lock()
+	if l.count < minLatencySamples {
+		l.mtx.Unlock()
+		return 0, false
+	}
+	s := slices.Clone(l.samples[:l.count])
+	l.mtx.Unlock()
+	slices.Sort(s)
+	return s[int(p*float64(len(s)-1))], true
+}
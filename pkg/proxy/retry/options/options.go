@@ -0,0 +1,182 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package options provides the upstream retry and hedging options for Backends
+package options
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/trickstercache/trickster/v2/pkg/util/yamlx"
+)
+
+// Options defines the upstream retry and hedging policy for a Backend
+type Options struct {
+	// MaxAttempts is the maximum number of upstream attempts made for a request,
+	// including the first. Values <= 1 disable retries
+	MaxAttempts int `json:"max_attempts,omitempty"`
+	// RetryableStatusCodes is the list of upstream response codes that are retried
+	RetryableStatusCodes []int `json:"retryable_status_codes,omitempty"`
+	// RetryNetworkErrors indicates whether attempts that fail without an upstream
+	// response (e.g., connection refused or reset) are retried
+	RetryNetworkErrors bool `json:"retry_network_errors,omitempty"`
+	// BackoffMS is the delay before the first retry
+	BackoffMS int `json:"backoff_ms,omitempty"`
+	// BackoffMultiplier is the factor by which the delay grows for each subsequent retry
+	BackoffMultiplier float64 `json:"backoff_multiplier,omitempty"`
+	// MaxBackoffMS caps the delay between retries
+	MaxBackoffMS int `json:"max_backoff_ms,omitempty"`
+	// Jitter is the portion (0 to 1) of each delay that is randomized
+	Jitter float64 `json:"jitter,omitempty"`
+	// BudgetRatio caps retries and hedged requests to this ratio of the Backend's
+	// request volume, so that retries can't amplify an upstream outage. 0 is unlimited
+	BudgetRatio float64 `json:"budget_ratio,omitempty"`
+	// HedgePercentile, when > 0, sends a second request when the first has not
+	// responded within this percentile (e.g., 0.95) of recent upstream latencies
+	HedgePercentile float64 `json:"hedge_percentile,omitempty"`
+	// HedgeMinDelayMS is the minimum delay before a hedged request is sent
+	HedgeMinDelayMS int `json:"hedge_min_delay_ms,omitempty"`
+}
+
+const (
+	// DefaultMaxAttempts is the default maximum number of upstream attempts
+	DefaultMaxAttempts = 3
+	// DefaultBackoffMS is the default delay before the first retry
+	DefaultBackoffMS = 25
+	// DefaultBackoffMultiplier is the default backoff growth factor
+	DefaultBackoffMultiplier = 2.0
+	// DefaultMaxBackoffMS is the default cap for the delay between retries
+	DefaultMaxBackoffMS = 1000
+	// DefaultJitter is the default portion of each delay that is randomized
+	DefaultJitter = 0.5
+	// DefaultBudgetRatio is the default ratio of request volume available for retries
+	DefaultBudgetRatio = 0.2
+	// DefaultHedgeMinDelayMS is the default minimum delay before a hedged request
+	DefaultHedgeMinDelayMS = 10
+)
+
+var (
+	// ErrInvalidBackoff is returned when the backoff options are out of range
+	ErrInvalidBackoff = errors.New("backoff_ms and max_backoff_ms must be >= 0, " +
+		"and backoff_multiplier must be >= 1")
+	// ErrInvalidJitter is returned when the jitter is not between 0 and 1
+	ErrInvalidJitter = errors.New("jitter must be between 0 and 1")
+	// ErrInvalidBudgetRatio is returned when the budget ratio is negative
+	ErrInvalidBudgetRatio = errors.New("budget_ratio must be >= 0")
+	// ErrInvalidHedgePercentile is returned when the hedge percentile is not between 0 and 1
+	ErrInvalidHedgePercentile = errors.New("hedge_percentile must be >= 0 and < 1")
+	// ErrInvalidStatusCode is returned when a retryable status code is not a valid HTTP status
+	ErrInvalidStatusCode = errors.New("retryable_status_codes must be between 100 and 599")
+)
+
+// New returns a New Options object with the default values
+func New() *Options {
+	return &Options{
+		MaxAttempts: DefaultMaxAttempts,
+		RetryableStatusCodes: []int{http.StatusBadGateway, http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout},
+		RetryNetworkErrors: true,
+		BackoffMS:          DefaultBackoffMS,
+		BackoffMultiplier:  DefaultBackoffMultiplier,
+		MaxBackoffMS:       DefaultMaxBackoffMS,
+		Jitter:             DefaultJitter,
+		BudgetRatio:        DefaultBudgetRatio,
+		HedgeMinDelayMS:    DefaultHedgeMinDelayMS,
+	}
+}
+
+// Clone returns a perfect copy of the Options
+func (o *Options) Clone() *Options {
+	no := *o
+	no.RetryableStatusCodes = slices.Clone(o.RetryableStatusCodes)
+	return &no
+}
+
+// Validate returns an error if the Options are invalid
+func (o *Options) Validate() error {
+	if o.BackoffMS < 0 || o.MaxBackoffMS < 0 || o.BackoffMultiplier < 1 {
+		return ErrInvalidBackoff
+	}
+	if o.Jitter < 0 || o.Jitter > 1 {
+		return ErrInvalidJitter
+	}
+	if o.BudgetRatio < 0 {
+		return ErrInvalidBudgetRatio
+	}
+	if o.HedgePercentile < 0 || o.HedgePercentile >= 1 {
+		return ErrInvalidHedgePercentile
+	}
+	for _, c := range o.RetryableStatusCodes {
+		if c < 100 || c > 599 {
+			return ErrInvalidStatusCode
+		}
+	}
+	return nil
+}
+
+// SetDefaults overlays the options defined in the yaml metadata for the named
+// Backend onto the default Options. It returns nil if no retry options are defined
+func SetDefaults(name string, options *Options, metadata yamlx.KeyLookup) (*Options, error) {
+	if metadata == nil || options == nil || !metadata.IsDefined("backends", name, "retry") {
+		return nil, nil
+	}
+
+	o := New()
+
+	if metadata.IsDefined("backends", name, "retry", "max_attempts") {
+		o.MaxAttempts = options.MaxAttempts
+	}
+
+	if metadata.IsDefined("backends", name, "retry", "retryable_status_codes") {
+		o.RetryableStatusCodes = slices.Clone(options.RetryableStatusCodes)
+	}
+
+	if metadata.IsDefined("backends", name, "retry", "retry_network_errors") {
+		o.RetryNetworkErrors = options.RetryNetworkErrors
+	}
+
+	if metadata.IsDefined("backends", name, "retry", "backoff_ms") {
+		o.BackoffMS = options.BackoffMS
+	}
+
+	if metadata.IsDefined("backends", name, "retry", "backoff_multiplier") {
+		o.BackoffMultiplier = options.BackoffMultiplier
+	}
+
+	if metadata.IsDefined("backends", name, "retry", "max_backoff_ms") {
+		o.MaxBackoffMS = options.MaxBackoffMS
+	}
+
+	if metadata.IsDefined("backends", name, "retry", "jitter") {
+		o.Jitter = options.Jitter
+	}
+
+	if metadata.IsDefined("backends", name, "retry", "budget_ratio") {
+		o.BudgetRatio = options.BudgetRatio
+	}
+
+	if metadata.IsDefined("backends", name, "retry", "hedge_percentile") {
+		o.HedgePercentile = options.HedgePercentile
+	}
+
+	if metadata.IsDefined("backends", name, "retry", "hedge_min_delay_ms") {
+		o.HedgeMinDelayMS = options.HedgeMinDelayMS
+	}
+
+	return o, nil
+}
@@ -0,0 +1,140 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"testing"
+
+	"github.com/trickstercache/trickster/v2/pkg/util/yamlx"
+
+	"sigs.k8s.io/yaml"
+)
+
+type testOptions1 struct {
+	Backends map[string]*testOptions2 `json:"backends,omitempty"`
+}
+
+type testOptions2 struct {
+	Retry *Options `json:"retry,omitempty"`
+}
+
+func fromYAML(conf string) (*Options, yamlx.KeyLookup, error) {
+	to := &testOptions1{}
+	err := yaml.Unmarshal([]byte(conf), to)
+	if err != nil {
+		return nil, nil, err
+	}
+	md, err := yamlx.GetKeyList(conf)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, v := range to.Backends {
+		if v != nil && v.Retry != nil {
+			return v.Retry, md, nil
+		}
+	}
+	return nil, md, nil
+}
+
+const testYAML = `
+backends:
+  test:
+    retry:
+      max_attempts: 5
+      retry_network_errors: false
+      retryable_status_codes: [ 503 ]
+      hedge_percentile: 0.9
+`
+
+const testYAMLNoRetry = `
+backends:
+  test:
+    provider: prometheus
+`
+
+func TestClone(t *testing.T) {
+	o := New()
+	co := o.Clone()
+	co.RetryableStatusCodes[0] = 500
+	if o.RetryableStatusCodes[0] == 500 {
+		t.Error("expected deep copy of status codes")
+	}
+	if co.MaxAttempts != o.MaxAttempts || co.BudgetRatio != o.BudgetRatio {
+		t.Error("clone mismatch")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		f        func(*Options)
+		expected error
+	}{
+		{func(*Options) {}, nil},
+		{func(o *Options) { o.BackoffMultiplier = 0.5 }, ErrInvalidBackoff},
+		{func(o *Options) { o.BackoffMS = -1 }, ErrInvalidBackoff},
+		{func(o *Options) { o.Jitter = 1.5 }, ErrInvalidJitter},
+		{func(o *Options) { o.BudgetRatio = -1 }, ErrInvalidBudgetRatio},
+		{func(o *Options) { o.HedgePercentile = 1 }, ErrInvalidHedgePercentile},
+		{func(o *Options) { o.RetryableStatusCodes = []int{600} }, ErrInvalidStatusCode},
+	}
+	for i, test := range tests {
+		o := New()
+		test.f(o)
+		if err := o.Validate(); err != test.expected {
+			t.Errorf("test %d: expected %v got %v", i, test.expected, err)
+		}
+	}
+}
+
+func TestSetDefaults(t *testing.T) {
+	o, err := SetDefaults("test", nil, nil)
+	if err != nil || o != nil {
+		t.Errorf("expected nil options and error, got %v %v", o, err)
+	}
+
+	o, md, err := fromYAML(testYAMLNoRetry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o, _ = SetDefaults("test", o, md); o != nil {
+		t.Error("expected nil options")
+	}
+
+	o, md, err = fromYAML(testYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err = SetDefaults("test", o, md)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.MaxAttempts != 5 {
+		t.Errorf("expected %d got %d", 5, o.MaxAttempts)
+	}
+	if o.RetryNetworkErrors {
+		t.Error("expected false")
+	}
+	if len(o.RetryableStatusCodes) != 1 || o.RetryableStatusCodes[0] != 503 {
+		t.Errorf("unexpected status codes %v", o.RetryableStatusCodes)
+	}
+	if o.HedgePercentile != 0.9 {
+		t.Errorf("expected %f got %f", 0.9, o.HedgePercentile)
+	}
+	if o.BackoffMS != DefaultBackoffMS || o.BudgetRatio != DefaultBudgetRatio {
+		t.Error("expected default backoff and budget")
+	}
+}
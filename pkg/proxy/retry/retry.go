@@ -0,0 +1,298 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package retry applies upstream retry, backoff and hedging policies to the
+// requests Trickster proxies to a Backend's origin
+package retry
+
+import (
+	"context"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/proxy/methods"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/retry/options"
+)
+
+// drainLimit is the most bytes read from a discarded response body, so that
+// its upstream connection can be reused
+const drainLimit = 64 * 1024
+
+// Attempt describes an additional upstream attempt made for a request
+type Attempt struct {
+	// Number is the 1-based count of this attempt for the request
+	Number int
+	// Hedged is true when the attempt was sent alongside a slow outstanding
+	// attempt, rather than after a failed one
+	Hedged bool
+	// StatusCode is the response code of the failed attempt being retried,
+	// or 0 for network errors and hedged attempts
+	StatusCode int
+	// Err is the error of the failed attempt being retried, if any
+	Err error
+	// Delay is the time waited before sending the attempt
+	Delay time.Duration
+}
+
+// Policy applies a Backend's retry options to its upstream requests. Since it
+// holds the retry budget and recent upstream latencies, a single Policy is
+// shared by all requests to the Backend
+type Policy struct {
+	options *options.Options
+	codes   map[int]struct{}
+	budget  *budget
+	latency *latencies
+}
+
+// New returns a new Policy for the provided Options, or nil if they are nil
+func New(o *options.Options) *Policy {
+	if o == nil {
+		return nil
+	}
+	codes := make(map[int]struct{}, len(o.RetryableStatusCodes))
+	for _, c := range o.RetryableStatusCodes {
+		codes[c] = struct{}{}
+	}
+	return &Policy{
+		options: o,
+		codes:   codes,
+		budget:  newBudget(o.BudgetRatio),
+		latency: &latencies{},
+	}
+}
+
+// Options returns the Options the Policy was created with
+func (p *Policy) Options() *options.Options {
+	return p.options
+}
+
+// Do sends the request using the client, retrying and hedging it according to
+// the Policy. When onAttempt is not nil, it is called before each additional
+// attempt is sent. Requests that are not idempotent, or whose bodies can't be
+// replayed, are sent exactly once
+func (p *Policy) Do(c *http.Client, r *http.Request,
+	onAttempt func(Attempt)) (*http.Response, error) {
+	if p == nil || !methods.IsIdempotent(r.Method) || !replayable(r) {
+		return c.Do(r)
+	}
+	p.budget.deposit()
+	attempts := 1
+	rq := r
+	for {
+		resp, err := p.send(c, rq, &attempts, onAttempt)
+		if attempts >= p.options.MaxAttempts || !p.retryable(r, resp, err) ||
+			!p.budget.withdraw() {
+			return resp, err
+		}
+		attempts++
+		a := Attempt{Number: attempts, Err: err, Delay: p.backoff(attempts - 1)}
+		if resp != nil {
+			a.StatusCode = resp.StatusCode
+		}
+		if !sleep(r.Context(), a.Delay) {
+			return resp, err
+		}
+		if resp != nil {
+			drain(resp)
+		}
+		if onAttempt != nil {
+			onAttempt(a)
+		}
+		if rq, err = clone(r); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *Policy) retryable(r *http.Request, resp *http.Response, err error) bool {
+	if r.Context().Err() != nil {
+		return false
+	}
+	if err != nil || resp == nil {
+		return p.options.RetryNetworkErrors
+	}
+	_, ok := p.codes[resp.StatusCode]
+	return ok
+}
+
+// backoff returns the delay before retry n, which grows exponentially from the
+// base delay up to the maximum, less a random portion determined by the jitter
+func (p *Policy) backoff(n int) time.Duration {
+	d := float64(p.options.BackoffMS) * math.Pow(p.options.BackoffMultiplier, float64(n-1))
+	d = min(d, float64(p.options.MaxBackoffMS))
+	d -= d * p.options.Jitter * rand.Float64()
+	return time.Duration(d * float64(time.Millisecond))
+}
+
+// hedgeDelay returns how long to wait on an attempt before hedging it, and
+// false if hedging is disabled or there are too few latency samples
+func (p *Policy) hedgeDelay() (time.Duration, bool) {
+	if p.options.HedgePercentile <= 0 {
+		return 0, false
+	}
+	d, ok := p.latency.percentile(p.options.HedgePercentile)
+	if !ok {
+		return 0, false
+	}
+	return max(d, time.Duration(p.options.HedgeMinDelayMS)*time.Millisecond), true
+}
+
+type result struct {
+	resp    *http.Response
+	err     error
+	index   int
+	elapsed time.Duration
+}
+
+func (p *Policy) do(c *http.Client, r *http.Request, index int, ch chan<- result) {
+	start := time.Now()
+	resp, err := c.Do(r)
+	ch <- result{resp: resp, err: err, index: index, elapsed: time.Since(start)}
+}
+
+// send makes one upstream attempt, hedging it with a second request when the
+// first has not responded within the hedge percentile of recent latencies
+func (p *Policy) send(c *http.Client, r *http.Request, attempts *int,
+	onAttempt func(Attempt)) (*http.Response, error) {
+	delay, ok := p.hedgeDelay()
+	if !ok {
+		start := time.Now()
+		resp, err := c.Do(r)
+		if err == nil {
+			p.latency.observe(time.Since(start))
+		}
+		return resp, err
+	}
+
+	results := make(chan result, 2)
+	ctx, cancel := context.WithCancel(r.Context())
+	cancels := []context.CancelFunc{cancel}
+	go p.do(c, r.WithContext(ctx), 0, results)
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case res := <-results:
+		return p.finish(res, cancels)
+	case <-t.C:
+	}
+
+	pending := 1
+	if p.budget.withdraw() {
+		if hr, err := clone(r); err == nil {
+			*attempts++
+			if onAttempt != nil {
+				onAttempt(Attempt{Number: *attempts, Hedged: true, Delay: delay})
+			}
+			ctx, cancel := context.WithCancel(r.Context())
+			cancels = append(cancels, cancel)
+			go p.do(c, hr.WithContext(ctx), 1, results)
+			pending++
+		}
+	}
+
+	// the first successful attempt wins the race
+	var res result
+	for pending > 0 {
+		res = <-results
+		pending--
+		if res.err == nil {
+			break
+		}
+	}
+	if pending > 0 {
+		// discard the response of the attempt that lost the race
+		go func(n int) {
+			for ; n > 0; n-- {
+				if lr := <-results; lr.resp != nil && lr.resp.Body != nil {
+					lr.resp.Body.Close()
+				}
+			}
+		}(pending)
+	}
+	return p.finish(res, cancels)
+}
+
+// finish cancels the attempts that did not win the race, and attaches the
+// winner's cancellation to its response body, so it is released on Close
+func (p *Policy) finish(res result, cancels []context.CancelFunc) (*http.Response, error) {
+	for i, cancel := range cancels {
+		if i != res.index || res.err != nil {
+			cancel()
+		}
+	}
+	if res.err != nil {
+		return res.resp, res.err
+	}
+	p.latency.observe(res.elapsed)
+	res.resp.Body = &cancelBody{ReadCloser: res.resp.Body, cancel: cancels[res.index]}
+	return res.resp, nil
+}
+
+// cancelBody releases the context of a hedged attempt when its body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// replayable returns true if the request body can be sent more than once
+func replayable(r *http.Request) bool {
+	return r.Body == nil || r.Body == http.NoBody || r.GetBody != nil
+}
+
+func clone(r *http.Request) (*http.Request, error) {
+	rq := r.Clone(r.Context())
+	if r.GetBody != nil {
+		b, err := r.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		rq.Body = b
+	}
+	return rq, nil
+}
+
+func drain(resp *http.Response) {
+	if resp.Body == nil {
+		return
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, drainLimit))
+	resp.Body.Close()
+}
+
+// sleep waits for the duration, returning false if the context ends first
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,291 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package retry
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/proxy/retry/options"
+)
+
+// failingServer returns a server that responds with code to the first n requests
+func failingServer(n int32, code int) (*httptest.Server, *int32) {
+	var count int32
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&count, 1) <= n {
+			w.WriteHeader(code)
+			return
+		}
+		w.Write([]byte("ok"))
+	})), &count
+}
+
+func testOptions() *options.Options {
+	o := options.New()
+	o.BackoffMS = 1
+	o.MaxBackoffMS = 5
+	return o
+}
+
+func TestNew(t *testing.T) {
+	if New(nil) != nil {
+		t.Error("expected nil policy")
+	}
+	o := testOptions()
+	p := New(o)
+	if p.Options() != o {
+		t.Error("options mismatch")
+	}
+	if _, ok := p.codes[http.StatusBadGateway]; !ok {
+		t.Error("expected retryable 502")
+	}
+}
+
+func TestDo(t *testing.T) {
+	ts, count := failingServer(2, http.StatusServiceUnavailable)
+	defer ts.Close()
+
+	var attempts []Attempt
+	p := New(testOptions())
+	r, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+	resp, err := p.Do(ts.Client(), r, func(a Attempt) { attempts = append(attempts, a) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, resp.StatusCode)
+	}
+	if *count != 3 {
+		t.Errorf("expected %d upstream requests got %d", 3, *count)
+	}
+	if len(attempts) != 2 || attempts[0].Number != 2 || attempts[1].Number != 3 ||
+		attempts[0].StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("unexpected attempts %v", attempts)
+	}
+}
+
+func TestDoMaxAttempts(t *testing.T) {
+	ts, count := failingServer(5, http.StatusBadGateway)
+	defer ts.Close()
+
+	p := New(testOptions())
+	r, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+	resp, err := p.Do(ts.Client(), r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("expected %d got %d", http.StatusBadGateway, resp.StatusCode)
+	}
+	if *count != options.DefaultMaxAttempts {
+		t.Errorf("expected %d upstream requests got %d", options.DefaultMaxAttempts, *count)
+	}
+}
+
+func TestDoNotRetried(t *testing.T) {
+	ts, count := failingServer(1, http.StatusBadGateway)
+	defer ts.Close()
+	p := New(testOptions())
+
+	// POST is not idempotent
+	r, _ := http.NewRequest(http.MethodPost, ts.URL, bytes.NewReader([]byte("body")))
+	resp, _ := p.Do(ts.Client(), r, nil)
+	resp.Body.Close()
+	if *count != 1 {
+		t.Errorf("expected %d upstream requests got %d", 1, *count)
+	}
+
+	// 400 is not a retryable status code
+	ts2, count2 := failingServer(1, http.StatusBadRequest)
+	defer ts2.Close()
+	r, _ = http.NewRequest(http.MethodGet, ts2.URL, nil)
+	resp, _ = p.Do(ts2.Client(), r, nil)
+	resp.Body.Close()
+	if *count2 != 1 {
+		t.Errorf("expected %d upstream requests got %d", 1, *count2)
+	}
+
+	// a nil policy sends the request once
+	ts3, count3 := failingServer(1, http.StatusBadGateway)
+	defer ts3.Close()
+	r, _ = http.NewRequest(http.MethodGet, ts3.URL, nil)
+	resp, _ = (*Policy)(nil).Do(ts3.Client(), r, nil)
+	resp.Body.Close()
+	if *count3 != 1 {
+		t.Errorf("expected %d upstream requests got %d", 1, *count3)
+	}
+}
+
+func TestDoNetworkError(t *testing.T) {
+	ts, _ := failingServer(0, 0)
+	u := ts.URL
+	ts.Close()
+
+	var attempts int
+	o := testOptions()
+	p := New(o)
+	r, _ := http.NewRequest(http.MethodGet, u, nil)
+	_, err := p.Do(http.DefaultClient, r, func(Attempt) { attempts++ })
+	if err == nil {
+		t.Error("expected error")
+	}
+	if attempts != o.MaxAttempts-1 {
+		t.Errorf("expected %d retries got %d", o.MaxAttempts-1, attempts)
+	}
+
+	o.RetryNetworkErrors = false
+	attempts = 0
+	p = New(o)
+	r, _ = http.NewRequest(http.MethodGet, u, nil)
+	p.Do(http.DefaultClient, r, func(Attempt) { attempts++ })
+	if attempts != 0 {
+		t.Errorf("expected %d retries got %d", 0, attempts)
+	}
+}
+
+func TestDoReplaysBody(t *testing.T) {
+	var bodies []string
+	var count int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		if atomic.AddInt32(&count, 1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer ts.Close()
+	p := New(testOptions())
+	r, _ := http.NewRequest(http.MethodPut, ts.URL, bytes.NewReader([]byte("body")))
+	resp, err := p.Do(ts.Client(), r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if len(bodies) != 2 || bodies[0] != "body" || bodies[1] != "body" {
+		t.Errorf("unexpected bodies %v", bodies)
+	}
+}
+
+func TestBudget(t *testing.T) {
+	if !newBudget(0).withdraw() {
+		t.Error("expected unlimited budget")
+	}
+	b := newBudget(0.5)
+	for i := 0; i < int(budgetBurst); i++ {
+		if !b.withdraw() {
+			t.Fatalf("expected withdrawal %d to succeed", i)
+		}
+	}
+	if b.withdraw() {
+		t.Error("expected exhausted budget")
+	}
+	b.deposit()
+	if b.withdraw() {
+		t.Error("expected exhausted budget")
+	}
+	b.deposit()
+	if !b.withdraw() {
+		t.Error("expected replenished budget")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	o := options.New()
+	o.Jitter = 0
+	p := New(o)
+	if d := p.backoff(1); d != 25*time.Millisecond {
+		t.Errorf("expected %s got %s", 25*time.Millisecond, d)
+	}
+	if d := p.backoff(3); d != 100*time.Millisecond {
+		t.Errorf("expected %s got %s", 100*time.Millisecond, d)
+	}
+	if d := p.backoff(20); d != time.Second {
+		t.Errorf("expected %s got %s", time.Second, d)
+	}
+	o.Jitter = 1
+	for i := 1; i < 10; i++ {
+		if d := p.backoff(i); d < 0 || d > time.Second {
+			t.Errorf("backoff %s out of range", d)
+		}
+	}
+}
+
+func TestLatencies(t *testing.T) {
+	l := &latencies{}
+	if _, ok := l.percentile(0.5); ok {
+		t.Error("expected too few samples")
+	}
+	for i := 1; i <= 300; i++ {
+		l.observe(time.Duration(i) * time.Millisecond)
+	}
+	d, ok := l.percentile(0.5)
+	if !ok {
+		t.Fatal("expected enough samples")
+	}
+	// only the most recent 256 samples (45ms - 300ms) are retained
+	if d != 172*time.Millisecond {
+		t.Errorf("expected %s got %s", 172*time.Millisecond, d)
+	}
+}
+
+func TestDoHedged(t *testing.T) {
+	var count int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&count, 1) == 1 {
+			select {
+			case <-time.After(2 * time.Second):
+			case <-r.Context().Done():
+			}
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	o := testOptions()
+	o.HedgePercentile = 0.9
+	p := New(o)
+	for range minLatencySamples {
+		p.latency.observe(time.Millisecond)
+	}
+
+	var attempts []Attempt
+	start := time.Now()
+	r, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+	resp, err := p.Do(ts.Client(), r, func(a Attempt) { attempts = append(attempts, a) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(b) != "ok" {
+		t.Errorf("expected %s got %s", "ok", string(b))
+	}
+	if time.Since(start) > time.Second {
+		t.Error("expected the hedged request to win")
+	}
+	if len(attempts) != 1 || !attempts[0].Hedged || attempts[0].Number != 2 {
+		t.Errorf("unexpected attempts %v", attempts)
+	}
+}
@@ -0,0 +1,271 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package options provides the configuration for authenticating requests to
+// a backend's origin
+package options
+
+import (
+	"errors"
+	"maps"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+)
+
+const (
+	// ModeSigV4 signs upstream requests with AWS Signature Version 4
+	ModeSigV4 = "sigv4"
+	// ModeOAuth2 authenticates upstream requests with a bearer token obtained
+	// with the OAuth2 client credentials grant
+	ModeOAuth2 = "oauth2"
+	// ModeBearer authenticates upstream requests with a static bearer token
+	ModeBearer = "bearer"
+)
+
+const (
+	// AuthStyleBasic sends the OAuth2 client credentials in an HTTP Basic
+	// Authorization header
+	AuthStyleBasic = "basic"
+	// AuthStyleParams sends the OAuth2 client credentials in the request body
+	AuthStyleParams = "params"
+)
+
+// DefaultOAuth2TimeoutMS is the default timeout of OAuth2 token requests
+const DefaultOAuth2TimeoutMS = 10000
+
+// redacted replaces credentials in Options that are exported to YAML
+const redacted = "*****"
+
+// DefaultSigV4Service is the default service name used in SigV4 signatures,
+// which is that of Amazon Managed Service for Prometheus
+const DefaultSigV4Service = "aps"
+
+// DefaultRoleSessionName is the default session name of assumed roles
+const DefaultRoleSessionName = "trickster"
+
+var (
+	// ErrInvalidMode is returned when the upstream auth mode is not supported
+	ErrInvalidMode = errors.New("invalid upstream_auth mode")
+	// ErrMissingRegion is returned when a SigV4 region is not provided, and is
+	// not available in the AWS_REGION or AWS_DEFAULT_REGION environment variables
+	ErrMissingRegion = errors.New("upstream_auth sigv4 region is required")
+	// ErrIncompleteWebIdentity is returned when only one of role_arn and
+	// web_identity_token_file is provided
+	ErrIncompleteWebIdentity = errors.New("upstream_auth sigv4 role_arn and " +
+		"web_identity_token_file must be provided together")
+	// ErrInvalidTokenURL is returned when the OAuth2 token URL is not an absolute URL
+	ErrInvalidTokenURL = errors.New("upstream_auth oauth2 token_url must be an absolute http or https URL")
+	// ErrInvalidClientCredentials is returned when exactly one of each of the
+	// OAuth2 client id and secret, and their files, is not provided
+	ErrInvalidClientCredentials = errors.New("upstream_auth oauth2 requires one of client_id " +
+		"or client_id_file, and one of client_secret or client_secret_file")
+	// ErrInvalidAuthStyle is returned when the OAuth2 auth style is not supported
+	ErrInvalidAuthStyle = errors.New("upstream_auth oauth2 auth_style must be basic or params")
+	// ErrInvalidBearerToken is returned when exactly one of the bearer token and
+	// its file is not provided
+	ErrInvalidBearerToken = errors.New("upstream_auth bearer requires one of token or token_file")
+)
+
+// Options defines how requests to a backend's origin are authenticated
+type Options struct {
+	// Mode is the authentication mode
+	Mode string `json:"mode,omitempty"`
+	// StripInboundAuthorization, when true, removes the Authorization header
+	// from requests received from clients, so that it is neither part of cache
+	// keys nor forwarded
+	StripInboundAuthorization bool `json:"strip_inbound_authorization,omitempty"`
+	// SigV4 is the configuration of the sigv4 mode
+	SigV4 *SigV4Options `json:"sigv4,omitempty"`
+	// OAuth2 is the configuration of the oauth2 mode
+	OAuth2 *OAuth2Options `json:"oauth2,omitempty"`
+	// Bearer is the configuration of the bearer mode
+	Bearer *BearerOptions `json:"bearer,omitempty"`
+}
+
+// SigV4Options defines the configuration for signing upstream requests with
+// AWS Signature Version 4. Credentials are read from the web identity token
+// file when RoleARN and WebIdentityTokenFile are provided, otherwise from the
+// shared credentials file when CredentialsFile or Profile are provided, and
+// otherwise from the environment, the web identity environment variables
+// (AWS_ROLE_ARN and AWS_WEB_IDENTITY_TOKEN_FILE) or the default shared
+// credentials file, in that order
+type SigV4Options struct {
+	// Region is the AWS region of the origin. Defaults to the AWS_REGION or
+	// AWS_DEFAULT_REGION environment variable
+	Region string `json:"region,omitempty"`
+	// Service is the AWS service name of the origin
+	Service string `json:"service,omitempty"`
+	// CredentialsFile is the path of the shared credentials file
+	CredentialsFile string `json:"credentials_file,omitempty"`
+	// Profile is the profile in the shared credentials file
+	Profile string `json:"profile,omitempty"`
+	// RoleARN is the role assumed with the token in WebIdentityTokenFile
+	RoleARN string `json:"role_arn,omitempty"`
+	// WebIdentityTokenFile is the path of the web identity token file
+	WebIdentityTokenFile string `json:"web_identity_token_file,omitempty"`
+	// RoleSessionName is the session name of the assumed role
+	RoleSessionName string `json:"role_session_name,omitempty"`
+	// STSEndpoint is the URL of the STS API used to assume the role. Defaults
+	// to the regional STS endpoint
+	STSEndpoint string `json:"sts_endpoint,omitempty"`
+}
+
+// OAuth2Options defines the configuration for obtaining bearer tokens with the
+// OAuth2 client credentials grant. Tokens are cached until shortly before they
+// expire, or until the origin rejects one
+type OAuth2Options struct {
+	// TokenURL is the URL of the authorization server's token endpoint
+	TokenURL string `json:"token_url,omitempty"`
+	// ClientID is the client identifier, or ClientIDFile is the path of a file
+	// containing it
+	ClientID     string `json:"client_id,omitempty"`
+	ClientIDFile string `json:"client_id_file,omitempty"`
+	// ClientSecret is the client secret, or ClientSecretFile is the path of a
+	// file containing it. The file is read again whenever it changes
+	ClientSecret     string `json:"client_secret,omitempty"`
+	ClientSecretFile string `json:"client_secret_file,omitempty"`
+	// Scopes are the scopes requested for the token
+	Scopes []string `json:"scopes,omitempty"`
+	// EndpointParams are additional parameters sent to the token endpoint,
+	// such as an audience
+	EndpointParams map[string]string `json:"endpoint_params,omitempty"`
+	// AuthStyle is how the client credentials are sent to the token endpoint:
+	// basic (an HTTP Basic Authorization header) or params (the request body)
+	AuthStyle string `json:"auth_style,omitempty"`
+	// TimeoutMS is the timeout of requests to the token endpoint
+	TimeoutMS int `json:"timeout_ms,omitempty"`
+}
+
+// BearerOptions defines the configuration for authenticating with a static
+// bearer token
+type BearerOptions struct {
+	// Token is the bearer token
+	Token string `json:"token,omitempty"`
+	// TokenFile is the path of a file containing the bearer token, which is
+	// read again whenever it changes
+	TokenFile string `json:"token_file,omitempty"`
+}
+
+// New returns a New Options object with the default values
+func New() *Options {
+	return &Options{}
+}
+
+// Clone returns a perfect copy of the Options
+func (o *Options) Clone() *Options {
+	c := *o
+	if o.SigV4 != nil {
+		s := *o.SigV4
+		c.SigV4 = &s
+	}
+	if o.OAuth2 != nil {
+		oa := *o.OAuth2
+		oa.Scopes = slices.Clone(o.OAuth2.Scopes)
+		oa.EndpointParams = maps.Clone(o.OAuth2.EndpointParams)
+		c.OAuth2 = &oa
+	}
+	if o.Bearer != nil {
+		b := *o.Bearer
+		c.Bearer = &b
+	}
+	return &c
+}
+
+// CloneYAMLSafe returns a copy of the Options that is safe to export to YAML
+// without exposing credentials
+func (o *Options) CloneYAMLSafe() *Options {
+	c := o.Clone()
+	if c.OAuth2 != nil && c.OAuth2.ClientSecret != "" {
+		c.OAuth2.ClientSecret = redacted
+	}
+	if c.Bearer != nil && c.Bearer.Token != "" {
+		c.Bearer.Token = redacted
+	}
+	return c
+}
+
+// Validate returns an error if the Options are invalid, and otherwise sets
+// the default values of the mode's options
+func (o *Options) Validate() error {
+	switch o.Mode {
+	case ModeSigV4:
+		if o.SigV4 == nil {
+			o.SigV4 = &SigV4Options{}
+		}
+		return o.SigV4.validate()
+	case ModeOAuth2:
+		if o.OAuth2 == nil {
+			o.OAuth2 = &OAuth2Options{}
+		}
+		return o.OAuth2.validate()
+	case ModeBearer:
+		if o.Bearer == nil || (o.Bearer.Token == "") == (o.Bearer.TokenFile == "") {
+			return ErrInvalidBearerToken
+		}
+		return nil
+	}
+	return ErrInvalidMode
+}
+
+func (o *OAuth2Options) validate() error {
+	u, err := url.Parse(o.TokenURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidTokenURL
+	}
+	if (o.ClientID == "") == (o.ClientIDFile == "") ||
+		(o.ClientSecret == "") == (o.ClientSecretFile == "") {
+		return ErrInvalidClientCredentials
+	}
+	o.AuthStyle = strings.ToLower(o.AuthStyle)
+	switch o.AuthStyle {
+	case "":
+		o.AuthStyle = AuthStyleBasic
+	case AuthStyleBasic, AuthStyleParams:
+	default:
+		return ErrInvalidAuthStyle
+	}
+	if o.TimeoutMS <= 0 {
+		o.TimeoutMS = DefaultOAuth2TimeoutMS
+	}
+	return nil
+}
+
+func (o *SigV4Options) validate() error {
+	if o.Region == "" {
+		o.Region = os.Getenv("AWS_REGION")
+	}
+	if o.Region == "" {
+		o.Region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if o.Region == "" {
+		return ErrMissingRegion
+	}
+	if o.Service == "" {
+		o.Service = DefaultSigV4Service
+	}
+	if (o.RoleARN == "") != (o.WebIdentityTokenFile == "") {
+		return ErrIncompleteWebIdentity
+	}
+	if o.RoleSessionName == "" {
+		o.RoleSessionName = DefaultRoleSessionName
+	}
+	if o.STSEndpoint == "" {
+		o.STSEndpoint = "https://sts." + o.Region + ".amazonaws.com"
+	}
+	return nil
+}
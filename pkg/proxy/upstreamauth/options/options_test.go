@@ -0,0 +1,118 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	if err := New().Validate(); !errors.Is(err, ErrInvalidMode) {
+		t.Errorf("expected %v got %v", ErrInvalidMode, err)
+	}
+	o := &Options{Mode: ModeSigV4}
+	if err := o.Validate(); !errors.Is(err, ErrMissingRegion) {
+		t.Errorf("expected %v got %v", ErrMissingRegion, err)
+	}
+	t.Setenv("AWS_DEFAULT_REGION", "us-west-2")
+	if err := o.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if o.SigV4.Region != "us-west-2" || o.SigV4.Service != DefaultSigV4Service ||
+		o.SigV4.RoleSessionName != DefaultRoleSessionName ||
+		o.SigV4.STSEndpoint != "https://sts.us-west-2.amazonaws.com" {
+		t.Errorf("unexpected options %+v", o.SigV4)
+	}
+	o = &Options{Mode: ModeSigV4, SigV4: &SigV4Options{Region: "eu-west-1", RoleARN: "arn"}}
+	if err := o.Validate(); !errors.Is(err, ErrIncompleteWebIdentity) {
+		t.Errorf("expected %v got %v", ErrIncompleteWebIdentity, err)
+	}
+}
+
+func TestClone(t *testing.T) {
+	o := &Options{Mode: ModeSigV4, SigV4: &SigV4Options{Region: "us-east-1"}}
+	o2 := o.Clone()
+	o2.SigV4.Region = "us-west-2"
+	if o2.Mode != ModeSigV4 || o.SigV4.Region != "us-east-1" {
+		t.Errorf("unexpected clone %+v", o2)
+	}
+}
+
+func TestValidateOAuth2(t *testing.T) {
+	tests := []struct {
+		o   *OAuth2Options
+		err error
+	}{
+		{nil, ErrInvalidTokenURL},
+		{&OAuth2Options{TokenURL: "/token", ClientID: "a", ClientSecret: "b"}, ErrInvalidTokenURL},
+		{&OAuth2Options{TokenURL: "https://auth/token", ClientSecret: "b"}, ErrInvalidClientCredentials},
+		{&OAuth2Options{TokenURL: "https://auth/token", ClientID: "a", ClientIDFile: "a",
+			ClientSecret: "b"}, ErrInvalidClientCredentials},
+		{&OAuth2Options{TokenURL: "https://auth/token", ClientID: "a"}, ErrInvalidClientCredentials},
+		{&OAuth2Options{TokenURL: "https://auth/token", ClientID: "a", ClientSecret: "b",
+			AuthStyle: "header"}, ErrInvalidAuthStyle},
+		{&OAuth2Options{TokenURL: "https://auth/token", ClientID: "a",
+			ClientSecretFile: "/secret", AuthStyle: "Params"}, nil},
+	}
+	for i, test := range tests {
+		o := &Options{Mode: ModeOAuth2, OAuth2: test.o}
+		if err := o.Validate(); !errors.Is(err, test.err) {
+			t.Errorf("test %d: expected %v got %v", i, test.err, err)
+		}
+	}
+	o := &Options{Mode: ModeOAuth2, OAuth2: &OAuth2Options{TokenURL: "https://auth/token",
+		ClientID: "a", ClientSecret: "b"}}
+	if err := o.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if o.OAuth2.AuthStyle != AuthStyleBasic || o.OAuth2.TimeoutMS != DefaultOAuth2TimeoutMS {
+		t.Errorf("unexpected options %+v", o.OAuth2)
+	}
+}
+
+func TestValidateBearer(t *testing.T) {
+	for i, b := range []*BearerOptions{nil, {}, {Token: "a", TokenFile: "b"}} {
+		o := &Options{Mode: ModeBearer, Bearer: b}
+		if err := o.Validate(); !errors.Is(err, ErrInvalidBearerToken) {
+			t.Errorf("test %d: expected %v got %v", i, ErrInvalidBearerToken, err)
+		}
+	}
+	o := &Options{Mode: ModeBearer, Bearer: &BearerOptions{TokenFile: "/token"}}
+	if err := o.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCloneYAMLSafe(t *testing.T) {
+	o := &Options{Mode: ModeOAuth2, OAuth2: &OAuth2Options{ClientID: "a", ClientSecret: "b",
+		Scopes: []string{"c"}}, Bearer: &BearerOptions{Token: "d"}}
+	c := o.CloneYAMLSafe()
+	if c.OAuth2.ClientSecret != redacted || c.Bearer.Token != redacted ||
+		c.OAuth2.ClientID != "a" {
+		t.Errorf("unexpected clone %+v", c)
+	}
+	if o.OAuth2.ClientSecret != "b" || o.Bearer.Token != "d" {
+		t.Error("expected the original options to be unmodified")
+	}
+	c.OAuth2.Scopes[0] = "e"
+	if o.OAuth2.Scopes[0] != "c" {
+		t.Error("expected scopes to be copied")
+	}
+}
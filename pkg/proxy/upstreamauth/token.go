@@ -0,0 +1,249 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package upstreamauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+	uao "github.com/trickstercache/trickster/v2/pkg/proxy/upstreamauth/options"
+)
+
+// tokenRefreshWindow is how long before their expiration OAuth2 tokens are refreshed
+const tokenRefreshWindow = time.Minute
+
+// errEmptyToken is returned when a token source provides an empty token
+var errEmptyToken = errors.New("empty bearer token")
+
+// tokenSource provides the bearer tokens used to authenticate upstream requests
+type tokenSource interface {
+	// Token returns a valid token
+	Token(ctx context.Context) (string, error)
+	// Invalidate discards the provided token when the origin rejects it
+	Invalidate(token string)
+}
+
+// fileValue is a value read from a file, which is read again whenever it changes
+type fileValue struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	value   string
+}
+
+func (f *fileValue) get() (string, error) {
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		return "", err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.value != "" && fi.ModTime().Equal(f.modTime) {
+		return f.value, nil
+	}
+	b, err := os.ReadFile(f.path)
+	if err != nil {
+		return "", err
+	}
+	v := strings.TrimSpace(string(b))
+	if v == "" {
+		return "", fmt.Errorf("%w in %s", errEmptyToken, f.path)
+	}
+	f.value, f.modTime = v, fi.ModTime()
+	return v, nil
+}
+
+// value returns a function that returns either the static value, or the value
+// read from the file at path
+func value(static, path string) func() (string, error) {
+	if static != "" {
+		return func() (string, error) { return static, nil }
+	}
+	return (&fileValue{path: path}).get
+}
+
+// staticTokenSource provides a static or file-reloaded bearer token
+type staticTokenSource struct {
+	token func() (string, error)
+}
+
+func (s *staticTokenSource) Token(context.Context) (string, error) {
+	return s.token()
+}
+
+func (s *staticTokenSource) Invalidate(string) {}
+
+// oauth2TokenSource provides bearer tokens obtained with the OAuth2 client
+// credentials grant, which are cached until shortly before they expire
+type oauth2TokenSource struct {
+	tokenURL       string
+	clientID       func() (string, error)
+	clientSecret   func() (string, error)
+	scopes         []string
+	endpointParams map[string]string
+	authStyle      string
+	client         *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func newOAuth2TokenSource(o *uao.OAuth2Options, base http.RoundTripper) *oauth2TokenSource {
+	client := &http.Client{Timeout: time.Duration(o.TimeoutMS) * time.Millisecond}
+	// reuse the backend's transport, and thus its TLS configuration, when possible
+	if tr, ok := base.(*http.Transport); ok {
+		client.Transport = tr
+	}
+	return &oauth2TokenSource{
+		tokenURL:       o.TokenURL,
+		clientID:       value(o.ClientID, o.ClientIDFile),
+		clientSecret:   value(o.ClientSecret, o.ClientSecretFile),
+		scopes:         o.Scopes,
+		endpointParams: o.EndpointParams,
+		authStyle:      o.AuthStyle,
+		client:         client,
+	}
+}
+
+func (s *oauth2TokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && (s.expires.IsZero() ||
+		time.Now().Add(tokenRefreshWindow).Before(s.expires)) {
+		return s.token, nil
+	}
+	token, expires, err := s.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	s.token, s.expires = token, expires
+	return token, nil
+}
+
+func (s *oauth2TokenSource) Invalidate(token string) {
+	s.mu.Lock()
+	if s.token == token {
+		s.token = ""
+	}
+	s.mu.Unlock()
+}
+
+// fetch requests a new token from the token endpoint. Errors never include the
+// client credentials or token
+func (s *oauth2TokenSource) fetch(ctx context.Context) (string, time.Time, error) {
+	id, err := s.clientID()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	secret, err := s.clientSecret()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	v := url.Values{"grant_type": {"client_credentials"}}
+	if len(s.scopes) > 0 {
+		v.Set("scope", strings.Join(s.scopes, " "))
+	}
+	for k, p := range s.endpointParams {
+		v.Set(k, p)
+	}
+	if s.authStyle == uao.AuthStyleParams {
+		v.Set("client_id", id)
+		v.Set("client_secret", secret)
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, s.tokenURL,
+		strings.NewReader(v.Encode()))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	r.Header.Set(headers.NameContentType, headers.ValueXFormURLEncoded)
+	r.Header.Set(headers.NameAccept, headers.ValueApplicationJSON)
+	if s.authStyle == uao.AuthStyleBasic {
+		r.SetBasicAuth(url.QueryEscape(id), url.QueryEscape(secret))
+	}
+	start := time.Now()
+	resp, err := s.client.Do(r)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("oauth2 token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	var tr tokenResponse
+	err = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&tr)
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, fmt.Errorf("oauth2 token request failed: %d %s %s",
+			resp.StatusCode, tr.Error, tr.ErrorDescription)
+	}
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("invalid oauth2 token response: %w", err)
+	}
+	if tr.AccessToken == "" {
+		return "", time.Time{}, errEmptyToken
+	}
+	var expires time.Time
+	if tr.ExpiresIn > 0 {
+		expires = start.Add(time.Duration(tr.ExpiresIn) * time.Second)
+	}
+	return tr.AccessToken, expires, nil
+}
+
+// tokenTransport authenticates each request with a bearer token
+type tokenTransport struct {
+	base   http.RoundTripper
+	source tokenSource
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *tokenTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	token, err := t.source.Token(r.Context())
+	if err != nil {
+		closeBody(r)
+		return nil, err
+	}
+	// RoundTrippers must not modify the provided request
+	r2 := r.Clone(r.Context())
+	r2.Header.Set(headers.NameAuthorization, "Bearer "+token)
+	resp, err := t.base.RoundTrip(r2)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized {
+		// the token may have been revoked, so the next request gets a new one
+		t.source.Invalidate(token)
+	}
+	return resp, err
+}
+
+// Unwrap returns the underlying RoundTripper
+func (t *tokenTransport) Unwrap() http.RoundTripper {
+	return t.base
+}
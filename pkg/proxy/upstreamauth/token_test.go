@@ -0,0 +1,241 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package upstreamauth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	uao "github.com/trickstercache/trickster/v2/pkg/proxy/upstreamauth/options"
+)
+
+// newTokenServer returns a stand-in OAuth2 token endpoint that issues numbered
+// tokens to the client with the provided credentials
+func newTokenServer(t *testing.T, id, secret string, calls *atomic.Int32) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		u, p, ok := r.BasicAuth()
+		if !ok {
+			u, p = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+		}
+		if u != id || p != secret || r.PostForm.Get("grant_type") != "client_credentials" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid_client","error_description":"bad credentials"}`))
+			return
+		}
+		n := calls.Add(1)
+		fmt.Fprintf(w, `{"access_token":"token-%d-%s-%s","token_type":"Bearer","expires_in":3600}`,
+			n, r.PostForm.Get("scope"), r.PostForm.Get("audience"))
+	}))
+}
+
+func testOAuth2Options(tokenURL string) *uao.Options {
+	o := &uao.Options{Mode: uao.ModeOAuth2, OAuth2: &uao.OAuth2Options{
+		TokenURL: tokenURL, ClientID: "id", ClientSecret: "secret",
+		Scopes: []string{"a", "b"}, EndpointParams: map[string]string{"audience": "mimir"}}}
+	if err := o.Validate(); err != nil {
+		panic(err)
+	}
+	return o
+}
+
+func TestOAuth2TokenSource(t *testing.T) {
+	var calls atomic.Int32
+	ts := newTokenServer(t, "id", "secret", &calls)
+	defer ts.Close()
+
+	o := testOAuth2Options(ts.URL)
+	s := newOAuth2TokenSource(o.OAuth2, nil)
+	ctx := context.Background()
+	token, err := s.Token(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "token-1-a b-mimir" {
+		t.Errorf("unexpected token %s", token)
+	}
+	// tokens are cached until shortly before they expire
+	if token, _ = s.Token(ctx); token != "token-1-a b-mimir" || calls.Load() != 1 {
+		t.Errorf("expected cached token got %s", token)
+	}
+	s.expires = time.Now().Add(tokenRefreshWindow / 2)
+	if token, _ = s.Token(ctx); !strings.HasPrefix(token, "token-2") {
+		t.Errorf("expected refreshed token got %s", token)
+	}
+	// rejected tokens are discarded
+	s.Invalidate("token-1")
+	if token, _ = s.Token(ctx); !strings.HasPrefix(token, "token-2") {
+		t.Errorf("expected cached token got %s", token)
+	}
+	s.Invalidate(token)
+	if token, _ = s.Token(ctx); !strings.HasPrefix(token, "token-3") {
+		t.Errorf("expected new token got %s", token)
+	}
+
+	// the credentials can be sent in the request body
+	o.OAuth2.AuthStyle = uao.AuthStyleParams
+	if _, err = newOAuth2TokenSource(o.OAuth2, nil).Token(ctx); err != nil {
+		t.Error(err)
+	}
+
+	// errors describe the failure without exposing the credentials
+	o.OAuth2.ClientSecret = "wrong-secret"
+	_, err = newOAuth2TokenSource(o.OAuth2, nil).Token(ctx)
+	if err == nil || !strings.Contains(err.Error(), "invalid_client") ||
+		strings.Contains(err.Error(), "wrong-secret") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestOAuth2ClientSecretFile(t *testing.T) {
+	var calls atomic.Int32
+	ts := newTokenServer(t, "file-id", "rotated", &calls)
+	defer ts.Close()
+
+	dir := t.TempDir()
+	idFile, secretFile := filepath.Join(dir, "id"), filepath.Join(dir, "secret")
+	os.WriteFile(idFile, []byte("file-id\n"), 0o600)
+	os.WriteFile(secretFile, []byte("original\n"), 0o600)
+	o := &uao.OAuth2Options{TokenURL: ts.URL, ClientIDFile: idFile,
+		ClientSecretFile: secretFile, AuthStyle: uao.AuthStyleBasic, TimeoutMS: 1000}
+	s := newOAuth2TokenSource(o, nil)
+	if _, err := s.Token(context.Background()); err == nil {
+		t.Error("expected error for invalid client secret")
+	}
+	// the rotated secret is read once the file changes
+	os.WriteFile(secretFile, []byte("rotated\n"), 0o600)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(secretFile, future, future)
+	if _, err := s.Token(context.Background()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBearerTransport(t *testing.T) {
+	var auth atomic.Value
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth.Store(r.Header.Get("Authorization"))
+	}))
+	defer ts.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(tokenFile, []byte("first\n"), 0o600)
+	tr, err := NewTransport(&uao.Options{Mode: uao.ModeBearer,
+		Bearer: &uao.BearerOptions{TokenFile: tokenFile}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &http.Client{Transport: tr}
+	r, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+	r.Header.Set("Authorization", "Basic inbound")
+	resp, err := c.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if v := auth.Load(); v != "Bearer first" {
+		t.Errorf("expected %s got %v", "Bearer first", v)
+	}
+	if r.Header.Get("Authorization") != "Basic inbound" {
+		t.Error("expected the provided request to be unmodified")
+	}
+
+	// the token file is read again once it changes
+	os.WriteFile(tokenFile, []byte("second\n"), 0o600)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(tokenFile, future, future)
+	resp, err = c.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if v := auth.Load(); v != "Bearer second" {
+		t.Errorf("expected %s got %v", "Bearer second", v)
+	}
+
+	os.WriteFile(tokenFile, nil, 0o600)
+	os.Chtimes(tokenFile, future.Add(time.Minute), future.Add(time.Minute))
+	if _, err = c.Get(ts.URL); err == nil {
+		t.Error("expected error for empty token file")
+	}
+}
+
+func TestOAuth2Transport(t *testing.T) {
+	var calls atomic.Int32
+	tokens := newTokenServer(t, "id", "secret", &calls)
+	defer tokens.Close()
+	// the origin rejects the first token it receives
+	var rejected atomic.Bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer token-") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if rejected.CompareAndSwap(false, true) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer ts.Close()
+
+	tr, err := NewTransport(testOAuth2Options(tokens.URL), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &http.Client{Transport: tr}
+	for _, code := range []int{http.StatusUnauthorized, http.StatusOK, http.StatusOK} {
+		resp, err := c.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != code {
+			t.Errorf("expected %d got %d", code, resp.StatusCode)
+		}
+	}
+	// a new token is requested after the first is rejected, and then cached
+	if calls.Load() != 2 {
+		t.Errorf("expected 2 token requests got %d", calls.Load())
+	}
+}
+
+func TestStripInboundAuthorization(t *testing.T) {
+	var auth string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+	})
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Basic inbound")
+	StripInboundAuthorization(&uao.Options{Mode: uao.ModeBearer}, next).
+		ServeHTTP(httptest.NewRecorder(), r)
+	if auth != "Basic inbound" {
+		t.Errorf("expected inbound authorization got %s", auth)
+	}
+	StripInboundAuthorization(&uao.Options{Mode: uao.ModeBearer,
+		StripInboundAuthorization: true}, next).ServeHTTP(httptest.NewRecorder(), r)
+	if auth != "" {
+		t.Errorf("expected no authorization got %s", auth)
+	}
+}
@@ -0,0 +1,158 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package upstreamauth authenticates the requests that Trickster sends to a
+// backend's origin, by signing them with AWS Signature Version 4, or with
+// OAuth2 client credentials or static bearer tokens
+package upstreamauth
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+	uao "github.com/trickstercache/trickster/v2/pkg/proxy/upstreamauth/options"
+	"github.com/trickstercache/trickster/v2/pkg/util/sigv4"
+)
+
+// stsTimeout is the timeout of requests to the STS API
+const stsTimeout = 10 * time.Second
+
+// NewTransport returns a RoundTripper that authenticates each request per the
+// Options before sending it with base
+func NewTransport(o *uao.Options, base http.RoundTripper) (http.RoundTripper, error) {
+	if o == nil {
+		return base, nil
+	}
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	switch o.Mode {
+	case uao.ModeSigV4:
+		p, err := sigV4Provider(o.SigV4)
+		if err != nil {
+			return nil, err
+		}
+		return &sigV4Transport{base: base, provider: p,
+			region: o.SigV4.Region, service: o.SigV4.Service}, nil
+	case uao.ModeOAuth2:
+		return &tokenTransport{base: base,
+			source: newOAuth2TokenSource(o.OAuth2, base)}, nil
+	case uao.ModeBearer:
+		return &tokenTransport{base: base, source: &staticTokenSource{
+			token: value(o.Bearer.Token, o.Bearer.TokenFile)}}, nil
+	}
+	return base, nil
+}
+
+// StripInboundAuthorization returns a handler that removes the Authorization
+// header from requests received from clients when the Options require it, and
+// otherwise returns next as-is
+func StripInboundAuthorization(o *uao.Options, next http.Handler) http.Handler {
+	if o == nil || !o.StripInboundAuthorization {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Del(headers.NameAuthorization)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// sigV4Provider returns the credentials Provider for the options
+func sigV4Provider(o *uao.SigV4Options) (sigv4.Provider, error) {
+	webIdentity := func(roleARN, tokenFile, sessionName string) sigv4.Provider {
+		return &sigv4.WebIdentityProvider{RoleARN: roleARN, TokenFile: tokenFile,
+			SessionName: sessionName, Endpoint: o.STSEndpoint,
+			Client: &http.Client{Timeout: stsTimeout}}
+	}
+	switch {
+	case o.RoleARN != "":
+		return webIdentity(o.RoleARN, o.WebIdentityTokenFile, o.RoleSessionName), nil
+	case o.CredentialsFile != "" || o.Profile != "":
+		path := o.CredentialsFile
+		if path == "" {
+			path = sigv4.DefaultCredentialsFile()
+		}
+		return &sigv4.FileProvider{Path: path, Profile: o.Profile}, nil
+	}
+	if c := sigv4.EnvCredentials(); c.Valid() {
+		return sigv4.StaticProvider(c), nil
+	}
+	if roleARN, tokenFile := os.Getenv("AWS_ROLE_ARN"),
+		os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"); roleARN != "" && tokenFile != "" {
+		sessionName := os.Getenv("AWS_ROLE_SESSION_NAME")
+		if sessionName == "" {
+			sessionName = o.RoleSessionName
+		}
+		return webIdentity(roleARN, tokenFile, sessionName), nil
+	}
+	if path := sigv4.DefaultCredentialsFile(); path != "" {
+		if _, err := os.Stat(path); err == nil {
+			return &sigv4.FileProvider{Path: path, Profile: os.Getenv("AWS_PROFILE")}, nil
+		}
+	}
+	return nil, sigv4.ErrNoCredentials
+}
+
+// sigV4Transport signs each request with AWS Signature Version 4
+type sigV4Transport struct {
+	base     http.RoundTripper
+	provider sigv4.Provider
+	region   string
+	service  string
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *sigV4Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	creds, err := t.provider.Retrieve(r.Context())
+	if err != nil {
+		closeBody(r)
+		return nil, err
+	}
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		body, err = io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	// RoundTrippers must not modify the provided request
+	r2 := r.Clone(r.Context())
+	if body != nil {
+		r2.Body = io.NopCloser(bytes.NewReader(body))
+		r2.ContentLength = int64(len(body))
+	}
+	sigv4.Sign(r2, creds, t.region, t.service, sigv4.PayloadHash(body), time.Now())
+	return t.base.RoundTrip(r2)
+}
+
+// Unwrap returns the underlying RoundTripper
+func (t *sigV4Transport) Unwrap() http.RoundTripper {
+	return t.base
+}
+
+func closeBody(r *http.Request) {
+	if r.Body != nil {
+		r.Body.Close()
+	}
+}
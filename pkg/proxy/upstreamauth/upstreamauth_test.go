@@ -0,0 +1,169 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package upstreamauth
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	uao "github.com/trickstercache/trickster/v2/pkg/proxy/upstreamauth/options"
+	"github.com/trickstercache/trickster/v2/pkg/util/sigv4"
+)
+
+var testCreds = sigv4.Credentials{AccessKeyID: "AKIDTEST", SecretAccessKey: "secret",
+	SessionToken: "session"}
+
+// newSigningStandIn returns a server that stands in for a SigV4-authenticated
+// service, responding with a 403 to any request that is not signed with creds
+func newSigningStandIn(creds sigv4.Credentials, region, service string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ts, err := time.Parse("20060102T150405Z", r.Header.Get(sigv4.HeaderDate))
+		if err != nil || r.Header.Get(sigv4.HeaderSecurityToken) != creds.SessionToken {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		v, _ := http.NewRequest(r.Method, "http://"+r.Host+r.URL.RequestURI(), nil)
+		if ct := r.Header.Get("Content-Type"); ct != "" {
+			v.Header.Set("Content-Type", ct)
+		}
+		sigv4.Sign(v, creds, region, service, sigv4.PayloadHash(body), ts)
+		if v.Header.Get("Authorization") != r.Header.Get("Authorization") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+}
+
+func testOptions(region string) *uao.Options {
+	return &uao.Options{Mode: uao.ModeSigV4, SigV4: &uao.SigV4Options{Region: region}}
+}
+
+func TestSigV4Transport(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", testCreds.AccessKeyID)
+	t.Setenv("AWS_SECRET_ACCESS_KEY", testCreds.SecretAccessKey)
+	t.Setenv("AWS_SESSION_TOKEN", testCreds.SessionToken)
+	ts := newSigningStandIn(testCreds, "us-east-1", "aps")
+	defer ts.Close()
+
+	tr, err := NewTransport(testOptions("us-east-1"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &http.Client{Transport: tr}
+	resp, err := c.Get(ts.URL + "/workspaces/ws-1/api/v1/query?query=up&time=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	// the request body is included in the signature
+	r, _ := http.NewRequest(http.MethodPost, ts.URL+"/workspaces/ws-1/api/v1/query",
+		strings.NewReader("query=up"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err = c.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, resp.StatusCode)
+	}
+	if r.Header.Get("Authorization") != "" {
+		t.Error("expected the provided request to be unmodified")
+	}
+
+	// requests signed for another region are rejected
+	tr, _ = NewTransport(testOptions("us-west-2"), nil)
+	resp, err = (&http.Client{Transport: tr}).Get(ts.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected %d got %d", http.StatusForbidden, resp.StatusCode)
+	}
+}
+
+func TestSigV4Provider(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_ROLE_ARN", "")
+	t.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", "")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "missing"))
+
+	o := testOptions("us-east-1")
+	if _, err := NewTransport(o, nil); !errors.Is(err, sigv4.ErrNoCredentials) {
+		t.Errorf("expected %v got %v", sigv4.ErrNoCredentials, err)
+	}
+
+	t.Setenv("AWS_ROLE_ARN", "arn:aws:iam::123456789012:role/test")
+	t.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", "/var/run/token")
+	p, err := sigV4Provider(o.SigV4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wp, ok := p.(*sigv4.WebIdentityProvider); !ok || wp.TokenFile != "/var/run/token" ||
+		wp.Endpoint != "https://sts.us-east-1.amazonaws.com" {
+		t.Errorf("expected web identity provider got %+v", p)
+	}
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "a")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "b")
+	if p, _ = sigV4Provider(o.SigV4); p != sigv4.StaticProvider(sigv4.EnvCredentials()) {
+		t.Errorf("expected environment credentials got %+v", p)
+	}
+
+	path := filepath.Join(t.TempDir(), "credentials")
+	os.WriteFile(path, []byte("[prod]\naws_access_key_id=AKIDTEST\naws_secret_access_key=secret\n"), 0o600)
+	o.SigV4.CredentialsFile = path
+	o.SigV4.Profile = "prod"
+	if p, _ = sigV4Provider(o.SigV4); p == nil {
+		t.Fatal("expected file provider")
+	} else if fp, ok := p.(*sigv4.FileProvider); !ok || fp.Path != path || fp.Profile != "prod" {
+		t.Errorf("expected file provider got %+v", p)
+	}
+
+	o.SigV4.RoleARN = "arn:aws:iam::123456789012:role/explicit"
+	o.SigV4.WebIdentityTokenFile = "/token"
+	if p, _ = sigV4Provider(o.SigV4); p == nil {
+		t.Fatal("expected web identity provider")
+	} else if wp, ok := p.(*sigv4.WebIdentityProvider); !ok || wp.RoleARN != o.SigV4.RoleARN {
+		t.Errorf("expected web identity provider got %+v", p)
+	}
+}
+
+func TestNewTransportNil(t *testing.T) {
+	base := http.DefaultTransport
+	if tr, err := NewTransport(nil, base); err != nil || tr != base {
+		t.Error("expected base transport")
+	}
+	if _, err := NewTransport(&uao.Options{Mode: "invalid"}, base); !errors.Is(err, uao.ErrInvalidMode) {
+		t.Errorf("expected %v got %v", uao.ErrInvalidMode, err)
+	}
+}
@@ -0,0 +1,41 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package options
+
+const (
+	// DefaultIntervalMS is the default interval at which a warmer runs
+	DefaultIntervalMS = 300000
+	// DefaultConcurrency is the default number of simultaneous requests a warmer will issue
+	DefaultConcurrency = 4
+	// DefaultTimeoutMS is the default amount of time a warming request may take
+	DefaultTimeoutMS = 30000
+	// DefaultTopNWindowMS is the default window over which requested keys are
+	// counted when selecting the top N keys to warm
+	DefaultTopNWindowMS = 3600000
+	// DefaultRequestMethod is the default HTTP method for warming requests
+	DefaultRequestMethod = "GET"
+	// DefaultTimeFormat is the default format for {{start}} and {{end}} tokens
+	DefaultTimeFormat = TimeFormatUnix
+)
+
+const (
+	// TimeFormatUnix renders times as epoch seconds
+	TimeFormatUnix = "unix"
+	// TimeFormatUnixMilli renders times as epoch milliseconds
+	TimeFormatUnixMilli = "unix_ms"
+	// TimeFormatRFC3339 renders times in RFC3339 format
+	TimeFormatRFC3339 = "rfc3339"
+)
@@ -0,0 +1,198 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+// Package options provides options for cache warmers
+package options
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+)
+
+// ErrNoWarmingRequests returns an error for a warmer with no requests or top N setting
+var ErrNoWarmingRequests = errors.New("warmer must provide requests or a top_n value")
+
+// Options defines the configuration for a Cache Warmer, which periodically issues requests
+// through a backend's router so that its cache is populated before clients request the data
+type Options struct {
+	// Name is the name of the Warmer
+	Name string `json:"-"`
+	// Backend is the name of the Backend whose router will receive the warming requests
+	Backend string `json:"backend,omitempty"`
+	// IntervalMS is the interval in milliseconds at which the warmer runs
+	IntervalMS int `json:"interval_ms,omitempty"`
+	// JitterMS is the maximum random delay added before each run, so that warmers on
+	// multiple instances do not issue their requests in lockstep
+	JitterMS int `json:"jitter_ms,omitempty"`
+	// Concurrency is the maximum number of warming requests in flight at once
+	Concurrency int `json:"concurrency,omitempty"`
+	// TimeoutMS is the amount of time a warming request may take before it is canceled
+	TimeoutMS int `json:"timeout_ms,omitempty"`
+	// Requests is the list of templated requests issued on each run
+	Requests []*RequestOptions `json:"requests,omitempty"`
+	// TopN, when > 0, causes the warmer to also replay the N most frequently requested
+	// cache keys for the backend, as observed during the last TopNWindowMS
+	TopN int `json:"top_n,omitempty"`
+	// TopNWindowMS is the window over which requested keys are counted for TopN
+	TopNWindowMS int `json:"top_n_window_ms,omitempty"`
+}
+
+// RequestOptions defines a templated warming request. Param and Header values may
+// include the {{start}}, {{end}} and {{step}} tokens, which are replaced with a time
+// range of RangeMS ending at the current time, aligned to StepMS
+type RequestOptions struct {
+	// Method is the HTTP method of the request
+	Method string `json:"method,omitempty"`
+	// Path is the URL path of the request
+	Path string `json:"path,omitempty"`
+	// Params is the map of URL query parameters for the request
+	Params map[string]string `json:"params,omitempty"`
+	// Headers is the map of HTTP headers for the request
+	Headers map[string]string `json:"headers,omitempty"`
+	// RangeMS is the duration of the relative time range used for {{start}} and {{end}}
+	RangeMS int `json:"range_ms,omitempty"`
+	// StepMS is the step used for {{step}}, to which {{start}} and {{end}} are aligned
+	StepMS int `json:"step_ms,omitempty"`
+	// TimeFormat is the format of the {{start}} and {{end}} tokens:
+	// 'unix' (default), 'unix_ms' or 'rfc3339'
+	TimeFormat string `json:"time_format,omitempty"`
+}
+
+// Lookup is a map of Options
+type Lookup map[string]*Options
+
+// New returns a new Options with default values
+func New() *Options {
+	return &Options{
+		IntervalMS:   DefaultIntervalMS,
+		Concurrency:  DefaultConcurrency,
+		TimeoutMS:    DefaultTimeoutMS,
+		TopNWindowMS: DefaultTopNWindowMS,
+	}
+}
+
+// Clone returns an exact copy of the subject Options
+func (o *Options) Clone() *Options {
+	c := &Options{
+		Name:         o.Name,
+		Backend:      o.Backend,
+		IntervalMS:   o.IntervalMS,
+		JitterMS:     o.JitterMS,
+		Concurrency:  o.Concurrency,
+		TimeoutMS:    o.TimeoutMS,
+		TopN:         o.TopN,
+		TopNWindowMS: o.TopNWindowMS,
+	}
+	if o.Requests != nil {
+		c.Requests = make([]*RequestOptions, len(o.Requests))
+		for i, r := range o.Requests {
+			c.Requests[i] = r.Clone()
+		}
+	}
+	return c
+}
+
+// Clone returns an exact copy of the subject RequestOptions
+func (r *RequestOptions) Clone() *RequestOptions {
+	c := &RequestOptions{
+		Method:     r.Method,
+		Path:       r.Path,
+		RangeMS:    r.RangeMS,
+		StepMS:     r.StepMS,
+		TimeFormat: r.TimeFormat,
+	}
+	if r.Params != nil {
+		c.Params = headers.Lookup(r.Params).Clone()
+	}
+	if r.Headers != nil {
+		c.Headers = headers.Lookup(r.Headers).Clone()
+	}
+	return c
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Options) DeepCopyInto(out *Options) {
+	*out = *in.Clone()
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Options.
+func (in *Options) DeepCopy() *Options {
+	if in == nil {
+		return nil
+	}
+	out := new(Options)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// SetDefaults applies default values to any unset Options in the Lookup, and
+// validates that each warmer references a defined backend
+func (l Lookup) SetDefaults(backends bo.Lookup) error {
+	for k, o := range l {
+		if o == nil {
+			o = New()
+			l[k] = o
+		}
+		o.Name = k
+		if _, ok := backends[o.Backend]; !ok {
+			return fmt.Errorf("invalid backend name [%s] provided in warmer [%s]", o.Backend, k)
+		}
+		if len(o.Requests) == 0 && o.TopN <= 0 {
+			return fmt.Errorf("%w: %s", ErrNoWarmingRequests, k)
+		}
+		if o.IntervalMS <= 0 {
+			o.IntervalMS = DefaultIntervalMS
+		}
+		if o.Concurrency <= 0 {
+			o.Concurrency = DefaultConcurrency
+		}
+		if o.TimeoutMS <= 0 {
+			o.TimeoutMS = DefaultTimeoutMS
+		}
+		if o.TopNWindowMS <= 0 {
+			o.TopNWindowMS = DefaultTopNWindowMS
+		}
+		if o.JitterMS < 0 {
+			o.JitterMS = 0
+		}
+		for i, r := range o.Requests {
+			if r == nil || r.Path == "" {
+				return fmt.Errorf("missing path for request %d in warmer [%s]", i, k)
+			}
+			if r.Method == "" {
+				r.Method = DefaultRequestMethod
+			}
+			r.Method = strings.ToUpper(r.Method)
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				return fmt.Errorf("invalid method [%s] for request %d in warmer [%s]",
+					r.Method, i, k)
+			}
+			switch r.TimeFormat {
+			case "":
+				r.TimeFormat = DefaultTimeFormat
+			case TimeFormatUnix, TimeFormatUnixMilli, TimeFormatRFC3339:
+			default:
+				return fmt.Errorf("invalid time_format [%s] for request %d in warmer [%s]",
+					r.TimeFormat, i, k)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package options
+
+import (
+	"errors"
+	"testing"
+
+	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
+)
+
+func TestSetDefaults(t *testing.T) {
+	backends := bo.Lookup{"test": bo.New()}
+
+	l := Lookup{"w1": {Backend: "test", TopN: 5,
+		Requests: []*RequestOptions{{Path: "/api/v1/query_range", Method: "get"}}}}
+	if err := l.SetDefaults(backends); err != nil {
+		t.Fatal(err)
+	}
+	o := l["w1"]
+	if o.Name != "w1" || o.IntervalMS != DefaultIntervalMS || o.Concurrency != DefaultConcurrency ||
+		o.TimeoutMS != DefaultTimeoutMS || o.TopNWindowMS != DefaultTopNWindowMS {
+		t.Errorf("unexpected defaults %+v", o)
+	}
+	if o.Requests[0].Method != "GET" || o.Requests[0].TimeFormat != DefaultTimeFormat {
+		t.Errorf("unexpected request defaults %+v", o.Requests[0])
+	}
+
+	tests := []struct {
+		o *Options
+	}{
+		{&Options{Backend: "invalid", TopN: 1}},
+		{&Options{Backend: "test"}},
+		{&Options{Backend: "test", Requests: []*RequestOptions{{}}}},
+		{&Options{Backend: "test", Requests: []*RequestOptions{{Path: "/", Method: "POST"}}}},
+		{&Options{Backend: "test", Requests: []*RequestOptions{{Path: "/", TimeFormat: "x"}}}},
+	}
+	for _, test := range tests {
+		if err := (Lookup{"w": test.o}).SetDefaults(backends); err == nil {
+			t.Errorf("expected error for %+v", test.o)
+		}
+	}
+
+	err := Lookup{"w": {Backend: "test"}}.SetDefaults(backends)
+	if !errors.Is(err, ErrNoWarmingRequests) {
+		t.Errorf("expected %v got %v", ErrNoWarmingRequests, err)
+	}
+}
+
+func TestClone(t *testing.T) {
+	o := New()
+	o.Backend = "test"
+	o.Requests = []*RequestOptions{{Path: "/", Params: map[string]string{"a": "b"},
+		Headers: map[string]string{"c": "d"}}}
+	c := o.DeepCopy()
+	if c.Backend != "test" || c.Requests[0].Params["a"] != "b" || c.Requests[0].Headers["c"] != "d" {
+		t.Errorf("unexpected clone %+v", c)
+	}
+	c.Requests[0].Params["a"] = "z"
+	if o.Requests[0].Params["a"] != "b" {
+		t.Error("expected deep copy of params")
+	}
+	var n *Options
+	if n.DeepCopy() != nil {
+		t.Error("expected nil")
+	}
+}
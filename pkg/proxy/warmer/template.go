@@ -0,0 +1,83 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package warmer
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	wo "github.com/trickstercache/trickster/v2/pkg/proxy/warmer/options"
+)
+
+const (
+	tokenStart = "{{start}}"
+	tokenEnd   = "{{end}}"
+	tokenStep  = "{{step}}"
+)
+
+// newTemplatedRequest returns a new request for the provided RequestOptions, with
+// any time tokens in its params and headers replaced using the provided time as now
+func newTemplatedRequest(ro *wo.RequestOptions, now time.Time) (*http.Request, error) {
+	rp := tokenReplacer(ro, now)
+	v := make(url.Values, len(ro.Params))
+	for k, p := range ro.Params {
+		v.Set(k, rp.Replace(p))
+	}
+	u := &url.URL{Scheme: "http", Host: "localhost", Path: ro.Path, RawQuery: v.Encode()}
+	r, err := http.NewRequest(ro.Method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, h := range ro.Headers {
+		r.Header.Set(k, rp.Replace(h))
+	}
+	return r, nil
+}
+
+// tokenReplacer returns a Replacer for the time tokens. The range ends at now, aligned
+// to the step so that successive runs produce the same cache keys that clients do
+func tokenReplacer(ro *wo.RequestOptions, now time.Time) *strings.Replacer {
+	step := time.Duration(ro.StepMS) * time.Millisecond
+	end := now
+	if step > 0 {
+		end = now.Truncate(step)
+	}
+	start := end.Add(-time.Duration(ro.RangeMS) * time.Millisecond)
+	var st string
+	if ro.TimeFormat == wo.TimeFormatUnixMilli {
+		st = strconv.FormatInt(step.Milliseconds(), 10)
+	} else {
+		st = strconv.FormatFloat(step.Seconds(), 'f', -1, 64)
+	}
+	return strings.NewReplacer(
+		tokenStart, formatTime(start, ro.TimeFormat),
+		tokenEnd, formatTime(end, ro.TimeFormat),
+		tokenStep, st,
+	)
+}
+
+func formatTime(t time.Time, format string) string {
+	switch format {
+	case wo.TimeFormatUnixMilli:
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	case wo.TimeFormatRFC3339:
+		return t.UTC().Format(time.RFC3339)
+	}
+	return strconv.FormatInt(t.Unix(), 10)
+}
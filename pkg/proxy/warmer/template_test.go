@@ -0,0 +1,67 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package warmer
+
+import (
+	"testing"
+	"time"
+
+	wo "github.com/trickstercache/trickster/v2/pkg/proxy/warmer/options"
+)
+
+func TestNewTemplatedRequest(t *testing.T) {
+	now := time.Unix(1700000030, 0)
+	ro := &wo.RequestOptions{
+		Method:     "GET",
+		Path:       "/api/v1/query_range",
+		Params:     map[string]string{"query": "up", "start": "{{start}}", "end": "{{end}}", "step": "{{step}}"},
+		Headers:    map[string]string{"X-Range": "{{start}}-{{end}}"},
+		RangeMS:    3600000,
+		StepMS:     60000,
+		TimeFormat: wo.TimeFormatUnix,
+	}
+	r, err := newTemplatedRequest(ro, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := r.URL.Query()
+	if v.Get("end") != "1699999980" || v.Get("start") != "1699996380" ||
+		v.Get("step") != "60" || v.Get("query") != "up" {
+		t.Errorf("unexpected query params %v", v)
+	}
+	if h := r.Header.Get("X-Range"); h != "1699996380-1699999980" {
+		t.Errorf("unexpected header %s", h)
+	}
+	if r.URL.Path != ro.Path {
+		t.Errorf("expected %s got %s", ro.Path, r.URL.Path)
+	}
+}
+
+func TestFormatTime(t *testing.T) {
+	tm := time.Unix(1700000000, 0)
+	tests := []struct {
+		format, expected string
+	}{
+		{wo.TimeFormatUnix, "1700000000"},
+		{wo.TimeFormatUnixMilli, "1700000000000"},
+		{wo.TimeFormatRFC3339, "2023-11-14T22:13:20Z"},
+	}
+	for _, test := range tests {
+		if s := formatTime(tm, test.format); s != test.expected {
+			t.Errorf("expected %s got %s", test.expected, s)
+		}
+	}
+}
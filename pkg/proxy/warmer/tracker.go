@@ -0,0 +1,255 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package warmer
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/cache/status"
+	"github.com/trickstercache/trickster/v2/pkg/observability/metrics"
+	tctx "github.com/trickstercache/trickster/v2/pkg/proxy/context"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+	po "github.com/trickstercache/trickster/v2/pkg/proxy/paths/options"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/request"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/urls"
+	wo "github.com/trickstercache/trickster/v2/pkg/proxy/warmer/options"
+)
+
+// maxTrackedKeys limits the number of distinct cache keys tracked per backend
+// for the purposes of top N warming
+const maxTrackedKeys = 10000
+
+// credentialHeaders are never retained for replay, even when they are part of
+// the cache key, so that client credentials are not held or reused by warmers
+var credentialHeaders = []string{headers.NameAuthorization,
+	headers.NameProxyAuthorization, headers.NameCookie}
+
+// tracked is the tracker used by Observe and the running Warmers
+var tracked = newTracker()
+
+// tracker records which cache keys were populated by warmers, so that later client
+// hits on them can be counted, and which keys clients request most, for top N warming
+type tracker struct {
+	active  atomic.Bool
+	mtx     sync.Mutex
+	windows map[string]time.Duration // warmer name -> attribution window
+	warmed  map[string]time.Time     // cache key -> attribution expiration
+	topKeys map[string]*keyCounts    // backend name -> client-requested keys
+}
+
+type keyCounts struct {
+	window time.Duration
+	keys   map[string]*trackedRequest
+}
+
+// trackedRequest is a client request for a cache key, retained so it can be replayed
+type trackedRequest struct {
+	method   string
+	url      *url.URL
+	header   http.Header
+	extent   time.Duration // the duration of the time range, for timeseries requests
+	count    int
+	since    time.Time
+	lastSeen time.Time
+}
+
+func newTracker() *tracker {
+	return &tracker{
+		windows: make(map[string]time.Duration),
+		warmed:  make(map[string]time.Time),
+		topKeys: make(map[string]*keyCounts),
+	}
+}
+
+// configure updates the tracker for the provided warmer options. Keys already
+// tracked for backends that still have a top N warmer are retained
+func (t *tracker) configure(opts wo.Lookup) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.windows = make(map[string]time.Duration, len(opts))
+	windows := make(map[string]time.Duration)
+	for k, o := range opts {
+		// a warmed key is attributed to the warmer until it would be warmed again
+		t.windows[k] = time.Duration(2*o.IntervalMS+o.JitterMS) * time.Millisecond
+		if o.TopN > 0 {
+			windows[o.Backend] = max(windows[o.Backend],
+				time.Duration(o.TopNWindowMS)*time.Millisecond)
+		}
+	}
+	for k := range t.topKeys {
+		if _, ok := windows[k]; !ok {
+			delete(t.topKeys, k)
+		}
+	}
+	for k, d := range windows {
+		if kc, ok := t.topKeys[k]; ok {
+			kc.window = d
+			continue
+		}
+		t.topKeys[k] = &keyCounts{window: d, keys: make(map[string]*trackedRequest)}
+	}
+	t.active.Store(len(opts) > 0)
+}
+
+// Observe records the outcome of a cacheable request. Requests issued by a warmer
+// mark their cache key as warmed, while client requests are counted as warm hits
+// when served from a warmed key, and are tracked for top N warming
+func Observe(r *http.Request, rsc *request.Resources, cs status.LookupStatus) {
+	if !tracked.active.Load() || r == nil || rsc == nil || rsc.CacheKey == "" ||
+		rsc.BackendOptions == nil {
+		return
+	}
+	if tracked.observe(r, rsc, cs, time.Now()) {
+		metrics.WarmerHitsServed.WithLabelValues(rsc.BackendOptions.Name, cs.String()).Inc()
+	}
+}
+
+// observe returns true if the request was a client hit on a warmed key
+func (t *tracker) observe(r *http.Request, rsc *request.Resources,
+	cs status.LookupStatus, now time.Time,
+) bool {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	key := rsc.CacheKey
+	if name := tctx.Warmer(r.Context()); name != "" {
+		if d, ok := t.windows[name]; ok {
+			t.warmed[key] = now.Add(d)
+		}
+		return false
+	}
+	var isWarmHit bool
+	if cs == status.LookupStatusHit || cs == status.LookupStatusPartialHit {
+		if exp, ok := t.warmed[key]; ok && now.Before(exp) {
+			isWarmHit = true
+		}
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return isWarmHit
+	}
+	kc, ok := t.topKeys[rsc.BackendOptions.Name]
+	if !ok {
+		return isWarmHit
+	}
+	tr, ok := kc.keys[key]
+	if !ok {
+		if len(kc.keys) >= maxTrackedKeys {
+			return isWarmHit
+		}
+		tr = &trackedRequest{
+			method: r.Method,
+			url:    urls.Clone(r.URL),
+			header: replayHeaders(r.Header, rsc.PathConfig),
+			since:  now,
+		}
+		kc.keys[key] = tr
+	}
+	if now.Sub(tr.since) > kc.window {
+		tr.count = 0
+		tr.since = now
+	}
+	if rsc.TimeRangeQuery != nil {
+		tr.extent = rsc.TimeRangeQuery.Extent.End.Sub(rsc.TimeRangeQuery.Extent.Start)
+	}
+	tr.count++
+	tr.lastSeen = now
+	return isWarmHit
+}
+
+// topN returns copies of the n most requested keys for the backend within its window
+func (t *tracker) topN(backend string, n int, now time.Time) []*trackedRequest {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	kc, ok := t.topKeys[backend]
+	if !ok || n <= 0 {
+		return nil
+	}
+	out := make([]*trackedRequest, 0, len(kc.keys))
+	for _, tr := range kc.keys {
+		if now.Sub(tr.lastSeen) > kc.window {
+			continue
+		}
+		c := *tr
+		out = append(out, &c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].count == out[j].count {
+			return out[i].lastSeen.After(out[j].lastSeen)
+		}
+		return out[i].count > out[j].count
+	})
+	if len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
+
+// prune removes expired warmed keys and keys not requested within their window
+func (t *tracker) prune(now time.Time) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	for k, exp := range t.warmed {
+		if !now.Before(exp) {
+			delete(t.warmed, k)
+		}
+	}
+	for _, kc := range t.topKeys {
+		for k, tr := range kc.keys {
+			if now.Sub(tr.lastSeen) > kc.window {
+				delete(kc.keys, k)
+			}
+		}
+	}
+}
+
+// replayHeaders returns the subset of the request headers that is retained for
+// replay: those that are part of the path's cache key, except for credentials
+func replayHeaders(h http.Header, pc *po.Options) http.Header {
+	out := make(http.Header)
+	if pc == nil {
+		return out
+	}
+	for _, k := range pc.CacheKeyHeaders {
+		k = http.CanonicalHeaderKey(k)
+		if slices.Contains(credentialHeaders, k) {
+			continue
+		}
+		if v, ok := h[k]; ok {
+			out[k] = slices.Clone(v)
+		}
+	}
+	return out
+}
+
+// request returns a new request that replays the tracked request
+func (tr *trackedRequest) request() (*http.Request, error) {
+	u := urls.Clone(tr.url)
+	if u.Host == "" {
+		u.Scheme = "http"
+		u.Host = "localhost"
+	}
+	r, err := http.NewRequest(tr.method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	r.Header = tr.header.Clone()
+	return r, nil
+}
@@ -0,0 +1,133 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package warmer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache/status"
+	tctx "github.com/trickstercache/trickster/v2/pkg/proxy/context"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+	po "github.com/trickstercache/trickster/v2/pkg/proxy/paths/options"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/request"
+	wo "github.com/trickstercache/trickster/v2/pkg/proxy/warmer/options"
+)
+
+func testResources(key string) *request.Resources {
+	o := bo.New()
+	o.Name = "test"
+	return &request.Resources{BackendOptions: o, CacheKey: key}
+}
+
+func TestTrackerWarmHits(t *testing.T) {
+	tr := newTracker()
+	tr.configure(wo.Lookup{"w": {Name: "w", Backend: "test", IntervalMS: 1000}})
+	now := time.Now()
+
+	r := httptest.NewRequest("GET", "/", nil)
+	if tr.observe(r, testResources("k1"), status.LookupStatusHit, now) {
+		t.Error("expected false for unwarmed key")
+	}
+
+	wr := r.WithContext(tctx.WithWarmer(context.Background(), "w"))
+	tr.observe(wr, testResources("k1"), status.LookupStatusKeyMiss, now)
+	if !tr.observe(r, testResources("k1"), status.LookupStatusHit, now) {
+		t.Error("expected true for warmed key")
+	}
+	if tr.observe(r, testResources("k1"), status.LookupStatusKeyMiss, now) {
+		t.Error("expected false for miss")
+	}
+
+	tr.prune(now.Add(time.Hour))
+	if tr.observe(r, testResources("k1"), status.LookupStatusHit, now.Add(time.Hour)) {
+		t.Error("expected false for expired warmed key")
+	}
+}
+
+func TestTrackerTopN(t *testing.T) {
+	tr := newTracker()
+	tr.configure(wo.Lookup{"w": {Name: "w", Backend: "test", IntervalMS: 1000,
+		TopN: 2, TopNWindowMS: 60000}})
+	now := time.Now()
+
+	for i, k := range []string{"a", "b", "b", "c", "c", "c"} {
+		r := httptest.NewRequest("GET", "/path?q="+k, nil)
+		tr.observe(r, testResources(k), status.LookupStatusHit, now.Add(time.Duration(i)))
+	}
+	// requests that can't be replayed are not tracked
+	tr.observe(httptest.NewRequest("POST", "/path?q=d", nil), testResources("d"),
+		status.LookupStatusHit, now)
+
+	top := tr.topN("test", 2, now)
+	if len(top) != 2 {
+		t.Fatalf("expected 2 got %d", len(top))
+	}
+	if top[0].url.RawQuery != "q=c" || top[0].count != 3 || top[1].url.RawQuery != "q=b" {
+		t.Errorf("unexpected top keys %s %s", top[0].url.RawQuery, top[1].url.RawQuery)
+	}
+	r, err := top[0].request()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.URL.Path != "/path" || r.URL.RawQuery != "q=c" {
+		t.Errorf("unexpected request url %s", r.URL.String())
+	}
+	if len(r.Header) != 0 {
+		t.Errorf("unexpected request headers %v", r.Header)
+	}
+
+	if top = tr.topN("other", 2, now); len(top) != 0 {
+		t.Errorf("expected 0 got %d", len(top))
+	}
+
+	tr.prune(now.Add(2 * time.Minute))
+	if top = tr.topN("test", 2, now); len(top) != 0 {
+		t.Errorf("expected 0 got %d", len(top))
+	}
+
+	// reconfiguring without a top N warmer stops tracking for the backend
+	tr.configure(wo.Lookup{})
+	if _, ok := tr.topKeys["test"]; ok {
+		t.Error("expected backend to no longer be tracked")
+	}
+}
+
+func TestReplayHeaders(t *testing.T) {
+	h := http.Header{}
+	h.Set(headers.NameAuthorization, "Basic secret")
+	h.Set(headers.NameCookie, "session=secret")
+	h.Set("X-Tenant", "a")
+	h.Set("X-Other", "b")
+	if out := replayHeaders(h, nil); len(out) != 0 {
+		t.Errorf("unexpected headers %v", out)
+	}
+	pc := po.New()
+	pc.CacheKeyHeaders = []string{"x-tenant", headers.NameAuthorization, headers.NameCookie}
+	out := replayHeaders(h, pc)
+	// only cache key headers are retained, and never credentials
+	if len(out) != 1 || out.Get("X-Tenant") != "a" {
+		t.Errorf("unexpected headers %v", out)
+	}
+	out.Set("X-Tenant", "b")
+	if h.Get("X-Tenant") != "a" {
+		t.Error("expected the request headers to be unmodified")
+	}
+}
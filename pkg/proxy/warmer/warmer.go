@@ -0,0 +1,252 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+// Package warmer provides Cache Warmers, which periodically issue requests through
+// a backend's router so that its cache is populated before clients request the data
+package warmer
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/backends"
+	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
+	"github.com/trickstercache/trickster/v2/pkg/observability/metrics"
+	tctx "github.com/trickstercache/trickster/v2/pkg/proxy/context"
+	wo "github.com/trickstercache/trickster/v2/pkg/proxy/warmer/options"
+	"github.com/trickstercache/trickster/v2/pkg/timeseries"
+)
+
+// Warmer periodically issues warming requests through a backend's router
+type Warmer struct {
+	options *wo.Options
+	client  backends.Backend
+	router  http.Handler
+	logger  interface{}
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// Warmers is a list of Warmers
+type Warmers []*Warmer
+
+// New returns a new Warmer that issues requests through the provided backend's router
+func New(o *wo.Options, client backends.Backend, logger interface{}) (*Warmer, error) {
+	if o == nil || client == nil || client.Router() == nil {
+		return nil, fmt.Errorf("invalid backend for warmer")
+	}
+	return &Warmer{
+		options: o,
+		client:  client,
+		router:  client.Router(),
+		logger:  logger,
+	}, nil
+}
+
+// StartAll creates and starts a Warmer for each of the provided options
+func StartAll(opts wo.Lookup, clients backends.Backends, logger interface{}) (Warmers, error) {
+	ws := make(Warmers, 0, len(opts))
+	for k, o := range opts {
+		w, err := New(o, clients.Get(o.Backend), logger)
+		if err != nil {
+			return nil, fmt.Errorf("invalid backend name [%s] provided in warmer [%s]",
+				o.Backend, k)
+		}
+		ws = append(ws, w)
+	}
+	tracked.configure(opts)
+	for _, w := range ws {
+		w.Start()
+	}
+	return ws, nil
+}
+
+// Stop stops all of the Warmers in the list
+func (ws Warmers) Stop() {
+	for _, w := range ws {
+		w.Stop()
+	}
+}
+
+// Start starts the Warmer's schedule in a new goroutine
+func (w *Warmer) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	w.wg.Add(1)
+	go w.run(ctx)
+	tl.Info(w.logger, "cache warmer started",
+		tl.Pairs{"warmerName": w.options.Name, "backendName": w.options.Backend,
+			"intervalMS": w.options.IntervalMS})
+}
+
+// Stop stops the Warmer, canceling any in-flight requests, and waits for it to exit
+func (w *Warmer) Stop() {
+	if w.cancel == nil {
+		return
+	}
+	w.cancel()
+	w.wg.Wait()
+	w.cancel = nil
+}
+
+func (w *Warmer) run(ctx context.Context) {
+	defer w.wg.Done()
+	interval := time.Duration(w.options.IntervalMS) * time.Millisecond
+	for {
+		// the first run happens immediately (plus jitter) so a freshly started
+		// instance does not wait a full interval to populate its cache
+		if !sleep(ctx, w.jitter()) {
+			return
+		}
+		w.Warm(ctx)
+		if !sleep(ctx, interval) {
+			return
+		}
+	}
+}
+
+func (w *Warmer) jitter() time.Duration {
+	if w.options.JitterMS <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Intn(w.options.JitterMS)) * time.Millisecond
+}
+
+// sleep waits for the duration, returning false if the context is canceled first
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// Warm issues one round of the Warmer's requests, bounded by its concurrency limit
+func (w *Warmer) Warm(ctx context.Context) {
+	now := time.Now()
+	reqs := w.requests(now)
+	if len(reqs) == 0 {
+		return
+	}
+	sem := make(chan struct{}, w.options.Concurrency)
+	var wg sync.WaitGroup
+	for _, r := range reqs {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case sem <- struct{}{}:
+		}
+		wg.Add(1)
+		go func(r *http.Request) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			w.issue(ctx, r)
+		}(r)
+	}
+	wg.Wait()
+	tracked.prune(time.Now())
+}
+
+// requests returns the templated and top N requests for a warming run
+func (w *Warmer) requests(now time.Time) []*http.Request {
+	reqs := make([]*http.Request, 0, len(w.options.Requests)+w.options.TopN)
+	for _, ro := range w.options.Requests {
+		r, err := newTemplatedRequest(ro, now)
+		if err != nil {
+			tl.Warn(w.logger, "could not build warming request",
+				tl.Pairs{"warmerName": w.options.Name, "path": ro.Path, "detail": err.Error()})
+			continue
+		}
+		reqs = append(reqs, r)
+	}
+	if w.options.TopN <= 0 {
+		return reqs
+	}
+	tsc, _ := w.client.(backends.TimeseriesBackend)
+	for _, tr := range tracked.topN(w.options.Backend, w.options.TopN, now) {
+		r, err := tr.request()
+		if err != nil {
+			continue
+		}
+		// timeseries requests are shifted so their time range ends now, which is the
+		// range a client reloading the same dashboard would request
+		if tr.extent > 0 && tsc != nil {
+			trq, _, _, err := tsc.ParseTimeRangeQuery(r)
+			if err == nil && trq != nil {
+				tsc.SetExtent(r, trq, &timeseries.Extent{Start: now.Add(-tr.extent), End: now})
+			}
+		}
+		reqs = append(reqs, r)
+	}
+	return reqs
+}
+
+// issue serves the request through the backend router, discarding the response
+func (w *Warmer) issue(ctx context.Context, r *http.Request) {
+	ctx, cancel := context.WithTimeout(ctx,
+		time.Duration(w.options.TimeoutMS)*time.Millisecond)
+	defer cancel()
+	r = r.WithContext(tctx.WithWarmer(ctx, w.options.Name))
+	rw := &discardWriter{h: make(http.Header)}
+	w.router.ServeHTTP(rw, r)
+	if rw.code == 0 {
+		rw.code = http.StatusOK
+	}
+	metrics.WarmerRequests.WithLabelValues(w.options.Name, w.options.Backend,
+		strconv.Itoa(rw.code)).Inc()
+	if rw.code >= http.StatusBadRequest {
+		tl.Debug(w.logger, "warming request failed",
+			tl.Pairs{"warmerName": w.options.Name, "backendName": w.options.Backend,
+				"url": r.URL.String(), "status": rw.code})
+	}
+}
+
+// discardWriter is an http.ResponseWriter that records the status code and
+// discards the body of warming responses
+type discardWriter struct {
+	h    http.Header
+	code int
+}
+
+func (dw *discardWriter) Header() http.Header {
+	return dw.h
+}
+
+func (dw *discardWriter) Write(b []byte) (int, error) {
+	if dw.code == 0 {
+		dw.code = http.StatusOK
+	}
+	return len(b), nil
+}
+
+func (dw *discardWriter) WriteHeader(code int) {
+	if dw.code == 0 {
+		dw.code = code
+	}
+}
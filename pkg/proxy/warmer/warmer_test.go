@@ -0,0 +1,103 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package warmer
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/backends"
+	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
+	tctx "github.com/trickstercache/trickster/v2/pkg/proxy/context"
+	wo "github.com/trickstercache/trickster/v2/pkg/proxy/warmer/options"
+)
+
+func TestWarm(t *testing.T) {
+	var mtx sync.Mutex
+	paths := make(map[string]int)
+	router := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if tctx.Warmer(r.Context()) != "w" {
+			t.Error("expected warmer name in context")
+		}
+		mtx.Lock()
+		paths[r.URL.Path]++
+		mtx.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	})
+	o := bo.New()
+	o.Name = "test"
+	b, _ := backends.New("test", o, nil, router, nil)
+
+	opts := wo.Lookup{"w": {Backend: "test", Requests: []*wo.RequestOptions{
+		{Path: "/a"}, {Path: "/b"}, {Path: "/c"},
+	}}}
+	if err := opts.SetDefaults(bo.Lookup{"test": o}); err != nil {
+		t.Fatal(err)
+	}
+	opts["w"].Concurrency = 2
+
+	w, err := New(opts["w"], b, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Warm(context.Background())
+	if len(paths) != 3 || paths["/a"] != 1 || paths["/c"] != 1 {
+		t.Errorf("unexpected warmed paths %v", paths)
+	}
+}
+
+func TestStartAll(t *testing.T) {
+	done := make(chan struct{}, 1)
+	router := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case done <- struct{}{}:
+		default:
+		}
+	})
+	o := bo.New()
+	b, _ := backends.New("test", o, nil, router, nil)
+	clients := backends.Backends{"test": b}
+
+	_, err := StartAll(wo.Lookup{"w": {Name: "w", Backend: "invalid"}}, clients, nil)
+	if err == nil {
+		t.Error("expected error for invalid backend")
+	}
+
+	opts := wo.Lookup{"w": {Backend: "test", Requests: []*wo.RequestOptions{{Path: "/"}}}}
+	if err := opts.SetDefaults(bo.Lookup{"test": o}); err != nil {
+		t.Fatal(err)
+	}
+	ws, err := StartAll(opts, clients, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("expected warming request")
+	}
+	ws.Stop()
+	tracked.configure(nil)
+}
+
+func TestNewInvalid(t *testing.T) {
+	if _, err := New(nil, nil, nil); err == nil {
+		t.Error("expected error")
+	}
+}
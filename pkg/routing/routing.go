@@ -37,11 +37,15 @@ import (
 	encoding "github.com/trickstercache/trickster/v2/pkg/encoding/handler"
 	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
 	"github.com/trickstercache/trickster/v2/pkg/observability/tracing"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/faults"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/handlers"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/handlers/health"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/methods"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/mirror"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/paths/matching"
 	po "github.com/trickstercache/trickster/v2/pkg/proxy/paths/options"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/request/rewriter"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/upstreamauth"
 	"github.com/trickstercache/trickster/v2/pkg/util/middleware"
 
 	"github.com/gorilla/mux"
@@ -126,6 +130,8 @@ func RegisterProxyRoutes(conf *config.Config, router *mux.Router, metricsRouter
 			return nil, err
 		}
 	}
+	// resolve mirror targets now that all backends' routes are registered
+	mirror.Resolve(clients)
 	err = rule.ValidateOptions(clients, conf.CompiledRewriters)
 	if err != nil {
 		return nil, err
@@ -151,6 +157,17 @@ func RegisterHealthHandler(router *http.ServeMux, path string, hc healthcheck.He
 	router.Handle(path, health.StatusHandler(hc))
 }
 
+// RegisterCacheHandlers registers the cache inspection handlers under the provided path
+func RegisterCacheHandlers(router *http.ServeMux, path string, clients backends.Backends,
+	caches map[string]cache.Cache) {
+	if path == "" {
+		return
+	}
+	path = strings.TrimSuffix(path, "/")
+	router.HandleFunc(path+"/inspect", handlers.CacheInspectHandleFunc(clients))
+	router.HandleFunc(path+"/keys", handlers.CacheKeysHandleFunc(caches))
+}
+
 func registerBackendRoutes(router *mux.Router, metricsRouter *http.ServeMux, conf *config.Config, k string,
 	o *bo.Options, clients backends.Backends, caches map[string]cache.Cache,
 	tracers tracing.Tracers, logger interface{}, dryRun bool,
@@ -245,6 +262,12 @@ func RegisterPathRoutes(router *mux.Router, handlers map[string]http.Handler,
 		if len(po1.ReqRewriter) > 0 {
 			h = rewriter.Rewrite(po1.ReqRewriter, h)
 		}
+		// mirror a sample of requests to the configured mirror backend
+		h = mirror.Handle(client, o, po1, h)
+		// inject any configured faults
+		h = faults.Handle(o, po1, h)
+		// remove inbound credentials that are replaced by upstream auth
+		h = upstreamauth.StripInboundAuthorization(o.UpstreamAuth, h)
 		// decorate frontend prometheus metrics
 		if !po1.NoMetrics {
 			h = middleware.Decorate(o.Name, o.Provider, po1.Path, h)
@@ -375,6 +398,12 @@ func RegisterDefaultBackendRoutes(router *mux.Router, bknds backends.Backends,
 		if len(po.ReqRewriter) > 0 {
 			h = rewriter.Rewrite(po.ReqRewriter, h)
 		}
+		// mirror a sample of requests to the configured mirror backend
+		h = mirror.Handle(client, o, po, h)
+		// inject any configured faults
+		h = faults.Handle(o, po, h)
+		// remove inbound credentials that are replaced by upstream auth
+		h = upstreamauth.StripInboundAuthorization(o.UpstreamAuth, h)
 		// decorate frontend prometheus metrics
 		if !po.NoMetrics {
 			h = middleware.Decorate(o.Name, o.Provider, po.Path, h)
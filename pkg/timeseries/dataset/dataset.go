@@ -22,6 +22,7 @@ package dataset
 
 import (
 	"io"
+	"slices"
 	"sort"
 	"sync"
 	"time"
@@ -84,6 +85,7 @@ func (ds *DataSet) CroppedClone(e timeseries.Extent) timeseries.Timeseries {
 
 	clone := &DataSet{
 		Error:        ds.Error,
+		Warnings:     slices.Clone(ds.Warnings),
 		Sorter:       ds.Sorter,
 		Merger:       ds.Merger,
 		SizeCropper:  ds.SizeCropper,
@@ -177,6 +179,7 @@ func (ds *DataSet) Clone() timeseries.Timeseries {
 	defer ds.UpdateLock.Unlock()
 	clone := &DataSet{
 		Error:        ds.Error,
+		Warnings:     slices.Clone(ds.Warnings),
 		Sorter:       ds.Sorter,
 		Merger:       ds.Merger,
 		SizeCropper:  ds.SizeCropper,
@@ -240,6 +243,7 @@ func (ds *DataSet) DefaultMerger(sortSeries bool, collection ...timeseries.Times
 		if !ok {
 			continue
 		}
+		ds.Warnings = timeseries.MergeWarnings(ds.Warnings, ds2.Warnings)
 		var rmtx sync.RWMutex
 		var rwg sync.WaitGroup
 		for _, r := range ds2.Results {
@@ -549,3 +553,13 @@ func (ds *DataSet) VolatileExtents() timeseries.ExtentList {
 func (ds *DataSet) SetVolatileExtents(e timeseries.ExtentList) {
 	ds.VolatileExtentList = e
 }
+
+// WarningList returns the DataSet-level Warnings
+func (ds *DataSet) WarningList() []string {
+	return ds.Warnings
+}
+
+// SetWarningList replaces the DataSet-level Warnings
+func (ds *DataSet) SetWarningList(w []string) {
+	ds.Warnings = w
+}
@@ -247,6 +247,32 @@ func TestVolatileExtents(t *testing.T) {
 	}
 }
 
+func TestWarnings(t *testing.T) {
+	ds := testDataSet2()
+	if w := timeseries.Warnings(ds); len(w) != 0 {
+		t.Errorf("expected %d got %d", 0, len(w))
+	}
+	timeseries.SetWarnings(ds, []string{"store unavailable"})
+
+	ds2 := testDataSet2()
+	ds2.Warnings = []string{"store unavailable", "partial response"}
+	ds.Merge(false, ds2)
+	w := ds.WarningList()
+	if len(w) != 2 || w[0] != "store unavailable" || w[1] != "partial response" {
+		t.Errorf("unexpected warnings %v", w)
+	}
+
+	c := ds.Clone().(*DataSet)
+	c.Warnings[0] = "x"
+	if ds.Warnings[0] != "store unavailable" {
+		t.Error("clone shares warnings")
+	}
+	c = ds.CroppedClone(timeseries.Extent{Start: time.Unix(5, 0), End: time.Unix(10, 0)}).(*DataSet)
+	if len(c.Warnings) != 2 {
+		t.Errorf("expected %d got %d", 2, len(c.Warnings))
+	}
+}
+
 func TestMarshalDataSet(t *testing.T) {
 	_, err := MarshalDataSet(nil, &timeseries.RequestOptions{}, 200)
 	if err != timeseries.ErrUnknownFormat {
@@ -18,7 +18,10 @@
 // and provides time range manipulation capabilities
 package timeseries
 
-import "time"
+import (
+	"slices"
+	"time"
+)
 
 // Second is 1B, because 1B Nanoseconds == 1 Second
 const Second = 1000000000
@@ -80,3 +83,37 @@ type Timeseries interface {
 	// SetTimeRangeQuery sets the TimeRangeQuery associated with the Timeseries
 	SetTimeRangeQuery(*TimeRangeQuery)
 }
+
+// Warner is an optional interface for Timeseries that carry warnings from the
+// upstream response, such as those indicating the response is partial
+type Warner interface {
+	// WarningList returns the warnings carried by the Timeseries
+	WarningList() []string
+	// SetWarningList replaces the warnings carried by the Timeseries
+	SetWarningList([]string)
+}
+
+// Warnings returns the warnings carried by the Timeseries, or nil if it is not a Warner
+func Warnings(ts Timeseries) []string {
+	if w, ok := ts.(Warner); ok {
+		return w.WarningList()
+	}
+	return nil
+}
+
+// MergeWarnings appends any warnings in w2 that are not already present in w
+func MergeWarnings(w, w2 []string) []string {
+	for _, v := range w2 {
+		if !slices.Contains(w, v) {
+			w = append(w, v)
+		}
+	}
+	return w
+}
+
+// SetWarnings replaces the warnings carried by the Timeseries, if it is a Warner
+func SetWarnings(ts Timeseries, warnings []string) {
+	if w, ok := ts.(Warner); ok {
+		w.SetWarningList(warnings)
+	}
+}
@@ -76,3 +76,8 @@ func (w *responseObserver) Write(b []byte) (int, error) {
 
 	return bytesWritten, err
 }
+
+// Unwrap returns the underlying ResponseWriter, for use by http.ResponseController
+func (w *responseObserver) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
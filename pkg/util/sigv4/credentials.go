@@ -0,0 +1,238 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sigv4
+
+import (
+	"bufio"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoCredentials is returned when no credentials are available
+var ErrNoCredentials = errors.New("no aws credentials available")
+
+// refreshWindow is how long before their expiration temporary credentials are refreshed
+const refreshWindow = 5 * time.Minute
+
+// Provider provides the Credentials used to sign requests
+type Provider interface {
+	// Retrieve returns valid Credentials, or an error if none are available
+	Retrieve(ctx context.Context) (Credentials, error)
+}
+
+// EnvCredentials returns the Credentials in the AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables
+func EnvCredentials() Credentials {
+	return Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+}
+
+// StaticProvider is a Provider of fixed Credentials
+type StaticProvider Credentials
+
+// Retrieve implements Provider
+func (p StaticProvider) Retrieve(context.Context) (Credentials, error) {
+	if c := Credentials(p); c.Valid() {
+		return c, nil
+	}
+	return Credentials{}, ErrNoCredentials
+}
+
+// DefaultCredentialsFile returns the path of the shared credentials file, per the
+// AWS_SHARED_CREDENTIALS_FILE environment variable, or ~/.aws/credentials
+func DefaultCredentialsFile() string {
+	if p := os.Getenv("AWS_SHARED_CREDENTIALS_FILE"); p != "" {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".aws", "credentials")
+}
+
+// FileProvider provides the Credentials of a profile in a shared credentials
+// file. The file is read again whenever it changes, so rotated credentials are used
+type FileProvider struct {
+	Path    string
+	Profile string
+
+	mu      sync.Mutex
+	modTime time.Time
+	creds   Credentials
+}
+
+// Retrieve implements Provider
+func (p *FileProvider) Retrieve(context.Context) (Credentials, error) {
+	fi, err := os.Stat(p.Path)
+	if err != nil {
+		return Credentials{}, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.creds.Valid() && fi.ModTime().Equal(p.modTime) {
+		return p.creds, nil
+	}
+	f, err := os.Open(p.Path)
+	if err != nil {
+		return Credentials{}, err
+	}
+	defer f.Close()
+	c, err := parseCredentialsFile(f, p.Profile)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("%s: %w", p.Path, err)
+	}
+	p.creds, p.modTime = c, fi.ModTime()
+	return c, nil
+}
+
+// parseCredentialsFile returns the credentials of the profile in the INI-formatted
+// shared credentials file
+func parseCredentialsFile(r io.Reader, profile string) (Credentials, error) {
+	if profile == "" {
+		profile = "default"
+	}
+	var c Credentials
+	var inProfile bool
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' && line[len(line)-1] == ']' {
+			inProfile = strings.TrimSpace(line[1:len(line)-1]) == profile
+			continue
+		}
+		if !inProfile {
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		v = strings.TrimSpace(v)
+		switch strings.ToLower(strings.TrimSpace(k)) {
+		case "aws_access_key_id":
+			c.AccessKeyID = v
+		case "aws_secret_access_key":
+			c.SecretAccessKey = v
+		case "aws_session_token":
+			c.SessionToken = v
+		}
+	}
+	if err := s.Err(); err != nil {
+		return Credentials{}, err
+	}
+	if !c.Valid() {
+		return Credentials{}, fmt.Errorf("%w for profile %s", ErrNoCredentials, profile)
+	}
+	return c, nil
+}
+
+// WebIdentityProvider provides temporary Credentials by assuming a role with
+// the web identity token in TokenFile (e.g., an EKS service account token), via
+// the STS AssumeRoleWithWebIdentity action. Credentials are cached until shortly
+// before they expire
+type WebIdentityProvider struct {
+	RoleARN     string
+	TokenFile   string
+	SessionName string
+	// Endpoint is the URL of the STS API, such as https://sts.us-east-1.amazonaws.com
+	Endpoint string
+	Client   *http.Client
+
+	mu      sync.Mutex
+	creds   Credentials
+	expires time.Time
+}
+
+type assumeRoleWithWebIdentityResponse struct {
+	Result struct {
+		Credentials struct {
+			AccessKeyID     string    `xml:"AccessKeyId"`
+			SecretAccessKey string    `xml:"SecretAccessKey"`
+			SessionToken    string    `xml:"SessionToken"`
+			Expiration      time.Time `xml:"Expiration"`
+		} `xml:"Credentials"`
+	} `xml:"AssumeRoleWithWebIdentityResult"`
+}
+
+// Retrieve implements Provider
+func (p *WebIdentityProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.creds.Valid() && time.Now().Add(refreshWindow).Before(p.expires) {
+		return p.creds, nil
+	}
+	token, err := os.ReadFile(p.TokenFile)
+	if err != nil {
+		return Credentials{}, err
+	}
+	v := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {p.RoleARN},
+		"RoleSessionName":  {p.SessionName},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, p.Endpoint,
+		strings.NewReader(v.Encode()))
+	if err != nil {
+		return Credentials{}, err
+	}
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(r)
+	if err != nil {
+		return Credentials{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return Credentials{}, fmt.Errorf("sts AssumeRoleWithWebIdentity failed: %d %s",
+			resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+	var out assumeRoleWithWebIdentityResponse
+	if err = xml.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return Credentials{}, err
+	}
+	rc := out.Result.Credentials
+	c := Credentials{AccessKeyID: rc.AccessKeyID, SecretAccessKey: rc.SecretAccessKey,
+		SessionToken: rc.SessionToken}
+	if !c.Valid() {
+		return Credentials{}, ErrNoCredentials
+	}
+	p.creds, p.expires = c, rc.Expiration
+	return c, nil
+}
@@ -0,0 +1,169 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sigv4
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testCredentialsFile = `
+# comment
+[default]
+aws_access_key_id = AKIDDEFAULT
+aws_secret_access_key = secretdefault
+
+[test]
+aws_access_key_id=AKIDTEST
+aws_secret_access_key=secrettest
+aws_session_token=tokentest
+`
+
+func TestParseCredentialsFile(t *testing.T) {
+	c, err := parseCredentialsFile(strings.NewReader(testCredentialsFile), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.AccessKeyID != "AKIDDEFAULT" || c.SecretAccessKey != "secretdefault" || c.SessionToken != "" {
+		t.Errorf("unexpected credentials %+v", c)
+	}
+	c, err = parseCredentialsFile(strings.NewReader(testCredentialsFile), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.AccessKeyID != "AKIDTEST" || c.SecretAccessKey != "secrettest" || c.SessionToken != "tokentest" {
+		t.Errorf("unexpected credentials %+v", c)
+	}
+	_, err = parseCredentialsFile(strings.NewReader(testCredentialsFile), "missing")
+	if !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("expected %v got %v", ErrNoCredentials, err)
+	}
+}
+
+func TestFileProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+	p := &FileProvider{Path: path, Profile: "test"}
+	if _, err := p.Retrieve(context.Background()); err == nil {
+		t.Error("expected error for missing file")
+	}
+	if err := os.WriteFile(path, []byte(testCredentialsFile), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := p.Retrieve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.AccessKeyID != "AKIDTEST" {
+		t.Errorf("unexpected credentials %+v", c)
+	}
+	// rotated credentials are read once the file changes
+	rotated := strings.ReplaceAll(testCredentialsFile, "AKIDTEST", "AKIDROTATED")
+	if err = os.WriteFile(path, []byte(rotated), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	if err = os.Chtimes(path, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if c, _ = p.Retrieve(context.Background()); c.AccessKeyID != "AKIDROTATED" {
+		t.Errorf("expected rotated credentials got %+v", c)
+	}
+}
+
+func TestEnvCredentials(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "a")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "b")
+	t.Setenv("AWS_SESSION_TOKEN", "c")
+	c := EnvCredentials()
+	if c.AccessKeyID != "a" || c.SecretAccessKey != "b" || c.SessionToken != "c" {
+		t.Errorf("unexpected credentials %+v", c)
+	}
+	if _, err := StaticProvider(c).Retrieve(context.Background()); err != nil {
+		t.Error(err)
+	}
+	if _, err := (StaticProvider{}).Retrieve(context.Background()); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("expected %v got %v", ErrNoCredentials, err)
+	}
+}
+
+const testSTSResponse = `<AssumeRoleWithWebIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleWithWebIdentityResult>
+    <Credentials>
+      <AccessKeyId>ASIATEST</AccessKeyId>
+      <SecretAccessKey>secret</SecretAccessKey>
+      <SessionToken>session</SessionToken>
+      <Expiration>%s</Expiration>
+    </Credentials>
+  </AssumeRoleWithWebIdentityResult>
+</AssumeRoleWithWebIdentityResponse>`
+
+func TestWebIdentityProvider(t *testing.T) {
+	var calls int
+	expiration := time.Now().Add(time.Hour)
+	sts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		r.ParseForm()
+		if r.Form.Get("Action") != "AssumeRoleWithWebIdentity" ||
+			r.Form.Get("RoleArn") != "arn:aws:iam::123456789012:role/test" ||
+			r.Form.Get("RoleSessionName") != "trickster" ||
+			r.Form.Get("WebIdentityToken") != "web-token" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, testSTSResponse, expiration.UTC().Format(time.RFC3339))
+	}))
+	defer sts.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("web-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p := &WebIdentityProvider{RoleARN: "arn:aws:iam::123456789012:role/test",
+		TokenFile: tokenFile, SessionName: "trickster", Endpoint: sts.URL}
+	c, err := p.Retrieve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.AccessKeyID != "ASIATEST" || c.SecretAccessKey != "secret" || c.SessionToken != "session" {
+		t.Errorf("unexpected credentials %+v", c)
+	}
+	// credentials are cached until shortly before they expire
+	p.Retrieve(context.Background())
+	if calls != 1 {
+		t.Errorf("expected 1 sts call got %d", calls)
+	}
+	expiration = time.Now().Add(time.Minute)
+	p.expires = expiration
+	p.Retrieve(context.Background())
+	if calls != 2 {
+		t.Errorf("expected 2 sts calls got %d", calls)
+	}
+
+	p = &WebIdentityProvider{RoleARN: "arn:aws:iam::123456789012:role/other",
+		TokenFile: tokenFile, SessionName: "trickster", Endpoint: sts.URL}
+	if _, err = p.Retrieve(context.Background()); err == nil {
+		t.Error("expected error for failed sts request")
+	}
+}
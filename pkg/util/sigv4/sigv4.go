@@ -0,0 +1,196 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package sigv4 provides AWS Signature Version 4 request signing to Trickster
+package sigv4
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// Algorithm is the SigV4 signing algorithm name
+	Algorithm = "AWS4-HMAC-SHA256"
+	// EmptyPayloadHash is the hex-encoded SHA-256 hash of an empty payload
+	EmptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	// UnsignedPayload may be used as the payload hash when the payload is not signed
+	UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+	// HeaderDate is the header carrying the signing time
+	HeaderDate = "X-Amz-Date"
+	// HeaderContentSHA256 is the header carrying the payload hash, required by S3
+	HeaderContentSHA256 = "X-Amz-Content-Sha256"
+	// HeaderSecurityToken is the header carrying a temporary credential's session token
+	HeaderSecurityToken = "X-Amz-Security-Token"
+
+	timeFormat = "20060102T150405Z"
+	dateFormat = "20060102"
+)
+
+// Credentials are AWS credentials used to sign requests
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// Valid returns true if the Credentials include a key ID and secret
+func (c Credentials) Valid() bool {
+	return c.AccessKeyID != "" && c.SecretAccessKey != ""
+}
+
+// signedHeaders are the non-x-amz headers included in the signature when present.
+// Other headers are excluded, since proxies and transports may change them
+var signedHeaders = map[string]bool{
+	"content-md5":  true,
+	"content-type": true,
+	"range":        true,
+}
+
+// PayloadHash returns the hex-encoded SHA-256 hash of the payload
+func PayloadHash(payload []byte) string {
+	if len(payload) == 0 {
+		return EmptyPayloadHash
+	}
+	h := sha256.Sum256(payload)
+	return hex.EncodeToString(h[:])
+}
+
+// Sign signs the request for the region and service at time t, by setting its
+// X-Amz-Date and Authorization headers (and X-Amz-Content-Sha256 for S3, and
+// X-Amz-Security-Token for temporary credentials). payloadHash is the result of
+// PayloadHash for the request body, or UnsignedPayload
+func Sign(r *http.Request, creds Credentials, region, service, payloadHash string, t time.Time) {
+	t = t.UTC()
+	amzDate := t.Format(timeFormat)
+	r.Header.Set(HeaderDate, amzDate)
+	if service == "s3" {
+		r.Header.Set(HeaderContentSHA256, payloadHash)
+	}
+	if creds.SessionToken != "" {
+		r.Header.Set(HeaderSecurityToken, creds.SessionToken)
+	}
+	r.Header.Del("Authorization")
+
+	host := r.Host
+	if host == "" {
+		host = r.URL.Host
+	}
+
+	names := []string{"host"}
+	values := map[string]string{"host": host}
+	for k, v := range r.Header {
+		lk := strings.ToLower(k)
+		if !signedHeaders[lk] && !strings.HasPrefix(lk, "x-amz-") {
+			continue
+		}
+		names = append(names, lk)
+		trimmed := make([]string, len(v))
+		for i := range v {
+			trimmed[i] = strings.Join(strings.Fields(v[i]), " ")
+		}
+		values[lk] = strings.Join(trimmed, ",")
+	}
+	sort.Strings(names)
+
+	var ch strings.Builder
+	for _, n := range names {
+		ch.WriteString(n)
+		ch.WriteByte(':')
+		ch.WriteString(values[n])
+		ch.WriteByte('\n')
+	}
+	sh := strings.Join(names, ";")
+
+	path := canonicalPath(r.URL.Path, service != "s3")
+	cr := strings.Join([]string{r.Method, path, canonicalQuery(r.URL.Query()),
+		ch.String(), sh, payloadHash}, "\n")
+
+	scope := strings.Join([]string{t.Format(dateFormat), region, service, "aws4_request"}, "/")
+	crh := sha256.Sum256([]byte(cr))
+	sts := strings.Join([]string{Algorithm, amzDate, scope, hex.EncodeToString(crh[:])}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), t.Format(dateFormat))
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	sig := hex.EncodeToString(hmacSHA256(key, sts))
+
+	r.Header.Set("Authorization", Algorithm+" Credential="+creds.AccessKeyID+"/"+scope+
+		", SignedHeaders="+sh+", Signature="+sig)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalPath returns the URI-encoded path. Services other than S3 encode
+// each path segment twice
+func canonicalPath(p string, double bool) string {
+	if p == "" {
+		return "/"
+	}
+	out := Escape(p, false)
+	if double {
+		out = Escape(out, false)
+	}
+	return out
+}
+
+// canonicalQuery returns the query parameters sorted by name and value and URI-encoded
+func canonicalQuery(q url.Values) string {
+	if len(q) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(q))
+	for k, vs := range q {
+		ek := Escape(k, true)
+		for _, v := range vs {
+			pairs = append(pairs, ek+"="+Escape(v, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// Escape URI-encodes every byte of s except the unreserved characters
+// (A-Z, a-z, 0-9, '-', '.', '_' and '~'), and '/' unless encodeSlash is true
+func Escape(s string, encodeSlash bool) string {
+	const hexChars = "0123456789ABCDEF"
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '.' || c == '_' || c == '~' || (c == '/' && !encodeSlash) {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hexChars[c>>4])
+		b.WriteByte(hexChars[c&15])
+	}
+	return b.String()
+}